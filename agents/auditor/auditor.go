@@ -0,0 +1,246 @@
+// Package auditor provides the LLM auditor, a critic + reviser agent pipeline that
+// fact-checks an answer and minimally revises it, for embedding fact-checking in
+// agent pipelines.
+//
+// The critic verifies the claims of the answer with its grounding tools (e.g.
+// geminitool.GoogleSearch, or the search_memory tool of tools/memory) and its own
+// knowledge. Its verdicts are parsed into a Report, stored in the CustomMetadata of
+// its event under MetadataKey.
+package auditor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/log"
+	"google.golang.org/adk/agent"
+	"google.golang.org/adk/agent/llmagent"
+	"google.golang.org/adk/agent/workflowagents/sequentialagent"
+	"google.golang.org/adk/model"
+	"google.golang.org/adk/tool"
+	"google.golang.org/genai"
+)
+
+const defaultName = "llm_auditor"
+
+// Config configures the auditor pipeline.
+type Config struct {
+	// Model is the LLM of the critic and the reviser.
+	Model model.LLM
+
+	// Optional. CriticModel overrides Model for the critic.
+	CriticModel model.LLM
+
+	// Optional. ReviserModel overrides Model for the reviser.
+	ReviserModel model.LLM
+
+	// Optional. Name of the pipeline agent. Default: "llm_auditor".
+	Name string
+
+	// Optional. GroundingTools are used by the critic to verify claims, e.g.
+	// geminitool.GoogleSearch{} (Gemini models only, and not combined with other tools).
+	// Without grounding, the critic relies on its own knowledge.
+	GroundingTools []tool.Tool
+
+	// Optional. GroundingToolsets are used by the critic to verify claims, e.g. the
+	// memory toolset of tools/memory to check answers against long-term memory.
+	GroundingToolsets []tool.Toolset
+
+	// Optional. CriticPrompt is the instruction of the critic. It should include
+	// ReportFormat for the verdicts to be parsed. Default: DefaultCriticPrompt.
+	CriticPrompt string
+
+	// Optional. ReviserPrompt is the instruction of the reviser. It must ask for
+	// EndMark after the revised answer. Default: DefaultReviserPrompt.
+	ReviserPrompt string
+
+	// Optional. Logger for logging. Falls back to `DiscardLog` if nil.
+	Logger log.Logger
+}
+
+// New creates the auditor pipeline, a sequential agent of two stages:
+//  1. Critic: identifies the claims of the answer, verifies them with its grounding
+//     tools and knowledge, and gives verdicts with justifications (a Report).
+//  2. Reviser: minimally edits the answer to fix the findings of the critic, keeping
+//     its style.
+func New(cfg Config) (agent.Agent, error) {
+	if cfg.Model == nil && (cfg.CriticModel == nil || cfg.ReviserModel == nil) {
+		return nil, errors.New("model cannot be nil")
+	}
+	if cfg.CriticModel == nil {
+		cfg.CriticModel = cfg.Model
+	}
+	if cfg.ReviserModel == nil {
+		cfg.ReviserModel = cfg.Model
+	}
+	if cfg.Name == "" {
+		cfg.Name = defaultName
+	}
+	if cfg.CriticPrompt == "" {
+		cfg.CriticPrompt = DefaultCriticPrompt
+	}
+	if cfg.ReviserPrompt == "" {
+		cfg.ReviserPrompt = DefaultReviserPrompt
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = discardlog.NewDiscardLog()
+	}
+
+	a := &auditor{Logger: cfg.Logger}
+
+	criticAgent, err := llmagent.New(llmagent.Config{
+		Model:               cfg.CriticModel,
+		Name:                "critic_agent",
+		Instruction:         cfg.CriticPrompt,
+		Tools:               cfg.GroundingTools,
+		Toolsets:            cfg.GroundingToolsets,
+		AfterModelCallbacks: []llmagent.AfterModelCallback{a.afterCritic},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create critic agent: %w", err)
+	}
+
+	reviserAgent, err := llmagent.New(llmagent.Config{
+		Model:               cfg.ReviserModel,
+		Name:                "reviser_agent",
+		Instruction:         cfg.ReviserPrompt,
+		AfterModelCallbacks: []llmagent.AfterModelCallback{afterReviser},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reviser agent: %w", err)
+	}
+
+	pipeline, err := sequentialagent.New(sequentialagent.Config{
+		AgentConfig: agent.Config{
+			Name:        cfg.Name,
+			Description: "Evaluates LLM-generated answers.",
+			SubAgents:   []agent.Agent{criticAgent, reviserAgent},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create auditor agent: %w", err)
+	}
+
+	cfg.Logger.Infof("llm auditor created: name=%s, grounding_tools=%d, grounding_toolsets=%d",
+		cfg.Name, len(cfg.GroundingTools), len(cfg.GroundingToolsets))
+
+	return pipeline, nil
+}
+
+type auditor struct {
+	log.Logger
+}
+
+// afterCritic appends the references of the grounding metadata (URLs, titles, text
+// snippets) to the critic's response, consolidates its text parts and moves its
+// verdicts into a Report in the response CustomMetadata.
+func (a *auditor) afterCritic(
+	_ agent.CallbackContext,
+	resp *model.LLMResponse,
+	_ error,
+) (*model.LLMResponse, error) {
+	if resp == nil || resp.Content == nil || resp.Content.Parts == nil || resp.Partial {
+		return nil, nil
+	}
+
+	// Grounding tool calls are intermediate steps, the verdicts come after them
+	for _, part := range resp.Content.Parts {
+		if part != nil && part.FunctionCall != nil {
+			return nil, nil
+		}
+	}
+
+	text := joinText(resp.Content.Parts)
+
+	report, rest, ok := extractReport(text)
+	if ok {
+		text = rest
+		if resp.CustomMetadata == nil {
+			resp.CustomMetadata = make(map[string]any)
+		}
+		resp.CustomMetadata[MetadataKey] = report
+		a.Infof("audit report: claims=%d, overall_verdict=%s", len(report.Claims), report.OverallVerdict)
+	} else {
+		a.Warnf("critic response has no parsable verdicts")
+	}
+
+	if references := groundingReferences(resp.GroundingMetadata); len(references) > 0 {
+		text += "\n\nReference:\n\n" + strings.Join(references, "")
+	}
+
+	if text != "" {
+		resp.Content.Parts = []*genai.Part{{Text: text}}
+	}
+	return resp, nil
+}
+
+// afterReviser strips EndMark and everything after it from the reviser's response,
+// leaving only the revised answer.
+func afterReviser(
+	_ agent.CallbackContext,
+	resp *model.LLMResponse,
+	_ error,
+) (*model.LLMResponse, error) {
+	if resp == nil || resp.Content == nil || resp.Content.Parts == nil {
+		return nil, nil
+	}
+
+	for idx, part := range resp.Content.Parts {
+		if before, _, found := strings.Cut(part.Text, EndMark); found {
+			part.Text = strings.TrimRight(before, "\n")
+			resp.Content.Parts = resp.Content.Parts[:idx+1]
+			break
+		}
+	}
+
+	return resp, nil
+}
+
+// groundingReferences returns a Markdown list item per grounding chunk (retrieved
+// context or web search result).
+func groundingReferences(meta *genai.GroundingMetadata) []string {
+	if meta == nil {
+		return nil
+	}
+
+	var references []string
+	for _, chunk := range meta.GroundingChunks {
+		if chunk == nil {
+			continue
+		}
+
+		var title, uri, text string
+		if chunk.RetrievedContext != nil {
+			title = chunk.RetrievedContext.Title
+			uri = chunk.RetrievedContext.URI
+			text = chunk.RetrievedContext.Text
+		} else if chunk.Web != nil {
+			title = chunk.Web.Title
+			uri = chunk.Web.URI
+		}
+
+		var parts []string
+		for _, s := range []string{title, uri, text} {
+			if s != "" {
+				parts = append(parts, s)
+			}
+		}
+		if len(parts) > 0 {
+			references = append(references, "* "+strings.Join(parts, ": ")+"\n")
+		}
+	}
+
+	return references
+}
+
+func joinText(parts []*genai.Part) string {
+	var sb strings.Builder
+	for _, part := range parts {
+		if part != nil && part.Text != "" && !part.Thought {
+			sb.WriteString(part.Text)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,111 @@
+package auditor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bytedance/sonic"
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+const criticOutput = "* Claim 1: The sun is cube-shaped.\n    * Verdict: Inaccurate\n\n" +
+	"```json\n" +
+	`{"claims": [` +
+	`{"claim": "The sun is cube-shaped.", "quote": "cube-shaped", "verdict": "Inaccurate", "justification": "It is a sphere."},` +
+	`{"claim": "The sun is very hot.", "verdict": "Accurate", "justification": "Known."}],` +
+	`"overall_verdict": "Inaccurate", "overall_justification": "Wrong shape."}` +
+	"\n```\n"
+
+func TestExtractReport(t *testing.T) {
+	report, rest, ok := extractReport(criticOutput)
+	if !ok {
+		t.Fatal("expected a report")
+	}
+	if len(report.Claims) != 2 || report.OverallVerdict != VerdictInaccurate {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if report.Claims[0].Quote != "cube-shaped" || report.Count(VerdictInaccurate) != 1 || report.Accurate() {
+		t.Errorf("unexpected claims: %+v", report.Claims)
+	}
+	if strings.Contains(rest, "```") || !strings.Contains(rest, "Claim 1") {
+		t.Errorf("expected the json block removed, got %q", rest)
+	}
+
+	for _, text := range []string{"no report", "```json\n{not json}\n```", "```json\n{\"claims\": []}"} {
+		if _, rest, ok := extractReport(text); ok || rest != text {
+			t.Errorf("extractReport(%q) = %q, %v", text, rest, ok)
+		}
+	}
+}
+
+func TestReportFromMetadata(t *testing.T) {
+	report, _, _ := extractReport(criticOutput)
+
+	if got := ReportFromMetadata(map[string]any{MetadataKey: report}); got != report {
+		t.Error("expected the typed report")
+	}
+
+	// Events loaded back from a session store hold the JSON-decoded form
+	data, _ := sonic.Marshal(map[string]any{MetadataKey: report})
+	var decoded map[string]any
+	if err := sonic.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	got := ReportFromMetadata(decoded)
+	if got == nil || len(got.Claims) != 2 || got.Claims[1].Verdict != VerdictAccurate {
+		t.Errorf("unexpected decoded report: %+v", got)
+	}
+
+	if ReportFromMetadata(nil) != nil {
+		t.Error("expected no report without metadata")
+	}
+}
+
+func TestAfterCritic(t *testing.T) {
+	a := &auditor{Logger: discardlog.NewDiscardLog()}
+
+	resp := &model.LLMResponse{
+		Content: &genai.Content{Parts: []*genai.Part{{Text: criticOutput[:40]}, {Text: criticOutput[40:]}}},
+		GroundingMetadata: &genai.GroundingMetadata{GroundingChunks: []*genai.GroundingChunk{
+			{Web: &genai.GroundingChunkWeb{Title: "NASA", URI: "https://nasa.gov/sun"}},
+		}},
+	}
+
+	got, err := a.afterCritic(nil, resp, nil)
+	if err != nil || got == nil {
+		t.Fatalf("afterCritic() = %v, %v", got, err)
+	}
+	if len(got.Content.Parts) != 1 {
+		t.Fatalf("expected consolidated text, got %d parts", len(got.Content.Parts))
+	}
+	text := got.Content.Parts[0].Text
+	if strings.Contains(text, "```json") || !strings.Contains(text, "* NASA: https://nasa.gov/sun") {
+		t.Errorf("unexpected critic text: %q", text)
+	}
+	if report := ReportFromMetadata(got.CustomMetadata); report == nil || len(report.Claims) != 2 {
+		t.Errorf("expected the report in CustomMetadata, got %v", got.CustomMetadata)
+	}
+
+	// Grounding tool calls pass through
+	call := &model.LLMResponse{Content: &genai.Content{Parts: []*genai.Part{
+		{Text: "Let me search."},
+		{FunctionCall: &genai.FunctionCall{Name: "search_memory"}},
+	}}}
+	if got, _ := a.afterCritic(nil, call, nil); got != nil || len(call.Content.Parts) != 2 {
+		t.Error("expected function call responses untouched")
+	}
+}
+
+func TestAfterReviser(t *testing.T) {
+	resp := &model.LLMResponse{Content: &genai.Content{Parts: []*genai.Part{
+		{Text: "The sun is sphere-shaped and very hot.\n" + EndMark + "\nextra"},
+		{Text: "trailing"},
+	}}}
+
+	got, _ := afterReviser(nil, resp, nil)
+	if len(got.Content.Parts) != 1 || got.Content.Parts[0].Text != "The sun is sphere-shaped and very hot." {
+		t.Errorf("unexpected revised answer: %+v", got.Content.Parts)
+	}
+}
@@ -1,31 +1,30 @@
-// Package agents provides example agent implementations using Google ADK.
-package agents
-
-import (
-	"context"
-	"log"
-	"strings"
-
-	"google.golang.org/adk/agent"
-	"google.golang.org/adk/agent/llmagent"
-	"google.golang.org/adk/agent/workflowagents/sequentialagent"
-	"google.golang.org/adk/model"
-	"google.golang.org/genai"
-)
+package auditor
 
 // EndMark is the delimiter that signals the end of the reviser's edited output.
 // The reviser agent outputs this marker after its revised answer text.
 const EndMark = "---END-OF-EDIT---"
 
-// CriticPrompt is the system instruction for the critic agent.
+// ReportFormat asks the critic to repeat its verification result as JSON, which
+// is parsed into a Report. Custom critic prompts should include it.
+//
+//nolint:lll
+const ReportFormat = `
+After the Markdown-formatted list, output the same verification result as a JSON object in a fenced code block marked as json, with the following keys:
+  * "claims": a list with an object per CLAIM, with the keys "claim" (the standalone statement), "quote" (the corresponding part in the answer text), "verdict" and "justification".
+  * "overall_verdict" and "overall_justification".
+Use exactly the VERDICT names above (Accurate, Inaccurate, Disputed, Unsupported, Not Applicable). The JSON block must be the last block of your output.
+`
+
+// DefaultCriticPrompt is the system instruction for the critic agent.
 // It instructs the LLM to act as an investigative journalist who:
 // 1. Identifies all claims in an answer text
 // 2. Verifies each claim using web search and knowledge
 // 3. Assigns verdicts (Accurate, Inaccurate, Disputed, Unsupported, Not Applicable)
 // 4. Provides an overall assessment of the answer's accuracy
+// 5. Repeats the verdicts as JSON (ReportFormat), parsed into a Report
 //
 //nolint:lll
-const CriticPrompt = `
+const DefaultCriticPrompt = `
 You are a professional investigative journalist, excelling at critical thinking and verifying information before printed to a highly-trustworthy publication.
 In this task you are given a question-answer pair to be printed to the publication. The publication editor tasked you to double-check the answer text.
 
@@ -71,11 +70,11 @@ There are various actions you can take to help you with the verification:
 # Output format
 
 The last block of your output should be a Markdown-formatted list, summarizing your verification result. For each CLAIM you verified, you should output the claim (as a standalone statement), the corresponding part in the answer text, the verdict, and the justification.
-
+` + ReportFormat + `
 Here is the question and answer you are going to double check:
 `
 
-// ReviserPrompt is the system instruction for the reviser agent.
+// DefaultReviserPrompt is the system instruction for the reviser agent.
 // It instructs the LLM to act as a professional editor who:
 // 1. Takes the critic's findings about claim accuracy
 // 2. Minimally revises the answer to fix inaccuracies
@@ -83,7 +82,7 @@ Here is the question and answer you are going to double check:
 // 4. Outputs the revised text followed by the EndMark delimiter
 //
 //nolint:lll
-const ReviserPrompt = `
+const DefaultReviserPrompt = `
 You are a professional editor working for a highly-trustworthy publication.
 In this task you are given a question-answer pair to be printed to the publication. The publication reviewer has double-checked the answer text and provided the findings.
 Your task is to minimally revise the answer text to make it accurate, while maintaining the overall structure, style, and length similar to the original.
@@ -157,151 +156,3 @@ The sun is sphere-shaped and very hot.
 
 Here are the question-answer pair and the reviewer-provided findings:
 `
-
-// afterCriticCB is a callback executed after the critic agent's model call.
-// It processes the grounding metadata from the LLM response to extract
-// references (URLs, titles, text snippets) that were used during fact-checking.
-// These references are appended to the response content for transparency.
-// Finally, it consolidates all text parts into a single part for cleaner output.
-func afterCriticCB(
-	_ agent.CallbackContext,
-	resp *model.LLMResponse,
-	_ error,
-) (*model.LLMResponse, error) {
-	// Early return if response or grounding metadata is missing
-	if resp == nil || resp.Content == nil || resp.Content.Parts == nil ||
-		resp.GroundingMetadata == nil {
-		return resp, nil
-	}
-
-	// Extract references from grounding chunks (web searches, retrieved context)
-	references := []string{}
-	for _, chunk := range resp.GroundingMetadata.GroundingChunks {
-		var title, uri, text string
-		// Handle retrieved context (e.g., from RAG systems)
-		if chunk.RetrievedContext != nil {
-			title = chunk.RetrievedContext.Title
-			uri = chunk.RetrievedContext.URI
-			text = chunk.RetrievedContext.Text
-		} else if chunk.Web != nil {
-			// Handle web search results
-			title = chunk.Web.Title
-			uri = chunk.Web.URI
-		}
-
-		// Build reference string from available parts
-		reference := ""
-		parts := []string{}
-		if title != "" {
-			parts = append(parts, title)
-		}
-		if uri != "" {
-			parts = append(parts, uri)
-		}
-		if text != "" {
-			parts = append(parts, text)
-		}
-
-		if len(parts) > 0 {
-			reference = "* " + strings.Join(parts, ": ") + "\n"
-		}
-		if reference != "" {
-			references = append(references, reference)
-		}
-	}
-
-	// Append references section if any were found
-	if len(references) > 0 {
-		reference := "\n\nReference:\n\n" + strings.Join(references, "")
-		resp.Content.Parts = append(resp.Content.Parts, &genai.Part{Text: reference})
-	}
-
-	// Consolidate all text parts into a single part for cleaner output
-	textParts := []string{}
-	for _, part := range resp.Content.Parts {
-		if part.Text != "" {
-			textParts = append(textParts, part.Text)
-		}
-	}
-	text := strings.Join(textParts, "")
-	if text != "" {
-		resp.Content.Parts = []*genai.Part{{Text: text}}
-	}
-	return resp, nil
-}
-
-// afterReviserCB is a callback executed after the reviser agent's model call.
-// It strips the EndMark delimiter and any content after it from the response,
-// leaving only the clean revised answer text.
-func afterReviserCB(
-	_ agent.CallbackContext,
-	resp *model.LLMResponse,
-	_ error,
-) (*model.LLMResponse, error) {
-	if resp == nil || resp.Content == nil || resp.Content.Parts == nil {
-		return resp, nil
-	}
-
-	// Find and remove the EndMark delimiter and everything after it
-	for idx, part := range resp.Content.Parts {
-		if strings.Contains(part.Text, EndMark) {
-			// Truncate parts list to exclude parts after the marker
-			resp.Content.Parts = resp.Content.Parts[:idx]
-			// Remove the marker from the current part
-			part.Text = strings.SplitN(part.Text, EndMark, 1)[0]
-		}
-	}
-
-	return resp, nil
-}
-
-// LLMAuditorAgent creates a sequential agent pipeline for auditing LLM-generated answers.
-// The pipeline consists of two stages:
-//  1. Critic Agent: Analyzes the answer, identifies claims, verifies them using
-//     web search and knowledge, and provides verdicts with justifications.
-//  2. Reviser Agent: Takes the critic's findings and minimally edits the answer
-//     to fix any inaccuracies while maintaining the original style.
-//
-// This implements a "self-correction" pattern where LLM output is verified and
-// improved through a multi-agent workflow.
-func LLMAuditorAgent(_ context.Context, model model.LLM) agent.Agent {
-	// Create the critic agent that analyzes and verifies claims
-	criticAgent, err := llmagent.New(llmagent.Config{
-		Model:               model,
-		Name:                "critic_agent",
-		Instruction:         CriticPrompt,
-		AfterModelCallbacks: []llmagent.AfterModelCallback{afterCriticCB},
-	})
-	if err != nil {
-		log.Fatalf("Failed to create critic agent: %v", err)
-	}
-
-	// Create the reviser agent that fixes identified issues
-	reviserAgent, err := llmagent.New(llmagent.Config{
-		Model:               model,
-		Name:                "reviser_agent",
-		Instruction:         ReviserPrompt,
-		AfterModelCallbacks: []llmagent.AfterModelCallback{afterReviserCB},
-	})
-	if err != nil {
-		log.Fatalf("Failed to create reviser agent: %v", err)
-	}
-
-	// Create the root sequential agent that orchestrates the pipeline
-	// The critic runs first, then the reviser processes the findings
-	rootAgent, err := sequentialagent.New(sequentialagent.Config{
-		AgentConfig: agent.Config{
-			Name:        "llm_auditor",
-			Description: "Evaluates LLM-generated answers.",
-			SubAgents: []agent.Agent{
-				criticAgent,
-				reviserAgent,
-			},
-		},
-	})
-	if err != nil {
-		log.Fatalf("Failed to create root agent: %v", err)
-	}
-
-	return rootAgent
-}
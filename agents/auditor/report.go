@@ -0,0 +1,103 @@
+package auditor
+
+import (
+	"strings"
+
+	"github.com/bytedance/sonic"
+)
+
+// MetadataKey is the LLMResponse.CustomMetadata key under which the critic's
+// Report is stored, and so found in the CustomMetadata of its event.
+const MetadataKey = "audit"
+
+// Verdicts assigned to claims and answers.
+const (
+	VerdictAccurate      = "Accurate"
+	VerdictInaccurate    = "Inaccurate"
+	VerdictDisputed      = "Disputed"
+	VerdictUnsupported   = "Unsupported"
+	VerdictNotApplicable = "Not Applicable"
+)
+
+// Claim is a claim of the audited answer with its verdict.
+type Claim struct {
+	// Claim is the claim as a standalone statement.
+	Claim string `json:"claim"`
+	// Quote is the part of the answer that makes the claim.
+	Quote         string `json:"quote,omitempty"`
+	Verdict       string `json:"verdict"`
+	Justification string `json:"justification"`
+}
+
+// Report is the structured result of the critic.
+type Report struct {
+	Claims               []Claim `json:"claims"`
+	OverallVerdict       string  `json:"overall_verdict"`
+	OverallJustification string  `json:"overall_justification"`
+}
+
+// Count returns the number of claims with verdict.
+func (r *Report) Count(verdict string) int {
+	n := 0
+	for _, c := range r.Claims {
+		if strings.EqualFold(c.Verdict, verdict) {
+			n++
+		}
+	}
+	return n
+}
+
+// Accurate reports whether every verified claim is accurate (or not applicable).
+func (r *Report) Accurate() bool {
+	return r.Count(VerdictAccurate)+r.Count(VerdictNotApplicable) == len(r.Claims)
+}
+
+// ReportFromMetadata returns the report stored under MetadataKey, or nil.
+// It accepts both the typed value set by the critic and its JSON-decoded form, as
+// found in events loaded back from a session store.
+func ReportFromMetadata(meta map[string]any) *Report {
+	raw, ok := meta[MetadataKey]
+	if !ok || raw == nil {
+		return nil
+	}
+
+	if report, ok := raw.(*Report); ok {
+		return report
+	}
+
+	data, err := sonic.Marshal(raw)
+	if err != nil {
+		return nil
+	}
+
+	var report Report
+	if err := sonic.Unmarshal(data, &report); err != nil {
+		return nil
+	}
+	return &report
+}
+
+// extractReport parses the last json code block of text into a Report and returns
+// text without the block. ok is false if text has no valid block.
+func extractReport(text string) (report *Report, rest string, ok bool) {
+	const fence = "```"
+
+	start := strings.LastIndex(text, fence+"json")
+	if start < 0 {
+		return nil, text, false
+	}
+
+	body := text[start+len(fence)+len("json"):]
+	end := strings.Index(body, fence)
+	if end < 0 {
+		return nil, text, false
+	}
+
+	report = &Report{}
+	if err := sonic.UnmarshalString(strings.TrimSpace(body[:end]), report); err != nil {
+		return nil, text, false
+	}
+
+	rest = strings.TrimRight(text[:start], " \n") + body[end+len(fence):]
+	return report, rest, true
+}
@@ -0,0 +1,292 @@
+// Command kadm administers the sessions stored by k-adk in Redis and PostgreSQL
+// through the library APIs, so operators do not script raw SQL or redis-cli.
+//
+// Usage:
+//
+//	kadm [-config config.yaml] [-verbose] session <command> [flags]
+//
+// Commands:
+//
+//	session dump     Dump a session, or every session of a user, as JSON lines
+//	session restore  Restore dumped sessions into Redis or PostgreSQL
+//	session prune    Prune old sessions of an app from PostgreSQL
+//	session stats    Report the sessions, events and storage of an app
+//
+// e.g.:
+//
+//	kadm session dump -app my_app -user u1 -o u1.jsonl           # Every session of u1, from Redis
+//	kadm session dump -app my_app -user u1 -session s1 -from postgres
+//	kadm session restore -to postgres -i u1.jsonl
+//	kadm session prune -app my_app -max-age 2160h -dry-run       # Count, then drop -dry-run
+//	kadm session stats -app my_app -user u1
+//
+// The Redis and PostgreSQL connections are read from the Redis and Postgres keys
+// of the config file; a command needing a backend that is not configured fails.
+// The Session key sets the scoped state, compression and encryption the
+// sessions were written with, so dumps and restores read and write them as the
+// deployment does.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/kydenul/k-adk/compression"
+	"github.com/kydenul/k-adk/encryption"
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	pg "github.com/kydenul/k-adk/session/postgres"
+	rsess "github.com/kydenul/k-adk/session/redis"
+	"github.com/kydenul/log"
+	"github.com/spf13/viper"
+)
+
+// errUsage reports invalid arguments, whose usage was printed.
+var errUsage = errors.New("invalid usage")
+
+// commands are the session subcommands.
+var commands = map[string]func(ctx context.Context, b *backends, args []string, stdout io.Writer) error{
+	"dump":    sessionDump,
+	"restore": sessionRestore,
+	"prune":   sessionPrune,
+	"stats":   sessionStats,
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+
+	switch {
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "kadm: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run parses the global flags and runs the session command of args.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("kadm", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configPath := fs.String("config", "config.yaml", "config file holding the Redis and Postgres connections")
+	verbose := fs.Bool("verbose", false, "log with the KLOG config of the config file")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: kadm [-config config.yaml] [-verbose] session <dump|restore|prune|stats> [flags]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+
+	args = fs.Args()
+	if len(args) < 2 || args[0] != "session" || commands[args[1]] == nil {
+		fs.Usage()
+		return errUsage
+	}
+
+	b, err := loadBackends(*configPath, *verbose)
+	if err != nil {
+		return err
+	}
+	defer b.close()
+
+	return commands[args[1]](ctx, b, args[2:], stdout)
+}
+
+// sessionConfig is the Session key of the config file: the options the
+// sessions of the deployment are written with.
+type sessionConfig struct {
+	// ScopedState stores the app and user state keys in their PostgreSQL tables,
+	// as pg.WithScopedState.
+	ScopedState bool `mapstructure:"scoped_state"`
+
+	// Compression is the codec of the payloads, e.g. "zstd"; empty for none.
+	Compression string `mapstructure:"compression"`
+
+	// Encryption reads the keys of the payloads from a key file, or from the
+	// ADK_ENCRYPTION_KEYS_<APP> variables; neither for no encryption.
+	Encryption struct {
+		KeyFile string `mapstructure:"key_file"`
+		Env     bool   `mapstructure:"env"`
+	} `mapstructure:"encryption"`
+}
+
+// backends opens the Redis and PostgreSQL connections of the config file on
+// demand.
+type backends struct {
+	cfg    *viper.Viper
+	logger log.Logger
+
+	// scopedState, codec and encryptor are the Session options, codec and
+	// encryptor nil when unset.
+	scopedState bool
+	codec       compression.Codec
+	encryptor   *encryption.Encryptor
+
+	rdb      *rsess.RedisClient
+	pgClient *pg.Client
+	closers  []func() error
+}
+
+// loadBackends reads the config file. Verbose logs with its KLOG config, else
+// nothing is logged, so dumps can be written to stdout.
+func loadBackends(path string, verbose bool) (*backends, error) {
+	cfg := viper.New()
+	cfg.SetConfigFile(path)
+	if err := cfg.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	b := &backends{cfg: cfg, logger: discardlog.NewDiscardLog()}
+	if verbose {
+		opt, err := log.LoadFromFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load log config: %w", err)
+		}
+		b.logger = log.NewLog(opt)
+	}
+
+	if err := b.loadSessionConfig(); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// loadSessionConfig reads the Session key of the config file, if any.
+func (b *backends) loadSessionConfig() error {
+	var sc sessionConfig
+	if err := b.cfg.UnmarshalKey("Session", &sc); err != nil {
+		return fmt.Errorf("failed to unmarshal Session config: %w", err)
+	}
+	b.scopedState = sc.ScopedState
+
+	if sc.Compression != "" {
+		codec, ok := compression.Lookup(sc.Compression)
+		if !ok {
+			return fmt.Errorf("unknown session compression %q", sc.Compression)
+		}
+		b.codec = codec
+	}
+
+	switch {
+	case sc.Encryption.KeyFile != "" && sc.Encryption.Env:
+		return errors.New("session encryption keys are read from a key file or the environment, not both")
+	case sc.Encryption.KeyFile != "":
+		keys, err := encryption.NewFileKeyProvider(sc.Encryption.KeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load session encryption keys: %w", err)
+		}
+		b.encryptor = encryption.NewEncryptor(keys)
+	case sc.Encryption.Env:
+		b.encryptor = encryption.NewEncryptor(&encryption.EnvKeyProvider{})
+	}
+
+	return nil
+}
+
+// redisClient connects to the Redis of the config file.
+func (b *backends) redisClient() (*rsess.RedisClient, error) {
+	if b.rdb != nil {
+		return b.rdb, nil
+	}
+	if !b.cfg.IsSet("Redis") {
+		return nil, errors.New("redis is not configured: no Redis key in the config file")
+	}
+
+	redisConfig := rsess.DefaultRedisConfig()
+	if err := b.cfg.UnmarshalKey("Redis", redisConfig); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal Redis config: %w", err)
+	}
+	redisConfig.Logger = b.logger
+
+	rdb, err := rsess.NewRedisClient(redisConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create redis client: %w", err)
+	}
+	b.rdb = rdb
+	b.closers = append(b.closers, rdb.Close)
+
+	return rdb, nil
+}
+
+// redisService returns a session service on the Redis of the config file.
+func (b *backends) redisService(opts ...rsess.ServiceOption) (*rsess.RedisSessionService, error) {
+	rdb, err := b.redisClient()
+	if err != nil {
+		return nil, err
+	}
+
+	options := []rsess.ServiceOption{rsess.WithLogger(b.logger)}
+	if b.codec != nil {
+		options = append(options, rsess.WithCodec(b.codec))
+	}
+	if b.encryptor != nil {
+		options = append(options, rsess.WithEncryption(b.encryptor))
+	}
+
+	svc, err := rsess.NewRedisSessionService(rdb, append(options, opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create redis session service: %w", err)
+	}
+
+	return svc, nil
+}
+
+// persister returns a synchronous persister on the PostgreSQL of the config file,
+// so the events of a session are written after the session.
+func (b *backends) persister(ctx context.Context, opts ...pg.PersisterOption) (*pg.SessionPersister, error) {
+	if b.pgClient == nil {
+		if !b.cfg.IsSet("Postgres") {
+			return nil, errors.New("postgres is not configured: no Postgres key in the config file")
+		}
+
+		pgConfig := pg.DefaultConfig()
+		if err := b.cfg.UnmarshalKey("Postgres", pgConfig); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal Postgres config: %w", err)
+		}
+		pgConfig.Logger = b.logger
+
+		client, err := pg.NewPostgresClient(ctx, pgConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create postgres client: %w", err)
+		}
+		b.pgClient = client
+		b.closers = append(b.closers, client.Close)
+	}
+
+	opts = append(opts, pg.WithAsyncBufferSize(0))
+	if b.scopedState {
+		opts = append(opts, pg.WithScopedState())
+	}
+	if b.codec != nil {
+		opts = append(opts, pg.WithCodec(b.codec))
+	}
+	if b.encryptor != nil {
+		opts = append(opts, pg.WithEncryption(b.encryptor))
+	}
+
+	persister, err := pg.NewSessionPersister(ctx, b.pgClient, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create postgres persister: %w", err)
+	}
+	b.closers = append(b.closers, persister.Close)
+
+	return persister, nil
+}
+
+// close closes the opened connections, last opened first.
+func (b *backends) close() {
+	for i := len(b.closers) - 1; i >= 0; i-- {
+		if err := b.closers[i](); err != nil {
+			b.logger.Errorf("failed to close backend: %v", err)
+		}
+	}
+}
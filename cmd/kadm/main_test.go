@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ksess "github.com/kydenul/k-adk/session"
+)
+
+func TestRunUsage(t *testing.T) {
+	for _, args := range [][]string{
+		nil,
+		{"session"},
+		{"session", "unknown"},
+		{"sessions", "dump"},
+		{"-unknown-flag"},
+	} {
+		if err := run(context.Background(), args, io.Discard, io.Discard); !errors.Is(err, errUsage) {
+			t.Errorf("run(%q): expected errUsage, got %v", args, err)
+		}
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing.yaml")
+	if err := run(context.Background(), []string{"-config", missing, "session", "stats"}, io.Discard, io.Discard); err == nil ||
+		errors.Is(err, errUsage) {
+		t.Errorf("expected a config error, got %v", err)
+	}
+}
+
+func TestSessionCommandsNeedBackends(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(config, []byte("Other:\n  key: value\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{
+		{"session", "dump", "-app", "a", "-user", "u"},
+		{"session", "dump", "-app", "a", "-user", "u", "-from", "postgres"},
+		{"session", "prune", "-app", "a", "-max-age", "24h"},
+		{"session", "prune", "-app", "a", "-max-per-user", "10", "-dry-run"},
+		{"session", "stats"},
+	} {
+		err := run(context.Background(), append([]string{"-config", config}, args...), io.Discard, io.Discard)
+		if err == nil || errors.Is(err, errUsage) || !strings.Contains(err.Error(), "configured") {
+			t.Errorf("run(%q): expected a backend error, got %v", args, err)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"session", "dump", "-app", "a"},
+		{"session", "dump", "-app", "a", "-user", "u", "-from", "mysql"},
+		{"session", "restore", "-to", "mysql"},
+		{"session", "prune"},
+		{"session", "prune", "-max-age", "24h"},
+		{"session", "prune", "-app", "a", "-dry-run"},
+		{"session", "stats", "-user", "u"},
+	} {
+		err := run(context.Background(), append([]string{"-config", config}, args...), io.Discard, io.Discard)
+		if !errors.Is(err, errUsage) {
+			t.Errorf("run(%q): expected errUsage, got %v", args, err)
+		}
+	}
+}
+
+func TestSessionConfig(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "keys.json")
+	if err := os.WriteFile(keyFile, []byte(`{"*": [{"id": "k1", "key": "`+strings.Repeat("A", 43)+`="}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		session string
+		wantErr bool
+	}{
+		{name: "Defaults", session: ""},
+		{name: "Options", session: "  scoped_state: true\n  compression: zstd\n  encryption:\n    key_file: " + keyFile + "\n"},
+		{name: "UnknownCompression", session: "  compression: lz4\n", wantErr: true},
+		{name: "MissingKeyFile", session: "  encryption:\n    key_file: " + filepath.Join(dir, "missing.json") + "\n", wantErr: true},
+		{name: "TwoKeySources", session: "  encryption:\n    key_file: " + keyFile + "\n    env: true\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := filepath.Join(dir, tt.name+".yaml")
+			content := "Other:\n  key: value\n"
+			if tt.session != "" {
+				content += "Session:\n" + tt.session
+			}
+			if err := os.WriteFile(config, []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := loadBackends(config, false)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadBackends failed: %v", err)
+			}
+			if tt.session != "" && (!b.scopedState || b.codec == nil || b.codec.Name() != "zstd" || b.encryptor == nil) {
+				t.Errorf("session options not loaded: scoped=%t codec=%v encryptor=%v", b.scopedState, b.codec, b.encryptor)
+			}
+			if tt.session == "" && (b.scopedState || b.codec != nil || b.encryptor != nil) {
+				t.Error("expected no session options")
+			}
+		})
+	}
+}
+
+func TestReadDumps(t *testing.T) {
+	input := `{"version":1,"app_name":"app","user_id":"u1","id":"s1","state":{"plan":"premium"},"events":[]}
+{"version":1,"app_name":"app","user_id":"u1","id":"s2","state":{},"events":[]}
+{
+  "version": 1, "app_name": "app", "user_id": "u2", "id": "s3",
+  "state": {}, "events": []
+}
+`
+	var ids []string
+	err := readDumps(strings.NewReader(input), func(dump *ksess.SessionDump) error {
+		if err := dump.Validate(); err != nil {
+			return err
+		}
+		ids = append(ids, dump.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("readDumps failed: %v", err)
+	}
+	if strings.Join(ids, ",") != "s1,s2,s3" {
+		t.Errorf("expected s1,s2,s3, got %v", ids)
+	}
+
+	stop := errors.New("stop")
+	if err := readDumps(strings.NewReader(input), func(*ksess.SessionDump) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("expected the error of fn, got %v", err)
+	}
+	if err := readDumps(strings.NewReader(`{"version":`), func(*ksess.SessionDump) error { return nil }); err == nil {
+		t.Error("expected an error for a truncated dump")
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	ksess "github.com/kydenul/k-adk/session"
+	pg "github.com/kydenul/k-adk/session/postgres"
+	rsess "github.com/kydenul/k-adk/session/redis"
+	"google.golang.org/adk/session"
+)
+
+// Backends of the -from and -to flags.
+const (
+	backendRedis    = "redis"
+	backendPostgres = "postgres"
+)
+
+// newFlagSet returns the flag set of a session command, printing its usage to
+// stderr.
+func newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet("kadm session "+name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: kadm session %s %s\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// sessionDump writes the dumps of a session, or of every session of a user, as
+// JSON lines, read from Redis or PostgreSQL.
+func sessionDump(ctx context.Context, b *backends, args []string, stdout io.Writer) error {
+	fs := newFlagSet("dump", "-app APP -user USER [-session ID] [-from redis|postgres] [-o FILE]")
+	appName := fs.String("app", "", "app name of the sessions (required)")
+	userID := fs.String("user", "", "user ID of the sessions (required)")
+	sessionID := fs.String("session", "", "session to dump; empty dumps every session of the user")
+	from := fs.String("from", backendRedis, "backend to read the sessions from, redis or postgres")
+	output := fs.String("o", "", "file to write the dumps to; empty writes them to stdout")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if *appName == "" || *userID == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	var (
+		ids    []string
+		export func(sessionID string) (*ksess.SessionDump, error)
+	)
+	switch *from {
+	case backendRedis:
+		svc, err := b.redisService()
+		if err != nil {
+			return err
+		}
+		if *sessionID == "" {
+			resp, err := svc.List(ctx, &session.ListRequest{AppName: *appName, UserID: *userID})
+			if err != nil {
+				return fmt.Errorf("failed to list sessions: %w", err)
+			}
+			for _, sess := range resp.Sessions {
+				ids = append(ids, sess.ID())
+			}
+		}
+		export = func(id string) (*ksess.SessionDump, error) { return ksess.Export(ctx, svc, *appName, *userID, id) }
+	case backendPostgres:
+		persister, err := b.persister(ctx)
+		if err != nil {
+			return err
+		}
+		if *sessionID == "" {
+			if ids, err = persister.ListSessionIDs(ctx, *appName, *userID); err != nil {
+				return err
+			}
+		}
+		export = func(id string) (*ksess.SessionDump, error) {
+			return ksess.ExportLoaded(ctx, persister, *appName, *userID, id)
+		}
+	default:
+		fs.Usage()
+		return errUsage
+	}
+	if *sessionID != "" {
+		ids = []string{*sessionID}
+	}
+
+	out := stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed to create dump file: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	enc := json.NewEncoder(out)
+	for _, id := range ids {
+		dump, err := export(id)
+		if err != nil {
+			return fmt.Errorf("failed to dump session %s: %w", id, err)
+		}
+		if err := enc.Encode(dump); err != nil {
+			return fmt.Errorf("failed to write dump of session %s: %w", id, err)
+		}
+	}
+
+	if *output != "" {
+		fmt.Fprintf(stdout, "dumped %d sessions to %s\n", len(ids), *output)
+	}
+	return nil
+}
+
+// sessionRestore restores the dumps written by sessionDump, or by
+// ksess.Export, into Redis or PostgreSQL.
+func sessionRestore(ctx context.Context, b *backends, args []string, stdout io.Writer) error {
+	fs := newFlagSet("restore", "[-to redis|postgres] [-i FILE] [-ttl DURATION]")
+	to := fs.String("to", backendRedis, "backend to restore the sessions into, redis or postgres")
+	input := fs.String("i", "", "file to read the dumps from; empty reads them from stdin")
+	ttl := fs.Duration("ttl", 0, "TTL of the sessions restored into Redis; 0 uses the default of the service")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+
+	var restore func(dump *ksess.SessionDump) error
+	switch *to {
+	case backendRedis:
+		var opts []rsess.ServiceOption
+		if *ttl > 0 {
+			opts = append(opts, rsess.WithTTL(*ttl))
+		}
+		svc, err := b.redisService(opts...)
+		if err != nil {
+			return err
+		}
+		restore = func(dump *ksess.SessionDump) error {
+			_, err := ksess.Import(ctx, svc, dump)
+			return err
+		}
+	case backendPostgres:
+		persister, err := b.persister(ctx)
+		if err != nil {
+			return err
+		}
+		restore = func(dump *ksess.SessionDump) error { return ksess.ImportPersisted(ctx, persister, dump) }
+	default:
+		fs.Usage()
+		return errUsage
+	}
+
+	in := io.Reader(os.Stdin)
+	if *input != "" {
+		f, err := os.Open(*input)
+		if err != nil {
+			return fmt.Errorf("failed to open dump file: %w", err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	// NOTE: A failing session is reported and skipped, e.g. one already in Redis
+	restored, failed := 0, 0
+	err := readDumps(in, func(dump *ksess.SessionDump) error {
+		if err := restore(dump); err != nil {
+			failed++
+			b.logger.Errorf("failed to restore session %s: %v", dump.ID, err)
+			fmt.Fprintf(stdout, "failed to restore session %s: %v\n", dump.ID, err)
+			return nil
+		}
+		restored++
+		return ctx.Err()
+	})
+	fmt.Fprintf(stdout, "restored %d sessions into %s, %d failed\n", restored, *to, failed)
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d sessions failed to restore", failed)
+	}
+	return nil
+}
+
+// readDumps decodes the dumps of r, JSON lines or concatenated JSON documents, and
+// calls fn with each one until it returns an error.
+func readDumps(r io.Reader, fn func(dump *ksess.SessionDump) error) error {
+	dec := json.NewDecoder(r)
+	for {
+		var dump ksess.SessionDump
+		err := dec.Decode(&dump)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read dump: %w", err)
+		}
+		if err := fn(&dump); err != nil {
+			return err
+		}
+	}
+}
+
+// sessionPrune prunes the sessions of an app in PostgreSQL out of a retention
+// policy, see pg.Retention, or counts them with -dry-run.
+func sessionPrune(ctx context.Context, b *backends, args []string, stdout io.Writer) error {
+	fs := newFlagSet("prune", "-app APP [-max-age DURATION] [-max-per-user N] [-dry-run]")
+	appName := fs.String("app", "", "app name of the sessions to prune")
+	maxAge := fs.Duration("max-age", 0, "prune the sessions not updated for longer, e.g. 2160h")
+	maxPerUser := fs.Int("max-per-user", 0, "keep the most recently updated sessions of each user, prune the others")
+	dryRun := fs.Bool("dry-run", false, "count the sessions to prune without deleting them")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if *appName == "" || (*maxAge <= 0 && *maxPerUser <= 0) {
+		fs.Usage()
+		return errUsage
+	}
+
+	persister, err := b.persister(ctx, pg.WithRetention(pg.Retention{
+		MaxAge:             *maxAge,
+		MaxSessionsPerUser: *maxPerUser,
+		AppName:            *appName,
+		DryRun:             *dryRun,
+	}))
+	if err != nil {
+		return err
+	}
+
+	pruned, err := persister.Prune(ctx)
+	if *dryRun {
+		fmt.Fprintf(stdout, "would prune %d sessions\n", pruned)
+	} else {
+		fmt.Fprintf(stdout, "pruned %d sessions\n", pruned)
+	}
+	return err
+}
+
+// stats is the report of sessionStats, by backend.
+type stats struct {
+	Postgres *pg.SessionStats `json:"postgres,omitempty"`
+
+	// RedisUser reports the sessions of a user, RedisUsage the memory used by
+	// every app and user.
+	RedisUser  *rsess.UserStats   `json:"redis_user,omitempty"`
+	RedisUsage *rsess.UsageReport `json:"redis_usage,omitempty"`
+}
+
+// sessionStats reports the sessions of an app in every configured backend, as
+// JSON: the PostgreSQL statistics, and the Redis stats of a user or, without
+// one, the Redis memory usage of every app, which scans the keyspace.
+func sessionStats(ctx context.Context, b *backends, args []string, stdout io.Writer) error {
+	fs := newFlagSet("stats", "[-app APP] [-user USER]")
+	appName := fs.String("app", "", "app name of the sessions; empty reports every app")
+	userID := fs.String("user", "", "user whose Redis sessions to report; requires -app")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if *userID != "" && *appName == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	var report stats
+	if b.cfg.IsSet("Postgres") {
+		persister, err := b.persister(ctx)
+		if err != nil {
+			return err
+		}
+		if report.Postgres, err = persister.SessionStats(ctx, *appName); err != nil {
+			return err
+		}
+	}
+
+	if b.cfg.IsSet("Redis") {
+		rdb, err := b.redisClient()
+		if err != nil {
+			return err
+		}
+		if *userID != "" {
+			svc, err := b.redisService()
+			if err != nil {
+				return err
+			}
+			if report.RedisUser, err = svc.Stats(ctx, *appName, *userID); err != nil {
+				return err
+			}
+		} else {
+			inspector, err := rsess.NewUsageInspector(rdb, rsess.UsageConfig{Logger: b.logger})
+			if err != nil {
+				return err
+			}
+			if report.RedisUsage, err = inspector.Inspect(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	if report == (stats{}) {
+		return errors.New("neither Redis nor Postgres is configured")
+	}
+
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
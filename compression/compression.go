@@ -0,0 +1,215 @@
+// Package compression compresses the JSON payloads written to the session stores,
+// e.g. events carrying large tool outputs or grounding metadata. Every compressed
+// payload is tagged with the name of its codec, so readers decode any payload
+// without configuration, and the payloads written before compression was enabled,
+// or with another codec, stay readable.
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+)
+
+// prefix marks a compressed payload: "z:v1:{codec}:{base64(compressed)}".
+// The text form keeps compressed payloads storable in Redis strings and JSONB columns.
+const prefix = "z:v1:"
+
+// MinSize is the size, in bytes, under which Encode keeps payloads as is: the
+// codec headers and the base64 text form outweigh what compression saves.
+const MinSize = 256
+
+var (
+	// ErrUnknownCodec is returned when decoding a payload of an unregistered codec.
+	ErrUnknownCodec = errors.New("unknown compression codec")
+
+	// ErrMalformed is returned for a compressed payload that cannot be parsed.
+	ErrMalformed = errors.New("malformed compressed payload")
+)
+
+// Codec compresses and decompresses payloads.
+type Codec interface {
+	// Name identifies the codec in the payloads it compressed. It must not contain ':'.
+	Name() string
+
+	// Compress compresses data.
+	Compress(data []byte) ([]byte, error)
+
+	// Decompress decompresses data compressed by Compress.
+	Decompress(data []byte) ([]byte, error)
+}
+
+var (
+	mu     sync.RWMutex
+	codecs = map[string]Codec{}
+)
+
+func init() {
+	for _, c := range []Codec{Gzip(), Zstd(), Snappy()} {
+		Register(c)
+	}
+}
+
+// Register makes c available to Decode under its name, replacing any codec of
+// the same name. Gzip, Zstd and Snappy are registered by default.
+func Register(c Codec) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	codecs[c.Name()] = c
+}
+
+// Lookup returns the registered codec named name.
+func Lookup(name string) (Codec, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	c, ok := codecs[name]
+	return c, ok
+}
+
+// Encode compresses data with c, in the tagged text form. Data is returned as is if
+// c is nil, data is shorter than MinSize, or compressing it does not shrink it.
+func Encode(c Codec, data []byte) ([]byte, error) {
+	if c == nil || len(data) < MinSize {
+		return data, nil
+	}
+
+	compressed, err := c.Compress(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress payload with %s: %w", c.Name(), err)
+	}
+
+	name := c.Name()
+	size := len(prefix) + len(name) + 1 + base64.RawStdEncoding.EncodedLen(len(compressed))
+	if size >= len(data) {
+		return data, nil
+	}
+
+	out := make([]byte, 0, size)
+	out = append(out, prefix...)
+	out = append(out, name...)
+	out = append(out, ':')
+	out = base64.RawStdEncoding.AppendEncode(out, compressed)
+
+	return out, nil
+}
+
+// Decode returns the original payload of data, decompressing it with the codec it
+// is tagged with. Data that is not compressed is returned as is.
+func Decode(data []byte) ([]byte, error) {
+	if !IsCompressed(data) {
+		return data, nil
+	}
+
+	name, encoded, ok := bytes.Cut(data[len(prefix):], []byte{':'})
+	if !ok {
+		return nil, ErrMalformed
+	}
+
+	c, ok := Lookup(string(name))
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCodec, name)
+	}
+
+	compressed, err := base64.RawStdEncoding.AppendDecode(nil, encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+
+	decompressed, err := c.Decompress(compressed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress payload with %s: %w", name, err)
+	}
+
+	return decompressed, nil
+}
+
+// IsCompressed reports whether data is a payload compressed by Encode.
+func IsCompressed(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(prefix))
+}
+
+// gzipCodec compresses with gzip, at the default level.
+type gzipCodec struct{}
+
+// Gzip returns the gzip codec, named "gzip". It is the most widely supported, and
+// the slowest, of the built-in codecs.
+func Gzip() Codec { return gzipCodec{} }
+
+func (gzipCodec) Name() string { return "gzip" }
+
+func (gzipCodec) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (gzipCodec) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+// zstdCodec compresses with zstd, sharing one encoder and decoder: their
+// EncodeAll and DecodeAll are safe for concurrent use.
+type zstdCodec struct {
+	enc *zstd.Encoder
+	dec *zstd.Decoder
+}
+
+var zstdOnce = sync.OnceValue(func() Codec {
+	// NOTE: Creating a zstd encoder or decoder without options does not fail
+	enc, _ := zstd.NewWriter(nil)
+	dec, _ := zstd.NewReader(nil)
+	return &zstdCodec{enc: enc, dec: dec}
+})
+
+// Zstd returns the zstd codec, named "zstd". It has the best ratio of the
+// built-in codecs, at a fraction of the cost of gzip.
+func Zstd() Codec { return zstdOnce() }
+
+func (c *zstdCodec) Name() string { return "zstd" }
+
+func (c *zstdCodec) Compress(data []byte) ([]byte, error) {
+	return c.enc.EncodeAll(data, nil), nil
+}
+
+func (c *zstdCodec) Decompress(data []byte) ([]byte, error) {
+	return c.dec.DecodeAll(data, nil)
+}
+
+// snappyCodec compresses with the snappy block format.
+type snappyCodec struct{}
+
+// Snappy returns the snappy codec, named "snappy". It is the fastest of the
+// built-in codecs, with the lowest ratio.
+func Snappy() Codec { return snappyCodec{} }
+
+func (snappyCodec) Name() string { return "snappy" }
+
+func (snappyCodec) Compress(data []byte) ([]byte, error) {
+	return s2.EncodeSnappy(nil, data), nil
+}
+
+func (snappyCodec) Decompress(data []byte) ([]byte, error) {
+	return s2.Decode(nil, data)
+}
@@ -0,0 +1,105 @@
+package compression
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	payload := []byte(`{"output":"` + strings.Repeat("grounding chunk ", 100) + `"}`)
+
+	for _, c := range []Codec{Gzip(), Zstd(), Snappy()} {
+		t.Run(c.Name(), func(t *testing.T) {
+			encoded, err := Encode(c, payload)
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+			if !IsCompressed(encoded) || len(encoded) >= len(payload) {
+				t.Fatalf("Unexpected encoded payload of %d bytes: %.40s", len(encoded), encoded)
+			}
+			if !bytes.HasPrefix(encoded, []byte("z:v1:"+c.Name()+":")) {
+				t.Errorf("Encoded payload not tagged with %s: %.40s", c.Name(), encoded)
+			}
+
+			decoded, err := Decode(encoded)
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			if !bytes.Equal(decoded, payload) {
+				t.Errorf("Decode() = %.40s, want the payload", decoded)
+			}
+		})
+	}
+}
+
+func TestEncodeKeepsPayload(t *testing.T) {
+	small := []byte(`{"id":"e1"}`)
+	if got, _ := Encode(Gzip(), small); !bytes.Equal(got, small) {
+		t.Errorf("Encode() of a small payload = %s, want it as is", got)
+	}
+
+	// Random data does not shrink
+	incompressible := make([]byte, 1024)
+	_, _ = rand.Read(incompressible)
+	if got, _ := Encode(Snappy(), incompressible); IsCompressed(got) {
+		t.Error("Encode() compressed a payload that does not shrink")
+	}
+
+	if got, _ := Encode(nil, small); !bytes.Equal(got, small) {
+		t.Errorf("Encode() without codec = %s, want it as is", got)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	plain := []byte(`{"id":"e1"}`)
+	if got, err := Decode(plain); err != nil || !bytes.Equal(got, plain) {
+		t.Errorf("Decode() of a plain payload = %s, %v", got, err)
+	}
+
+	tests := []struct {
+		name string
+		data string
+		want error
+	}{
+		{"unknown codec", "z:v1:lz4:AAAA", ErrUnknownCodec},
+		{"missing codec", "z:v1:AAAA", ErrMalformed},
+		{"invalid base64", "z:v1:gzip:!!", ErrMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode([]byte(tt.data)); !errors.Is(err, tt.want) {
+				t.Errorf("Decode() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("registered codec", func(t *testing.T) {
+		Register(halfCodec{})
+
+		encoded, err := Encode(halfCodec{}, bytes.Repeat([]byte("ab"), MinSize))
+		if err != nil || !IsCompressed(encoded) {
+			t.Fatalf("Encode() = %.40s, %v", encoded, err)
+		}
+		if got, err := Decode(encoded); err != nil || !bytes.Equal(got, bytes.Repeat([]byte("ab"), MinSize)) {
+			t.Errorf("Decode() = %.40s, %v", got, err)
+		}
+	})
+}
+
+// halfCodec is a Codec "compressing" a payload to its first half, for payloads
+// made of two identical halves.
+type halfCodec struct{}
+
+func (halfCodec) Name() string { return "half" }
+
+func (halfCodec) Compress(data []byte) ([]byte, error) {
+	return bytes.Clone(data[:len(data)/2]), nil
+}
+
+func (halfCodec) Decompress(data []byte) ([]byte, error) {
+	return bytes.Repeat(data, 2), nil
+}
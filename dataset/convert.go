@@ -0,0 +1,171 @@
+package dataset
+
+import (
+	"strings"
+
+	"github.com/bytedance/sonic"
+	"google.golang.org/adk/session"
+	"google.golang.org/genai"
+)
+
+// Message roles of the intermediate conversation.
+const (
+	roleSystem    = "system"
+	roleUser      = "user"
+	roleAssistant = "assistant"
+	roleTool      = "tool"
+)
+
+// message is a format-neutral chat message.
+type message struct {
+	Role       string
+	Text       string
+	ToolCalls  []toolCall
+	ToolCallID string
+	Name       string
+}
+
+type toolCall struct {
+	ID        string
+	Name      string
+	Arguments string // JSON object
+}
+
+// turn is a user message and every agent message up to the next user message.
+type turn struct {
+	messages []message
+	failed   bool
+}
+
+// successful reports whether the turn completed without error and ended with an
+// assistant text answer.
+func (t *turn) successful() bool {
+	if t.failed || len(t.messages) < 2 {
+		return false
+	}
+	last := t.messages[len(t.messages)-1]
+	return last.Role == roleAssistant && last.Text != "" && len(last.ToolCalls) == 0
+}
+
+// buildTurns splits the events of a session into turns. Partial events are
+// skipped; events before the first user message are ignored.
+func buildTurns(events session.Events) []turn {
+	var turns []turn
+	for evt := range events.All() {
+		if evt == nil || evt.Partial {
+			continue
+		}
+
+		if evt.Author == roleUser {
+			if text := contentText(evt.Content); text != "" {
+				turns = append(turns, turn{messages: []message{{Role: roleUser, Text: text}}})
+			}
+			continue
+		}
+
+		if len(turns) == 0 {
+			continue
+		}
+
+		t := &turns[len(turns)-1]
+		if evt.ErrorCode != "" || evt.Interrupted {
+			t.failed = true
+		}
+		t.messages = append(t.messages, eventMessages(evt)...)
+	}
+
+	return turns
+}
+
+// eventMessages converts an agent event: text and function calls become an
+// assistant message, function responses become tool messages.
+func eventMessages(evt *session.Event) []message {
+	if evt.Content == nil {
+		return nil
+	}
+
+	assistant := message{Role: roleAssistant, Text: contentText(evt.Content)}
+	var tools []message
+
+	for _, part := range evt.Content.Parts {
+		if part == nil {
+			continue
+		}
+
+		if fc := part.FunctionCall; fc != nil {
+			args, _ := sonic.MarshalString(fc.Args)
+			if fc.Args == nil {
+				args = "{}"
+			}
+			assistant.ToolCalls = append(assistant.ToolCalls, toolCall{
+				ID:        fc.ID,
+				Name:      fc.Name,
+				Arguments: args,
+			})
+		}
+
+		if fr := part.FunctionResponse; fr != nil {
+			out, _ := sonic.MarshalString(fr.Response)
+			tools = append(tools, message{
+				Role:       roleTool,
+				Text:       out,
+				ToolCallID: fr.ID,
+				Name:       fr.Name,
+			})
+		}
+	}
+
+	var msgs []message
+	if assistant.Text != "" || len(assistant.ToolCalls) > 0 {
+		msgs = append(msgs, assistant)
+	}
+	return append(msgs, tools...)
+}
+
+// stripToolInternals drops tool calls and tool results, keeping only text.
+func stripToolInternals(msgs []message) []message {
+	out := make([]message, 0, len(msgs))
+	for _, m := range msgs {
+		if m.Role == roleTool {
+			continue
+		}
+		m.ToolCalls = nil
+		if m.Text == "" {
+			continue
+		}
+		out = append(out, m)
+	}
+
+	return mergeAdjacent(out)
+}
+
+// mergeAdjacent joins consecutive text-only messages of the same role, which
+// stripping tool internals or multi-agent handoffs can produce.
+func mergeAdjacent(msgs []message) []message {
+	var out []message
+	for _, m := range msgs {
+		if n := len(out); n > 0 && out[n-1].Role == m.Role &&
+			len(out[n-1].ToolCalls) == 0 && len(m.ToolCalls) == 0 && m.Role != roleTool {
+			out[n-1].Text += "\n\n" + m.Text
+			continue
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// contentText concatenates the non-thought text parts of c.
+func contentText(c *genai.Content) string {
+	if c == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, p := range c.Parts {
+		if p != nil && p.Text != "" && !p.Thought {
+			sb.WriteString(p.Text)
+		}
+	}
+
+	return strings.TrimSpace(sb.String())
+}
@@ -0,0 +1,285 @@
+// Package dataset converts stored sessions into fine-tuning datasets (OpenAI chat
+// JSONL, ShareGPT), so accumulated conversations can feed model improvement
+// pipelines.
+package dataset
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"math"
+
+	"github.com/kydenul/k-adk/genai/transcript"
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/log"
+	"google.golang.org/adk/session"
+)
+
+// Format is an output dataset format.
+type Format string
+
+const (
+	// FormatOpenAI writes one {"messages": [...]} object per line, the OpenAI chat
+	// fine-tuning format (tool calls use "tool_calls" and "tool" messages).
+	FormatOpenAI Format = "openai"
+
+	// FormatShareGPT writes one {"conversations": [{"from", "value"}, ...]} object
+	// per line ("human", "gpt", "function_call", "observation").
+	FormatShareGPT Format = "sharegpt"
+)
+
+var errUnknownFormat = errors.New("dataset: unknown format")
+
+// Config is the configuration for creating an Exporter.
+type Config struct {
+	// Optional. Format of the output. Default: FormatOpenAI.
+	Format Format
+
+	// Optional. SystemPrompt is prepended to every example.
+	SystemPrompt string
+
+	// Optional. OnlySuccessful drops turns that errored, were interrupted or did
+	// not end with a text answer.
+	OnlySuccessful bool
+
+	// Optional. StripToolInternals drops tool calls and tool results, keeping only
+	// the user messages and the agent's text answers.
+	StripToolInternals bool
+
+	// Optional. Redactor masks PII in every message text (and tool arguments).
+	Redactor transcript.Redactor
+
+	// Optional. MinTurns is the minimum number of turns an example must keep after
+	// filtering. Default: 1.
+	MinTurns int
+
+	// Optional. SampleRate in (0, 1] keeps that fraction of sessions. Sampling is
+	// deterministic per session ID and Seed, so re-exports select the same sessions.
+	// Default: 1 (keep all).
+	SampleRate float64
+
+	// Optional. Seed of the sampling.
+	Seed uint64
+
+	// Optional. MaxExamples stops the export after that many examples. Default: no limit.
+	MaxExamples int
+
+	// Optional. Logger for logging. Falls back to `DiscardLog` if nil.
+	Logger log.Logger
+}
+
+// Stats summarizes an export.
+type Stats struct {
+	Sessions      int `json:"sessions"`
+	Examples      int `json:"examples"`
+	SampledOut    int `json:"sampledOut"`
+	TooShort      int `json:"tooShort"`
+	DroppedTurns  int `json:"droppedTurns"`
+	ExportedTurns int `json:"exportedTurns"`
+}
+
+// Exporter converts sessions into dataset examples.
+type Exporter struct {
+	log.Logger
+
+	cfg    Config
+	encode func(msgs []message) (any, error)
+}
+
+// New creates an Exporter with the specified configuration.
+func New(cfg Config) (*Exporter, error) {
+	if cfg.Format == "" {
+		cfg.Format = FormatOpenAI
+	}
+	if cfg.MinTurns <= 0 {
+		cfg.MinTurns = 1
+	}
+	if cfg.SampleRate <= 0 || cfg.SampleRate > 1 {
+		cfg.SampleRate = 1
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = discardlog.NewDiscardLog()
+	}
+
+	e := &Exporter{Logger: cfg.Logger, cfg: cfg}
+
+	switch cfg.Format {
+	case FormatOpenAI:
+		e.encode = encodeOpenAI
+	case FormatShareGPT:
+		e.encode = encodeShareGPT
+	default:
+		return nil, fmt.Errorf("%w: %q", errUnknownFormat, cfg.Format)
+	}
+
+	return e, nil
+}
+
+// Export writes one JSON line per selected session to w. It stops at the first
+// error of sessions or w, or when ctx is cancelled.
+func (e *Exporter) Export(
+	ctx context.Context,
+	w io.Writer,
+	sessions iter.Seq2[session.Session, error],
+) (*Stats, error) {
+	stats := &Stats{}
+	jw := newLineWriter(w)
+
+	for sess, err := range sessions {
+		if err != nil {
+			return stats, fmt.Errorf("failed to read session: %w", err)
+		}
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		if e.cfg.MaxExamples > 0 && stats.Examples >= e.cfg.MaxExamples {
+			break
+		}
+
+		stats.Sessions++
+
+		if !e.sampled(sess.ID()) {
+			stats.SampledOut++
+			continue
+		}
+
+		msgs, kept, dropped := e.convert(sess)
+		stats.DroppedTurns += dropped
+		if kept < e.cfg.MinTurns {
+			stats.TooShort++
+			continue
+		}
+
+		example, err := e.encode(msgs)
+		if err != nil {
+			return stats, fmt.Errorf("failed to encode session %s: %w", sess.ID(), err)
+		}
+		if err := jw.write(example); err != nil {
+			return stats, fmt.Errorf("failed to write example: %w", err)
+		}
+
+		stats.Examples++
+		stats.ExportedTurns += kept
+	}
+
+	e.Infof("dataset exported: format=%s, sessions=%d, examples=%d, turns=%d, droppedTurns=%d",
+		e.cfg.Format, stats.Sessions, stats.Examples, stats.ExportedTurns, stats.DroppedTurns)
+
+	return stats, nil
+}
+
+// convert applies the filters to a session and returns its messages with the
+// number of kept and dropped turns.
+func (e *Exporter) convert(sess session.Session) (msgs []message, kept, dropped int) {
+	if e.cfg.SystemPrompt != "" {
+		msgs = append(msgs, message{Role: roleSystem, Text: e.redact(e.cfg.SystemPrompt)})
+	}
+
+	for _, t := range buildTurns(sess.Events()) {
+		if e.cfg.OnlySuccessful && !t.successful() {
+			dropped++
+			continue
+		}
+
+		turnMsgs := t.messages
+		if e.cfg.StripToolInternals {
+			turnMsgs = stripToolInternals(turnMsgs)
+		}
+		// A turn without any agent message teaches nothing
+		if len(turnMsgs) < 2 {
+			dropped++
+			continue
+		}
+
+		for _, m := range turnMsgs {
+			msgs = append(msgs, e.redactMessage(m))
+		}
+		kept++
+	}
+
+	return msgs, kept, dropped
+}
+
+// sampled reports whether the session is selected by SampleRate.
+func (e *Exporter) sampled(sessionID string) bool {
+	if e.cfg.SampleRate >= 1 {
+		return true
+	}
+
+	// NOTE: FNV leaves the high bits of similar IDs, e.g. "sess-1", "sess-2",
+	// clustered, so the sampling hashes them with SHA-256
+	h := sha256.New()
+	_ = binary.Write(h, binary.LittleEndian, e.cfg.Seed)
+	_, _ = h.Write([]byte(sessionID))
+
+	return float64(binary.BigEndian.Uint64(h.Sum(nil)))/math.MaxUint64 < e.cfg.SampleRate
+}
+
+func (e *Exporter) redact(text string) string {
+	if e.cfg.Redactor == nil {
+		return text
+	}
+	return e.cfg.Redactor.Redact(text)
+}
+
+func (e *Exporter) redactMessage(m message) message {
+	m.Text = e.redact(m.Text)
+	if len(m.ToolCalls) > 0 {
+		calls := make([]toolCall, len(m.ToolCalls))
+		for i, c := range m.ToolCalls {
+			c.Arguments = e.redact(c.Arguments)
+			calls[i] = c
+		}
+		m.ToolCalls = calls
+	}
+	return m
+}
+
+// FromSessions adapts a slice of sessions to the iterator taken by Export.
+func FromSessions(sessions []session.Session) iter.Seq2[session.Session, error] {
+	return func(yield func(session.Session, error) bool) {
+		for _, s := range sessions {
+			if !yield(s, nil) {
+				return
+			}
+		}
+	}
+}
+
+// FromService yields the sessions of a user stored in svc, with their events.
+// An empty userID lists the sessions of every user if the service supports it.
+func FromService(
+	ctx context.Context,
+	svc session.Service,
+	appName, userID string,
+) iter.Seq2[session.Session, error] {
+	return func(yield func(session.Session, error) bool) {
+		listResp, err := svc.List(ctx, &session.ListRequest{AppName: appName, UserID: userID})
+		if err != nil {
+			yield(nil, fmt.Errorf("failed to list sessions: %w", err))
+			return
+		}
+
+		for _, s := range listResp.Sessions {
+			// List may omit events, so load every session in full
+			getResp, err := svc.Get(ctx, &session.GetRequest{
+				AppName:   s.AppName(),
+				UserID:    s.UserID(),
+				SessionID: s.ID(),
+			})
+			if err != nil {
+				if !yield(nil, fmt.Errorf("failed to get session %s: %w", s.ID(), err)) {
+					return
+				}
+				continue
+			}
+			if !yield(getResp.Session, nil) {
+				return
+			}
+		}
+	}
+}
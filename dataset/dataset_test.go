@@ -0,0 +1,242 @@
+package dataset
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kydenul/k-adk/genai/transcript"
+	"google.golang.org/adk/model"
+	"google.golang.org/adk/session"
+	"google.golang.org/genai"
+)
+
+// mockSession implements session.Session for testing
+type mockSession struct {
+	id     string
+	events *mockEvents
+}
+
+func (s *mockSession) ID() string                { return s.id }
+func (s *mockSession) AppName() string           { return "test_app" }
+func (s *mockSession) UserID() string            { return "test_user" }
+func (s *mockSession) State() session.State      { return nil }
+func (s *mockSession) Events() session.Events    { return s.events }
+func (s *mockSession) LastUpdateTime() time.Time { return time.Time{} }
+
+type mockEvents struct {
+	events []*session.Event
+}
+
+func (e *mockEvents) All() iter.Seq[*session.Event] {
+	return func(yield func(*session.Event) bool) {
+		for _, evt := range e.events {
+			if !yield(evt) {
+				return
+			}
+		}
+	}
+}
+
+func (e *mockEvents) Len() int { return len(e.events) }
+
+func (e *mockEvents) At(i int) *session.Event {
+	if i < 0 || i >= len(e.events) {
+		return nil
+	}
+	return e.events[i]
+}
+
+func newSession(id string, events ...*session.Event) session.Session {
+	return &mockSession{id: id, events: &mockEvents{events: events}}
+}
+
+func userEvent(text string) *session.Event {
+	return &session.Event{
+		Author:      "user",
+		LLMResponse: model.LLMResponse{Content: genai.NewContentFromText(text, genai.RoleUser)},
+	}
+}
+
+func agentEvent(parts ...*genai.Part) *session.Event {
+	return &session.Event{
+		Author:      "assistant_agent",
+		LLMResponse: model.LLMResponse{Content: genai.NewContentFromParts(parts, genai.RoleModel)},
+	}
+}
+
+// toolSession is a session with a tool-using turn followed by a failed turn.
+func toolSession() session.Session {
+	failed := agentEvent(genai.NewPartFromText("partial answer"))
+	failed.ErrorCode = "RESOURCE_EXHAUSTED"
+
+	return newSession("sess-1",
+		userEvent("What is the weather in Paris? Mail me at jane@example.com"),
+		agentEvent(&genai.Part{FunctionCall: &genai.FunctionCall{
+			ID: "call-1", Name: "get_weather", Args: map[string]any{"city": "Paris"},
+		}}),
+		agentEvent(&genai.Part{FunctionResponse: &genai.FunctionResponse{
+			ID: "call-1", Name: "get_weather", Response: map[string]any{"temp": 21},
+		}}),
+		agentEvent(genai.NewPartFromText("It is 21°C in Paris.")),
+		userEvent("And tomorrow?"),
+		failed,
+	)
+}
+
+func export(t *testing.T, cfg Config, sessions ...session.Session) (*Stats, []map[string]any) {
+	t.Helper()
+
+	e, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	stats, err := e.Export(context.Background(), &buf, FromSessions(sessions))
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	var lines []map[string]any
+	for line := range strings.Lines(buf.String()) {
+		var v map[string]any
+		if err := json.Unmarshal([]byte(line), &v); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", line, err)
+		}
+		lines = append(lines, v)
+	}
+
+	return stats, lines
+}
+
+func TestExportOpenAI(t *testing.T) {
+	stats, lines := export(t, Config{SystemPrompt: "You are a weather bot."}, toolSession())
+	if len(lines) != 1 || stats.Examples != 1 || stats.ExportedTurns != 2 {
+		t.Fatalf("Unexpected export: stats=%+v, lines=%d", stats, len(lines))
+	}
+
+	msgs := lines[0]["messages"].([]any)
+	roles := make([]string, len(msgs))
+	for i, m := range msgs {
+		roles[i] = m.(map[string]any)["role"].(string)
+	}
+	want := "system,user,assistant,tool,assistant,user,assistant"
+	if got := strings.Join(roles, ","); got != want {
+		t.Fatalf("roles = %s, want %s", got, want)
+	}
+
+	call := msgs[2].(map[string]any)
+	if call["content"] != nil {
+		t.Errorf("tool call content = %v, want null", call["content"])
+	}
+	fn := call["tool_calls"].([]any)[0].(map[string]any)["function"].(map[string]any)
+	if fn["name"] != "get_weather" || fn["arguments"] != `{"city":"Paris"}` {
+		t.Errorf("Unexpected function call: %v", fn)
+	}
+	if tool := msgs[3].(map[string]any); tool["tool_call_id"] != "call-1" || tool["content"] != `{"temp":21}` {
+		t.Errorf("Unexpected tool message: %v", tool)
+	}
+}
+
+func TestExportShareGPT(t *testing.T) {
+	_, lines := export(t, Config{Format: FormatShareGPT, OnlySuccessful: true}, toolSession())
+	if len(lines) != 1 {
+		t.Fatalf("Expected 1 line, got %d", len(lines))
+	}
+
+	convs := lines[0]["conversations"].([]any)
+	var from []string
+	for _, c := range convs {
+		from = append(from, c.(map[string]any)["from"].(string))
+	}
+	want := "human,function_call,observation,gpt"
+	if got := strings.Join(from, ","); got != want {
+		t.Fatalf("from = %s, want %s", got, want)
+	}
+
+	value := convs[1].(map[string]any)["value"].(string)
+	if value != `{"arguments":{"city":"Paris"},"name":"get_weather"}` &&
+		value != `{"name":"get_weather","arguments":{"city":"Paris"}}` {
+		t.Errorf("Unexpected function_call value: %s", value)
+	}
+}
+
+func TestExportFilters(t *testing.T) {
+	redactor := transcript.NewPatternRedactor(regexp.MustCompile(`[\w.]+@[\w.]+`))
+
+	stats, lines := export(t, Config{
+		OnlySuccessful:     true,
+		StripToolInternals: true,
+		Redactor:           redactor,
+	}, toolSession())
+
+	if stats.DroppedTurns != 1 || stats.ExportedTurns != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	msgs := lines[0]["messages"].([]any)
+	if len(msgs) != 2 {
+		t.Fatalf("Expected user and assistant messages, got %v", msgs)
+	}
+	if content := msgs[0].(map[string]any)["content"].(string); strings.Contains(content, "jane@example.com") ||
+		!strings.Contains(content, "[REDACTED]") {
+		t.Errorf("user message not redacted: %q", content)
+	}
+	if content := msgs[1].(map[string]any)["content"]; content != "It is 21°C in Paris." {
+		t.Errorf("assistant message = %v", content)
+	}
+}
+
+func TestExportMinTurns(t *testing.T) {
+	onlyUser := newSession("sess-2", userEvent("hello?"))
+
+	stats, lines := export(t, Config{}, onlyUser)
+	if len(lines) != 0 || stats.TooShort != 1 {
+		t.Errorf("Expected session without answer to be skipped: stats=%+v", stats)
+	}
+
+	stats, lines = export(t, Config{MinTurns: 3}, toolSession())
+	if len(lines) != 0 || stats.TooShort != 1 {
+		t.Errorf("Expected session below MinTurns to be skipped: stats=%+v", stats)
+	}
+}
+
+func TestExportSampling(t *testing.T) {
+	var sessions []session.Session
+	for i := range 200 {
+		sessions = append(sessions, newSession(fmt.Sprintf("sess-%d", i),
+			userEvent("hi"), agentEvent(genai.NewPartFromText("hello"))))
+	}
+
+	cfg := Config{SampleRate: 0.25, Seed: 7}
+	stats, _ := export(t, cfg, sessions...)
+	if stats.Examples < 25 || stats.Examples > 75 {
+		t.Errorf("Expected about 50 sampled examples, got %d", stats.Examples)
+	}
+	if stats.Examples+stats.SampledOut != len(sessions) {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	again, _ := export(t, cfg, sessions...)
+	if again.Examples != stats.Examples {
+		t.Errorf("Sampling not deterministic: %d != %d", again.Examples, stats.Examples)
+	}
+
+	limited, lines := export(t, Config{MaxExamples: 10}, sessions...)
+	if len(lines) != 10 || limited.Examples != 10 {
+		t.Errorf("Expected MaxExamples to stop at 10, got %d", len(lines))
+	}
+}
+
+func TestNewUnknownFormat(t *testing.T) {
+	if _, err := New(Config{Format: "alpaca"}); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
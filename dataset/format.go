@@ -0,0 +1,119 @@
+package dataset
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+
+	"github.com/bytedance/sonic"
+)
+
+// openAIExample is one line of the OpenAI chat fine-tuning format.
+type openAIExample struct {
+	Messages []openAIMessage `json:"messages"`
+}
+
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    *string          `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAIToolCall struct {
+	ID       string             `json:"id"`
+	Type     string             `json:"type"`
+	Function openAIFunctionCall `json:"function"`
+}
+
+type openAIFunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+func encodeOpenAI(msgs []message) (any, error) {
+	out := make([]openAIMessage, 0, len(msgs))
+	for _, m := range msgs {
+		om := openAIMessage{Role: m.Role, ToolCallID: m.ToolCallID}
+		// An assistant message with only tool calls has a null content
+		if m.Text != "" || len(m.ToolCalls) == 0 {
+			text := m.Text
+			om.Content = &text
+		}
+		for _, c := range m.ToolCalls {
+			om.ToolCalls = append(om.ToolCalls, openAIToolCall{
+				ID:       c.ID,
+				Type:     "function",
+				Function: openAIFunctionCall{Name: c.Name, Arguments: c.Arguments},
+			})
+		}
+		out = append(out, om)
+	}
+
+	return openAIExample{Messages: out}, nil
+}
+
+// shareGPTExample is one line of the ShareGPT format.
+type shareGPTExample struct {
+	Conversations []shareGPTMessage `json:"conversations"`
+}
+
+type shareGPTMessage struct {
+	From  string `json:"from"`
+	Value string `json:"value"`
+}
+
+func encodeShareGPT(msgs []message) (any, error) {
+	out := make([]shareGPTMessage, 0, len(msgs))
+	for _, m := range msgs {
+		switch m.Role {
+		case roleSystem:
+			out = append(out, shareGPTMessage{From: "system", Value: m.Text})
+		case roleUser:
+			out = append(out, shareGPTMessage{From: "human", Value: m.Text})
+		case roleTool:
+			out = append(out, shareGPTMessage{From: "observation", Value: m.Text})
+		case roleAssistant:
+			if m.Text != "" {
+				out = append(out, shareGPTMessage{From: "gpt", Value: m.Text})
+			}
+			for _, c := range m.ToolCalls {
+				// Redaction may break the arguments JSON, keep them as a string then
+				var args any = c.Arguments
+				if json.Valid([]byte(c.Arguments)) {
+					args = json.RawMessage(c.Arguments)
+				}
+				value, err := sonic.MarshalString(map[string]any{"name": c.Name, "arguments": args})
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, shareGPTMessage{From: "function_call", Value: value})
+			}
+		}
+	}
+
+	return shareGPTExample{Conversations: out}, nil
+}
+
+// lineWriter writes JSON values as newline-delimited lines.
+type lineWriter struct {
+	w *bufio.Writer
+}
+
+func newLineWriter(w io.Writer) *lineWriter {
+	return &lineWriter{w: bufio.NewWriter(w)}
+}
+
+func (lw *lineWriter) write(v any) error {
+	data, err := sonic.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := lw.w.Write(data); err != nil {
+		return err
+	}
+	if err := lw.w.WriteByte('\n'); err != nil {
+		return err
+	}
+	return lw.w.Flush()
+}
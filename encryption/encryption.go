@@ -0,0 +1,214 @@
+// Package encryption seals stored payloads with per-app (tenant) keys. Every
+// ciphertext is tagged with the ID of its key, so keys can be rotated: new payloads
+// use the app's current key, older ones stay readable, and a Rotator re-encrypts
+// them in the background.
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// prefix marks an encrypted payload: "enc:v1:{keyID}:{base64(nonce|ciphertext)}".
+// The text form keeps ciphertexts storable in Redis strings and JSONB columns.
+const prefix = "enc:v1:"
+
+var (
+	// ErrNotEncrypted is returned when decrypting a payload without the encryption prefix.
+	ErrNotEncrypted = errors.New("payload is not encrypted")
+
+	// ErrMalformed is returned for an encrypted payload that cannot be parsed.
+	ErrMalformed = errors.New("malformed encrypted payload")
+
+	// ErrKeyNotFound is returned by a KeyProvider without the requested key.
+	ErrKeyNotFound = errors.New("encryption key not found")
+)
+
+// Encryptor encrypts payloads with the keys of their app.
+type Encryptor struct {
+	keys KeyProvider
+}
+
+// NewEncryptor creates an Encryptor using keys.
+func NewEncryptor(keys KeyProvider) *Encryptor {
+	return &Encryptor{keys: keys}
+}
+
+// Encrypt seals plaintext with the current key of appName. The app name is
+// authenticated, so a ciphertext cannot be replayed into another tenant.
+func (e *Encryptor) Encrypt(ctx context.Context, appName string, plaintext []byte) ([]byte, error) {
+	return e.seal(ctx, appName, plaintext, false)
+}
+
+// EncryptDeterministic seals plaintext like Encrypt, with a nonce derived from
+// the key, the app and the plaintext: equal plaintexts of an app give equal
+// ciphertexts under the same key. It reveals which payloads are equal, so use it
+// only where stored ciphertexts are compared, e.g. by Redis scripts detecting
+// changed state values. Decrypt opens its ciphertexts.
+func (e *Encryptor) EncryptDeterministic(ctx context.Context, appName string, plaintext []byte) ([]byte, error) {
+	return e.seal(ctx, appName, plaintext, true)
+}
+
+func (e *Encryptor) seal(ctx context.Context, appName string, plaintext []byte, deterministic bool) ([]byte, error) {
+	key, err := e.keys.CurrentKey(ctx, appName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current key of %s: %w", appName, err)
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	ad := additionalData(appName, key.ID)
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if deterministic {
+		// NOTE: A synthetic nonce, only ever reused for the same plaintext
+		copy(nonce, syntheticNonce(key, ad, plaintext))
+	} else if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, ad)
+
+	out := make([]byte, 0, len(prefix)+len(key.ID)+1+base64.RawStdEncoding.EncodedLen(len(sealed)))
+	out = append(out, prefix...)
+	out = append(out, key.ID...)
+	out = append(out, ':')
+	out = base64.RawStdEncoding.AppendEncode(out, sealed)
+
+	return out, nil
+}
+
+// Decrypt opens a payload sealed by Encrypt for appName, with whichever key it was
+// sealed with.
+func (e *Encryptor) Decrypt(ctx context.Context, appName string, data []byte) ([]byte, error) {
+	keyID, sealed, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := e.keys.Key(ctx, appName, keyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key %s of %s: %w", keyID, appName, err)
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData(appName, keyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt payload: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+// Rewrap re-encrypts data with the current key of appName. It reports false, with
+// data unchanged, if data is not encrypted or already uses the current key.
+func (e *Encryptor) Rewrap(ctx context.Context, appName string, data []byte) ([]byte, bool, error) {
+	keyID, ok := KeyID(data)
+	if !ok {
+		return data, false, nil
+	}
+
+	current, err := e.keys.CurrentKey(ctx, appName)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get current key of %s: %w", appName, err)
+	}
+	if keyID == current.ID {
+		return data, false, nil
+	}
+
+	plaintext, err := e.Decrypt(ctx, appName, data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	rewrapped, err := e.Encrypt(ctx, appName, plaintext)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return rewrapped, true, nil
+}
+
+// IsEncrypted reports whether data is an encrypted payload.
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(prefix))
+}
+
+// KeyID returns the ID of the key data was encrypted with.
+func KeyID(data []byte) (string, bool) {
+	keyID, _, err := parse(data)
+	return keyID, err == nil
+}
+
+func parse(data []byte) (keyID string, sealed []byte, err error) {
+	rest, ok := bytes.CutPrefix(data, []byte(prefix))
+	if !ok {
+		return "", nil, ErrNotEncrypted
+	}
+
+	id, encoded, ok := bytes.Cut(rest, []byte{':'})
+	if !ok || len(id) == 0 {
+		return "", nil, ErrMalformed
+	}
+
+	sealed, err = base64.RawStdEncoding.AppendDecode(nil, encoded)
+	if err != nil {
+		return "", nil, ErrMalformed
+	}
+
+	return string(id), sealed, nil
+}
+
+func newAEAD(key Key) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key.Material)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key %s: %w", key.ID, err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	return aead, nil
+}
+
+// syntheticNonce returns the HMAC-SHA256 of the additional data and plaintext,
+// under a subkey of key distinct from the encryption key.
+func syntheticNonce(key Key, ad, plaintext []byte) []byte {
+	sub := hmac.New(sha256.New, key.Material)
+	sub.Write([]byte("k-adk synthetic nonce"))
+
+	mac := hmac.New(sha256.New, sub.Sum(nil))
+	mac.Write(ad)
+	mac.Write([]byte{0})
+	mac.Write(plaintext)
+	return mac.Sum(nil)
+}
+
+func additionalData(appName, keyID string) []byte {
+	return []byte(appName + "\x00" + keyID)
+}
+
+// validKeyID reports whether id can tag a ciphertext.
+func validKeyID(id string) bool {
+	return id != "" && !strings.ContainsAny(id, ":,\x00")
+}
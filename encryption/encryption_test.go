@@ -0,0 +1,305 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func testKey(id string, b byte) Key {
+	return Key{ID: id, Material: bytes.Repeat([]byte{b}, keySize)}
+}
+
+func newTestKeyring(t *testing.T) *Keyring {
+	t.Helper()
+
+	keys := NewKeyring()
+	if err := keys.Set("app_a", testKey("a1", 1)); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := keys.Set(defaultApp, testKey("d1", 2)); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	return keys
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	ctx := context.Background()
+	enc := NewEncryptor(newTestKeyring(t))
+
+	ciphertext, err := enc.Encrypt(ctx, "app_a", []byte(`{"city":"Paris"}`))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if !IsEncrypted(ciphertext) || bytes.Contains(ciphertext, []byte("Paris")) {
+		t.Fatalf("Unexpected ciphertext: %s", ciphertext)
+	}
+	if id, ok := KeyID(ciphertext); !ok || id != "a1" {
+		t.Errorf("KeyID() = %q, %v", id, ok)
+	}
+
+	plaintext, err := enc.Decrypt(ctx, "app_a", ciphertext)
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if string(plaintext) != `{"city":"Paris"}` {
+		t.Errorf("Decrypt() = %s", plaintext)
+	}
+
+	// Apps without keys use the default keys
+	other, err := enc.Encrypt(ctx, "app_b", []byte("x"))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if id, _ := KeyID(other); id != "d1" {
+		t.Errorf("Expected default key, got %s", id)
+	}
+
+	if _, err := enc.Decrypt(ctx, "app_a", []byte("plain")); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("Expected ErrNotEncrypted, got %v", err)
+	}
+}
+
+func TestEncryptDeterministic(t *testing.T) {
+	ctx := context.Background()
+	enc := NewEncryptor(newTestKeyring(t))
+
+	first, err := enc.EncryptDeterministic(ctx, "app_a", []byte(`"Paris"`))
+	if err != nil {
+		t.Fatalf("EncryptDeterministic failed: %v", err)
+	}
+	second, _ := enc.EncryptDeterministic(ctx, "app_a", []byte(`"Paris"`))
+	if !bytes.Equal(first, second) {
+		t.Errorf("Expected equal ciphertexts, got %s and %s", first, second)
+	}
+
+	other, _ := enc.EncryptDeterministic(ctx, "app_a", []byte(`"Lyon"`))
+	otherApp, _ := enc.EncryptDeterministic(ctx, "app_b", []byte(`"Paris"`))
+	if bytes.Equal(first, other) || bytes.Equal(first, otherApp) {
+		t.Error("Expected distinct ciphertexts for other plaintexts and apps")
+	}
+
+	plaintext, err := enc.Decrypt(ctx, "app_a", first)
+	if err != nil || string(plaintext) != `"Paris"` {
+		t.Errorf("Decrypt() = %s, %v", plaintext, err)
+	}
+}
+
+func TestDecryptOtherTenant(t *testing.T) {
+	ctx := context.Background()
+	keys := newTestKeyring(t)
+	keys.Set("app_b", testKey("a1", 1)) // same key, other tenant
+	enc := NewEncryptor(keys)
+
+	ciphertext, err := enc.Encrypt(ctx, "app_a", []byte("secret"))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if _, err := enc.Decrypt(ctx, "app_b", ciphertext); err == nil {
+		t.Error("Expected payload of app_a not to decrypt as app_b")
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	ctx := context.Background()
+	keys := newTestKeyring(t)
+	enc := NewEncryptor(keys)
+
+	old, _ := enc.Encrypt(ctx, "app_a", []byte("secret"))
+
+	if _, changed, _ := enc.Rewrap(ctx, "app_a", old); changed {
+		t.Error("Expected no rewrap with the current key")
+	}
+	if _, changed, _ := enc.Rewrap(ctx, "app_a", []byte("plain")); changed {
+		t.Error("Expected plaintext to be left untouched")
+	}
+
+	if err := keys.Rotate("app_a", testKey("a2", 3)); err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+
+	rewrapped, changed, err := enc.Rewrap(ctx, "app_a", old)
+	if err != nil || !changed {
+		t.Fatalf("Rewrap() changed=%v, err=%v", changed, err)
+	}
+	if id, _ := KeyID(rewrapped); id != "a2" {
+		t.Errorf("Expected key a2, got %s", id)
+	}
+
+	// Retired keys still decrypt
+	for _, data := range [][]byte{old, rewrapped} {
+		if plaintext, err := enc.Decrypt(ctx, "app_a", data); err != nil || string(plaintext) != "secret" {
+			t.Errorf("Decrypt() = %q, %v", plaintext, err)
+		}
+	}
+}
+
+func TestEnvKeyProvider(t *testing.T) {
+	ctx := context.Background()
+	k1 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, keySize))
+	k2 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, keySize))
+
+	t.Setenv("TEST_KEYS_WEATHER_APP", "k2:"+k2+", k1:"+k1)
+	t.Setenv("TEST_KEYS_DEFAULT", "d1:"+k1)
+
+	p := &EnvKeyProvider{Prefix: "TEST_KEYS_"}
+
+	if key, err := p.CurrentKey(ctx, "weather-app"); err != nil || key.ID != "k2" {
+		t.Errorf("CurrentKey() = %v, %v", key.ID, err)
+	}
+	if key, err := p.Key(ctx, "weather-app", "k1"); err != nil || key.Material[0] != 1 {
+		t.Errorf("Key(k1) = %v, %v", key.ID, err)
+	}
+	if key, err := p.CurrentKey(ctx, "other"); err != nil || key.ID != "d1" {
+		t.Errorf("Expected default key, got %v, %v", key.ID, err)
+	}
+	if _, err := p.Key(ctx, "weather-app", "k9"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+
+	if _, err := ParseKeys("k1:" + base64.StdEncoding.EncodeToString([]byte("short"))); err == nil {
+		t.Error("Expected error for a short key")
+	}
+}
+
+func TestFileKeyProvider(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keys.json")
+	k1 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, keySize))
+	k2 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, keySize))
+
+	os.WriteFile(path, []byte(`{"app_a": [{"id": "k1", "key": "`+k1+`"}]}`), 0o600)
+
+	p, err := NewFileKeyProvider(path)
+	if err != nil {
+		t.Fatalf("NewFileKeyProvider failed: %v", err)
+	}
+	if key, err := p.CurrentKey(ctx, "app_a"); err != nil || key.ID != "k1" {
+		t.Errorf("CurrentKey() = %v, %v", key.ID, err)
+	}
+
+	os.WriteFile(path, []byte(`{"app_a": [{"id": "k2", "key": "`+k2+`"}, {"id": "k1", "key": "`+k1+`"}]}`), 0o600)
+	if err := p.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if key, err := p.CurrentKey(ctx, "app_a"); err != nil || key.ID != "k2" {
+		t.Errorf("CurrentKey() after reload = %v, %v", key.ID, err)
+	}
+
+	os.WriteFile(path, []byte(`{"app_a": [{"id": "k3", "key": "c2hvcnQ="}]}`), 0o600)
+	if err := p.Reload(); err == nil {
+		t.Error("Expected error for an invalid key file")
+	}
+	if key, _ := p.CurrentKey(ctx, "app_a"); key.ID != "k2" {
+		t.Errorf("Expected keys unchanged after failed reload, got %s", key.ID)
+	}
+}
+
+// fakeKMS unwraps keys by xoring them with 0xff and counts the calls.
+type fakeKMS struct{ calls int }
+
+func (f *fakeKMS) Decrypt(_ context.Context, wrapped []byte) ([]byte, error) {
+	f.calls++
+	out := make([]byte, len(wrapped))
+	for i, b := range wrapped {
+		out[i] = b ^ 0xff
+	}
+	return out, nil
+}
+
+func TestKMSKeyProvider(t *testing.T) {
+	ctx := context.Background()
+	kms := &fakeKMS{}
+	p := NewKMSKeyProvider(kms)
+	p.Set("app_a", WrappedKey{ID: "k1", Wrapped: bytes.Repeat([]byte{0xfe}, keySize)})
+
+	enc := NewEncryptor(p)
+	ciphertext, err := enc.Encrypt(ctx, "app_a", []byte("secret"))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if _, err := enc.Decrypt(ctx, "app_a", ciphertext); err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+
+	key, _ := p.CurrentKey(ctx, "app_a")
+	if key.Material[0] != 1 {
+		t.Errorf("Unexpected unwrapped key: %v", key.Material[0])
+	}
+	if kms.calls != 1 {
+		t.Errorf("Expected the key to be unwrapped once, got %d calls", kms.calls)
+	}
+}
+
+// getTestRedisAddr returns the Redis address for testing.
+// Falls back to localhost:6379 if TEST_REDIS_ADDR is not set.
+func getTestRedisAddr() string {
+	if addr := os.Getenv("TEST_REDIS_ADDR"); addr != "" {
+		return addr
+	}
+	return "localhost:6379"
+}
+
+func TestRedisTargetRotate(t *testing.T) {
+	rdb := redis.NewUniversalClient(&redis.UniversalOptions{Addrs: []string{getTestRedisAddr()}})
+	t.Cleanup(func() { rdb.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		t.Skipf("Redis not available at %s, skipping test: %v", getTestRedisAddr(), err)
+	}
+
+	keys := newTestKeyring(t)
+	enc := NewEncryptor(keys)
+
+	sessionKey, eventsKey := "test_rotate:app_a:user1:sess1", "test_rotate_events:app_a:user1:sess1"
+	t.Cleanup(func() { rdb.Del(context.Background(), sessionKey, eventsKey) })
+
+	state, _ := enc.Encrypt(ctx, "app_a", []byte(`{"state":{}}`))
+	event, _ := enc.Encrypt(ctx, "app_a", []byte(`{"id":"e1"}`))
+	rdb.Set(ctx, sessionKey, state, time.Hour)
+	rdb.RPush(ctx, eventsKey, event, "plain")
+
+	keys.Rotate("app_a", testKey("a2", 3))
+
+	rotator, err := NewRotator(RotatorConfig{
+		Encryptor: enc,
+		Targets: []Target{&RedisTarget{
+			Client:   rdb,
+			Patterns: []string{"test_rotate:*", "test_rotate_events:*"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("NewRotator failed: %v", err)
+	}
+
+	if report := rotator.RunOnce(ctx); report["redis"] != 2 {
+		t.Errorf("Expected 2 rewrapped payloads, got %v", report)
+	}
+
+	data, _ := rdb.Get(ctx, sessionKey).Bytes()
+	if id, _ := KeyID(data); id != "a2" {
+		t.Errorf("Expected session rewrapped with a2, got %s", id)
+	}
+	if ttl := rdb.TTL(ctx, sessionKey).Val(); ttl <= 0 {
+		t.Errorf("Expected TTL to be kept, got %v", ttl)
+	}
+
+	events, _ := rdb.LRange(ctx, eventsKey, 0, -1).Result()
+	if id, _ := KeyID([]byte(events[0])); id != "a2" || events[1] != "plain" {
+		t.Errorf("Unexpected events after rotation: %v", events)
+	}
+
+	if report := rotator.RunOnce(ctx); report["redis"] != 0 {
+		t.Errorf("Expected nothing to rotate, got %v", report)
+	}
+}
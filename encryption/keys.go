@@ -0,0 +1,403 @@
+package encryption
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/bytedance/sonic"
+)
+
+// keySize is the size of an AES-256 key.
+const keySize = 32
+
+// defaultApp holds the keys of apps without keys of their own.
+const defaultApp = "*"
+
+// Key is a data encryption key.
+type Key struct {
+	// ID tags the ciphertexts sealed with the key. It cannot contain ':' or ','.
+	ID string
+
+	// Material is the 32-byte AES-256 key.
+	Material []byte
+}
+
+// KeyProvider selects the keys of an app (tenant).
+type KeyProvider interface {
+	// CurrentKey returns the key new payloads of appName are encrypted with.
+	CurrentKey(ctx context.Context, appName string) (Key, error)
+
+	// Key returns the key id of appName, current or retired. It returns
+	// ErrKeyNotFound if there is no such key.
+	Key(ctx context.Context, appName, id string) (Key, error)
+}
+
+// Keyring is a KeyProvider holding the keys in memory. The first key of an app is
+// its current key; the others are retired keys kept to decrypt older payloads.
+// Keys of the app "*" are used by apps without keys of their own.
+type Keyring struct {
+	mu   sync.RWMutex
+	apps map[string][]Key
+}
+
+// NewKeyring creates an empty Keyring.
+func NewKeyring() *Keyring {
+	return &Keyring{apps: make(map[string][]Key)}
+}
+
+// Set replaces the keys of appName, current key first.
+func (k *Keyring) Set(appName string, keys ...Key) error {
+	if len(keys) == 0 {
+		return fmt.Errorf("no keys for app %s", appName)
+	}
+	for _, key := range keys {
+		if err := validateKey(key); err != nil {
+			return err
+		}
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.apps[appName] = keys
+
+	return nil
+}
+
+// Rotate makes key the current key of appName, keeping the previous keys.
+func (k *Keyring) Rotate(appName string, key Key) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.apps[appName] = append([]Key{key}, k.apps[appName]...)
+
+	return nil
+}
+
+// CurrentKey implements KeyProvider.
+func (k *Keyring) CurrentKey(_ context.Context, appName string) (Key, error) {
+	keys := k.keys(appName)
+	if len(keys) == 0 {
+		return Key{}, fmt.Errorf("%w: no keys for app %s", ErrKeyNotFound, appName)
+	}
+
+	return keys[0], nil
+}
+
+// Key implements KeyProvider.
+func (k *Keyring) Key(_ context.Context, appName, id string) (Key, error) {
+	for _, key := range k.keys(appName) {
+		if key.ID == id {
+			return key, nil
+		}
+	}
+
+	return Key{}, fmt.Errorf("%w: %s", ErrKeyNotFound, id)
+}
+
+func (k *Keyring) keys(appName string) []Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if keys, ok := k.apps[appName]; ok {
+		return keys
+	}
+	return k.apps[defaultApp]
+}
+
+// EnvKeyProvider reads the keys of an app from the environment variable
+// Prefix + APP_NAME (upper-cased, non-alphanumerics replaced by '_'), falling back
+// to Prefix + "DEFAULT". The value is a comma-separated list of "id:base64key",
+// current key first:
+//
+//	ADK_ENCRYPTION_KEYS_WEATHER_APP="k2:9x...=,k1:Qm...="
+type EnvKeyProvider struct {
+	// Optional. Prefix of the variables. Default: "ADK_ENCRYPTION_KEYS_".
+	Prefix string
+}
+
+// CurrentKey implements KeyProvider.
+func (p *EnvKeyProvider) CurrentKey(_ context.Context, appName string) (Key, error) {
+	keys, err := p.keys(appName)
+	if err != nil {
+		return Key{}, err
+	}
+
+	return keys[0], nil
+}
+
+// Key implements KeyProvider.
+func (p *EnvKeyProvider) Key(_ context.Context, appName, id string) (Key, error) {
+	keys, err := p.keys(appName)
+	if err != nil {
+		return Key{}, err
+	}
+
+	for _, key := range keys {
+		if key.ID == id {
+			return key, nil
+		}
+	}
+
+	return Key{}, fmt.Errorf("%w: %s", ErrKeyNotFound, id)
+}
+
+func (p *EnvKeyProvider) keys(appName string) ([]Key, error) {
+	prefix := p.Prefix
+	if prefix == "" {
+		prefix = "ADK_ENCRYPTION_KEYS_"
+	}
+
+	name := prefix + envName(appName)
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		name = prefix + "DEFAULT"
+		value, ok = os.LookupEnv(name)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: no keys for app %s", ErrKeyNotFound, appName)
+	}
+
+	keys, err := ParseKeys(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+
+	return keys, nil
+}
+
+func validateKey(key Key) error {
+	if !validKeyID(key.ID) {
+		return fmt.Errorf("invalid key id %q", key.ID)
+	}
+	if len(key.Material) != keySize {
+		return fmt.Errorf("key %s must be %d bytes, got %d", key.ID, keySize, len(key.Material))
+	}
+	return nil
+}
+
+func envName(appName string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, appName)
+}
+
+// ParseKeys parses a comma-separated list of "id:base64key".
+func ParseKeys(s string) ([]Key, error) {
+	var keys []Key
+	for entry := range strings.SplitSeq(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || !validKeyID(id) {
+			return nil, fmt.Errorf("invalid key entry %q, expected id:base64key", id)
+		}
+
+		material, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode key %s: %w", id, err)
+		}
+		if len(material) != keySize {
+			return nil, fmt.Errorf("key %s must be %d bytes, got %d", id, keySize, len(material))
+		}
+
+		keys = append(keys, Key{ID: id, Material: material})
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: empty key list", ErrKeyNotFound)
+	}
+
+	return keys, nil
+}
+
+// FileKeyProvider reads the keys from a JSON file mapping app names (or "*") to
+// their keys, current key first:
+//
+//	{"weather_app": [{"id": "k2", "key": "9x...="}, {"id": "k1", "key": "Qm...="}]}
+//
+// Call Reload after rotating keys in the file.
+type FileKeyProvider struct {
+	*Keyring
+
+	path string
+}
+
+// NewFileKeyProvider creates a FileKeyProvider and loads path.
+func NewFileKeyProvider(path string) (*FileKeyProvider, error) {
+	p := &FileKeyProvider{Keyring: NewKeyring(), path: path}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Reload reads the key file again. The keys are left unchanged on error.
+func (p *FileKeyProvider) Reload() error {
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	var file map[string][]struct {
+		ID  string `json:"id"`
+		Key []byte `json:"key"`
+	}
+	if err := sonic.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse key file: %w", err)
+	}
+
+	keyring := NewKeyring()
+	for appName, entries := range file {
+		keys := make([]Key, 0, len(entries))
+		for _, e := range entries {
+			keys = append(keys, Key{ID: e.ID, Material: e.Key})
+		}
+		if err := keyring.Set(appName, keys...); err != nil {
+			return fmt.Errorf("invalid keys for app %s: %w", appName, err)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.apps = keyring.apps
+
+	return nil
+}
+
+// KMS decrypts data keys wrapped by a key management service (AWS KMS, GCP KMS,
+// Vault transit, ...). Implementations adapt the client of the service.
+type KMS interface {
+	Decrypt(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// WrappedKey is a data key encrypted by a KMS.
+type WrappedKey struct {
+	ID      string
+	Wrapped []byte
+}
+
+// KMSKeyProvider is a KeyProvider whose data keys are stored wrapped by a KMS
+// (envelope encryption). Keys are unwrapped on first use and cached in memory.
+type KMSKeyProvider struct {
+	kms KMS
+
+	mu        sync.RWMutex
+	wrapped   map[string][]WrappedKey
+	unwrapped map[string][]byte
+}
+
+// NewKMSKeyProvider creates a KMSKeyProvider unwrapping keys with kms.
+func NewKMSKeyProvider(kms KMS) *KMSKeyProvider {
+	return &KMSKeyProvider{
+		kms:       kms,
+		wrapped:   make(map[string][]WrappedKey),
+		unwrapped: make(map[string][]byte),
+	}
+}
+
+// Set replaces the wrapped keys of appName ("*" for the default), current key first.
+func (p *KMSKeyProvider) Set(appName string, keys ...WrappedKey) error {
+	if len(keys) == 0 {
+		return fmt.Errorf("no keys for app %s", appName)
+	}
+	for _, key := range keys {
+		if !validKeyID(key.ID) {
+			return fmt.Errorf("invalid key id %q", key.ID)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.wrapped[appName] = keys
+	// IDs may have been reused for other keys
+	clear(p.unwrapped)
+
+	return nil
+}
+
+// CurrentKey implements KeyProvider.
+func (p *KMSKeyProvider) CurrentKey(ctx context.Context, appName string) (Key, error) {
+	keys := p.keys(appName)
+	if len(keys) == 0 {
+		return Key{}, fmt.Errorf("%w: no keys for app %s", ErrKeyNotFound, appName)
+	}
+
+	return p.unwrap(ctx, appName, keys[0])
+}
+
+// Key implements KeyProvider.
+func (p *KMSKeyProvider) Key(ctx context.Context, appName, id string) (Key, error) {
+	for _, key := range p.keys(appName) {
+		if key.ID == id {
+			return p.unwrap(ctx, appName, key)
+		}
+	}
+
+	return Key{}, fmt.Errorf("%w: %s", ErrKeyNotFound, id)
+}
+
+func (p *KMSKeyProvider) keys(appName string) []WrappedKey {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if keys, ok := p.wrapped[appName]; ok {
+		return keys
+	}
+	return p.wrapped[defaultApp]
+}
+
+func (p *KMSKeyProvider) unwrap(ctx context.Context, appName string, key WrappedKey) (Key, error) {
+	// Key IDs are unique per app, the default keys are shared
+	cacheKey := appName + "\x00" + key.ID
+
+	p.mu.RLock()
+	material, ok := p.unwrapped[cacheKey]
+	p.mu.RUnlock()
+	if ok {
+		return Key{ID: key.ID, Material: material}, nil
+	}
+
+	material, err := p.kms.Decrypt(ctx, key.Wrapped)
+	if err != nil {
+		return Key{}, fmt.Errorf("failed to unwrap key %s: %w", key.ID, err)
+	}
+	if len(material) != keySize {
+		return Key{}, fmt.Errorf("key %s must be %d bytes, got %d", key.ID, keySize, len(material))
+	}
+
+	p.mu.Lock()
+	p.unwrapped[cacheKey] = material
+	p.mu.Unlock()
+
+	return Key{ID: key.ID, Material: material}, nil
+}
+
+// Ensure interfaces are implemented
+var (
+	_ KeyProvider = (*Keyring)(nil)
+	_ KeyProvider = (*EnvKeyProvider)(nil)
+	_ KeyProvider = (*FileKeyProvider)(nil)
+	_ KeyProvider = (*KMSKeyProvider)(nil)
+)
@@ -0,0 +1,499 @@
+package encryption
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/k-adk/internal/redisscript"
+	"github.com/kydenul/log"
+	"github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultRotationInterval = time.Hour
+	defaultBatchSize        = 100
+)
+
+// RewrapFunc re-encrypts a payload of appName with its current key. It reports
+// false if the payload is unchanged. See Encryptor.Rewrap.
+type RewrapFunc func(ctx context.Context, appName string, data []byte) ([]byte, bool, error)
+
+// Target is a store whose encrypted payloads can be rotated.
+type Target interface {
+	// Name identifies the target in logs and reports.
+	Name() string
+
+	// Rotate rewraps every payload of the target and returns how many changed.
+	// Payloads modified concurrently are skipped and picked up by the next run.
+	Rotate(ctx context.Context, rewrap RewrapFunc) (int, error)
+}
+
+// RotatorConfig configures a Rotator.
+type RotatorConfig struct {
+	// Encryptor rewraps the payloads.
+	Encryptor *Encryptor
+
+	// Targets are the stores to rotate.
+	Targets []Target
+
+	// Optional. Interval between rotation runs. Default: 1h.
+	Interval time.Duration
+
+	// Optional. Logger for logging. Falls back to `DiscardLog` if nil.
+	Logger log.Logger
+}
+
+// Rotator re-encrypts stored payloads with the current keys in the background, so
+// that retired keys can eventually be removed.
+type Rotator struct {
+	log.Logger
+
+	enc      *Encryptor
+	targets  []Target
+	interval time.Duration
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRotator creates a Rotator with the specified configuration. Call Start to
+// rotate periodically, or RunOnce.
+func NewRotator(cfg RotatorConfig) (*Rotator, error) {
+	if cfg.Encryptor == nil {
+		return nil, errors.New("encryptor cannot be nil")
+	}
+	if len(cfg.Targets) == 0 {
+		return nil, errors.New("no rotation targets")
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultRotationInterval
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = discardlog.NewDiscardLog()
+	}
+
+	return &Rotator{
+		Logger:   cfg.Logger,
+		enc:      cfg.Encryptor,
+		targets:  cfg.Targets,
+		interval: cfg.Interval,
+	}, nil
+}
+
+// Start launches the rotation loop. It stops when ctx is cancelled or Stop is called.
+func (r *Rotator) Start(ctx context.Context) {
+	ctx, r.cancel = context.WithCancel(ctx)
+
+	r.wg.Go(func() { r.loop(ctx) })
+
+	r.Infof("key rotator started: interval=%s, targets=%d", r.interval, len(r.targets))
+}
+
+// Stop cancels the running rotation and waits for it to exit.
+func (r *Rotator) Stop() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.wg.Wait()
+
+	r.Info("key rotator stopped")
+}
+
+func (r *Rotator) loop(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		r.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce rotates every target and returns the number of rewrapped payloads per
+// target. A failing target is logged and does not stop the others.
+func (r *Rotator) RunOnce(ctx context.Context) map[string]int {
+	report := make(map[string]int, len(r.targets))
+	for _, t := range r.targets {
+		n, err := t.Rotate(ctx, r.enc.Rewrap)
+		report[t.Name()] = n
+		if err != nil {
+			r.Errorf("key rotation failed: target=%s, rewrapped=%d, err=%v", t.Name(), n, err)
+			continue
+		}
+		if n > 0 {
+			r.Infof("key rotation completed: target=%s, rewrapped=%d", t.Name(), n)
+		}
+	}
+
+	return report
+}
+
+// casStringScript replaces a string value only if it is unchanged, keeping its TTL.
+//
+// KEYS[1]: key
+// ARGV[1]: expected value
+// ARGV[2]: new value
+var casStringScript = redisscript.New("encryption_cas_string", `
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+    return 0
+end
+redis.call('SET', KEYS[1], ARGV[2], 'KEEPTTL')
+return 1
+`)
+
+// casListScript replaces a list element only if it is unchanged.
+//
+// KEYS[1]: key
+// ARGV[1]: index
+// ARGV[2]: expected value
+// ARGV[3]: new value
+var casListScript = redisscript.New("encryption_cas_list", `
+if redis.call('LINDEX', KEYS[1], ARGV[1]) ~= ARGV[2] then
+    return 0
+end
+redis.call('LSET', KEYS[1], ARGV[1], ARGV[3])
+return 1
+`)
+
+// RedisTarget rotates the encrypted string values and list elements of Redis keys.
+type RedisTarget struct {
+	Client redis.UniversalClient
+
+	// Optional. Patterns of the keys to rotate. Default: the session store keys,
+	// "session:*" and "events:*".
+	Patterns []string
+
+	// Optional. AppName returns the app of a key. Default: its second
+	// ':'-separated segment, as in "session:{app}:{user}:{session}".
+	AppName func(key string) string
+
+	// Optional. BatchSize of SCAN and LRANGE. Default: 100.
+	BatchSize int64
+}
+
+// Name implements Target.
+func (t *RedisTarget) Name() string {
+	return "redis"
+}
+
+// Rotate implements Target.
+func (t *RedisTarget) Rotate(ctx context.Context, rewrap RewrapFunc) (int, error) {
+	patterns := t.Patterns
+	if len(patterns) == 0 {
+		patterns = []string{"session:*", "events:*"}
+	}
+	appName := t.AppName
+	if appName == nil {
+		appName = keyAppName
+	}
+	batch := t.BatchSize
+	if batch <= 0 {
+		batch = defaultBatchSize
+	}
+
+	total := 0
+	for _, pattern := range patterns {
+		iter := t.Client.Scan(ctx, 0, pattern, batch).Iterator()
+		for iter.Next(ctx) {
+			key := iter.Val()
+
+			typ, err := t.Client.Type(ctx, key).Result()
+			if err != nil {
+				return total, fmt.Errorf("failed to get type of %s: %w", key, err)
+			}
+
+			var n int
+			switch typ {
+			case "string":
+				n, err = t.rotateString(ctx, key, appName(key), rewrap)
+			case "list":
+				n, err = t.rotateList(ctx, key, appName(key), batch, rewrap)
+			}
+			total += n
+			if err != nil {
+				return total, fmt.Errorf("failed to rotate %s: %w", key, err)
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return total, fmt.Errorf("failed to scan %s: %w", pattern, err)
+		}
+	}
+
+	return total, nil
+}
+
+func (t *RedisTarget) rotateString(
+	ctx context.Context,
+	key, appName string,
+	rewrap RewrapFunc,
+) (int, error) {
+	value, err := t.Client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	rewrapped, changed, err := rewrap(ctx, appName, value)
+	if err != nil || !changed {
+		return 0, err
+	}
+
+	return casStringScript.Run(ctx, t.Client, []string{key}, value, rewrapped).Int()
+}
+
+func (t *RedisTarget) rotateList(
+	ctx context.Context,
+	key, appName string,
+	batch int64,
+	rewrap RewrapFunc,
+) (int, error) {
+	n := 0
+	for start := int64(0); ; start += batch {
+		values, err := t.Client.LRange(ctx, key, start, start+batch-1).Result()
+		if err != nil {
+			return n, err
+		}
+
+		for i, value := range values {
+			rewrapped, changed, err := rewrap(ctx, appName, []byte(value))
+			if err != nil {
+				return n, err
+			}
+			if !changed {
+				continue
+			}
+
+			swapped, err := casListScript.Run(ctx, t.Client, []string{key},
+				start+int64(i), value, rewrapped).Int()
+			if err != nil {
+				return n, err
+			}
+			n += swapped
+		}
+
+		if int64(len(values)) < batch {
+			return n, nil
+		}
+	}
+}
+
+func keyAppName(key string) string {
+	parts := strings.SplitN(key, ":", 3)
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[1]
+}
+
+// PostgresTarget rotates the encrypted values of a table column. Rows are read in
+// batches ordered by their key, and updated only if unchanged since read.
+type PostgresTarget struct {
+	DB *sql.DB
+
+	// Table and Column holding the payloads.
+	Table  string
+	Column string
+
+	// Optional. Schema of Table. Default: the search_path of the connection.
+	Schema string
+
+	// KeyColumns uniquely identify a row, e.g. the primary key.
+	KeyColumns []string
+
+	// Optional. AppColumn holds the app of a row. Default: "app_name".
+	AppColumn string
+
+	// JSONB reports whether Column is JSONB, holding the payload as a JSON string.
+	// Otherwise it is TEXT or BYTEA.
+	JSONB bool
+
+	// Optional. BatchSize of the row reads. Default: 100.
+	BatchSize int
+}
+
+// SessionTables returns the targets of the PostgreSQL session store: the state of
+// the sessions table and the content of its shardCount event tables. The session
+// store client of tables with configured names has its own targets.
+func SessionTables(db *sql.DB, shardCount int) []Target {
+	targets := []Target{&PostgresTarget{
+		DB:         db,
+		Table:      "sessions",
+		Column:     "state",
+		KeyColumns: []string{"app_name", "user_id", "id"},
+		JSONB:      true,
+	}}
+
+	for i := range shardCount {
+		targets = append(targets, &PostgresTarget{
+			DB:         db,
+			Table:      fmt.Sprintf("session_events_%d", i),
+			Column:     "content",
+			KeyColumns: []string{"app_name", "user_id", "session_id", "event_order"},
+			JSONB:      true,
+		})
+	}
+
+	return targets
+}
+
+// Name implements Target.
+func (t *PostgresTarget) Name() string {
+	if t.Schema != "" {
+		return "postgres:" + t.Schema + "." + t.Table + "." + t.Column
+	}
+	return "postgres:" + t.Table + "." + t.Column
+}
+
+// Rotate implements Target.
+func (t *PostgresTarget) Rotate(ctx context.Context, rewrap RewrapFunc) (int, error) {
+	if len(t.KeyColumns) == 0 {
+		return 0, errors.New("key columns cannot be empty")
+	}
+
+	appColumn := t.AppColumn
+	if appColumn == "" {
+		appColumn = "app_name"
+	}
+	batch := t.BatchSize
+	if batch <= 0 {
+		batch = defaultBatchSize
+	}
+
+	// The payload is read and compared as text: the string of a JSONB payload
+	value := pq.QuoteIdentifier(t.Column)
+	if t.JSONB {
+		value += " #>> '{}'"
+	} else {
+		value += "::text"
+	}
+
+	keys := make([]string, len(t.KeyColumns))
+	for i, c := range t.KeyColumns {
+		keys[i] = pq.QuoteIdentifier(c)
+	}
+	keyList := strings.Join(keys, ", ")
+	table := pq.QuoteIdentifier(t.Table)
+	if t.Schema != "" {
+		table = pq.QuoteIdentifier(t.Schema) + "." + table
+	}
+
+	// Keyset pagination: the rows after the last key of the previous batch
+	after := make([]string, len(keys))
+	for i := range keys {
+		after[i] = fmt.Sprintf("$%d", i+1)
+	}
+
+	selectRows := fmt.Sprintf(`SELECT %s, %s, %s FROM %s WHERE %s LIKE 'enc:v1:%%'`,
+		pq.QuoteIdentifier(appColumn), value, keyList, table, value)
+	order := fmt.Sprintf(` ORDER BY %s LIMIT %d`, keyList, batch)
+	selectFirst := selectRows + order
+	selectNext := selectRows + fmt.Sprintf(` AND (%s) > (%s)`, keyList, strings.Join(after, ", ")) + order
+
+	newValue := "$1"
+	if t.JSONB {
+		newValue = "to_jsonb($1::text)"
+	}
+	where := make([]string, len(keys))
+	for i, k := range keys {
+		where[i] = fmt.Sprintf("%s = $%d", k, i+3)
+	}
+	update := fmt.Sprintf(`UPDATE %s SET %s = %s WHERE %s = $2 AND %s`,
+		table, pq.QuoteIdentifier(t.Column), newValue, value, strings.Join(where, " AND "))
+
+	total := 0
+	var last []any
+	for {
+		query, args := selectFirst, []any(nil)
+		if last != nil {
+			query, args = selectNext, last
+		}
+
+		rows, err := t.readBatch(ctx, query, args, len(keys))
+		if err != nil {
+			return total, err
+		}
+
+		for _, row := range rows {
+			rewrapped, changed, err := rewrap(ctx, row.appName, []byte(row.value))
+			if err != nil {
+				return total, fmt.Errorf("failed to rewrap row %v: %w", row.keys, err)
+			}
+			if !changed {
+				continue
+			}
+
+			args := append([]any{string(rewrapped), row.value}, row.keys...)
+			res, err := t.DB.ExecContext(ctx, update, args...)
+			if err != nil {
+				return total, fmt.Errorf("failed to update row %v: %w", row.keys, err)
+			}
+			if affected, _ := res.RowsAffected(); affected > 0 {
+				total++
+			}
+		}
+
+		if len(rows) < batch {
+			return total, nil
+		}
+		last = rows[len(rows)-1].keys
+	}
+}
+
+type encryptedRow struct {
+	appName string
+	value   string
+	keys    []any
+}
+
+func (t *PostgresTarget) readBatch(
+	ctx context.Context,
+	query string,
+	args []any,
+	keyCount int,
+) ([]encryptedRow, error) {
+	rows, err := t.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w", t.Table, err)
+	}
+	defer rows.Close()
+
+	var batch []encryptedRow
+	for rows.Next() {
+		row := encryptedRow{keys: make([]any, keyCount)}
+		dest := make([]any, 0, keyCount+2)
+		dest = append(dest, &row.appName, &row.value)
+		for i := range row.keys {
+			dest = append(dest, &row.keys[i])
+		}
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", t.Table, err)
+		}
+		batch = append(batch, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", t.Table, err)
+	}
+
+	return batch, nil
+}
+
+// Ensure interfaces are implemented
+var (
+	_ Target = (*RedisTarget)(nil)
+	_ Target = (*PostgresTarget)(nil)
+)
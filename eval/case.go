@@ -0,0 +1,134 @@
+// Package eval replays recorded sessions against a candidate agent and scores
+// the new answers against the recorded ones, for offline evaluation of prompt,
+// model and agent changes.
+package eval
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	"google.golang.org/adk/session"
+	"google.golang.org/genai"
+)
+
+// Turn is one user message of a recorded session together with the final text
+// answer the agent gave to it.
+type Turn struct {
+	User     *genai.Content `json:"user"`
+	Expected string         `json:"expected"`
+}
+
+// Case is a recorded session reduced to its user turns.
+type Case struct {
+	ID      string `json:"id"`
+	AppName string `json:"appName"`
+	UserID  string `json:"userId"`
+	Turns   []Turn `json:"turns"`
+}
+
+// exportedSession mirrors the session JSON returned by the sessions REST API
+// (GET /apps/:app_name/users/:user_id/sessions/:session_id).
+type exportedSession struct {
+	ID      string          `json:"id"`
+	AppName string          `json:"appName"`
+	UserID  string          `json:"userId"`
+	Events  []exportedEvent `json:"events"`
+}
+
+type exportedEvent struct {
+	Author  string         `json:"author"`
+	Partial bool           `json:"partial"`
+	Content *genai.Content `json:"content"`
+}
+
+// CaseFromSession builds a Case from a live session.
+func CaseFromSession(s session.Session) Case {
+	events := make([]exportedEvent, 0, s.Events().Len())
+	for e := range s.Events().All() {
+		events = append(events, exportedEvent{Author: e.Author, Partial: e.Partial, Content: e.Content})
+	}
+
+	return Case{
+		ID:      s.ID(),
+		AppName: s.AppName(),
+		UserID:  s.UserID(),
+		Turns:   buildTurns(events),
+	}
+}
+
+// LoadCases reads exported sessions from r. It accepts either a single session
+// object or a JSON array of sessions, as returned by the sessions REST API.
+func LoadCases(r io.Reader) ([]Case, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read exported sessions: %w", err)
+	}
+
+	var sessions []exportedSession
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		err = sonic.Unmarshal(data, &sessions)
+	} else {
+		var single exportedSession
+		err = sonic.Unmarshal(data, &single)
+		sessions = append(sessions, single)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode exported sessions: %w", err)
+	}
+
+	cases := make([]Case, 0, len(sessions))
+	for _, s := range sessions {
+		cases = append(cases, Case{
+			ID:      s.ID,
+			AppName: s.AppName,
+			UserID:  s.UserID,
+			Turns:   buildTurns(s.Events),
+		})
+	}
+
+	return cases, nil
+}
+
+// buildTurns pairs every user message with the last non-partial text written by
+// an agent before the next user message.
+func buildTurns(events []exportedEvent) []Turn {
+	var turns []Turn
+	for _, e := range events {
+		if e.Content == nil || e.Partial {
+			continue
+		}
+
+		if e.Author == "user" {
+			if contentText(e.Content) != "" {
+				turns = append(turns, Turn{User: e.Content})
+			}
+			continue
+		}
+
+		if len(turns) == 0 {
+			continue
+		}
+		if text := contentText(e.Content); text != "" {
+			turns[len(turns)-1].Expected = text
+		}
+	}
+
+	return turns
+}
+
+// contentText concatenates the non-thought text parts of c.
+func contentText(c *genai.Content) string {
+	if c == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, p := range c.Parts {
+		if p != nil && p.Text != "" && !p.Thought {
+			sb.WriteString(p.Text)
+		}
+	}
+	return sb.String()
+}
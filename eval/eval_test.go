@@ -0,0 +1,194 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"math"
+	"strings"
+	"testing"
+
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+// --- LoadCases ---
+
+func TestLoadCases(t *testing.T) {
+	const exported = `{
+		"id": "s1",
+		"appName": "app",
+		"userId": "u1",
+		"events": [
+			{"author": "user", "content": {"role": "user", "parts": [{"text": "hi"}]}},
+			{"author": "agent", "partial": true, "content": {"role": "model", "parts": [{"text": "hel"}]}},
+			{"author": "agent", "content": {"role": "model", "parts": [{"text": "hello"}]}},
+			{"author": "user", "content": {"role": "user", "parts": [{"text": "weather?"}]}},
+			{"author": "agent", "content": {"role": "model", "parts": [{"functionCall": {"name": "get_weather"}}]}},
+			{"author": "agent", "content": {"role": "model", "parts": [{"text": "sunny"}]}}
+		]
+	}`
+
+	t.Run("single session", func(t *testing.T) {
+		cases, err := LoadCases(strings.NewReader(exported))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(cases) != 1 {
+			t.Fatalf("expected 1 case, got %d", len(cases))
+		}
+
+		c := cases[0]
+		if c.ID != "s1" || c.AppName != "app" || c.UserID != "u1" {
+			t.Errorf("unexpected case header: %+v", c)
+		}
+		if len(c.Turns) != 2 {
+			t.Fatalf("expected 2 turns, got %d", len(c.Turns))
+		}
+		if c.Turns[0].Expected != "hello" {
+			t.Errorf("expected 'hello', got %q", c.Turns[0].Expected)
+		}
+		if c.Turns[1].Expected != "sunny" {
+			t.Errorf("expected 'sunny', got %q", c.Turns[1].Expected)
+		}
+	})
+
+	t.Run("array of sessions", func(t *testing.T) {
+		cases, err := LoadCases(strings.NewReader("[" + exported + "," + exported + "]"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(cases) != 2 {
+			t.Errorf("expected 2 cases, got %d", len(cases))
+		}
+	})
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		if _, err := LoadCases(strings.NewReader("{")); err == nil {
+			t.Error("expected error for invalid JSON")
+		}
+	})
+}
+
+// --- Scorers ---
+
+func TestExactMatch(t *testing.T) {
+	ctx := context.Background()
+
+	score, _ := ExactMatch{}.Score(ctx, Sample{Expected: " Hello ", Actual: "Hello"})
+	if score.Value != 1 {
+		t.Errorf("expected 1, got %v", score.Value)
+	}
+
+	score, _ = ExactMatch{}.Score(ctx, Sample{Expected: "Hello", Actual: "hello"})
+	if score.Value != 0 {
+		t.Errorf("expected 0, got %v", score.Value)
+	}
+
+	score, _ = ExactMatch{IgnoreCase: true}.Score(ctx, Sample{Expected: "Hello", Actual: "hello"})
+	if score.Value != 1 {
+		t.Errorf("expected 1 with IgnoreCase, got %v", score.Value)
+	}
+}
+
+type fakeEmbedder map[string][]float32
+
+func (f fakeEmbedder) Embed(_ context.Context, text string) ([]float32, error) {
+	if v, ok := f[text]; ok {
+		return v, nil
+	}
+	return nil, errors.New("unknown text")
+}
+
+func TestEmbeddingSimilarity(t *testing.T) {
+	scorer := NewEmbeddingSimilarity(fakeEmbedder{
+		"a": {1, 0},
+		"b": {1, 1},
+		"c": {-1, 0},
+	})
+	ctx := context.Background()
+
+	score, err := scorer.Score(ctx, Sample{Expected: "a", Actual: "b"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if math.Abs(score.Value-1/math.Sqrt2) > 1e-6 {
+		t.Errorf("expected %v, got %v", 1/math.Sqrt2, score.Value)
+	}
+
+	score, _ = scorer.Score(ctx, Sample{Expected: "a", Actual: "c"})
+	if score.Value != 0 {
+		t.Errorf("expected negative similarity clamped to 0, got %v", score.Value)
+	}
+
+	if _, err := scorer.Score(ctx, Sample{Expected: "a", Actual: "missing"}); err == nil {
+		t.Error("expected embedder error to be returned")
+	}
+}
+
+type fakeLLM struct {
+	text string
+	req  *model.LLMRequest
+}
+
+func (f *fakeLLM) Name() string { return "fake" }
+
+func (f *fakeLLM) GenerateContent(
+	_ context.Context,
+	req *model.LLMRequest,
+	_ bool,
+) iter.Seq2[*model.LLMResponse, error] {
+	f.req = req
+	return func(yield func(*model.LLMResponse, error) bool) {
+		yield(&model.LLMResponse{Content: genai.NewContentFromText(f.text, genai.RoleModel)}, nil)
+	}
+}
+
+func TestLLMJudge(t *testing.T) {
+	llm := &fakeLLM{text: "* Claim 1: Accurate\nJUSTIFICATION: Matches the reference.\n**SCORE:** 0.8"}
+	judge := NewLLMJudge(llm)
+
+	score, err := judge.Score(context.Background(), Sample{Question: "q", Expected: "ref", Actual: "cand"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if score.Value != 0.8 {
+		t.Errorf("expected 0.8, got %v", score.Value)
+	}
+	if score.Reason != "Matches the reference." {
+		t.Errorf("unexpected reason: %q", score.Reason)
+	}
+
+	prompt := llm.req.Contents[0].Parts[0].Text
+	for _, want := range []string{"q", "ref", "cand"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("expected prompt to contain %q, got %q", want, prompt)
+		}
+	}
+
+	llm.text = "no verdict here"
+	if _, err := judge.Score(context.Background(), Sample{}); !errors.Is(err, errNoJudgeScore) {
+		t.Errorf("expected errNoJudgeScore, got %v", err)
+	}
+}
+
+// --- Report ---
+
+func TestReportSummarize(t *testing.T) {
+	r := &Report{Cases: []CaseResult{{
+		Turns: []TurnResult{
+			{Scores: map[string]Score{"exact": {Value: 1}}},
+			{Scores: map[string]Score{"exact": {Value: 0}}},
+			{Scores: map[string]Score{"exact": {Error: "scorer failed"}}},
+			{Error: "boom"},
+		},
+	}}}
+	r.summarize()
+
+	if r.Turns != 4 || r.Failed != 1 {
+		t.Errorf("expected turns=4 failed=1, got turns=%d failed=%d", r.Turns, r.Failed)
+	}
+	if r.MeanScores["exact"] != 0.5 {
+		t.Errorf("expected mean 0.5, got %v", r.MeanScores["exact"])
+	}
+}
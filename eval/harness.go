@@ -0,0 +1,179 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/log"
+	"google.golang.org/adk/agent"
+	"google.golang.org/adk/runner"
+	"google.golang.org/adk/session"
+)
+
+const defaultAppName = "eval"
+
+var errNoAgent = errors.New("eval: agent cannot be nil")
+
+// Config is the configuration for creating a Harness.
+type Config struct {
+	// Agent is the candidate agent (with its model configuration) to evaluate.
+	Agent agent.Agent
+
+	// Scorers compare the candidate answers with the recorded ones.
+	// Falls back to ExactMatch if empty.
+	Scorers []Scorer
+
+	// Optional. AppName used for the replay sessions. Falls back to the case's
+	// AppName, then to "eval".
+	AppName string
+
+	// Optional. Logger for logging. Falls back to `DiscardLog` if nil.
+	Logger log.Logger
+}
+
+// Harness replays Cases against a candidate agent and scores the answers.
+//
+// Every case runs in a fresh in-memory session. Only the user messages are
+// replayed: the history of later turns holds the candidate's own earlier answers.
+type Harness struct {
+	log.Logger
+
+	agent   agent.Agent
+	scorers []Scorer
+	appName string
+}
+
+// New creates a new Harness with the specified configuration.
+func New(cfg Config) (*Harness, error) {
+	if cfg.Agent == nil {
+		return nil, errNoAgent
+	}
+
+	if cfg.Logger == nil {
+		cfg.Logger = discardlog.NewDiscardLog()
+	}
+
+	if len(cfg.Scorers) == 0 {
+		cfg.Scorers = []Scorer{ExactMatch{}}
+	}
+
+	return &Harness{
+		Logger:  cfg.Logger,
+		agent:   cfg.Agent,
+		scorers: cfg.Scorers,
+		appName: cfg.AppName,
+	}, nil
+}
+
+// Run replays every case and returns the report. Failures of individual turns and
+// scorers are recorded in the report; only context cancellation aborts the run.
+func (h *Harness) Run(ctx context.Context, cases []Case) (*Report, error) {
+	report := &Report{StartedAt: time.Now()}
+
+	for _, c := range cases {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		report.Cases = append(report.Cases, h.runCase(ctx, c))
+	}
+
+	report.Duration = time.Since(report.StartedAt)
+	report.summarize()
+
+	h.Infof("eval completed: cases=%d, turns=%d", len(report.Cases), report.Turns)
+
+	return report, nil
+}
+
+func (h *Harness) runCase(ctx context.Context, c Case) CaseResult {
+	result := CaseResult{ID: c.ID}
+
+	appName := h.appName
+	if appName == "" {
+		appName = c.AppName
+	}
+	if appName == "" {
+		appName = defaultAppName
+	}
+
+	userID := c.UserID
+	if userID == "" {
+		userID = "eval_user"
+	}
+
+	sessSvc := session.InMemoryService()
+	created, err := sessSvc.Create(ctx, &session.CreateRequest{AppName: appName, UserID: userID})
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to create session: %v", err)
+		return result
+	}
+
+	r, err := runner.New(runner.Config{
+		AppName:        appName,
+		Agent:          h.agent,
+		SessionService: sessSvc,
+	})
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to create runner: %v", err)
+		return result
+	}
+
+	for i, turn := range c.Turns {
+		tr := TurnResult{
+			Index:    i,
+			Question: contentText(turn.User),
+			Expected: turn.Expected,
+			Scores:   make(map[string]Score, len(h.scorers)),
+		}
+
+		actual, err := h.replayTurn(ctx, r, userID, created.Session.ID(), turn)
+		if err != nil {
+			h.Warnf("replay failed: case=%s, turn=%d, err=%v", c.ID, i, err)
+			tr.Error = err.Error()
+			result.Turns = append(result.Turns, tr)
+			continue
+		}
+		tr.Actual = actual
+
+		sample := Sample{Question: tr.Question, Expected: tr.Expected, Actual: tr.Actual}
+		for _, s := range h.scorers {
+			score, err := s.Score(ctx, sample)
+			if err != nil {
+				h.Warnf("scorer failed: case=%s, turn=%d, scorer=%s, err=%v", c.ID, i, s.Name(), err)
+				score = Score{Error: err.Error()}
+			}
+			tr.Scores[s.Name()] = score
+		}
+
+		result.Turns = append(result.Turns, tr)
+	}
+
+	return result
+}
+
+// replayTurn sends the user message of turn and returns the last final text the agent produced.
+func (h *Harness) replayTurn(
+	ctx context.Context,
+	r *runner.Runner,
+	userID, sessionID string,
+	turn Turn,
+) (string, error) {
+	var answer string
+	for event, err := range r.Run(ctx, userID, sessionID, turn.User, agent.RunConfig{}) {
+		if err != nil {
+			return answer, err
+		}
+		if event == nil || event.Partial || event.Author == "user" {
+			continue
+		}
+		if text := contentText(event.Content); text != "" {
+			answer = text
+		}
+	}
+
+	return answer, nil
+}
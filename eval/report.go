@@ -0,0 +1,104 @@
+package eval
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// TurnResult is the outcome of replaying one Turn.
+type TurnResult struct {
+	Index    int              `json:"index"`
+	Question string           `json:"question"`
+	Expected string           `json:"expected"`
+	Actual   string           `json:"actual"`
+	Scores   map[string]Score `json:"scores"`
+	Error    string           `json:"error,omitempty"`
+}
+
+// CaseResult is the outcome of replaying one Case.
+type CaseResult struct {
+	ID    string       `json:"id"`
+	Turns []TurnResult `json:"turns"`
+	Error string       `json:"error,omitempty"`
+}
+
+// Report is the result of a Harness run.
+type Report struct {
+	StartedAt time.Time     `json:"startedAt"`
+	Duration  time.Duration `json:"duration"`
+
+	Cases []CaseResult `json:"cases"`
+
+	// Turns is the number of replayed turns; Failed counts those that errored.
+	Turns  int `json:"turns"`
+	Failed int `json:"failed"`
+
+	// MeanScores is the mean score of every scorer over the turns it scored successfully.
+	MeanScores map[string]float64 `json:"meanScores"`
+}
+
+// summarize fills the aggregate fields of r from its cases.
+func (r *Report) summarize() {
+	sums := make(map[string]float64)
+	counts := make(map[string]int)
+
+	for _, c := range r.Cases {
+		for _, t := range c.Turns {
+			r.Turns++
+			if t.Error != "" {
+				r.Failed++
+				continue
+			}
+
+			for name, s := range t.Scores {
+				if s.Error != "" {
+					continue
+				}
+				sums[name] += s.Value
+				counts[name]++
+			}
+		}
+	}
+
+	r.MeanScores = make(map[string]float64, len(sums))
+	for name, sum := range sums {
+		r.MeanScores[name] = sum / float64(counts[name])
+	}
+}
+
+// WriteJSON writes r as indented JSON to w.
+func (r *Report) WriteJSON(w io.Writer) error {
+	data, err := sonic.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// String returns a short human-readable summary of r.
+func (r *Report) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "cases=%d turns=%d failed=%d duration=%s\n",
+		len(r.Cases), r.Turns, r.Failed, r.Duration.Round(time.Millisecond))
+
+	names := make([]string, 0, len(r.MeanScores))
+	for name := range r.MeanScores {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		fmt.Fprintf(&sb, "  %-12s %.3f\n", name, r.MeanScores[name])
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,239 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+var (
+	_ Scorer = ExactMatch{}
+	_ Scorer = (*EmbeddingSimilarity)(nil)
+	_ Scorer = (*LLMJudge)(nil)
+
+	errEmptyEmbedding = errors.New("empty embedding")
+	errNoJudgeScore   = errors.New("judge response has no SCORE line")
+)
+
+// Sample is a single replayed turn handed to a Scorer.
+type Sample struct {
+	Question string
+	Expected string
+	Actual   string
+}
+
+// Score is the result of a Scorer for one Sample. Value is in [0, 1].
+type Score struct {
+	Value  float64 `json:"value"`
+	Reason string  `json:"reason,omitempty"`
+
+	// Error is set when the scorer failed; such scores are left out of the report means.
+	Error string `json:"error,omitempty"`
+}
+
+// Scorer compares a candidate answer with the recorded one.
+type Scorer interface {
+	Name() string
+	Score(ctx context.Context, s Sample) (Score, error)
+}
+
+// ExactMatch scores 1 if the answers are equal after trimming whitespace, 0 otherwise.
+type ExactMatch struct {
+	// IgnoreCase compares the answers case-insensitively.
+	IgnoreCase bool
+}
+
+// Name returns "exact".
+func (ExactMatch) Name() string { return "exact" }
+
+// Score compares s.Expected and s.Actual.
+func (m ExactMatch) Score(_ context.Context, s Sample) (Score, error) {
+	expected, actual := strings.TrimSpace(s.Expected), strings.TrimSpace(s.Actual)
+
+	equal := expected == actual
+	if m.IgnoreCase {
+		equal = strings.EqualFold(expected, actual)
+	}
+
+	if equal {
+		return Score{Value: 1}, nil
+	}
+	return Score{Value: 0}, nil
+}
+
+// Embedder generates embeddings from text. memory/postgres.EmbeddingModel
+// implementations, such as OpenAICompatibleEmbedding, satisfy it.
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+}
+
+// EmbeddingSimilarity scores the cosine similarity of the answers' embeddings,
+// clamped to [0, 1].
+type EmbeddingSimilarity struct {
+	Embedder Embedder
+}
+
+// NewEmbeddingSimilarity creates an EmbeddingSimilarity scorer backed by embedder.
+func NewEmbeddingSimilarity(embedder Embedder) *EmbeddingSimilarity {
+	return &EmbeddingSimilarity{Embedder: embedder}
+}
+
+// Name returns "embedding".
+func (*EmbeddingSimilarity) Name() string { return "embedding" }
+
+// Score embeds both answers and returns their cosine similarity.
+func (e *EmbeddingSimilarity) Score(ctx context.Context, s Sample) (Score, error) {
+	expected, err := e.Embedder.Embed(ctx, s.Expected)
+	if err != nil {
+		return Score{}, fmt.Errorf("failed to embed expected answer: %w", err)
+	}
+
+	actual, err := e.Embedder.Embed(ctx, s.Actual)
+	if err != nil {
+		return Score{}, fmt.Errorf("failed to embed actual answer: %w", err)
+	}
+
+	sim, err := cosineSimilarity(expected, actual)
+	if err != nil {
+		return Score{}, err
+	}
+
+	return Score{Value: math.Max(0, math.Min(1, sim))}, nil
+}
+
+func cosineSimilarity(a, b []float32) (float64, error) {
+	if len(a) == 0 || len(b) == 0 {
+		return 0, errEmptyEmbedding
+	}
+	if len(a) != len(b) {
+		return 0, fmt.Errorf("embedding dimension mismatch: %d != %d", len(a), len(b))
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0, nil
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB)), nil
+}
+
+// JudgePrompt is the default system instruction of LLMJudge. Like the critic of
+// the llm_auditor example, the judge identifies the claims of the candidate answer
+// and verifies each one, here against the reference answer instead of the web.
+//
+//nolint:lll
+const JudgePrompt = `
+You are a meticulous reviewer grading a candidate answer against a reference answer to the same question.
+
+# Your task
+
+First, identify every CLAIM presented in the candidate answer. Second, verify each CLAIM against the reference answer and assign one of the following verdicts:
+
+    * Accurate: The CLAIM is consistent with the reference answer.
+    * Inaccurate: The CLAIM contradicts the reference answer.
+    * Unsupported: The reference answer neither supports nor contradicts the CLAIM.
+    * Not Applicable: The CLAIM is a subjective opinion or a formality that does not require verification.
+
+Finally, consider whether the candidate answer covers everything the reference answer addresses, and give an OVERALL assessment.
+
+# Output format
+
+Output a short Markdown list with one line per CLAIM and its verdict, then a line "JUSTIFICATION: <one sentence>", and as the very last line "SCORE: <number between 0 and 1>", where 1 means the candidate is as good as the reference and 0 means it is wrong or unrelated.
+`
+
+// scoreLine matches the "SCORE: <n>" line of a judge response.
+var scoreLine = regexp.MustCompile(`(?im)^\s*\**SCORE\**\s*:\s*\**\s*([0-9]*\.?[0-9]+)`)
+
+// justificationLine matches the "JUSTIFICATION: <text>" line of a judge response.
+var justificationLine = regexp.MustCompile(`(?im)^\s*\**JUSTIFICATION\**\s*:\s*\**\s*(.+)$`)
+
+// LLMJudge asks an LLM to grade the candidate answer against the recorded one.
+type LLMJudge struct {
+	// Model is the judge LLM.
+	Model model.LLM
+
+	// Optional. Prompt overrides JudgePrompt. The response must end with a "SCORE: <n>" line.
+	Prompt string
+}
+
+// NewLLMJudge creates an LLMJudge scorer using llm with the default JudgePrompt.
+func NewLLMJudge(llm model.LLM) *LLMJudge {
+	return &LLMJudge{Model: llm}
+}
+
+// Name returns "llm_judge".
+func (*LLMJudge) Name() string { return "llm_judge" }
+
+// Score sends the question and both answers to the judge and parses its SCORE line.
+func (j *LLMJudge) Score(ctx context.Context, s Sample) (Score, error) {
+	prompt := j.Prompt
+	if prompt == "" {
+		prompt = JudgePrompt
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Question: ")
+	sb.WriteString(s.Question)
+	sb.WriteString("\n\nReference answer: ")
+	sb.WriteString(s.Expected)
+	sb.WriteString("\n\nCandidate answer: ")
+	sb.WriteString(s.Actual)
+
+	req := &model.LLMRequest{
+		Contents: []*genai.Content{
+			{
+				Role:  "user",
+				Parts: []*genai.Part{{Text: sb.String()}},
+			},
+		},
+		Config: &genai.GenerateContentConfig{
+			SystemInstruction: &genai.Content{
+				Parts: []*genai.Part{{Text: prompt}},
+			},
+		},
+	}
+
+	var out strings.Builder
+	for resp, err := range j.Model.GenerateContent(ctx, req, false) {
+		if err != nil {
+			return Score{}, fmt.Errorf("judge LLM call failed: %w", err)
+		}
+		if resp != nil {
+			out.WriteString(contentText(resp.Content))
+		}
+	}
+
+	return parseJudgeResponse(out.String())
+}
+
+// parseJudgeResponse extracts the last SCORE line and the justification of a judge response.
+func parseJudgeResponse(text string) (Score, error) {
+	matches := scoreLine.FindAllStringSubmatch(text, -1)
+	if len(matches) == 0 {
+		return Score{}, errNoJudgeScore
+	}
+
+	value, err := strconv.ParseFloat(matches[len(matches)-1][1], 64)
+	if err != nil {
+		return Score{}, fmt.Errorf("failed to parse judge score: %w", err)
+	}
+
+	score := Score{Value: math.Max(0, math.Min(1, value))}
+	if m := justificationLine.FindStringSubmatch(text); m != nil {
+		score.Reason = strings.TrimSpace(m[1])
+	}
+
+	return score, nil
+}
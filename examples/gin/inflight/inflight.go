@@ -0,0 +1,101 @@
+// Package inflight tracks the agent runs currently executing on the server so that
+// they can be interrupted from another request, and serializes runs on the same session.
+package inflight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrInterrupted is the cancellation cause of a run stopped through Interrupt.
+var ErrInterrupted = errors.New("run interrupted")
+
+var _ Registry = (*LocalRegistry)(nil)
+
+// Key identifies the session a run belongs to.
+type Key struct {
+	AppName   string `json:"appName"`
+	UserID    string `json:"userId"`
+	SessionID string `json:"sessionId"`
+}
+
+// String returns the key as "app:user:session".
+func (k Key) String() string {
+	return fmt.Sprintf("%s:%s:%s", k.AppName, k.UserID, k.SessionID)
+}
+
+// Registry tracks in-flight runs.
+type Registry interface {
+	// Register derives a cancellable context for a run of key. The returned release
+	// function must be called once the run completes.
+	Register(ctx context.Context, key Key) (runCtx context.Context, release func())
+
+	// Interrupt cancels the runs of key with ErrInterrupted. It reports whether
+	// a run was in flight.
+	Interrupt(ctx context.Context, key Key) (bool, error)
+}
+
+// LocalRegistry is a Registry for a single server instance.
+type LocalRegistry struct {
+	mu   sync.Mutex
+	runs map[Key]map[*run]struct{}
+}
+
+type run struct {
+	cancel context.CancelCauseFunc
+}
+
+// NewLocalRegistry creates an empty LocalRegistry.
+func NewLocalRegistry() *LocalRegistry {
+	return &LocalRegistry{runs: make(map[Key]map[*run]struct{})}
+}
+
+// Register implements Registry.
+func (r *LocalRegistry) Register(ctx context.Context, key Key) (context.Context, func()) {
+	runCtx, cancel := context.WithCancelCause(ctx)
+	entry := &run{cancel: cancel}
+
+	r.mu.Lock()
+	if r.runs[key] == nil {
+		r.runs[key] = make(map[*run]struct{})
+	}
+	r.runs[key][entry] = struct{}{}
+	r.mu.Unlock()
+
+	release := func() {
+		r.mu.Lock()
+		delete(r.runs[key], entry)
+		if len(r.runs[key]) == 0 {
+			delete(r.runs, key)
+		}
+		r.mu.Unlock()
+
+		cancel(nil)
+	}
+
+	return runCtx, release
+}
+
+// Interrupt implements Registry.
+func (r *LocalRegistry) Interrupt(_ context.Context, key Key) (bool, error) {
+	return r.cancel(key), nil
+}
+
+// cancel cancels the local runs of key and reports whether there were any.
+func (r *LocalRegistry) cancel(key Key) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	runs := r.runs[key]
+	for entry := range runs {
+		entry.cancel(ErrInterrupted)
+	}
+	return len(runs) > 0
+}
+
+// Interrupted reports whether ctx, returned by Register, was cancelled by Interrupt.
+func Interrupted(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrInterrupted)
+}
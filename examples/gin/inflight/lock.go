@@ -0,0 +1,197 @@
+package inflight
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/k-adk/internal/redisscript"
+	"github.com/kydenul/log"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultLockTTL           = 30 * time.Second
+	defaultLockWaitTimeout   = 30 * time.Second
+	defaultLockRetryInterval = 100 * time.Millisecond
+
+	// minLockTTL keeps the lock TTL at a whole number of milliseconds for PEXPIRE,
+	// with room for the refreshes every TTL/3.
+	minLockTTL = time.Second
+)
+
+// ErrSessionBusy is returned by SessionLocker.Acquire when another run holds the
+// session lock (and, in wait mode, did not release it within WaitTimeout).
+var ErrSessionBusy = errors.New("session has a run in progress")
+
+// releaseScript deletes the lock only if it is still held by the caller's token.
+var releaseScript = redisscript.New("inflight_release", `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// refreshScript extends the lock only if it is still held by the caller's token.
+var refreshScript = redisscript.New("inflight_refresh", `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// LockConfig configures a SessionLocker.
+type LockConfig struct {
+	// Optional. TTL of the lock. It is refreshed every TTL/3 while the run is alive,
+	// so it only bounds how long a crashed instance blocks the session. Default: 30s,
+	// raised to 1s if below.
+	TTL time.Duration
+
+	// Optional. Wait queues a concurrent run until the lock is free instead of
+	// failing immediately with ErrSessionBusy.
+	Wait bool
+
+	// Optional. WaitTimeout bounds how long a queued run waits. Default: 30s.
+	WaitTimeout time.Duration
+
+	// Optional. RetryInterval between lock attempts while waiting. Default: 100ms.
+	RetryInterval time.Duration
+
+	// Optional. Logger for logging. Falls back to `DiscardLog` if nil.
+	Logger log.Logger
+}
+
+// SessionLocker serializes runs on the same session across server instances with
+// a Redis lock.
+type SessionLocker struct {
+	rdb redis.UniversalClient
+	cfg LockConfig
+}
+
+// NewSessionLocker creates a SessionLocker with the specified configuration.
+func NewSessionLocker(rdb redis.UniversalClient, cfg LockConfig) *SessionLocker {
+	if cfg.Logger == nil {
+		cfg.Logger = discardlog.NewDiscardLog()
+	}
+
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultLockTTL
+	}
+	if cfg.TTL < minLockTTL {
+		cfg.Logger.Warnf("session lock TTL %s is below %s, using %s", cfg.TTL, minLockTTL, minLockTTL)
+		cfg.TTL = minLockTTL
+	}
+	if cfg.WaitTimeout <= 0 {
+		cfg.WaitTimeout = defaultLockWaitTimeout
+	}
+	if cfg.RetryInterval <= 0 {
+		cfg.RetryInterval = defaultLockRetryInterval
+	}
+
+	return &SessionLocker{rdb: rdb, cfg: cfg}
+}
+
+func buildLockKey(key Key) string {
+	return "lock:session:" + key.String()
+}
+
+// Acquire takes the lock of key. The returned release function must be called once
+// the run completes. It returns ErrSessionBusy if the session is locked by another run.
+func (l *SessionLocker) Acquire(ctx context.Context, key Key) (func(), error) {
+	lockKey := buildLockKey(key)
+	token, err := newLockToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := l.lock(ctx, lockKey, token); err != nil {
+		return nil, err
+	}
+
+	// Keep the lock alive while the run is in progress
+	keepaliveCtx, stop := context.WithCancel(context.WithoutCancel(ctx))
+	go l.keepalive(keepaliveCtx, lockKey, token)
+
+	release := func() {
+		stop()
+
+		if err := releaseScript.Run(
+			context.WithoutCancel(ctx), l.rdb, []string{lockKey}, token,
+		).Err(); err != nil {
+			l.cfg.Logger.Warnf("failed to release session lock: key=%s, err=%v", lockKey, err)
+		}
+	}
+
+	return release, nil
+}
+
+// lock tries to set the lock once, or until WaitTimeout in wait mode.
+func (l *SessionLocker) lock(ctx context.Context, lockKey, token string) error {
+	ok, err := l.rdb.SetNX(ctx, lockKey, token, l.cfg.TTL).Result()
+	if err != nil {
+		return fmt.Errorf("failed to acquire session lock: %w", err)
+	}
+	if ok {
+		return nil
+	}
+	if !l.cfg.Wait {
+		return ErrSessionBusy
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, l.cfg.WaitTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(l.cfg.RetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-waitCtx.Done():
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return ErrSessionBusy
+
+		case <-ticker.C:
+			ok, err := l.rdb.SetNX(waitCtx, lockKey, token, l.cfg.TTL).Result()
+			if err != nil && waitCtx.Err() == nil {
+				return fmt.Errorf("failed to acquire session lock: %w", err)
+			}
+			if ok {
+				return nil
+			}
+		}
+	}
+}
+
+// keepalive refreshes the lock TTL until ctx is cancelled.
+func (l *SessionLocker) keepalive(ctx context.Context, lockKey, token string) {
+	ticker := time.NewTicker(l.cfg.TTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			if err := refreshScript.Run(
+				ctx, l.rdb, []string{lockKey}, token, l.cfg.TTL.Milliseconds(),
+			).Err(); err != nil && ctx.Err() == nil {
+				l.cfg.Logger.Warnf("failed to refresh session lock: key=%s, err=%v", lockKey, err)
+			}
+		}
+	}
+}
+
+func newLockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate lock token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,160 @@
+package inflight
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bytedance/sonic"
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/log"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	interruptChannel = "inflight:interrupt"
+
+	// defaultRunTTL bounds how long a crashed instance can leave a run marked in
+	// flight. Live runs refresh it every TTL/3.
+	defaultRunTTL = 10 * time.Minute
+)
+
+var _ Registry = (*RedisRegistry)(nil)
+
+// RedisRegistry is a Registry shared by all server instances: runs are marked in
+// Redis, and interrupts are broadcast over Pub/Sub to the instance executing them.
+type RedisRegistry struct {
+	local  *LocalRegistry
+	rdb    redis.UniversalClient
+	ttl    time.Duration
+	logger log.Logger
+
+	pubsub *redis.PubSub
+}
+
+// NewRedisRegistry creates a RedisRegistry and subscribes to interrupt broadcasts.
+// ttl is how long a run stays marked after its instance stops refreshing it, e.g.
+// on a crash; if <= 0, 10 minutes is used. Call Close to unsubscribe.
+func NewRedisRegistry(
+	ctx context.Context,
+	rdb redis.UniversalClient,
+	ttl time.Duration,
+	logger log.Logger,
+) (*RedisRegistry, error) {
+	if ttl <= 0 {
+		ttl = defaultRunTTL
+	}
+	if logger == nil {
+		logger = discardlog.NewDiscardLog()
+	}
+
+	pubsub := rdb.Subscribe(ctx, interruptChannel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe to interrupts: %w", err)
+	}
+
+	r := &RedisRegistry{
+		local:  NewLocalRegistry(),
+		rdb:    rdb,
+		ttl:    ttl,
+		logger: logger,
+		pubsub: pubsub,
+	}
+	go r.listen()
+
+	return r, nil
+}
+
+func buildRunKey(key Key) string {
+	return "inflight:" + key.String()
+}
+
+// Register implements Registry.
+func (r *RedisRegistry) Register(ctx context.Context, key Key) (context.Context, func()) {
+	runCtx, releaseLocal := r.local.Register(ctx, key)
+
+	runKey := buildRunKey(key)
+	if err := r.rdb.Incr(ctx, runKey).Err(); err != nil {
+		r.logger.Warnf("failed to mark run in flight: key=%s, err=%v", runKey, err)
+	} else {
+		r.rdb.Expire(ctx, runKey, r.ttl)
+		go r.keepalive(runCtx, runKey)
+	}
+
+	release := func() {
+		releaseLocal()
+
+		// The request context may already be cancelled; unmark regardless.
+		cleanupCtx := context.WithoutCancel(ctx)
+		if n, err := r.rdb.Decr(cleanupCtx, runKey).Result(); err != nil {
+			r.logger.Warnf("failed to unmark run: key=%s, err=%v", runKey, err)
+		} else if n <= 0 {
+			r.rdb.Del(cleanupCtx, runKey)
+		}
+	}
+
+	return runCtx, release
+}
+
+// keepalive refreshes the TTL of runKey until ctx, the context of the run, is
+// done, so runs lasting longer than the TTL can still be interrupted.
+func (r *RedisRegistry) keepalive(ctx context.Context, runKey string) {
+	// NOTE: time.NewTicker panics on the zero period of a TTL below 3ns
+	ticker := time.NewTicker(max(r.ttl/3, time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			if err := r.rdb.Expire(ctx, runKey, r.ttl).Err(); err != nil && ctx.Err() == nil {
+				r.logger.Warnf("failed to refresh run: key=%s, err=%v", runKey, err)
+			}
+		}
+	}
+}
+
+// Interrupt implements Registry.
+func (r *RedisRegistry) Interrupt(ctx context.Context, key Key) (bool, error) {
+	n, err := r.rdb.Exists(ctx, buildRunKey(key)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to look up run: %w", err)
+	}
+	if n == 0 {
+		return false, nil
+	}
+
+	payload, err := sonic.Marshal(key)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal interrupt: %w", err)
+	}
+
+	if err := r.rdb.Publish(ctx, interruptChannel, payload).Err(); err != nil {
+		return false, fmt.Errorf("failed to broadcast interrupt: %w", err)
+	}
+
+	return true, nil
+}
+
+// listen cancels local runs for every interrupt broadcast until Close.
+func (r *RedisRegistry) listen() {
+	for msg := range r.pubsub.Channel() {
+		var key Key
+		if err := sonic.UnmarshalString(msg.Payload, &key); err != nil {
+			r.logger.Warnf("invalid interrupt payload: %q, err=%v", msg.Payload, err)
+			continue
+		}
+
+		if r.local.cancel(key) {
+			r.logger.Infof("run interrupted: key=%s", key)
+		}
+	}
+}
+
+// Close unsubscribes from interrupt broadcasts.
+func (r *RedisRegistry) Close() error {
+	return r.pubsub.Close()
+}
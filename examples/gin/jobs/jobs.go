@@ -0,0 +1,303 @@
+// Package jobs runs agent invocations in the background: requests are enqueued in
+// Redis, executed by a pool of workers, and their events are stored for polling.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/kydenul/k-adk/examples/gin/models"
+	"github.com/kydenul/k-adk/internal/redisscript"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// queueKey and processingKey share a hash tag, so BLMOVE works in a cluster.
+	// A job moves from the queue to the processing list when a worker takes it,
+	// and leaves it when the job finishes.
+	queueKey      = "jobs:{queue}"
+	processingKey = "jobs:{queue}:processing"
+
+	// leaseTTL is how long a taken job is leased to its worker, which refreshes
+	// the lease while it runs. A job whose lease expired is queued again.
+	leaseTTL = 30 * time.Second
+
+	defaultJobTTL = 24 * time.Hour
+
+	// waitPollInterval is the interval at which Wait checks a job for new events.
+	waitPollInterval = 250 * time.Millisecond
+)
+
+// Job statuses.
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// ErrJobNotFound is returned when a job does not exist or has expired.
+var ErrJobNotFound = errors.New("job not found")
+
+// Job is a background agent invocation.
+type Job struct {
+	ID         string                 `json:"id"`
+	Status     string                 `json:"status"`
+	Request    models.RunAgentRequest `json:"request"`
+	Error      string                 `json:"error,omitempty"`
+	EventCount int64                  `json:"eventCount"`
+	CreatedAt  time.Time              `json:"createdAt"`
+	StartedAt  *time.Time             `json:"startedAt,omitempty"`
+	FinishedAt *time.Time             `json:"finishedAt,omitempty"`
+}
+
+// Done reports whether the job has finished.
+func (j *Job) Done() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusFailed
+}
+
+// Store persists jobs and their events in Redis.
+type Store struct {
+	rdb redis.UniversalClient
+	ttl time.Duration
+}
+
+// NewStore creates a Store. Jobs and their events expire ttl after their last
+// update; if ttl is <= 0, 24 hours is used.
+func NewStore(rdb redis.UniversalClient, ttl time.Duration) *Store {
+	if ttl <= 0 {
+		ttl = defaultJobTTL
+	}
+	return &Store{rdb: rdb, ttl: ttl}
+}
+
+func buildJobKey(id string) string {
+	return "jobs:" + id
+}
+
+func buildJobEventsKey(id string) string {
+	return "jobs:" + id + ":events"
+}
+
+func buildJobLeaseKey(id string) string {
+	return "jobs:" + id + ":lease"
+}
+
+// Enqueue stores a new queued job for req and pushes it onto the queue.
+func (s *Store) Enqueue(ctx context.Context, req models.RunAgentRequest) (*Job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+
+	job := &Job{
+		ID:        id,
+		Status:    StatusQueued,
+		Request:   req,
+		CreatedAt: time.Now(),
+	}
+	if err := s.save(ctx, job); err != nil {
+		return nil, err
+	}
+
+	if err := s.rdb.LPush(ctx, queueKey, id).Err(); err != nil {
+		return nil, fmt.Errorf("failed to enqueue job: %w", err)
+	}
+
+	return job, nil
+}
+
+// Get returns the job with id.
+func (s *Store) Get(ctx context.Context, id string) (*Job, error) {
+	data, err := s.rdb.Get(ctx, buildJobKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+
+	var job Job
+	if err := sonic.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal job: %w", err)
+	}
+
+	return &job, nil
+}
+
+// Wait blocks until job id has more than after events or has finished, up to
+// timeout, and returns the job. It backs long-polling clients.
+func (s *Store) Wait(ctx context.Context, id string, after int64, timeout time.Duration) (*Job, error) {
+	deadline := time.Now().Add(timeout)
+
+	ticker := time.NewTicker(waitPollInterval)
+	defer ticker.Stop()
+
+	for {
+		job, err := s.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if job.EventCount > after || job.Done() || !time.Now().Before(deadline) {
+			return job, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Events returns the events of job id, starting at offset.
+func (s *Store) Events(ctx context.Context, id string, offset int64) ([]models.Event, error) {
+	items, err := s.rdb.LRange(ctx, buildJobEventsKey(id), offset, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job events: %w", err)
+	}
+
+	events := make([]models.Event, 0, len(items))
+	for _, item := range items {
+		var evt models.Event
+		if err := sonic.UnmarshalString(item, &evt); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal job event: %w", err)
+		}
+		events = append(events, evt)
+	}
+
+	return events, nil
+}
+
+// appendEvent stores evt and bumps the job's event count.
+func (s *Store) appendEvent(ctx context.Context, job *Job, evt models.Event) error {
+	data, err := sonic.Marshal(evt)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job event: %w", err)
+	}
+
+	eventsKey := buildJobEventsKey(job.ID)
+
+	pipe := s.rdb.TxPipeline()
+	pipe.RPush(ctx, eventsKey, data)
+	pipe.Expire(ctx, eventsKey, s.ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to append job event: %w", err)
+	}
+
+	job.EventCount++
+	return s.save(ctx, job)
+}
+
+func (s *Store) save(ctx context.Context, job *Job) error {
+	data, err := sonic.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job: %w", err)
+	}
+
+	if err := s.rdb.Set(ctx, buildJobKey(job.ID), data, s.ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save job: %w", err)
+	}
+	return nil
+}
+
+// dequeue blocks up to timeout for the next queued job ID, moves it to the
+// processing list and leases it. Call ack when the job is done.
+func (s *Store) dequeue(ctx context.Context, timeout time.Duration) (string, error) {
+	id, err := s.rdb.BLMove(ctx, queueKey, processingKey, "RIGHT", "LEFT", timeout).Result()
+	if err != nil {
+		return "", err
+	}
+
+	// NOTE: A job left unleased is queued again by requeueExpired
+	if err := s.lease(ctx, id); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// lease leases job id to its worker for leaseTTL.
+func (s *Store) lease(ctx context.Context, id string) error {
+	if err := s.rdb.Set(ctx, buildJobLeaseKey(id), 1, leaseTTL).Err(); err != nil {
+		return fmt.Errorf("failed to lease job: %w", err)
+	}
+	return nil
+}
+
+// ack removes job id, done, from the processing list.
+func (s *Store) ack(ctx context.Context, id string) error {
+	pipe := s.rdb.TxPipeline()
+	pipe.LRem(ctx, processingKey, 1, id)
+	pipe.Del(ctx, buildJobLeaseKey(id))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to ack job: %w", err)
+	}
+	return nil
+}
+
+// requeueScript moves a job from the processing list back to the queue, to be
+// taken next, unless another worker requeued it already.
+//
+// KEYS[1]: processing list
+// KEYS[2]: queue
+// ARGV[1]: job ID
+var requeueScript = redisscript.New("jobs_requeue", `
+if redis.call('LREM', KEYS[1], 1, ARGV[1]) == 1 then
+    redis.call('RPUSH', KEYS[2], ARGV[1])
+    return 1
+end
+return 0
+`)
+
+// requeueExpired queues again the jobs of the processing list whose worker died.
+// A job is requeued when it had no lease in this call and in the previous one,
+// whose unleased jobs are suspects: a job just taken is not leased yet. It
+// returns the suspects of the next call and the number of jobs requeued.
+func (s *Store) requeueExpired(ctx context.Context, suspects map[string]bool) (map[string]bool, int, error) {
+	ids, err := s.rdb.LRange(ctx, processingKey, 0, -1).Result()
+	if err != nil {
+		return suspects, 0, fmt.Errorf("failed to list processing jobs: %w", err)
+	}
+
+	pipe := s.rdb.Pipeline()
+	leased := make([]*redis.IntCmd, len(ids))
+	for i, id := range ids {
+		leased[i] = pipe.Exists(ctx, buildJobLeaseKey(id))
+	}
+	if len(ids) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return suspects, 0, fmt.Errorf("failed to check job leases: %w", err)
+		}
+	}
+
+	next := make(map[string]bool)
+	requeued := 0
+	for i, id := range ids {
+		switch {
+		case leased[i].Val() > 0:
+		case suspects[id]:
+			n, err := requeueScript.Run(ctx, s.rdb, []string{processingKey, queueKey}, id).Int()
+			if err != nil {
+				return next, requeued, fmt.Errorf("failed to requeue job %s: %w", id, err)
+			}
+			requeued += n
+		default:
+			next[id] = true
+		}
+	}
+
+	return next, requeued, nil
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate job ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
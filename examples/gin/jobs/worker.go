@@ -0,0 +1,208 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/kydenul/k-adk/examples/gin/models"
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/log"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultWorkers     = 4
+	defaultPollTimeout = 5 * time.Second
+)
+
+// RunFunc executes the agent for req, calling emit for every produced event.
+type RunFunc func(ctx context.Context, req *models.RunAgentRequest, emit func(models.Event) error) error
+
+// PoolConfig configures a worker Pool.
+type PoolConfig struct {
+	// Store holds the queue and the jobs.
+	Store *Store
+
+	// Run executes a job.
+	Run RunFunc
+
+	// Optional. Workers is the number of concurrent jobs. Default: 4.
+	Workers int
+
+	// Optional. Logger for logging. Falls back to `DiscardLog` if nil.
+	Logger log.Logger
+}
+
+// Pool executes queued jobs with a fixed number of workers.
+type Pool struct {
+	log.Logger
+
+	store   *Store
+	run     RunFunc
+	workers int
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewPool creates a worker Pool. Call Start to begin processing.
+func NewPool(cfg PoolConfig) *Pool {
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultWorkers
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = discardlog.NewDiscardLog()
+	}
+
+	return &Pool{
+		Logger:  cfg.Logger,
+		store:   cfg.Store,
+		run:     cfg.Run,
+		workers: cfg.Workers,
+	}
+}
+
+// Start launches the workers. They stop when ctx is cancelled or Stop is called.
+func (p *Pool) Start(ctx context.Context) {
+	ctx, p.cancel = context.WithCancel(ctx)
+
+	for range p.workers {
+		p.wg.Go(func() { p.work(ctx) })
+	}
+	p.wg.Go(func() { p.requeueExpired(ctx) })
+
+	p.Infof("job worker pool started: workers=%d", p.workers)
+}
+
+// Stop cancels the running jobs and waits for the workers to exit.
+func (p *Pool) Stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.wg.Wait()
+}
+
+func (p *Pool) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		id, err := p.store.dequeue(ctx, defaultPollTimeout)
+		if errors.Is(err, redis.Nil) || ctx.Err() != nil {
+			continue
+		}
+		if err != nil {
+			p.Warnf("failed to dequeue job: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		p.process(ctx, id)
+	}
+}
+
+// requeueExpired queues again, every leaseTTL, the jobs whose worker died
+// while running them, e.g. in a crashed instance.
+func (p *Pool) requeueExpired(ctx context.Context) {
+	ticker := time.NewTicker(leaseTTL)
+	defer ticker.Stop()
+
+	var suspects map[string]bool
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		next, requeued, err := p.store.requeueExpired(ctx, suspects)
+		if err != nil {
+			p.Warnf("failed to requeue expired jobs: %v", err)
+		}
+		if requeued > 0 {
+			p.Infof("requeued %d jobs of dead workers", requeued)
+		}
+		suspects = next
+	}
+}
+
+// process runs job id and records its outcome, then acks it. A job whose worker
+// dies before is run again, after the events it stored.
+func (p *Pool) process(ctx context.Context, id string) {
+	// Status updates must land even if the pool is shutting down
+	saveCtx := context.WithoutCancel(ctx)
+
+	job, err := p.store.Get(ctx, id)
+	if errors.Is(err, ErrJobNotFound) {
+		p.Warnf("dropping expired job: id=%s", id)
+		if err := p.store.ack(saveCtx, id); err != nil {
+			p.Warnf("failed to ack job: id=%s, err=%v", id, err)
+		}
+		return
+	}
+	if err != nil {
+		p.Warnf("failed to load job: id=%s, err=%v", id, err)
+		return
+	}
+
+	stopLease := p.keepLease(saveCtx, id)
+
+	started := time.Now()
+	job.Status = StatusRunning
+	job.StartedAt = &started
+	if err := p.store.save(saveCtx, job); err != nil {
+		p.Warnf("failed to mark job running: id=%s, err=%v", id, err)
+	}
+
+	runErr := p.run(ctx, &job.Request, func(evt models.Event) error {
+		return p.store.appendEvent(saveCtx, job, evt)
+	})
+
+	finished := time.Now()
+	job.FinishedAt = &finished
+	job.Status = StatusSucceeded
+	if runErr != nil {
+		job.Status = StatusFailed
+		job.Error = runErr.Error()
+	}
+
+	if err := p.store.save(saveCtx, job); err != nil {
+		p.Warnf("failed to save job result: id=%s, err=%v", id, err)
+	}
+
+	stopLease()
+	if err := p.store.ack(saveCtx, id); err != nil {
+		p.Warnf("failed to ack job: id=%s, err=%v", id, err)
+	}
+
+	p.Infof("job finished: id=%s, status=%s, events=%d, duration=%s",
+		id, job.Status, job.EventCount, finished.Sub(started))
+}
+
+// keepLease refreshes the lease of job id until the returned function is called.
+func (p *Pool) keepLease(ctx context.Context, id string) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(leaseTTL / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := p.store.lease(ctx, id); err != nil && ctx.Err() == nil {
+				p.Warnf("failed to refresh job lease: id=%s, err=%v", id, err)
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -4,27 +4,40 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/bytedance/sonic"
 	"github.com/gin-gonic/gin"
+	"github.com/kydenul/k-adk/examples/gin/inflight"
+	"github.com/kydenul/k-adk/examples/gin/jobs"
 	"github.com/kydenul/k-adk/examples/gin/middleware"
 	"github.com/kydenul/k-adk/examples/gin/models"
+	"github.com/kydenul/k-adk/examples/gin/stream"
+	"github.com/kydenul/k-adk/genai/registry"
+	genaitypes "github.com/kydenul/k-adk/genai/types"
 	kmem "github.com/kydenul/k-adk/memory/postgres"
+	"github.com/kydenul/k-adk/scheduler"
+	ksessbase "github.com/kydenul/k-adk/session"
 	pg "github.com/kydenul/k-adk/session/postgres"
 	ksess "github.com/kydenul/k-adk/session/redis"
+	"github.com/kydenul/k-adk/voice"
 	"github.com/kydenul/log"
 	"google.golang.org/genai"
 
 	"google.golang.org/adk/agent"
 	"google.golang.org/adk/agent/llmagent"
+	"google.golang.org/adk/artifact"
 	"google.golang.org/adk/memory"
+	"google.golang.org/adk/model"
 	"google.golang.org/adk/model/gemini"
+	"google.golang.org/adk/plugin"
 	"google.golang.org/adk/runner"
 	"google.golang.org/adk/session"
 	"google.golang.org/adk/tool"
@@ -34,6 +47,15 @@ import (
 const (
 	defaultAppName         = "gin_agent"
 	defaultRedisSessionTTL = 10 * time.Minute
+
+	// maxRedisSessionTTL caps the session TTLs requested in the initial state of a
+	// session, see ksess.SessionTTLStateKey.
+	maxRedisSessionTTL = 24 * time.Hour
+
+	// defaultLongPollWait and maxLongPollWait bound how long GET /jobs/:job_id holds
+	// a long-polling request.
+	defaultLongPollWait = 30 * time.Second
+	maxLongPollWait     = 60 * time.Second
 )
 
 var Logger log.Logger
@@ -62,23 +84,158 @@ type Server struct {
 	agentLoader    agent.Loader
 	memoryService  memory.Service
 	sessionService session.Service
+	pluginConfig   runner.PluginConfig
+	inflight       inflight.Registry
+	locker         *inflight.SessionLocker
+
+	// artifactService backs the artifacts API; nil disables it.
+	artifactService artifact.Service
+
+	// artifactCleanup deletes sessions with their artifacts; nil deletes the
+	// sessions only.
+	artifactCleanup *ksessbase.ArtifactCleanupService
+
+	// views filters the events of the sessions served by role; nil serves every
+	// event.
+	views *ksessbase.ViewService
+
+	// render controls how inline data is rendered in session responses.
+	render models.RenderOptions
+
+	// streamBuffer controls the buffering of /run_sse events for slow clients.
+	streamBuffer stream.BufferConfig
+
+	// jobStore backs /run_async; nil disables background runs.
+	jobStore *jobs.Store
+
+	// schedules backs the schedules API; nil disables scheduled runs.
+	schedules *scheduler.Store
+
+	// usage backs the Redis usage stats; nil disables them.
+	usage *ksess.UsageInspector
+
+	// eventSizes backs the event size stats; nil disables them.
+	eventSizes *ksess.EventSizeTracker
+
+	// models resolves the model overrides of generationConfig.model; nil disables
+	// them.
+	models *registry.Registry
+
+	// health backs the readiness report of /health; nil reports ok.
+	health *ksessbase.HealthReporter
+}
+
+// generationConfigKey is the context key of the per-request generation overrides.
+type generationConfigKey struct{}
+
+// withGenerationConfig attaches the request's generation overrides to ctx.
+func withGenerationConfig(ctx context.Context, cfg *models.GenerationConfig) context.Context {
+	if cfg == nil {
+		return ctx
+	}
+	if cfg.Model != "" {
+		ctx = registry.WithModel(ctx, cfg.Model)
+	}
+	if cfg.StreamCoalesce != nil {
+		ctx = genaitypes.WithCoalesce(ctx, cfg.StreamCoalesce.Config())
+	}
+	return context.WithValue(ctx, generationConfigKey{}, cfg)
+}
+
+// validateGenerationConfig checks the request's generation overrides, and that its
+// model override resolves in the model registry.
+func (s *Server) validateGenerationConfig(cfg *models.GenerationConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if cfg == nil || cfg.Model == "" {
+		return nil
+	}
+
+	if s.models == nil {
+		return errors.New("generationConfig.model is not enabled")
+	}
+	if _, err := s.models.Resolve(cfg.Model); err != nil {
+		return fmt.Errorf("generationConfig.model: %w", err)
+	}
+
+	return nil
+}
+
+// generationConfigPlugin returns a runner plugin that merges the overrides attached
+// by withGenerationConfig into every LLM request of the run.
+func generationConfigPlugin() runner.PluginConfig {
+	p, _ := plugin.New(plugin.Config{
+		Name: "generation_config",
+		BeforeModelCallback: llmagent.BeforeModelCallback(func(
+			ctx agent.CallbackContext,
+			req *model.LLMRequest,
+		) (*model.LLMResponse, error) {
+			cfg, ok := ctx.Value(generationConfigKey{}).(*models.GenerationConfig)
+			if !ok || req == nil {
+				return nil, nil
+			}
+
+			if req.Config == nil {
+				req.Config = &genai.GenerateContentConfig{}
+			}
+			cfg.ApplyTo(req.Config)
+
+			return nil, nil
+		}),
+	})
+
+	return runner.PluginConfig{Plugins: []*plugin.Plugin{p}}
 }
 
 // ============================================================================
 // Server implementation
 // ============================================================================
 
-// NewServer creates a new Server with the given agent loader and session service.
+// NewServer creates a new Server with the given agent loader, session service,
+// in-flight run registry and optional per-session run lock.
 func NewServer(
 	agentLoader agent.Loader,
 	sessSrv session.Service,
 	memSrv memory.Service,
+	runs inflight.Registry,
+	locker *inflight.SessionLocker,
 ) *Server {
+	if runs == nil {
+		runs = inflight.NewLocalRegistry()
+	}
+
 	return &Server{
 		agentLoader:    agentLoader,
 		memoryService:  memSrv,
 		sessionService: sessSrv,
+		pluginConfig:   generationConfigPlugin(),
+		inflight:       runs,
+		locker:         locker,
+	}
+}
+
+// lockSession takes the per-session run lock and writes an error response if it
+// cannot. The returned release function is nil if the response was written.
+func (s *Server) lockSession(c *gin.Context, key inflight.Key) func() {
+	if s.locker == nil {
+		return func() {}
+	}
+
+	release, err := s.locker.Acquire(c.Request.Context(), key)
+	if errors.Is(err, inflight.ErrSessionBusy) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return nil
+	}
+	if err != nil {
+		c.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": fmt.Sprintf("failed to lock session: %v", err)},
+		)
+		return nil
 	}
+
+	return release
 }
 
 // handleRun handles the /run endpoint (compatible with ADK REST API).
@@ -97,7 +254,12 @@ func (s *Server) handleRun(c *gin.Context) {
 		return
 	}
 
-	ctx := c.Request.Context()
+	if err := s.validateGenerationConfig(req.GenerationConfig); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := withGenerationConfig(c.Request.Context(), req.GenerationConfig)
 
 	// Validate session exists
 	_, err := s.sessionService.Get(ctx, &session.GetRequest{
@@ -122,10 +284,12 @@ func (s *Server) handleRun(c *gin.Context) {
 
 	// Create runner
 	r, err := runner.New(runner.Config{
-		AppName:        req.AppName,
-		Agent:          curAgent,
-		MemoryService:  s.memoryService,
-		SessionService: s.sessionService,
+		AppName:         req.AppName,
+		Agent:           curAgent,
+		MemoryService:   s.memoryService,
+		SessionService:  s.sessionService,
+		ArtifactService: s.artifactService,
+		PluginConfig:    s.pluginConfig,
 	})
 	if err != nil {
 		c.JSON(
@@ -141,11 +305,28 @@ func (s *Server) handleRun(c *gin.Context) {
 		streamingMode = agent.StreamingModeSSE
 	}
 
+	key := inflight.Key{AppName: req.AppName, UserID: req.UserID, SessionID: req.SessionID}
+
+	// Serialize runs on the same session
+	unlock := s.lockSession(c, key)
+	if unlock == nil {
+		return
+	}
+	defer unlock()
+
+	// Track the run so it can be interrupted
+	runCtx, release := s.inflight.Register(ctx, key)
+	defer release()
+
 	// Run and collect events
 	var events []models.Event
 	for event, err := range r.Run(
-		ctx, req.UserID, req.SessionID, &req.NewMessage, agent.RunConfig{StreamingMode: streamingMode}) {
+		runCtx, req.UserID, req.SessionID, &req.NewMessage, agent.RunConfig{StreamingMode: streamingMode}) {
 		if err != nil {
+			if inflight.Interrupted(runCtx) {
+				break
+			}
+
 			c.JSON(
 				http.StatusInternalServerError,
 				gin.H{"error": fmt.Sprintf("runner error: %v", err)},
@@ -161,10 +342,13 @@ func (s *Server) handleRun(c *gin.Context) {
 	c.JSON(http.StatusOK, events)
 }
 
-// handleRunSSE handles the /run_sse endpoint with Server-Sent Events.
+// handleRunSSE handles the /run_sse endpoint with Server-Sent Events, or the
+// transport negotiated by constrained clients (see stream.Negotiate).
 // POST /run_sse
 // Request: RunAgentRequest
-// Response: SSE stream of Event objects
+// Response: SSE stream of Event objects (default, Accept: text/event-stream),
+// NDJSON stream of Event objects (Accept: application/x-ndjson),
+// or 202 {"jobId", "cursor", "poll"} to long-poll (Prefer: respond-async)
 func (s *Server) handleRunSSE(c *gin.Context) {
 	var req models.RunAgentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -177,7 +361,12 @@ func (s *Server) handleRunSSE(c *gin.Context) {
 		return
 	}
 
-	ctx := c.Request.Context()
+	if err := s.validateGenerationConfig(req.GenerationConfig); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := withGenerationConfig(c.Request.Context(), req.GenerationConfig)
 
 	// Validate session exists
 	_, err := s.sessionService.Get(ctx, &session.GetRequest{
@@ -190,6 +379,18 @@ func (s *Server) handleRunSSE(c *gin.Context) {
 		return
 	}
 
+	transport := stream.Negotiate(c.Request)
+
+	// Long-poll: run in the background, the client polls /jobs/:job_id with a cursor
+	if transport == stream.TransportLongPoll {
+		if s.jobStore == nil {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": "background runs are not enabled"})
+			return
+		}
+		s.enqueueRun(c, req)
+		return
+	}
+
 	// Load agent
 	curAgent, err := s.agentLoader.LoadAgent(req.AppName)
 	if err != nil {
@@ -202,10 +403,12 @@ func (s *Server) handleRunSSE(c *gin.Context) {
 
 	// Create runner
 	r, err := runner.New(runner.Config{
-		AppName:        req.AppName,
-		Agent:          curAgent,
-		SessionService: s.sessionService,
-		MemoryService:  s.memoryService,
+		AppName:         req.AppName,
+		Agent:           curAgent,
+		SessionService:  s.sessionService,
+		MemoryService:   s.memoryService,
+		ArtifactService: s.artifactService,
+		PluginConfig:    s.pluginConfig,
 	})
 	if err != nil {
 		c.JSON(
@@ -215,31 +418,385 @@ func (s *Server) handleRunSSE(c *gin.Context) {
 		return
 	}
 
-	// Set SSE headers (same as built-in ADK)
-	c.Header("Content-Type", "text/event-stream")
-	c.Header("Cache-Control", "no-cache")
-	c.Header("Connection", "keep-alive")
-	c.Status(http.StatusOK)
+	key := inflight.Key{AppName: req.AppName, UserID: req.UserID, SessionID: req.SessionID}
+
+	// Serialize runs on the same session
+	unlock := s.lockSession(c, key)
+	if unlock == nil {
+		return
+	}
+	defer unlock()
+
+	// Set streaming headers (SSE same as built-in ADK)
+	w := stream.NewWriter(c.Writer, transport)
+	w.WriteHeader(http.StatusOK)
+
+	// Buffer the events so a slow client does not stall the run
+	bw := stream.NewBufferedWriter(w, s.streamBuffer)
+
+	// Track the run so it can be interrupted
+	runCtx, release := s.inflight.Register(ctx, key)
+	defer release()
 
 	// Run with streaming
 	for event, err := range r.Run(
-		ctx, req.UserID, req.SessionID, &req.NewMessage, agent.RunConfig{StreamingMode: agent.StreamingModeSSE}) {
+		runCtx, req.UserID, req.SessionID, &req.NewMessage, agent.RunConfig{StreamingMode: agent.StreamingModeSSE}) {
 		if err != nil {
-			_, _ = fmt.Fprintf(c.Writer, "Error while running agent: %v\n", err)
-			c.Writer.Flush()
+			if inflight.Interrupted(runCtx) {
+				_ = bw.WriteInterrupted()
+				break
+			}
+
+			_ = bw.WriteError(err)
 			continue
 		}
 
-		// Write SSE format: "data: {json}\n\n"
-		eventJSON, _ := sonic.Marshal(models.FromSessionEvent(event))
-		_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", eventJSON)
-		c.Writer.Flush()
+		_ = bw.WriteEvent(models.FromSessionEvent(event))
+	}
+
+	if err := bw.Close(); err != nil {
+		log.Warnf("stream of session %s ended early: %v", req.SessionID, err)
 	}
 
 	// Persist session to memory for cross-session search
 	s.addSessionToMemory(ctx, req.AppName, req.UserID, req.SessionID)
 }
 
+// handleRunAsync enqueues a background run and returns its job ID immediately.
+// POST /run_async
+// Request: RunAgentRequest
+// Response: 202 {"jobId": "...", "status": "queued"}
+func (s *Server) handleRunAsync(c *gin.Context) {
+	if s.jobStore == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "background runs are not enabled"})
+		return
+	}
+
+	var req models.RunAgentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.AppName == "" || req.UserID == "" || req.SessionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "appName, userId, and sessionId are required"})
+		return
+	}
+
+	if err := s.validateGenerationConfig(req.GenerationConfig); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	// Validate session exists
+	_, err := s.sessionService.Get(ctx, &session.GetRequest{
+		AppName:   req.AppName,
+		UserID:    req.UserID,
+		SessionID: req.SessionID,
+	})
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("session not found: %v", err)})
+		return
+	}
+
+	s.enqueueRun(c, req)
+}
+
+// enqueueRun enqueues a validated run as a background job and writes its ID and
+// the long-poll URL of its events.
+func (s *Server) enqueueRun(c *gin.Context, req models.RunAgentRequest) {
+	job, err := s.jobStore.Enqueue(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": fmt.Sprintf("failed to enqueue run: %v", err)},
+		)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"jobId":  job.ID,
+		"status": job.Status,
+		"cursor": 0,
+		"poll":   fmt.Sprintf("/jobs/%s?after=0&wait=%d", job.ID, int(defaultLongPollWait.Seconds())),
+	})
+}
+
+// handleGetJob returns the status of a background run and the events produced so far.
+// With wait, it long-polls: the response is held until events after the cursor exist
+// or the job finishes, up to wait seconds (at most 60).
+// GET /jobs/:job_id?after=N&wait=S
+// Response: Job with "events" (starting at index N) and "cursor" (the next N)
+func (s *Server) handleGetJob(c *gin.Context) {
+	if s.jobStore == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "background runs are not enabled"})
+		return
+	}
+
+	after, err := strconv.ParseInt(c.DefaultQuery("after", "0"), 10, 64)
+	if err != nil || after < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "after must be a non-negative integer"})
+		return
+	}
+
+	wait, err := strconv.Atoi(c.DefaultQuery("wait", "0"))
+	if err != nil || wait < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "wait must be a non-negative number of seconds"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	// Long-poll: hold the request until events after the cursor exist or the job ends
+	var job *jobs.Job
+	if wait > 0 {
+		timeout := min(time.Duration(wait)*time.Second, maxLongPollWait)
+		job, err = s.jobStore.Wait(ctx, c.Param("job_id"), after, timeout)
+	} else {
+		job, err = s.jobStore.Get(ctx, c.Param("job_id"))
+	}
+	if errors.Is(err, jobs.ErrJobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	events, err := s.jobStore.Events(ctx, job.ID, after)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":         job.ID,
+		"status":     job.Status,
+		"error":      job.Error,
+		"eventCount": job.EventCount,
+		"createdAt":  job.CreatedAt,
+		"startedAt":  job.StartedAt,
+		"finishedAt": job.FinishedAt,
+		"events":     events,
+		"cursor":     after + int64(len(events)),
+	})
+}
+
+// runJob executes a background run for the job worker pool. Like /run it takes the
+// session lock and registers the run, so it can be interrupted.
+func (s *Server) runJob(
+	ctx context.Context,
+	req *models.RunAgentRequest,
+	emit func(models.Event) error,
+) error {
+	ctx = withGenerationConfig(ctx, req.GenerationConfig)
+	key := inflight.Key{AppName: req.AppName, UserID: req.UserID, SessionID: req.SessionID}
+
+	curAgent, err := s.agentLoader.LoadAgent(req.AppName)
+	if err != nil {
+		return fmt.Errorf("failed to load agent: %w", err)
+	}
+
+	r, err := runner.New(runner.Config{
+		AppName:         req.AppName,
+		Agent:           curAgent,
+		SessionService:  s.sessionService,
+		MemoryService:   s.memoryService,
+		ArtifactService: s.artifactService,
+		PluginConfig:    s.pluginConfig,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create runner: %w", err)
+	}
+
+	if s.locker != nil {
+		unlock, err := s.locker.Acquire(ctx, key)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	runCtx, release := s.inflight.Register(ctx, key)
+	defer release()
+
+	for event, err := range r.Run(runCtx, req.UserID, req.SessionID, &req.NewMessage, agent.RunConfig{}) {
+		if err != nil {
+			if inflight.Interrupted(runCtx) {
+				break
+			}
+			return fmt.Errorf("runner error: %w", err)
+		}
+
+		if err := emit(models.FromSessionEvent(event)); err != nil {
+			Logger.Warnf("failed to store job event: %v", err)
+		}
+	}
+
+	// Persist session to memory for cross-session search
+	s.addSessionToMemory(context.WithoutCancel(ctx), req.AppName, req.UserID, req.SessionID)
+
+	return nil
+}
+
+// runScheduled executes a scheduled invocation like a background run, so that it
+// honors the session lock and can be interrupted.
+func (s *Server) runScheduled(ctx context.Context, inv *scheduler.Invocation) error {
+	req := &models.RunAgentRequest{
+		AppName:    inv.Schedule.AppName,
+		UserID:     inv.Schedule.UserID,
+		SessionID:  inv.SessionID,
+		NewMessage: *inv.Message,
+	}
+
+	return s.runJob(ctx, req, func(models.Event) error { return nil })
+}
+
+// handleListSchedules lists the recurring runs of a user.
+// GET /apps/:app_name/users/:user_id/schedules
+// Response: []Schedule
+func (s *Server) handleListSchedules(c *gin.Context) {
+	if s.schedules == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "scheduled runs are not enabled"})
+		return
+	}
+
+	schedules, err := s.schedules.List(c.Request.Context(), c.Param("app_name"), c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if schedules == nil {
+		schedules = []*scheduler.Schedule{}
+	}
+
+	c.JSON(http.StatusOK, schedules)
+}
+
+// handleCreateSchedule creates a recurring run.
+// POST /apps/:app_name/users/:user_id/schedules
+// Request: CreateScheduleRequest
+// Response: Schedule
+func (s *Server) handleCreateSchedule(c *gin.Context) {
+	if s.schedules == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "scheduled runs are not enabled"})
+		return
+	}
+
+	var req models.CreateScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+
+	sched := &scheduler.Schedule{
+		AppName:       c.Param("app_name"),
+		UserID:        c.Param("user_id"),
+		Cron:          req.Cron,
+		Message:       req.Message,
+		SessionPolicy: req.SessionPolicy,
+		SessionID:     req.SessionID,
+		Enabled:       req.Enabled == nil || *req.Enabled,
+	}
+	if sched.SessionPolicy == "" {
+		sched.SessionPolicy = scheduler.SessionPolicyNew
+	}
+
+	err := s.schedules.Create(c.Request.Context(), sched)
+	if errors.Is(err, scheduler.ErrInvalidSchedule) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, sched)
+}
+
+// handleUpdateSchedule enables or disables a recurring run.
+// PATCH /apps/:app_name/users/:user_id/schedules/:schedule_id
+// Request: UpdateScheduleRequest
+// Response: Schedule
+func (s *Server) handleUpdateSchedule(c *gin.Context) {
+	var req models.UpdateScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+
+	sched, ok := s.getOwnedSchedule(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	if err := s.schedules.SetEnabled(ctx, sched.ID, req.Enabled); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := s.schedules.Get(ctx, sched.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// handleDeleteSchedule deletes a recurring run.
+// DELETE /apps/:app_name/users/:user_id/schedules/:schedule_id
+func (s *Server) handleDeleteSchedule(c *gin.Context) {
+	sched, ok := s.getOwnedSchedule(c)
+	if !ok {
+		return
+	}
+
+	if err := s.schedules.Delete(c.Request.Context(), sched.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// getOwnedSchedule loads the schedule of the path and checks that it belongs to the
+// app and user of the path. It writes the error response and returns false otherwise.
+func (s *Server) getOwnedSchedule(c *gin.Context) (*scheduler.Schedule, bool) {
+	if s.schedules == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "scheduled runs are not enabled"})
+		return nil, false
+	}
+
+	id, err := strconv.ParseInt(c.Param("schedule_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "schedule_id must be an integer"})
+		return nil, false
+	}
+
+	sched, err := s.schedules.Get(c.Request.Context(), id)
+	if err == nil && (sched.AppName != c.Param("app_name") || sched.UserID != c.Param("user_id")) {
+		err = scheduler.ErrScheduleNotFound
+	}
+	if errors.Is(err, scheduler.ErrScheduleNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	return sched, true
+}
+
 // addSessionToMemory re-fetches the session (which now includes the latest events)
 // and persists it to the memory service for cross-session search.
 func (s *Server) addSessionToMemory(ctx context.Context, appName, userID, sessionID string) {
@@ -289,6 +846,10 @@ func (s *Server) handleCreateSession(c *gin.Context) {
 		SessionID: sessionID,
 		State:     req.State,
 	})
+	if errors.Is(err, ksessbase.ErrInvalidSessionID) || errors.Is(err, ksess.ErrInvalidTTL) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(
 			http.StatusInternalServerError,
@@ -312,7 +873,7 @@ func (s *Server) handleCreateSession(c *gin.Context) {
 		}
 	}
 
-	c.JSON(http.StatusOK, models.FromSession(resp.Session))
+	c.JSON(http.StatusOK, models.RenderSession(resp.Session, s.render))
 }
 
 // handleGetSession retrieves a specific session.
@@ -343,26 +904,253 @@ func (s *Server) handleGetSession(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, models.FromSession(resp.Session))
-}
-
-// handleListSessions lists all sessions for a user.
-// GET /apps/:app_name/users/:user_id/sessions
-func (s *Server) handleListSessions(c *gin.Context) {
-	appName := c.Param("app_name")
-	userID := c.Param("user_id")
-
-	if appName == "" || userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "app_name and user_id are required"})
+	sess := s.viewSession(c, resp.Session)
+	if sess == nil {
 		return
 	}
 
-	resp, err := s.sessionService.List(c.Request.Context(), &session.ListRequest{
-		AppName: appName,
-		UserID:  userID,
-	})
-	if err != nil {
-		c.JSON(
+	c.JSON(http.StatusOK, models.RenderSession(sess, s.render))
+}
+
+// viewContext attaches the view role the request was authenticated with, see
+// middleware.ViewRole, to its context.
+func viewContext(c *gin.Context) context.Context {
+	ctx := c.Request.Context()
+	if role := c.GetString(middleware.ViewRoleKey); role != "" {
+		ctx = ksessbase.WithViewRole(ctx, role)
+	}
+	return ctx
+}
+
+// viewSession returns the events of sess visible to the role of the request, and
+// writes an error response if the role has no view. The returned session is nil
+// if the response was written.
+func (s *Server) viewSession(c *gin.Context, sess session.Session) session.Session {
+	if s.views == nil {
+		return sess
+	}
+
+	viewed, err := s.views.View(viewContext(c), sess)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return nil
+	}
+	return viewed
+}
+
+// sessionSyncer is implemented by session services supporting delta sync.
+type sessionSyncer interface {
+	Sync(ctx context.Context, req *ksess.SyncRequest) (*ksess.SyncResponse, error)
+}
+
+// handleSyncSession returns the events and the state diff of a session since the
+// checkpoint of a client, so mobile clients resync cheaply after being backgrounded.
+// GET /apps/:app_name/users/:user_id/sessions/:session_id/sync?sinceEvent=N&sinceStateVersion=V
+// Response: SyncResponse, whose eventSequence and stateVersion are the next checkpoint
+func (s *Server) handleSyncSession(c *gin.Context) {
+	syncer, ok := s.sessionService.(sessionSyncer)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "session sync is not supported"})
+		return
+	}
+
+	sinceEvent, err := strconv.Atoi(c.DefaultQuery("sinceEvent", "0"))
+	if err != nil || sinceEvent < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sinceEvent must be a non-negative integer"})
+		return
+	}
+
+	sinceStateVersion, err := strconv.ParseInt(c.DefaultQuery("sinceStateVersion", "0"), 10, 64)
+	if err != nil || sinceStateVersion < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sinceStateVersion must be a non-negative integer"})
+		return
+	}
+
+	appName := c.Param("app_name")
+	userID := c.Param("user_id")
+	sessionID := c.Param("session_id")
+
+	resp, err := syncer.Sync(c.Request.Context(), &ksess.SyncRequest{
+		AppName:           appName,
+		UserID:            userID,
+		SessionID:         sessionID,
+		SinceEvent:        sinceEvent,
+		SinceStateVersion: sinceStateVersion,
+	})
+	if errors.Is(err, ksess.ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": fmt.Sprintf("failed to sync session: %v", err)},
+		)
+		return
+	}
+
+	visible := resp.Events
+	if s.views != nil {
+		visible, err = s.views.FilterEvents(viewContext(c), resp.Events)
+		if err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	events := make([]models.Event, 0, len(visible))
+	for _, e := range visible {
+		events = append(events, models.RenderSessionEvent(appName, userID, sessionID, e, s.render))
+	}
+
+	c.JSON(http.StatusOK, models.SyncResponse{
+		Events:        events,
+		EventSequence: resp.EventSequence,
+		EventsReset:   resp.EventsReset,
+		StateVersion:  resp.StateVersion,
+		FullState:     resp.FullState,
+		State:         resp.State,
+		StateSet:      resp.StateSet,
+		StateRemoved:  resp.StateRemoved,
+	})
+}
+
+// sessionTagger is implemented by session services supporting session tags.
+type sessionTagger interface {
+	SetTags(ctx context.Context, req *ksess.SetTagsRequest) (map[string]string, error)
+	Find(ctx context.Context, req *ksess.FindRequest) (*session.ListResponse, error)
+}
+
+// handleSetSessionTags sets and removes tags of a session.
+// PATCH /apps/:app_name/users/:user_id/sessions/:session_id/tags
+// Request: SetTagsRequest
+// Response: {"tags": {...}}, the tags of the session
+func (s *Server) handleSetSessionTags(c *gin.Context) {
+	tagger, ok := s.sessionService.(sessionTagger)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "session tags are not supported"})
+		return
+	}
+
+	var req models.SetTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+
+	tags, err := tagger.SetTags(c.Request.Context(), &ksess.SetTagsRequest{
+		AppName:   c.Param("app_name"),
+		UserID:    c.Param("user_id"),
+		SessionID: c.Param("session_id"),
+		Set:       req.Set,
+		Remove:    req.Remove,
+	})
+	switch {
+	case errors.Is(err, ksess.ErrSessionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+
+	case errors.Is(err, ksessbase.ErrInvalidTag):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+
+	case err != nil:
+		c.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": fmt.Sprintf("failed to set session tags: %v", err)},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tags": tags})
+}
+
+// sessionNotifier is implemented by session services publishing session changes.
+type sessionNotifier interface {
+	Subscribe(ctx context.Context, appName, userID string) (<-chan ksess.Notification, error)
+}
+
+// handleSessionNotifications streams the changes of the sessions of a user
+// (created, event appended, deleted) until the client disconnects, for dashboards
+// and live frontends.
+// GET /apps/:app_name/users/:user_id/notifications
+// Response: SSE stream of Notification objects, or NDJSON (Accept: application/x-ndjson)
+func (s *Server) handleSessionNotifications(c *gin.Context) {
+	notifier, ok := s.sessionService.(sessionNotifier)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "session notifications are not supported"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	notifications, err := notifier.Subscribe(ctx, c.Param("app_name"), c.Param("user_id"))
+	if err != nil {
+		c.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": fmt.Sprintf("failed to subscribe to notifications: %v", err)},
+		)
+		return
+	}
+
+	w := stream.NewWriter(c.Writer, stream.Negotiate(c.Request))
+	w.WriteHeader(http.StatusOK)
+
+	for n := range notifications {
+		if err := w.WriteEvent(n); err != nil {
+			Logger.Warnf("failed to write session notification: %v", err)
+			return
+		}
+	}
+}
+
+// handleListSessions lists all sessions for a user.
+// GET /apps/:app_name/users/:user_id/sessions?tag=key:value
+// Every tag query parameter keeps only the sessions having that tag.
+func (s *Server) handleListSessions(c *gin.Context) {
+	appName := c.Param("app_name")
+	userID := c.Param("user_id")
+
+	if appName == "" || userID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "app_name and user_id are required"})
+		return
+	}
+
+	var tags map[string]string
+	for _, tag := range c.QueryArray("tag") {
+		key, value, ok := strings.Cut(tag, ":")
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "tag must be key:value"})
+			return
+		}
+		if tags == nil {
+			tags = make(map[string]string)
+		}
+		tags[key] = value
+	}
+
+	var (
+		resp *session.ListResponse
+		err  error
+	)
+	if tags != nil {
+		tagger, ok := s.sessionService.(sessionTagger)
+		if !ok {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": "session tags are not supported"})
+			return
+		}
+		resp, err = tagger.Find(c.Request.Context(), &ksess.FindRequest{
+			AppName: appName,
+			UserID:  userID,
+			Tags:    tags,
+		})
+	} else {
+		resp, err = s.sessionService.List(c.Request.Context(), &session.ListRequest{
+			AppName: appName,
+			UserID:  userID,
+		})
+	}
+	if err != nil {
+		c.JSON(
 			http.StatusInternalServerError,
 			gin.H{"error": fmt.Sprintf("failed to list sessions: %v", err)},
 		)
@@ -371,7 +1159,10 @@ func (s *Server) handleListSessions(c *gin.Context) {
 
 	sessions := make([]models.Session, 0, len(resp.Sessions))
 	for _, sess := range resp.Sessions {
-		sessions = append(sessions, models.FromSession(sess))
+		if sess = s.viewSession(c, sess); sess == nil {
+			return
+		}
+		sessions = append(sessions, models.RenderSession(sess, s.render))
 	}
 
 	c.JSON(http.StatusOK, sessions)
@@ -392,7 +1183,12 @@ func (s *Server) handleDeleteSession(c *gin.Context) {
 		return
 	}
 
-	err := s.sessionService.Delete(c.Request.Context(), &session.DeleteRequest{
+	deleter := s.sessionService
+	if s.artifactCleanup != nil {
+		deleter = s.artifactCleanup
+	}
+
+	err := deleter.Delete(c.Request.Context(), &session.DeleteRequest{
 		AppName:   appName,
 		UserID:    userID,
 		SessionID: sessionID,
@@ -408,6 +1204,233 @@ func (s *Server) handleDeleteSession(c *gin.Context) {
 	c.JSON(http.StatusOK, nil)
 }
 
+// handleInterrupt cancels the run currently executing for a session.
+// POST /apps/:app_name/users/:user_id/sessions/:session_id/interrupt
+// Response: {"interrupted": true} or 404 if no run is in flight
+func (s *Server) handleInterrupt(c *gin.Context) {
+	key := inflight.Key{
+		AppName:   c.Param("app_name"),
+		UserID:    c.Param("user_id"),
+		SessionID: c.Param("session_id"),
+	}
+
+	interrupted, err := s.inflight.Interrupt(c.Request.Context(), key)
+	if err != nil {
+		c.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": fmt.Sprintf("failed to interrupt run: %v", err)},
+		)
+		return
+	}
+
+	if !interrupted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no run in flight for this session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"interrupted": true})
+}
+
+// handleSelectCandidate appends one candidate of a multi-candidate event to the
+// session as the canonical turn ("regenerate / pick the best").
+// POST /apps/:app_name/users/:user_id/sessions/:session_id/events/:event_id/select
+// Request: SelectCandidateRequest
+// Response: Event
+func (s *Server) handleSelectCandidate(c *gin.Context) {
+	appName := c.Param("app_name")
+	userID := c.Param("user_id")
+	sessionID := c.Param("session_id")
+	eventID := c.Param("event_id")
+
+	var req models.SelectCandidateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	resp, err := s.sessionService.Get(ctx, &session.GetRequest{
+		AppName:   appName,
+		UserID:    userID,
+		SessionID: sessionID,
+	})
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("session not found: %v", err)})
+		return
+	}
+
+	var source *session.Event
+	for e := range resp.Session.Events().All() {
+		if e.ID == eventID {
+			source = e
+			break
+		}
+	}
+	if source == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "event not found"})
+		return
+	}
+
+	var selected *genaitypes.Candidate
+	for _, cand := range genaitypes.CandidatesFromMetadata(source.CustomMetadata) {
+		if cand.Index == req.Index {
+			selected = &cand
+			break
+		}
+	}
+	if selected == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("candidate %d not found", req.Index)})
+		return
+	}
+
+	evt := session.NewEvent(source.InvocationID)
+	evt.Author = source.Author
+	evt.Branch = source.Branch
+	evt.Content = selected.Content
+	evt.FinishReason = selected.FinishReason
+	evt.TurnComplete = true
+	evt.CustomMetadata = map[string]any{
+		"selectedCandidate": map[string]any{"eventId": source.ID, "index": selected.Index},
+	}
+
+	if err := s.sessionService.AppendEvent(ctx, resp.Session, evt); err != nil {
+		c.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": fmt.Sprintf("failed to append event: %v", err)},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.FromSessionEvent(evt))
+}
+
+// handleListArtifacts lists the artifact names of a session.
+// GET /apps/:app_name/users/:user_id/sessions/:session_id/artifacts
+func (s *Server) handleListArtifacts(c *gin.Context) {
+	if s.artifactService == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "artifacts are not enabled"})
+		return
+	}
+
+	resp, err := s.artifactService.List(c.Request.Context(), &artifact.ListRequest{
+		AppName:   c.Param("app_name"),
+		UserID:    c.Param("user_id"),
+		SessionID: c.Param("session_id"),
+	})
+	if err != nil {
+		c.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": fmt.Sprintf("failed to list artifacts: %v", err)},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp.FileNames)
+}
+
+// handleGetArtifact loads an artifact version (latest by default).
+// GET /apps/:app_name/users/:user_id/sessions/:session_id/artifacts/:artifact_name?version=N
+// GET /apps/:app_name/users/:user_id/sessions/:session_id/artifacts/:artifact_name/raw?version=N
+// Response: the artifact part as JSON, or its raw data with its MIME type for /raw
+func (s *Server) handleGetArtifact(c *gin.Context) {
+	if s.artifactService == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "artifacts are not enabled"})
+		return
+	}
+
+	var version int64
+	if v := c.Query("version"); v != "" {
+		var err error
+		if version, err = strconv.ParseInt(v, 10, 64); err != nil || version < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "version must be a non-negative integer"})
+			return
+		}
+	}
+
+	resp, err := s.artifactService.Load(c.Request.Context(), &artifact.LoadRequest{
+		AppName:   c.Param("app_name"),
+		UserID:    c.Param("user_id"),
+		SessionID: c.Param("session_id"),
+		FileName:  c.Param("artifact_name"),
+		Version:   version,
+	})
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("artifact not found: %v", err)})
+		return
+	}
+
+	if strings.HasSuffix(c.FullPath(), "/raw") {
+		writePartData(c, resp.Part)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp.Part)
+}
+
+// handleGetEventPart serves the raw data of one content part of an event, the URL
+// given to inline data too large to be rendered in session responses.
+// GET /apps/:app_name/users/:user_id/sessions/:session_id/events/:event_id/parts/:part
+func (s *Server) handleGetEventPart(c *gin.Context) {
+	index, err := strconv.Atoi(c.Param("part"))
+	if err != nil || index < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "part must be a non-negative integer"})
+		return
+	}
+
+	resp, err := s.sessionService.Get(c.Request.Context(), &session.GetRequest{
+		AppName:   c.Param("app_name"),
+		UserID:    c.Param("user_id"),
+		SessionID: c.Param("session_id"),
+	})
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("session not found: %v", err)})
+		return
+	}
+
+	// NOTE: Part indexes are those of the view the session was served with
+	sess := s.viewSession(c, resp.Session)
+	if sess == nil {
+		return
+	}
+
+	eventID := c.Param("event_id")
+	for e := range sess.Events().All() {
+		if e.ID != eventID {
+			continue
+		}
+		if e.Content == nil || index >= len(e.Content.Parts) {
+			break
+		}
+		writePartData(c, e.Content.Parts[index])
+		return
+	}
+
+	c.JSON(http.StatusNotFound, gin.H{"error": "event part not found"})
+}
+
+// writePartData writes the data of a part with its MIME type: inline data as is,
+// text as text/plain, file data as a redirect to its URI.
+func writePartData(c *gin.Context, part *genai.Part) {
+	switch {
+	case part == nil:
+		c.JSON(http.StatusNotFound, gin.H{"error": "part has no data"})
+	case part.InlineData != nil:
+		mimeType := part.InlineData.MIMEType
+		if mimeType == "" {
+			mimeType = "application/octet-stream"
+		}
+		c.Header("Cache-Control", "private, max-age=86400")
+		c.Data(http.StatusOK, mimeType, part.InlineData.Data)
+	case part.FileData != nil:
+		c.Redirect(http.StatusFound, part.FileData.FileURI)
+	case part.Text != "":
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(part.Text))
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"error": "part has no data"})
+	}
+}
+
 // handleListApps lists all available apps/agents.
 // GET /list-apps
 func (s *Server) handleListApps(c *gin.Context) {
@@ -415,11 +1438,59 @@ func (s *Server) handleListApps(c *gin.Context) {
 	c.JSON(http.StatusOK, agents)
 }
 
-// handleHealth handles the /health endpoint.
+// handleRedisUsage returns the last report of the Redis usage inspector: the memory
+// used by the sessions of every app and user, and the top users.
+// GET /stats/redis-usage?top=N
+func (s *Server) handleRedisUsage(c *gin.Context) {
+	if s.usage == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "redis usage stats are not enabled"})
+		return
+	}
+
+	top, err := strconv.Atoi(c.DefaultQuery("top", "10"))
+	if err != nil || top < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "top must be a non-negative integer"})
+		return
+	}
+
+	report := s.usage.Stats()
+	if report == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "first inspection in progress"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"report": report, "topUsers": report.TopUsers(top)})
+}
+
+// handleEventSizes returns the histogram of the serialized sizes of the appended
+// events, and the number of sessions flagged for their event list size.
+// GET /stats/event-sizes
+func (s *Server) handleEventSizes(c *gin.Context) {
+	if s.eventSizes == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "event size stats are not enabled"})
+		return
+	}
+
+	c.JSON(http.StatusOK, s.eventSizes.Stats())
+}
+
+// handleHealth handles the /health endpoint: 200 with the report if every
+// backend is ready, 503 otherwise.
 func (s *Server) handleHealth(c *gin.Context) {
 	log.Debugf("Health check: %v", c.Request.Form)
 
-	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	if s.health == nil {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+		return
+	}
+
+	report := s.health.Ready(c.Request.Context())
+	if !report.Healthy() {
+		c.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
 }
 
 // ============================================================================
@@ -443,6 +1514,43 @@ func getWeather(_ tool.Context, input WeatherInput) (WeatherOutput, error) {
 	}, nil
 }
 
+// newModelRegistry builds the model registry from the MODEL_REGISTRY config file,
+// for the APP_ENV environment (default "production"). Without a config file, the
+// "fast", "smart" and "cheap" models are all Gemini 2.5 Flash.
+func newModelRegistry(ctx context.Context) (*registry.Registry, error) {
+	if path := os.Getenv("MODEL_REGISTRY"); path != "" {
+		cfg, err := registry.LoadConfig(path)
+		if err != nil {
+			return nil, err
+		}
+
+		env := os.Getenv("APP_ENV")
+		if env == "" {
+			env = "production"
+		}
+		return cfg.Build(ctx, env, registry.BuildOptions{Logger: Logger})
+	}
+
+	flash, err := gemini.NewModel(ctx, "gemini-2.5-flash", &genai.ClientConfig{
+		APIKey: os.Getenv("GOOGLE_API_KEY"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	reg := registry.New()
+	if err := reg.Register("gemini-2.5-flash", flash); err != nil {
+		return nil, err
+	}
+	for _, alias := range []string{"fast", "smart", "cheap"} {
+		if err := reg.Alias(alias, "gemini-2.5-flash"); err != nil {
+			return nil, err
+		}
+	}
+
+	return reg, nil
+}
+
 // ============================================================================
 // Main
 // ============================================================================
@@ -452,12 +1560,11 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 
-	// Create Gemini model
-	model, err := gemini.NewModel(ctx, "gemini-2.5-flash", &genai.ClientConfig{
-		APIKey: os.Getenv("GOOGLE_API_KEY"),
-	})
+	// Create model registry: the agent uses the logical model "fast", which
+	// requests can override with generationConfig.model
+	modelRegistry, err := newModelRegistry(ctx)
 	if err != nil {
-		log.Fatalf("Failed to create model: %v", err)
+		log.Fatalf("Failed to create model registry: %v", err)
 	}
 
 	// Create weather tool
@@ -488,17 +1595,31 @@ func main() {
 	}
 
 	// Create Redis client
-	rdb, err := ksess.NewRedisClient(ksess.LoadRedisConfigFromFile(
-		filepath.Join(".", "config.yaml"), "Production"))
+	redisConfig, err := ksess.LoadRedisConfig(filepath.Join(".", "config.yaml"), "Production")
+	if err != nil {
+		log.Fatalf("Failed to load redis config: %v", err)
+	}
+	rdb, err := ksess.NewRedisClient(redisConfig)
 	if err != nil {
 		log.Fatalf("Failed to create redis client: %v", err)
 	}
 
 	// Create Redis session service
+	// Record the event sizes and flag the sessions whose event list grows too large
+	eventSizes, err := ksess.NewEventSizeTracker(ksess.EventSizeConfig{Logger: Logger})
+	if err != nil {
+		log.Fatalf("Failed to create event size tracker: %v", err)
+	}
+
 	sessSrv, err := ksess.NewRedisSessionService(rdb,
 		ksess.WithTTL(defaultRedisSessionTTL),
+		ksess.WithMaxSessionTTL(maxRedisSessionTTL),
 		ksess.WithLogger(Logger),
-		ksess.WithPersister(pgPersister))
+		ksess.WithPersister(pgPersister),
+		ksess.WithLoader(pgPersister),
+		ksess.WithEventSizeTracker(eventSizes),
+		ksess.WithNotifications(),
+		ksess.WithIDPolicy(ksessbase.IDPolicy{}))
 	if err != nil {
 		log.Fatalf("Failed to create session service: %v", err)
 	}
@@ -516,7 +1637,7 @@ func main() {
 	// Create LLMAgent
 	a, err := llmagent.New(llmagent.Config{
 		Name:        defaultAppName,
-		Model:       model,
+		Model:       modelRegistry.LLM("fast"),
 		Description: "A helpful assistant powered by Gin and ADK.",
 		Instruction: `You are a helpful assistant. You can help users with various tasks.
 When asked about weather, use the get_weather tool to get current weather information.
@@ -530,8 +1651,138 @@ Always be polite and helpful.`,
 	// Create agent loader
 	agentLoader := agent.NewSingleLoader(a)
 
+	// Create in-flight run registry (shared across instances through Redis)
+	runs, err := inflight.NewRedisRegistry(ctx, rdb, 0, Logger)
+	if err != nil {
+		log.Fatalf("Failed to create in-flight run registry: %v", err)
+	}
+	defer func() { _ = runs.Close() }()
+
+	// Create per-session run lock: concurrent runs on a session get 409 Conflict,
+	// or wait for the running one when RUN_LOCK_WAIT=true
+	locker := inflight.NewSessionLocker(rdb, inflight.LockConfig{
+		Wait:   os.Getenv("RUN_LOCK_WAIT") == "true",
+		Logger: Logger,
+	})
+
 	// Create server
-	server := NewServer(agentLoader, sessSrv, memSrv)
+	server := NewServer(agentLoader, sessSrv, memSrv, runs, locker)
+	server.eventSizes = eventSizes
+	server.models = modelRegistry
+
+	// Report Redis, the PostgreSQL pool and the async queue of the persister at
+	// /health, for readiness probes
+	server.health, err = ksessbase.NewHealthReporter(
+		ksessbase.WithHealthCheck("redis", sessSrv),
+		ksessbase.WithHealthCheck("postgres", pgPersister),
+		ksessbase.WithHealthLogger(Logger))
+	if err != nil {
+		log.Fatalf("Failed to create health reporter: %v", err)
+	}
+
+	// Enable artifacts: runs save them in memory, and session responses replace
+	// inline data larger than 32 KiB by artifact or event part URLs
+	server.artifactService = artifact.InMemoryService()
+	server.render = models.RenderOptions{MaxInlineBytes: models.DefaultMaxInlineBytes}
+
+	// Delete the artifacts of a session along with it
+	server.artifactCleanup, err = ksessbase.WithArtifactCleanup(sessSrv, server.artifactService,
+		ksessbase.ArtifactCleanupConfig{Logger: Logger})
+	if err != nil {
+		log.Fatalf("Failed to create artifact cleanup: %v", err)
+	}
+
+	// Serve end users the conversation only: tool calls, thoughts and state changes
+	// are hidden unless the request is authenticated with the admin token
+	endUserView := ksessbase.ChainViews(ksessbase.HideToolCalls, ksessbase.HideThoughts, ksessbase.HideStateDelta)
+	server.views, err = ksessbase.WithViews(sessSrv, ksessbase.ViewConfig{
+		Roles:   map[string]ksessbase.ViewFilter{"admin": nil, "user": endUserView},
+		Default: endUserView,
+		Logger:  Logger,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create session views: %v", err)
+	}
+
+	// Buffer /run_sse events: a slow client gets coalesced text deltas and loses
+	// intermediate partials, and is disconnected after 30s without reading
+	server.streamBuffer = stream.BufferConfig{
+		DropPartials: true,
+		CoalesceText: true,
+		Logger:       Logger,
+	}
+
+	// Enable background runs: /run_async enqueues in Redis, a worker pool executes
+	server.jobStore = jobs.NewStore(rdb, 0)
+	workers := jobs.NewPool(jobs.PoolConfig{
+		Store:  server.jobStore,
+		Run:    server.runJob,
+		Logger: Logger,
+	})
+	workers.Start(ctx)
+	defer workers.Stop()
+
+	// Enable scheduled runs: cron schedules live in PostgreSQL, every occurrence is
+	// claimed through Redis so only one instance fires it
+	server.schedules, err = scheduler.NewStore(ctx, pgClient.DB())
+	if err != nil {
+		log.Fatalf("Failed to create schedule store: %v", err)
+	}
+	sched, err := scheduler.New(scheduler.Config{
+		Store:          server.schedules,
+		Redis:          rdb,
+		SessionService: sessSrv,
+		Run:            server.runScheduled,
+		Logger:         Logger,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create scheduler: %v", err)
+	}
+	sched.Start(ctx)
+	defer sched.Stop()
+
+	// Enable the voice bridge when a speech API is configured: audio over WebSocket,
+	// transcribed, run through the agent and answered with synthesized speech
+	var voiceHandler http.Handler
+	if voiceURL := os.Getenv("VOICE_BASE_URL"); voiceURL != "" {
+		voiceRunner, err := runner.New(runner.Config{
+			AppName:         defaultAppName,
+			Agent:           a,
+			SessionService:  sessSrv,
+			MemoryService:   memSrv,
+			ArtifactService: server.artifactService,
+			PluginConfig:    server.pluginConfig,
+		})
+		if err != nil {
+			log.Fatalf("Failed to create voice runner: %v", err)
+		}
+
+		speech := voice.OpenAIConfig{BaseURL: voiceURL, APIKey: os.Getenv("VOICE_API_KEY"), Logger: Logger}
+		stt, tts := speech, speech
+		stt.Model, tts.Model = "whisper-1", "tts-1"
+
+		bridge, err := voice.New(voice.Config{
+			AppName:   defaultAppName,
+			Run:       voice.RunnerFunc(voiceRunner, agent.RunConfig{}),
+			STT:       voice.NewOpenAISpeechToText(stt),
+			TTS:       voice.NewOpenAITextToSpeech(tts),
+			Artifacts: server.artifactService,
+			Logger:    Logger,
+		})
+		if err != nil {
+			log.Fatalf("Failed to create voice bridge: %v", err)
+		}
+		voiceHandler = voice.NewHandler(bridge, voice.HandlerConfig{})
+	}
+
+	// Enable Redis usage stats: session keys are scanned at a limited rate and
+	// their memory aggregated per app and user
+	server.usage, err = ksess.NewUsageInspector(rdb, ksess.UsageConfig{Logger: Logger})
+	if err != nil {
+		log.Fatalf("Failed to create redis usage inspector: %v", err)
+	}
+	server.usage.Start(ctx)
+	defer server.usage.Stop()
 
 	// Setup Gin router
 	r := gin.Default()
@@ -541,6 +1792,7 @@ Always be polite and helpful.`,
 		middleware.Recovery(),
 		middleware.Logger(),
 		middleware.CROS(),
+		middleware.ViewRole(os.Getenv("ADMIN_API_TOKEN")),
 	)
 
 	// ========================================================================
@@ -549,18 +1801,24 @@ Always be polite and helpful.`,
 
 	// Health check
 	r.GET("/health", server.handleHealth)
+	r.GET("/stats/redis-usage", server.handleRedisUsage)
+	r.GET("/stats/event-sizes", server.handleEventSizes)
 
 	// Runtime API
 	r.POST("/run", server.handleRun)
 	r.OPTIONS("/run", func(c *gin.Context) { c.Status(http.StatusNoContent) })
 	r.POST("/run_sse", server.handleRunSSE)
 	r.OPTIONS("/run_sse", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	r.POST("/run_async", server.handleRunAsync)
+	r.OPTIONS("/run_async", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	r.GET("/jobs/:job_id", server.handleGetJob)
 
 	// Apps API
 	r.GET("/list-apps", server.handleListApps)
 
 	// Sessions API
 	r.GET("/apps/:app_name/users/:user_id/sessions", server.handleListSessions)
+	r.GET("/apps/:app_name/users/:user_id/notifications", server.handleSessionNotifications)
 	r.POST("/apps/:app_name/users/:user_id/sessions", server.handleCreateSession)
 	r.GET("/apps/:app_name/users/:user_id/sessions/:session_id", server.handleGetSession)
 	r.POST("/apps/:app_name/users/:user_id/sessions/:session_id", server.handleCreateSession)
@@ -569,6 +1827,39 @@ Always be polite and helpful.`,
 		"/apps/:app_name/users/:user_id/sessions/:session_id",
 		func(c *gin.Context) { c.Status(http.StatusNoContent) },
 	)
+	r.GET("/apps/:app_name/users/:user_id/sessions/:session_id/sync", server.handleSyncSession)
+	r.PATCH("/apps/:app_name/users/:user_id/sessions/:session_id/tags", server.handleSetSessionTags)
+	r.POST("/apps/:app_name/users/:user_id/sessions/:session_id/interrupt", server.handleInterrupt)
+	r.POST(
+		"/apps/:app_name/users/:user_id/sessions/:session_id/events/:event_id/select",
+		server.handleSelectCandidate,
+	)
+
+	// Artifacts API
+	r.GET("/apps/:app_name/users/:user_id/sessions/:session_id/artifacts", server.handleListArtifacts)
+	r.GET(
+		"/apps/:app_name/users/:user_id/sessions/:session_id/artifacts/:artifact_name",
+		server.handleGetArtifact,
+	)
+	r.GET(
+		"/apps/:app_name/users/:user_id/sessions/:session_id/artifacts/:artifact_name/raw",
+		server.handleGetArtifact,
+	)
+	r.GET(
+		"/apps/:app_name/users/:user_id/sessions/:session_id/events/:event_id/parts/:part",
+		server.handleGetEventPart,
+	)
+
+	// Voice API
+	if voiceHandler != nil {
+		r.GET("/voice", gin.WrapH(voiceHandler))
+	}
+
+	// Schedules API
+	r.GET("/apps/:app_name/users/:user_id/schedules", server.handleListSchedules)
+	r.POST("/apps/:app_name/users/:user_id/schedules", server.handleCreateSchedule)
+	r.PATCH("/apps/:app_name/users/:user_id/schedules/:schedule_id", server.handleUpdateSchedule)
+	r.DELETE("/apps/:app_name/users/:user_id/schedules/:schedule_id", server.handleDeleteSchedule)
 
 	// Start server
 	port := os.Getenv("PORT")
@@ -592,11 +1883,19 @@ Always be polite and helpful.`,
 		log.Infof("  GET    /list-apps")
 		log.Infof("  POST   /run")
 		log.Infof("  POST   /run_sse")
+		log.Infof("  POST   /run_async")
+		log.Infof("  GET    /jobs/:job_id")
 		log.Infof("  GET    /apps/:app_name/users/:user_id/sessions")
 		log.Infof("  POST   /apps/:app_name/users/:user_id/sessions")
 		log.Infof("  GET    /apps/:app_name/users/:user_id/sessions/:session_id")
 		log.Infof("  POST   /apps/:app_name/users/:user_id/sessions/:session_id")
 		log.Infof("  DELETE /apps/:app_name/users/:user_id/sessions/:session_id")
+		log.Infof("  POST   /apps/:app_name/users/:user_id/sessions/:session_id/interrupt")
+		log.Infof("  POST   /apps/:app_name/users/:user_id/sessions/:session_id/events/:event_id/select")
+		log.Infof("  GET    /apps/:app_name/users/:user_id/schedules")
+		log.Infof("  POST   /apps/:app_name/users/:user_id/schedules")
+		log.Infof("  PATCH  /apps/:app_name/users/:user_id/schedules/:schedule_id")
+		log.Infof("  DELETE /apps/:app_name/users/:user_id/schedules/:schedule_id")
 
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Failed to start server: %v", err)
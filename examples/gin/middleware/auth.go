@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ViewRoleKey is the key of the view role of an authenticated request in the gin
+// context.
+const ViewRoleKey = "view_role"
+
+// AdminRole is the view role of the requests authenticated with the admin token.
+const AdminRole = "admin"
+
+// ViewRole authenticates the requests carrying "Authorization: Bearer <token>" with
+// adminToken, and sets their view role to AdminRole under ViewRoleKey. Other
+// requests get no role, and the default view. An empty adminToken authenticates no
+// request.
+//
+// NOTE: The role never comes from the client: in production, set it from the
+// identity your gateway or auth provider verified
+func ViewRole(adminToken string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if ok && adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1 {
+			c.Set(ViewRoleKey, AdminRole)
+		}
+
+		c.Next()
+	}
+}
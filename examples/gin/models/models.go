@@ -1,9 +1,13 @@
 package models
 
 import (
+	"errors"
+	"fmt"
 	"maps"
 	"time"
 
+	genaitypes "github.com/kydenul/k-adk/genai/types"
+	ksess "github.com/kydenul/k-adk/session"
 	"google.golang.org/adk/model"
 	"google.golang.org/adk/session"
 	"google.golang.org/genai"
@@ -13,6 +17,12 @@ import (
 // Request/Response models
 // ============================================================================
 
+// maxCandidateCount caps generationConfig.candidateCount.
+const maxCandidateCount = 8
+
+// maxCoalesceWindowMs caps generationConfig.streamCoalesce.windowMs.
+const maxCoalesceWindowMs = 5000
+
 // RunAgentRequest
 type RunAgentRequest struct {
 	AppName    string         `json:"appName"`
@@ -21,6 +31,49 @@ type RunAgentRequest struct {
 	NewMessage genai.Content  `json:"newMessage"`
 	Streaming  bool           `json:"streaming,omitempty"`
 	StateDelta map[string]any `json:"stateDelta,omitempty"`
+
+	// GenerationConfig overrides the agent's generation settings for this call only.
+	GenerationConfig *GenerationConfig `json:"generationConfig,omitempty"`
+}
+
+// GenerationConfig holds per-request generation overrides. Unset fields keep the
+// agent's own configuration.
+type GenerationConfig struct {
+	// Model is a model name or alias of the server's model registry, e.g. "smart",
+	// used instead of the agent's model.
+	Model string `json:"model,omitempty"`
+
+	Temperature     *float32 `json:"temperature,omitempty"`
+	TopP            *float32 `json:"topP,omitempty"`
+	MaxOutputTokens *int32   `json:"maxOutputTokens,omitempty"`
+
+	// ThinkingLevel is one of "LOW" or "HIGH" (genai.ThinkingLevel).
+	ThinkingLevel *genai.ThinkingLevel `json:"thinkingLevel,omitempty"`
+
+	// ThinkingBudget is the thinking token budget, for models that take one.
+	ThinkingBudget *int32 `json:"thinkingBudget,omitempty"`
+
+	// CandidateCount requests that many alternative responses, for models that support it.
+	CandidateCount *int32 `json:"candidateCount,omitempty"`
+
+	// StreamCoalesce merges the streamed text deltas into fewer, larger partial
+	// events, for the OpenAI and Anthropic adapters.
+	StreamCoalesce *StreamCoalesce `json:"streamCoalesce,omitempty"`
+}
+
+// StreamCoalesce holds deltas for up to WindowMs milliseconds or until they reach
+// MaxBytes; zero values disable a bound, and both zero disable coalescing.
+type StreamCoalesce struct {
+	WindowMs int `json:"windowMs,omitempty"`
+	MaxBytes int `json:"maxBytes,omitempty"`
+}
+
+// Config returns the adapters' coalescing config.
+func (c *StreamCoalesce) Config() genaitypes.CoalesceConfig {
+	return genaitypes.CoalesceConfig{
+		Window:   time.Duration(c.WindowMs) * time.Millisecond,
+		MaxBytes: c.MaxBytes,
+	}
 }
 
 // Event
@@ -39,6 +92,13 @@ type Event struct {
 	ErrorCode          string                   `json:"errorCode"`
 	ErrorMessage       string                   `json:"errorMessage"`
 	Actions            EventActions             `json:"actions"`
+
+	// Candidates holds all alternative responses (content is candidate 0) when the
+	// model was asked for more than one (generationConfig.candidateCount > 1).
+	Candidates []genaitypes.Candidate `json:"candidates,omitempty"`
+
+	// Attachments summarizes the non-text parts of content; set by RenderSessionEvent.
+	Attachments []Attachment `json:"attachments,omitempty"`
 }
 
 // EventActions represents actions performed during an event.
@@ -56,6 +116,48 @@ type Session struct {
 	UpdatedAt int64          `json:"lastUpdateTime"`
 	Events    []Event        `json:"events"`
 	State     map[string]any `json:"state"`
+
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// SetTagsRequest is the request body for changing the tags of a session: set adds
+// or overwrites tags, remove drops them by key.
+type SetTagsRequest struct {
+	Set    map[string]string `json:"set,omitempty"`
+	Remove []string          `json:"remove,omitempty"`
+}
+
+// SyncResponse is the delta of a session since a client checkpoint: the new events
+// and either the state diff or, when fullState is true, the whole state.
+type SyncResponse struct {
+	Events        []Event        `json:"events"`
+	EventSequence int            `json:"eventSequence"`
+	EventsReset   bool           `json:"eventsReset,omitempty"`
+	StateVersion  int64          `json:"stateVersion"`
+	FullState     bool           `json:"fullState,omitempty"`
+	State         map[string]any `json:"state,omitempty"`
+	StateSet      map[string]any `json:"stateSet,omitempty"`
+	StateRemoved  []string       `json:"stateRemoved,omitempty"`
+}
+
+// SelectCandidateRequest is the request body for selecting one candidate of an event
+// as the canonical turn.
+type SelectCandidateRequest struct {
+	Index int `json:"index"`
+}
+
+// CreateScheduleRequest is the request body for creating a recurring run.
+type CreateScheduleRequest struct {
+	Cron          string `json:"cron"`
+	Message       string `json:"message"`
+	SessionPolicy string `json:"sessionPolicy,omitempty"`
+	SessionID     string `json:"sessionId,omitempty"`
+	Enabled       *bool  `json:"enabled,omitempty"`
+}
+
+// UpdateScheduleRequest is the request body for enabling or disabling a schedule.
+type UpdateScheduleRequest struct {
+	Enabled bool `json:"enabled"`
 }
 
 // CreateSessionRequest is the request body for creating a session.
@@ -64,6 +166,78 @@ type CreateSessionRequest struct {
 	Events []Event        `json:"events,omitempty"`
 }
 
+// Validate checks that the overrides are within the ranges accepted by the providers.
+func (g *GenerationConfig) Validate() error {
+	if g == nil {
+		return nil
+	}
+
+	if g.Temperature != nil && (*g.Temperature < 0 || *g.Temperature > 2) {
+		return errors.New("generationConfig.temperature must be between 0 and 2")
+	}
+	if g.TopP != nil && (*g.TopP < 0 || *g.TopP > 1) {
+		return errors.New("generationConfig.topP must be between 0 and 1")
+	}
+	if g.MaxOutputTokens != nil && *g.MaxOutputTokens <= 0 {
+		return errors.New("generationConfig.maxOutputTokens must be positive")
+	}
+	if g.CandidateCount != nil && (*g.CandidateCount < 1 || *g.CandidateCount > maxCandidateCount) {
+		return fmt.Errorf("generationConfig.candidateCount must be between 1 and %d", maxCandidateCount)
+	}
+	if g.ThinkingBudget != nil && *g.ThinkingBudget < 0 {
+		return errors.New("generationConfig.thinkingBudget cannot be negative")
+	}
+	if c := g.StreamCoalesce; c != nil {
+		if c.WindowMs < 0 || c.WindowMs > maxCoalesceWindowMs {
+			return fmt.Errorf("generationConfig.streamCoalesce.windowMs must be between 0 and %d", maxCoalesceWindowMs)
+		}
+		if c.MaxBytes < 0 {
+			return errors.New("generationConfig.streamCoalesce.maxBytes cannot be negative")
+		}
+	}
+	if g.ThinkingLevel != nil {
+		switch *g.ThinkingLevel {
+		case genai.ThinkingLevelLow, genai.ThinkingLevelHigh:
+		default:
+			return errors.New("generationConfig.thinkingLevel must be LOW or HIGH")
+		}
+	}
+
+	return nil
+}
+
+// ApplyTo merges the overrides into cfg.
+func (g *GenerationConfig) ApplyTo(cfg *genai.GenerateContentConfig) {
+	if g == nil || cfg == nil {
+		return
+	}
+
+	if g.Temperature != nil {
+		cfg.Temperature = g.Temperature
+	}
+	if g.TopP != nil {
+		cfg.TopP = g.TopP
+	}
+	if g.MaxOutputTokens != nil {
+		cfg.MaxOutputTokens = *g.MaxOutputTokens
+	}
+	if g.CandidateCount != nil {
+		cfg.CandidateCount = *g.CandidateCount
+	}
+
+	if g.ThinkingLevel != nil || g.ThinkingBudget != nil {
+		if cfg.ThinkingConfig == nil {
+			cfg.ThinkingConfig = &genai.ThinkingConfig{}
+		}
+		if g.ThinkingLevel != nil {
+			cfg.ThinkingConfig.ThinkingLevel = *g.ThinkingLevel
+		}
+		if g.ThinkingBudget != nil {
+			cfg.ThinkingConfig.ThinkingBudget = g.ThinkingBudget
+		}
+	}
+}
+
 // ============================================================================
 // Conversion functions
 // ============================================================================
@@ -88,6 +262,7 @@ func FromSessionEvent(e *session.Event) Event {
 			StateDelta:    e.Actions.StateDelta,
 			ArtifactDelta: e.Actions.ArtifactDelta,
 		},
+		Candidates: genaitypes.CandidatesFromMetadata(e.CustomMetadata),
 	}
 }
 
@@ -107,14 +282,26 @@ func FromSession(s session.Session) Session {
 		UpdatedAt: s.LastUpdateTime().Unix(),
 		Events:    events,
 		State:     state,
+		Tags:      ksess.TagsOf(s),
 	}
 }
 
 // toSessionEvent converts an API Event to a session.Event.
 func ToSessionEvent(e Event) *session.Event {
+	var customMetadata map[string]any
+	if len(e.Candidates) > 0 {
+		customMetadata = map[string]any{genaitypes.CandidatesMetadataKey: e.Candidates}
+	}
+
+	// NOTE: Events without a time are stamped by the session service
+	var timestamp time.Time
+	if e.Time > 0 {
+		timestamp = time.Unix(e.Time, 0)
+	}
+
 	return &session.Event{
 		ID:                 e.ID,
-		Timestamp:          time.Unix(e.Time, 0),
+		Timestamp:          timestamp,
 		InvocationID:       e.InvocationID,
 		Branch:             e.Branch,
 		Author:             e.Author,
@@ -127,6 +314,7 @@ func ToSessionEvent(e Event) *session.Event {
 			Interrupted:       e.Interrupted,
 			ErrorCode:         e.ErrorCode,
 			ErrorMessage:      e.ErrorMessage,
+			CustomMetadata:    customMetadata,
 		},
 		Actions: session.EventActions{
 			StateDelta:    e.Actions.StateDelta,
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+
+	"google.golang.org/adk/session"
+	"google.golang.org/genai"
+)
+
+// DefaultMaxInlineBytes is the largest inline data kept in rendered content.
+const DefaultMaxInlineBytes = 32 * 1024
+
+// RenderOptions controls how event content is rendered for web frontends.
+type RenderOptions struct {
+	// MaxInlineBytes is the largest inline data kept in content. Larger inline data
+	// is replaced by a fileData part pointing at the artifact (or event part) URL.
+	// Negative keeps all inline data.
+	MaxInlineBytes int
+
+	// BaseURL prefixes the generated URLs, e.g., "https://api.example.com".
+	// Optional. Default: relative URLs.
+	BaseURL string
+}
+
+// Attachment summarizes a non-text part of an event.
+type Attachment struct {
+	// Part is the index of the part in the event content, -1 for an artifact saved
+	// by the event without a matching part.
+	Part     int    `json:"part"`
+	Kind     string `json:"kind"` // image, audio, video, text, document or file
+	MIMEType string `json:"mimeType"`
+	Name     string `json:"name,omitempty"`
+	Size     int    `json:"size,omitempty"`
+
+	// URL serves the data; empty when it is kept inline.
+	URL string `json:"url,omitempty"`
+
+	// Artifact and Version identify the artifact holding the data, if any.
+	Artifact string `json:"artifact,omitempty"`
+	Version  int64  `json:"version,omitempty"`
+}
+
+// RenderSessionEvent converts a session.Event like FromSessionEvent, replacing large
+// inline data by URLs and summarizing every non-text part in Attachments.
+func RenderSessionEvent(
+	appName, userID, sessionID string,
+	e *session.Event,
+	opts RenderOptions,
+) Event {
+	evt := FromSessionEvent(e)
+	if e.Content == nil {
+		return evt
+	}
+
+	maxInline := opts.MaxInlineBytes
+	if maxInline == 0 {
+		maxInline = DefaultMaxInlineBytes
+	}
+
+	content := &genai.Content{Role: e.Content.Role, Parts: make([]*genai.Part, len(e.Content.Parts))}
+	for i, part := range e.Content.Parts {
+		content.Parts[i] = part
+		if part == nil {
+			continue
+		}
+
+		switch {
+		case part.InlineData != nil:
+			blob := part.InlineData
+			att := Attachment{
+				Part:     i,
+				Kind:     MediaKind(blob.MIMEType),
+				MIMEType: blob.MIMEType,
+				Name:     blob.DisplayName,
+				Size:     len(blob.Data),
+			}
+
+			if maxInline < 0 || len(blob.Data) <= maxInline {
+				evt.Attachments = append(evt.Attachments, att)
+				continue
+			}
+
+			// Prefer the artifact saved for this data, the event part otherwise
+			if version, ok := e.Actions.ArtifactDelta[blob.DisplayName]; ok && blob.DisplayName != "" {
+				att.Artifact, att.Version = blob.DisplayName, version
+				att.URL = ArtifactURL(opts.BaseURL, appName, userID, sessionID, blob.DisplayName, version)
+			} else {
+				att.URL = EventPartURL(opts.BaseURL, appName, userID, sessionID, e.ID, i)
+			}
+
+			rendered := *part
+			rendered.InlineData = nil
+			rendered.FileData = &genai.FileData{
+				FileURI:     att.URL,
+				MIMEType:    blob.MIMEType,
+				DisplayName: blob.DisplayName,
+			}
+			content.Parts[i] = &rendered
+			evt.Attachments = append(evt.Attachments, att)
+
+		case part.FileData != nil:
+			evt.Attachments = append(evt.Attachments, Attachment{
+				Part:     i,
+				Kind:     MediaKind(part.FileData.MIMEType),
+				MIMEType: part.FileData.MIMEType,
+				Name:     part.FileData.DisplayName,
+				URL:      part.FileData.FileURI,
+			})
+		}
+	}
+
+	// Artifacts saved by the event without a matching part (e.g., by a tool)
+	for _, name := range slices.Sorted(maps.Keys(e.Actions.ArtifactDelta)) {
+		if hasAttachment(evt.Attachments, name) {
+			continue
+		}
+		version := e.Actions.ArtifactDelta[name]
+		evt.Attachments = append(evt.Attachments, Attachment{
+			Part:     -1,
+			Kind:     "file",
+			Name:     name,
+			URL:      ArtifactURL(opts.BaseURL, appName, userID, sessionID, name, version),
+			Artifact: name,
+			Version:  version,
+		})
+	}
+
+	evt.Content = content
+	return evt
+}
+
+// RenderSession converts a session.Session like FromSession, rendering every event
+// with RenderSessionEvent.
+func RenderSession(s session.Session, opts RenderOptions) Session {
+	sess := FromSession(s)
+
+	events := make([]Event, 0, s.Events().Len())
+	for e := range s.Events().All() {
+		events = append(events, RenderSessionEvent(s.AppName(), s.UserID(), s.ID(), e, opts))
+	}
+	sess.Events = events
+
+	return sess
+}
+
+// ArtifactURL returns the URL serving the raw data of an artifact version.
+func ArtifactURL(baseURL, appName, userID, sessionID, name string, version int64) string {
+	return fmt.Sprintf("%s/apps/%s/users/%s/sessions/%s/artifacts/%s/raw?version=%d",
+		strings.TrimSuffix(baseURL, "/"), url.PathEscape(appName), url.PathEscape(userID),
+		url.PathEscape(sessionID), url.PathEscape(name), version)
+}
+
+// EventPartURL returns the URL serving the raw data of one part of an event.
+func EventPartURL(baseURL, appName, userID, sessionID, eventID string, part int) string {
+	return fmt.Sprintf("%s/apps/%s/users/%s/sessions/%s/events/%s/parts/%d",
+		strings.TrimSuffix(baseURL, "/"), url.PathEscape(appName), url.PathEscape(userID),
+		url.PathEscape(sessionID), url.PathEscape(eventID), part)
+}
+
+// MediaKind classifies a MIME type for display.
+func MediaKind(mimeType string) string {
+	switch major, _, _ := strings.Cut(mimeType, "/"); {
+	case major == "image", major == "audio", major == "video", major == "text":
+		return major
+	case mimeType == "application/pdf":
+		return "document"
+	default:
+		return "file"
+	}
+}
+
+func hasAttachment(atts []Attachment, artifact string) bool {
+	for _, a := range atts {
+		if a.Artifact == artifact {
+			return true
+		}
+	}
+	return false
+}
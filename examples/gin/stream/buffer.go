@@ -0,0 +1,325 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kydenul/k-adk/examples/gin/models"
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/log"
+	"google.golang.org/genai"
+)
+
+const (
+	defaultBufferSize   = 64
+	defaultStallTimeout = 30 * time.Second
+)
+
+// ErrClientStalled is returned by the writes of a BufferedWriter once its client
+// has not accepted data for the stall timeout and was disconnected.
+var ErrClientStalled = errors.New("stream client stalled")
+
+// BufferConfig configures a BufferedWriter.
+type BufferConfig struct {
+	// Optional. Size is the number of frames buffered for the client. Default: 64.
+	Size int
+
+	// Optional. DropPartials drops buffered partial events when the buffer is full;
+	// the final event of a response carries its whole content.
+	DropPartials bool
+
+	// Optional. CoalesceText merges consecutive buffered partial text events of a
+	// response into one, so a slow client receives fewer, larger deltas.
+	CoalesceText bool
+
+	// Optional. StallTimeout is how long the client may not accept data before it is
+	// disconnected. Default: 30s.
+	StallTimeout time.Duration
+
+	// Optional. Logger for logging. Falls back to `DiscardLog` if nil.
+	Logger log.Logger
+}
+
+// frame is a buffered write: an event, the interrupted marker or a run error.
+type frame struct {
+	event       *models.Event
+	interrupted bool
+	err         error
+}
+
+// BufferedWriter decouples a run from its client: writes are queued in a bounded
+// buffer drained by a goroutine, so a slow client does not block the run. When the
+// buffer is full, the configured policies make room; otherwise the write waits, and
+// a client stalled for the stall timeout is disconnected.
+type BufferedWriter struct {
+	w      *Writer
+	cfg    BufferConfig
+	logger log.Logger
+
+	mu     sync.Mutex
+	queue  []frame
+	closed bool
+	err    error
+
+	dropped   int
+	coalesced int
+
+	wake  chan struct{}
+	space chan struct{}
+	done  chan struct{}
+}
+
+// NewBufferedWriter creates a BufferedWriter writing to w, and starts draining it.
+// Call Close when the run ends.
+func NewBufferedWriter(w *Writer, cfg BufferConfig) *BufferedWriter {
+	if cfg.Size <= 0 {
+		cfg.Size = defaultBufferSize
+	}
+	if cfg.StallTimeout <= 0 {
+		cfg.StallTimeout = defaultStallTimeout
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = discardlog.NewDiscardLog()
+	}
+
+	b := &BufferedWriter{
+		w:      w,
+		cfg:    cfg,
+		logger: cfg.Logger,
+		queue:  make([]frame, 0, cfg.Size),
+		wake:   make(chan struct{}, 1),
+		space:  make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	go b.drain()
+
+	return b
+}
+
+// WriteEvent queues an event.
+func (b *BufferedWriter) WriteEvent(ev models.Event) error {
+	return b.push(frame{event: &ev})
+}
+
+// WriteInterrupted queues the marker ending an interrupted run.
+func (b *BufferedWriter) WriteInterrupted() error {
+	return b.push(frame{interrupted: true})
+}
+
+// WriteError queues a run error.
+func (b *BufferedWriter) WriteError(runErr error) error {
+	return b.push(frame{err: runErr})
+}
+
+// Close writes the buffered frames and stops the drain. It returns ErrClientStalled
+// if the client was disconnected.
+func (b *BufferedWriter) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	notify(b.wake)
+
+	<-b.done
+	_ = http.NewResponseController(b.w.w).SetWriteDeadline(time.Time{})
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.dropped > 0 || b.coalesced > 0 {
+		b.logger.Infof("slow stream client: dropped=%d, coalesced=%d", b.dropped, b.coalesced)
+	}
+
+	return b.err
+}
+
+// push queues a frame, making room with the policies or waiting for the client
+// when the buffer is full.
+func (b *BufferedWriter) push(f frame) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.err != nil {
+		return b.err
+	}
+	if b.closed {
+		return errors.New("stream writer is closed")
+	}
+
+	if b.cfg.CoalesceText && b.coalesce(f) {
+		b.coalesced++
+		return nil
+	}
+
+	var stalled <-chan time.Time
+	for len(b.queue) >= b.cfg.Size {
+		if b.cfg.DropPartials && b.dropPartial(f) {
+			b.dropped++
+			if f.event != nil && f.event.Partial {
+				return nil
+			}
+			break
+		}
+
+		if stalled == nil {
+			timer := time.NewTimer(b.cfg.StallTimeout)
+			defer timer.Stop()
+			stalled = timer.C
+		}
+
+		b.mu.Unlock()
+		select {
+		case <-b.space:
+		case <-stalled:
+			b.mu.Lock()
+			b.fail(ErrClientStalled)
+			return b.err
+		}
+		b.mu.Lock()
+
+		if b.err != nil {
+			return b.err
+		}
+	}
+
+	b.queue = append(b.queue, f)
+	notify(b.wake)
+
+	return nil
+}
+
+// coalesce merges a partial text event into the last buffered one of the same
+// response. The buffered event gets a copy of the content: the events share it with
+// the session.
+func (b *BufferedWriter) coalesce(f frame) bool {
+	if len(b.queue) == 0 {
+		return false
+	}
+
+	last := b.queue[len(b.queue)-1].event
+	next := f.event
+	if last == nil || next == nil || !last.Partial || !next.Partial ||
+		last.InvocationID != next.InvocationID || last.Author != next.Author {
+		return false
+	}
+
+	lastPart, ok := textPart(last)
+	if !ok {
+		return false
+	}
+	nextPart, ok := textPart(next)
+	if !ok || lastPart.Thought != nextPart.Thought {
+		return false
+	}
+
+	content := *last.Content
+	content.Parts = []*genai.Part{{Text: lastPart.Text + nextPart.Text, Thought: lastPart.Thought}}
+	last.Content = &content
+	last.ID = next.ID
+	last.Time = next.Time
+
+	return true
+}
+
+// textPart returns the part of an event whose content is a single text part.
+func textPart(ev *models.Event) (*genai.Part, bool) {
+	if ev.Content == nil || len(ev.Content.Parts) != 1 {
+		return nil, false
+	}
+
+	part := ev.Content.Parts[0]
+	if part == nil || part.Text == "" || part.FunctionCall != nil || part.FunctionResponse != nil ||
+		part.InlineData != nil || part.FileData != nil {
+		return nil, false
+	}
+
+	return part, true
+}
+
+// dropPartial makes room for f: it drops f itself if it is a partial event, else
+// the oldest buffered partial event. It returns false if nothing can be dropped.
+func (b *BufferedWriter) dropPartial(f frame) bool {
+	if f.event != nil && f.event.Partial {
+		return true
+	}
+
+	for i, queued := range b.queue {
+		if queued.event != nil && queued.event.Partial {
+			b.queue = append(b.queue[:i], b.queue[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// drain writes the buffered frames to the client until Close, or until a write
+// fails.
+func (b *BufferedWriter) drain() {
+	defer close(b.done)
+
+	rc := http.NewResponseController(b.w.w)
+	for {
+		b.mu.Lock()
+		for len(b.queue) == 0 && !b.closed && b.err == nil {
+			b.mu.Unlock()
+			<-b.wake
+			b.mu.Lock()
+		}
+		if len(b.queue) == 0 || b.err != nil {
+			b.mu.Unlock()
+			return
+		}
+
+		f := b.queue[0]
+		b.queue = b.queue[1:]
+		b.mu.Unlock()
+		notify(b.space)
+
+		// NOTE: A client that does not read blocks the write until the deadline,
+		// which breaks the connection
+		_ = rc.SetWriteDeadline(time.Now().Add(b.cfg.StallTimeout))
+
+		if err := b.writeFrame(f); err != nil {
+			b.logger.Warnf("stream client disconnected: %v", err)
+
+			b.mu.Lock()
+			b.fail(fmt.Errorf("%w: %w", ErrClientStalled, err))
+			b.mu.Unlock()
+			return
+		}
+	}
+}
+
+func (b *BufferedWriter) writeFrame(f frame) error {
+	switch {
+	case f.event != nil:
+		return b.w.WriteEvent(f.event)
+	case f.interrupted:
+		return b.w.WriteInterrupted()
+	default:
+		return b.w.WriteError(f.err)
+	}
+}
+
+// fail records the error ending the stream, drops the buffered frames and wakes
+// the waiting writes. Called with the lock held.
+func (b *BufferedWriter) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+	b.dropped += len(b.queue)
+	b.queue = nil
+	notify(b.space)
+	notify(b.wake)
+}
+
+// notify signals a channel without blocking.
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
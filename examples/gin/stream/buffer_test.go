@@ -0,0 +1,254 @@
+package stream
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/kydenul/k-adk/examples/gin/models"
+	"google.golang.org/genai"
+)
+
+// slowClient is a response whose writes wait for gate, if set, to be signaled or
+// closed.
+type slowClient struct {
+	gate chan struct{}
+
+	mu     sync.Mutex
+	header http.Header
+	body   bytes.Buffer
+}
+
+func newSlowClient(gate chan struct{}) *slowClient {
+	return &slowClient{gate: gate, header: http.Header{}}
+}
+
+func (c *slowClient) Header() http.Header { return c.header }
+
+func (c *slowClient) WriteHeader(int) {}
+
+func (c *slowClient) Write(p []byte) (int, error) {
+	if c.gate != nil {
+		<-c.gate
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.body.Write(p)
+}
+
+// events returns the IDs of the NDJSON events received, and the text of their
+// content.
+func (c *slowClient) events(t *testing.T) (ids, texts []string) {
+	t.Helper()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for line := range strings.SplitSeq(strings.TrimSpace(c.body.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var ev models.Event
+		if err := sonic.UnmarshalString(line, &ev); err != nil {
+			t.Fatalf("invalid event %q: %v", line, err)
+		}
+		ids = append(ids, ev.ID)
+		if ev.Content != nil && len(ev.Content.Parts) == 1 {
+			texts = append(texts, ev.Content.Parts[0].Text)
+		}
+	}
+	return ids, texts
+}
+
+func textEvent(id, text string, partial bool) models.Event {
+	return models.Event{
+		ID:           id,
+		InvocationID: "inv-1",
+		Author:       "agent",
+		Partial:      partial,
+		Content:      genai.NewContentFromText(text, genai.RoleModel),
+	}
+}
+
+// writeAsync writes ev in a goroutine, returning the channel of its result.
+func writeAsync(b *BufferedWriter, ev models.Event) <-chan error {
+	result := make(chan error, 1)
+	go func() { result <- b.WriteEvent(ev) }()
+	return result
+}
+
+func TestBufferedWriterFull(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     BufferConfig
+		partial bool
+		// wantWait is whether the write past the size waits for the client.
+		wantWait bool
+		wantIDs  []string
+	}{
+		{
+			name:     "waits for the client",
+			cfg:      BufferConfig{Size: 2},
+			wantWait: true,
+			wantIDs:  []string{"e0", "e1", "e2", "e3"},
+		},
+		{
+			name:    "drops partial events",
+			cfg:     BufferConfig{Size: 2, DropPartials: true},
+			partial: true,
+			wantIDs: []string{"e0", "e1", "e2"},
+		},
+		{
+			name:    "coalesces partial text",
+			cfg:     BufferConfig{Size: 2, CoalesceText: true},
+			partial: true,
+			wantIDs: []string{"e0", "e3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gate := make(chan struct{})
+			client := newSlowClient(gate)
+			b := NewBufferedWriter(NewWriter(client, TransportNDJSON), tt.cfg)
+
+			// e0 is taken by the drain, blocked on the client, and the others fill
+			// the buffer
+			if err := b.WriteEvent(textEvent("e0", "a", tt.partial)); err != nil {
+				t.Fatalf("WriteEvent failed: %v", err)
+			}
+			waitFor(t, func() bool {
+				b.mu.Lock()
+				defer b.mu.Unlock()
+				return len(b.queue) == 0
+			})
+			for _, id := range []string{"e1", "e2"} {
+				if err := b.WriteEvent(textEvent(id, "b", tt.partial)); err != nil {
+					t.Fatalf("WriteEvent(%s) failed: %v", id, err)
+				}
+			}
+
+			result := writeAsync(b, textEvent("e3", "c", tt.partial))
+			select {
+			case err := <-result:
+				if tt.wantWait {
+					t.Fatalf("the write past the size returned %v without waiting", err)
+				}
+			case <-time.After(50 * time.Millisecond):
+				if !tt.wantWait {
+					t.Fatal("the write past the size waited for the client")
+				}
+			}
+
+			close(gate)
+			if tt.wantWait {
+				if err := <-result; err != nil {
+					t.Fatalf("WriteEvent failed: %v", err)
+				}
+			}
+			if err := b.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+
+			if ids, _ := client.events(t); !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("received %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestBufferedWriterStall(t *testing.T) {
+	gate := make(chan struct{})
+	defer close(gate)
+
+	b := NewBufferedWriter(NewWriter(newSlowClient(gate), TransportNDJSON), BufferConfig{
+		Size:         1,
+		StallTimeout: 20 * time.Millisecond,
+	})
+
+	var err error
+	for i := 0; i < 3 && err == nil; i++ {
+		err = b.WriteEvent(textEvent("e", "a", false))
+	}
+	if !errors.Is(err, ErrClientStalled) {
+		t.Fatalf("expected ErrClientStalled for a client that does not read, got %v", err)
+	}
+	if err := b.WriteEvent(textEvent("e", "a", false)); !errors.Is(err, ErrClientStalled) {
+		t.Errorf("expected the writes after a stall to fail, got %v", err)
+	}
+}
+
+func TestBufferedWriterSendsBeforeClose(t *testing.T) {
+	client := newSlowClient(nil)
+	b := NewBufferedWriter(NewWriter(client, TransportNDJSON), BufferConfig{})
+	defer b.Close()
+
+	if err := b.WriteEvent(textEvent("e0", "a", false)); err != nil {
+		t.Fatalf("WriteEvent failed: %v", err)
+	}
+
+	// NOTE: The drain writes each event when it is queued, not when the buffer fills
+	waitFor(t, func() bool {
+		ids, _ := client.events(t)
+		return len(ids) == 1
+	})
+}
+
+func TestBufferedWriterCloseDrains(t *testing.T) {
+	gate := make(chan struct{})
+	client := newSlowClient(gate)
+	b := NewBufferedWriter(NewWriter(client, TransportNDJSON), BufferConfig{Size: 16})
+
+	var want []string
+	for _, id := range []string{"e0", "e1", "e2", "e3", "e4"} {
+		if err := b.WriteEvent(textEvent(id, id, false)); err != nil {
+			t.Fatalf("WriteEvent failed: %v", err)
+		}
+		want = append(want, id)
+	}
+	if err := b.WriteInterrupted(); err != nil {
+		t.Fatalf("WriteInterrupted failed: %v", err)
+	}
+
+	// The client reads slowly while Close waits for the buffer to be written
+	go func() {
+		for range 6 {
+			time.Sleep(5 * time.Millisecond)
+			gate <- struct{}{}
+		}
+	}()
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	ids, texts := client.events(t)
+	if !slices.Equal(ids, append(want, "")) {
+		t.Errorf("received %v, want %v and the interrupted marker", ids, want)
+	}
+	if !slices.Equal(texts, want) {
+		t.Errorf("received texts %v, want %v", texts, want)
+	}
+	if err := b.WriteEvent(textEvent("late", "a", false)); err == nil {
+		t.Error("expected a write after Close to fail")
+	}
+}
+
+// waitFor polls cond until it holds, failing the test after a second.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
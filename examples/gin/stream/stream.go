@@ -0,0 +1,146 @@
+// Package stream encodes run events for clients that cannot all consume Server-Sent
+// Events: the transport of a run is negotiated from the request headers, and events
+// are written as SSE or newline-delimited JSON (long-poll is served by background jobs).
+package stream
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/bytedance/sonic"
+)
+
+// Content types of the streaming transports.
+const (
+	ContentTypeSSE    = "text/event-stream"
+	ContentTypeNDJSON = "application/x-ndjson"
+)
+
+// Transport is the encoding of a run's events.
+type Transport string
+
+// Supported transports.
+const (
+	// TransportSSE streams "data: {json}\n\n" frames (default, built-in ADK format).
+	TransportSSE Transport = "sse"
+
+	// TransportNDJSON streams one JSON event per line, readable by any client that
+	// can consume a chunked response body.
+	TransportNDJSON Transport = "ndjson"
+
+	// TransportLongPoll runs in the background and returns a cursor to poll the
+	// events with, for clients and proxies that buffer streamed responses.
+	TransportLongPoll Transport = "longpoll"
+)
+
+// Negotiate returns the transport of a request: the "transport" query parameter if
+// set, long-poll for "Prefer: respond-async" (RFC 7240), otherwise the first
+// streaming media type of the Accept header, SSE by default.
+func Negotiate(r *http.Request) Transport {
+	switch t := Transport(r.URL.Query().Get("transport")); t {
+	case TransportSSE, TransportNDJSON, TransportLongPoll:
+		return t
+	}
+
+	for pref := range strings.SplitSeq(r.Header.Get("Prefer"), ",") {
+		if strings.EqualFold(strings.TrimSpace(pref), "respond-async") {
+			return TransportLongPoll
+		}
+	}
+
+	for accepted := range strings.SplitSeq(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil || params["q"] == "0" {
+			continue
+		}
+
+		switch mediaType {
+		case ContentTypeSSE:
+			return TransportSSE
+		case ContentTypeNDJSON, "application/jsonl", "application/json-seq":
+			return TransportNDJSON
+		}
+	}
+
+	return TransportSSE
+}
+
+// Writer writes events to a streamed response in the encoding of a transport.
+type Writer struct {
+	w         http.ResponseWriter
+	transport Transport
+}
+
+// NewWriter creates a Writer for the SSE or NDJSON transport.
+func NewWriter(w http.ResponseWriter, transport Transport) *Writer {
+	if transport != TransportNDJSON {
+		transport = TransportSSE
+	}
+	return &Writer{w: w, transport: transport}
+}
+
+// WriteHeader sets the streaming headers and the status of the response.
+func (w *Writer) WriteHeader(status int) {
+	h := w.w.Header()
+	if w.transport == TransportNDJSON {
+		h.Set("Content-Type", ContentTypeNDJSON)
+	} else {
+		h.Set("Content-Type", ContentTypeSSE)
+		h.Set("Connection", "keep-alive")
+	}
+	h.Set("Cache-Control", "no-cache")
+	// Ask reverse proxies (nginx) not to buffer the stream
+	h.Set("X-Accel-Buffering", "no")
+
+	w.w.WriteHeader(status)
+}
+
+// WriteEvent writes one event and flushes it to the client.
+func (w *Writer) WriteEvent(v any) error {
+	data, err := sonic.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	return w.write(data)
+}
+
+// WriteInterrupted writes the marker ending an interrupted run.
+func (w *Writer) WriteInterrupted() error {
+	return w.write([]byte(`{"interrupted":true}`))
+}
+
+// WriteError reports a run error. SSE keeps the built-in ADK plain-text line;
+// NDJSON writes {"error": "..."} so every line stays valid JSON.
+func (w *Writer) WriteError(runErr error) error {
+	if w.transport == TransportSSE {
+		_, err := fmt.Fprintf(w.w, "Error while running agent: %v\n", runErr)
+		w.flush()
+		return err
+	}
+
+	data, err := sonic.Marshal(map[string]string{"error": runErr.Error()})
+	if err != nil {
+		return err
+	}
+	return w.write(data)
+}
+
+func (w *Writer) write(data []byte) error {
+	var err error
+	if w.transport == TransportNDJSON {
+		_, err = fmt.Fprintf(w.w, "%s\n", data)
+	} else {
+		_, err = fmt.Fprintf(w.w, "data: %s\n\n", data)
+	}
+	w.flush()
+
+	return err
+}
+
+func (w *Writer) flush() {
+	if f, ok := w.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,207 @@
+// Package main migrates the sessions of a Redis-only deployment onto the Redis +
+// PostgreSQL hybrid setup with session/migrate, with progress reporting and
+// resumable runs.
+//
+// Usage:
+//
+//	go run main.go -app my_app -users u1,u2              # Migrate the sessions of u1 and u2
+//	go run main.go -app my_app -buckets 64               # Every user of a bucketed source index
+//	go run main.go -app my_app -users u1,u2 -resume u1:s9 # Resume an interrupted run
+//
+// The source, destination Redis and PostgreSQL connections are read from config.yaml.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/kydenul/log"
+	"github.com/spf13/viper"
+
+	"github.com/kydenul/k-adk/session/migrate"
+	pg "github.com/kydenul/k-adk/session/postgres"
+	rsess "github.com/kydenul/k-adk/session/redis"
+)
+
+// TTL defines the session expiration time in the destination Redis.
+const TTL = 7 * 24 * time.Hour
+
+// logger is the global logger instance used throughout the application.
+var logger log.Logger
+
+// init initializes the global logger by loading configuration from config.yaml.
+// Panics if the configuration file cannot be loaded.
+func init() {
+	opt, err := log.LoadFromFile(filepath.Join(".", "config.yaml"))
+	if err != nil {
+		panic(fmt.Sprintf("Failed to load log config from file: %v", err))
+	}
+	logger = log.NewLog(opt)
+
+	// Viper Config
+	viper.AddConfigPath(".")
+	viper.SetConfigName("config")
+	viper.SetConfigType("yaml")
+	if err := viper.ReadInConfig(); err != nil {
+		log.Fatalf("Failed to read config file: %v", err)
+	}
+}
+
+// redisConfig loads the Redis configuration under key from config.yaml using viper.
+// Fatally exits if configuration loading fails.
+func redisConfig(key string) *rsess.RedisConfig {
+	redisConfig := &rsess.RedisConfig{}
+	if err := viper.UnmarshalKey(key, redisConfig); err != nil {
+		log.Fatalf("Failed to unmarshal Redis config %s: %v", key, err)
+	}
+
+	return redisConfig
+}
+
+// postgresConfig loads PostgreSQL configuration from config.yaml using viper.
+// Fatally exits if configuration loading fails.
+func postgresConfig() *pg.Config {
+	cfg := pg.DefaultConfig()
+	if err := viper.UnmarshalKey("Postgres", cfg); err != nil {
+		log.Fatalf("Failed to unmarshal Postgres config: %v", err)
+	}
+
+	cfg.Logger = logger
+
+	return cfg
+}
+
+func main() {
+	appName := flag.String("app", "", "app name of the sessions to migrate (required)")
+	users := flag.String("users", "", "comma-separated user IDs; empty lists every user of a bucketed source index")
+	buckets := flag.Int("buckets", 0, "bucket count of the source index (see WithBucketedIndex), to list its users")
+	resume := flag.String("resume", "", "cursor printed by an interrupted run, to resume it")
+	rate := flag.Int("rate", 50, "sessions migrated per second, 0 for no limit")
+	flag.Parse()
+
+	if *appName == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	cursor, err := migrate.ParseCursor(*resume)
+	if err != nil {
+		log.Fatalf("Invalid cursor: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Source: the Redis-only deployment
+	srcRDB, err := rsess.NewRedisClient(redisConfig("Source"))
+	if err != nil {
+		log.Fatalf("Failed to create source redis client: %v", err)
+	}
+	defer func() {
+		if err := srcRDB.Close(); err != nil {
+			logger.Errorf("Failed to close source redis client: %v", err)
+		}
+	}()
+
+	srcService, err := rsess.NewRedisSessionService(srcRDB,
+		rsess.WithLogger(logger),
+		rsess.WithBucketedIndex(*buckets))
+	if err != nil {
+		log.Fatalf("Failed to create source session service: %v", err)
+	}
+
+	// Destination: Redis + PostgreSQL
+	dstRDB, err := rsess.NewRedisClient(redisConfig("RedisProd"))
+	if err != nil {
+		log.Fatalf("Failed to create redis client: %v", err)
+	}
+	defer func() {
+		if err := dstRDB.Close(); err != nil {
+			logger.Errorf("Failed to close redis client: %v", err)
+		}
+	}()
+
+	pgClient, err := pg.NewPostgresClient(ctx, postgresConfig())
+	if err != nil {
+		log.Fatalf("Failed to create postgres client: %v", err)
+	}
+	defer func() {
+		if err := pgClient.Close(); err != nil {
+			logger.Errorf("Failed to close postgres client: %v", err)
+		}
+	}()
+
+	// Synchronous, so the events of a session are written after the session
+	pgPersister, err := pg.NewSessionPersister(ctx, pgClient, pg.WithAsyncBufferSize(0))
+	if err != nil {
+		log.Fatalf("Failed to create postgres persister: %v", err)
+	}
+	defer func() {
+		if err := pgPersister.Close(); err != nil {
+			logger.Errorf("Failed to close postgres persister: %v", err)
+		}
+	}()
+
+	dstService, err := rsess.NewRedisSessionService(dstRDB,
+		rsess.WithTTL(TTL),
+		rsess.WithLogger(logger),
+		rsess.WithPersister(pgPersister))
+	if err != nil {
+		log.Fatalf("Failed to create session service: %v", err)
+	}
+
+	filter := migrate.Filter{AppName: *appName}
+	if *users != "" {
+		filter.UserIDs = strings.Split(*users, ",")
+	} else {
+		filter.UserIDs, err = indexedUsers(ctx, srcService, *appName)
+		if err != nil {
+			log.Fatalf("Failed to list the users of the source: %v", err)
+		}
+	}
+
+	result, err := migrate.Migrate(ctx, srcService, dstService, filter,
+		migrate.WithResume(cursor),
+		migrate.WithSessionsPerSecond(*rate),
+		migrate.WithLogger(logger),
+		migrate.WithProgress(func(r *migrate.Result) {
+			if handled := r.Sessions + r.Skipped + r.Failed; handled%100 == 0 {
+				logger.Infof("Migration progress: sessions=%d, events=%d, skipped=%d, failed=%d, cursor=%s",
+					r.Sessions, r.Events, r.Skipped, r.Failed, r.Cursor)
+			}
+		}))
+	if err != nil {
+		if result != nil && !result.Done {
+			logger.Errorf("Migration interrupted: %v; resume with the same flags and -resume %q",
+				err, result.Cursor)
+			return
+		}
+		log.Fatalf("Migration failed: %v", err)
+	}
+
+	logger.Infof("✓ Migration completed: sessions=%d, events=%d, skipped=%d, failed=%d, duration=%s",
+		result.Sessions, result.Events, result.Skipped, result.Failed, result.Duration)
+}
+
+// indexedUsers returns the users of appName in the bucketed index of svc.
+func indexedUsers(ctx context.Context, svc *rsess.RedisSessionService, appName string) ([]string, error) {
+	seen := make(map[string]bool)
+	var users []string
+	for ref, err := range svc.AllSessions(ctx, appName) {
+		if err != nil {
+			return nil, err
+		}
+		if !seen[ref.UserID] {
+			seen[ref.UserID] = true
+			users = append(users, ref.UserID)
+		}
+	}
+	return users, nil
+}
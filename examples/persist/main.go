@@ -1,23 +1,29 @@
 // Package main demonstrates a multi-agent system with Redis + PostgreSQL
 // hybrid session persistence using Google ADK.
 //
-// This example provides two modes:
+// This example provides three modes:
 //   - demo: Runs a persistence demonstration that validates the hybrid storage functionality
 //   - serve: Starts the full web server with the weather_time_agent
+//   - backfill: Imports the sessions stored only in Redis into PostgreSQL
 //
 // Usage:
 //
-//	go run main.go demo   # Run persistence demo
-//	go run main.go serve  # Start web server (default)
+//	go run main.go demo                         # Run persistence demo
+//	go run main.go serve                        # Start web server (default)
+//	go run main.go backfill [app_name] [cursor] # Import Redis-only sessions
 package main
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/bytedance/sonic"
@@ -108,11 +114,15 @@ func main() {
 	case "serve":
 		runServer()
 
+	case "backfill":
+		runBackfill(os.Args[2:])
+
 	default:
 		fmt.Printf("Unknown mode: %s\n", mode)
-		fmt.Println("Usage: go run main.go [demo|serve]")
-		fmt.Println("  demo  - Run persistence demonstration")
-		fmt.Println("  serve - Start web server (default)")
+		fmt.Println("Usage: go run main.go [demo|serve|backfill]")
+		fmt.Println("  demo     - Run persistence demonstration")
+		fmt.Println("  serve    - Start web server (default)")
+		fmt.Println("  backfill - Import Redis-only sessions into PostgreSQL ([app_name] [cursor])")
 		os.Exit(1)
 	}
 }
@@ -651,6 +661,83 @@ func runServer() {
 	}
 }
 
+// runBackfill imports the sessions stored in Redis into PostgreSQL, e.g. after
+// enabling the persister on a deployment that started with Redis-only sessions.
+// args are the optional app name (empty or "*" for every app) and the cursor
+// printed by an interrupted run, to resume it. Interrupt it with Ctrl-C.
+func runBackfill(args []string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var cfg rsess.BackfillConfig
+	if len(args) > 0 && args[0] != "*" {
+		cfg.AppName = args[0]
+	}
+	if len(args) > 1 {
+		cursor, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			log.Fatalf("Invalid cursor %q: %v", args[1], err)
+		}
+		cfg.Resume = cursor
+	}
+	cfg.OnProgress = func(r *rsess.BackfillResult) {
+		logger.Infof("Backfill progress: sessions=%d, events=%d, skipped=%d, failed=%d, cursor=%d",
+			r.Sessions, r.Events, r.Skipped, r.Failed, r.Cursor)
+	}
+
+	rdb, err := rsess.NewRedisClient(redisConfig())
+	if err != nil {
+		log.Fatalf("Failed to create redis client: %v", err)
+	}
+	defer func() {
+		if err := rdb.Close(); err != nil {
+			logger.Errorf("Failed to close redis client: %v", err)
+		}
+	}()
+
+	pgClient, err := pg.NewPostgresClient(ctx, postgresConfig())
+	if err != nil {
+		log.Fatalf("Failed to create postgres client: %v", err)
+	}
+	defer func() {
+		if err := pgClient.Close(); err != nil {
+			logger.Errorf("Failed to close postgres client: %v", err)
+		}
+	}()
+
+	// Synchronous, so the events of a session are written after the session
+	pgPersister, err := pg.NewSessionPersister(ctx, pgClient, pg.WithAsyncBufferSize(0))
+	if err != nil {
+		log.Fatalf("Failed to create postgres persister: %v", err)
+	}
+	defer func() {
+		if err := pgPersister.Close(); err != nil {
+			logger.Errorf("Failed to close postgres persister: %v", err)
+		}
+	}()
+
+	sessService, err := rsess.NewRedisSessionService(rdb,
+		rsess.WithTTL(TTL),
+		rsess.WithLogger(logger))
+	if err != nil {
+		log.Fatalf("Failed to create session service: %v", err)
+	}
+
+	cfg.Persister = pgPersister
+	result, err := sessService.Backfill(ctx, cfg)
+	if err != nil {
+		if result != nil && !result.Done {
+			logger.Errorf("Backfill interrupted: %v; resume with: go run main.go backfill %q %d",
+				err, cmp.Or(cfg.AppName, "*"), result.Cursor)
+			return
+		}
+		log.Fatalf("Backfill failed: %v", err)
+	}
+
+	logger.Infof("✓ Backfill completed: sessions=%d, events=%d, skipped=%d, failed=%d, duration=%s",
+		result.Sessions, result.Events, result.Skipped, result.Failed, result.Duration)
+}
+
 // Helper functions
 
 func printDivider(title string) {
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/kydenul/k-adk/genai/openai"
+	"github.com/kydenul/k-adk/plugin/contextguard"
 	rsess "github.com/kydenul/k-adk/session/redis"
 	"github.com/kydenul/log"
 	"github.com/spf13/viper"
@@ -71,6 +72,13 @@ func main() {
 
 	log.Infof("Created session: %s", resp.Session.ID())
 
+	// Keep the prompt bounded as the Redis-backed conversation grows
+	budget := contextguard.NewBudget(contextguard.BudgetConfig{
+		MaxTokens:  32_000,
+		Counter:    model,
+		Summarizer: model,
+	})
+
 	rootAgent, err := llmagent.New(llmagent.Config{
 		Name:        "session_agent",
 		Model:       model,
@@ -78,7 +86,9 @@ func main() {
 		Instruction: `You are a helpful assistant. You remember everything discussed in the current conversation.
 The conversation history is maintained automatically through the session.
 Be conversational and reference previous parts of the conversation when relevant.`,
-		Toolsets: []tool.Toolset{},
+		Toolsets:             []tool.Toolset{},
+		BeforeModelCallbacks: []llmagent.BeforeModelCallback{budget.BeforeModel},
+		AfterModelCallbacks:  []llmagent.AfterModelCallback{budget.AfterModel},
 	})
 	if err != nil {
 		log.Fatalf("Failed to create root agent: %v", err)
@@ -175,7 +185,9 @@ Be conversational and reference previous parts of the conversation when relevant
 		Instruction: `You are a helpful assistant. You remember everything discussed in the current conversation.
 The conversation history is maintained automatically through the session.
 Be conversational and reference previous parts of the conversation when relevant.`,
-		Toolsets: []tool.Toolset{},
+		Toolsets:             []tool.Toolset{},
+		BeforeModelCallbacks: []llmagent.BeforeModelCallback{budget.BeforeModel},
+		AfterModelCallbacks:  []llmagent.AfterModelCallback{budget.AfterModel},
 	})
 	if err != nil {
 		log.Fatalf("Failed to create new agent: %v", err)
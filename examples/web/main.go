@@ -23,8 +23,9 @@ import (
 	"google.golang.org/adk/tool/geminitool"
 	"google.golang.org/genai"
 
-	"github.com/kydenul/k-adk/examples/web/agents"
+	"github.com/kydenul/k-adk/agents/auditor"
 	rsess "github.com/kydenul/k-adk/session/redis"
+	imagetool "github.com/kydenul/k-adk/tools/image"
 )
 
 // TTL defines the session expiration time in Redis.
@@ -130,8 +131,30 @@ func main() {
 	}
 
 	// Initialize specialized sub-agents
-	llmAuditor := agents.LLMAuditorAgent(ctx, model)
-	imageGeneratorAgent := agents.ImageGeneratorAgent(ctx, model)
+	llmAuditor, err := auditor.New(auditor.Config{
+		Model:          model,
+		GroundingTools: []tool.Tool{geminitool.GoogleSearch{}},
+		Logger:         logger,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create llm auditor: %v", err)
+	}
+
+	imageGenerator, err := imagetool.New(ctx, imagetool.Config{
+		Backend: genai.BackendGeminiAPI,
+		APIKey:  apiKey,
+		Logger:  logger,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create image generator: %v", err)
+	}
+	imageGeneratorAgent, err := imagetool.NewAgent(imagetool.AgentConfig{
+		Generator: imageGenerator,
+		Model:     model,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create image generator agent: %v", err)
+	}
 
 	// Create a multi-loader to manage all agents
 	agentLoader, err := agent.NewMultiLoader(rootAgent, llmAuditor, imageGeneratorAgent)
@@ -8,11 +8,17 @@ import (
 	"iter"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
 	"github.com/bytedance/sonic"
+	"github.com/kydenul/k-adk/genai/transcript"
+	genaitypes "github.com/kydenul/k-adk/genai/types"
+	"github.com/kydenul/k-adk/internal/coalesce"
 	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/k-adk/internal/streamwatch"
+	"github.com/kydenul/k-adk/internal/toolresult"
 	"github.com/kydenul/log"
 	"github.com/spf13/cast"
 	"google.golang.org/adk/model"
@@ -23,6 +29,12 @@ var (
 	// Ensure Model implements model.LLM
 	_ model.LLM = (*Model)(nil)
 
+	// Ensure Model reports its capabilities
+	_ genaitypes.CapabilityReporter = (*Model)(nil)
+
+	// Ensure Model can count prompt tokens
+	_ genaitypes.TokenCounter = (*Model)(nil)
+
 	ErrNoContentInResponse = errors.New("no content in Anthropic response")
 )
 
@@ -34,6 +46,19 @@ type Model struct {
 	modelName            string
 	maxOutputTokens      int64
 	thinkingBudgetTokens int64
+	capabilities         *genaitypes.Capabilities
+
+	// maxToolResultBytes caps serialized FunctionResponse payloads. Zero means no limit.
+	maxToolResultBytes int
+
+	// transcript records request/response pairs. Nil disables recording.
+	transcript *transcript.Recorder
+
+	// streamIdleTimeout aborts silent streams. Zero disables the watchdog.
+	streamIdleTimeout time.Duration
+
+	// coalesce merges streamed text deltas.
+	coalesce genaitypes.CoalesceConfig
 }
 
 // HTTPOptions holds HTTP-level configuration for the Anthropic client.
@@ -65,6 +90,31 @@ type Config struct {
 	// If zero, extended thinking is not enabled.
 	ThinkingBudgetTokens int64
 
+	// Optional. Capabilities overrides the built-in capability table for this model.
+	Capabilities *genaitypes.Capabilities
+
+	// Optional. MaxToolResultBytes caps the size of each serialized tool (function) response.
+	// Larger responses are truncated head+tail and annotated so the model knows content is missing.
+	// If zero, falls back to MaxToolResultTokens; if both are zero, responses are sent verbatim.
+	MaxToolResultBytes int
+
+	// Optional. MaxToolResultTokens is like MaxToolResultBytes but expressed in (approximate) tokens.
+	MaxToolResultTokens int
+
+	// Optional. Transcript records every request/response pair (redacted) to its sink.
+	// If nil, nothing is recorded.
+	Transcript *transcript.Recorder
+
+	// Optional. StreamIdleTimeout aborts a streaming response when no chunk arrives for
+	// this long, with a *genaitypes.StreamIdleError (matching genaitypes.ErrStreamIdle).
+	// If zero, falls back to genaitypes.DefaultStreamIdleTimeout; negative disables it.
+	StreamIdleTimeout time.Duration
+
+	// Optional. Coalesce merges consecutive streamed text deltas into fewer, larger
+	// partial responses. Overridden per call by genaitypes.WithCoalesce. If zero,
+	// every delta is streamed as is.
+	Coalesce genaitypes.CoalesceConfig
+
 	// Optional. Logger for logging. Falls back to `DiscardLog` if nil.
 	Logger log.Logger
 }
@@ -103,6 +153,11 @@ func New(config Config) *Model {
 		modelName:            config.ModelName,
 		maxOutputTokens:      config.MaxOutputTokens,
 		thinkingBudgetTokens: config.ThinkingBudgetTokens,
+		capabilities:         config.Capabilities,
+		maxToolResultBytes:   toolresult.MaxBytes(config.MaxToolResultBytes, config.MaxToolResultTokens),
+		transcript:           config.Transcript,
+		streamIdleTimeout:    streamwatch.Timeout(config.StreamIdleTimeout),
+		coalesce:             config.Coalesce,
 	}
 }
 
@@ -118,10 +173,10 @@ func (m *Model) GenerateContent(
 	m.Debugf("GenerateContent called: stream=%v, contents=%d", stream, len(req.Contents))
 
 	if stream {
-		return m.generateStream(ctx, req)
+		return m.transcript.Wrap(ctx, m.modelName, req, stream, m.generateStream(ctx, req))
 	}
 
-	return m.generate(ctx, req)
+	return m.transcript.Wrap(ctx, m.modelName, req, stream, m.generate(ctx, req))
 }
 
 // generate sends a single request and yields one complete response.
@@ -177,12 +232,18 @@ func (m *Model) generateStream(
 		}
 
 		m.Debugf("opening stream to Anthropic API")
-		stream := m.client.Messages.NewStreaming(ctx, params)
+		watchdog, streamCtx := streamwatch.New(ctx, m.streamIdleTimeout)
+		defer watchdog.Stop()
+
+		stream := m.client.Messages.NewStreaming(streamCtx, params)
 
 		message := anthropic.Message{}
+		deltas := coalesce.New(ctx, m.coalesce)
 		chunkCount := 0
 
+		// Ping events count as chunks, they keep the stream alive
 		for stream.Next() {
+			watchdog.Touch()
 			event := stream.Current()
 			if err := message.Accumulate(event); err != nil {
 				m.Errorf("failed to accumulate stream event: %v", err)
@@ -196,17 +257,8 @@ func (m *Model) generateStream(
 			case anthropic.ContentBlockDeltaEvent:
 				switch deltaVariant := eventVariant.Delta.AsAny().(type) {
 				case anthropic.TextDelta:
-					if deltaVariant.Text != "" {
-						part := &genai.Part{Text: deltaVariant.Text}
-						llmResp := &model.LLMResponse{
-							Content: &genai.Content{
-								Role:  genai.RoleModel,
-								Parts: []*genai.Part{part},
-							},
-							Partial:      true,
-							TurnComplete: false,
-						}
-						if !yield(llmResp, nil) {
+					if text, ok := deltas.Add(deltaVariant.Text); ok {
+						if !yield(partialResponse(text), nil) {
 							m.Warnf("streaming response cancelled by caller")
 							return
 						}
@@ -216,11 +268,19 @@ func (m *Model) generateStream(
 		}
 
 		if err := stream.Err(); err != nil {
+			err = watchdog.Err(err, m.modelName, chunkCount)
 			m.Errorf("stream error: %v", err)
 			yield(nil, err)
 			return
 		}
 
+		if text, ok := deltas.Flush(); ok {
+			if !yield(partialResponse(text), nil) {
+				m.Warnf("streaming response cancelled by caller")
+				return
+			}
+		}
+
 		m.Debugf("stream completed: total_chunks=%d", chunkCount)
 
 		// Build final aggregated response
@@ -240,6 +300,18 @@ func (m *Model) generateStream(
 	}
 }
 
+// partialResponse returns the partial response of a streamed text delta.
+func partialResponse(text string) *model.LLMResponse {
+	return &model.LLMResponse{
+		Content: &genai.Content{
+			Role:  genai.RoleModel,
+			Parts: []*genai.Part{{Text: text}},
+		},
+		Partial:      true,
+		TurnComplete: false,
+	}
+}
+
 // buildMessageParams converts an LLMRequest into Anthropic's API format (system prompt, messages, tools, config).
 func (m *Model) buildMessageParams(req *model.LLMRequest) (anthropic.MessageNewParams, error) {
 	m.Debugf("building message parameters")
@@ -258,9 +330,13 @@ func (m *Model) buildMessageParams(req *model.LLMRequest) (anthropic.MessageNewP
 		MaxTokens: maxTokens,
 	}
 
-	// Apply thinking config
-	if m.thinkingBudgetTokens > 0 {
-		params.Thinking = anthropic.ThinkingConfigParamOfEnabled(m.thinkingBudgetTokens)
+	// Apply thinking config; a per-request budget overrides the model default
+	thinkingBudget := m.thinkingBudgetTokens
+	if req.Config != nil && req.Config.ThinkingConfig != nil && req.Config.ThinkingConfig.ThinkingBudget != nil {
+		thinkingBudget = int64(*req.Config.ThinkingConfig.ThinkingBudget)
+	}
+	if thinkingBudget > 0 {
+		params.Thinking = anthropic.ThinkingConfigParamOfEnabled(thinkingBudget)
 	}
 
 	// Add system instruction if present
@@ -277,7 +353,7 @@ func (m *Model) buildMessageParams(req *model.LLMRequest) (anthropic.MessageNewP
 	// Convert content messages
 	messages := []anthropic.MessageParam{}
 	for _, content := range req.Contents {
-		msg, err := convertContentToMessage(content)
+		msg, err := convertContentToMessage(content, m.maxToolResultBytes, m)
 		if err != nil {
 			m.Errorf("failed to convert content to message: %v", err)
 			return anthropic.MessageNewParams{}, err
@@ -321,7 +397,13 @@ func (m *Model) buildMessageParams(req *model.LLMRequest) (anthropic.MessageNewP
 }
 
 // convertContentToMessage transforms a genai.Content (text, images, tool calls/results) into an Anthropic message.
-func convertContentToMessage(content *genai.Content) (*anthropic.MessageParam, error) {
+// Tool results larger than maxToolResultBytes are truncated, and logged to logger; zero
+// disables truncation.
+func convertContentToMessage(
+	content *genai.Content,
+	maxToolResultBytes int,
+	logger log.Logger,
+) (*anthropic.MessageParam, error) {
 	role := convertRoleToAnthropic(content.Role)
 
 	var blocks []anthropic.ContentBlockParamUnion
@@ -381,6 +463,12 @@ func convertContentToMessage(content *genai.Content) (*anthropic.MessageParam, e
 			if err != nil {
 				return nil, fmt.Errorf("failed to marshal function response: %w", err)
 			}
+			if maxToolResultBytes > 0 && len(responseJSON) > maxToolResultBytes {
+				limited := toolresult.Limit(responseJSON, maxToolResultBytes)
+				logger.Warnf("tool response truncated: name=%s, bytes=%d->%d",
+					part.FunctionResponse.Name, len(responseJSON), len(limited))
+				responseJSON = limited
+			}
 			blocks = append(
 				blocks,
 				anthropic.NewToolResultBlock(
@@ -1,12 +1,20 @@
 package anthropic
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
+	genaitypes "github.com/kydenul/k-adk/genai/types"
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/log"
 	"google.golang.org/adk/model"
 	"google.golang.org/genai"
 )
@@ -160,6 +168,29 @@ func TestBuildMessageParams(t *testing.T) {
 		}
 	})
 
+	t.Run("request thinking budget overrides model default", func(t *testing.T) {
+		m := New(Config{ModelName: "claude-sonnet-4-20250514", ThinkingBudgetTokens: 4096})
+		req := &model.LLMRequest{
+			Config: &genai.GenerateContentConfig{
+				ThinkingConfig: &genai.ThinkingConfig{ThinkingBudget: genai.Ptr[int32](1024)},
+			},
+			Contents: []*genai.Content{
+				{Role: "user", Parts: []*genai.Part{{Text: "hello"}}},
+			},
+		}
+
+		params, err := m.buildMessageParams(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if params.Thinking.OfEnabled == nil {
+			t.Fatal("expected Thinking.OfEnabled to be set")
+		}
+		if params.Thinking.OfEnabled.BudgetTokens != 1024 {
+			t.Errorf("expected BudgetTokens=1024, got %d", params.Thinking.OfEnabled.BudgetTokens)
+		}
+	})
+
 	t.Run("thinking not applied when zero", func(t *testing.T) {
 		m := New(Config{ModelName: "claude-sonnet-4-20250514"})
 		req := &model.LLMRequest{
@@ -333,7 +364,7 @@ func TestConvertContentToMessage_ImageTypes(t *testing.T) {
 				},
 			}
 
-			msg, err := convertContentToMessage(content)
+			msg, err := convertContentToMessage(content, 0, discardlog.NewDiscardLog())
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -358,7 +389,7 @@ func TestConvertContentToMessage_PDF(t *testing.T) {
 		},
 	}
 
-	msg, err := convertContentToMessage(content)
+	msg, err := convertContentToMessage(content, 0, discardlog.NewDiscardLog())
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -390,7 +421,7 @@ func TestConvertContentToMessage_TextFiles(t *testing.T) {
 				},
 			}
 
-			msg, err := convertContentToMessage(content)
+			msg, err := convertContentToMessage(content, 0, discardlog.NewDiscardLog())
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -416,7 +447,7 @@ func TestConvertContentToMessage_PlainTextUsesRawData(t *testing.T) {
 		},
 	}
 
-	msg, err := convertContentToMessage(content)
+	msg, err := convertContentToMessage(content, 0, discardlog.NewDiscardLog())
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -445,7 +476,7 @@ func TestConvertContentToMessage_UnsupportedMIME(t *testing.T) {
 		},
 	}
 
-	_, err := convertContentToMessage(content)
+	_, err := convertContentToMessage(content, 0, discardlog.NewDiscardLog())
 	if err == nil {
 		t.Fatal("expected error for unsupported MIME type")
 	}
@@ -463,7 +494,7 @@ func TestConvertContentToMessage_MixedParts(t *testing.T) {
 		},
 	}
 
-	msg, err := convertContentToMessage(content)
+	msg, err := convertContentToMessage(content, 0, discardlog.NewDiscardLog())
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -483,7 +514,7 @@ func TestConvertContentToMessage_TextOnly(t *testing.T) {
 		},
 	}
 
-	msg, err := convertContentToMessage(content)
+	msg, err := convertContentToMessage(content, 0, discardlog.NewDiscardLog())
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -510,7 +541,7 @@ func TestConvertContentToMessage_FunctionCallAndResponse(t *testing.T) {
 			},
 		}
 
-		msg, err := convertContentToMessage(content)
+		msg, err := convertContentToMessage(content, 0, discardlog.NewDiscardLog())
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -545,7 +576,7 @@ func TestConvertContentToMessage_FunctionCallAndResponse(t *testing.T) {
 			},
 		}
 
-		msg, err := convertContentToMessage(content)
+		msg, err := convertContentToMessage(content, 0, discardlog.NewDiscardLog())
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -564,7 +595,7 @@ func TestConvertContentToMessage_EmptyParts(t *testing.T) {
 		Parts: []*genai.Part{},
 	}
 
-	msg, err := convertContentToMessage(content)
+	msg, err := convertContentToMessage(content, 0, discardlog.NewDiscardLog())
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -1196,6 +1227,42 @@ func TestHTTPOptions(t *testing.T) {
 	})
 }
 
+// --- Capabilities ---
+
+func TestCapabilities(t *testing.T) {
+	t.Run("claude 4 supports vision and tools", func(t *testing.T) {
+		caps := New(Config{ModelName: "claude-sonnet-4-20250514"}).Capabilities()
+		if !caps.Vision || !caps.Tools || caps.Audio {
+			t.Errorf("unexpected capabilities: %+v", caps)
+		}
+	})
+
+	t.Run("json schema is not supported", func(t *testing.T) {
+		caps := New(Config{ModelName: "claude-3-7-sonnet-latest"}).Capabilities()
+		err := caps.Validate(&genai.GenerateContentConfig{
+			ResponseSchema: &genai.Schema{Type: genai.TypeObject},
+		})
+		if !errors.Is(err, genaitypes.ErrJSONSchemaUnsupported) {
+			t.Errorf("expected ErrJSONSchemaUnsupported, got %v", err)
+		}
+	})
+
+	t.Run("haiku 3.5 has no vision", func(t *testing.T) {
+		caps := New(Config{ModelName: "claude-3-5-haiku-20241022"}).Capabilities()
+		if caps.Vision {
+			t.Error("expected no vision for claude-3-5-haiku")
+		}
+	})
+
+	t.Run("config override takes precedence", func(t *testing.T) {
+		override := &genaitypes.Capabilities{Tools: true, JSONSchema: true}
+		caps := New(Config{ModelName: "claude-opus-4-1", Capabilities: override}).Capabilities()
+		if caps != *override {
+			t.Errorf("expected override capabilities, got %+v", caps)
+		}
+	})
+}
+
 // --- Model interface compliance ---
 
 func TestModelInterface(t *testing.T) {
@@ -1207,6 +1274,70 @@ func TestModelInterface(t *testing.T) {
 	}
 }
 
+// --- Tool result truncation ---
+
+func TestConvertContentToMessage_ToolResultTruncation(t *testing.T) {
+	content := &genai.Content{
+		Role: "user",
+		Parts: []*genai.Part{
+			{
+				FunctionResponse: &genai.FunctionResponse{
+					ID:       "toolu_123",
+					Name:     "read_file",
+					Response: map[string]any{"content": strings.Repeat("line\n", 2000)},
+				},
+			},
+		},
+	}
+
+	toolText := func(t *testing.T, maxBytes int, logger log.Logger) string {
+		t.Helper()
+		msg, err := convertContentToMessage(content, maxBytes, logger)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(msg.Content) != 1 || msg.Content[0].OfToolResult == nil {
+			t.Fatal("expected 1 tool result block")
+		}
+		return msg.Content[0].OfToolResult.Content[0].OfText.Text
+	}
+
+	t.Run("zero limit keeps response verbatim", func(t *testing.T) {
+		logger := &warnRecorder{Logger: discardlog.NewDiscardLog()}
+		if text := toolText(t, 0, logger); strings.Contains(text, "_truncated") {
+			t.Error("expected untruncated response")
+		}
+		if len(logger.warnings) != 0 {
+			t.Errorf("expected no warning, got %q", logger.warnings)
+		}
+	})
+
+	t.Run("limit truncates and annotates", func(t *testing.T) {
+		logger := &warnRecorder{Logger: discardlog.NewDiscardLog()}
+		text := toolText(t, 1000, logger)
+		if len(text) > 1000 {
+			t.Errorf("expected at most 1000 bytes, got %d", len(text))
+		}
+		if !strings.Contains(text, "_truncated") || !strings.Contains(text, "bytes omitted") {
+			t.Errorf("expected truncation markers, got %q", text)
+		}
+		if len(logger.warnings) != 1 || !strings.Contains(logger.warnings[0], "name=read_file") {
+			t.Errorf("expected the truncation logged, got %q", logger.warnings)
+		}
+	})
+}
+
+// warnRecorder is a log.Logger recording its warnings.
+type warnRecorder struct {
+	log.Logger
+
+	warnings []string
+}
+
+func (r *warnRecorder) Warnf(format string, args ...any) {
+	r.warnings = append(r.warnings, fmt.Sprintf(format, args...))
+}
+
 // --- JSON serialization of messages ---
 
 func TestMessageSerialization(t *testing.T) {
@@ -1219,7 +1350,7 @@ func TestMessageSerialization(t *testing.T) {
 			},
 		}
 
-		msg, err := convertContentToMessage(content)
+		msg, err := convertContentToMessage(content, 0, discardlog.NewDiscardLog())
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1241,7 +1372,7 @@ func TestMessageSerialization(t *testing.T) {
 			},
 		}
 
-		msg, err := convertContentToMessage(content)
+		msg, err := convertContentToMessage(content, 0, discardlog.NewDiscardLog())
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1263,7 +1394,7 @@ func TestMessageSerialization(t *testing.T) {
 			},
 		}
 
-		msg, err := convertContentToMessage(content)
+		msg, err := convertContentToMessage(content, 0, discardlog.NewDiscardLog())
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1277,3 +1408,73 @@ func TestMessageSerialization(t *testing.T) {
 		}
 	})
 }
+
+// --- Streaming watchdog ---
+
+func TestGenerateStream_IdleTimeout(t *testing.T) {
+	events := []string{
+		"event: message_start\ndata: " + `{"type":"message_start","message":{"id":"msg_1","type":"message",` +
+			`"role":"assistant","model":"claude-sonnet-4-20250514","content":[],"stop_reason":null,` +
+			`"usage":{"input_tokens":5,"output_tokens":0}}}`,
+		"event: content_block_start\ndata: " +
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		"event: content_block_delta\ndata: " +
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}`,
+	}
+
+	// Send the events, then nothing until the client gives up
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			_, _ = w.Write([]byte(event + "\n\n"))
+		}
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	t.Cleanup(srv.Close)
+
+	m := New(Config{
+		ModelName:         "claude-sonnet-4-20250514",
+		APIKey:            "test",
+		BaseURL:           srv.URL,
+		StreamIdleTimeout: 100 * time.Millisecond,
+	})
+
+	req := &model.LLMRequest{Contents: []*genai.Content{genai.NewContentFromText("hi", genai.RoleUser)}}
+
+	var (
+		partials int
+		lastErr  error
+	)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for resp, err := range m.GenerateContent(context.Background(), req, true) {
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			if resp.Partial {
+				partials++
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream was not aborted")
+	}
+
+	if partials != 1 {
+		t.Errorf("expected 1 partial response before the stall, got %d", partials)
+	}
+
+	var idle *genaitypes.StreamIdleError
+	if !errors.As(lastErr, &idle) || !errors.Is(lastErr, genaitypes.ErrStreamIdle) {
+		t.Fatalf("expected a StreamIdleError, got %v", lastErr)
+	}
+	if idle.Chunks != len(events) {
+		t.Errorf("expected %d chunks before the stall, got %d", len(events), idle.Chunks)
+	}
+}
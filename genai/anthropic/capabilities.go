@@ -0,0 +1,45 @@
+package anthropic
+
+import genaitypes "github.com/kydenul/k-adk/genai/types"
+
+// defaultCapabilities is reported for Claude models missing from the built-in table.
+//
+// JSONSchema is false across the board: this adapter does not translate ResponseSchema.
+var defaultCapabilities = genaitypes.Capabilities{
+	Vision:           true,
+	Tools:            true,
+	StreamingUsage:   true,
+	MaxContextTokens: 200_000,
+}
+
+// capabilityTable lists known Claude model families.
+// Lookups use the longest matching prefix of the lower-cased model name.
+var capabilityTable = []genaitypes.CapabilityEntry{
+	{Prefix: "claude-2", Capabilities: genaitypes.Capabilities{
+		StreamingUsage: true, MaxContextTokens: 100_000,
+	}},
+	{Prefix: "claude-instant", Capabilities: genaitypes.Capabilities{
+		StreamingUsage: true, MaxContextTokens: 100_000,
+	}},
+	{Prefix: "claude-3", Capabilities: defaultCapabilities},
+	{Prefix: "claude-3-5-haiku", Capabilities: genaitypes.Capabilities{
+		Tools: true, StreamingUsage: true, MaxContextTokens: 200_000,
+	}},
+	{Prefix: "claude-sonnet-4", Capabilities: defaultCapabilities},
+	{Prefix: "claude-opus-4", Capabilities: defaultCapabilities},
+	{Prefix: "claude-haiku-4", Capabilities: defaultCapabilities},
+}
+
+// Capabilities reports the features supported by the configured model.
+// Config.Capabilities takes precedence over the built-in table.
+func (m *Model) Capabilities() genaitypes.Capabilities {
+	if m.capabilities != nil {
+		return *m.capabilities
+	}
+
+	if caps, ok := genaitypes.LookupCapabilities(capabilityTable, m.modelName); ok {
+		return caps
+	}
+
+	return defaultCapabilities
+}
@@ -0,0 +1,45 @@
+package anthropic
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/spf13/cast"
+	"google.golang.org/adk/model"
+)
+
+// CountTokens returns the exact prompt tokens of req using Anthropic's count_tokens endpoint.
+func (m *Model) CountTokens(ctx context.Context, req *model.LLMRequest) (int, error) {
+	params, err := m.buildMessageParams(req)
+	if err != nil {
+		return 0, err
+	}
+
+	countParams := anthropic.MessageCountTokensParams{
+		Model:    params.Model,
+		Messages: params.Messages,
+		Thinking: params.Thinking,
+	}
+	if len(params.System) > 0 {
+		countParams.System = anthropic.MessageCountTokensParamsSystemUnion{
+			OfTextBlockArray: params.System,
+		}
+	}
+	for _, t := range params.Tools {
+		if t.OfTool != nil {
+			countParams.Tools = append(countParams.Tools,
+				anthropic.MessageCountTokensToolUnionParam{OfTool: t.OfTool})
+		}
+	}
+
+	resp, err := m.client.Messages.CountTokens(ctx, countParams)
+	if err != nil {
+		m.Errorf("Anthropic count_tokens request failed: %v", err)
+		return 0, fmt.Errorf("failed to count tokens: %w", err)
+	}
+
+	m.Debugf("counted prompt tokens: %d", resp.InputTokens)
+
+	return cast.ToInt(resp.InputTokens), nil
+}
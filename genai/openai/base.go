@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/openai/openai-go/v3"
@@ -114,6 +115,46 @@ func ensureObjectProperties(schema map[string]any) {
 	}
 }
 
+// strictSchema adapts a converted response schema to OpenAI strict mode: objects
+// forbid additional properties and require all their properties, the optional
+// ones accepting null instead.
+func strictSchema(schema map[string]any) {
+	if schema == nil {
+		return
+	}
+
+	if props, ok := schema["properties"].(map[string]any); ok && len(props) > 0 {
+		required := make(map[string]bool)
+		if names, ok := schema["required"].([]string); ok {
+			for _, name := range names {
+				required[name] = true
+			}
+		}
+
+		names := make([]string, 0, len(props))
+		for name, prop := range props {
+			names = append(names, name)
+
+			propMap, ok := prop.(map[string]any)
+			if !ok {
+				continue
+			}
+			strictSchema(propMap)
+			if typ, ok := propMap["type"].(string); ok && !required[name] {
+				propMap["type"] = []string{typ, "null"}
+			}
+		}
+		slices.Sort(names)
+
+		schema["required"] = names
+		schema["additionalProperties"] = false
+	}
+
+	if items, ok := schema["items"].(map[string]any); ok {
+		strictSchema(items)
+	}
+}
+
 // convertSchema recursively converts a genai.Schema to OpenAI JSON schema format.
 func convertSchema(schema *genai.Schema) (map[string]any, error) {
 	if schema == nil {
@@ -0,0 +1,92 @@
+package openai
+
+import genaitypes "github.com/kydenul/k-adk/genai/types"
+
+// defaultCapabilities is reported for models missing from the built-in table.
+// Most OpenAI-compatible servers (vLLM, Ollama, LiteLLM) accept tools and text only.
+var defaultCapabilities = genaitypes.Capabilities{
+	Tools:            true,
+	MaxContextTokens: 8192,
+}
+
+// capabilityTable lists known OpenAI and common OpenAI-compatible (Ollama, vLLM) model families.
+// Lookups use the longest matching prefix of the lower-cased model name.
+var capabilityTable = []genaitypes.CapabilityEntry{
+	// OpenAI
+	{Prefix: "gpt-4o", Capabilities: genaitypes.Capabilities{
+		Vision: true, Tools: true, JSONSchema: true, StreamingUsage: true, MaxContextTokens: 128_000,
+	}},
+	{Prefix: "gpt-4o-audio", Capabilities: genaitypes.Capabilities{
+		Audio: true, Tools: true, StreamingUsage: true, MaxContextTokens: 128_000,
+	}},
+	{Prefix: "gpt-4.1", Capabilities: genaitypes.Capabilities{
+		Vision: true, Tools: true, JSONSchema: true, StreamingUsage: true, MaxContextTokens: 1_047_576,
+	}},
+	{Prefix: "gpt-4-turbo", Capabilities: genaitypes.Capabilities{
+		Vision: true, Tools: true, StreamingUsage: true, MaxContextTokens: 128_000,
+	}},
+	{Prefix: "gpt-4", Capabilities: genaitypes.Capabilities{
+		Tools: true, StreamingUsage: true, MaxContextTokens: 8192,
+	}},
+	{Prefix: "gpt-3.5-turbo", Capabilities: genaitypes.Capabilities{
+		Tools: true, StreamingUsage: true, MaxContextTokens: 16_385,
+	}},
+	{Prefix: "gpt-5", Capabilities: genaitypes.Capabilities{
+		Vision: true, Tools: true, JSONSchema: true, StreamingUsage: true, MaxContextTokens: 400_000,
+	}},
+	{Prefix: "o1", Capabilities: genaitypes.Capabilities{
+		Vision: true, Tools: true, JSONSchema: true, StreamingUsage: true, MaxContextTokens: 200_000,
+	}},
+	{Prefix: "o3", Capabilities: genaitypes.Capabilities{
+		Vision: true, Tools: true, JSONSchema: true, StreamingUsage: true, MaxContextTokens: 200_000,
+	}},
+	{Prefix: "o4-mini", Capabilities: genaitypes.Capabilities{
+		Vision: true, Tools: true, JSONSchema: true, StreamingUsage: true, MaxContextTokens: 200_000,
+	}},
+
+	// Ollama / vLLM
+	{Prefix: "llama3", Capabilities: genaitypes.Capabilities{
+		Tools: true, JSONSchema: true, MaxContextTokens: 8192,
+	}},
+	{Prefix: "llama3.1", Capabilities: genaitypes.Capabilities{
+		Tools: true, JSONSchema: true, MaxContextTokens: 131_072,
+	}},
+	{Prefix: "llama3.2-vision", Capabilities: genaitypes.Capabilities{
+		Vision: true, JSONSchema: true, MaxContextTokens: 131_072,
+	}},
+	{Prefix: "qwen2.5", Capabilities: genaitypes.Capabilities{
+		Tools: true, JSONSchema: true, MaxContextTokens: 32_768,
+	}},
+	{Prefix: "qwen3", Capabilities: genaitypes.Capabilities{
+		Tools: true, JSONSchema: true, MaxContextTokens: 40_960,
+	}},
+	{Prefix: "mistral", Capabilities: genaitypes.Capabilities{
+		Tools: true, JSONSchema: true, MaxContextTokens: 32_768,
+	}},
+	{Prefix: "gemma3", Capabilities: genaitypes.Capabilities{
+		Vision: true, JSONSchema: true, MaxContextTokens: 131_072,
+	}},
+	{Prefix: "llava", Capabilities: genaitypes.Capabilities{
+		Vision: true, MaxContextTokens: 4096,
+	}},
+	{Prefix: "deepseek-r1", Capabilities: genaitypes.Capabilities{
+		JSONSchema: true, MaxContextTokens: 131_072,
+	}},
+	{Prefix: "deepseek-chat", Capabilities: genaitypes.Capabilities{
+		Tools: true, StreamingUsage: true, MaxContextTokens: 65_536,
+	}},
+}
+
+// Capabilities reports the features supported by the configured model.
+// Config.Capabilities takes precedence over the built-in table.
+func (m *Model) Capabilities() genaitypes.Capabilities {
+	if m.capabilities != nil {
+		return *m.capabilities
+	}
+
+	if caps, ok := genaitypes.LookupCapabilities(capabilityTable, m.modelName); ok {
+		return caps
+	}
+
+	return defaultCapabilities
+}
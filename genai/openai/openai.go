@@ -10,8 +10,14 @@ import (
 	"iter"
 	"net/http"
 	"sync"
+	"time"
 
+	"github.com/kydenul/k-adk/genai/transcript"
+	genaitypes "github.com/kydenul/k-adk/genai/types"
+	"github.com/kydenul/k-adk/internal/coalesce"
 	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/k-adk/internal/streamwatch"
+	"github.com/kydenul/k-adk/internal/toolresult"
 	"github.com/kydenul/log"
 	"github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
@@ -24,6 +30,12 @@ var (
 	// Ensure Model implements model.LLM
 	_ model.LLM = (*Model)(nil)
 
+	// Ensure Model reports its capabilities
+	_ genaitypes.CapabilityReporter = (*Model)(nil)
+
+	// Ensure Model can count prompt tokens
+	_ genaitypes.TokenCounter = (*Model)(nil)
+
 	ErrNoChoicesInResponse = errors.New("no choices in OpenAI response")
 )
 
@@ -34,8 +46,21 @@ const maxToolCallIDLength = 40
 type Model struct {
 	log.Logger
 
-	client    *openai.Client
-	modelName string
+	client       *openai.Client
+	modelName    string
+	capabilities *genaitypes.Capabilities
+
+	// maxToolResultBytes caps serialized FunctionResponse payloads. Zero means no limit.
+	maxToolResultBytes int
+
+	// transcript records request/response pairs. Nil disables recording.
+	transcript *transcript.Recorder
+
+	// streamIdleTimeout aborts silent streams. Zero disables the watchdog.
+	streamIdleTimeout time.Duration
+
+	// coalesce merges streamed text deltas.
+	coalesce genaitypes.CoalesceConfig
 
 	toolCallMtx sync.RWMutex
 	toolCall    map[string]string
@@ -68,6 +93,32 @@ type Config struct {
 	// Optional. HTTPOptions for custom HTTP headers.
 	HTTPOptions HTTPOptions
 
+	// Optional. Capabilities overrides the built-in capability table for this model.
+	// Useful for fine-tuned or self-hosted models the table does not know about.
+	Capabilities *genaitypes.Capabilities
+
+	// Optional. MaxToolResultBytes caps the size of each serialized tool (function) response.
+	// Larger responses are truncated head+tail and annotated so the model knows content is missing.
+	// If zero, falls back to MaxToolResultTokens; if both are zero, responses are sent verbatim.
+	MaxToolResultBytes int
+
+	// Optional. MaxToolResultTokens is like MaxToolResultBytes but expressed in (approximate) tokens.
+	MaxToolResultTokens int
+
+	// Optional. Transcript records every request/response pair (redacted) to its sink.
+	// If nil, nothing is recorded.
+	Transcript *transcript.Recorder
+
+	// Optional. StreamIdleTimeout aborts a streaming response when no chunk arrives for
+	// this long, with a *genaitypes.StreamIdleError (matching genaitypes.ErrStreamIdle).
+	// If zero, falls back to genaitypes.DefaultStreamIdleTimeout; negative disables it.
+	StreamIdleTimeout time.Duration
+
+	// Optional. Coalesce merges consecutive streamed text deltas into fewer, larger
+	// partial responses. Overridden per call by genaitypes.WithCoalesce. If zero,
+	// every delta is streamed as is.
+	Coalesce genaitypes.CoalesceConfig
+
 	// Optional. Logger for logging. Falls back to `DiscardLog` if nil.
 	Logger log.Logger
 }
@@ -102,9 +153,15 @@ func New(config Config) *Model {
 
 	// Return the OpenAI model
 	return &Model{
-		Logger:    config.Logger,
-		client:    &client,
-		modelName: config.ModelName,
+		Logger:       config.Logger,
+		client:       &client,
+		modelName:    config.ModelName,
+		capabilities: config.Capabilities,
+
+		maxToolResultBytes: toolresult.MaxBytes(config.MaxToolResultBytes, config.MaxToolResultTokens),
+		transcript:         config.Transcript,
+		streamIdleTimeout:  streamwatch.Timeout(config.StreamIdleTimeout),
+		coalesce:           config.Coalesce,
 
 		toolCall: make(map[string]string),
 	}
@@ -123,10 +180,10 @@ func (m *Model) GenerateContent(
 	m.Debugf("GenerateContent called: stream=%v, contents=%d", strem, len(req.Contents))
 
 	if strem {
-		return m.generateStream(ctx, req)
+		return m.transcript.Wrap(ctx, m.modelName, req, strem, m.generateStream(ctx, req))
 	}
 
-	return m.generate(ctx, req)
+	return m.transcript.Wrap(ctx, m.modelName, req, strem, m.generate(ctx, req))
 }
 
 // buildChatCompletionParameters converts the LLMRequest to OpenAI API parameters.
@@ -186,6 +243,9 @@ func applyGenerationConfig(
 	if cfg.TopP != nil {
 		params.TopP = openai.Float(float64(*cfg.TopP))
 	}
+	if cfg.CandidateCount > 1 {
+		params.N = openai.Int(int64(cfg.CandidateCount))
+	}
 
 	// Stop sequences
 	if len(cfg.StopSequences) == 1 {
@@ -213,6 +273,7 @@ func applyGenerationConfig(
 	// Structured output with schema
 	if cfg.ResponseSchema != nil {
 		if schemaMap, err := convertSchema(cfg.ResponseSchema); err == nil {
+			strictSchema(schemaMap)
 			params.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
 				OfJSONSchema: &openai.ResponseFormatJSONSchemaParam{
 					JSONSchema: openai.ResponseFormatJSONSchemaJSONSchemaParam{
@@ -282,6 +343,12 @@ func (m *Model) convertContentToMessages(
 			if err != nil {
 				return nil, fmt.Errorf("failed to marshal function response: %w", err)
 			}
+			if m.maxToolResultBytes > 0 && len(responseJSON) > m.maxToolResultBytes {
+				limited := toolresult.Limit(responseJSON, m.maxToolResultBytes)
+				m.Warnf("tool response truncated: name=%s, bytes=%d->%d",
+					part.FunctionResponse.Name, len(responseJSON), len(limited))
+				responseJSON = limited
+			}
 			normalizedID := m.normalizeToolCallID(part.FunctionResponse.ID)
 			messages = append(messages, openai.ToolMessage(string(responseJSON), normalizedID))
 
@@ -354,16 +421,28 @@ func convertResponse(resp *openai.ChatCompletion) (*model.LLMResponse, error) {
 	}
 
 	choice := resp.Choices[0]
+
+	return &model.LLMResponse{
+		Content:        convertChoiceMessage(choice.Message),
+		CustomMetadata: candidatesMetadata(resp.Choices),
+		UsageMetadata:  convertUsageMetadata(resp.Usage),
+		FinishReason:   convertFinishReason(choice.FinishReason),
+		TurnComplete:   true,
+	}, nil
+}
+
+// convertChoiceMessage converts the message of a single choice into model content.
+func convertChoiceMessage(msg openai.ChatCompletionMessage) *genai.Content {
 	content := &genai.Content{
 		Role:  genai.RoleModel,
 		Parts: []*genai.Part{},
 	}
 
-	if choice.Message.Content != "" {
-		content.Parts = append(content.Parts, &genai.Part{Text: choice.Message.Content})
+	if msg.Content != "" {
+		content.Parts = append(content.Parts, &genai.Part{Text: msg.Content})
 	}
 
-	for _, tc := range choice.Message.ToolCalls {
+	for _, tc := range msg.ToolCalls {
 		content.Parts = append(content.Parts, &genai.Part{
 			FunctionCall: &genai.FunctionCall{
 				ID:   tc.ID,
@@ -373,12 +452,26 @@ func convertResponse(resp *openai.ChatCompletion) (*model.LLMResponse, error) {
 		})
 	}
 
-	return &model.LLMResponse{
-		Content:       content,
-		UsageMetadata: convertUsageMetadata(resp.Usage),
-		FinishReason:  convertFinishReason(choice.FinishReason),
-		TurnComplete:  true,
-	}, nil
+	return content
+}
+
+// candidatesMetadata returns CustomMetadata carrying every choice as a candidate,
+// or nil for a single-choice response.
+func candidatesMetadata(choices []openai.ChatCompletionChoice) map[string]any {
+	if len(choices) <= 1 {
+		return nil
+	}
+
+	candidates := make([]genaitypes.Candidate, 0, len(choices))
+	for _, choice := range choices {
+		candidates = append(candidates, genaitypes.Candidate{
+			Index:        int(choice.Index),
+			Content:      convertChoiceMessage(choice.Message),
+			FinishReason: convertFinishReason(choice.FinishReason),
+		})
+	}
+
+	return map[string]any{genaitypes.CandidatesMetadataKey: candidates}
 }
 
 // convertTools transforms genai tools into OpenAI function tool format.
@@ -467,39 +560,48 @@ func (m *Model) generateStream(
 		}
 
 		m.Debugf("opening stream to OpenAI API")
-		stream := m.client.Chat.Completions.NewStreaming(ctx, params)
+		watchdog, streamCtx := streamwatch.New(ctx, m.streamIdleTimeout)
+		defer watchdog.Stop()
+
+		stream := m.client.Chat.Completions.NewStreaming(streamCtx, params)
 		accum := openai.ChatCompletionAccumulator{}
 
+		deltas := coalesce.New(ctx, m.coalesce)
+
 		chunkCount := 0
 		for stream.Next() {
+			watchdog.Touch()
 			chunk := stream.Current()
 			accum.AddChunk(chunk)
 			chunkCount++
 
-			if len(chunk.Choices) <= 0 || chunk.Choices[0].Delta.Content == "" {
+			// Only candidate 0 is streamed; others are delivered with the final response
+			delta, ok := deltas.Add(firstChoiceDelta(chunk.Choices))
+			if !ok {
 				continue
 			}
 
 			// Yield partial responses as chunks are received
-			if !yield(&model.LLMResponse{
-				Content: &genai.Content{
-					Role:  genai.RoleModel,
-					Parts: []*genai.Part{{Text: chunk.Choices[0].Delta.Content}},
-				},
-				Partial:      true,
-				TurnComplete: false,
-			}, nil) {
+			if !yield(partialResponse(delta), nil) {
 				m.Warnf("streaming response cancelled by caller")
 				return
 			}
 		}
 
 		if err := stream.Err(); err != nil {
+			err = watchdog.Err(err, m.modelName, chunkCount)
 			m.Errorf("stream error: %v", err)
 			yield(nil, err)
 			return
 		}
 
+		if delta, ok := deltas.Flush(); ok {
+			if !yield(partialResponse(delta), nil) {
+				m.Warnf("streaming response cancelled by caller")
+				return
+			}
+		}
+
 		m.Debugf("stream completed: total_chunks=%d", chunkCount)
 
 		// Build and yield final aggregated response
@@ -515,6 +617,28 @@ func (m *Model) generateStream(
 	}
 }
 
+// partialResponse returns the partial response of a streamed text delta.
+func partialResponse(delta string) *model.LLMResponse {
+	return &model.LLMResponse{
+		Content: &genai.Content{
+			Role:  genai.RoleModel,
+			Parts: []*genai.Part{{Text: delta}},
+		},
+		Partial:      true,
+		TurnComplete: false,
+	}
+}
+
+// firstChoiceDelta returns the text delta of choice 0 in a stream chunk.
+func firstChoiceDelta(choices []openai.ChatCompletionChunkChoice) string {
+	for _, choice := range choices {
+		if choice.Index == 0 {
+			return choice.Delta.Content
+		}
+	}
+	return ""
+}
+
 // buildStreamFinalResponse creates the final LLMResponse from accumulated stream chunks.
 func buildStreamFinalResponse(
 	accum *openai.ChatCompletionAccumulator,
@@ -524,35 +648,18 @@ func buildStreamFinalResponse(
 		Parts: []*genai.Part{},
 	}
 
-	if len(accum.Choices) > 0 {
-		choice := accum.Choices[0]
-
-		if choice.Message.Content != "" {
-			content.Parts = append(content.Parts,
-				&genai.Part{Text: choice.Message.Content})
-		}
-
-		for _, tc := range choice.Message.ToolCalls {
-			content.Parts = append(content.Parts, &genai.Part{
-				FunctionCall: &genai.FunctionCall{
-					ID:   tc.ID,
-					Name: tc.Function.Name,
-					Args: parseJSONArgs(tc.Function.Arguments),
-				},
-			})
-		}
-	}
-
 	var finalReason genai.FinishReason
 	if len(accum.Choices) > 0 {
+		content = convertChoiceMessage(accum.Choices[0].Message)
 		finalReason = convertFinishReason(accum.Choices[0].FinishReason)
 	}
 
 	return &model.LLMResponse{
-		Content:       content,
-		UsageMetadata: convertUsageMetadata(accum.Usage),
-		FinishReason:  finalReason,
-		Partial:       false,
-		TurnComplete:  true,
+		Content:        content,
+		CustomMetadata: candidatesMetadata(accum.Choices),
+		UsageMetadata:  convertUsageMetadata(accum.Usage),
+		FinishReason:   finalReason,
+		Partial:        false,
+		TurnComplete:   true,
 	}
 }
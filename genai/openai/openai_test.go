@@ -1,11 +1,16 @@
 package openai
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	genaitypes "github.com/kydenul/k-adk/genai/types"
 	"github.com/openai/openai-go/v3"
 	"google.golang.org/adk/model"
 	"google.golang.org/genai"
@@ -353,6 +358,74 @@ func TestConvertContentToMessages_EmptyParts(t *testing.T) {
 	}
 }
 
+func TestConvertContentToMessages_ToolResultTruncation(t *testing.T) {
+	largeResponse := func() *genai.Content {
+		items := make([]any, 200)
+		for i := range items {
+			items[i] = map[string]any{"id": i, "name": strings.Repeat("x", 20)}
+		}
+		return &genai.Content{
+			Role: "user",
+			Parts: []*genai.Part{
+				{
+					FunctionResponse: &genai.FunctionResponse{
+						ID:   "call_123",
+						Name: "search",
+						Response: map[string]any{
+							"log":   strings.Repeat("a", 5000) + "END",
+							"items": items,
+						},
+					},
+				},
+			},
+		}
+	}
+
+	toolText := func(t *testing.T, m *Model) string {
+		t.Helper()
+		msgs, err := m.convertContentToMessages(largeResponse())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(msgs) != 1 || msgs[0].OfTool == nil {
+			t.Fatal("expected 1 tool message")
+		}
+		return msgs[0].OfTool.Content.OfString.Value
+	}
+
+	t.Run("no limit keeps response verbatim", func(t *testing.T) {
+		text := toolText(t, New(Config{ModelName: "gpt-4o"}))
+		if strings.Contains(text, "omitted") {
+			t.Error("expected untruncated response")
+		}
+	})
+
+	t.Run("byte limit truncates JSON-aware", func(t *testing.T) {
+		text := toolText(t, New(Config{ModelName: "gpt-4o", MaxToolResultBytes: 2000}))
+		if len(text) > 2000 {
+			t.Errorf("expected at most 2000 bytes, got %d", len(text))
+		}
+
+		var out map[string]any
+		if err := json.Unmarshal([]byte(text), &out); err != nil {
+			t.Fatalf("expected valid JSON, got error: %v", err)
+		}
+		if _, ok := out["_truncated"]; !ok {
+			t.Error("expected truncation note")
+		}
+		if log, _ := out["log"].(string); !strings.HasSuffix(log, "END") {
+			t.Error("expected tail of long string to be kept")
+		}
+	})
+
+	t.Run("token limit is converted to bytes", func(t *testing.T) {
+		text := toolText(t, New(Config{ModelName: "gpt-4o", MaxToolResultTokens: 500}))
+		if len(text) > 2000 {
+			t.Errorf("expected at most 2000 bytes, got %d", len(text))
+		}
+	})
+}
+
 // --- buildRoleMessage ---
 
 func TestBuildRoleMessage(t *testing.T) {
@@ -655,6 +728,69 @@ func TestConvertResponse(t *testing.T) {
 			t.Errorf("expected city=NYC, got %v", fc.Args["city"])
 		}
 	})
+
+	t.Run("single choice has no candidates metadata", func(t *testing.T) {
+		resp, err := convertResponse(&openai.ChatCompletion{
+			Choices: []openai.ChatCompletionChoice{
+				{Message: openai.ChatCompletionMessage{Content: "Hello!"}},
+			},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.CustomMetadata != nil {
+			t.Errorf("expected nil CustomMetadata, got %v", resp.CustomMetadata)
+		}
+	})
+
+	t.Run("multiple choices stored as candidates", func(t *testing.T) {
+		resp, err := convertResponse(&openai.ChatCompletion{
+			Choices: []openai.ChatCompletionChoice{
+				{Index: 0, Message: openai.ChatCompletionMessage{Content: "first"}, FinishReason: "stop"},
+				{Index: 1, Message: openai.ChatCompletionMessage{Content: "second"}, FinishReason: "length"},
+			},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Content.Parts[0].Text != "first" {
+			t.Errorf("expected primary content 'first', got %q", resp.Content.Parts[0].Text)
+		}
+
+		candidates := genaitypes.CandidatesFromMetadata(resp.CustomMetadata)
+		if len(candidates) != 2 {
+			t.Fatalf("expected 2 candidates, got %d", len(candidates))
+		}
+		if candidates[1].Index != 1 || candidates[1].Content.Parts[0].Text != "second" {
+			t.Errorf("unexpected candidate 1: %+v", candidates[1])
+		}
+		if candidates[1].FinishReason != genai.FinishReasonMaxTokens {
+			t.Errorf("expected MAX_TOKENS finish reason, got %v", candidates[1].FinishReason)
+		}
+	})
+
+	t.Run("candidates survive a JSON round trip", func(t *testing.T) {
+		resp, _ := convertResponse(&openai.ChatCompletion{
+			Choices: []openai.ChatCompletionChoice{
+				{Index: 0, Message: openai.ChatCompletionMessage{Content: "first"}},
+				{Index: 1, Message: openai.ChatCompletionMessage{Content: "second"}},
+			},
+		})
+
+		data, err := json.Marshal(resp.CustomMetadata)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var decoded map[string]any
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		candidates := genaitypes.CandidatesFromMetadata(decoded)
+		if len(candidates) != 2 || candidates[1].Content.Parts[0].Text != "second" {
+			t.Errorf("unexpected decoded candidates: %+v", candidates)
+		}
+	})
 }
 
 // --- applyGenerationConfig ---
@@ -681,6 +817,16 @@ func TestApplyGenerationConfig(t *testing.T) {
 		}
 	})
 
+	t.Run("candidate count", func(t *testing.T) {
+		params := openai.ChatCompletionNewParams{}
+		applyGenerationConfig(&params, &genai.GenerateContentConfig{
+			CandidateCount: 3,
+		})
+		if params.N.Value != 3 {
+			t.Fatalf("expected N=3, got %d", params.N.Value)
+		}
+	})
+
 	t.Run("stop sequences single", func(t *testing.T) {
 		params := openai.ChatCompletionNewParams{}
 		applyGenerationConfig(&params, &genai.GenerateContentConfig{
@@ -1264,6 +1410,45 @@ func TestConvertSchema(t *testing.T) {
 	})
 }
 
+func TestStrictSchema(t *testing.T) {
+	schema, err := convertSchema(&genai.Schema{
+		Type:     genai.TypeObject,
+		Required: []string{"name"},
+		Properties: map[string]*genai.Schema{
+			"name": {Type: genai.TypeString},
+			"nick": {Type: genai.TypeString},
+			"tags": {Type: genai.TypeArray, Items: &genai.Schema{
+				Type:       genai.TypeObject,
+				Properties: map[string]*genai.Schema{"label": {Type: genai.TypeString}},
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	strictSchema(schema)
+
+	if schema["additionalProperties"] != false {
+		t.Error("expected additionalProperties=false")
+	}
+	if required, _ := schema["required"].([]string); strings.Join(required, ",") != "name,nick,tags" {
+		t.Errorf("expected every property required, got %v", schema["required"])
+	}
+
+	props := schema["properties"].(map[string]any)
+	if typ, _ := props["name"].(map[string]any)["type"].(string); typ != "string" {
+		t.Errorf("expected required property type string, got %v", props["name"])
+	}
+	if typ, _ := props["nick"].(map[string]any)["type"].([]string); strings.Join(typ, ",") != "string,null" {
+		t.Errorf("expected optional property to be nullable, got %v", props["nick"])
+	}
+
+	items := props["tags"].(map[string]any)["items"].(map[string]any)
+	if items["additionalProperties"] != false {
+		t.Error("expected nested object to forbid additional properties")
+	}
+}
+
 // --- HTTPOptions struct ---
 
 func TestHTTPOptions(t *testing.T) {
@@ -1284,6 +1469,98 @@ func TestHTTPOptions(t *testing.T) {
 	})
 }
 
+// --- Capabilities ---
+
+func TestCapabilities(t *testing.T) {
+	t.Run("known model from table", func(t *testing.T) {
+		caps := New(Config{ModelName: "gpt-4o-mini"}).Capabilities()
+		if !caps.Vision || !caps.Tools || !caps.JSONSchema {
+			t.Errorf("expected vision, tools and json schema for gpt-4o-mini, got %+v", caps)
+		}
+		if caps.MaxContextTokens != 128_000 {
+			t.Errorf("expected 128000 context tokens, got %d", caps.MaxContextTokens)
+		}
+	})
+
+	t.Run("longest prefix wins", func(t *testing.T) {
+		caps := New(Config{ModelName: "gpt-4o-audio-preview"}).Capabilities()
+		if !caps.Audio || caps.Vision {
+			t.Errorf("expected audio-only capabilities, got %+v", caps)
+		}
+	})
+
+	t.Run("provider prefix is ignored", func(t *testing.T) {
+		caps := New(Config{ModelName: "openai/gpt-4.1"}).Capabilities()
+		if caps.MaxContextTokens != 1_047_576 {
+			t.Errorf("expected gpt-4.1 context window, got %d", caps.MaxContextTokens)
+		}
+	})
+
+	t.Run("unknown model falls back to defaults", func(t *testing.T) {
+		caps := New(Config{ModelName: "my-custom-model"}).Capabilities()
+		if caps != defaultCapabilities {
+			t.Errorf("expected default capabilities, got %+v", caps)
+		}
+	})
+
+	t.Run("config override takes precedence", func(t *testing.T) {
+		override := &genaitypes.Capabilities{Vision: true, MaxContextTokens: 4096}
+		caps := New(Config{ModelName: "gpt-4o", Capabilities: override}).Capabilities()
+		if caps != *override {
+			t.Errorf("expected override capabilities, got %+v", caps)
+		}
+	})
+
+	t.Run("validate rejects unsupported features", func(t *testing.T) {
+		caps := New(Config{ModelName: "deepseek-r1:8b"}).Capabilities()
+		err := caps.Validate(&genai.GenerateContentConfig{
+			Tools: []*genai.Tool{{FunctionDeclarations: []*genai.FunctionDeclaration{{Name: "f"}}}},
+		})
+		if !errors.Is(err, genaitypes.ErrToolsUnsupported) {
+			t.Errorf("expected ErrToolsUnsupported, got %v", err)
+		}
+
+		err = caps.ValidateRequest(&model.LLMRequest{
+			Contents: []*genai.Content{{
+				Role:  "user",
+				Parts: []*genai.Part{{InlineData: &genai.Blob{MIMEType: "image/png"}}},
+			}},
+		})
+		if !errors.Is(err, genaitypes.ErrVisionUnsupported) {
+			t.Errorf("expected ErrVisionUnsupported, got %v", err)
+		}
+	})
+}
+
+// --- CountTokens ---
+
+func TestCountTokens(t *testing.T) {
+	m := New(Config{ModelName: "gpt-4o"})
+
+	short, err := m.CountTokens(context.Background(), &model.LLMRequest{
+		Contents: []*genai.Content{genai.NewContentFromText("hi", genai.RoleUser)},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	long, err := m.CountTokens(context.Background(), &model.LLMRequest{
+		Contents: []*genai.Content{
+			genai.NewContentFromText(strings.Repeat("hello world ", 100), genai.RoleUser),
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if long <= short {
+		t.Errorf("expected longer prompt to count more tokens: short=%d, long=%d", short, long)
+	}
+	if long < 1200/charsPerToken {
+		t.Errorf("expected at least %d tokens, got %d", 1200/charsPerToken, long)
+	}
+}
+
 // --- Model interface compliance ---
 
 func TestModelInterface(t *testing.T) {
@@ -1369,3 +1646,73 @@ func TestMessageSerialization(t *testing.T) {
 		}
 	})
 }
+
+// --- Streaming watchdog ---
+
+// stallingServer streams the SSE events, then sends nothing until the client gives up.
+func stallingServer(t *testing.T, events ...string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			_, _ = w.Write([]byte(event + "\n\n"))
+		}
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestGenerateStream_IdleTimeout(t *testing.T) {
+	srv := stallingServer(t,
+		`data: {"id":"c1","object":"chat.completion.chunk","created":1,"model":"gpt-4o",`+
+			`"choices":[{"index":0,"delta":{"content":"Hel"},"finish_reason":null}]}`)
+
+	m := New(Config{
+		ModelName:         "gpt-4o",
+		APIKey:            "test",
+		BaseURL:           srv.URL,
+		StreamIdleTimeout: 100 * time.Millisecond,
+	})
+
+	req := &model.LLMRequest{Contents: []*genai.Content{genai.NewContentFromText("hi", genai.RoleUser)}}
+
+	var (
+		partials int
+		lastErr  error
+	)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for resp, err := range m.GenerateContent(context.Background(), req, true) {
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			if resp.Partial {
+				partials++
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream was not aborted")
+	}
+
+	if partials != 1 {
+		t.Errorf("expected 1 partial response before the stall, got %d", partials)
+	}
+
+	var idle *genaitypes.StreamIdleError
+	if !errors.As(lastErr, &idle) || !errors.Is(lastErr, genaitypes.ErrStreamIdle) {
+		t.Fatalf("expected a StreamIdleError, got %v", lastErr)
+	}
+	if idle.Model != "gpt-4o" || idle.Chunks != 1 {
+		t.Errorf("unexpected error details: %+v", idle)
+	}
+}
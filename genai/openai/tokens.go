@@ -0,0 +1,39 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/adk/model"
+)
+
+// charsPerToken is the rough average number of characters per token for OpenAI tokenizers.
+const charsPerToken = 4
+
+// CountTokens estimates the prompt tokens of req.
+//
+// The Chat Completions API has no counting endpoint, so the estimate is derived from the
+// serialized size of the messages and tools that would be sent.
+func (m *Model) CountTokens(_ context.Context, req *model.LLMRequest) (int, error) {
+	params, err := m.buildChatCompletionParameters(req)
+	if err != nil {
+		return 0, err
+	}
+
+	msgJSON, err := json.Marshal(params.Messages)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal messages: %w", err)
+	}
+
+	total := len(msgJSON)
+	if len(params.Tools) > 0 {
+		toolsJSON, err := json.Marshal(params.Tools)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal tools: %w", err)
+		}
+		total += len(toolsJSON)
+	}
+
+	return total / charsPerToken, nil
+}
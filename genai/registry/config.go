@@ -0,0 +1,201 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/bytedance/sonic"
+	"github.com/kydenul/k-adk/genai/anthropic"
+	"github.com/kydenul/k-adk/genai/openai"
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/log"
+	"google.golang.org/adk/model"
+	"google.golang.org/adk/model/gemini"
+	"google.golang.org/genai"
+)
+
+// Built-in providers of DefaultFactories.
+const (
+	ProviderOpenAI     = "openai"
+	ProviderAnthropic  = "anthropic"
+	ProviderOpenRouter = "openrouter"
+	ProviderGemini     = "gemini"
+)
+
+const openRouterBaseURL = "https://openrouter.ai/api/v1"
+
+// ModelSpec describes a model of the configuration: either a concrete model of a
+// provider, or an alias of another name.
+type ModelSpec struct {
+	// Alias makes the name an alias of another name. The other fields are ignored.
+	Alias string `json:"alias,omitempty"`
+
+	// Provider is the factory building the model, e.g. "openai", "anthropic",
+	// "openrouter" or "gemini".
+	Provider string `json:"provider,omitempty"`
+
+	// Model is the name of the model at the provider, e.g. "gemini-2.5-flash".
+	Model string `json:"model,omitempty"`
+
+	// Optional. BaseURL of the provider API.
+	BaseURL string `json:"base_url,omitempty"`
+
+	// Optional. APIKeyEnv is the environment variable holding the API key.
+	// Default: the provider's own variable (OPENAI_API_KEY, ANTHROPIC_API_KEY,
+	// OPENROUTER_API_KEY, GOOGLE_API_KEY).
+	APIKeyEnv string `json:"api_key_env,omitempty"`
+}
+
+// Config maps, per environment, model names to their specs:
+//
+//	{
+//	  "environments": {
+//	    "prod": {
+//	      "fast":  {"provider": "gemini", "model": "gemini-2.5-flash"},
+//	      "smart": {"provider": "anthropic", "model": "claude-sonnet-4-5"},
+//	      "cheap": {"alias": "fast"}
+//	    }
+//	  }
+//	}
+type Config struct {
+	Environments map[string]map[string]ModelSpec `json:"environments"`
+}
+
+// Factory builds the model of a spec.
+type Factory func(ctx context.Context, spec ModelSpec, logger log.Logger) (model.LLM, error)
+
+// BuildOptions configures Config.Build.
+type BuildOptions struct {
+	// Optional. Factories by provider, merged over DefaultFactories.
+	Factories map[string]Factory
+
+	// Optional. Logger for logging, also passed to the factories. Falls back to
+	// `DiscardLog` if nil.
+	Logger log.Logger
+}
+
+// LoadConfig reads a JSON Config file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model registry config: %w", err)
+	}
+
+	var cfg Config
+	if err := sonic.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse model registry config: %w", err)
+	}
+
+	return &cfg, nil
+}
+
+// Build creates the Registry of an environment: it builds the model of every spec
+// with the factory of its provider, and registers the aliases.
+func (c *Config) Build(ctx context.Context, env string, opts BuildOptions) (*Registry, error) {
+	specs, ok := c.Environments[env]
+	if !ok {
+		return nil, fmt.Errorf("unknown model registry environment: %q", env)
+	}
+
+	if opts.Logger == nil {
+		opts.Logger = discardlog.NewDiscardLog()
+	}
+
+	factories := DefaultFactories()
+	for provider, factory := range opts.Factories {
+		factories[provider] = factory
+	}
+
+	reg := New()
+
+	// NOTE: Models first, in a stable order, then the aliases that point to them
+	names := slices.Sorted(maps.Keys(specs))
+
+	for _, name := range names {
+		spec := specs[name]
+		if spec.Alias != "" {
+			continue
+		}
+
+		factory, ok := factories[spec.Provider]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q for model %q", ErrUnknownProvider, spec.Provider, name)
+		}
+
+		m, err := factory(ctx, spec, opts.Logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create model %q: %w", name, err)
+		}
+		if err := reg.Register(name, m); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, name := range names {
+		if alias := specs[name].Alias; alias != "" {
+			if err := reg.Alias(name, alias); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, name := range names {
+		if _, err := reg.Resolve(name); err != nil {
+			return nil, fmt.Errorf("invalid model %q: %w", name, err)
+		}
+	}
+
+	opts.Logger.Infof("model registry built: env=%s, models=%v", env, names)
+
+	return reg, nil
+}
+
+// DefaultFactories returns the factories of the built-in providers.
+func DefaultFactories() map[string]Factory {
+	return map[string]Factory{
+		ProviderOpenAI: func(_ context.Context, spec ModelSpec, logger log.Logger) (model.LLM, error) {
+			return openai.New(openai.Config{
+				ModelName: spec.Model,
+				APIKey:    apiKey(spec, "OPENAI_API_KEY"),
+				BaseURL:   spec.BaseURL,
+				Logger:    logger,
+			}), nil
+		},
+		ProviderOpenRouter: func(_ context.Context, spec ModelSpec, logger log.Logger) (model.LLM, error) {
+			baseURL := spec.BaseURL
+			if baseURL == "" {
+				baseURL = openRouterBaseURL
+			}
+			return openai.New(openai.Config{
+				ModelName: spec.Model,
+				APIKey:    apiKey(spec, "OPENROUTER_API_KEY"),
+				BaseURL:   baseURL,
+				Logger:    logger,
+			}), nil
+		},
+		ProviderAnthropic: func(_ context.Context, spec ModelSpec, logger log.Logger) (model.LLM, error) {
+			return anthropic.New(anthropic.Config{
+				ModelName: spec.Model,
+				APIKey:    apiKey(spec, "ANTHROPIC_API_KEY"),
+				BaseURL:   spec.BaseURL,
+				Logger:    logger,
+			}), nil
+		},
+		ProviderGemini: func(ctx context.Context, spec ModelSpec, _ log.Logger) (model.LLM, error) {
+			return gemini.NewModel(ctx, spec.Model, &genai.ClientConfig{
+				APIKey: apiKey(spec, "GOOGLE_API_KEY"),
+			})
+		},
+	}
+}
+
+// apiKey returns the API key of a spec, from its APIKeyEnv or the default variable.
+func apiKey(spec ModelSpec, defaultEnv string) string {
+	if spec.APIKeyEnv != "" {
+		return os.Getenv(spec.APIKeyEnv)
+	}
+	return os.Getenv(defaultEnv)
+}
@@ -0,0 +1,174 @@
+// Package registry maps logical model names such as "fast", "smart" or "cheap" to
+// concrete model adapters, so switching the models of a fleet, e.g. from Gemini to
+// a Claude or OpenRouter model, is a configuration change rather than a code change.
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"maps"
+	"slices"
+	"sync"
+
+	"google.golang.org/adk/model"
+)
+
+// maxAliasDepth bounds the chains of aliases.
+const maxAliasDepth = 8
+
+var (
+	ErrUnknownModel    = errors.New("unknown model")
+	ErrUnknownProvider = errors.New("unknown model provider")
+	ErrAliasCycle      = errors.New("model alias cycle")
+)
+
+// Registry holds models by name, and aliases pointing to names. It is safe for
+// concurrent use.
+type Registry struct {
+	mu      sync.RWMutex
+	models  map[string]model.LLM
+	aliases map[string]string
+}
+
+// New creates an empty Registry.
+func New() *Registry {
+	return &Registry{
+		models:  make(map[string]model.LLM),
+		aliases: make(map[string]string),
+	}
+}
+
+// Register adds a model under name, replacing the model or alias of that name.
+func (r *Registry) Register(name string, m model.LLM) error {
+	if name == "" || m == nil {
+		return errors.New("model name and model cannot be empty")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.aliases, name)
+	r.models[name] = m
+
+	return nil
+}
+
+// Alias makes alias resolve to target, a model or another alias, replacing the
+// model or alias of that name. Returns ErrAliasCycle if target resolves to alias.
+func (r *Registry) Alias(alias, target string) error {
+	if alias == "" || target == "" {
+		return errors.New("alias and target cannot be empty")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for name, depth := target, 0; depth < maxAliasDepth; depth++ {
+		if name == alias {
+			return fmt.Errorf("%w: %s -> %s", ErrAliasCycle, alias, target)
+		}
+		next, ok := r.aliases[name]
+		if !ok {
+			break
+		}
+		name = next
+	}
+
+	delete(r.models, alias)
+	r.aliases[alias] = target
+
+	return nil
+}
+
+// Resolve returns the model of a name, following aliases.
+func (r *Registry) Resolve(name string) (model.LLM, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for range maxAliasDepth {
+		if m, ok := r.models[name]; ok {
+			return m, nil
+		}
+		target, ok := r.aliases[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownModel, name)
+		}
+		name = target
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrAliasCycle, name)
+}
+
+// Names returns the names of the models and aliases, sorted.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := slices.Collect(maps.Keys(r.models))
+	names = slices.AppendSeq(names, maps.Keys(r.aliases))
+	slices.Sort(names)
+
+	return names
+}
+
+// LLM returns a model.LLM that resolves name on every call, so agents can be
+// configured with a logical name, e.g. Model: reg.LLM("smart"). A name attached
+// to the call context with WithModel takes precedence, for per-request overrides.
+func (r *Registry) LLM(name string) model.LLM {
+	return &routedLLM{registry: r, name: name}
+}
+
+// modelKey is the context key of the per-call model override.
+type modelKey struct{}
+
+// WithModel attaches a model name overriding the name of the LLMs of a Registry to
+// the calls made with ctx.
+func WithModel(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, modelKey{}, name)
+}
+
+// ModelFromContext returns the model name attached by WithModel.
+func ModelFromContext(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(modelKey{}).(string)
+	return name, ok && name != ""
+}
+
+// routedLLM is a model.LLM resolving its model in a Registry on every call.
+type routedLLM struct {
+	registry *Registry
+	name     string
+}
+
+var _ model.LLM = (*routedLLM)(nil)
+
+// Name returns the name of the model the logical name resolves to, or the logical
+// name if it does not resolve.
+func (l *routedLLM) Name() string {
+	if m, err := l.registry.Resolve(l.name); err == nil {
+		return m.Name()
+	}
+	return l.name
+}
+
+func (l *routedLLM) GenerateContent(
+	ctx context.Context,
+	req *model.LLMRequest,
+	stream bool,
+) iter.Seq2[*model.LLMResponse, error] {
+	name := l.name
+	if override, ok := ModelFromContext(ctx); ok {
+		name = override
+	}
+
+	m, err := l.registry.Resolve(name)
+	if err != nil {
+		return func(yield func(*model.LLMResponse, error) bool) { yield(nil, err) }
+	}
+
+	if req != nil {
+		req.Model = m.Name()
+	}
+	return m.GenerateContent(ctx, req, stream)
+}
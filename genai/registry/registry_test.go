@@ -0,0 +1,209 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/kydenul/log"
+	"google.golang.org/adk/model"
+)
+
+// fakeLLM is a model.LLM recording the requested model.
+type fakeLLM struct {
+	name string
+	got  string
+}
+
+func (f *fakeLLM) Name() string { return f.name }
+
+func (f *fakeLLM) GenerateContent(
+	_ context.Context,
+	req *model.LLMRequest,
+	_ bool,
+) iter.Seq2[*model.LLMResponse, error] {
+	f.got = req.Model
+	return func(yield func(*model.LLMResponse, error) bool) {
+		yield(&model.LLMResponse{}, nil)
+	}
+}
+
+func generate(t *testing.T, ctx context.Context, m model.LLM) error {
+	t.Helper()
+
+	var last error
+	for _, err := range m.GenerateContent(ctx, &model.LLMRequest{}, false) {
+		last = err
+	}
+	return last
+}
+
+func TestResolve(t *testing.T) {
+	flash := &fakeLLM{name: "gemini-2.5-flash"}
+	sonnet := &fakeLLM{name: "claude-sonnet-4-5"}
+
+	reg := New()
+	if err := reg.Register("flash", flash); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := reg.Register("sonnet", sonnet); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := reg.Alias("fast", "flash"); err != nil {
+		t.Fatalf("Alias() error = %v", err)
+	}
+	if err := reg.Alias("cheap", "fast"); err != nil {
+		t.Fatalf("Alias() error = %v", err)
+	}
+
+	t.Run("follows aliases", func(t *testing.T) {
+		m, err := reg.Resolve("cheap")
+		if err != nil {
+			t.Fatalf("Resolve() error = %v", err)
+		}
+		if m != flash {
+			t.Errorf("Resolve() = %s, want %s", m.Name(), flash.Name())
+		}
+	})
+
+	t.Run("unknown model", func(t *testing.T) {
+		if _, err := reg.Resolve("smart"); !errors.Is(err, ErrUnknownModel) {
+			t.Errorf("Resolve() error = %v, want ErrUnknownModel", err)
+		}
+	})
+
+	t.Run("rejects cycles", func(t *testing.T) {
+		if err := reg.Alias("flash", "cheap"); !errors.Is(err, ErrAliasCycle) {
+			t.Errorf("Alias() error = %v, want ErrAliasCycle", err)
+		}
+		if _, err := reg.Resolve("cheap"); err != nil {
+			t.Errorf("Resolve() after rejected alias error = %v", err)
+		}
+	})
+
+	t.Run("retargets aliases", func(t *testing.T) {
+		if err := reg.Alias("fast", "sonnet"); err != nil {
+			t.Fatalf("Alias() error = %v", err)
+		}
+		m, _ := reg.Resolve("cheap")
+		if m != sonnet {
+			t.Errorf("Resolve() = %s, want %s", m.Name(), sonnet.Name())
+		}
+	})
+
+	want := []string{"cheap", "fast", "flash", "sonnet"}
+	if names := reg.Names(); !slices.Equal(names, want) {
+		t.Errorf("Names() = %v, want %v", names, want)
+	}
+}
+
+func TestLLM(t *testing.T) {
+	flash := &fakeLLM{name: "gemini-2.5-flash"}
+	sonnet := &fakeLLM{name: "claude-sonnet-4-5"}
+
+	reg := New()
+	_ = reg.Register("fast", flash)
+	_ = reg.Register("smart", sonnet)
+
+	llm := reg.LLM("fast")
+	if llm.Name() != flash.Name() {
+		t.Errorf("Name() = %s, want %s", llm.Name(), flash.Name())
+	}
+
+	t.Run("routes to the named model", func(t *testing.T) {
+		if err := generate(t, context.Background(), llm); err != nil {
+			t.Fatalf("GenerateContent() error = %v", err)
+		}
+		if flash.got != flash.Name() {
+			t.Errorf("request model = %q, want %q", flash.got, flash.Name())
+		}
+	})
+
+	t.Run("context override", func(t *testing.T) {
+		ctx := WithModel(context.Background(), "smart")
+		if err := generate(t, ctx, llm); err != nil {
+			t.Fatalf("GenerateContent() error = %v", err)
+		}
+		if sonnet.got != sonnet.Name() {
+			t.Errorf("request model = %q, want %q", sonnet.got, sonnet.Name())
+		}
+	})
+
+	t.Run("unknown override", func(t *testing.T) {
+		ctx := WithModel(context.Background(), "nope")
+		if err := generate(t, ctx, llm); !errors.Is(err, ErrUnknownModel) {
+			t.Errorf("GenerateContent() error = %v, want ErrUnknownModel", err)
+		}
+	})
+}
+
+func TestConfigBuild(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "models.json")
+	data := `{
+		"environments": {
+			"prod": {
+				"fast":  {"provider": "fake", "model": "fake-fast"},
+				"smart": {"provider": "fake", "model": "fake-smart"},
+				"cheap": {"alias": "fast"}
+			},
+			"broken": {
+				"fast": {"provider": "nope", "model": "x"}
+			},
+			"dangling": {
+				"cheap": {"alias": "fast"}
+			}
+		}
+	}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	opts := BuildOptions{
+		Factories: map[string]Factory{
+			"fake": func(_ context.Context, spec ModelSpec, _ log.Logger) (model.LLM, error) {
+				return &fakeLLM{name: spec.Model}, nil
+			},
+		},
+	}
+
+	t.Run("builds models and aliases", func(t *testing.T) {
+		reg, err := cfg.Build(context.Background(), "prod", opts)
+		if err != nil {
+			t.Fatalf("Build() error = %v", err)
+		}
+		m, err := reg.Resolve("cheap")
+		if err != nil {
+			t.Fatalf("Resolve() error = %v", err)
+		}
+		if m.Name() != "fake-fast" {
+			t.Errorf("Resolve() = %s, want fake-fast", m.Name())
+		}
+	})
+
+	t.Run("unknown provider", func(t *testing.T) {
+		if _, err := cfg.Build(context.Background(), "broken", opts); !errors.Is(err, ErrUnknownProvider) {
+			t.Errorf("Build() error = %v, want ErrUnknownProvider", err)
+		}
+	})
+
+	t.Run("dangling alias", func(t *testing.T) {
+		if _, err := cfg.Build(context.Background(), "dangling", opts); !errors.Is(err, ErrUnknownModel) {
+			t.Errorf("Build() error = %v, want ErrUnknownModel", err)
+		}
+	})
+
+	t.Run("unknown environment", func(t *testing.T) {
+		if _, err := cfg.Build(context.Background(), "staging", opts); err == nil {
+			t.Error("Build() expected error for unknown environment")
+		}
+	})
+}
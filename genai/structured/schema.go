@@ -0,0 +1,264 @@
+package structured
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// ErrUnsupportedType is returned when a Go type has no JSON schema, e.g. a channel,
+// a function, an interface or a recursive struct.
+var ErrUnsupportedType = errors.New("type not supported in response schema")
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// SchemaFor returns the response schema of T, generated from its exported fields
+// the way encoding/json marshals them:
+//
+//   - the `json` tag names a field, "-" skips it;
+//   - fields are required unless they are pointers or tagged omitempty/omitzero;
+//   - the `description` tag describes a field, the `enum` tag lists its allowed
+//     comma-separated values;
+//   - time.Time is a date-time string, []byte a base64 string and
+//     map[string]V an object.
+func SchemaFor[T any]() (*genai.Schema, error) {
+	return schemaOf(reflect.TypeFor[T](), make(map[reflect.Type]bool))
+}
+
+func schemaOf(t reflect.Type, visiting map[reflect.Type]bool) (*genai.Schema, error) {
+	if t == timeType {
+		return &genai.Schema{Type: genai.TypeString, Format: "date-time"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema, err := schemaOf(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		schema.Nullable = genai.Ptr(true)
+		return schema, nil
+
+	case reflect.String:
+		return &genai.Schema{Type: genai.TypeString}, nil
+
+	case reflect.Bool:
+		return &genai.Schema{Type: genai.TypeBoolean}, nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &genai.Schema{Type: genai.TypeInteger}, nil
+
+	case reflect.Float32, reflect.Float64:
+		return &genai.Schema{Type: genai.TypeNumber}, nil
+
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &genai.Schema{Type: genai.TypeString, Format: "byte"}, nil
+		}
+		items, err := schemaOf(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &genai.Schema{Type: genai.TypeArray, Items: items}, nil
+
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("%w: %s has non-string keys", ErrUnsupportedType, t)
+		}
+		return &genai.Schema{Type: genai.TypeObject}, nil
+
+	case reflect.Struct:
+		if visiting[t] {
+			return nil, fmt.Errorf("%w: %s is recursive", ErrUnsupportedType, t)
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		schema := &genai.Schema{Type: genai.TypeObject, Properties: make(map[string]*genai.Schema)}
+		if err := addFields(schema, t, visiting); err != nil {
+			return nil, err
+		}
+		return schema, nil
+
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, t)
+	}
+}
+
+// addFields adds the properties of the fields of struct type t to schema,
+// flattening embedded structs.
+func addFields(schema *genai.Schema, t reflect.Type, visiting map[reflect.Type]bool) error {
+	for field := range t.Fields() {
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if err := addFields(schema, embedded, visiting); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		prop, err := schemaOf(field.Type, visiting)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		if desc := field.Tag.Get("description"); desc != "" {
+			prop.Description = desc
+		}
+		if enum := field.Tag.Get("enum"); enum != "" {
+			prop.Enum = strings.Split(enum, ",")
+		}
+
+		schema.Properties[name] = prop
+		schema.PropertyOrdering = append(schema.PropertyOrdering, name)
+
+		optional := field.Type.Kind() == reflect.Pointer
+		for opt := range strings.SplitSeq(opts, ",") {
+			optional = optional || opt == "omitempty" || opt == "omitzero"
+		}
+		if !optional {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+
+	return nil
+}
+
+// jsonSchema converts a response schema to a JSON Schema document, for models
+// given the schema in their instructions.
+func jsonSchema(schema *genai.Schema) map[string]any {
+	result := make(map[string]any)
+
+	if schema.Type != genai.TypeUnspecified {
+		typ := strings.ToLower(string(schema.Type))
+		if schema.Nullable != nil && *schema.Nullable {
+			result["type"] = []string{typ, "null"}
+		} else {
+			result["type"] = typ
+		}
+	}
+	if schema.Description != "" {
+		result["description"] = schema.Description
+	}
+	if schema.Format != "" {
+		result["format"] = schema.Format
+	}
+	if len(schema.Enum) > 0 {
+		result["enum"] = schema.Enum
+	}
+	if len(schema.Required) > 0 {
+		result["required"] = schema.Required
+	}
+
+	if len(schema.Properties) > 0 {
+		props := make(map[string]any, len(schema.Properties))
+		for name, prop := range schema.Properties {
+			props[name] = jsonSchema(prop)
+		}
+		result["properties"] = props
+	}
+	if schema.Items != nil {
+		result["items"] = jsonSchema(schema.Items)
+	}
+
+	return result
+}
+
+// validate checks a decoded JSON value against the schema and returns an error
+// describing the first mismatch, with its path.
+func validate(schema *genai.Schema, value any, path string) error {
+	if schema == nil {
+		return nil
+	}
+	if value == nil {
+		if schema.Nullable != nil && *schema.Nullable {
+			return nil
+		}
+		return fmt.Errorf("%s: must not be null", path)
+	}
+
+	switch schema.Type {
+	case genai.TypeObject:
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: must be an object", path)
+		}
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+		for name, prop := range schema.Properties {
+			if v, ok := obj[name]; ok {
+				// Strict modes require every property and send the optional
+				// ones as null when left out.
+				if v == nil && !slices.Contains(schema.Required, name) {
+					continue
+				}
+				if err := validate(prop, v, path+"."+name); err != nil {
+					return err
+				}
+			}
+		}
+
+	case genai.TypeArray:
+		items, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s: must be an array", path)
+		}
+		for i, item := range items {
+			if err := validate(schema.Items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+
+	case genai.TypeString:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: must be a string", path)
+		}
+		if len(schema.Enum) > 0 && !slices.Contains(schema.Enum, s) {
+			return fmt.Errorf("%s: must be one of %s", path, strings.Join(schema.Enum, ", "))
+		}
+
+	case genai.TypeInteger:
+		n, ok := value.(float64)
+		if !ok || n != float64(int64(n)) {
+			return fmt.Errorf("%s: must be an integer", path)
+		}
+
+	case genai.TypeNumber:
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s: must be a number", path)
+		}
+
+	case genai.TypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: must be a boolean", path)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,228 @@
+// Package structured generates schema-validated JSON responses parsed into Go
+// structs, uniformly across model adapters.
+package structured
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	genaitypes "github.com/kydenul/k-adk/genai/types"
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/log"
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+var (
+	// ErrInvalidOutput is matched by the errors of responses that are not JSON
+	// matching the schema, after the repair retry.
+	ErrInvalidOutput = errors.New("model output does not match the response schema")
+
+	// ErrEmptyResponse is returned when the model produced no text.
+	ErrEmptyResponse = errors.New("model returned no text")
+)
+
+// Config configures a Generator.
+type Config struct {
+	// Optional. DisableRepair turns off the repair retry: by default an invalid
+	// response is sent back to the model once, with the validation error, to be
+	// corrected.
+	DisableRepair bool
+
+	// Optional. Logger for logging. Falls back to `DiscardLog` if nil.
+	Logger log.Logger
+}
+
+// Generator requests responses of an LLM as JSON matching the schema of T, and
+// parses them into T.
+//
+// The schema is set as the ResponseSchema of the requests when the model supports
+// schema-constrained responses (see genaitypes.Capabilities), and is always stated
+// in the system instruction, so models without native support, e.g. the Anthropic
+// adapter, answer in the same shape.
+type Generator[T any] struct {
+	llm    model.LLM
+	cfg    Config
+	logger log.Logger
+
+	schema      *genai.Schema
+	instruction string
+}
+
+// New creates a Generator of T responses from llm. It fails with ErrUnsupportedType
+// if T has no JSON schema, see SchemaFor.
+func New[T any](llm model.LLM, cfg Config) (*Generator[T], error) {
+	if llm == nil {
+		return nil, errors.New("llm is required")
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = discardlog.NewDiscardLog()
+	}
+
+	schema, err := SchemaFor[T]()
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := sonic.Marshal(jsonSchema(schema))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal response schema: %w", err)
+	}
+
+	return &Generator[T]{
+		llm:    llm,
+		cfg:    cfg,
+		logger: cfg.Logger,
+		schema: schema,
+		instruction: "Respond with only a JSON value matching this JSON schema, " +
+			"without markdown fences or any other text:\n" + string(doc),
+	}, nil
+}
+
+// Schema returns the response schema of T.
+func (g *Generator[T]) Schema() *genai.Schema { return g.schema }
+
+// Apply returns a copy of req requesting a JSON response matching the schema.
+// req is not modified.
+func (g *Generator[T]) Apply(req *model.LLMRequest) *model.LLMRequest {
+	out := &model.LLMRequest{}
+	if req != nil {
+		*out = *req
+	}
+
+	cfg := &genai.GenerateContentConfig{}
+	if out.Config != nil {
+		*cfg = *out.Config
+	}
+	out.Config = cfg
+
+	cfg.ResponseMIMEType = "application/json"
+	cfg.ResponseJsonSchema = nil
+	cfg.ResponseSchema = nil
+	if reporter, ok := g.llm.(genaitypes.CapabilityReporter); !ok || reporter.Capabilities().JSONSchema {
+		cfg.ResponseSchema = g.schema
+	}
+
+	system := &genai.Content{Role: genai.RoleUser}
+	if cfg.SystemInstruction != nil {
+		*system = *cfg.SystemInstruction
+		system.Parts = append([]*genai.Part(nil), cfg.SystemInstruction.Parts...)
+	}
+	system.Parts = append(system.Parts, genai.NewPartFromText(g.instruction))
+	cfg.SystemInstruction = system
+
+	return out
+}
+
+// Generate sends req, applied (see Apply), to the model and returns its response
+// parsed into T. An invalid response is retried once through the model with the
+// validation error, unless Config.DisableRepair is set; if it is still invalid,
+// the error matches ErrInvalidOutput.
+func (g *Generator[T]) Generate(ctx context.Context, req *model.LLMRequest) (T, error) {
+	var zero T
+
+	req = g.Apply(req)
+	resp, err := g.generate(ctx, req)
+	if err != nil {
+		return zero, err
+	}
+
+	result, err := g.Parse(resp.Content)
+	if err == nil || g.cfg.DisableRepair || !errors.Is(err, ErrInvalidOutput) {
+		return result, err
+	}
+
+	g.logger.Warnf("invalid structured response from %s, asking for a repair: %v", g.llm.Name(), err)
+
+	// NOTE: The repair request replays the conversation with the invalid response
+	// and the validation error
+	repair := *req
+	repair.Contents = append(append([]*genai.Content(nil), req.Contents...),
+		resp.Content,
+		genai.NewContentFromText(fmt.Sprintf(
+			"Your response is invalid: %v. Respond again with only the corrected JSON.", err,
+		), genai.RoleUser),
+	)
+
+	resp, err = g.generate(ctx, &repair)
+	if err != nil {
+		return zero, err
+	}
+
+	return g.Parse(resp.Content)
+}
+
+// Parse parses the text of a model response, e.g. the content of an agent event,
+// into T. Markdown code fences around the JSON are ignored. The error matches
+// ErrInvalidOutput if the text is not JSON matching the schema.
+func (g *Generator[T]) Parse(content *genai.Content) (T, error) {
+	var result T
+
+	text := responseText(content)
+	if text == "" {
+		return result, ErrEmptyResponse
+	}
+
+	var value any
+	if err := sonic.UnmarshalString(text, &value); err != nil {
+		return result, fmt.Errorf("%w: not valid JSON: %v", ErrInvalidOutput, err)
+	}
+	if err := validate(g.schema, value, "$"); err != nil {
+		return result, fmt.Errorf("%w: %v", ErrInvalidOutput, err)
+	}
+	if err := sonic.UnmarshalString(text, &result); err != nil {
+		return result, fmt.Errorf("%w: %v", ErrInvalidOutput, err)
+	}
+
+	return result, nil
+}
+
+// generate returns the final response of the model to req.
+func (g *Generator[T]) generate(ctx context.Context, req *model.LLMRequest) (*model.LLMResponse, error) {
+	var final *model.LLMResponse
+	for resp, err := range g.llm.GenerateContent(ctx, req, false) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate content: %w", err)
+		}
+		if resp != nil && !resp.Partial {
+			final = resp
+		}
+	}
+
+	if final == nil || final.Content == nil {
+		if final != nil && final.ErrorCode != "" {
+			return nil, fmt.Errorf("model error %s: %s", final.ErrorCode, final.ErrorMessage)
+		}
+		return nil, ErrEmptyResponse
+	}
+
+	return final, nil
+}
+
+// responseText returns the text of the non-thought parts of content, without
+// markdown code fences.
+func responseText(content *genai.Content) string {
+	if content == nil {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, part := range content.Parts {
+		if part != nil && !part.Thought {
+			b.WriteString(part.Text)
+		}
+	}
+
+	text := strings.TrimSpace(b.String())
+	if rest, ok := strings.CutPrefix(text, "```"); ok {
+		// Drop the language of the fence, e.g. "json"
+		if _, body, found := strings.Cut(rest, "\n"); found {
+			text = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(body), "```"))
+		}
+	}
+
+	return text
+}
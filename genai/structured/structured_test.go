@@ -0,0 +1,209 @@
+package structured
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	genaitypes "github.com/kydenul/k-adk/genai/types"
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+type address struct {
+	City string `json:"city"`
+}
+
+type ticket struct {
+	Title    string    `json:"title" description:"One-line summary"`
+	Priority string    `json:"priority" enum:"low,high"`
+	Points   int       `json:"points"`
+	Tags     []string  `json:"tags,omitempty"`
+	Due      time.Time `json:"due"`
+	Owner    *address  `json:"owner"`
+	internal string
+	Skipped  string `json:"-"`
+	address
+}
+
+// fakeLLM is a model.LLM answering with the queued texts and recording the requests.
+type fakeLLM struct {
+	replies []string
+	reqs    []*model.LLMRequest
+	caps    *genaitypes.Capabilities
+}
+
+func (f *fakeLLM) Name() string { return "fake" }
+
+func (f *fakeLLM) GenerateContent(
+	_ context.Context,
+	req *model.LLMRequest,
+	_ bool,
+) iter.Seq2[*model.LLMResponse, error] {
+	f.reqs = append(f.reqs, req)
+	reply := f.replies[0]
+	f.replies = f.replies[1:]
+
+	return func(yield func(*model.LLMResponse, error) bool) {
+		yield(&model.LLMResponse{Content: genai.NewContentFromText(reply, genai.RoleModel)}, nil)
+	}
+}
+
+// capsLLM is a fakeLLM reporting capabilities.
+type capsLLM struct{ *fakeLLM }
+
+func (c capsLLM) Capabilities() genaitypes.Capabilities { return *c.caps }
+
+func TestSchemaFor(t *testing.T) {
+	schema, err := SchemaFor[ticket]()
+	if err != nil {
+		t.Fatalf("SchemaFor() error = %v", err)
+	}
+
+	want := []string{"title", "priority", "points", "tags", "due", "owner", "city"}
+	if !slices.Equal(schema.PropertyOrdering, want) {
+		t.Errorf("properties = %v, want %v", schema.PropertyOrdering, want)
+	}
+	if want := []string{"title", "priority", "points", "due", "city"}; !slices.Equal(schema.Required, want) {
+		t.Errorf("required = %v, want %v", schema.Required, want)
+	}
+
+	props := schema.Properties
+	if props["title"].Description != "One-line summary" {
+		t.Errorf("title description = %q", props["title"].Description)
+	}
+	if !slices.Equal(props["priority"].Enum, []string{"low", "high"}) {
+		t.Errorf("priority enum = %v", props["priority"].Enum)
+	}
+	if props["points"].Type != genai.TypeInteger || props["tags"].Items.Type != genai.TypeString {
+		t.Error("unexpected points or tags schema")
+	}
+	if props["due"].Format != "date-time" {
+		t.Errorf("due format = %q, want date-time", props["due"].Format)
+	}
+	if owner := props["owner"]; owner.Nullable == nil || !*owner.Nullable || owner.Type != genai.TypeObject {
+		t.Error("expected owner to be a nullable object")
+	}
+
+	type node struct {
+		Next *node `json:"next"`
+	}
+	if _, err := SchemaFor[node](); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("SchemaFor() recursive error = %v, want ErrUnsupportedType", err)
+	}
+	if _, err := SchemaFor[struct{ C chan int }](); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("SchemaFor() channel error = %v, want ErrUnsupportedType", err)
+	}
+}
+
+const validTicket = `{"title":"Crash","priority":"high","points":3,"due":"2026-01-02T00:00:00Z",` +
+	`"owner":null,"city":"Paris"}`
+
+func TestGenerate(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("parses fenced output", func(t *testing.T) {
+		llm := &fakeLLM{replies: []string{"```json\n" + validTicket + "\n```"}}
+		gen, err := New[ticket](llm, Config{})
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+
+		got, err := gen.Generate(ctx, &model.LLMRequest{
+			Config: &genai.GenerateContentConfig{SystemInstruction: genai.NewContentFromText("Triage", genai.RoleUser)},
+		})
+		if err != nil {
+			t.Fatalf("Generate() error = %v", err)
+		}
+		if got.Title != "Crash" || got.Points != 3 || got.City != "Paris" || got.Owner != nil {
+			t.Errorf("Generate() = %+v", got)
+		}
+
+		cfg := llm.reqs[0].Config
+		if cfg.ResponseSchema != gen.Schema() || cfg.ResponseMIMEType != "application/json" {
+			t.Error("expected the response schema to be set")
+		}
+		if len(cfg.SystemInstruction.Parts) != 2 || !strings.Contains(cfg.SystemInstruction.Parts[1].Text, `"priority"`) {
+			t.Error("expected the schema to be stated in the system instruction")
+		}
+	})
+
+	t.Run("accepts null optional properties", func(t *testing.T) {
+		// OpenAI strict mode requires every property and sends the left-out
+		// optional ones as null.
+		llm := &fakeLLM{replies: []string{strings.Replace(validTicket, `"owner"`, `"tags":null,"owner"`, 1)}}
+		gen, _ := New[ticket](llm, Config{DisableRepair: true})
+
+		got, err := gen.Generate(ctx, &model.LLMRequest{})
+		if err != nil {
+			t.Fatalf("Generate() error = %v", err)
+		}
+		if got.Tags != nil {
+			t.Errorf("tags = %v, want nil", got.Tags)
+		}
+	})
+
+	t.Run("rejects null required properties", func(t *testing.T) {
+		llm := &fakeLLM{replies: []string{strings.Replace(validTicket, `"points":3`, `"points":null`, 1)}}
+		gen, _ := New[ticket](llm, Config{DisableRepair: true})
+
+		if _, err := gen.Generate(ctx, &model.LLMRequest{}); !errors.Is(err, ErrInvalidOutput) {
+			t.Errorf("Generate() error = %v, want ErrInvalidOutput", err)
+		}
+	})
+
+	t.Run("repairs invalid output", func(t *testing.T) {
+		llm := &fakeLLM{replies: []string{strings.Replace(validTicket, "high", "urgent", 1), validTicket}}
+		gen, _ := New[ticket](llm, Config{})
+
+		got, err := gen.Generate(ctx, &model.LLMRequest{})
+		if err != nil {
+			t.Fatalf("Generate() error = %v", err)
+		}
+		if got.Priority != "high" {
+			t.Errorf("priority = %q, want high", got.Priority)
+		}
+
+		repair := llm.reqs[1].Contents
+		if len(repair) != 2 || !strings.Contains(repair[1].Parts[0].Text, "priority") {
+			t.Errorf("repair request contents = %d, want the invalid response and the error", len(repair))
+		}
+	})
+
+	t.Run("fails after the repair", func(t *testing.T) {
+		llm := &fakeLLM{replies: []string{"not json", `{"title":1}`}}
+		gen, _ := New[ticket](llm, Config{})
+
+		if _, err := gen.Generate(ctx, &model.LLMRequest{}); !errors.Is(err, ErrInvalidOutput) {
+			t.Errorf("Generate() error = %v, want ErrInvalidOutput", err)
+		}
+	})
+
+	t.Run("repair disabled", func(t *testing.T) {
+		llm := &fakeLLM{replies: []string{"not json"}}
+		gen, _ := New[ticket](llm, Config{DisableRepair: true})
+
+		if _, err := gen.Generate(ctx, &model.LLMRequest{}); !errors.Is(err, ErrInvalidOutput) {
+			t.Errorf("Generate() error = %v, want ErrInvalidOutput", err)
+		}
+		if len(llm.reqs) != 1 {
+			t.Errorf("requests = %d, want 1", len(llm.reqs))
+		}
+	})
+
+	t.Run("model without schema support", func(t *testing.T) {
+		llm := capsLLM{&fakeLLM{replies: []string{validTicket}, caps: &genaitypes.Capabilities{Tools: true}}}
+		gen, _ := New[ticket](llm, Config{})
+
+		if _, err := gen.Generate(ctx, &model.LLMRequest{}); err != nil {
+			t.Fatalf("Generate() error = %v", err)
+		}
+		if cfg := llm.reqs[0].Config; cfg.ResponseSchema != nil || len(cfg.SystemInstruction.Parts) != 1 {
+			t.Error("expected the schema in the system instruction only")
+		}
+	})
+}
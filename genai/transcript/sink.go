@@ -0,0 +1,176 @@
+package transcript
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/global"
+
+	_ "github.com/lib/pq" // PostgreSQL driver
+)
+
+// otelScopeName is the instrumentation scope of log records emitted by OTLPSink.
+const otelScopeName = "github.com/kydenul/k-adk/genai/transcript"
+
+var (
+	_ Sink = (*FileSink)(nil)
+	_ Sink = (*PostgresSink)(nil)
+	_ Sink = (*OTLPSink)(nil)
+)
+
+// FileSink appends records as JSON lines to a file.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// NewFileSink opens (or creates) path for appending JSONL records.
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open transcript file: %w", err)
+	}
+
+	return &FileSink{file: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Write appends rec as a single JSON line.
+func (s *FileSink) Write(_ context.Context, rec *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.enc.Encode(rec); err != nil {
+		return fmt.Errorf("failed to write transcript record: %w", err)
+	}
+	return nil
+}
+
+// Close closes the underlying file.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.file.Close()
+}
+
+// PostgresSink stores records in the llm_transcripts table.
+type PostgresSink struct {
+	db *sql.DB
+}
+
+// NewPostgresSink connects to PostgreSQL and creates the llm_transcripts table if needed.
+func NewPostgresSink(ctx context.Context, connStr string) (*PostgresSink, error) {
+	if connStr == "" {
+		return nil, errors.New("postgres connection string cannot be empty")
+	}
+
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	const schema = `
+		CREATE TABLE IF NOT EXISTS llm_transcripts (
+			id BIGSERIAL PRIMARY KEY,
+			invocation_id VARCHAR(255) NOT NULL DEFAULT '',
+			model VARCHAR(255) NOT NULL,
+			stream BOOLEAN NOT NULL DEFAULT FALSE,
+			started_at TIMESTAMPTZ NOT NULL,
+			duration_ms BIGINT NOT NULL DEFAULT 0,
+			record JSONB NOT NULL,
+			error TEXT NOT NULL DEFAULT ''
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_llm_transcripts_invocation ON llm_transcripts(invocation_id);
+		CREATE INDEX IF NOT EXISTS idx_llm_transcripts_started_at ON llm_transcripts(started_at);
+	`
+
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to create llm_transcripts table: %w", err)
+	}
+
+	return &PostgresSink{db: db}, nil
+}
+
+// Write inserts rec as a row.
+func (s *PostgresSink) Write(ctx context.Context, rec *Record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal transcript record: %w", err)
+	}
+
+	const query = `
+		INSERT INTO llm_transcripts (invocation_id, model, stream, started_at, duration_ms, record, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	if _, err := s.db.ExecContext(ctx, query,
+		rec.InvocationID, rec.Model, rec.Stream, rec.StartedAt, rec.DurationMs, data, rec.Error,
+	); err != nil {
+		return fmt.Errorf("failed to insert transcript record: %w", err)
+	}
+
+	return nil
+}
+
+// Close closes the database connection.
+func (s *PostgresSink) Close() error { return s.db.Close() }
+
+// OTLPSink emits records as OpenTelemetry log records. Export (e.g. OTLP over gRPC/HTTP)
+// is handled by the LoggerProvider configured by the application.
+type OTLPSink struct {
+	logger otellog.Logger
+}
+
+// NewOTLPSink creates a sink using the global LoggerProvider.
+func NewOTLPSink() *OTLPSink {
+	return NewOTLPSinkWithProvider(global.GetLoggerProvider())
+}
+
+// NewOTLPSinkWithProvider creates a sink using provider.
+func NewOTLPSinkWithProvider(provider otellog.LoggerProvider) *OTLPSink {
+	return &OTLPSink{logger: provider.Logger(otelScopeName)}
+}
+
+// Write emits rec with its JSON encoding as the body.
+func (s *OTLPSink) Write(ctx context.Context, rec *Record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal transcript record: %w", err)
+	}
+
+	var lr otellog.Record
+	lr.SetEventName("llm.transcript")
+	lr.SetTimestamp(rec.StartedAt)
+	lr.SetSeverity(otellog.SeverityInfo)
+	if rec.Error != "" {
+		lr.SetSeverity(otellog.SeverityError)
+	}
+	lr.SetBody(otellog.StringValue(string(data)))
+	lr.AddAttributes(
+		otellog.String("invocation_id", rec.InvocationID),
+		otellog.String("model", rec.Model),
+		otellog.Bool("stream", rec.Stream),
+		otellog.Int64("duration_ms", rec.DurationMs),
+	)
+
+	s.logger.Emit(ctx, lr)
+
+	return nil
+}
+
+// Close is a no-op; the LoggerProvider is owned by the application.
+func (s *OTLPSink) Close() error { return nil }
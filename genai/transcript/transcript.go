@@ -0,0 +1,271 @@
+// Package transcript records full LLM request/response pairs of the model adapters
+// to a pluggable Sink, for prompt debugging and offline evaluation.
+package transcript
+
+import (
+	"context"
+	"iter"
+	"regexp"
+	"time"
+
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/log"
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+// redactedPlaceholder replaces text matched by a pattern Redactor.
+const redactedPlaceholder = "[REDACTED]"
+
+// Record is a single LLM request/response pair.
+type Record struct {
+	// InvocationID of the agent invocation that issued the request, if known.
+	InvocationID string `json:"invocation_id"`
+	Model        string `json:"model"`
+	Stream       bool   `json:"stream"`
+
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+
+	// Request holds the contents and generation config that were sent.
+	// Inline binary data is omitted; only its MIME type is kept.
+	Contents []*genai.Content             `json:"contents"`
+	Config   *genai.GenerateContentConfig `json:"config,omitempty"`
+
+	// Response is the final (non-partial) response, or the last partial one
+	// if the stream ended early.
+	Response *model.LLMResponse `json:"response,omitempty"`
+	Error    string             `json:"error,omitempty"`
+}
+
+// Sink persists transcript records.
+type Sink interface {
+	Write(ctx context.Context, rec *Record) error
+	Close() error
+}
+
+// Redactor masks sensitive text before a record reaches the Sink.
+// It is applied to every text part, function call argument and function response
+// string value of the request and response.
+type Redactor interface {
+	Redact(text string) string
+}
+
+// RedactorFunc adapts a function to the Redactor interface.
+type RedactorFunc func(text string) string
+
+// Redact calls f(text).
+func (f RedactorFunc) Redact(text string) string { return f(text) }
+
+// NewPatternRedactor returns a Redactor that replaces every match of patterns with "[REDACTED]".
+func NewPatternRedactor(patterns ...*regexp.Regexp) Redactor {
+	return RedactorFunc(func(text string) string {
+		for _, p := range patterns {
+			text = p.ReplaceAllString(text, redactedPlaceholder)
+		}
+		return text
+	})
+}
+
+// Config is the configuration for creating a Recorder.
+type Config struct {
+	// Sink receives the recorded request/response pairs.
+	Sink Sink
+
+	// Optional. Redactor masks sensitive text. If nil, text is recorded verbatim.
+	Redactor Redactor
+
+	// Optional. Logger for logging. Falls back to `DiscardLog` if nil.
+	Logger log.Logger
+}
+
+// Recorder captures request/response pairs from a model adapter.
+//
+// Pass it to an adapter via its Config.Transcript field:
+//
+//	rec := transcript.New(transcript.Config{Sink: sink})
+//	llm := openai.New(openai.Config{ModelName: "gpt-4o", Transcript: rec})
+type Recorder struct {
+	log.Logger
+
+	sink     Sink
+	redactor Redactor
+}
+
+// New creates a new Recorder with the specified configuration.
+func New(cfg Config) *Recorder {
+	if cfg.Logger == nil {
+		cfg.Logger = discardlog.NewDiscardLog()
+	}
+
+	return &Recorder{
+		Logger:   cfg.Logger,
+		sink:     cfg.Sink,
+		redactor: cfg.Redactor,
+	}
+}
+
+// Wrap returns seq unchanged for the caller, recording req and the final response
+// once the sequence has been consumed. Sink errors are logged, never returned.
+func (r *Recorder) Wrap(
+	ctx context.Context,
+	modelName string,
+	req *model.LLMRequest,
+	stream bool,
+	seq iter.Seq2[*model.LLMResponse, error],
+) iter.Seq2[*model.LLMResponse, error] {
+	if r == nil || r.sink == nil {
+		return seq
+	}
+
+	return func(yield func(*model.LLMResponse, error) bool) {
+		rec := &Record{
+			InvocationID: invocationID(ctx),
+			Model:        modelName,
+			Stream:       stream,
+			StartedAt:    time.Now(),
+		}
+		if req != nil {
+			rec.Config = r.copyConfig(req.Config)
+			rec.Contents = r.copyContents(req.Contents)
+		}
+
+		defer func() {
+			rec.DurationMs = time.Since(rec.StartedAt).Milliseconds()
+			r.write(ctx, rec)
+		}()
+
+		for resp, err := range seq {
+			switch {
+			case err != nil:
+				rec.Error = err.Error()
+
+			case resp != nil && (rec.Response == nil || rec.Response.Partial || !resp.Partial):
+				rec.Response = r.copyResponse(resp)
+			}
+
+			if !yield(resp, err) {
+				return
+			}
+		}
+	}
+}
+
+// write sends rec to the sink, detached from the request's cancellation.
+func (r *Recorder) write(ctx context.Context, rec *Record) {
+	if err := r.sink.Write(context.WithoutCancel(ctx), rec); err != nil {
+		r.Errorf("failed to write transcript record: invocation=%s, err=%v", rec.InvocationID, err)
+	}
+}
+
+// invocationID extracts the invocation ID from an agent.InvocationContext, if ctx is one.
+func invocationID(ctx context.Context) string {
+	if ic, ok := ctx.(interface{ InvocationID() string }); ok {
+		return ic.InvocationID()
+	}
+	return ""
+}
+
+// copyResponse copies resp with its content redacted.
+func (r *Recorder) copyResponse(resp *model.LLMResponse) *model.LLMResponse {
+	cp := *resp
+	cp.Content = r.copyContent(resp.Content)
+	return &cp
+}
+
+// copyConfig copies cfg with its system instruction redacted.
+func (r *Recorder) copyConfig(cfg *genai.GenerateContentConfig) *genai.GenerateContentConfig {
+	if cfg == nil {
+		return nil
+	}
+
+	cp := *cfg
+	cp.SystemInstruction = r.copyContent(cfg.SystemInstruction)
+	return &cp
+}
+
+// copyContents copies contents with text redacted and inline data stripped,
+// leaving the live request untouched.
+func (r *Recorder) copyContents(contents []*genai.Content) []*genai.Content {
+	out := make([]*genai.Content, 0, len(contents))
+	for _, c := range contents {
+		out = append(out, r.copyContent(c))
+	}
+	return out
+}
+
+func (r *Recorder) copyContent(c *genai.Content) *genai.Content {
+	if c == nil {
+		return nil
+	}
+
+	out := &genai.Content{Role: c.Role, Parts: make([]*genai.Part, 0, len(c.Parts))}
+	for _, p := range c.Parts {
+		if p == nil {
+			continue
+		}
+
+		cp := *p
+		cp.Text = r.redact(p.Text)
+
+		if p.InlineData != nil {
+			cp.InlineData = &genai.Blob{MIMEType: p.InlineData.MIMEType, DisplayName: p.InlineData.DisplayName}
+		}
+
+		if p.FunctionCall != nil {
+			fc := *p.FunctionCall
+			fc.Args = r.redactMap(p.FunctionCall.Args)
+			cp.FunctionCall = &fc
+		}
+
+		if p.FunctionResponse != nil {
+			fr := *p.FunctionResponse
+			fr.Response = r.redactMap(p.FunctionResponse.Response)
+			cp.FunctionResponse = &fr
+		}
+
+		out.Parts = append(out.Parts, &cp)
+	}
+
+	return out
+}
+
+func (r *Recorder) redact(text string) string {
+	if r.redactor == nil || text == "" {
+		return text
+	}
+	return r.redactor.Redact(text)
+}
+
+// redactMap deep-copies m, redacting all string values.
+func (r *Recorder) redactMap(m map[string]any) map[string]any {
+	if r.redactor == nil || m == nil {
+		return m
+	}
+
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		out[k] = r.redactValue(v)
+	}
+	return out
+}
+
+func (r *Recorder) redactValue(v any) any {
+	switch val := v.(type) {
+	case string:
+		return r.redact(val)
+
+	case map[string]any:
+		return r.redactMap(val)
+
+	case []any:
+		out := make([]any, len(val))
+		for i := range val {
+			out[i] = r.redactValue(val[i])
+		}
+		return out
+
+	default:
+		return v
+	}
+}
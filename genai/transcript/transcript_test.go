@@ -0,0 +1,134 @@
+package transcript
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"regexp"
+	"testing"
+
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+type memorySink struct {
+	records []*Record
+}
+
+func (s *memorySink) Write(_ context.Context, rec *Record) error {
+	s.records = append(s.records, rec)
+	return nil
+}
+
+func (s *memorySink) Close() error { return nil }
+
+func responses(items ...*model.LLMResponse) iter.Seq2[*model.LLMResponse, error] {
+	return func(yield func(*model.LLMResponse, error) bool) {
+		for _, item := range items {
+			if !yield(item, nil) {
+				return
+			}
+		}
+	}
+}
+
+// --- Recorder.Wrap ---
+
+func TestWrap(t *testing.T) {
+	t.Run("nil recorder returns seq unchanged", func(t *testing.T) {
+		var r *Recorder
+		seq := responses(&model.LLMResponse{})
+
+		count := 0
+		for range r.Wrap(context.Background(), "m", &model.LLMRequest{}, false, seq) {
+			count++
+		}
+		if count != 1 {
+			t.Errorf("expected 1 response, got %d", count)
+		}
+	})
+
+	t.Run("records final response of a stream", func(t *testing.T) {
+		sink := &memorySink{}
+		r := New(Config{Sink: sink})
+
+		req := &model.LLMRequest{Contents: []*genai.Content{genai.NewContentFromText("hi", genai.RoleUser)}}
+		seq := responses(
+			&model.LLMResponse{Content: genai.NewContentFromText("he", genai.RoleModel), Partial: true},
+			&model.LLMResponse{Content: genai.NewContentFromText("hello", genai.RoleModel)},
+		)
+
+		for range r.Wrap(context.Background(), "gpt-4o", req, true, seq) {
+		}
+
+		if len(sink.records) != 1 {
+			t.Fatalf("expected 1 record, got %d", len(sink.records))
+		}
+		rec := sink.records[0]
+		if rec.Model != "gpt-4o" || !rec.Stream {
+			t.Errorf("unexpected record header: model=%q, stream=%v", rec.Model, rec.Stream)
+		}
+		if rec.Response == nil || rec.Response.Content.Parts[0].Text != "hello" {
+			t.Errorf("expected final response 'hello', got %+v", rec.Response)
+		}
+		if len(rec.Contents) != 1 || rec.Contents[0].Parts[0].Text != "hi" {
+			t.Errorf("expected request contents to be recorded, got %+v", rec.Contents)
+		}
+	})
+
+	t.Run("records errors", func(t *testing.T) {
+		sink := &memorySink{}
+		r := New(Config{Sink: sink})
+
+		seq := func(yield func(*model.LLMResponse, error) bool) {
+			yield(nil, errors.New("boom"))
+		}
+
+		for range r.Wrap(context.Background(), "m", &model.LLMRequest{}, false, seq) {
+		}
+
+		if len(sink.records) != 1 || sink.records[0].Error != "boom" {
+			t.Fatalf("expected error to be recorded, got %+v", sink.records)
+		}
+	})
+
+	t.Run("redacts without mutating the request", func(t *testing.T) {
+		sink := &memorySink{}
+		r := New(Config{
+			Sink:     sink,
+			Redactor: NewPatternRedactor(regexp.MustCompile(`sk-\w+`)),
+		})
+
+		req := &model.LLMRequest{Contents: []*genai.Content{{
+			Role: genai.RoleUser,
+			Parts: []*genai.Part{
+				{Text: "key is sk-secret"},
+				{FunctionCall: &genai.FunctionCall{Name: "f", Args: map[string]any{
+					"nested": map[string]any{"key": "sk-secret"},
+				}}},
+				{InlineData: &genai.Blob{MIMEType: "image/png", Data: []byte{1, 2, 3}}},
+			},
+		}}}
+
+		for range r.Wrap(context.Background(), "m", req, false, responses()) {
+		}
+
+		parts := sink.records[0].Contents[0].Parts
+		if parts[0].Text != "key is [REDACTED]" {
+			t.Errorf("expected redacted text, got %q", parts[0].Text)
+		}
+		if got := parts[1].FunctionCall.Args["nested"].(map[string]any)["key"]; got != "[REDACTED]" {
+			t.Errorf("expected redacted function arg, got %v", got)
+		}
+		if parts[2].InlineData.Data != nil || parts[2].InlineData.MIMEType != "image/png" {
+			t.Errorf("expected inline data stripped to MIME type, got %+v", parts[2].InlineData)
+		}
+
+		if req.Contents[0].Parts[0].Text != "key is sk-secret" {
+			t.Error("request text was mutated")
+		}
+		if req.Contents[0].Parts[2].InlineData.Data == nil {
+			t.Error("request inline data was mutated")
+		}
+	})
+}
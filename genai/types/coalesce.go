@@ -0,0 +1,36 @@
+package genaitypes
+
+import (
+	"context"
+	"time"
+)
+
+// CoalesceConfig merges the consecutive text deltas of a streaming response into
+// fewer, larger partial responses. Held text is released once the window since its
+// first delta has elapsed or it reaches MaxBytes, when the next delta arrives, and
+// always before the final response. The zero value streams every delta as is.
+type CoalesceConfig struct {
+	// Optional. Window is how long text deltas may be held.
+	Window time.Duration
+
+	// Optional. MaxBytes is the size of held text that is released immediately.
+	MaxBytes int
+}
+
+// Enabled reports whether the config coalesces deltas.
+func (c CoalesceConfig) Enabled() bool { return c.Window > 0 || c.MaxBytes > 0 }
+
+// coalesceKey is the context key of the per-call coalescing config.
+type coalesceKey struct{}
+
+// WithCoalesce attaches a coalescing config overriding the adapters' own to the
+// calls made with ctx, e.g. per server request. A zero config disables coalescing.
+func WithCoalesce(ctx context.Context, cfg CoalesceConfig) context.Context {
+	return context.WithValue(ctx, coalesceKey{}, cfg)
+}
+
+// CoalesceFromContext returns the coalescing config attached by WithCoalesce.
+func CoalesceFromContext(ctx context.Context) (CoalesceConfig, bool) {
+	cfg, ok := ctx.Value(coalesceKey{}).(CoalesceConfig)
+	return cfg, ok
+}
@@ -0,0 +1,38 @@
+package genaitypes
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultStreamIdleTimeout is the default time a streaming response may stay silent
+// (no chunk, including keep-alive pings) before the adapter aborts it.
+const DefaultStreamIdleTimeout = 2 * time.Minute
+
+// ErrStreamIdle is matched by the errors of streams aborted for being idle.
+var ErrStreamIdle = errors.New("model stream idle timeout")
+
+// StreamIdleError reports a streaming response aborted because the provider sent
+// nothing for Idle. The request can be retried, or served by another model.
+type StreamIdleError struct {
+	// Model is the name of the model that stopped responding.
+	Model string
+
+	// Idle is the inactivity timeout that was exceeded.
+	Idle time.Duration
+
+	// Chunks is the number of chunks received before the stream stalled.
+	Chunks int
+}
+
+func (e *StreamIdleError) Error() string {
+	return fmt.Sprintf("%v: model %s sent nothing for %v after %d chunks",
+		ErrStreamIdle, e.Model, e.Idle, e.Chunks)
+}
+
+// Is reports whether target is ErrStreamIdle.
+func (e *StreamIdleError) Is(target error) bool { return target == ErrStreamIdle }
+
+// Timeout reports true, the error is a timeout.
+func (e *StreamIdleError) Timeout() bool { return true }
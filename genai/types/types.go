@@ -0,0 +1,173 @@
+package genaitypes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+var (
+	ErrVisionUnsupported     = errors.New("model does not support image input")
+	ErrAudioUnsupported      = errors.New("model does not support audio input")
+	ErrToolsUnsupported      = errors.New("model does not support tool calling")
+	ErrJSONSchemaUnsupported = errors.New("model does not support JSON schema responses")
+)
+
+// Capabilities describes the features supported by a model behind an adapter.
+type Capabilities struct {
+	// Vision reports whether image inputs are accepted.
+	Vision bool `json:"vision"`
+	// Audio reports whether audio inputs are accepted.
+	Audio bool `json:"audio"`
+	// Tools reports whether function/tool calling is supported.
+	Tools bool `json:"tools"`
+	// JSONSchema reports whether schema-constrained responses are supported.
+	JSONSchema bool `json:"json_schema"`
+	// StreamingUsage reports whether token usage is reported for streaming responses.
+	StreamingUsage bool `json:"streaming_usage"`
+	// MaxContextTokens is the context window size in tokens. Zero means unknown.
+	MaxContextTokens int `json:"max_context_tokens"`
+}
+
+// CapabilityReporter is implemented by model adapters that can describe their features.
+type CapabilityReporter interface {
+	Capabilities() Capabilities
+}
+
+// TokenCounter is implemented by model adapters that can count the prompt tokens of a request.
+type TokenCounter interface {
+	CountTokens(ctx context.Context, req *model.LLMRequest) (int, error)
+}
+
+// CapabilityEntry maps a model name prefix to its capabilities.
+type CapabilityEntry struct {
+	Prefix       string
+	Capabilities Capabilities
+}
+
+// LookupCapabilities returns the capabilities of the entry with the longest prefix
+// matching modelName. Provider prefixes such as "openai/" (OpenRouter style) are ignored.
+// The second return value is false if no entry matches.
+func LookupCapabilities(table []CapabilityEntry, modelName string) (Capabilities, bool) {
+	name := strings.ToLower(modelName)
+	if idx := strings.LastIndex(name, "/"); idx >= 0 {
+		name = name[idx+1:]
+	}
+
+	var (
+		best    Capabilities
+		bestLen = -1
+	)
+	for _, entry := range table {
+		if strings.HasPrefix(name, entry.Prefix) && len(entry.Prefix) > bestLen {
+			best = entry.Capabilities
+			bestLen = len(entry.Prefix)
+		}
+	}
+
+	return best, bestLen >= 0
+}
+
+// Validate checks the generation config against the capabilities and returns an error
+// describing the first unsupported feature, or nil if the config can be served.
+func (c Capabilities) Validate(cfg *genai.GenerateContentConfig) error {
+	if cfg == nil {
+		return nil
+	}
+
+	if !c.Tools && hasFunctionDeclarations(cfg.Tools) {
+		return ErrToolsUnsupported
+	}
+
+	if !c.JSONSchema && (cfg.ResponseSchema != nil || cfg.ResponseJsonSchema != nil) {
+		return ErrJSONSchemaUnsupported
+	}
+
+	return nil
+}
+
+// ValidateRequest checks the request config and contents (inline media) against the capabilities.
+func (c Capabilities) ValidateRequest(req *model.LLMRequest) error {
+	if req == nil {
+		return nil
+	}
+
+	if err := c.Validate(req.Config); err != nil {
+		return err
+	}
+
+	for _, content := range req.Contents {
+		if content == nil {
+			continue
+		}
+
+		for _, part := range content.Parts {
+			if part == nil || part.InlineData == nil {
+				continue
+			}
+
+			mime := part.InlineData.MIMEType
+			switch {
+			case strings.HasPrefix(mime, "image/") && !c.Vision:
+				return fmt.Errorf("%w: %s", ErrVisionUnsupported, mime)
+
+			case strings.HasPrefix(mime, "audio/") && !c.Audio:
+				return fmt.Errorf("%w: %s", ErrAudioUnsupported, mime)
+			}
+		}
+	}
+
+	return nil
+}
+
+// hasFunctionDeclarations reports whether any tool declares at least one function.
+func hasFunctionDeclarations(tools []*genai.Tool) bool {
+	for _, t := range tools {
+		if t != nil && len(t.FunctionDeclarations) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// CandidatesMetadataKey is the LLMResponse.CustomMetadata key under which adapters
+// store every candidate when more than one was requested (GenerateContentConfig.CandidateCount > 1).
+// The response Content is always candidate 0.
+const CandidatesMetadataKey = "candidates"
+
+// Candidate is one of several alternative responses to the same request.
+type Candidate struct {
+	Index        int                `json:"index"`
+	Content      *genai.Content     `json:"content"`
+	FinishReason genai.FinishReason `json:"finishReason,omitempty"`
+}
+
+// CandidatesFromMetadata returns the candidates stored under CandidatesMetadataKey, or nil.
+// It accepts both the typed value set by adapters and its JSON-decoded form, as found in
+// events loaded back from a session store.
+func CandidatesFromMetadata(meta map[string]any) []Candidate {
+	raw, ok := meta[CandidatesMetadataKey]
+	if !ok || raw == nil {
+		return nil
+	}
+
+	if candidates, ok := raw.([]Candidate); ok {
+		return candidates
+	}
+
+	data, err := sonic.Marshal(raw)
+	if err != nil {
+		return nil
+	}
+
+	var candidates []Candidate
+	if err := sonic.Unmarshal(data, &candidates); err != nil {
+		return nil
+	}
+	return candidates
+}
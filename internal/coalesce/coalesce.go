@@ -0,0 +1,69 @@
+// Package coalesce merges the text deltas of streaming model responses, so that a
+// chatty provider does not turn a response into hundreds of partial events.
+package coalesce
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	genaitypes "github.com/kydenul/k-adk/genai/types"
+)
+
+// Buffer holds the text deltas of a stream until they are released by its config.
+// A nil Buffer releases every delta as is.
+type Buffer struct {
+	cfg   genaitypes.CoalesceConfig
+	text  strings.Builder
+	since time.Time
+	now   func() time.Time
+}
+
+// New returns the Buffer of a stream: the config attached to ctx with
+// genaitypes.WithCoalesce, or else the configured one. It returns nil if
+// coalescing is disabled.
+func New(ctx context.Context, configured genaitypes.CoalesceConfig) *Buffer {
+	cfg := configured
+	if override, ok := genaitypes.CoalesceFromContext(ctx); ok {
+		cfg = override
+	}
+	if !cfg.Enabled() {
+		return nil
+	}
+
+	return &Buffer{cfg: cfg, now: time.Now}
+}
+
+// Add holds a delta and returns the text to release, if any.
+func (b *Buffer) Add(delta string) (string, bool) {
+	if b == nil {
+		return delta, delta != ""
+	}
+	if delta == "" {
+		return "", false
+	}
+
+	if b.text.Len() == 0 {
+		b.since = b.now()
+	}
+	b.text.WriteString(delta)
+
+	if (b.cfg.MaxBytes > 0 && b.text.Len() >= b.cfg.MaxBytes) ||
+		(b.cfg.Window > 0 && b.now().Sub(b.since) >= b.cfg.Window) {
+		return b.Flush()
+	}
+
+	return "", false
+}
+
+// Flush releases the held text, if any. Call it when the stream ends.
+func (b *Buffer) Flush() (string, bool) {
+	if b == nil || b.text.Len() == 0 {
+		return "", false
+	}
+
+	text := b.text.String()
+	b.text.Reset()
+
+	return text, true
+}
@@ -0,0 +1,78 @@
+package coalesce
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	genaitypes "github.com/kydenul/k-adk/genai/types"
+)
+
+func TestNew(t *testing.T) {
+	if b := New(context.Background(), genaitypes.CoalesceConfig{}); b != nil {
+		t.Error("Expected nil buffer for the zero config")
+	}
+
+	configured := genaitypes.CoalesceConfig{MaxBytes: 8}
+	if b := New(context.Background(), configured); b == nil || b.cfg != configured {
+		t.Error("Expected the configured buffer")
+	}
+
+	ctx := genaitypes.WithCoalesce(context.Background(), genaitypes.CoalesceConfig{})
+	if b := New(ctx, configured); b != nil {
+		t.Error("Expected the context config to disable coalescing")
+	}
+
+	override := genaitypes.CoalesceConfig{Window: time.Second}
+	ctx = genaitypes.WithCoalesce(context.Background(), override)
+	if b := New(ctx, configured); b == nil || b.cfg != override {
+		t.Error("Expected the context config to override the configured one")
+	}
+}
+
+func TestBuffer(t *testing.T) {
+	t.Run("nil buffer passes deltas through", func(t *testing.T) {
+		var b *Buffer
+		if text, ok := b.Add("hi"); !ok || text != "hi" {
+			t.Errorf("Add() = %q, %v", text, ok)
+		}
+		if _, ok := b.Flush(); ok {
+			t.Error("Expected nothing to flush")
+		}
+	})
+
+	t.Run("max bytes", func(t *testing.T) {
+		b := New(context.Background(), genaitypes.CoalesceConfig{MaxBytes: 5})
+
+		if _, ok := b.Add("he"); ok {
+			t.Error("Expected the delta to be held")
+		}
+		if text, ok := b.Add("llo"); !ok || text != "hello" {
+			t.Errorf("Add() = %q, %v, want hello", text, ok)
+		}
+		if _, ok := b.Add("!"); ok {
+			t.Error("Expected the delta to be held")
+		}
+		if text, ok := b.Flush(); !ok || text != "!" {
+			t.Errorf("Flush() = %q, %v, want !", text, ok)
+		}
+	})
+
+	t.Run("window", func(t *testing.T) {
+		now := time.Unix(0, 0)
+		b := New(context.Background(), genaitypes.CoalesceConfig{Window: 100 * time.Millisecond})
+		b.now = func() time.Time { return now }
+
+		if _, ok := b.Add("a"); ok {
+			t.Error("Expected the delta to be held")
+		}
+		now = now.Add(50 * time.Millisecond)
+		if _, ok := b.Add("b"); ok {
+			t.Error("Expected the delta to be held within the window")
+		}
+		now = now.Add(50 * time.Millisecond)
+		if text, ok := b.Add("c"); !ok || text != "abc" {
+			t.Errorf("Add() = %q, %v, want abc", text, ok)
+		}
+	})
+}
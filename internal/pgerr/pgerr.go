@@ -0,0 +1,90 @@
+// Package pgerr classifies PostgreSQL errors, so the PostgreSQL packages can wrap
+// them with sentinel errors callers match with errors.Is.
+package pgerr
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrConnection reports that the database could not be reached, or the
+	// connection broke during the call.
+	ErrConnection = errors.New("postgres connection unavailable")
+
+	// ErrConstraint reports an integrity constraint violation (class 23), such as a
+	// duplicate key.
+	ErrConstraint = errors.New("postgres constraint violation")
+
+	// ErrSerialization reports a transaction aborted to keep the transactions
+	// serializable (class 40), such as a serialization failure or a deadlock. It
+	// may succeed if run again.
+	ErrSerialization = errors.New("postgres serialization failure")
+
+	// ErrUnavailableTable reports that a table is missing (undefined_table) or could
+	// not be locked (lock_not_available).
+	ErrUnavailableTable = errors.New("postgres table unavailable")
+)
+
+// Classify returns the sentinel error matching err, or nil if err is not one of
+// the classified failures.
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return classifyCode(string(pqErr.Code))
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return classifyCode(pgErr.Code)
+	}
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) {
+		return ErrConnection
+	}
+
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrConnection
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ErrConnection
+	}
+
+	return nil
+}
+
+// classifyCode returns the sentinel error of a SQLSTATE code, of lib/pq or pgx.
+func classifyCode(code string) error {
+	switch {
+	case strings.HasPrefix(code, "08"):
+		return ErrConnection
+	case strings.HasPrefix(code, "23"):
+		return ErrConstraint
+	case strings.HasPrefix(code, "40"):
+		return ErrSerialization
+	case code == "42P01", code == "55P03":
+		return ErrUnavailableTable
+	}
+	return nil
+}
+
+// Wrap wraps err as "msg: err", also wrapping its sentinel error if it has one.
+func Wrap(msg string, err error) error {
+	if sentinel := Classify(err); sentinel != nil {
+		return fmt.Errorf("%s: %w: %w", msg, sentinel, err)
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
@@ -0,0 +1,58 @@
+package pgerr
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"nil", nil, nil},
+		{"plain", errors.New("boom"), nil},
+		{"bad conn", fmt.Errorf("query: %w", driver.ErrBadConn), ErrConnection},
+		{"connection failure", &pq.Error{Code: "08006"}, ErrConnection},
+		{"unique violation", &pq.Error{Code: "23505"}, ErrConstraint},
+		{"undefined table", &pq.Error{Code: "42P01"}, ErrUnavailableTable},
+		{"lock not available", &pq.Error{Code: "55P03"}, ErrUnavailableTable},
+		{"syntax error", &pq.Error{Code: "42601"}, nil},
+		{"pgx unique violation", &pgconn.PgError{Code: "23505"}, ErrConstraint},
+		{"pgx undefined table", fmt.Errorf("query: %w", &pgconn.PgError{Code: "42P01"}), ErrUnavailableTable},
+		{"serialization failure", &pq.Error{Code: "40001"}, ErrSerialization},
+		{"pgx deadlock", &pgconn.PgError{Code: "40P01"}, ErrSerialization},
+		{"pgx admin shutdown", &pgconn.PgError{Code: "57P01"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Classify(tt.err); got != tt.want {
+				t.Errorf("Classify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrap(t *testing.T) {
+	cause := &pq.Error{Code: "23505", Message: "duplicate key"}
+
+	err := Wrap("failed to insert", cause)
+	if !errors.Is(err, ErrConstraint) {
+		t.Errorf("expected ErrConstraint, got %v", err)
+	}
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr != cause {
+		t.Errorf("expected the cause to be kept, got %v", err)
+	}
+
+	if err := Wrap("failed", errors.New("boom")); err.Error() != "failed: boom" {
+		t.Errorf("unexpected message: %q", err.Error())
+	}
+}
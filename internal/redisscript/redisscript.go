@@ -0,0 +1,325 @@
+// Package redisscript manages the Lua scripts run against Redis.
+//
+// Every script is registered under a name. On Redis 7+, scripts run as Redis
+// Functions: each source is loaded once as its own library, named after the name
+// and a hash of the source, persisted and replicated by Redis, so it survives
+// restarts and failovers, and instances of different releases sharing a Redis each
+// call their own source. Prune unloads the libraries of the sources no longer
+// registered, once every instance runs the same release. On older servers,
+// or when functions cannot be loaded, scripts run with EVALSHA from their cached
+// SHA1. Either way a script missing from the server, e.g. after SCRIPT FLUSH or
+// FUNCTION FLUSH, is loaded again and the call retried.
+package redisscript
+
+import (
+	"context"
+	"crypto/sha1" //nolint:gosec // the script cache is keyed by SHA1
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// functionsMinVersion is the first Redis major version with Redis Functions.
+const functionsMinVersion = 7
+
+var (
+	// ErrInvalidScript is returned when registering a script with an invalid name.
+	ErrInvalidScript = errors.New("invalid script")
+
+	// ErrDuplicateScript is returned when registering a script under a name already
+	// registered.
+	ErrDuplicateScript = errors.New("script already registered")
+)
+
+// validName restricts script names to the characters allowed in function names.
+var validName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// functionHashLength is the length of the hash of the source in function names.
+const functionHashLength = 16
+
+// librarySuffix matches the suffix of the library names after the script name:
+// the hash of its source, or the version of the releases naming them by version.
+var librarySuffix = regexp.MustCompile(`^(?:[0-9a-f]{16}|v[0-9]+)$`)
+
+// mode is how the scripts run on a server.
+type mode int
+
+const (
+	modeScripts mode = iota + 1
+	modeFunctions
+)
+
+// Registry is a set of named scripts.
+type Registry struct {
+	prefix string
+
+	mu      sync.RWMutex
+	scripts map[string]*Script
+
+	// modes caches the mode of every client, detected on first use.
+	modes sync.Map
+}
+
+// Default is the registry of the scripts of this module, named with the "kadk" prefix.
+var Default = NewRegistry("kadk")
+
+// NewRegistry creates a Registry naming the functions of its scripts with prefix.
+func NewRegistry(prefix string) *Registry {
+	return &Registry{prefix: prefix, scripts: make(map[string]*Script)}
+}
+
+// New registers a script in the Default registry, panicking on an invalid or
+// duplicate script. It is meant for package-level script variables.
+func New(name, src string) *Script {
+	s, err := Default.Register(name, src)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Register registers src under name, lowercase letters, digits and underscores.
+// The function running the script on Redis 7+ is named after name and a hash of
+// src, so a changed source runs as a new function.
+func (r *Registry) Register(name, src string) (*Script, error) {
+	if !validName.MatchString(name) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidScript, name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.scripts[name]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrDuplicateScript, name)
+	}
+
+	sum := sha1.Sum([]byte(src)) //nolint:gosec // the script cache is keyed by SHA1
+	hash := hex.EncodeToString(sum[:])
+	s := &Script{
+		registry: r,
+		name:     name,
+		src:      src,
+		hash:     hash,
+		function: fmt.Sprintf("%s_%s_%s", r.prefix, name, hash[:functionHashLength]),
+	}
+	r.scripts[name] = s
+
+	return s, nil
+}
+
+// Scripts returns the registered scripts, sorted by name.
+func (r *Registry) Scripts() []*Script {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	scripts := make([]*Script, 0, len(r.scripts))
+	for _, s := range r.scripts {
+		scripts = append(scripts, s)
+	}
+	slices.SortFunc(scripts, func(a, b *Script) int { return strings.Compare(a.name, b.name) })
+
+	return scripts
+}
+
+// Load loads every registered script on the server of c, e.g. at startup, so the
+// first calls do not pay for it.
+func (r *Registry) Load(ctx context.Context, c redis.UniversalClient) error {
+	var errs []error
+	for _, s := range r.Scripts() {
+		if err := s.load(ctx, c, r.mode(ctx, c)); err != nil {
+			errs = append(errs, fmt.Errorf("failed to load script %s: %w", s.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Prune unloads from the server of c, from every master of a cluster, the function
+// libraries of the registered scripts other than their current source, e.g. left
+// by the previous releases. It returns the number of libraries unloaded. Run it
+// once every instance sharing the server runs the current release: an instance of
+// another release loads its libraries again, on its next calls.
+func (r *Registry) Prune(ctx context.Context, c redis.UniversalClient) (int, error) {
+	if r.mode(ctx, c) != modeFunctions {
+		return 0, nil
+	}
+
+	if cc, ok := c.(*redis.ClusterClient); ok {
+		var (
+			mu     sync.Mutex
+			pruned int
+		)
+		err := cc.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			n, err := r.prune(ctx, node)
+			mu.Lock()
+			pruned += n
+			mu.Unlock()
+			return err
+		})
+		return pruned, err
+	}
+
+	return r.prune(ctx, c)
+}
+
+// prune unloads the stale libraries of the registered scripts from a single node.
+func (r *Registry) prune(ctx context.Context, c redis.Cmdable) (int, error) {
+	libs, err := c.FunctionList(ctx, redis.FunctionListQuery{LibraryNamePattern: r.prefix + "_*"}).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	pruned := 0
+	for _, lib := range libs {
+		if !r.stale(lib.Name) {
+			continue
+		}
+		if err := c.FunctionDelete(ctx, lib.Name).Err(); err != nil && !redis.HasErrorPrefix(err, "ERR Library not found") {
+			return pruned, fmt.Errorf("failed to unload library %s: %w", lib.Name, err)
+		}
+		pruned++
+	}
+
+	return pruned, nil
+}
+
+// stale reports whether library is the library of a registered script, with
+// another source than the current one.
+func (r *Registry) stale(library string) bool {
+	for _, s := range r.Scripts() {
+		suffix, ok := strings.CutPrefix(library, r.prefix+"_"+s.name+"_")
+		if ok && library != s.function && librarySuffix.MatchString(suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// mode returns the mode of c, detecting it from the server version on first use.
+func (r *Registry) mode(ctx context.Context, c redis.UniversalClient) mode {
+	if m, ok := r.modes.Load(c); ok {
+		return m.(mode)
+	}
+
+	info, err := c.Info(ctx, "server").Result()
+	if err != nil {
+		// NOTE: Detected again on the next call
+		return modeScripts
+	}
+
+	m := modeScripts
+	if serverMajorVersion(info) >= functionsMinVersion {
+		m = modeFunctions
+	}
+	r.modes.Store(c, m)
+
+	return m
+}
+
+// serverMajorVersion returns the major version in the INFO server section, 0 if
+// missing.
+func serverMajorVersion(info string) int {
+	for line := range strings.SplitSeq(info, "\n") {
+		version, ok := strings.CutPrefix(strings.TrimSpace(line), "redis_version:")
+		if !ok {
+			continue
+		}
+
+		major, _, _ := strings.Cut(version, ".")
+		n, _ := strconv.Atoi(major)
+		return n
+	}
+
+	return 0
+}
+
+// Script is a Lua script registered in a Registry. KEYS and ARGV are available to
+// it as in EVAL, in both modes.
+type Script struct {
+	registry *Registry
+	name     string
+	src      string
+	hash     string
+	function string
+}
+
+// Name returns the name of the script.
+func (s *Script) Name() string { return s.name }
+
+// Hash returns the SHA1 of the script source, its key in the script cache.
+func (s *Script) Hash() string { return s.hash }
+
+// Function returns the name of the function, and of its library, running the
+// script on Redis 7+: "{prefix}_{name}_{hash}", with the first 16 hex digits of
+// its hash.
+func (s *Script) Function() string { return s.function }
+
+// Run runs the script with keys and args, loading it first if the server does not
+// have it.
+func (s *Script) Run(ctx context.Context, c redis.UniversalClient, keys []string, args ...any) *redis.Cmd {
+	if s.registry.mode(ctx, c) == modeFunctions {
+		cmd := c.FCall(ctx, s.function, keys, args...)
+		switch err := cmd.Err(); {
+		case redis.HasErrorPrefix(err, "Function not found"):
+			if err := s.load(ctx, c, modeFunctions); err == nil {
+				return c.FCall(ctx, s.function, keys, args...)
+			}
+			// NOTE: Functions cannot be loaded, e.g. denied by an ACL
+			s.registry.modes.Store(c, modeScripts)
+
+		case redis.HasErrorPrefix(err, "unknown command"):
+			// NOTE: A Redis-compatible server without functions
+			s.registry.modes.Store(c, modeScripts)
+
+		default:
+			return cmd
+		}
+	}
+
+	cmd := c.EvalSha(ctx, s.hash, keys, args...)
+	if !redis.HasErrorPrefix(cmd.Err(), "NOSCRIPT") {
+		return cmd
+	}
+
+	// NOTE: The script cache was flushed, e.g. by a restart or failover: EVAL caches
+	// the script again on the node of the keys
+	return c.Eval(ctx, s.src, keys, args...)
+}
+
+// load loads the script on the server of c, on every master of a cluster.
+func (s *Script) load(ctx context.Context, c redis.UniversalClient, m mode) error {
+	if m == modeScripts {
+		// NOTE: ScriptLoad of a cluster client loads on every shard
+		hash, err := c.ScriptLoad(ctx, s.src).Result()
+		if err != nil {
+			return err
+		}
+		if hash != s.hash {
+			return fmt.Errorf("unexpected script hash %s, want %s", hash, s.hash)
+		}
+		return nil
+	}
+
+	code := s.library()
+	if cc, ok := c.(*redis.ClusterClient); ok {
+		return cc.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return node.FunctionLoadReplace(ctx, code).Err()
+		})
+	}
+
+	return c.FunctionLoadReplace(ctx, code).Err()
+}
+
+// library returns the code of the function library of the script, a single
+// function named like the library.
+func (s *Script) library() string {
+	return fmt.Sprintf("#!lua name=%s\nredis.register_function('%s', function(KEYS, ARGV)\n%s\nend)\n",
+		s.function, s.function, s.src)
+}
@@ -0,0 +1,201 @@
+package redisscript
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const incrScript = `
+local n = redis.call('INCRBY', KEYS[1], tonumber(ARGV[1]))
+return n
+`
+
+func getTestRedisAddr() string {
+	if addr := os.Getenv("TEST_REDIS_ADDR"); addr != "" {
+		return addr
+	}
+	return "localhost:6379"
+}
+
+func setupTestRedis(t *testing.T) redis.UniversalClient {
+	t.Helper()
+
+	rdb := redis.NewUniversalClient(&redis.UniversalOptions{Addrs: []string{getTestRedisAddr()}})
+	t.Cleanup(func() { rdb.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		t.Skipf("Redis not available at %s, skipping test: %v", getTestRedisAddr(), err)
+	}
+
+	return rdb
+}
+
+func TestRegister(t *testing.T) {
+	r := NewRegistry("test")
+
+	s, err := r.Register("incr", incrScript)
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	function := "test_incr_" + s.Hash()[:functionHashLength]
+	if s.Function() != function || len(s.Hash()) != 40 {
+		t.Errorf("function = %s, hash = %s", s.Function(), s.Hash())
+	}
+	if lib := s.library(); !strings.HasPrefix(lib, "#!lua name="+function+"\n") ||
+		!strings.Contains(lib, "redis.register_function('"+function+"', function(KEYS, ARGV)") {
+		t.Errorf("unexpected library:\n%s", lib)
+	}
+
+	if _, err := r.Register("incr", incrScript); !errors.Is(err, ErrDuplicateScript) {
+		t.Errorf("duplicate error = %v, want ErrDuplicateScript", err)
+	}
+	for _, name := range []string{"", "Incr", "incr-by", "1incr"} {
+		if _, err := r.Register(name, incrScript); !errors.Is(err, ErrInvalidScript) {
+			t.Errorf("Register(%q) error = %v, want ErrInvalidScript", name, err)
+		}
+	}
+
+	// A changed source runs as another function
+	other := NewRegistry("test")
+	changed, err := other.Register("incr", incrScript+"-- changed\n")
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if changed.Function() == s.Function() {
+		t.Errorf("changed source kept function %s", s.Function())
+	}
+
+	if _, err := r.Register("add", incrScript); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if got := r.Scripts(); len(got) != 2 || got[0].Name() != "add" || got[1].Name() != "incr" {
+		t.Errorf("Scripts() = %v, want add and incr", got)
+	}
+}
+
+func TestStale(t *testing.T) {
+	r := NewRegistry("test")
+	s, _ := r.Register("incr", incrScript)
+	_, _ = r.Register("incr_by", incrScript)
+
+	tests := map[string]bool{
+		s.Function():                  false,
+		"test_incr_0123456789abcdef":  true,
+		"test_incr_v2":                true,
+		"test_incr_by_v1":             true,
+		"test_decr_0123456789abcdef":  false, // not registered
+		"other_incr_0123456789abcdef": false, // another registry
+		"test_incr_custom":            false,
+	}
+	for library, want := range tests {
+		if got := r.stale(library); got != want {
+			t.Errorf("stale(%q) = %v, want %v", library, got, want)
+		}
+	}
+}
+
+func TestServerMajorVersion(t *testing.T) {
+	tests := map[string]int{
+		"# Server\r\nredis_version:7.2.4\r\nredis_mode:standalone\r\n": 7,
+		"# Server\r\nredis_version:6.2.14\r\n":                         6,
+		"# Server\r\n":                                                 0,
+	}
+
+	for info, want := range tests {
+		if got := serverMajorVersion(info); got != want {
+			t.Errorf("serverMajorVersion(%q) = %d, want %d", info, got, want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	rdb := setupTestRedis(t)
+	ctx := context.Background()
+
+	r := NewRegistry("kadktest")
+	s, err := r.Register("incr", incrScript)
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	const key = "kadktest:redisscript:counter"
+	t.Cleanup(func() {
+		rdb.Del(context.Background(), key)
+		rdb.FunctionDelete(context.Background(), s.Function())
+	})
+
+	run := func(t *testing.T, want int64) {
+		t.Helper()
+		got, err := s.Run(ctx, rdb, []string{key}, 2).Int64()
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if got != want {
+			t.Errorf("Run() = %d, want %d", got, want)
+		}
+	}
+
+	t.Run("scripts", func(t *testing.T) {
+		r.modes.Store(rdb, modeScripts)
+		rdb.Del(ctx, key)
+
+		if err := r.Load(ctx, rdb); err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if exists := rdb.ScriptExists(ctx, s.Hash()).Val(); len(exists) != 1 || !exists[0] {
+			t.Error("expected the script to be cached")
+		}
+		run(t, 2)
+
+		// NOTE: A flushed script cache is reloaded
+		if err := rdb.ScriptFlush(ctx).Err(); err != nil {
+			t.Fatalf("ScriptFlush failed: %v", err)
+		}
+		run(t, 4)
+	})
+
+	t.Run("functions", func(t *testing.T) {
+		r.modes.Delete(rdb)
+		if r.mode(ctx, rdb) != modeFunctions {
+			t.Skip("Redis Functions need Redis 7+")
+		}
+		rdb.Del(ctx, key)
+
+		run(t, 2)
+		if libs := rdb.FunctionList(ctx, redis.FunctionListQuery{LibraryNamePattern: s.Function()}).Val(); len(libs) != 1 {
+			t.Fatalf("libraries = %d, want the library of the script", len(libs))
+		}
+
+		// NOTE: A deleted function is loaded again
+		if err := rdb.FunctionDelete(ctx, s.Function()).Err(); err != nil {
+			t.Fatalf("FunctionDelete failed: %v", err)
+		}
+		run(t, 4)
+
+		// NOTE: The library of a previous source is pruned, the current one kept
+		previous := NewRegistry("kadktest")
+		old, _ := previous.Register("incr", incrScript+"-- previous\n")
+		if err := previous.Load(ctx, rdb); err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		t.Cleanup(func() { rdb.FunctionDelete(context.Background(), old.Function()) })
+
+		pruned, err := r.Prune(ctx, rdb)
+		if err != nil || pruned != 1 {
+			t.Fatalf("Prune() = %d, %v, want 1", pruned, err)
+		}
+		if libs := rdb.FunctionList(ctx, redis.FunctionListQuery{LibraryNamePattern: old.Function()}).Val(); len(libs) != 0 {
+			t.Error("expected the previous library to be unloaded")
+		}
+		run(t, 6)
+	})
+}
@@ -0,0 +1,181 @@
+// Package stmtcache caches the prepared statements of hot queries.
+//
+// Without it, every database/sql call with arguments makes lib/pq parse the query
+// on the server (an extra round trip) and plan it again. A cached *sql.Stmt is
+// prepared once per pooled connection, on first use, and reused by every later call
+// on that connection, so the server also keeps its plan.
+package stmtcache
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+)
+
+// Cache holds a prepared statement per query. A disabled Cache runs the queries
+// unprepared, for poolers that cannot keep statements (PgBouncer transaction mode).
+type Cache struct {
+	db       *sql.DB
+	disabled bool
+
+	mu    sync.RWMutex
+	stmts map[string]*sql.Stmt
+}
+
+// New creates a Cache of db. If disabled, queries are never prepared.
+func New(db *sql.DB, disabled bool) *Cache {
+	return &Cache{db: db, disabled: disabled, stmts: make(map[string]*sql.Stmt)}
+}
+
+// Stmt returns the prepared statement of query, preparing it on first use.
+func (c *Cache) Stmt(ctx context.Context, query string) (*sql.Stmt, error) {
+	c.mu.RLock()
+	stmt, ok := c.stmts[query]
+	c.mu.RUnlock()
+	if ok {
+		return stmt, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if stmt, ok := c.stmts[query]; ok {
+		return stmt, nil
+	}
+
+	stmt, err := c.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	c.stmts[query] = stmt
+
+	return stmt, nil
+}
+
+// ExecContext executes query with args.
+func (c *Cache) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if c.disabled {
+		return c.db.ExecContext(ctx, query, args...)
+	}
+
+	stmt, err := c.Stmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.ExecContext(ctx, args...)
+}
+
+// QueryContext runs query with args.
+func (c *Cache) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if c.disabled {
+		return c.db.QueryContext(ctx, query, args...)
+	}
+
+	stmt, err := c.Stmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.QueryContext(ctx, args...)
+}
+
+// QueryRowContext runs query with args, returning one row.
+func (c *Cache) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	if c.disabled {
+		return c.db.QueryRowContext(ctx, query, args...)
+	}
+
+	stmt, err := c.Stmt(ctx, query)
+	if err != nil {
+		// Row carries the error to Scan
+		return c.db.QueryRowContext(ctx, query, args...)
+	}
+	return stmt.QueryRowContext(ctx, args...)
+}
+
+// Tx binds the cache to tx. The statements bound to a transaction are closed when
+// it ends; the cached statements stay.
+func (c *Cache) Tx(tx *sql.Tx) *Tx {
+	return &Tx{cache: c, tx: tx}
+}
+
+// Tx runs the cached statements within a transaction.
+type Tx struct {
+	cache *Cache
+	tx    *sql.Tx
+}
+
+// Stmt returns the prepared statement of query bound to the transaction.
+func (t *Tx) Stmt(ctx context.Context, query string) (*sql.Stmt, error) {
+	if t.cache.disabled {
+		return t.tx.PrepareContext(ctx, query)
+	}
+
+	stmt, err := t.cache.Stmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return t.tx.StmtContext(ctx, stmt), nil
+}
+
+// ExecContext executes query with args within the transaction.
+func (t *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if t.cache.disabled {
+		return t.tx.ExecContext(ctx, query, args...)
+	}
+
+	stmt, err := t.Stmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.ExecContext(ctx, args...)
+}
+
+// QueryContext runs query with args within the transaction.
+func (t *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if t.cache.disabled {
+		return t.tx.QueryContext(ctx, query, args...)
+	}
+
+	stmt, err := t.Stmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.QueryContext(ctx, args...)
+}
+
+// QueryRowContext runs query with args within the transaction, returning one row.
+func (t *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	if t.cache.disabled {
+		return t.tx.QueryRowContext(ctx, query, args...)
+	}
+
+	stmt, err := t.Stmt(ctx, query)
+	if err != nil {
+		// Row carries the error to Scan
+		return t.tx.QueryRowContext(ctx, query, args...)
+	}
+	return stmt.QueryRowContext(ctx, args...)
+}
+
+// Len returns the number of cached statements.
+func (c *Cache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.stmts)
+}
+
+// Close closes the cached statements. The cache stays usable.
+func (c *Cache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var errs []error
+	for query, stmt := range c.stmts {
+		errs = append(errs, stmt.Close())
+		delete(c.stmts, query)
+	}
+
+	return errors.Join(errs...)
+}
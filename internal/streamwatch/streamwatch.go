@@ -0,0 +1,70 @@
+// Package streamwatch aborts streaming model responses whose provider stays silent,
+// so that a stalled SSE connection does not pin a turn forever.
+package streamwatch
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	genaitypes "github.com/kydenul/k-adk/genai/types"
+)
+
+// errIdle is the cancel cause of an idle stream.
+var errIdle = errors.New("stream idle")
+
+// Timeout resolves a configured idle timeout: zero falls back to
+// genaitypes.DefaultStreamIdleTimeout, a negative value disables the watchdog.
+func Timeout(configured time.Duration) time.Duration {
+	if configured == 0 {
+		return genaitypes.DefaultStreamIdleTimeout
+	}
+	return max(configured, 0)
+}
+
+// Watchdog cancels the context of a stream when no chunk arrives within its timeout.
+type Watchdog struct {
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	timeout time.Duration
+	timer   *time.Timer
+}
+
+// New returns a Watchdog and the context to open the stream with. The timeout covers
+// the wait for the first chunk too. A non-positive timeout disables the watchdog.
+func New(ctx context.Context, timeout time.Duration) (*Watchdog, context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	w := &Watchdog{ctx: ctx, cancel: cancel, timeout: timeout}
+
+	if timeout > 0 {
+		w.timer = time.AfterFunc(timeout, func() { cancel(errIdle) })
+	}
+
+	return w, ctx
+}
+
+// Touch records a received chunk, restarting the timeout.
+func (w *Watchdog) Touch() {
+	if w.timer != nil {
+		w.timer.Reset(w.timeout)
+	}
+}
+
+// Stop releases the watchdog and cancels the stream context. Call it once the
+// stream is done.
+func (w *Watchdog) Stop() {
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.cancel(nil)
+}
+
+// Err returns a *genaitypes.StreamIdleError if the stream was aborted for being idle,
+// or err otherwise.
+func (w *Watchdog) Err(err error, modelName string, chunks int) error {
+	if !errors.Is(context.Cause(w.ctx), errIdle) {
+		return err
+	}
+
+	return &genaitypes.StreamIdleError{Model: modelName, Idle: w.timeout, Chunks: chunks}
+}
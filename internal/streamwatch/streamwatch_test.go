@@ -0,0 +1,86 @@
+package streamwatch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	genaitypes "github.com/kydenul/k-adk/genai/types"
+)
+
+func TestTimeout(t *testing.T) {
+	if got := Timeout(0); got != genaitypes.DefaultStreamIdleTimeout {
+		t.Errorf("Timeout(0) = %v", got)
+	}
+	if got := Timeout(-1); got != 0 {
+		t.Errorf("Timeout(-1) = %v, want disabled", got)
+	}
+	if got := Timeout(time.Second); got != time.Second {
+		t.Errorf("Timeout(1s) = %v", got)
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	t.Run("touch keeps the stream alive", func(t *testing.T) {
+		w, ctx := New(context.Background(), 50*time.Millisecond)
+		defer w.Stop()
+
+		for range 4 {
+			time.Sleep(20 * time.Millisecond)
+			w.Touch()
+		}
+		if ctx.Err() != nil {
+			t.Fatal("Expected the stream context to stay alive")
+		}
+
+		streamErr := errors.New("eof")
+		if err := w.Err(streamErr, "m", 4); err != streamErr {
+			t.Errorf("Err() = %v, want the stream error", err)
+		}
+	})
+
+	t.Run("silence aborts the stream", func(t *testing.T) {
+		w, ctx := New(context.Background(), 20*time.Millisecond)
+		defer w.Stop()
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+			t.Fatal("Expected the stream context to be canceled")
+		}
+
+		err := w.Err(ctx.Err(), "gpt-4o", 3)
+		var idle *genaitypes.StreamIdleError
+		if !errors.As(err, &idle) || idle.Model != "gpt-4o" || idle.Chunks != 3 {
+			t.Fatalf("Err() = %v, want a StreamIdleError", err)
+		}
+		if !errors.Is(err, genaitypes.ErrStreamIdle) {
+			t.Error("Expected the error to match ErrStreamIdle")
+		}
+	})
+
+	t.Run("caller cancellation is not a timeout", func(t *testing.T) {
+		parent, cancel := context.WithCancel(context.Background())
+		w, ctx := New(parent, time.Minute)
+		defer w.Stop()
+
+		cancel()
+		<-ctx.Done()
+		if err := w.Err(ctx.Err(), "m", 0); !errors.Is(err, context.Canceled) {
+			t.Errorf("Err() = %v, want context.Canceled", err)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		w, ctx := New(context.Background(), 0)
+		time.Sleep(10 * time.Millisecond)
+		if ctx.Err() != nil {
+			t.Error("Expected a disabled watchdog not to cancel")
+		}
+		w.Stop()
+		if ctx.Err() == nil {
+			t.Error("Expected Stop to release the context")
+		}
+	})
+}
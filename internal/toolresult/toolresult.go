@@ -0,0 +1,190 @@
+// Package toolresult limits the size of tool (function) responses before they are
+// sent to a model provider.
+package toolresult
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+const (
+	// NoteKey is the key added to truncated JSON object responses to tell the
+	// model that content was removed.
+	NoteKey = "_truncated"
+
+	// noteReserve is the number of bytes kept free for the truncation note.
+	noteReserve = 160
+
+	// maxShrinkPasses bounds the number of JSON-aware shrink iterations.
+	maxShrinkPasses = 48
+
+	// minShrinkableString is the shortest string that is still worth shrinking.
+	minShrinkableString = 64
+
+	// charsPerToken converts a token limit into a byte limit.
+	charsPerToken = 4
+)
+
+// MaxBytes resolves the configured byte and token limits into a single byte limit.
+// maxBytes takes precedence; zero means no limit.
+func MaxBytes(maxBytes, maxTokens int) int {
+	if maxBytes > 0 {
+		return maxBytes
+	}
+	if maxTokens > 0 {
+		return maxTokens * charsPerToken
+	}
+	return 0
+}
+
+// Limit truncates a serialized FunctionResponse payload to roughly maxBytes.
+// A maxBytes <= 0 disables truncation.
+//
+// Truncation is JSON-aware: the largest strings and arrays are cut in the middle
+// (keeping head and tail) until the payload fits, and a note is added under NoteKey.
+// If the payload still does not fit, the serialized text itself is cut head+tail.
+func Limit(data []byte, maxBytes int) []byte {
+	if maxBytes <= 0 || len(data) <= maxBytes {
+		return data
+	}
+
+	originalLen := len(data)
+	budget := max(maxBytes-noteReserve, maxBytes/2)
+
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return truncateText(data, maxBytes)
+	}
+
+	shrunk := data
+	for range maxShrinkPasses {
+		if len(shrunk) <= budget || !shrinkLargest(&v) {
+			break
+		}
+
+		out, err := json.Marshal(v)
+		if err != nil {
+			return truncateText(data, maxBytes)
+		}
+		shrunk = out
+	}
+
+	if obj, ok := v.(map[string]any); ok && len(shrunk) <= budget {
+		obj[NoteKey] = fmt.Sprintf("Tool output was truncated from %d to about %d bytes; "+
+			"the middle of long values was omitted.", originalLen, len(shrunk))
+		if out, err := json.Marshal(obj); err == nil {
+			return out
+		}
+	}
+
+	return truncateText(data, maxBytes)
+}
+
+// leaf is a shrinkable value within a decoded JSON document.
+type leaf struct {
+	size int
+	val  any
+	set  func(any)
+}
+
+// shrinkLargest halves the largest shrinkable string or array in *root.
+// Returns false if nothing could be shrunk.
+func shrinkLargest(root *any) bool {
+	var leaves []leaf
+	collectLeaves(*root, func(nv any) { *root = nv }, &leaves)
+
+	var best *leaf
+	for i := range leaves {
+		if best == nil || leaves[i].size > best.size {
+			best = &leaves[i]
+		}
+	}
+	if best == nil {
+		return false
+	}
+
+	switch val := best.val.(type) {
+	case string:
+		best.set(cutString(val, len(val)/2))
+
+	case []any:
+		keep := max(1, len(val)/4)
+		omitted := len(val) - 2*keep
+
+		shrunk := make([]any, 0, 2*keep+1)
+		shrunk = append(shrunk, val[:keep]...)
+		shrunk = append(shrunk, fmt.Sprintf("...[%d items omitted]...", omitted))
+		shrunk = append(shrunk, val[len(val)-keep:]...)
+		best.set(shrunk)
+	}
+
+	return true
+}
+
+// collectLeaves walks v and records strings and arrays that can still be shrunk.
+func collectLeaves(v any, set func(any), out *[]leaf) {
+	switch val := v.(type) {
+	case string:
+		if len(val) >= minShrinkableString {
+			*out = append(*out, leaf{size: len(val), val: val, set: set})
+		}
+
+	case []any:
+		if len(val) > 3 {
+			if data, err := json.Marshal(val); err == nil {
+				*out = append(*out, leaf{size: len(data), val: val, set: set})
+			}
+		}
+		for i := range val {
+			collectLeaves(val[i], func(nv any) { val[i] = nv }, out)
+		}
+
+	case map[string]any:
+		for k := range val {
+			collectLeaves(val[k], func(nv any) { val[k] = nv }, out)
+		}
+	}
+}
+
+// cutString keeps roughly keep bytes of s, split between head and tail, cut on
+// rune boundaries.
+func cutString(s string, keep int) string {
+	head, tail := cutPoints(s, keep)
+	omitted := tail - head
+
+	return fmt.Sprintf("%s...[%d bytes omitted]...%s", s[:head], omitted, s[tail:])
+}
+
+// cutPoints returns the end of the head and the start of the tail keeping
+// roughly keep bytes of s, moved inward to rune boundaries so that neither cuts
+// a multi-byte rune.
+func cutPoints[S ~string | ~[]byte](s S, keep int) (head, tail int) {
+	head = keep / 2
+	tail = len(s) - (keep - head)
+
+	for head > 0 && !utf8.RuneStart(s[head]) {
+		head--
+	}
+	for tail < len(s) && !utf8.RuneStart(s[tail]) {
+		tail++
+	}
+	return head, tail
+}
+
+// truncateText cuts serialized output to maxBytes keeping the head and tail, on
+// rune boundaries. The result is no longer valid JSON, so the note is inlined as
+// text.
+func truncateText(data []byte, maxBytes int) []byte {
+	head, tail := cutPoints(data, max(maxBytes-noteReserve, 0))
+
+	note := fmt.Sprintf("\n...[tool output truncated: %d of %d bytes omitted]...\n",
+		tail-head, len(data))
+
+	out := make([]byte, 0, head+len(note)+len(data)-tail)
+	out = append(out, data[:head]...)
+	out = append(out, note...)
+	out = append(out, data[tail:]...)
+
+	return out
+}
@@ -0,0 +1,143 @@
+package toolresult
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestMaxBytes(t *testing.T) {
+	tests := []struct {
+		name                string
+		maxBytes, maxTokens int
+		want                int
+	}{
+		{"no limit", 0, 0, 0},
+		{"bytes", 1000, 0, 1000},
+		{"tokens", 0, 100, 400},
+		{"bytes over tokens", 1000, 100, 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MaxBytes(tt.maxBytes, tt.maxTokens); got != tt.want {
+				t.Errorf("MaxBytes(%d, %d) = %d, want %d", tt.maxBytes, tt.maxTokens, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCutString(t *testing.T) {
+	tests := []struct {
+		name     string
+		s        string
+		keep     int
+		wantHead string
+		wantTail string
+	}{
+		{"ascii", strings.Repeat("a", 10) + strings.Repeat("b", 10), 8, "aaaa", "bbbb"},
+		{"head and tail inside a rune", "aéééééééééz", 4, "a", "z"},
+		{"tail inside a rune", "ab" + strings.Repeat("日", 5), 5, "ab", "日"},
+		{"emoji", strings.Repeat("🙂", 8), 10, "🙂", "🙂"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cutString(tt.s, tt.keep)
+			if !utf8.ValidString(got) {
+				t.Fatalf("cutString() = %q, not valid UTF-8", got)
+			}
+			head, tail, ok := strings.Cut(got, "...[")
+			if !ok || head != tt.wantHead {
+				t.Errorf("head = %q, want %q", head, tt.wantHead)
+			}
+			if _, tail, _ = strings.Cut(tail, "]..."); tail != tt.wantTail {
+				t.Errorf("tail = %q, want %q", tail, tt.wantTail)
+			}
+		})
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		maxBytes int
+	}{
+		{"ascii", strings.Repeat("x", 1000), 200},
+		{"two-byte runes", strings.Repeat("é", 500), 201},
+		{"three-byte runes", strings.Repeat("日", 500), 203},
+		{"four-byte runes", strings.Repeat("🙂", 500), 205},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateText([]byte(tt.data), tt.maxBytes)
+			if !utf8.Valid(got) {
+				t.Fatalf("truncateText() = %q, not valid UTF-8", got)
+			}
+			if !strings.Contains(string(got), "tool output truncated") {
+				t.Errorf("truncateText() = %q, want the truncation note", got)
+			}
+			if len(got) >= len(tt.data) {
+				t.Errorf("len = %d, want less than %d", len(got), len(tt.data))
+			}
+		})
+	}
+}
+
+func TestLimit(t *testing.T) {
+	obj, _ := json.Marshal(map[string]any{
+		"result": strings.Repeat("日本語のテキスト", 200),
+		"items":  strings.Split(strings.Repeat("item,", 100), ","),
+		"status": "ok",
+	})
+
+	tests := []struct {
+		name     string
+		data     []byte
+		maxBytes int
+		wantSame bool
+		wantNote bool
+		wantJSON bool
+	}{
+		{name: "no limit", data: obj, maxBytes: 0, wantSame: true, wantJSON: true},
+		{name: "fits", data: []byte(`{"a":1}`), maxBytes: 100, wantSame: true, wantJSON: true},
+		{name: "object shrunk", data: obj, maxBytes: 1000, wantNote: true, wantJSON: true},
+		{name: "not JSON", data: []byte(strings.Repeat("ü", 1000)), maxBytes: 300},
+		{name: "array", data: []byte(`[` + strings.Repeat(`"éééééééé",`, 200) + `"é"]`), maxBytes: 300},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Limit(tt.data, tt.maxBytes)
+			if tt.wantSame {
+				if string(got) != string(tt.data) {
+					t.Errorf("Limit() changed a payload within the limit")
+				}
+				return
+			}
+
+			if !utf8.Valid(got) {
+				t.Fatalf("Limit() = %q, not valid UTF-8", got)
+			}
+			if len(got) > tt.maxBytes {
+				t.Errorf("len = %d, want at most %d", len(got), tt.maxBytes)
+			}
+
+			var v any
+			if err := json.Unmarshal(got, &v); (err == nil) != tt.wantJSON {
+				t.Errorf("valid JSON = %t, want %t", err == nil, tt.wantJSON)
+			}
+			if m, ok := v.(map[string]any); ok {
+				if _, noted := m[NoteKey]; noted != tt.wantNote {
+					t.Errorf("note = %t, want %t", noted, tt.wantNote)
+				}
+				if s, _ := m["result"].(string); strings.ContainsRune(s, utf8.RuneError) {
+					t.Errorf("result holds a replacement character: %q", s)
+				}
+			}
+		})
+	}
+}
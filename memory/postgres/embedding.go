@@ -3,17 +3,22 @@ package memory
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
 	"strings"
+	"sync"
 	"sync/atomic"
 
 	"github.com/bytedance/sonic"
 	discardlog "github.com/kydenul/k-adk/internal/discard_log"
 	"github.com/kydenul/log"
 	"github.com/spf13/cast"
+	"golang.org/x/sync/singleflight"
 )
 
 var errNoEmbedding = errors.New("no embedding returned")
@@ -38,6 +43,18 @@ type OpenAICompatibleEmbedding struct {
 	// HTTPClient allows customizing the HTTP client used for requests.
 	// If nil, http.DefaultClient is used.
 	HTTPClient *http.Client
+
+	// group deduplicates concurrent identical embeds
+	group    singleflight.Group
+	mu       sync.Mutex
+	inflight map[string]*inflightEmbed
+}
+
+// inflightEmbed is the upstream request shared by the callers of an identical embed.
+type inflightEmbed struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	waiters int
 }
 
 // EmbeddingConfig holds configuration for creating an OpenAICompatibleEmbedding.
@@ -88,7 +105,85 @@ func NewOpenAICompatibleEmbedding(cfg EmbeddingConfig) *OpenAICompatibleEmbeddin
 func (e *OpenAICompatibleEmbedding) Dimension() int { return cast.ToInt(e.dim.Load()) }
 
 // Embed generates an embedding vector for the given text.
+//
+// Concurrent calls for the same model and text share one upstream request. The
+// request is cancelled only once every caller waiting for it has gone.
 func (e *OpenAICompatibleEmbedding) Embed(ctx context.Context, text string) ([]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256([]byte(text))
+	key := e.Model + ":" + hex.EncodeToString(sum[:])
+
+	call := e.join(ctx, key)
+	ch := e.group.DoChan(key, func() (any, error) {
+		return e.embed(call.ctx, text)
+	})
+
+	select {
+	case res := <-ch:
+		e.leave(key, call)
+		if res.Err != nil {
+			return nil, res.Err
+		}
+
+		embedding := res.Val.([]float32)
+		if res.Shared {
+			e.Debugf("embedding request shared: model=%s", e.Model)
+			// Every caller gets its own copy to modify
+			embedding = slices.Clone(embedding)
+		}
+		return embedding, nil
+
+	case <-ctx.Done():
+		e.leave(key, call)
+		return nil, ctx.Err()
+	}
+}
+
+// join registers a caller of the upstream request of key, starting a new one if
+// none is in flight.
+func (e *OpenAICompatibleEmbedding) join(ctx context.Context, key string) *inflightEmbed {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.inflight == nil {
+		e.inflight = make(map[string]*inflightEmbed)
+	}
+
+	call, ok := e.inflight[key]
+	if !ok {
+		// Detached from the first caller: it may leave while others still wait
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &inflightEmbed{ctx: callCtx, cancel: cancel}
+		e.inflight[key] = call
+	}
+	call.waiters++
+
+	return call
+}
+
+// leave unregisters a caller of call. The last one cancels the upstream request,
+// and makes the next caller start a fresh one.
+func (e *OpenAICompatibleEmbedding) leave(key string, call *inflightEmbed) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	call.waiters--
+	if call.waiters > 0 {
+		return
+	}
+
+	call.cancel()
+	if e.inflight[key] == call {
+		delete(e.inflight, key)
+		e.group.Forget(key)
+	}
+}
+
+// embed calls the embeddings API.
+func (e *OpenAICompatibleEmbedding) embed(ctx context.Context, text string) ([]float32, error) {
 	e.Debugf("generating embedding: model=%s, text_length=%d", e.Model, len(text))
 
 	reqBody := map[string]any{
@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestEmbedSuccess(t *testing.T) {
@@ -321,3 +324,128 @@ func TestEmbedBaseURLTrailingSlash(t *testing.T) {
 
 	t.Logf("✓ Embed trailing slash: correctly handled")
 }
+
+// waitForWaiters waits until n callers share the in-flight embed of text.
+func waitForWaiters(t *testing.T, emb *OpenAICompatibleEmbedding, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		emb.mu.Lock()
+		waiters := 0
+		for _, call := range emb.inflight {
+			waiters += call.waiters
+		}
+		emb.mu.Unlock()
+
+		if waiters == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %d callers", n)
+}
+
+func TestEmbedDeduplication(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-release
+		json.NewEncoder(w).Encode(map[string]any{
+			"data": []map[string]any{{"embedding": []float32{0.1, 0.2}, "index": 0}},
+		})
+	}))
+	defer server.Close()
+
+	emb := NewOpenAICompatibleEmbedding(EmbeddingConfig{
+		BaseURL:    server.URL,
+		Model:      "test-model",
+		HTTPClient: server.Client(),
+	})
+
+	const callers = 5
+	results := make([][]float32, callers)
+	var wg sync.WaitGroup
+	for i := range callers {
+		wg.Go(func() {
+			result, err := emb.Embed(context.Background(), "same query")
+			if err != nil {
+				t.Errorf("Embed failed: %v", err)
+			}
+			results[i] = result
+		})
+	}
+
+	waitForWaiters(t, emb, callers)
+	close(release)
+	wg.Wait()
+
+	if n := requests.Load(); n != 1 {
+		t.Errorf("Expected 1 upstream request, got %d", n)
+	}
+
+	// Callers own their results
+	results[0][0] = 9
+	for i, result := range results[1:] {
+		if len(result) != 2 || result[0] != 0.1 {
+			t.Errorf("Unexpected result %d: %v", i+1, result)
+		}
+	}
+
+	// Different texts are not deduplicated
+	emb.Embed(context.Background(), "other query")
+	if n := requests.Load(); n != 2 {
+		t.Errorf("Expected 2 upstream requests, got %d", n)
+	}
+}
+
+func TestEmbedDeduplicationCallerCancel(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"data": []map[string]any{{"embedding": []float32{0.5}, "index": 0}},
+		})
+	}))
+	defer server.Close()
+
+	emb := NewOpenAICompatibleEmbedding(EmbeddingConfig{
+		BaseURL:    server.URL,
+		Model:      "test-model",
+		HTTPClient: server.Client(),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := emb.Embed(ctx, "query")
+		firstErr <- err
+	}()
+	waitForWaiters(t, emb, 1)
+
+	second := make(chan []float32, 1)
+	go func() {
+		result, err := emb.Embed(context.Background(), "query")
+		if err != nil {
+			t.Errorf("Embed failed: %v", err)
+		}
+		second <- result
+	}()
+	waitForWaiters(t, emb, 2)
+
+	// The first caller leaves, the shared request goes on for the second
+	cancel()
+	if err := <-firstErr; err == nil {
+		t.Error("Expected error for cancelled caller")
+	}
+	close(release)
+
+	if result := <-second; len(result) != 1 || result[0] != 0.5 {
+		t.Errorf("Unexpected result: %v", result)
+	}
+}
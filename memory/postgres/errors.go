@@ -0,0 +1,29 @@
+package memory
+
+import (
+	"errors"
+
+	"github.com/kydenul/k-adk/internal/pgerr"
+)
+
+var (
+	// ErrEmbeddingFailed is returned when the embedding model fails to embed a text
+	// the operation cannot do without.
+	ErrEmbeddingFailed = errors.New("embedding failed")
+
+	// ErrInvalidTextSearchConfig is returned for a text search configuration that is
+	// not a PostgreSQL identifier.
+	ErrInvalidTextSearchConfig = errors.New("invalid text search configuration")
+
+	// ErrInvalidSimilarity is returned for a similarity threshold outside [-1, 1].
+	ErrInvalidSimilarity = errors.New("invalid similarity threshold")
+
+	// ErrMemoryNotFound is returned when a memory entry to update or delete does not exist.
+	ErrMemoryNotFound = errors.New("memory entry not found")
+
+	// ErrConnection is returned when PostgreSQL cannot be reached.
+	ErrConnection = pgerr.ErrConnection
+
+	// ErrConstraintViolation is returned when a write violates a constraint.
+	ErrConstraintViolation = pgerr.ErrConstraint
+)
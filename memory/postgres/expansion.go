@@ -0,0 +1,228 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	memorytypes "github.com/kydenul/k-adk/memory/types"
+	"google.golang.org/adk/memory"
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+const (
+	defaultExpansionQueries = 3
+
+	// defaultSearchLimit is the number of memories a search returns by default.
+	defaultSearchLimit = 10
+
+	// rrfK dampens the weight of top ranks in reciprocal rank fusion.
+	rrfK = 60
+
+	hydePrompt = `You write short passages for a search index of past conversations.
+Given the user's query, write a plausible 2-4 sentence answer as it could have appeared
+in an earlier conversation. Do not mention that it is hypothetical. Output only the passage.`
+
+	multiQueryPrompt = `You rewrite search queries for a search index of past conversations.
+Given the user's query, write %d alternative queries that use different words or make
+implicit context explicit. Output one query per line, without numbering.`
+)
+
+// listMarker matches a bullet or number prefix of a generated query line.
+var listMarker = regexp.MustCompile(`^(?:[-*•]|\d+[.)])\s*`)
+
+// QueryExpander rewrites a memory search query into the queries whose embeddings
+// are searched. The original query is always searched as well.
+type QueryExpander interface {
+	Expand(ctx context.Context, query string) ([]string, error)
+}
+
+// HyDEExpander asks an LLM for a hypothetical answer to the query and searches
+// with it (Hypothetical Document Embeddings). A short question is often closer,
+// in embedding space, to a plausible answer than to itself.
+type HyDEExpander struct {
+	Model model.LLM
+}
+
+// NewHyDEExpander creates a HyDEExpander using llm.
+func NewHyDEExpander(llm model.LLM) *HyDEExpander {
+	return &HyDEExpander{Model: llm}
+}
+
+// Expand implements QueryExpander.
+func (e *HyDEExpander) Expand(ctx context.Context, query string) ([]string, error) {
+	text, err := generateText(ctx, e.Model, hydePrompt, query)
+	if err != nil {
+		return nil, err
+	}
+	if text == "" {
+		return nil, nil
+	}
+	return []string{text}, nil
+}
+
+// MultiQueryExpander asks an LLM for alternative phrasings of the query and
+// searches with each of them.
+type MultiQueryExpander struct {
+	Model model.LLM
+
+	// Optional. Queries is the number of alternative queries. Default: 3.
+	Queries int
+}
+
+// NewMultiQueryExpander creates a MultiQueryExpander using llm.
+func NewMultiQueryExpander(llm model.LLM, queries int) *MultiQueryExpander {
+	return &MultiQueryExpander{Model: llm, Queries: queries}
+}
+
+// Expand implements QueryExpander.
+func (e *MultiQueryExpander) Expand(ctx context.Context, query string) ([]string, error) {
+	n := e.Queries
+	if n <= 0 {
+		n = defaultExpansionQueries
+	}
+
+	text, err := generateText(ctx, e.Model, fmt.Sprintf(multiQueryPrompt, n), query)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseExpandedQueries(text, query, n), nil
+}
+
+// parseExpandedQueries returns up to n distinct non-empty lines of text that differ
+// from the original query, stripped of list markers.
+func parseExpandedQueries(text, original string, n int) []string {
+	seen := map[string]bool{strings.ToLower(strings.TrimSpace(original)): true}
+
+	var queries []string
+	for line := range strings.Lines(text) {
+		line = listMarker.ReplaceAllString(strings.TrimSpace(line), "")
+		line = strings.TrimSpace(strings.Trim(line, `"`))
+		if line == "" || seen[strings.ToLower(line)] {
+			continue
+		}
+
+		seen[strings.ToLower(line)] = true
+		queries = append(queries, line)
+		if len(queries) == n {
+			break
+		}
+	}
+
+	return queries
+}
+
+// generateText runs a single non-streaming LLM call and returns its text.
+func generateText(ctx context.Context, llm model.LLM, instruction, query string) (string, error) {
+	if llm == nil {
+		return "", errors.New("query expansion model cannot be nil")
+	}
+
+	req := &model.LLMRequest{
+		Contents: []*genai.Content{genai.NewContentFromText(query, genai.RoleUser)},
+		Config: &genai.GenerateContentConfig{
+			SystemInstruction: genai.NewContentFromText(instruction, genai.RoleUser),
+		},
+	}
+
+	var sb strings.Builder
+	for resp, err := range llm.GenerateContent(ctx, req, false) {
+		if err != nil {
+			return "", fmt.Errorf("query expansion LLM call failed: %w", err)
+		}
+		if resp != nil {
+			sb.WriteString(extractTextFromContent(resp.Content))
+		}
+	}
+
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// expandQuery returns the original query followed by its expansions. Expansion
+// failures are logged and fall back to the original query alone.
+func (s *PostgresMemoryService) expandQuery(ctx context.Context, query string) []string {
+	queries := []string{query}
+	if s.queryExpander == nil {
+		return queries
+	}
+
+	expanded, err := s.queryExpander.Expand(ctx, query)
+	if err != nil {
+		s.logger.Warnf("query expansion failed, searching the original query only: %v", err)
+		return queries
+	}
+
+	s.logger.Debugf("query expanded: query=%q, expansions=%q", query, expanded)
+
+	return append(queries, expanded...)
+}
+
+// searchByExpandedVectors runs a vector search for every query and merges the
+// results with reciprocal rank fusion. Queries whose embedding fails are skipped.
+func (s *PostgresMemoryService) searchByExpandedVectors(
+	ctx context.Context,
+	req *memory.SearchRequest,
+	queries []string,
+	opts SearchOptions,
+) ([]memorytypes.EntryWithID, error) {
+	var lists [][]memorytypes.EntryWithID
+	for _, q := range queries {
+		embedding, err := s.embeddingModel.Embed(ctx, q)
+		if err != nil || len(embedding) == 0 {
+			s.logger.Warnf("failed to embed expanded query %q: %v", q, err)
+			continue
+		}
+
+		entries, err := s.searchByVectorWithID(ctx, req, embedding, opts)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, entries)
+	}
+
+	return fuseRankings(lists, s.candidateLimit(opts.Limit)), nil
+}
+
+// fuseRankings merges ranked result lists with reciprocal rank fusion and returns
+// the top limit entries.
+func fuseRankings(lists [][]memorytypes.EntryWithID, limit int) []memorytypes.EntryWithID {
+	type fused struct {
+		entry memorytypes.EntryWithID
+		score float64
+		first int // order of first appearance, to keep ties stable
+	}
+
+	byID := make(map[int]*fused)
+	var order []*fused
+	for _, list := range lists {
+		for rank, entry := range list {
+			f, ok := byID[entry.ID]
+			if !ok {
+				f = &fused{entry: entry, first: len(order)}
+				byID[entry.ID] = f
+				order = append(order, f)
+			}
+			f.score += 1.0 / float64(rrfK+rank+1)
+		}
+	}
+
+	slices.SortStableFunc(order, func(a, b *fused) int {
+		if c := cmp.Compare(b.score, a.score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.first, b.first)
+	})
+
+	merged := make([]memorytypes.EntryWithID, 0, min(len(order), limit))
+	for _, f := range order[:min(len(order), limit)] {
+		merged = append(merged, f.entry)
+	}
+
+	return merged
+}
@@ -0,0 +1,119 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"testing"
+
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	memorytypes "github.com/kydenul/k-adk/memory/types"
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+// fakeLLM implements model.LLM and returns a fixed answer.
+type fakeLLM struct {
+	answer string
+	err    error
+	req    *model.LLMRequest
+}
+
+func (*fakeLLM) Name() string { return "fake" }
+
+func (f *fakeLLM) GenerateContent(
+	_ context.Context,
+	req *model.LLMRequest,
+	_ bool,
+) iter.Seq2[*model.LLMResponse, error] {
+	f.req = req
+	return func(yield func(*model.LLMResponse, error) bool) {
+		if f.err != nil {
+			yield(nil, f.err)
+			return
+		}
+		yield(&model.LLMResponse{Content: genai.NewContentFromText(f.answer, genai.RoleModel)}, nil)
+	}
+}
+
+func TestHyDEExpander(t *testing.T) {
+	llm := &fakeLLM{answer: "  We decided to deploy the Go service on Fridays.  "}
+
+	got, err := NewHyDEExpander(llm).Expand(context.Background(), "deploy day?")
+	if err != nil {
+		t.Fatalf("Expand failed: %v", err)
+	}
+	if len(got) != 1 || got[0] != "We decided to deploy the Go service on Fridays." {
+		t.Errorf("Expand() = %q", got)
+	}
+	if llm.req == nil || llm.req.Config.SystemInstruction == nil {
+		t.Error("expected a system instruction in the request")
+	}
+
+	llm.err = errors.New("boom")
+	if _, err := NewHyDEExpander(llm).Expand(context.Background(), "deploy day?"); err == nil {
+		t.Error("expected error from failing LLM")
+	}
+}
+
+func TestMultiQueryExpander(t *testing.T) {
+	llm := &fakeLLM{answer: "1. Which day do we deploy?\n- deploy day?\n\n* Release schedule for the Go service\n\"Friday deployments\"\n2025 roadmap"}
+
+	got, err := NewMultiQueryExpander(llm, 4).Expand(context.Background(), "Deploy day?")
+	if err != nil {
+		t.Fatalf("Expand failed: %v", err)
+	}
+
+	want := []string{
+		"Which day do we deploy?",
+		"Release schedule for the Go service",
+		"Friday deployments",
+		"2025 roadmap",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expand() = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Expand()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestFuseRankings(t *testing.T) {
+	entry := func(id int) memorytypes.EntryWithID { return memorytypes.EntryWithID{ID: id} }
+
+	lists := [][]memorytypes.EntryWithID{
+		{entry(1), entry(2), entry(3)},
+		{entry(3), entry(4)},
+		{entry(3), entry(1)},
+	}
+
+	got := fuseRankings(lists, 3)
+
+	wantIDs := []int{3, 1, 2}
+	if len(got) != len(wantIDs) {
+		t.Fatalf("fuseRankings() returned %d entries, want %d", len(got), len(wantIDs))
+	}
+	for i, id := range wantIDs {
+		if got[i].ID != id {
+			t.Errorf("fuseRankings()[%d].ID = %d, want %d", i, got[i].ID, id)
+		}
+	}
+
+	if got := fuseRankings(nil, 10); len(got) != 0 {
+		t.Errorf("fuseRankings(nil) = %v, want empty", got)
+	}
+}
+
+func TestExpandQueryFallback(t *testing.T) {
+	svc := &PostgresMemoryService{
+		logger:        discardlog.NewDiscardLog(),
+		queryExpander: NewHyDEExpander(&fakeLLM{err: errors.New("unavailable")}),
+	}
+
+	got := svc.expandQuery(context.Background(), "deploy day?")
+	if len(got) != 1 || got[0] != "deploy day?" {
+		t.Errorf("expandQuery() = %q, want original query only", got)
+	}
+}
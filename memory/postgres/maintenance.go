@@ -0,0 +1,302 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	vectorIndexName = "idx_memory_embedding"
+
+	// Below this many rows per list IVFFlat clusters are too sparse to be useful.
+	minIVFFlatLists = 10
+
+	// A rebuild is triggered when the ideal list count, or the row count since the
+	// last build, has drifted by this factor.
+	reindexDriftFactor = 2.0
+
+	// Interval between two scheduled runs when StartMaintenance is given none.
+	defaultMaintenanceInterval = time.Hour
+)
+
+// ReindexPolicy controls whether Maintain rebuilds the vector index.
+type ReindexPolicy int
+
+const (
+	// ReindexNever only refreshes statistics and probes.
+	ReindexNever ReindexPolicy = iota
+
+	// ReindexAuto rebuilds the index when its list count no longer fits the row
+	// count, or when the table has doubled since the index was built (IVFFlat
+	// centroids are trained once, at build time).
+	ReindexAuto
+
+	// ReindexAlways rebuilds the index on every run.
+	ReindexAlways
+)
+
+// MaintainOptions configures a maintenance run.
+type MaintainOptions struct {
+	// Optional. Reindex policy for the IVFFlat index. Default: ReindexNever.
+	Reindex ReindexPolicy
+
+	// Optional. Probes overrides the tuned ivfflat.probes used by vector searches.
+	// Default: sqrt(lists).
+	Probes int
+}
+
+// MaintenanceReport describes what a maintenance run did.
+type MaintenanceReport struct {
+	Rows      int64         `json:"rows"`
+	Lists     int           `json:"lists"`
+	Probes    int           `json:"probes"`
+	Reindexed bool          `json:"reindexed"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// Maintain keeps vector search quality stable after bulk ingestion: it refreshes
+// the planner statistics (ANALYZE), optionally rebuilds the IVFFlat index with a
+// list count sized for the current row count, and tunes the probes used by
+// subsequent vector searches of this service.
+//
+// Without an embedding model, or with DistributedSQL, only ANALYZE is run.
+func (s *PostgresMemoryService) Maintain(
+	ctx context.Context,
+	opts MaintainOptions,
+) (*MaintenanceReport, error) {
+	start := time.Now()
+
+	if _, err := s.db.ExecContext(ctx, `ANALYZE memory_entries`); err != nil {
+		s.logger.Errorf("failed to analyze memory_entries: %v", err)
+		return nil, fmt.Errorf("failed to analyze memory_entries: %w", err)
+	}
+
+	report := &MaintenanceReport{}
+
+	// NOTE: There is no vector index to tune with DistributedSQL
+	if s.embeddingDim == 0 || s.distributedSQL {
+		report.Duration = time.Since(start)
+		return report, nil
+	}
+
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM memory_entries WHERE embedding IS NOT NULL`,
+	).Scan(&report.Rows); err != nil {
+		return nil, fmt.Errorf("failed to count embedded entries: %w", err)
+	}
+
+	lists, builtRows, err := s.vectorIndexInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	wantLists := optimalLists(report.Rows)
+	if needsReindex(opts.Reindex, lists, wantLists, builtRows, report.Rows) {
+		if err := s.rebuildVectorIndex(ctx, wantLists, report.Rows); err != nil {
+			return nil, err
+		}
+		lists = wantLists
+		report.Reindexed = true
+	}
+	report.Lists = lists
+
+	report.Probes = opts.Probes
+	if report.Probes <= 0 {
+		report.Probes = optimalProbes(lists)
+	}
+	s.probes.Store(int32(report.Probes)) //nolint:gosec // bounded by the list count
+
+	report.Duration = time.Since(start)
+
+	s.logger.Infof("memory maintenance completed: rows=%d, lists=%d, probes=%d, reindexed=%v, duration=%s",
+		report.Rows, report.Lists, report.Probes, report.Reindexed, report.Duration)
+
+	return report, nil
+}
+
+// StartMaintenance runs Maintain every interval until ctx is cancelled or the
+// returned stop function is called. The first run happens immediately. A
+// non-positive interval defaults to one hour.
+func (s *PostgresMemoryService) StartMaintenance(
+	ctx context.Context,
+	interval time.Duration,
+	opts MaintainOptions,
+) (stop func()) {
+	if interval <= 0 {
+		s.logger.Warnf("invalid memory maintenance interval %s, using %s", interval, defaultMaintenanceInterval)
+		interval = defaultMaintenanceInterval
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := s.Maintain(ctx, opts); err != nil && ctx.Err() == nil {
+				s.logger.Warnf("scheduled memory maintenance failed: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// vectorIndexInfo returns the list count of the vector index and the row count
+// recorded when it was last built by Maintain (0 if unknown).
+func (s *PostgresMemoryService) vectorIndexInfo(ctx context.Context) (lists int, builtRows int64, err error) {
+	var (
+		reloptions sql.NullString
+		comment    sql.NullString
+	)
+
+	err = s.db.QueryRowContext(ctx, `
+		SELECT array_to_string(c.reloptions, ','), obj_description(c.oid, 'pg_class')
+		FROM pg_class c
+		WHERE c.relname = $1 AND c.relkind = 'i'
+	`, vectorIndexName).Scan(&reloptions, &comment)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read vector index info: %w", err)
+	}
+
+	return parseIntOption(reloptions.String, "lists"), int64(parseIntOption(comment.String, "rows")), nil
+}
+
+// rebuildVectorIndex builds a new index with lists next to the current one, then
+// swaps them, so searches keep using an index during the rebuild.
+func (s *PostgresMemoryService) rebuildVectorIndex(ctx context.Context, lists int, rows int64) error {
+	const tmpName = vectorIndexName + "_new"
+
+	stmts := []string{
+		`DROP INDEX CONCURRENTLY IF EXISTS ` + tmpName,
+		fmt.Sprintf(`CREATE INDEX CONCURRENTLY %s ON memory_entries
+			USING ivfflat (embedding vector_cosine_ops) WITH (lists = %d)`, tmpName, lists),
+		`DROP INDEX CONCURRENTLY IF EXISTS ` + vectorIndexName,
+		`ALTER INDEX ` + tmpName + ` RENAME TO ` + vectorIndexName,
+		fmt.Sprintf(`COMMENT ON INDEX %s IS 'rows=%d'`, vectorIndexName, rows),
+	}
+
+	for _, stmt := range stmts {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			s.logger.Errorf("failed to rebuild vector index: stmt=%q, err=%v", stmt, err)
+			return fmt.Errorf("failed to rebuild vector index: %w", err)
+		}
+	}
+
+	s.logger.Infof("vector index rebuilt: lists=%d, rows=%d", lists, rows)
+
+	return nil
+}
+
+// queryVector runs a vector search query with the tuned ivfflat.probes. The
+// returned function closes rows and ends the transaction, if any.
+func (s *PostgresMemoryService) queryVector(
+	ctx context.Context,
+	query string,
+	args ...any,
+) (*sql.Rows, func(), error) {
+	probes := s.probes.Load()
+	if probes <= 0 {
+		rows, err := s.stmts.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, nil, err
+		}
+		return rows, func() { _ = rows.Close() }, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// SET does not take bind parameters; probes is an integer
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`SET LOCAL ivfflat.probes = %d`, probes)); err != nil {
+		_ = tx.Rollback()
+		return nil, nil, fmt.Errorf("failed to set ivfflat.probes: %w", err)
+	}
+
+	rows, err := s.stmts.Tx(tx).QueryContext(ctx, query, args...)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, nil, err
+	}
+
+	return rows, func() {
+		_ = rows.Close()
+		_ = tx.Commit()
+	}, nil
+}
+
+// optimalLists follows the pgvector guidance: rows/1000 up to 1M rows, sqrt(rows) above.
+func optimalLists(rows int64) int {
+	lists := int(rows / 1000)
+	if rows > 1_000_000 {
+		lists = int(math.Sqrt(float64(rows)))
+	}
+	return max(lists, minIVFFlatLists)
+}
+
+// optimalProbes follows the pgvector guidance of sqrt(lists).
+func optimalProbes(lists int) int {
+	if lists <= 0 {
+		return 1
+	}
+	return max(int(math.Ceil(math.Sqrt(float64(lists)))), 1)
+}
+
+func needsReindex(policy ReindexPolicy, lists, wantLists int, builtRows, rows int64) bool {
+	switch policy {
+	case ReindexAlways:
+		return true
+
+	case ReindexAuto:
+		if lists <= 0 {
+			return true
+		}
+		ratio := float64(max(lists, wantLists)) / float64(min(lists, wantLists))
+		if ratio >= reindexDriftFactor {
+			return true
+		}
+		return rows > 0 && float64(rows) >= reindexDriftFactor*float64(builtRows)
+
+	default:
+		return false
+	}
+}
+
+// parseIntOption extracts key=N from a comma separated option list.
+func parseIntOption(s, key string) int {
+	for opt := range strings.SplitSeq(s, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(opt), "=")
+		if !ok || k != key {
+			continue
+		}
+		n, err := strconv.Atoi(strings.Trim(v, `'"`))
+		if err == nil {
+			return n
+		}
+	}
+	return 0
+}
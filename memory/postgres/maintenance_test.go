@@ -0,0 +1,136 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestOptimalLists(t *testing.T) {
+	tests := []struct {
+		rows int64
+		want int
+	}{
+		{0, minIVFFlatLists},
+		{5_000, minIVFFlatLists},
+		{250_000, 250},
+		{1_000_000, 1000},
+		{4_000_000, 2000},
+	}
+
+	for _, tt := range tests {
+		if got := optimalLists(tt.rows); got != tt.want {
+			t.Errorf("optimalLists(%d) = %d, want %d", tt.rows, got, tt.want)
+		}
+	}
+}
+
+func TestOptimalProbes(t *testing.T) {
+	tests := []struct {
+		lists int
+		want  int
+	}{
+		{0, 1},
+		{1, 1},
+		{10, 4},
+		{100, 10},
+		{1000, 32},
+	}
+
+	for _, tt := range tests {
+		if got := optimalProbes(tt.lists); got != tt.want {
+			t.Errorf("optimalProbes(%d) = %d, want %d", tt.lists, got, tt.want)
+		}
+	}
+}
+
+func TestNeedsReindex(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    ReindexPolicy
+		lists     int
+		wantLists int
+		builtRows int64
+		rows      int64
+		want      bool
+	}{
+		{"never", ReindexNever, 100, 1000, 0, 1_000_000, false},
+		{"always", ReindexAlways, 100, 100, 100_000, 100_000, true},
+		{"auto missing index", ReindexAuto, 0, 10, 0, 0, true},
+		{"auto lists drift", ReindexAuto, 100, 250, 100_000, 150_000, true},
+		{"auto built on empty table", ReindexAuto, 100, 100, 0, 100_000, true},
+		{"auto table doubled", ReindexAuto, 100, 150, 70_000, 150_000, true},
+		{"auto stable", ReindexAuto, 100, 120, 100_000, 120_000, false},
+		{"auto empty table", ReindexAuto, 10, 10, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := needsReindex(tt.policy, tt.lists, tt.wantLists, tt.builtRows, tt.rows)
+			if got != tt.want {
+				t.Errorf("needsReindex() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseIntOption(t *testing.T) {
+	tests := []struct {
+		s, key string
+		want   int
+	}{
+		{"lists=100", "lists", 100},
+		{"fillfactor=90,lists=250", "lists", 250},
+		{"rows=12345", "rows", 12345},
+		{"lists='42'", "lists", 42},
+		{"", "lists", 0},
+		{"lists=abc", "lists", 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseIntOption(tt.s, tt.key); got != tt.want {
+			t.Errorf("parseIntOption(%q, %q) = %d, want %d", tt.s, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestMaintainWithoutEmbeddings(t *testing.T) {
+	svc := setupTestDB(t)
+	if svc == nil {
+		return
+	}
+	defer svc.Close()
+
+	report, err := svc.Maintain(context.Background(), MaintainOptions{Reindex: ReindexAuto})
+	if err != nil {
+		t.Fatalf("Maintain failed: %v", err)
+	}
+	if report.Reindexed || report.Probes != 0 {
+		t.Errorf("expected ANALYZE only without embeddings, got %+v", report)
+	}
+}
+
+func TestStartMaintenance(t *testing.T) {
+	svc := setupTestDB(t)
+	if svc == nil {
+		return
+	}
+	defer svc.Close()
+
+	stop := svc.StartMaintenance(context.Background(), 10*time.Millisecond, MaintainOptions{})
+	time.Sleep(30 * time.Millisecond)
+	stop()
+}
+
+func TestStartMaintenanceZeroInterval(t *testing.T) {
+	svc := setupTestDB(t)
+	if svc == nil {
+		return
+	}
+	defer svc.Close()
+
+	// Must fall back to the default interval instead of panicking.
+	stop := svc.StartMaintenance(context.Background(), 0, MaintainOptions{})
+	time.Sleep(30 * time.Millisecond)
+	stop()
+}
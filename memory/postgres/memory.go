@@ -4,10 +4,13 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/bytedance/sonic"
 	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/k-adk/internal/pgerr"
+	"github.com/kydenul/k-adk/internal/stmtcache"
 	memorytypes "github.com/kydenul/k-adk/memory/types"
 	"github.com/kydenul/log"
 	"google.golang.org/adk/memory"
@@ -31,9 +34,30 @@ var (
 // PostgresMemoryService implements memory.Service using PostgresSQL with pgvector
 type PostgresMemoryService struct {
 	db             *sql.DB
+	stmts          *stmtcache.Cache
 	logger         log.Logger
 	embeddingModel EmbeddingModel
 	embeddingDim   int
+	queryExpander  QueryExpander
+
+	reranker         Reranker
+	rerankCandidates int
+
+	// searchLimit and minSimilarity are the default SearchOptions.
+	searchLimit   int
+	minSimilarity float64
+
+	// text renders the searchable text of the memory entries.
+	text textRenderer
+	// textSearchConfig is the text search configuration of the searchable text.
+	textSearchConfig string
+
+	// probes is the ivfflat.probes of vector searches, tuned by Maintain; 0 keeps
+	// the server default.
+	probes atomic.Int32
+
+	// distributedSQL creates the schema without the IVFFlat index.
+	distributedSQL bool
 }
 
 // PgMemSvrConfig holds configuration for PostgresMemoryService.
@@ -45,6 +69,54 @@ type PgMemSvrConfig struct {
 	// EmbeddingModel is used to generate embeddings for semantic search (optional)
 	EmbeddingModel EmbeddingModel
 
+	// Optional. QueryExpander rewrites queries before vector search (e.g., HyDEExpander
+	// or MultiQueryExpander); results across the expanded queries are merged.
+	// Only used with an EmbeddingModel.
+	QueryExpander QueryExpander
+
+	// Optional. Reranker reorders the top search candidates (e.g., HTTPReranker with
+	// a cross-encoder) before they are returned.
+	Reranker Reranker
+
+	// Optional. RerankCandidates is the number of candidates fetched for the reranker.
+	// Default: 50.
+	RerankCandidates int
+
+	// Optional. SearchLimit is the number of memories a search returns, unless
+	// its context sets another with WithSearchOptions. Default: 10.
+	SearchLimit int
+
+	// Optional. MinSimilarity is the cosine similarity, in [-1, 1], below which the
+	// entries found by vector search are dropped, unless the context of the search
+	// sets another with WithSearchOptions. See SearchOptions.MinSimilarity.
+	// Default: 0, no threshold.
+	MinSimilarity float64
+
+	// Optional. ToolText controls how the tool calls and responses of the events
+	// are rendered into their searchable text, so tool-driven sessions can be
+	// recalled. Default: ToolTextFull.
+	ToolText ToolTextMode
+
+	// Optional. MaxToolTextLength is the number of characters of the rendered
+	// arguments or result of a tool, beyond which they are truncated. Default: 1000.
+	MaxToolTextLength int
+
+	// Optional. TextSearchConfig is the PostgreSQL text search configuration of the
+	// full-text search, e.g. "simple" for memories in several languages, or "german".
+	// Default: "english".
+	TextSearchConfig string
+
+	// Optional. DistributedSQL creates the schema for CockroachDB and YugabyteDB:
+	// the embedding column is added without PL/pgSQL and without the IVFFlat
+	// index, so vector searches scan the entries of the user, and Maintain only
+	// refreshes the statistics. Default: false.
+	DistributedSQL bool
+
+	// Optional. DisableStatementCache runs the search and insert queries
+	// unprepared. Set it behind poolers that cannot keep prepared statements, such
+	// as PgBouncer in transaction mode.
+	DisableStatementCache bool
+
 	// Optional. Falls back to DiscardLog if nil.
 	Logger log.Logger
 }
@@ -59,6 +131,25 @@ func NewPostgresMemoryService(
 		cfg.Logger = &discardlog.DiscardLog{}
 	}
 
+	if cfg.RerankCandidates <= 0 {
+		cfg.RerankCandidates = defaultRerankCandidates
+	}
+	if cfg.SearchLimit <= 0 {
+		cfg.SearchLimit = defaultSearchLimit
+	}
+	if err := validateSimilarity(cfg.MinSimilarity); err != nil {
+		return nil, err
+	}
+	if cfg.MaxToolTextLength <= 0 {
+		cfg.MaxToolTextLength = defaultMaxToolTextLength
+	}
+	if cfg.TextSearchConfig == "" {
+		cfg.TextSearchConfig = defaultTextSearchConfig
+	}
+	if !validTextSearchConfig.MatchString(cfg.TextSearchConfig) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTextSearchConfig, cfg.TextSearchConfig)
+	}
+
 	// NOTE: Open and connect to PostgresSQL
 	db, err := sql.Open("postgres", cfg.ConnStr)
 	if err != nil {
@@ -68,7 +159,7 @@ func NewPostgresMemoryService(
 
 	if err := db.PingContext(ctx); err != nil {
 		cfg.Logger.Errorf("failed to connect to database: %v", err)
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+		return nil, pgerr.Wrap("failed to connect to database", err)
 	}
 
 	// NOTE: Embedding Dimension
@@ -80,7 +171,7 @@ func NewPostgresMemoryService(
 			embedding, err := cfg.EmbeddingModel.Embed(ctx, "dimension probe")
 			if err != nil {
 				cfg.Logger.Errorf("failed to probe embedding dimension: %v", err)
-				return nil, fmt.Errorf("failed to probe embedding dimension: %w", err)
+				return nil, fmt.Errorf("failed to probe embedding dimension: %w: %w", ErrEmbeddingFailed, err)
 			}
 
 			embeddingDim = len(embedding)
@@ -89,9 +180,21 @@ func NewPostgresMemoryService(
 
 	svc := &PostgresMemoryService{
 		db:             db,
+		stmts:          stmtcache.New(db, cfg.DisableStatementCache),
 		embeddingModel: cfg.EmbeddingModel,
 		embeddingDim:   embeddingDim,
+		queryExpander:  cfg.QueryExpander,
 		logger:         cfg.Logger,
+
+		reranker:         cfg.Reranker,
+		rerankCandidates: cfg.RerankCandidates,
+
+		searchLimit:   cfg.SearchLimit,
+		minSimilarity: cfg.MinSimilarity,
+
+		text:             textRenderer{mode: cfg.ToolText, maxLength: cfg.MaxToolTextLength},
+		textSearchConfig: cfg.TextSearchConfig,
+		distributedSQL:   cfg.DistributedSQL,
 	}
 
 	if err := svc.initSchema(ctx); err != nil {
@@ -127,8 +230,15 @@ func (s *PostgresMemoryService) initSchema(ctx context.Context) error {
 		CREATE INDEX IF NOT EXISTS idx_memory_app_user ON memory_entries(app_name, user_id);
 		CREATE INDEX IF NOT EXISTS idx_memory_session ON memory_entries(session_id);
 		CREATE INDEX IF NOT EXISTS idx_memory_timestamp ON memory_entries(timestamp);
-		CREATE INDEX IF NOT EXISTS idx_memory_content_text ON memory_entries USING gin(to_tsvector('english', content_text));
 	`
+	// NOTE: The default index keeps its name from before the configuration was settable
+	textIndex := "idx_memory_content_text"
+	if s.textSearchConfig != defaultTextSearchConfig {
+		textIndex += "_" + s.textSearchConfig
+	}
+	baseSchema += fmt.Sprintf(`
+		CREATE INDEX IF NOT EXISTS %s ON memory_entries USING gin(to_tsvector('%s', content_text));
+	`, textIndex, s.textSearchConfig)
 
 	if _, err := s.db.ExecContext(ctx, baseSchema); err != nil {
 		s.logger.Errorf("failed to create base schema: %v", err)
@@ -136,6 +246,9 @@ func (s *PostgresMemoryService) initSchema(ctx context.Context) error {
 	}
 
 	// Add vector column if embedding model is configured
+	if s.embeddingDim > 0 && s.distributedSQL {
+		return s.initDistributedVectorSchema(ctx)
+	}
 	if s.embeddingDim > 0 {
 		vectorSchema := fmt.Sprintf(`
 			-- Enable pgvector extension
@@ -173,6 +286,23 @@ func (s *PostgresMemoryService) initSchema(ctx context.Context) error {
 	return nil
 }
 
+// initDistributedVectorSchema adds the vector column on CockroachDB and
+// YugabyteDB, which have no DO blocks nor IVFFlat index.
+func (s *PostgresMemoryService) initDistributedVectorSchema(ctx context.Context) error {
+	// NOTE: CockroachDB has a native vector type, YugabyteDB the pgvector extension
+	if _, err := s.db.ExecContext(ctx, `CREATE EXTENSION IF NOT EXISTS vector`); err != nil {
+		s.logger.Warnf("failed to create the vector extension, assuming a native vector type: %v", err)
+	}
+
+	stmt := fmt.Sprintf(`ALTER TABLE memory_entries ADD COLUMN IF NOT EXISTS embedding vector(%d)`, s.embeddingDim)
+	if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+		s.logger.Errorf("failed to create vector schema: %v", err)
+		return fmt.Errorf("failed to create vector schema: %w", err)
+	}
+
+	return nil
+}
+
 // AddSession extracts memory entries from a session and stores them.
 func (s *PostgresMemoryService) AddSession(ctx context.Context, sess session.Session) error {
 	events := sess.Events()
@@ -187,14 +317,14 @@ func (s *PostgresMemoryService) AddSession(ctx context.Context, sess session.Ses
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		s.logger.Errorf("failed to begin transaction: %v", err)
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return pgerr.Wrap("failed to begin transaction", err)
 	}
 	defer func() { _ = tx.Rollback() }()
 
 	// Prepare statement base on whether we have embeddings
 	var stmt *sql.Stmt
 	if s.embeddingModel != nil {
-		stmt, err = tx.PrepareContext(ctx, `
+		stmt, err = s.stmts.Tx(tx).Stmt(ctx, `
 			INSERT INTO memory_entries
 			(app_name, user_id, session_id, event_id, author, content, content_text, embedding, timestamp)
 			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
@@ -202,7 +332,7 @@ func (s *PostgresMemoryService) AddSession(ctx context.Context, sess session.Ses
 			SET content = EXCLUDED.content, content_text = EXCLUDED.content_text, embedding = EXCLUDED.embedding
 		`)
 	} else {
-		stmt, err = tx.PrepareContext(ctx, `
+		stmt, err = s.stmts.Tx(tx).Stmt(ctx, `
 			INSERT INTO memory_entries (app_name, user_id, session_id, event_id, author, content, content_text, timestamp)
 			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 			ON CONFLICT (app_name, user_id, session_id, event_id) DO UPDATE
@@ -212,7 +342,7 @@ func (s *PostgresMemoryService) AddSession(ctx context.Context, sess session.Ses
 
 	if err != nil {
 		s.logger.Errorf("failed to prepare statement: %v", err)
-		return fmt.Errorf("failed to prepare statement: %w", err)
+		return pgerr.Wrap("failed to prepare statement", err)
 	}
 	defer func() { _ = stmt.Close() }()
 
@@ -226,8 +356,8 @@ func (s *PostgresMemoryService) AddSession(ctx context.Context, sess session.Ses
 			continue
 		}
 
-		// Extract text content
-		text := extractTextFromContent(event.Content)
+		// Extract the searchable text, tool calls and responses included
+		text := s.text.render(event.Content)
 		if text == "" {
 			skippedCount++
 			continue
@@ -295,7 +425,7 @@ func (s *PostgresMemoryService) AddSession(ctx context.Context, sess session.Ses
 
 	if err := tx.Commit(); err != nil {
 		s.logger.Errorf("failed to commit transaction: %v", err)
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		return pgerr.Wrap("failed to commit transaction", err)
 	}
 
 	s.logger.Infof("session added to memory: session=%s, inserted=%d, skipped=%d, errors=%d",
@@ -304,8 +434,13 @@ func (s *PostgresMemoryService) AddSession(ctx context.Context, sess session.Ses
 	return nil
 }
 
-// Close closes the database connection.
-func (s *PostgresMemoryService) Close() error { return s.db.Close() }
+// Close closes the prepared statements and the database connection.
+func (s *PostgresMemoryService) Close() error {
+	if err := s.stmts.Close(); err != nil {
+		s.logger.Warnf("failed to close prepared statements: %v", err)
+	}
+	return s.db.Close()
+}
 
 // DB returns the underlying database connection for testing purposes.
 func (s *PostgresMemoryService) DB() *sql.DB { return s.db }
@@ -318,28 +453,51 @@ func (s *PostgresMemoryService) Search(
 	s.logger.Debugf("searching memories: app=%s, user=%s, query=%q",
 		req.AppName, req.UserID, req.Query)
 
+	opts, err := s.searchOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	var (
-		memories   []memory.Entry
-		err        error
-		searchType string
+		memories       []memory.Entry
+		searchType     string
+		vectorSearched bool
 	)
 
 	// NOTE: If we have an embedding model and a query, try vector search first
 	if s.embeddingModel != nil && req.Query != "" {
-		embedding, embErr := s.embeddingModel.Embed(ctx, req.Query)
-		if embErr == nil && len(embedding) > 0 {
-			memories, err = s.searchByVector(ctx, req, embedding)
+		if queries := s.expandQuery(ctx, req.Query); len(queries) > 1 {
+			entries, err := s.searchByExpandedVectors(ctx, req, queries, opts)
 			if err != nil {
-				s.logger.Errorf("failed to search by vector: %v", err)
+				s.logger.Errorf("failed to search by expanded vectors: %v", err)
 				return nil, err
 			}
-			searchType = "vector"
+			vectorSearched = true
+			for _, e := range entries {
+				memories = append(memories, memory.Entry{
+					Content:   e.Content,
+					Author:    e.Author,
+					Timestamp: e.Timestamp,
+				})
+			}
+			searchType = "vector_expanded"
+		} else {
+			embedding, embErr := s.embeddingModel.Embed(ctx, req.Query)
+			if embErr == nil && len(embedding) > 0 {
+				memories, err = s.searchByVector(ctx, req, embedding, opts)
+				if err != nil {
+					s.logger.Errorf("failed to search by vector: %v", err)
+					return nil, err
+				}
+				vectorSearched = true
+				searchType = "vector"
+			}
 		}
 	}
 
 	// NOTE: Fallback to text search if no results or no embedding model
 	if len(memories) == 0 && req.Query != "" {
-		memories, err = s.searchByText(ctx, req)
+		memories, err = s.searchByText(ctx, req, opts)
 		if err != nil {
 			s.logger.Errorf("failed to search by text: %v", err)
 			return nil, err
@@ -347,9 +505,10 @@ func (s *PostgresMemoryService) Search(
 		searchType = "text"
 	}
 
-	// NOTE: If still no results and query is empty, return recent entries
-	if len(memories) == 0 {
-		memories, err = s.searchRecent(ctx, req)
+	// NOTE: If still no results, return recent entries, unless the similarity
+	// threshold of a vector search found none relevant to the query
+	if len(memories) == 0 && (!vectorSearched || opts.MinSimilarity == 0) {
+		memories, err = s.searchRecent(ctx, req, opts)
 		if err != nil {
 			s.logger.Errorf("failed to search recent: %v", err)
 			return nil, err
@@ -357,6 +516,12 @@ func (s *PostgresMemoryService) Search(
 		searchType = "recent"
 	}
 
+	// NOTE: Rerank query results (recent entries have no query to rank against)
+	if searchType != "recent" {
+		memories = rerankEntries(ctx, s, req.Query, memories,
+			func(e memory.Entry) *genai.Content { return e.Content }, opts.Limit)
+	}
+
 	s.logger.Debugf("search completed: type=%s, results=%d", searchType, len(memories))
 
 	return &memory.SearchResponse{Memories: memories}, nil
@@ -367,25 +532,28 @@ func (s *PostgresMemoryService) searchByVector(
 	ctx context.Context,
 	req *memory.SearchRequest,
 	embedding []float32,
+	opts SearchOptions,
 ) ([]memory.Entry, error) {
 	s.logger.Debugf("searching by vector: app=%s, user=%s, embedding_dim=%d",
 		req.AppName, req.UserID, len(embedding))
 
+	filter, filterArgs := similarityFilter(opts)
 	query := `
 		SELECT content, author, timestamp
 		FROM memory_entries
-		WHERE app_name = $1 AND user_id = $2 AND embedding IS NOT NULL
+		WHERE app_name = $1 AND user_id = $2 AND embedding IS NOT NULL ` + filter + `
 		ORDER BY embedding <=> $3
-		LIMIT 10
+		LIMIT $4
 	`
 
 	embeddingStr := vectorToString(embedding)
-	rows, err := s.db.QueryContext(ctx, query, req.AppName, req.UserID, embeddingStr)
+	args := append([]any{req.AppName, req.UserID, embeddingStr, s.candidateLimit(opts.Limit)}, filterArgs...)
+	rows, done, err := s.queryVector(ctx, query, args...)
 	if err != nil {
 		s.logger.Errorf("failed to search by vector: %v", err)
-		return nil, fmt.Errorf("failed to search by vector: %w", err)
+		return nil, pgerr.Wrap("failed to search by vector", err)
 	}
-	defer func() { _ = rows.Close() }()
+	defer done()
 
 	return s.scanMemories(rows)
 }
@@ -394,23 +562,24 @@ func (s *PostgresMemoryService) searchByVector(
 func (s *PostgresMemoryService) searchByText(
 	ctx context.Context,
 	req *memory.SearchRequest,
+	opts SearchOptions,
 ) ([]memory.Entry, error) {
 	s.logger.Debugf("searching by text: app=%s, user=%s, query=%q",
 		req.AppName, req.UserID, req.Query)
 
-	query := `
+	query := fmt.Sprintf(`
 		SELECT content, author, timestamp
 		FROM memory_entries
 		WHERE app_name = $1 AND user_id = $2
-		AND to_tsvector('english', content_text) @@ plainto_tsquery('english', $3)
-		ORDER BY ts_rank(to_tsvector('english', content_text), plainto_tsquery('english', $3)) DESC,
+		AND to_tsvector('%[1]s', content_text) @@ plainto_tsquery('%[1]s', $3)
+		ORDER BY ts_rank(to_tsvector('%[1]s', content_text), plainto_tsquery('%[1]s', $3)) DESC,
 		         timestamp DESC
-		LIMIT 10
-		`
-	rows, err := s.db.QueryContext(ctx, query, req.AppName, req.UserID, req.Query)
+		LIMIT $4
+		`, s.textSearchConfig)
+	rows, err := s.stmts.QueryContext(ctx, query, req.AppName, req.UserID, req.Query, s.candidateLimit(opts.Limit))
 	if err != nil {
 		s.logger.Errorf("failed to search by text: %v", err)
-		return nil, fmt.Errorf("failed to search by text: %w", err)
+		return nil, pgerr.Wrap("failed to search by text", err)
 	}
 	defer func() { _ = rows.Close() }()
 
@@ -421,6 +590,7 @@ func (s *PostgresMemoryService) searchByText(
 func (s *PostgresMemoryService) searchRecent(
 	ctx context.Context,
 	req *memory.SearchRequest,
+	opts SearchOptions,
 ) ([]memory.Entry, error) {
 	s.logger.Debugf("searching recent entries: app=%s, user=%s", req.AppName, req.UserID)
 
@@ -429,13 +599,13 @@ func (s *PostgresMemoryService) searchRecent(
 		FROM memory_entries
 		WHERE app_name = $1 AND user_id = $2
 		ORDER BY timestamp DESC
-		LIMIT 10
+		LIMIT $3
 	`
 
-	rows, err := s.db.QueryContext(ctx, query, req.AppName, req.UserID)
+	rows, err := s.stmts.QueryContext(ctx, query, req.AppName, req.UserID, opts.Limit)
 	if err != nil {
 		s.logger.Errorf("failed to search recent: %v", err)
-		return nil, fmt.Errorf("failed to search recent: %w", err)
+		return nil, pgerr.Wrap("failed to search recent", err)
 	}
 	defer func() { _ = rows.Close() }()
 
@@ -520,28 +690,44 @@ func (s *PostgresMemoryService) SearchWithID(
 	s.logger.Debugf("searching memories with ID: app=%s, user=%s, query=%q",
 		req.AppName, req.UserID, req.Query)
 
+	opts, err := s.searchOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	var (
-		memories   []memorytypes.EntryWithID
-		err        error
-		searchType string
+		memories       []memorytypes.EntryWithID
+		searchType     string
+		vectorSearched bool
 	)
 
 	// NOTE: If we have an embedding model and a query, try vector search first
 	if s.embeddingModel != nil && req.Query != "" {
-		embedding, embErr := s.embeddingModel.Embed(ctx, req.Query)
-		if embErr == nil && len(embedding) > 0 {
-			memories, err = s.searchByVectorWithID(ctx, req, embedding)
+		if queries := s.expandQuery(ctx, req.Query); len(queries) > 1 {
+			memories, err = s.searchByExpandedVectors(ctx, req, queries, opts)
 			if err != nil {
-				s.logger.Errorf("failed to search by vector with ID: %v", err)
+				s.logger.Errorf("failed to search by expanded vectors with ID: %v", err)
 				return nil, err
 			}
-			searchType = "vector"
+			vectorSearched = true
+			searchType = "vector_expanded"
+		} else {
+			embedding, embErr := s.embeddingModel.Embed(ctx, req.Query)
+			if embErr == nil && len(embedding) > 0 {
+				memories, err = s.searchByVectorWithID(ctx, req, embedding, opts)
+				if err != nil {
+					s.logger.Errorf("failed to search by vector with ID: %v", err)
+					return nil, err
+				}
+				vectorSearched = true
+				searchType = "vector"
+			}
 		}
 	}
 
 	// NOTE: Fallback to text search if no results or no embedding model
 	if len(memories) == 0 && req.Query != "" {
-		memories, err = s.searchByTextWithID(ctx, req)
+		memories, err = s.searchByTextWithID(ctx, req, opts)
 		if err != nil {
 			s.logger.Errorf("failed to search by text with ID: %v", err)
 			return nil, err
@@ -549,9 +735,10 @@ func (s *PostgresMemoryService) SearchWithID(
 		searchType = "text"
 	}
 
-	// NOTE: If still no results and query is empty, return recent entries
-	if len(memories) == 0 {
-		memories, err = s.searchRecentWithID(ctx, req)
+	// NOTE: If still no results, return recent entries, unless the similarity
+	// threshold of a vector search found none relevant to the query
+	if len(memories) == 0 && (!vectorSearched || opts.MinSimilarity == 0) {
+		memories, err = s.searchRecentWithID(ctx, req, opts)
 		if err != nil {
 			s.logger.Errorf("failed to search recent with ID: %v", err)
 			return nil, err
@@ -559,6 +746,12 @@ func (s *PostgresMemoryService) SearchWithID(
 		searchType = "recent"
 	}
 
+	// NOTE: Rerank query results (recent entries have no query to rank against)
+	if searchType != "recent" {
+		memories = rerankEntries(ctx, s, req.Query, memories,
+			func(e memorytypes.EntryWithID) *genai.Content { return e.Content }, opts.Limit)
+	}
+
 	s.logger.Debugf("search with ID completed: type=%s, results=%d", searchType, len(memories))
 
 	return memories, nil
@@ -569,25 +762,28 @@ func (s *PostgresMemoryService) searchByVectorWithID(
 	ctx context.Context,
 	req *memory.SearchRequest,
 	embedding []float32,
+	opts SearchOptions,
 ) ([]memorytypes.EntryWithID, error) {
 	s.logger.Debugf("searching by vector with ID: app=%s, user=%s, embedding_dim=%d",
 		req.AppName, req.UserID, len(embedding))
 
+	filter, filterArgs := similarityFilter(opts)
 	query := `
 		SELECT id, content, author, timestamp
 		FROM memory_entries
-		WHERE app_name = $1 AND user_id = $2 AND embedding IS NOT NULL
+		WHERE app_name = $1 AND user_id = $2 AND embedding IS NOT NULL ` + filter + `
 		ORDER BY embedding <=> $3
-		LIMIT 10
+		LIMIT $4
 	`
 
 	embeddingStr := vectorToString(embedding)
-	rows, err := s.db.QueryContext(ctx, query, req.AppName, req.UserID, embeddingStr)
+	args := append([]any{req.AppName, req.UserID, embeddingStr, s.candidateLimit(opts.Limit)}, filterArgs...)
+	rows, done, err := s.queryVector(ctx, query, args...)
 	if err != nil {
 		s.logger.Errorf("failed to search by vector with ID: %v", err)
-		return nil, fmt.Errorf("failed to search by vector with ID: %w", err)
+		return nil, pgerr.Wrap("failed to search by vector with ID", err)
 	}
-	defer func() { _ = rows.Close() }()
+	defer done()
 
 	return s.scanMemoriesWithID(rows)
 }
@@ -596,23 +792,24 @@ func (s *PostgresMemoryService) searchByVectorWithID(
 func (s *PostgresMemoryService) searchByTextWithID(
 	ctx context.Context,
 	req *memory.SearchRequest,
+	opts SearchOptions,
 ) ([]memorytypes.EntryWithID, error) {
 	s.logger.Debugf("searching by text with ID: app=%s, user=%s, query=%q",
 		req.AppName, req.UserID, req.Query)
 
-	query := `
+	query := fmt.Sprintf(`
 		SELECT id, content, author, timestamp
 		FROM memory_entries
 		WHERE app_name = $1 AND user_id = $2
-		AND to_tsvector('english', content_text) @@ plainto_tsquery('english', $3)
-		ORDER BY ts_rank(to_tsvector('english', content_text), plainto_tsquery('english', $3)) DESC,
+		AND to_tsvector('%[1]s', content_text) @@ plainto_tsquery('%[1]s', $3)
+		ORDER BY ts_rank(to_tsvector('%[1]s', content_text), plainto_tsquery('%[1]s', $3)) DESC,
 		         timestamp DESC
-		LIMIT 10
-		`
-	rows, err := s.db.QueryContext(ctx, query, req.AppName, req.UserID, req.Query)
+		LIMIT $4
+		`, s.textSearchConfig)
+	rows, err := s.stmts.QueryContext(ctx, query, req.AppName, req.UserID, req.Query, s.candidateLimit(opts.Limit))
 	if err != nil {
 		s.logger.Errorf("failed to search by text with ID: %v", err)
-		return nil, fmt.Errorf("failed to search by text with ID: %w", err)
+		return nil, pgerr.Wrap("failed to search by text with ID", err)
 	}
 	defer func() { _ = rows.Close() }()
 
@@ -623,6 +820,7 @@ func (s *PostgresMemoryService) searchByTextWithID(
 func (s *PostgresMemoryService) searchRecentWithID(
 	ctx context.Context,
 	req *memory.SearchRequest,
+	opts SearchOptions,
 ) ([]memorytypes.EntryWithID, error) {
 	s.logger.Debugf("searching recent entries with ID: app=%s, user=%s", req.AppName, req.UserID)
 
@@ -631,13 +829,13 @@ func (s *PostgresMemoryService) searchRecentWithID(
 		FROM memory_entries
 		WHERE app_name = $1 AND user_id = $2
 		ORDER BY timestamp DESC
-		LIMIT 10
+		LIMIT $3
 	`
 
-	rows, err := s.db.QueryContext(ctx, query, req.AppName, req.UserID)
+	rows, err := s.stmts.QueryContext(ctx, query, req.AppName, req.UserID, opts.Limit)
 	if err != nil {
 		s.logger.Errorf("failed to search recent with ID: %v", err)
-		return nil, fmt.Errorf("failed to search recent with ID: %w", err)
+		return nil, pgerr.Wrap("failed to search recent with ID", err)
 	}
 	defer func() { _ = rows.Close() }()
 
@@ -672,7 +870,7 @@ func (s *PostgresMemoryService) UpdateMemory(
 		embedding, embErr := s.embeddingModel.Embed(ctx, newContent)
 		if embErr != nil {
 			s.logger.Errorf("failed to generate embedding for updated content: %v", embErr)
-			return fmt.Errorf("failed to generate embedding for updated content: %w", embErr)
+			return fmt.Errorf("failed to generate embedding for updated content: %w: %w", ErrEmbeddingFailed, embErr)
 		}
 
 		embeddingStr := vectorToString(embedding)
@@ -687,7 +885,7 @@ func (s *PostgresMemoryService) UpdateMemory(
 		)
 		if err != nil {
 			s.logger.Errorf("failed to update memory entry: %v", err)
-			return fmt.Errorf("failed to update memory entry: %w", err)
+			return pgerr.Wrap("failed to update memory entry", err)
 		}
 
 		rowsAffected, err := result.RowsAffected()
@@ -704,7 +902,8 @@ func (s *PostgresMemoryService) UpdateMemory(
 				entryID,
 			)
 			return fmt.Errorf(
-				"memory entry not found: app=%s, user=%s, id=%d",
+				"%w: app=%s, user=%s, id=%d",
+				ErrMemoryNotFound,
 				appName,
 				userID,
 				entryID,
@@ -722,7 +921,7 @@ func (s *PostgresMemoryService) UpdateMemory(
 		)
 		if err != nil {
 			s.logger.Errorf("failed to update memory entry: %v", err)
-			return fmt.Errorf("failed to update memory entry: %w", err)
+			return pgerr.Wrap("failed to update memory entry", err)
 		}
 
 		rowsAffected, err := result.RowsAffected()
@@ -737,7 +936,8 @@ func (s *PostgresMemoryService) UpdateMemory(
 				appName, userID, entryID,
 			)
 			return fmt.Errorf(
-				"memory entry not found: app=%s, user=%s, id=%d",
+				"%w: app=%s, user=%s, id=%d",
+				ErrMemoryNotFound,
 				appName, userID, entryID,
 			)
 		}
@@ -764,7 +964,7 @@ func (s *PostgresMemoryService) DeleteMemory(
 	result, err := s.db.ExecContext(ctx, query, entryID, appName, userID)
 	if err != nil {
 		s.logger.Errorf("failed to delete memory entry: %v", err)
-		return fmt.Errorf("failed to delete memory entry: %w", err)
+		return pgerr.Wrap("failed to delete memory entry", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
@@ -781,7 +981,8 @@ func (s *PostgresMemoryService) DeleteMemory(
 			entryID,
 		)
 		return fmt.Errorf(
-			"memory entry not found: app=%s, user=%s, id=%d",
+			"%w: app=%s, user=%s, id=%d",
+			ErrMemoryNotFound,
 			appName,
 			userID,
 			entryID,
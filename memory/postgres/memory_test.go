@@ -2,6 +2,7 @@ package memory
 
 import (
 	"context"
+	"errors"
 	"iter"
 	"os"
 	"testing"
@@ -410,6 +411,23 @@ func TestSearchIsolationByApp(t *testing.T) {
 	t.Logf("✓ SearchIsolationByApp: app isolation works correctly")
 }
 
+func TestMemoryNotFound(t *testing.T) {
+	svc := setupTestDB(t)
+	defer svc.Close()
+
+	ctx := context.Background()
+
+	err := svc.UpdateMemory(ctx, "test_app", "user1", -1, "updated")
+	if !errors.Is(err, ErrMemoryNotFound) {
+		t.Errorf("UpdateMemory: expected ErrMemoryNotFound, got %v", err)
+	}
+
+	err = svc.DeleteMemory(ctx, "test_app", "user1", -1)
+	if !errors.Is(err, ErrMemoryNotFound) {
+		t.Errorf("DeleteMemory: expected ErrMemoryNotFound, got %v", err)
+	}
+}
+
 func TestClose(t *testing.T) {
 	svc := setupTestDB(t)
 
@@ -0,0 +1,222 @@
+package memory
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/log"
+	"google.golang.org/genai"
+)
+
+// defaultRerankCandidates is the number of search candidates passed to the reranker.
+const defaultRerankCandidates = 50
+
+var errNoRerankResults = errors.New("no rerank results returned")
+
+// RerankResult is the relevance of one document, identified by its index in the
+// documents passed to Rerank.
+type RerankResult struct {
+	Index int
+	Score float64
+}
+
+// Reranker scores documents against a query, typically with a cross-encoder.
+// Results are ordered by decreasing relevance.
+type Reranker interface {
+	Rerank(ctx context.Context, query string, documents []string) ([]RerankResult, error)
+}
+
+// HTTPReranker implements Reranker using the Cohere rerank API format.
+// This format is also served by Jina, Voyage, vLLM, Infinity and Hugging Face TEI (/rerank).
+type HTTPReranker struct {
+	log.Logger
+
+	// e.g., "https://api.cohere.com/v2", "http://localhost:8080"
+	BaseURL string
+
+	// Optional. not required for local models
+	APIKey string
+
+	// e.g., "rerank-v3.5", "BAAI/bge-reranker-v2-m3"
+	Model string
+
+	// HTTPClient allows customizing the HTTP client used for requests.
+	// If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+// RerankerConfig holds configuration for creating an HTTPReranker.
+type RerankerConfig struct {
+	BaseURL string
+	APIKey  string
+	Model   string
+
+	// HTTPClient allows customizing the HTTP client used for requests.
+	// Useful for testing with mock servers.
+	HTTPClient *http.Client
+
+	// Optional. Logger for logging. Falls back to DiscardLog if nil.
+	Logger log.Logger
+}
+
+// NewHTTPReranker creates a new reranker using the Cohere-compatible rerank API.
+func NewHTTPReranker(cfg RerankerConfig) *HTTPReranker {
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	if cfg.Logger == nil {
+		cfg.Logger = discardlog.NewDiscardLog()
+	}
+
+	cfg.Logger.Infof("reranker created: model=%s, baseURL=%s", cfg.Model, cfg.BaseURL)
+
+	return &HTTPReranker{
+		Logger:     cfg.Logger,
+		BaseURL:    strings.TrimSuffix(cfg.BaseURL, "/"),
+		APIKey:     cfg.APIKey,
+		Model:      cfg.Model,
+		HTTPClient: httpClient,
+	}
+}
+
+// Rerank scores documents against query.
+func (r *HTTPReranker) Rerank(
+	ctx context.Context,
+	query string,
+	documents []string,
+) ([]RerankResult, error) {
+	r.Debugf("reranking: model=%s, documents=%d", r.Model, len(documents))
+
+	jsonBody, err := sonic.Marshal(map[string]any{
+		"model":     r.Model,
+		"query":     query,
+		"documents": documents,
+		"top_n":     len(documents),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST",
+		r.BaseURL+"/rerank", bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if r.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+r.APIKey)
+	}
+
+	resp, err := r.HTTPClient.Do(req)
+	if err != nil {
+		r.Errorf("rerank API call failed: %v", err)
+		return nil, fmt.Errorf("failed to call rerank API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		r.Errorf("rerank API returned error: status=%d, body=%s", resp.StatusCode, string(respBody))
+		return nil, fmt.Errorf("rerank API returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var result rerankResponse
+	if err := sonic.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	// Voyage returns "data" instead of "results"
+	items := result.Results
+	if len(items) == 0 {
+		items = result.Data
+	}
+	if len(items) == 0 {
+		return nil, errNoRerankResults
+	}
+
+	results := make([]RerankResult, 0, len(items))
+	for _, item := range items {
+		if item.Index < 0 || item.Index >= len(documents) {
+			continue
+		}
+		results = append(results, RerankResult{Index: item.Index, Score: item.RelevanceScore})
+	}
+
+	slices.SortStableFunc(results, func(a, b RerankResult) int { return cmp.Compare(b.Score, a.Score) })
+
+	return results, nil
+}
+
+// rerankResponse represents the Cohere-compatible rerank API response format.
+type rerankResponse struct {
+	Results []rerankItem `json:"results"`
+	Data    []rerankItem `json:"data"`
+}
+
+type rerankItem struct {
+	Index          int     `json:"index"`
+	RelevanceScore float64 `json:"relevance_score"`
+}
+
+// Ensure interface is implemented
+var _ Reranker = (*HTTPReranker)(nil)
+
+// candidateLimit is the number of results fetched by a search returning limit
+// results: more when a reranker picks the best of them.
+func (s *PostgresMemoryService) candidateLimit(limit int) int {
+	if s.reranker == nil {
+		return limit
+	}
+	return max(s.rerankCandidates, limit)
+}
+
+// rerankEntries reorders search candidates by reranker relevance and returns the
+// top limit results. On reranker failure the candidates keep their search order.
+func rerankEntries[T any](
+	ctx context.Context,
+	s *PostgresMemoryService,
+	query string,
+	entries []T,
+	content func(T) *genai.Content,
+	limit int,
+) []T {
+	if s.reranker == nil || len(entries) <= 1 {
+		return entries[:min(len(entries), limit)]
+	}
+
+	documents := make([]string, len(entries))
+	for i, e := range entries {
+		documents[i] = s.text.render(content(e))
+	}
+
+	results, err := s.reranker.Rerank(ctx, query, documents)
+	if err != nil {
+		s.logger.Warnf("rerank failed, keeping search order: %v", err)
+		return entries[:min(len(entries), limit)]
+	}
+
+	reranked := make([]T, 0, min(len(results), limit))
+	for _, r := range results[:min(len(results), limit)] {
+		reranked = append(reranked, entries[r.Index])
+	}
+
+	s.logger.Debugf("search results reranked: candidates=%d, results=%d", len(entries), len(reranked))
+
+	return reranked
+}
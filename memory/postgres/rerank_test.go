@@ -0,0 +1,165 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"google.golang.org/genai"
+)
+
+func TestHTTPRerankerSuccess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rerank" {
+			t.Errorf("Expected /rerank, got %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			t.Errorf("Expected bearer token, got %q", r.Header.Get("Authorization"))
+		}
+
+		var reqBody struct {
+			Model     string   `json:"model"`
+			Query     string   `json:"query"`
+			Documents []string `json:"documents"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		if reqBody.Model != "rerank-model" || reqBody.Query != "deploy day" || len(reqBody.Documents) != 3 {
+			t.Errorf("Unexpected request body: %+v", reqBody)
+		}
+
+		// Unordered on purpose, with an out-of-range index
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"results": []map[string]any{
+				{"index": 0, "relevance_score": 0.2},
+				{"index": 2, "relevance_score": 0.9},
+				{"index": 7, "relevance_score": 0.8},
+				{"index": 1, "relevance_score": 0.5},
+			},
+		})
+	}))
+	defer server.Close()
+
+	r := NewHTTPReranker(RerankerConfig{
+		BaseURL:    server.URL + "/",
+		APIKey:     "test-key",
+		Model:      "rerank-model",
+		HTTPClient: server.Client(),
+	})
+
+	results, err := r.Rerank(context.Background(), "deploy day", []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatalf("Rerank failed: %v", err)
+	}
+
+	wantOrder := []int{2, 1, 0}
+	if len(results) != len(wantOrder) {
+		t.Fatalf("Expected %d results, got %d", len(wantOrder), len(results))
+	}
+	for i, idx := range wantOrder {
+		if results[i].Index != idx {
+			t.Errorf("results[%d].Index = %d, want %d", i, results[i].Index, idx)
+		}
+	}
+}
+
+func TestHTTPRerankerVoyageFormat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"data": []map[string]any{{"index": 1, "relevance_score": 0.7}},
+		})
+	}))
+	defer server.Close()
+
+	r := NewHTTPReranker(RerankerConfig{BaseURL: server.URL, HTTPClient: server.Client()})
+
+	results, err := r.Rerank(context.Background(), "q", []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("Rerank failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Index != 1 {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+}
+
+func TestHTTPRerankerServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error": "overloaded"}`))
+	}))
+	defer server.Close()
+
+	r := NewHTTPReranker(RerankerConfig{BaseURL: server.URL, HTTPClient: server.Client()})
+
+	if _, err := r.Rerank(context.Background(), "q", []string{"a"}); err == nil {
+		t.Error("Expected error for server error response")
+	}
+}
+
+// staticReranker implements Reranker with fixed results.
+type staticReranker struct {
+	results []RerankResult
+	err     error
+}
+
+func (r *staticReranker) Rerank(context.Context, string, []string) ([]RerankResult, error) {
+	return r.results, r.err
+}
+
+func TestRerankEntries(t *testing.T) {
+	entries := make([]string, 12)
+	for i := range entries {
+		entries[i] = string(rune('a' + i))
+	}
+	content := func(e string) *genai.Content { return genai.NewContentFromText(e, genai.RoleModel) }
+
+	t.Run("reorders and truncates", func(t *testing.T) {
+		var results []RerankResult
+		for i := len(entries) - 1; i >= 0; i-- {
+			results = append(results, RerankResult{Index: i, Score: float64(i)})
+		}
+		svc := &PostgresMemoryService{
+			logger:   discardlog.NewDiscardLog(),
+			reranker: &staticReranker{results: results},
+		}
+
+		got := rerankEntries(context.Background(), svc, "q", entries, content, defaultSearchLimit)
+		if len(got) != defaultSearchLimit {
+			t.Fatalf("Expected %d results, got %d", defaultSearchLimit, len(got))
+		}
+		if got[0] != "l" || got[9] != "c" {
+			t.Errorf("Unexpected order: %v", got)
+		}
+	})
+
+	t.Run("keeps search order on failure", func(t *testing.T) {
+		svc := &PostgresMemoryService{
+			logger:   discardlog.NewDiscardLog(),
+			reranker: &staticReranker{err: errors.New("unavailable")},
+		}
+
+		got := rerankEntries(context.Background(), svc, "q", entries, content, defaultSearchLimit)
+		if len(got) != defaultSearchLimit || got[0] != "a" {
+			t.Errorf("Unexpected results: %v", got)
+		}
+	})
+
+	t.Run("candidate limit", func(t *testing.T) {
+		svc := &PostgresMemoryService{}
+		if got := svc.candidateLimit(defaultSearchLimit); got != defaultSearchLimit {
+			t.Errorf("candidateLimit() = %d without reranker, want %d", got, defaultSearchLimit)
+		}
+
+		svc.reranker = &staticReranker{}
+		svc.rerankCandidates = defaultRerankCandidates
+		if got := svc.candidateLimit(defaultSearchLimit); got != defaultRerankCandidates {
+			t.Errorf("candidateLimit() = %d with reranker, want %d", got, defaultRerankCandidates)
+		}
+	})
+}
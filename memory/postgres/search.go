@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+)
+
+// SearchOptions tunes the recall and precision of a memory search.
+type SearchOptions struct {
+	// Optional. Limit is the number of memories returned. Default: 10.
+	Limit int
+
+	// Optional. MinSimilarity is the cosine similarity, in [-1, 1], below which the
+	// entries found by vector search are dropped, so unrelated memories are not
+	// injected into prompts. A query finding no entry above it returns the entries
+	// of the text search, if any, rather than the most recent ones.
+	// Default: 0, no threshold.
+	MinSimilarity float64
+
+	// Optional. DisableMinSimilarity searches without a threshold, neither
+	// MinSimilarity nor PgMemSvrConfig.MinSimilarity. Default: false.
+	DisableMinSimilarity bool
+}
+
+// searchOptionsKey is the context key of the SearchOptions of a search.
+type searchOptionsKey struct{}
+
+// WithSearchOptions returns a context carrying the options of the searches
+// made with it, e.g. a larger limit for a tool listing memories. Its zero fields
+// keep the defaults of the service, PgMemSvrConfig.SearchLimit and
+// PgMemSvrConfig.MinSimilarity; DisableMinSimilarity drops the latter.
+func WithSearchOptions(ctx context.Context, opts SearchOptions) context.Context {
+	return context.WithValue(ctx, searchOptionsKey{}, opts)
+}
+
+// searchOptions returns the options of a search: those of ctx over the defaults
+// of the service.
+func (s *PostgresMemoryService) searchOptions(ctx context.Context) (SearchOptions, error) {
+	opts := SearchOptions{
+		Limit:         cmp.Or(s.searchLimit, defaultSearchLimit),
+		MinSimilarity: s.minSimilarity,
+	}
+
+	if o, ok := ctx.Value(searchOptionsKey{}).(SearchOptions); ok {
+		if o.Limit > 0 {
+			opts.Limit = o.Limit
+		}
+		switch {
+		case o.DisableMinSimilarity:
+			opts.MinSimilarity = 0
+		case o.MinSimilarity != 0:
+			if err := validateSimilarity(o.MinSimilarity); err != nil {
+				return SearchOptions{}, err
+			}
+			opts.MinSimilarity = o.MinSimilarity
+		}
+	}
+
+	return opts, nil
+}
+
+// validateSimilarity checks that a similarity threshold is a cosine similarity.
+func validateSimilarity(v float64) error {
+	if v < -1 || v > 1 {
+		return fmt.Errorf("%w: %v", ErrInvalidSimilarity, v)
+	}
+	return nil
+}
+
+// similarityFilter returns the condition of the vector search queries dropping
+// the entries below opts.MinSimilarity, on the query embedding $3, with its
+// argument $5; empty without a threshold. pgvector's <=> is the cosine distance,
+// 1 - similarity.
+func similarityFilter(opts SearchOptions) (string, []any) {
+	if opts.MinSimilarity == 0 {
+		return "", nil
+	}
+	return "AND embedding <=> $3 <= $5", []any{1 - opts.MinSimilarity}
+}
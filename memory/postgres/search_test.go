@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/adk/memory"
+)
+
+func TestSearchOptions(t *testing.T) {
+	svc := &PostgresMemoryService{}
+
+	opts, err := svc.searchOptions(context.Background())
+	if err != nil || opts.Limit != defaultSearchLimit || opts.MinSimilarity != 0 {
+		t.Errorf("expected the defaults, got %+v, %v", opts, err)
+	}
+
+	svc.searchLimit, svc.minSimilarity = 5, 0.7
+	opts, _ = svc.searchOptions(context.Background())
+	if opts.Limit != 5 || opts.MinSimilarity != 0.7 {
+		t.Errorf("expected the defaults of the service, got %+v", opts)
+	}
+
+	ctx := WithSearchOptions(context.Background(), SearchOptions{Limit: 20})
+	opts, _ = svc.searchOptions(ctx)
+	if opts.Limit != 20 || opts.MinSimilarity != 0.7 {
+		t.Errorf("expected the limit of the context, got %+v", opts)
+	}
+
+	ctx = WithSearchOptions(context.Background(), SearchOptions{DisableMinSimilarity: true})
+	opts, _ = svc.searchOptions(ctx)
+	if opts.Limit != 5 || opts.MinSimilarity != 0 {
+		t.Errorf("expected no threshold, got %+v", opts)
+	}
+
+	ctx = WithSearchOptions(context.Background(), SearchOptions{MinSimilarity: 1.5})
+	if _, err := svc.searchOptions(ctx); !errors.Is(err, ErrInvalidSimilarity) {
+		t.Errorf("expected ErrInvalidSimilarity, got %v", err)
+	}
+}
+
+func TestSimilarityFilter(t *testing.T) {
+	if filter, args := similarityFilter(SearchOptions{}); filter != "" || args != nil {
+		t.Errorf("expected no filter without a threshold, got %q %v", filter, args)
+	}
+
+	filter, args := similarityFilter(SearchOptions{MinSimilarity: 0.75})
+	if filter != "AND embedding <=> $3 <= $5" || len(args) != 1 || args[0] != 0.25 {
+		t.Errorf("unexpected filter %q %v", filter, args)
+	}
+}
+
+func TestNewPostgresMemoryServiceInvalidSimilarity(t *testing.T) {
+	_, err := NewPostgresMemoryService(context.Background(), PgMemSvrConfig{
+		ConnStr:       getTestConnString(),
+		MinSimilarity: -2,
+	})
+	if !errors.Is(err, ErrInvalidSimilarity) {
+		t.Errorf("expected ErrInvalidSimilarity, got %v", err)
+	}
+}
+
+func TestSearchLimitAndThreshold(t *testing.T) {
+	svc := setupTestDB(t)
+	defer svc.Close()
+	ctx := context.Background()
+
+	sess := createTestSession(
+		"sess-limit",
+		"test_app",
+		"user-limit",
+		[]struct{ author, text string }{
+			{"user", "First message"},
+			{"assistant", "First response"},
+			{"user", "Second message"},
+			{"assistant", "Second response"},
+		},
+	)
+	if err := svc.AddSession(ctx, sess); err != nil {
+		t.Fatalf("AddSession failed: %v", err)
+	}
+
+	resp, err := svc.Search(WithSearchOptions(ctx, SearchOptions{Limit: 2}), &memory.SearchRequest{
+		AppName: "test_app",
+		UserID:  "user-limit",
+	})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(resp.Memories) != 2 {
+		t.Errorf("expected 2 recent memories, got %d", len(resp.Memories))
+	}
+
+	// NOTE: Without an embedding model no vector search runs, so a threshold
+	// keeps the recent entries
+	resp, err = svc.Search(WithSearchOptions(ctx, SearchOptions{MinSimilarity: 0.8}), &memory.SearchRequest{
+		AppName: "test_app",
+		UserID:  "user-limit",
+		Query:   "unrelated quantum chromodynamics",
+	})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(resp.Memories) == 0 {
+		t.Error("expected the recent memories without a vector search")
+	}
+}
@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	"google.golang.org/genai"
+)
+
+const (
+	// defaultTextSearchConfig is the PostgreSQL text search configuration of the
+	// content_text column.
+	defaultTextSearchConfig = "english"
+
+	// defaultMaxToolTextLength bounds the rendered arguments or result of a tool.
+	defaultMaxToolTextLength = 1000
+)
+
+// validTextSearchConfig matches the names of PostgreSQL text search configurations,
+// which are inlined in the search queries.
+var validTextSearchConfig = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// ToolTextMode controls how the tool calls and responses of an event are rendered
+// into the searchable text of its memory entry.
+type ToolTextMode int
+
+const (
+	// ToolTextFull renders the tool names with their arguments and results.
+	ToolTextFull ToolTextMode = iota
+
+	// ToolTextNames renders the tool names only.
+	ToolTextNames
+
+	// ToolTextNone leaves the tool calls and responses out, as text parts only are
+	// searchable.
+	ToolTextNone
+)
+
+// textRenderer renders contents into the searchable text of memory entries.
+type textRenderer struct {
+	mode      ToolTextMode
+	maxLength int
+}
+
+// render returns the text parts of content followed, in order, by its tool calls
+// and responses, e.g. `[tool call] get_weather {"city":"Paris"}`.
+func (r textRenderer) render(content *genai.Content) string {
+	if content == nil {
+		return ""
+	}
+
+	var parts []string
+	for _, part := range content.Parts {
+		switch {
+		case part.Text != "":
+			parts = append(parts, part.Text)
+
+		case part.FunctionCall != nil && r.mode != ToolTextNone:
+			parts = append(parts, r.renderTool("[tool call]", part.FunctionCall.Name, part.FunctionCall.Args))
+
+		case part.FunctionResponse != nil && r.mode != ToolTextNone:
+			parts = append(parts, r.renderTool("[tool result]", part.FunctionResponse.Name, part.FunctionResponse.Response))
+		}
+	}
+
+	return strings.TrimSpace(strings.Join(parts, " "))
+}
+
+// renderTool renders a tool call or response, its values as JSON truncated to
+// maxLength characters.
+func (r textRenderer) renderTool(label, name string, values map[string]any) string {
+	if r.mode == ToolTextNames || len(values) == 0 {
+		return label + " " + name
+	}
+
+	// NOTE: Sorted keys keep the text of an event stable across re-ingestions
+	data, err := sonic.ConfigStd.Marshal(values)
+	if err != nil {
+		return label + " " + name
+	}
+
+	text := string(data)
+	if runes := []rune(text); r.maxLength > 0 && len(runes) > r.maxLength {
+		text = string(runes[:r.maxLength]) + "…"
+	}
+
+	return fmt.Sprintf("%s %s %s", label, name, text)
+}
@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestTextRenderer(t *testing.T) {
+	content := &genai.Content{Parts: []*genai.Part{
+		genai.NewPartFromText("Checking the forecast"),
+		genai.NewPartFromFunctionCall("get_weather", map[string]any{"days": 3, "city": "Paris"}),
+		genai.NewPartFromFunctionResponse("get_weather", map[string]any{"forecast": "sunny"}),
+		genai.NewPartFromBytes([]byte{1, 2}, "image/png"),
+	}}
+
+	tests := []struct {
+		name     string
+		renderer textRenderer
+		want     string
+	}{
+		{
+			"full", textRenderer{mode: ToolTextFull},
+			`Checking the forecast [tool call] get_weather {"city":"Paris","days":3} ` +
+				`[tool result] get_weather {"forecast":"sunny"}`,
+		},
+		{
+			"names", textRenderer{mode: ToolTextNames},
+			"Checking the forecast [tool call] get_weather [tool result] get_weather",
+		},
+		{"none", textRenderer{mode: ToolTextNone}, "Checking the forecast"},
+		{
+			"truncated", textRenderer{mode: ToolTextFull, maxLength: 8},
+			`Checking the forecast [tool call] get_weather {"city":… [tool result] get_weather {"foreca…`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.renderer.render(content); got != tt.want {
+				t.Errorf("render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("tool-only event", func(t *testing.T) {
+		call := genai.NewContentFromFunctionCall("search_flights", map[string]any{"to": "東京"}, genai.RoleModel)
+		if got := (textRenderer{}).render(call); !strings.Contains(got, "search_flights") || !strings.Contains(got, "東京") {
+			t.Errorf("render() = %q, want the tool name and arguments", got)
+		}
+	})
+}
+
+func TestInvalidTextSearchConfig(t *testing.T) {
+	_, err := NewPostgresMemoryService(context.Background(), PgMemSvrConfig{TextSearchConfig: "english'); DROP TABLE x; --"})
+	if !errors.Is(err, ErrInvalidTextSearchConfig) {
+		t.Errorf("NewPostgresMemoryService() error = %v, want ErrInvalidTextSearchConfig", err)
+	}
+}
@@ -0,0 +1,184 @@
+package contextguard
+
+import (
+	"log/slog"
+	"sync"
+
+	genaitypes "github.com/kydenul/k-adk/genai/types"
+	"google.golang.org/adk/agent"
+	"google.golang.org/adk/model"
+)
+
+const (
+	// BudgetMetadataKey is the LLMResponse.CustomMetadata key under which a
+	// Budget records what it trimmed from the request.
+	BudgetMetadataKey = "context_budget"
+
+	defaultBudgetKeepRecent = 4
+)
+
+// BudgetConfig configures a Budget.
+type BudgetConfig struct {
+	// MaxTokens is the prompt token budget. Requests above it are trimmed.
+	MaxTokens int
+
+	// Optional. Counter counts prompt tokens, typically the model adapter itself
+	// (openai.Model and anthropic.Model implement genaitypes.TokenCounter).
+	// Falls back to the built-in heuristic if nil or if counting fails.
+	Counter genaitypes.TokenCounter
+
+	// Optional. Summarizer condenses the dropped turns into a summary that is
+	// prepended to the request. If nil, the oldest turns are simply dropped.
+	Summarizer model.LLM
+
+	// Optional. KeepRecent is the minimum number of most recent contents that are
+	// never trimmed. Default: 4.
+	KeepRecent int
+}
+
+// TrimReport describes what a Budget removed from a request.
+type TrimReport struct {
+	TokensBefore int  `json:"tokens_before"`
+	TokensAfter  int  `json:"tokens_after"`
+	Dropped      int  `json:"dropped_contents"`
+	Summarized   bool `json:"summarized"`
+}
+
+// Budget keeps the prompt of an llmagent within a fixed token budget.
+//
+// Unlike ContextGuard, which is installed as a runner plugin, a Budget is wired
+// directly into llmagent.Config:
+//
+//	budget := contextguard.NewBudget(contextguard.BudgetConfig{MaxTokens: 32_000, Counter: llm})
+//	llmagent.New(llmagent.Config{
+//		BeforeModelCallbacks: []llmagent.BeforeModelCallback{budget.BeforeModel},
+//		AfterModelCallbacks:  []llmagent.AfterModelCallback{budget.AfterModel},
+//	})
+type Budget struct {
+	cfg BudgetConfig
+
+	// reports holds trim reports by invocation until AfterModel attaches them to the
+	// response, or drops them when the model call fails.
+	reports sync.Map
+}
+
+// NewBudget creates a Budget from cfg.
+func NewBudget(cfg BudgetConfig) *Budget {
+	if cfg.KeepRecent <= 0 {
+		cfg.KeepRecent = defaultBudgetKeepRecent
+	}
+
+	return &Budget{cfg: cfg}
+}
+
+// BeforeModel trims the oldest contents of req until it fits the budget.
+func (b *Budget) BeforeModel(
+	ctx agent.CallbackContext,
+	req *model.LLMRequest,
+) (*model.LLMResponse, error) {
+	if req == nil || b.cfg.MaxTokens <= 0 || len(req.Contents) <= b.cfg.KeepRecent {
+		return nil, nil
+	}
+
+	heuristic := estimateTokens(req)
+	tokens := b.countTokens(ctx, req, heuristic)
+	if tokens <= b.cfg.MaxTokens {
+		return nil, nil
+	}
+
+	// Scale heuristic estimates by the observed ratio so each candidate split
+	// does not require another (possibly remote) token count.
+	ratio := defaultHeuristicCorrectionFactor
+	if heuristic > 0 {
+		ratio = float64(tokens) / float64(heuristic)
+	}
+
+	original := req.Contents
+	splitIdx := 0
+	for idx := 1; idx <= len(original)-b.cfg.KeepRecent; idx++ {
+		splitIdx = safeSplitIndex(original, idx)
+		req.Contents = original[splitIdx:]
+		if int(float64(estimateTokens(req))*ratio) <= b.cfg.MaxTokens {
+			break
+		}
+	}
+
+	if splitIdx <= 0 {
+		req.Contents = original
+		return nil, nil
+	}
+
+	report := TrimReport{TokensBefore: tokens, Dropped: splitIdx}
+
+	if b.cfg.Summarizer != nil {
+		summary, err := summarize(ctx, b.cfg.Summarizer, original[:splitIdx], "",
+			computeBuffer(b.cfg.MaxTokens), loadTodos(ctx))
+		if err != nil {
+			slog.Warn("ContextGuard [budget]: summarization failed, dropping turns",
+				"agent", ctx.AgentName(),
+				"session", ctx.SessionID(),
+				"error", err,
+			)
+		} else {
+			replaceSummary(req, summary, original[splitIdx:])
+			report.Summarized = true
+		}
+	}
+
+	report.TokensAfter = int(float64(estimateTokens(req)) * ratio)
+	b.reports.Store(ctx.InvocationID(), report)
+
+	slog.Info("ContextGuard [budget]: request trimmed",
+		"agent", ctx.AgentName(),
+		"session", ctx.SessionID(),
+		"tokensBefore", report.TokensBefore,
+		"tokensAfter", report.TokensAfter,
+		"dropped", report.Dropped,
+		"summarized", report.Summarized,
+		"budget", b.cfg.MaxTokens,
+	)
+
+	return nil, nil
+}
+
+// AfterModel annotates the final response with the TrimReport of its request, if
+// any. The report is dropped when the model call fails too.
+func (b *Budget) AfterModel(
+	ctx agent.CallbackContext,
+	resp *model.LLMResponse,
+	err error,
+) (*model.LLMResponse, error) {
+	if err == nil && resp != nil && resp.Partial {
+		return nil, nil
+	}
+
+	// NOTE: Every terminal call deletes the report, or failed calls would leak it
+	val, ok := b.reports.LoadAndDelete(ctx.InvocationID())
+	if !ok || resp == nil {
+		return nil, nil
+	}
+
+	if resp.CustomMetadata == nil {
+		resp.CustomMetadata = make(map[string]any)
+	}
+	resp.CustomMetadata[BudgetMetadataKey] = val
+
+	return nil, nil
+}
+
+// countTokens uses the configured counter, falling back to the corrected heuristic.
+func (b *Budget) countTokens(ctx agent.CallbackContext, req *model.LLMRequest, heuristic int) int {
+	if b.cfg.Counter != nil {
+		tokens, err := b.cfg.Counter.CountTokens(ctx, req)
+		if err == nil {
+			return tokens
+		}
+
+		slog.Warn("ContextGuard [budget]: token counter failed, using heuristic",
+			"agent", ctx.AgentName(),
+			"error", err,
+		)
+	}
+
+	return int(float64(heuristic) * defaultHeuristicCorrectionFactor)
+}
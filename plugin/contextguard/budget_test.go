@@ -0,0 +1,82 @@
+package contextguard
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"google.golang.org/adk/agent"
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+// fakeCallbackContext implements the methods of agent.CallbackContext used by the
+// budget; the others panic.
+type fakeCallbackContext struct {
+	agent.CallbackContext
+
+	invocationID string
+}
+
+func (f *fakeCallbackContext) InvocationID() string { return f.invocationID }
+func (f *fakeCallbackContext) AgentName() string    { return "agent" }
+func (f *fakeCallbackContext) SessionID() string    { return "session" }
+
+// overBudgetRequest returns a request of n contents well above a budget of 100
+// tokens.
+func overBudgetRequest(n int) *model.LLMRequest {
+	req := &model.LLMRequest{}
+	for range n {
+		req.Contents = append(req.Contents, genai.NewContentFromText(strings.Repeat("word ", 200), genai.RoleUser))
+	}
+	return req
+}
+
+func TestBudgetAfterModel(t *testing.T) {
+	final := func() *model.LLMResponse { return &model.LLMResponse{} }
+
+	tests := []struct {
+		name string
+		resp *model.LLMResponse
+		err  error
+		// wantReport is whether the response is annotated with the report.
+		wantReport bool
+		// wantKept is whether the report is kept for a later call.
+		wantKept bool
+	}{
+		{name: "final response", resp: final(), wantReport: true},
+		{name: "partial response", resp: &model.LLMResponse{Partial: true}, wantKept: true},
+		{name: "nil response", resp: nil},
+		{name: "model error", resp: nil, err: errors.New("model unavailable")},
+		{name: "error response", resp: final(), err: errors.New("model unavailable"), wantReport: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBudget(BudgetConfig{MaxTokens: 100, KeepRecent: 1})
+			ctx := &fakeCallbackContext{invocationID: "inv-1"}
+
+			req := overBudgetRequest(4)
+			if _, err := b.BeforeModel(ctx, req); err != nil {
+				t.Fatalf("BeforeModel failed: %v", err)
+			}
+			if len(req.Contents) >= 4 {
+				t.Fatalf("expected the request to be trimmed, got %d contents", len(req.Contents))
+			}
+
+			if _, err := b.AfterModel(ctx, tt.resp, tt.err); err != nil {
+				t.Fatalf("AfterModel failed: %v", err)
+			}
+
+			if tt.resp != nil {
+				_, annotated := tt.resp.CustomMetadata[BudgetMetadataKey]
+				if annotated != tt.wantReport {
+					t.Errorf("response annotated = %t, want %t", annotated, tt.wantReport)
+				}
+			}
+			if _, kept := b.reports.Load("inv-1"); kept != tt.wantKept {
+				t.Errorf("report kept = %t, want %t", kept, tt.wantKept)
+			}
+		})
+	}
+}
@@ -0,0 +1,495 @@
+// Package latency records a per-turn latency breakdown (model calls, tool calls,
+// session persistence and total turn time), so that slow turns can be attributed
+// to the LLM, a tool, Redis or PostgreSQL without external tracing.
+//
+// The Tracker is an ADK plugin that times the model and tool callbacks of every
+// invocation. Persistence is timed by wrapping the session service and persister
+// with Tracker.SessionService and Tracker.Persister. Every non-partial event
+// carries the breakdown of its turn so far under the "latency" key of its
+// CustomMetadata, and finished turns are aggregated in Stats and passed to OnTurn.
+package latency
+
+import (
+	"maps"
+	"sync"
+	"time"
+
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/log"
+	"google.golang.org/adk/agent"
+	"google.golang.org/adk/agent/llmagent"
+	"google.golang.org/adk/model"
+	"google.golang.org/adk/plugin"
+	"google.golang.org/adk/runner"
+	"google.golang.org/adk/session"
+	"google.golang.org/adk/tool"
+	"google.golang.org/genai"
+)
+
+// MetadataKey is the event CustomMetadata key of the breakdown.
+const MetadataKey = "latency"
+
+// Stat names of Stats, besides the per tool and per store ones.
+const (
+	StatModel = "model"
+	StatTurn  = "turn"
+
+	statToolPrefix        = "tool:"
+	statPersistencePrefix = "persistence:"
+)
+
+// Breakdown is the latency of a turn, attributed to its stages.
+type Breakdown struct {
+	InvocationID string
+	AppName      string
+	UserID       string
+	SessionID    string
+
+	// Model is the time spent in model calls, ModelCalls their number.
+	Model      time.Duration
+	ModelCalls int
+
+	// Tools is the time spent in every tool, by tool name.
+	Tools map[string]time.Duration
+
+	// Persistence is the time spent persisting events, by store name.
+	Persistence map[string]time.Duration
+
+	// Total is the time since the turn started.
+	Total time.Duration
+}
+
+// Metadata returns the breakdown as event CustomMetadata, in milliseconds.
+func (b Breakdown) Metadata() map[string]any {
+	md := map[string]any{
+		"model_ms":    b.Model.Milliseconds(),
+		"model_calls": b.ModelCalls,
+		"total_ms":    b.Total.Milliseconds(),
+	}
+	if len(b.Tools) > 0 {
+		md["tools_ms"] = millis(b.Tools)
+	}
+	if len(b.Persistence) > 0 {
+		md["persistence_ms"] = millis(b.Persistence)
+	}
+	return md
+}
+
+// FromEvent returns the breakdown stamped on evt, if any. Only the durations are
+// restored; stored events hold them with millisecond precision.
+func FromEvent(evt *session.Event) (Breakdown, bool) {
+	if evt == nil {
+		return Breakdown{}, false
+	}
+	md, ok := evt.CustomMetadata[MetadataKey].(map[string]any)
+	if !ok {
+		return Breakdown{}, false
+	}
+
+	b := Breakdown{
+		InvocationID: evt.InvocationID,
+		Model:        duration(md["model_ms"]),
+		ModelCalls:   int(number(md["model_calls"])),
+		Total:        duration(md["total_ms"]),
+	}
+	if tools, ok := md["tools_ms"].(map[string]any); ok {
+		b.Tools = make(map[string]time.Duration, len(tools))
+		for name, v := range tools {
+			b.Tools[name] = duration(v)
+		}
+	}
+	if stores, ok := md["persistence_ms"].(map[string]any); ok {
+		b.Persistence = make(map[string]time.Duration, len(stores))
+		for name, v := range stores {
+			b.Persistence[name] = duration(v)
+		}
+	}
+
+	return b, true
+}
+
+// Stat aggregates the observed durations of a stage.
+type Stat struct {
+	Count int64
+	Total time.Duration
+	Max   time.Duration
+}
+
+// Mean returns the mean duration, or 0 without observations.
+func (s Stat) Mean() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Count)
+}
+
+// Config configures a Tracker.
+type Config struct {
+	// Optional. Called with the breakdown of every finished turn, e.g. to export
+	// it to a metrics system.
+	OnTurn func(Breakdown)
+
+	// Optional. Turns slower than SlowTurn are logged with their breakdown.
+	// Default: 0 (disabled).
+	SlowTurn time.Duration
+
+	// Optional. Logger for logging. Falls back to `DiscardLog` if nil.
+	Logger log.Logger
+}
+
+// Tracker times the turns of a runner.
+type Tracker struct {
+	log.Logger
+
+	onTurn   func(Breakdown)
+	slowTurn time.Duration
+
+	mu    sync.Mutex
+	turns map[string]*turn // invocation ID -> turn
+	stats map[string]Stat
+}
+
+// turn accumulates the breakdown of an invocation in progress.
+type turn struct {
+	start time.Time
+
+	mu     sync.Mutex
+	b      Breakdown
+	starts map[string]time.Time // model and tool calls in progress
+}
+
+// New creates a Tracker.
+func New(cfg Config) *Tracker {
+	if cfg.Logger == nil {
+		cfg.Logger = discardlog.NewDiscardLog()
+	}
+
+	return &Tracker{
+		Logger:   cfg.Logger,
+		onTurn:   cfg.OnTurn,
+		slowTurn: cfg.SlowTurn,
+		turns:    make(map[string]*turn),
+		stats:    make(map[string]Stat),
+	}
+}
+
+// Plugin returns the ADK plugin of the tracker, to combine with other plugins.
+func (t *Tracker) Plugin() *plugin.Plugin {
+	p, _ := plugin.New(plugin.Config{
+		Name:                  "latency",
+		OnUserMessageCallback: t.onUserMessage,
+		BeforeRunCallback:     t.beforeRun,
+		AfterRunCallback:      t.afterRun,
+		OnEventCallback:       t.onEvent,
+		BeforeModelCallback:   llmagent.BeforeModelCallback(t.beforeModel),
+		AfterModelCallback:    llmagent.AfterModelCallback(t.afterModel),
+		OnModelErrorCallback:  llmagent.OnModelErrorCallback(t.onModelError),
+		BeforeToolCallback:    llmagent.BeforeToolCallback(t.beforeTool),
+		AfterToolCallback:     llmagent.AfterToolCallback(t.afterTool),
+	})
+
+	return p
+}
+
+// PluginConfig returns a runner.PluginConfig ready to pass to the ADK runner.
+func (t *Tracker) PluginConfig() runner.PluginConfig {
+	return runner.PluginConfig{Plugins: []*plugin.Plugin{t.Plugin()}}
+}
+
+// Stats returns the aggregated durations of the finished turns (StatTurn), model
+// calls (StatModel), tool calls ("tool:{name}") and persistence ("persistence:{store}").
+func (t *Tracker) Stats() map[string]Stat {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return maps.Clone(t.stats)
+}
+
+func (t *Tracker) onUserMessage(
+	ctx agent.InvocationContext,
+	_ *genai.Content,
+) (*genai.Content, error) {
+	// The user message is appended before BeforeRun, start the turn now to time it
+	t.begin(ctx)
+	return nil, nil
+}
+
+func (t *Tracker) beforeRun(ctx agent.InvocationContext) (*genai.Content, error) {
+	t.begin(ctx)
+	return nil, nil
+}
+
+func (t *Tracker) afterRun(ctx agent.InvocationContext) {
+	t.finish(ctx.InvocationID())
+}
+
+func (t *Tracker) onEvent(_ agent.InvocationContext, evt *session.Event) (*session.Event, error) {
+	if evt == nil || evt.Partial {
+		return nil, nil
+	}
+
+	b, ok := t.snapshot(evt.InvocationID)
+	if !ok {
+		return nil, nil
+	}
+
+	if evt.CustomMetadata == nil {
+		evt.CustomMetadata = make(map[string]any)
+	}
+	evt.CustomMetadata[MetadataKey] = b.Metadata()
+
+	return nil, nil
+}
+
+func (t *Tracker) beforeModel(
+	ctx agent.CallbackContext,
+	_ *model.LLMRequest,
+) (*model.LLMResponse, error) {
+	t.start(ctx.InvocationID(), modelKey(ctx))
+	return nil, nil
+}
+
+func (t *Tracker) afterModel(
+	ctx agent.CallbackContext,
+	resp *model.LLMResponse,
+	_ error,
+) (*model.LLMResponse, error) {
+	// Streaming calls end with their last, non-partial response
+	if resp != nil && resp.Partial {
+		return nil, nil
+	}
+
+	t.modelDone(ctx)
+	return nil, nil
+}
+
+func (t *Tracker) onModelError(
+	ctx agent.CallbackContext,
+	_ *model.LLMRequest,
+	_ error,
+) (*model.LLMResponse, error) {
+	t.modelDone(ctx)
+	return nil, nil
+}
+
+func (t *Tracker) modelDone(ctx agent.CallbackContext) {
+	d, ok := t.stop(ctx.InvocationID(), modelKey(ctx))
+	if !ok {
+		return
+	}
+
+	t.observe(StatModel, d)
+	t.record(ctx.InvocationID(), func(b *Breakdown) {
+		b.Model += d
+		b.ModelCalls++
+	})
+}
+
+func (t *Tracker) beforeTool(
+	ctx tool.Context,
+	_ tool.Tool,
+	_ map[string]any,
+) (map[string]any, error) {
+	t.start(ctx.InvocationID(), toolKey(ctx))
+	return nil, nil
+}
+
+func (t *Tracker) afterTool(
+	ctx tool.Context,
+	tl tool.Tool,
+	_, _ map[string]any,
+	_ error,
+) (map[string]any, error) {
+	d, ok := t.stop(ctx.InvocationID(), toolKey(ctx))
+	if !ok {
+		return nil, nil
+	}
+
+	name := tl.Name()
+	t.observe(statToolPrefix+name, d)
+	t.record(ctx.InvocationID(), func(b *Breakdown) {
+		if b.Tools == nil {
+			b.Tools = make(map[string]time.Duration)
+		}
+		b.Tools[name] += d
+	})
+
+	return nil, nil
+}
+
+// persisted records a persistence of store for the turn of invocationID.
+func (t *Tracker) persisted(invocationID, store string, d time.Duration) {
+	t.observe(statPersistencePrefix+store, d)
+	t.record(invocationID, func(b *Breakdown) {
+		if b.Persistence == nil {
+			b.Persistence = make(map[string]time.Duration)
+		}
+		b.Persistence[store] += d
+	})
+}
+
+// begin starts the turn of the invocation, unless started already.
+func (t *Tracker) begin(ctx agent.InvocationContext) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.turns[ctx.InvocationID()]; ok {
+		return
+	}
+
+	tr := &turn{
+		start:  time.Now(),
+		b:      Breakdown{InvocationID: ctx.InvocationID()},
+		starts: make(map[string]time.Time),
+	}
+	if sess := ctx.Session(); sess != nil {
+		tr.b.AppName, tr.b.UserID, tr.b.SessionID = sess.AppName(), sess.UserID(), sess.ID()
+	}
+	t.turns[ctx.InvocationID()] = tr
+}
+
+// finish ends the turn of invocationID, aggregating and reporting its breakdown.
+func (t *Tracker) finish(invocationID string) {
+	t.mu.Lock()
+	tr, ok := t.turns[invocationID]
+	delete(t.turns, invocationID)
+	t.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	b := tr.snapshot()
+	t.observe(StatTurn, b.Total)
+
+	if t.slowTurn > 0 && b.Total >= t.slowTurn {
+		t.Warnf("slow turn: invocation=%s, session=%s, total=%v, model=%v (%d calls), tools=%v, persistence=%v",
+			b.InvocationID, b.SessionID, b.Total, b.Model, b.ModelCalls, b.Tools, b.Persistence)
+	}
+
+	if t.onTurn != nil {
+		t.onTurn(b)
+	}
+}
+
+// snapshot returns the breakdown so far of the turn of invocationID.
+func (t *Tracker) snapshot(invocationID string) (Breakdown, bool) {
+	tr, ok := t.turn(invocationID)
+	if !ok {
+		return Breakdown{}, false
+	}
+
+	return tr.snapshot(), true
+}
+
+// turn returns the turn of invocationID, if in progress.
+func (t *Tracker) turn(invocationID string) (*turn, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tr, ok := t.turns[invocationID]
+	return tr, ok
+}
+
+// record updates the breakdown of the turn of invocationID, if in progress.
+func (t *Tracker) record(invocationID string, update func(*Breakdown)) {
+	tr, ok := t.turn(invocationID)
+	if !ok {
+		return
+	}
+
+	tr.mu.Lock()
+	update(&tr.b)
+	tr.mu.Unlock()
+}
+
+// start marks the start of a timed call of the turn of invocationID. Calls of
+// invocations without a turn (not run by a runner) are not timed.
+func (t *Tracker) start(invocationID, key string) {
+	tr, ok := t.turn(invocationID)
+	if !ok {
+		return
+	}
+
+	tr.mu.Lock()
+	tr.starts[key] = time.Now()
+	tr.mu.Unlock()
+}
+
+// stop returns the duration of a timed call since start.
+func (t *Tracker) stop(invocationID, key string) (time.Duration, bool) {
+	tr, ok := t.turn(invocationID)
+	if !ok {
+		return 0, false
+	}
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	start, ok := tr.starts[key]
+	if !ok {
+		return 0, false
+	}
+	delete(tr.starts, key)
+
+	return time.Since(start), true
+}
+
+// observe aggregates a duration of the stat name.
+func (t *Tracker) observe(name string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.stats[name]
+	s.Count++
+	s.Total += d
+	s.Max = max(s.Max, d)
+	t.stats[name] = s
+}
+
+func (tr *turn) snapshot() Breakdown {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	b := tr.b
+	b.Tools = maps.Clone(tr.b.Tools)
+	b.Persistence = maps.Clone(tr.b.Persistence)
+	b.Total = time.Since(tr.start)
+
+	return b
+}
+
+// modelKey identifies the model call of an agent; an agent calls the model
+// sequentially, and agents running in parallel have their own branch.
+func modelKey(ctx agent.CallbackContext) string {
+	return "model\x00" + ctx.Branch() + "\x00" + ctx.AgentName()
+}
+
+// toolKey identifies a tool call, tools may run in parallel.
+func toolKey(ctx tool.Context) string {
+	return "tool\x00" + ctx.FunctionCallID()
+}
+
+func millis(m map[string]time.Duration) map[string]any {
+	out := make(map[string]any, len(m))
+	for k, d := range m {
+		out[k] = d.Milliseconds()
+	}
+	return out
+}
+
+// number converts a metadata number, which is a float64 once read back from JSON.
+func number(v any) float64 {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
+
+func duration(v any) time.Duration {
+	return time.Duration(number(v) * float64(time.Millisecond))
+}
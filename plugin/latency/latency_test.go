@@ -0,0 +1,152 @@
+package latency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/adk/agent"
+	"google.golang.org/adk/model"
+	"google.golang.org/adk/session"
+	"google.golang.org/adk/tool"
+)
+
+// fakeInvocation implements the methods of agent.InvocationContext used by the
+// tracker; the others panic.
+type fakeInvocation struct {
+	agent.InvocationContext
+
+	id   string
+	sess session.Session
+}
+
+func (f *fakeInvocation) InvocationID() string     { return f.id }
+func (f *fakeInvocation) Session() session.Session { return f.sess }
+
+// fakeToolContext implements the methods of tool.Context used by the tracker.
+type fakeToolContext struct {
+	tool.Context
+
+	invocationID string
+	callID       string
+}
+
+func (f *fakeToolContext) InvocationID() string   { return f.invocationID }
+func (f *fakeToolContext) FunctionCallID() string { return f.callID }
+func (f *fakeToolContext) Branch() string         { return "" }
+func (f *fakeToolContext) AgentName() string      { return "agent" }
+
+type fakeTool struct {
+	tool.Tool
+
+	name string
+}
+
+func (f *fakeTool) Name() string { return f.name }
+
+func TestTrackerBreakdown(t *testing.T) {
+	ctx := context.Background()
+
+	var finished []Breakdown
+	tracker := New(Config{OnTurn: func(b Breakdown) { finished = append(finished, b) }})
+
+	svc := session.InMemoryService()
+	created, err := svc.Create(ctx, &session.CreateRequest{AppName: "app", UserID: "user1", SessionID: "sess1"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	timed := tracker.SessionService("memory", svc)
+
+	inv := &fakeInvocation{id: "inv1", sess: created.Session}
+	tracker.onUserMessage(inv, nil)
+
+	if err := timed.AppendEvent(ctx, created.Session, session.NewEvent("inv1")); err != nil {
+		t.Fatalf("AppendEvent failed: %v", err)
+	}
+
+	// Model call, streamed: partial responses do not end it
+	tc := &fakeToolContext{invocationID: "inv1", callID: "call1"}
+	tracker.beforeModel(tc, &model.LLMRequest{})
+	time.Sleep(5 * time.Millisecond)
+	tracker.afterModel(tc, &model.LLMResponse{Partial: true}, nil)
+	tracker.afterModel(tc, &model.LLMResponse{}, nil)
+
+	// Parallel tool calls
+	weather := &fakeTool{name: "get_weather"}
+	other := &fakeToolContext{invocationID: "inv1", callID: "call2"}
+	tracker.beforeTool(tc, weather, nil)
+	tracker.beforeTool(other, weather, nil)
+	time.Sleep(5 * time.Millisecond)
+	tracker.afterTool(tc, weather, nil, nil, nil)
+	tracker.afterTool(other, weather, nil, nil, nil)
+
+	evt := session.NewEvent("inv1")
+	tracker.onEvent(inv, evt)
+
+	b, ok := FromEvent(evt)
+	if !ok {
+		t.Fatalf("Expected a breakdown in the event metadata: %v", evt.CustomMetadata)
+	}
+	if b.ModelCalls != 1 || b.Model < 5*time.Millisecond {
+		t.Errorf("Unexpected model latency: %v (%d calls)", b.Model, b.ModelCalls)
+	}
+	if b.Tools["get_weather"] < 10*time.Millisecond {
+		t.Errorf("Expected both tool calls to count, got %v", b.Tools)
+	}
+	if _, ok := b.Persistence["memory"]; !ok {
+		t.Errorf("Expected persistence of the user message, got %v", b.Persistence)
+	}
+	if b.Total < b.Model+5*time.Millisecond {
+		t.Errorf("Total %v shorter than its stages", b.Total)
+	}
+
+	tracker.afterRun(inv)
+
+	if len(finished) != 1 || finished[0].SessionID != "sess1" || finished[0].ModelCalls != 1 {
+		t.Fatalf("Unexpected finished turns: %+v", finished)
+	}
+
+	stats := tracker.Stats()
+	if stats[StatTurn].Count != 1 || stats[StatModel].Count != 1 || stats["tool:get_weather"].Count != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if stats["persistence:memory"].Count != 1 {
+		t.Errorf("Expected 1 persistence, got %+v", stats["persistence:memory"])
+	}
+
+	// Calls after the turn ended are not recorded
+	tracker.beforeModel(tc, &model.LLMRequest{})
+	tracker.afterModel(tc, &model.LLMResponse{}, nil)
+	if tracker.Stats()[StatModel].Count != 1 {
+		t.Error("Expected no model call recorded outside a turn")
+	}
+}
+
+func TestFromEventJSON(t *testing.T) {
+	// Stored events read back numbers as float64
+	evt := session.NewEvent("inv1")
+	evt.CustomMetadata = map[string]any{
+		MetadataKey: map[string]any{
+			"model_ms":       float64(1200),
+			"model_calls":    float64(2),
+			"tools_ms":       map[string]any{"search": float64(300)},
+			"persistence_ms": map[string]any{"redis": float64(4)},
+			"total_ms":       float64(1600),
+		},
+	}
+
+	b, ok := FromEvent(evt)
+	if !ok {
+		t.Fatal("Expected a breakdown")
+	}
+	if b.Model != 1200*time.Millisecond || b.ModelCalls != 2 || b.Total != 1600*time.Millisecond {
+		t.Errorf("Unexpected breakdown: %+v", b)
+	}
+	if b.Tools["search"] != 300*time.Millisecond || b.Persistence["redis"] != 4*time.Millisecond {
+		t.Errorf("Unexpected stages: %v, %v", b.Tools, b.Persistence)
+	}
+
+	if _, ok := FromEvent(session.NewEvent("inv2")); ok {
+		t.Error("Expected no breakdown without metadata")
+	}
+}
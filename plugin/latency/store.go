@@ -0,0 +1,58 @@
+package latency
+
+import (
+	"context"
+	"time"
+
+	ksess "github.com/kydenul/k-adk/session"
+	"google.golang.org/adk/session"
+)
+
+// Ensure interfaces are implemented
+var (
+	_ session.Service = (*timedService)(nil)
+	_ ksess.Persister = (*timedPersister)(nil)
+)
+
+// SessionService wraps svc to time its event appends as persistence of the store
+// name (e.g. "redis"). The time of a persister configured on svc is included; wrap
+// the persister with Persister to tell it apart.
+func (t *Tracker) SessionService(name string, svc session.Service) session.Service {
+	return &timedService{Service: svc, tracker: t, name: name}
+}
+
+// Persister wraps p to time its event persistence as persistence of the store
+// name (e.g. "postgres").
+func (t *Tracker) Persister(name string, p ksess.Persister) ksess.Persister {
+	return &timedPersister{Persister: p, tracker: t, name: name}
+}
+
+type timedService struct {
+	session.Service
+
+	tracker *Tracker
+	name    string
+}
+
+func (s *timedService) AppendEvent(ctx context.Context, sess session.Session, evt *session.Event) error {
+	start := time.Now()
+	err := s.Service.AppendEvent(ctx, sess, evt)
+	s.tracker.persisted(evt.InvocationID, s.name, time.Since(start))
+
+	return err
+}
+
+type timedPersister struct {
+	ksess.Persister
+
+	tracker *Tracker
+	name    string
+}
+
+func (p *timedPersister) PersistEvent(ctx context.Context, sess session.Session, evt *session.Event) error {
+	start := time.Now()
+	err := p.Persister.PersistEvent(ctx, sess, evt)
+	p.tracker.persisted(evt.InvocationID, p.name, time.Since(start))
+
+	return err
+}
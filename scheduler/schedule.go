@@ -0,0 +1,131 @@
+// Package scheduler triggers agent invocations on cron schedules. Schedules are
+// stored in PostgreSQL and every occurrence is claimed through a Redis lock, so
+// that exactly one server instance fires it.
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Session policies.
+const (
+	// SessionPolicyNew runs every occurrence in a fresh session.
+	SessionPolicyNew = "new"
+
+	// SessionPolicyAppend appends every occurrence to Schedule.SessionID,
+	// creating that session on the first run if it does not exist.
+	SessionPolicyAppend = "append"
+)
+
+var (
+	ErrInvalidSchedule  = errors.New("invalid schedule")
+	ErrScheduleNotFound = errors.New("schedule not found")
+)
+
+// Schedule is a recurring agent invocation.
+type Schedule struct {
+	ID      int64  `json:"id"`
+	AppName string `json:"appName"`
+	UserID  string `json:"userId"`
+
+	// Cron is a standard 5-field cron expression (or a descriptor such as "@hourly"),
+	// evaluated in UTC unless prefixed with "CRON_TZ=<zone> ".
+	Cron string `json:"cron"`
+
+	// Message is a text/template for the user message sent on every run.
+	// It is executed with TemplateData.
+	Message string `json:"message"`
+
+	// SessionPolicy is SessionPolicyNew or SessionPolicyAppend.
+	SessionPolicy string `json:"sessionPolicy"`
+
+	// SessionID is the target session for SessionPolicyAppend.
+	SessionID string `json:"sessionId,omitempty"`
+
+	Enabled   bool       `json:"enabled"`
+	NextRunAt time.Time  `json:"nextRunAt"`
+	LastRunAt *time.Time `json:"lastRunAt,omitempty"`
+	RunCount  int64      `json:"runCount"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// TemplateData is the data available to Schedule.Message.
+type TemplateData struct {
+	// Now is the scheduled time of the occurrence.
+	Now time.Time
+	// LastRunAt is the time of the previous occurrence, zero on the first run.
+	LastRunAt time.Time
+	// RunCount is the number of previous occurrences.
+	RunCount int64
+	// ScheduleID is the ID of the schedule.
+	ScheduleID int64
+}
+
+// cronParser accepts standard 5-field expressions and descriptors.
+var cronParser = cron.NewParser(
+	cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+// Validate checks the schedule's fields, cron expression and message template.
+func (s *Schedule) Validate() error {
+	if s.AppName == "" || s.UserID == "" {
+		return fmt.Errorf("%w: appName and userId are required", ErrInvalidSchedule)
+	}
+
+	if _, err := cronParser.Parse(s.Cron); err != nil {
+		return fmt.Errorf("%w: cron %q: %v", ErrInvalidSchedule, s.Cron, err)
+	}
+
+	if strings.TrimSpace(s.Message) == "" {
+		return fmt.Errorf("%w: message is required", ErrInvalidSchedule)
+	}
+	if _, err := template.New("message").Parse(s.Message); err != nil {
+		return fmt.Errorf("%w: message template: %v", ErrInvalidSchedule, err)
+	}
+
+	switch s.SessionPolicy {
+	case SessionPolicyNew:
+	case SessionPolicyAppend:
+		if s.SessionID == "" {
+			return fmt.Errorf("%w: sessionId is required for the append policy", ErrInvalidSchedule)
+		}
+	default:
+		return fmt.Errorf("%w: unknown session policy %q", ErrInvalidSchedule, s.SessionPolicy)
+	}
+
+	return nil
+}
+
+// Next returns the first occurrence of the schedule strictly after t.
+func (s *Schedule) Next(t time.Time) (time.Time, error) {
+	sched, err := cronParser.Parse(s.Cron)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: cron %q: %v", ErrInvalidSchedule, s.Cron, err)
+	}
+	return sched.Next(t.UTC()), nil
+}
+
+// RenderMessage executes the message template for the occurrence at now.
+func (s *Schedule) RenderMessage(now time.Time) (string, error) {
+	tmpl, err := template.New("message").Option("missingkey=error").Parse(s.Message)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse message template: %w", err)
+	}
+
+	data := TemplateData{Now: now, RunCount: s.RunCount, ScheduleID: s.ID}
+	if s.LastRunAt != nil {
+		data.LastRunAt = *s.LastRunAt
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render message template: %w", err)
+	}
+	return sb.String(), nil
+}
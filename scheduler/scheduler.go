@@ -0,0 +1,324 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/log"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/adk/agent"
+	"google.golang.org/adk/memory"
+	"google.golang.org/adk/runner"
+	"google.golang.org/adk/session"
+	"google.golang.org/genai"
+)
+
+const (
+	defaultInterval = 15 * time.Second
+	defaultLockTTL  = 10 * time.Minute
+	defaultBatch    = 100
+)
+
+// Invocation is a single occurrence of a schedule.
+type Invocation struct {
+	Schedule *Schedule
+
+	// ScheduledAt is the occurrence time (the schedule's NextRunAt when it fired).
+	ScheduledAt time.Time
+
+	// SessionID is the session the run appends to.
+	SessionID string
+
+	// Message is the rendered user message.
+	Message *genai.Content
+}
+
+// RunFunc executes the agent for an invocation.
+type RunFunc func(ctx context.Context, inv *Invocation) error
+
+// Config configures a Scheduler.
+type Config struct {
+	// Store holds the schedules.
+	Store *Store
+
+	// Redis is used to claim every occurrence, so that only one instance fires it.
+	Redis redis.UniversalClient
+
+	// SessionService creates and looks up the sessions of the runs.
+	SessionService session.Service
+
+	// Run executes an invocation. See NewAgentRunFunc.
+	Run RunFunc
+
+	// Optional. Interval between polls of due schedules. Default: 15s.
+	Interval time.Duration
+
+	// Optional. LockTTL of an occurrence claim. It must exceed the time between
+	// claiming and advancing a schedule. Default: 10m.
+	LockTTL time.Duration
+
+	// Optional. Logger for logging. Falls back to `DiscardLog` if nil.
+	Logger log.Logger
+}
+
+// Scheduler polls the store for due schedules and fires them.
+//
+// Missed occurrences (e.g. while every instance was down) are coalesced: a schedule
+// fires once for its overdue NextRunAt and then moves to the next time after now.
+type Scheduler struct {
+	log.Logger
+
+	store    *Store
+	rdb      redis.UniversalClient
+	sessions session.Service
+	run      RunFunc
+	interval time.Duration
+	lockTTL  time.Duration
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates a Scheduler with the specified configuration. Call Start to begin polling.
+func New(cfg Config) (*Scheduler, error) {
+	if cfg.Store == nil {
+		return nil, errors.New("store cannot be nil")
+	}
+	if cfg.Redis == nil {
+		return nil, errors.New("redis client cannot be nil")
+	}
+	if cfg.SessionService == nil {
+		return nil, errors.New("session service cannot be nil")
+	}
+	if cfg.Run == nil {
+		return nil, errors.New("run function cannot be nil")
+	}
+
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.LockTTL <= 0 {
+		cfg.LockTTL = defaultLockTTL
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = discardlog.NewDiscardLog()
+	}
+
+	return &Scheduler{
+		Logger:   cfg.Logger,
+		store:    cfg.Store,
+		rdb:      cfg.Redis,
+		sessions: cfg.SessionService,
+		run:      cfg.Run,
+		interval: cfg.Interval,
+		lockTTL:  cfg.LockTTL,
+	}, nil
+}
+
+// Start launches the polling loop. It stops when ctx is cancelled or Stop is called.
+func (s *Scheduler) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+
+	s.wg.Go(func() { s.loop(ctx) })
+
+	s.Infof("scheduler started: interval=%s", s.interval)
+}
+
+// Stop cancels the running invocations and waits for them to exit.
+func (s *Scheduler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+
+	s.Info("scheduler stopped")
+}
+
+func (s *Scheduler) loop(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.Tick(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick fires every schedule due at now. It is called by the polling loop and is
+// exported for tests and for callers that drive the scheduler themselves.
+func (s *Scheduler) Tick(ctx context.Context, now time.Time) {
+	due, err := s.store.Due(ctx, now, defaultBatch)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.Errorf("failed to load due schedules: %v", err)
+		}
+		return
+	}
+
+	for _, sched := range due {
+		if ctx.Err() != nil {
+			return
+		}
+
+		inv, err := s.claim(ctx, sched, now)
+		if err != nil {
+			s.Errorf("failed to fire schedule: id=%d, err=%v", sched.ID, err)
+			continue
+		}
+		if inv == nil {
+			continue
+		}
+
+		s.wg.Go(func() {
+			if err := s.run(ctx, inv); err != nil {
+				s.Errorf("scheduled run failed: id=%d, session=%s, err=%v",
+					inv.Schedule.ID, inv.SessionID, err)
+				return
+			}
+			s.Infof("scheduled run completed: id=%d, session=%s", inv.Schedule.ID, inv.SessionID)
+		})
+	}
+}
+
+// claim takes the occurrence lock, advances the schedule and prepares the
+// invocation. It returns nil if another instance already fired the occurrence.
+func (s *Scheduler) claim(ctx context.Context, sched *Schedule, now time.Time) (*Invocation, error) {
+	runAt := sched.NextRunAt
+
+	lockKey := buildLockKey(sched.ID, runAt)
+	ok, err := s.rdb.SetNX(ctx, lockKey, "1", s.lockTTL).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim occurrence: %w", err)
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	next, err := sched.Next(now)
+	if err != nil {
+		return nil, err
+	}
+
+	// The conditional update guards against a claim that outlived its lock
+	advanced, err := s.store.advance(ctx, sched.ID, runAt, next)
+	if err != nil {
+		return nil, err
+	}
+	if !advanced {
+		return nil, nil
+	}
+
+	sessionID, err := s.resolveSession(ctx, sched)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := sched.RenderMessage(runAt)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Infof("schedule fired: id=%d, scheduledAt=%s, session=%s, next=%s",
+		sched.ID, runAt.Format(time.RFC3339), sessionID, next.Format(time.RFC3339))
+
+	return &Invocation{
+		Schedule:    sched,
+		ScheduledAt: runAt,
+		SessionID:   sessionID,
+		Message:     genai.NewContentFromText(text, genai.RoleUser),
+	}, nil
+}
+
+// resolveSession returns the session of the run according to the session policy.
+func (s *Scheduler) resolveSession(ctx context.Context, sched *Schedule) (string, error) {
+	if sched.SessionPolicy == SessionPolicyAppend {
+		_, err := s.sessions.Get(ctx, &session.GetRequest{
+			AppName:   sched.AppName,
+			UserID:    sched.UserID,
+			SessionID: sched.SessionID,
+		})
+		if err == nil {
+			return sched.SessionID, nil
+		}
+		s.Debugf("append session not found, creating it: id=%d, session=%s, err=%v",
+			sched.ID, sched.SessionID, err)
+	}
+
+	created, err := s.sessions.Create(ctx, &session.CreateRequest{
+		AppName:   sched.AppName,
+		UserID:    sched.UserID,
+		SessionID: sched.SessionID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return created.Session.ID(), nil
+}
+
+func buildLockKey(id int64, runAt time.Time) string {
+	return "scheduler:lock:" + strconv.FormatInt(id, 10) + ":" + strconv.FormatInt(runAt.Unix(), 10)
+}
+
+// NewAgentRunFunc returns a RunFunc that runs the schedule's app agent and drains
+// its events. The session is added to memSvc afterwards if memSvc is not nil.
+func NewAgentRunFunc(
+	loader agent.Loader,
+	sessSvc session.Service,
+	memSvc memory.Service,
+	pluginConfig runner.PluginConfig,
+) RunFunc {
+	return func(ctx context.Context, inv *Invocation) error {
+		sched := inv.Schedule
+
+		a, err := loader.LoadAgent(sched.AppName)
+		if err != nil {
+			return fmt.Errorf("failed to load agent: %w", err)
+		}
+
+		r, err := runner.New(runner.Config{
+			AppName:        sched.AppName,
+			Agent:          a,
+			SessionService: sessSvc,
+			MemoryService:  memSvc,
+			PluginConfig:   pluginConfig,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create runner: %w", err)
+		}
+
+		for _, err := range r.Run(ctx, sched.UserID, inv.SessionID, inv.Message, agent.RunConfig{}) {
+			if err != nil {
+				return fmt.Errorf("runner error: %w", err)
+			}
+		}
+
+		if memSvc == nil {
+			return nil
+		}
+
+		resp, err := sessSvc.Get(context.WithoutCancel(ctx), &session.GetRequest{
+			AppName:   sched.AppName,
+			UserID:    sched.UserID,
+			SessionID: inv.SessionID,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get session for memory: %w", err)
+		}
+		if err := memSvc.AddSession(context.WithoutCancel(ctx), resp.Session); err != nil {
+			return fmt.Errorf("failed to add session to memory: %w", err)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,229 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	_ "github.com/lib/pq" // PostgreSQL driver
+)
+
+// Store persists schedules in the agent_schedules table.
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore creates a Store on db and creates the agent_schedules table if needed.
+// The caller owns db.
+func NewStore(ctx context.Context, db *sql.DB) (*Store, error) {
+	if db == nil {
+		return nil, errors.New("database cannot be nil")
+	}
+
+	const schema = `
+		CREATE TABLE IF NOT EXISTS agent_schedules (
+			id BIGSERIAL PRIMARY KEY,
+			app_name VARCHAR(255) NOT NULL,
+			user_id VARCHAR(255) NOT NULL,
+			cron VARCHAR(255) NOT NULL,
+			message TEXT NOT NULL,
+			session_policy VARCHAR(16) NOT NULL,
+			session_id VARCHAR(255) NOT NULL DEFAULT '',
+			enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			next_run_at TIMESTAMPTZ NOT NULL,
+			last_run_at TIMESTAMPTZ,
+			run_count BIGINT NOT NULL DEFAULT 0,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_agent_schedules_due ON agent_schedules(next_run_at) WHERE enabled;
+		CREATE INDEX IF NOT EXISTS idx_agent_schedules_owner ON agent_schedules(app_name, user_id);
+	`
+
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		return nil, fmt.Errorf("failed to create agent_schedules table: %w", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+const selectColumns = `
+	id, app_name, user_id, cron, message, session_policy, session_id,
+	enabled, next_run_at, last_run_at, run_count, created_at
+`
+
+// Create validates s, computes its first run time and inserts it. s.ID, s.NextRunAt
+// and s.CreatedAt are filled in.
+func (st *Store) Create(ctx context.Context, s *Schedule) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+
+	next, err := s.Next(time.Now())
+	if err != nil {
+		return err
+	}
+	s.NextRunAt = next
+
+	const query = `
+		INSERT INTO agent_schedules (app_name, user_id, cron, message, session_policy, session_id, enabled, next_run_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`
+
+	if err := st.db.QueryRowContext(ctx, query,
+		s.AppName, s.UserID, s.Cron, s.Message, s.SessionPolicy, s.SessionID, s.Enabled, s.NextRunAt,
+	).Scan(&s.ID, &s.CreatedAt); err != nil {
+		return fmt.Errorf("failed to insert schedule: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns the schedule with id.
+func (st *Store) Get(ctx context.Context, id int64) (*Schedule, error) {
+	row := st.db.QueryRowContext(ctx, `SELECT `+selectColumns+` FROM agent_schedules WHERE id = $1`, id)
+
+	s, err := scanSchedule(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrScheduleNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schedule: %w", err)
+	}
+
+	return s, nil
+}
+
+// List returns the schedules of a user, oldest first.
+func (st *Store) List(ctx context.Context, appName, userID string) ([]*Schedule, error) {
+	rows, err := st.db.QueryContext(ctx,
+		`SELECT `+selectColumns+` FROM agent_schedules WHERE app_name = $1 AND user_id = $2 ORDER BY id`,
+		appName, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schedules: %w", err)
+	}
+
+	return scanSchedules(rows)
+}
+
+// Due returns the enabled schedules whose next run is at or before now.
+func (st *Store) Due(ctx context.Context, now time.Time, limit int) ([]*Schedule, error) {
+	rows, err := st.db.QueryContext(ctx,
+		`SELECT `+selectColumns+` FROM agent_schedules WHERE enabled AND next_run_at <= $1 ORDER BY next_run_at LIMIT $2`,
+		now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due schedules: %w", err)
+	}
+
+	return scanSchedules(rows)
+}
+
+// SetEnabled enables or disables a schedule. Re-enabling recomputes the next run
+// so that missed occurrences are not fired.
+func (st *Store) SetEnabled(ctx context.Context, id int64, enabled bool) error {
+	s, err := st.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	next := s.NextRunAt
+	if enabled && !s.Enabled {
+		if next, err = s.Next(time.Now()); err != nil {
+			return err
+		}
+	}
+
+	res, err := st.db.ExecContext(ctx,
+		`UPDATE agent_schedules SET enabled = $2, next_run_at = $3 WHERE id = $1`, id, enabled, next)
+	if err != nil {
+		return fmt.Errorf("failed to update schedule: %w", err)
+	}
+
+	return expectOneRow(res)
+}
+
+// Delete removes a schedule.
+func (st *Store) Delete(ctx context.Context, id int64) error {
+	res, err := st.db.ExecContext(ctx, `DELETE FROM agent_schedules WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+
+	return expectOneRow(res)
+}
+
+// advance records the occurrence at runAt and moves the schedule to next. It only
+// applies if the schedule is still at runAt, so an occurrence is advanced once.
+func (st *Store) advance(ctx context.Context, id int64, runAt, next time.Time) (bool, error) {
+	res, err := st.db.ExecContext(ctx, `
+		UPDATE agent_schedules
+		SET next_run_at = $3, last_run_at = $2, run_count = run_count + 1
+		WHERE id = $1 AND next_run_at = $2
+	`, id, runAt, next)
+	if err != nil {
+		return false, fmt.Errorf("failed to advance schedule: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to advance schedule: %w", err)
+	}
+	return n == 1, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSchedule(row rowScanner) (*Schedule, error) {
+	var (
+		s         Schedule
+		lastRunAt sql.NullTime
+	)
+
+	if err := row.Scan(
+		&s.ID, &s.AppName, &s.UserID, &s.Cron, &s.Message, &s.SessionPolicy, &s.SessionID,
+		&s.Enabled, &s.NextRunAt, &lastRunAt, &s.RunCount, &s.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if lastRunAt.Valid {
+		s.LastRunAt = &lastRunAt.Time
+	}
+
+	return &s, nil
+}
+
+func scanSchedules(rows *sql.Rows) ([]*Schedule, error) {
+	defer rows.Close()
+
+	var schedules []*Schedule
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan schedule: %w", err)
+		}
+		schedules = append(schedules, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate schedules: %w", err)
+	}
+
+	return schedules, nil
+}
+
+func expectOneRow(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	if n == 0 {
+		return ErrScheduleNotFound
+	}
+	return nil
+}
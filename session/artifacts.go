@@ -0,0 +1,176 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/log"
+	"google.golang.org/adk/artifact"
+	"google.golang.org/adk/session"
+)
+
+var _ session.Service = (*ArtifactCleanupService)(nil)
+
+// userScopedPrefix marks the artifacts scoped to a user rather than a session.
+const userScopedPrefix = "user:"
+
+// ErrNilArtifactService is returned when the artifact service is nil.
+var ErrNilArtifactService = errors.New("artifact service cannot be nil")
+
+// ArtifactCleanupConfig configures WithArtifactCleanup.
+type ArtifactCleanupConfig struct {
+	// Optional. Archive receives a copy of every version of the artifacts of a
+	// deleted session, under the same app, user and session, before they are
+	// deleted. Default: the artifacts are deleted.
+	Archive artifact.Service
+
+	// Optional. Logger for logging. Falls back to `DiscardLog` if nil.
+	Logger log.Logger
+}
+
+// ArtifactCleanupService is a session.Service removing, or archiving, the
+// artifacts of the sessions it deletes.
+type ArtifactCleanupService struct {
+	next      session.Service
+	artifacts artifact.Service
+	archive   artifact.Service
+	logger    log.Logger
+}
+
+// WithArtifactCleanup wraps svc so that deleting a session also removes the
+// artifacts saved during it in artifacts, e.g. by the image generation tool or
+// an agent callback, instead of orphaning them. User-scoped artifacts ("user:"
+// file names) outlive the session and are kept.
+func WithArtifactCleanup(
+	svc session.Service,
+	artifacts artifact.Service,
+	cfg ArtifactCleanupConfig,
+) (*ArtifactCleanupService, error) {
+	if svc == nil {
+		return nil, ErrNilService
+	}
+	if artifacts == nil {
+		return nil, ErrNilArtifactService
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = discardlog.NewDiscardLog()
+	}
+
+	return &ArtifactCleanupService{
+		next:      svc,
+		artifacts: artifacts,
+		archive:   cfg.Archive,
+		logger:    cfg.Logger,
+	}, nil
+}
+
+// Create creates a session.
+func (s *ArtifactCleanupService) Create(
+	ctx context.Context,
+	req *session.CreateRequest,
+) (*session.CreateResponse, error) {
+	return s.next.Create(ctx, req)
+}
+
+// Get gets a session.
+func (s *ArtifactCleanupService) Get(ctx context.Context, req *session.GetRequest) (*session.GetResponse, error) {
+	return s.next.Get(ctx, req)
+}
+
+// List lists sessions.
+func (s *ArtifactCleanupService) List(ctx context.Context, req *session.ListRequest) (*session.ListResponse, error) {
+	return s.next.List(ctx, req)
+}
+
+// AppendEvent appends an event to a session.
+func (s *ArtifactCleanupService) AppendEvent(ctx context.Context, sess session.Session, evt *session.Event) error {
+	return s.next.AppendEvent(ctx, sess, evt)
+}
+
+// Delete deletes a session, then its artifacts. A failed artifact cleanup is
+// logged without failing the request: the session is gone, and PurgeArtifacts
+// can be retried.
+func (s *ArtifactCleanupService) Delete(ctx context.Context, req *session.DeleteRequest) error {
+	if err := s.next.Delete(ctx, req); err != nil {
+		return err
+	}
+
+	if err := s.PurgeArtifacts(ctx, req.AppName, req.UserID, req.SessionID); err != nil {
+		s.logger.Warnf("failed to clean up artifacts of session %s: %v", req.SessionID, err)
+	}
+
+	return nil
+}
+
+// PurgeArtifacts removes the artifacts of a session, archiving them first if an
+// archive is configured, e.g. from purge jobs deleting sessions without Delete.
+// An artifact that fails to be archived is kept.
+func (s *ArtifactCleanupService) PurgeArtifacts(ctx context.Context, appName, userID, sessionID string) error {
+	resp, err := s.artifacts.List(ctx, &artifact.ListRequest{
+		AppName: appName, UserID: userID, SessionID: sessionID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list artifacts: %w", err)
+	}
+
+	var errs []error
+	purged := 0
+	for _, fileName := range resp.FileNames {
+		if strings.HasPrefix(fileName, userScopedPrefix) {
+			continue
+		}
+
+		if s.archive != nil {
+			if err := s.archiveArtifact(ctx, appName, userID, sessionID, fileName); err != nil {
+				errs = append(errs, fmt.Errorf("failed to archive artifact %s: %w", fileName, err))
+				continue
+			}
+		}
+
+		// NOTE: Version 0 deletes every version
+		if err := s.artifacts.Delete(ctx, &artifact.DeleteRequest{
+			AppName: appName, UserID: userID, SessionID: sessionID, FileName: fileName,
+		}); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete artifact %s: %w", fileName, err))
+			continue
+		}
+		purged++
+	}
+
+	s.logger.Infof("session artifacts purged: app=%s, user=%s, session=%s, artifacts=%d, archived=%t",
+		appName, userID, sessionID, purged, s.archive != nil)
+
+	return errors.Join(errs...)
+}
+
+// archiveArtifact copies every version of an artifact to the archive, oldest first.
+func (s *ArtifactCleanupService) archiveArtifact(ctx context.Context, appName, userID, sessionID, fileName string) error {
+	resp, err := s.artifacts.Versions(ctx, &artifact.VersionsRequest{
+		AppName: appName, UserID: userID, SessionID: sessionID, FileName: fileName,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list versions: %w", err)
+	}
+
+	versions := slices.Sorted(slices.Values(resp.Versions))
+	for _, version := range versions {
+		loaded, err := s.artifacts.Load(ctx, &artifact.LoadRequest{
+			AppName: appName, UserID: userID, SessionID: sessionID, FileName: fileName, Version: version,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to load version %d: %w", version, err)
+		}
+
+		if _, err := s.archive.Save(ctx, &artifact.SaveRequest{
+			AppName: appName, UserID: userID, SessionID: sessionID, FileName: fileName, Part: loaded.Part,
+		}); err != nil {
+			return fmt.Errorf("failed to save version %d: %w", version, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"google.golang.org/adk/artifact"
+	"google.golang.org/adk/session"
+	"google.golang.org/genai"
+)
+
+func TestArtifactCleanup(t *testing.T) {
+	ctx := context.Background()
+
+	artifacts := artifact.InMemoryService()
+	archive := artifact.InMemoryService()
+
+	svc, err := WithArtifactCleanup(session.InMemoryService(), artifacts, ArtifactCleanupConfig{Archive: archive})
+	if err != nil {
+		t.Fatalf("WithArtifactCleanup failed: %v", err)
+	}
+
+	save := func(sessionID, fileName, text string) {
+		t.Helper()
+		if _, err := artifacts.Save(ctx, &artifact.SaveRequest{
+			AppName: "app", UserID: "user", SessionID: sessionID, FileName: fileName, Part: genai.NewPartFromText(text),
+		}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	for _, id := range []string{"s1", "s2"} {
+		if _, err := svc.Create(ctx, &session.CreateRequest{AppName: "app", UserID: "user", SessionID: id}); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+	save("s1", "report.md", "v1")
+	save("s1", "report.md", "v2")
+	save("s1", "user:profile.md", "profile")
+	save("s2", "image.png", "other session")
+
+	if err := svc.Delete(ctx, &session.DeleteRequest{AppName: "app", UserID: "user", SessionID: "s1"}); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	list := func(svc artifact.Service, sessionID string) []string {
+		t.Helper()
+		resp, err := svc.List(ctx, &artifact.ListRequest{AppName: "app", UserID: "user", SessionID: sessionID})
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		return resp.FileNames
+	}
+
+	if got := list(artifacts, "s1"); !slices.Equal(got, []string{"user:profile.md"}) {
+		t.Errorf("artifacts left = %v, want only the user-scoped one", got)
+	}
+	if got := list(artifacts, "s2"); !slices.Contains(got, "image.png") {
+		t.Errorf("artifacts of another session = %v, want image.png kept", got)
+	}
+
+	versions, err := archive.Versions(ctx, &artifact.VersionsRequest{
+		AppName: "app", UserID: "user", SessionID: "s1", FileName: "report.md",
+	})
+	if err != nil || len(versions.Versions) != 2 {
+		t.Fatalf("archived versions = %v, %v, want 2", versions, err)
+	}
+	latest, _ := archive.Load(ctx, &artifact.LoadRequest{
+		AppName: "app", UserID: "user", SessionID: "s1", FileName: "report.md",
+	})
+	if latest.Part.Text != "v2" {
+		t.Errorf("latest archived version = %q, want v2", latest.Part.Text)
+	}
+
+	t.Run("nil services", func(t *testing.T) {
+		if _, err := WithArtifactCleanup(nil, artifacts, ArtifactCleanupConfig{}); !errors.Is(err, ErrNilService) {
+			t.Errorf("error = %v, want ErrNilService", err)
+		}
+		if _, err := WithArtifactCleanup(session.InMemoryService(), nil, ArtifactCleanupConfig{}); !errors.Is(err, ErrNilArtifactService) {
+			t.Errorf("error = %v, want ErrNilArtifactService", err)
+		}
+	})
+}
@@ -0,0 +1,26 @@
+package session
+
+import (
+	"strings"
+
+	"google.golang.org/adk/session"
+)
+
+// BranchVisible reports whether an event of eventBranch is part of the history seen
+// on branch, following the ADK branch format "agent_1.agent_2.agent_3": events of
+// the root (empty) branch, of branch itself and of its ancestors are visible, while
+// events of sibling and child branches are not.
+func BranchVisible(eventBranch, branch string) bool {
+	return eventBranch == "" || eventBranch == branch || strings.HasPrefix(branch, eventBranch+".")
+}
+
+// FilterBranch returns the events of events visible on branch, in order.
+func FilterBranch(events []*session.Event, branch string) []*session.Event {
+	filtered := make([]*session.Event, 0, len(events))
+	for _, evt := range events {
+		if evt != nil && BranchVisible(evt.Branch, branch) {
+			filtered = append(filtered, evt)
+		}
+	}
+	return filtered
+}
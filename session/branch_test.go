@@ -0,0 +1,44 @@
+package session
+
+import (
+	"testing"
+
+	"google.golang.org/adk/session"
+)
+
+func TestBranchVisible(t *testing.T) {
+	tests := []struct {
+		eventBranch, branch string
+		want                bool
+	}{
+		{"", "", true},
+		{"", "root.a", true},
+		{"root", "root.a", true},
+		{"root.a", "root.a", true},
+		{"root.a", "root.b", false},
+		{"root.a.x", "root.a", false},
+		{"root.ab", "root.a", false},
+		{"root.a", "", false},
+	}
+
+	for _, tt := range tests {
+		if got := BranchVisible(tt.eventBranch, tt.branch); got != tt.want {
+			t.Errorf("BranchVisible(%q, %q) = %v, want %v", tt.eventBranch, tt.branch, got, tt.want)
+		}
+	}
+}
+
+func TestFilterBranch(t *testing.T) {
+	events := []*session.Event{
+		{ID: "e1"},
+		{ID: "e2", Branch: "root.a"},
+		{ID: "e3", Branch: "root.b"},
+		nil,
+		{ID: "e4", Branch: "root"},
+	}
+
+	got := FilterBranch(events, "root.a")
+	if len(got) != 3 || got[0].ID != "e1" || got[1].ID != "e2" || got[2].ID != "e4" {
+		t.Errorf("FilterBranch returned %v", got)
+	}
+}
@@ -0,0 +1,250 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"maps"
+	"slices"
+	"time"
+
+	"google.golang.org/adk/session"
+)
+
+// DumpVersion is the version of the SessionDump schema written by Export.
+const DumpVersion = 1
+
+var (
+	// ErrInvalidDump is returned when importing a dump of another schema version,
+	// or without app, user or session ID.
+	ErrInvalidDump = errors.New("invalid session dump")
+
+	// ErrDumpNotFound is returned when exporting a session that does not exist.
+	ErrDumpNotFound = errors.New("session to export not found")
+)
+
+// SessionDump is a session with its state and events, in a stable JSON schema, to
+// move sessions between environments (dev to prod) or backends (in-memory, Redis,
+// PostgreSQL). Events are serialized as session.Event, the way the backends of
+// this module store them.
+type SessionDump struct {
+	// Version is the schema version, DumpVersion.
+	Version int `json:"version"`
+
+	AppName        string            `json:"app_name"`
+	UserID         string            `json:"user_id"`
+	ID             string            `json:"id"`
+	State          map[string]any    `json:"state"`
+	Tags           map[string]string `json:"tags,omitempty"`
+	Events         []*session.Event  `json:"events"`
+	LastUpdateTime time.Time         `json:"last_update_time"`
+
+	// ExportedAt is when the dump was taken.
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+// Importer is implemented by the session services importing a session as is,
+// keeping the timestamps of its events and its last update time, such as
+// redis.RedisSessionService and inmemory.InMemorySessionService. ImportSession
+// fails if the session exists.
+type Importer interface {
+	ImportSession(ctx context.Context, stored *StoredSession) error
+}
+
+// Export reads a session of svc, with all its events, into a dump.
+func Export(ctx context.Context, svc session.Service, appName, userID, sessionID string) (*SessionDump, error) {
+	if svc == nil {
+		return nil, ErrNilService
+	}
+
+	resp, err := svc.Get(ctx, &session.GetRequest{AppName: appName, UserID: userID, SessionID: sessionID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if resp == nil || resp.Session == nil {
+		return nil, fmt.Errorf("%w: %s", ErrDumpNotFound, sessionID)
+	}
+
+	sess := resp.Session
+	stored := &StoredSession{
+		ID:             sess.ID(),
+		AppName:        sess.AppName(),
+		UserID:         sess.UserID(),
+		State:          map[string]any{},
+		Tags:           maps.Clone(TagsOf(sess)),
+		Events:         slices.Collect(sess.Events().All()),
+		LastUpdateTime: sess.LastUpdateTime(),
+	}
+	if state := sess.State(); state != nil {
+		stored.State = maps.Collect(state.All())
+	}
+
+	return NewDump(stored), nil
+}
+
+// ExportLoaded reads a session of a persistent store, e.g. postgres.SessionPersister,
+// into a dump.
+func ExportLoaded(ctx context.Context, loader Loader, appName, userID, sessionID string) (*SessionDump, error) {
+	stored, err := loader.LoadSession(ctx, appName, userID, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+	if stored == nil {
+		return nil, fmt.Errorf("%w: %s", ErrDumpNotFound, sessionID)
+	}
+
+	return NewDump(stored), nil
+}
+
+// NewDump returns the dump of a stored session.
+func NewDump(stored *StoredSession) *SessionDump {
+	state := stored.State
+	if state == nil {
+		state = map[string]any{}
+	}
+	events := stored.Events
+	if events == nil {
+		events = []*session.Event{}
+	}
+
+	return &SessionDump{
+		Version:        DumpVersion,
+		AppName:        stored.AppName,
+		UserID:         stored.UserID,
+		ID:             stored.ID,
+		State:          state,
+		Tags:           stored.Tags,
+		Events:         events,
+		LastUpdateTime: stored.LastUpdateTime,
+		ExportedAt:     time.Now(),
+	}
+}
+
+// Validate checks the schema version and the identity of the dump.
+func (d *SessionDump) Validate() error {
+	if d.Version != DumpVersion {
+		return fmt.Errorf("%w: version %d, expected %d", ErrInvalidDump, d.Version, DumpVersion)
+	}
+	if d.AppName == "" || d.UserID == "" || d.ID == "" {
+		return fmt.Errorf("%w: app name, user ID and session ID are required", ErrInvalidDump)
+	}
+	return nil
+}
+
+// Stored returns the dumped session as a StoredSession.
+func (d *SessionDump) Stored() *StoredSession {
+	return &StoredSession{
+		ID:             d.ID,
+		AppName:        d.AppName,
+		UserID:         d.UserID,
+		State:          d.State,
+		Tags:           d.Tags,
+		Events:         d.Events,
+		LastUpdateTime: d.LastUpdateTime,
+	}
+}
+
+// Import creates the dumped session in svc, under its app, user and session ID,
+// and returns it. Services implementing Importer keep the dump as is. Others get
+// the session created with the dumped state and the events appended in order: the
+// events keep their timestamps unless the service stamps appended events anew, and
+// the services applying the state delta of the appended events, as the ADK
+// services do, apply them again over the state.
+func Import(ctx context.Context, svc session.Service, dump *SessionDump) (session.Session, error) {
+	if svc == nil {
+		return nil, ErrNilService
+	}
+	if err := dump.Validate(); err != nil {
+		return nil, err
+	}
+
+	if importer, ok := svc.(Importer); ok {
+		if err := importer.ImportSession(ctx, dump.Stored()); err != nil {
+			return nil, fmt.Errorf("failed to import session: %w", err)
+		}
+
+		resp, err := svc.Get(ctx, &session.GetRequest{AppName: dump.AppName, UserID: dump.UserID, SessionID: dump.ID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get imported session: %w", err)
+		}
+		return resp.Session, nil
+	}
+
+	resp, err := svc.Create(ctx, &session.CreateRequest{
+		AppName:   dump.AppName,
+		UserID:    dump.UserID,
+		SessionID: dump.ID,
+		State:     maps.Clone(dump.State),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	for _, evt := range dump.Events {
+		// NOTE: Services set the timestamp and ID of the appended events, leave the
+		// dump's alone
+		replayed := *evt
+		if err := svc.AppendEvent(ctx, resp.Session, &replayed); err != nil {
+			return nil, fmt.Errorf("failed to append event %s: %w", evt.ID, err)
+		}
+	}
+
+	return resp.Session, nil
+}
+
+// ImportPersisted writes the dumped session and its events through a persister,
+// e.g. postgres.SessionPersister, keeping their timestamps. Use a synchronous
+// persister so the events are written after the session.
+func ImportPersisted(ctx context.Context, p Persister, dump *SessionDump) error {
+	if err := dump.Validate(); err != nil {
+		return err
+	}
+
+	sess := &dumpSession{dump: dump}
+	if err := p.PersistSession(ctx, sess); err != nil {
+		return fmt.Errorf("failed to persist session: %w", err)
+	}
+	for _, evt := range dump.Events {
+		if err := p.PersistEvent(ctx, sess, evt); err != nil {
+			return fmt.Errorf("failed to persist event %s: %w", evt.ID, err)
+		}
+	}
+
+	return nil
+}
+
+var (
+	_ session.Session = (*dumpSession)(nil)
+	_ session.State   = dumpState(nil)
+)
+
+// dumpSession is the session of a dump handed to persisters.
+type dumpSession struct {
+	dump *SessionDump
+}
+
+func (s *dumpSession) ID() string                { return s.dump.ID }
+func (s *dumpSession) AppName() string           { return s.dump.AppName }
+func (s *dumpSession) UserID() string            { return s.dump.UserID }
+func (s *dumpSession) State() session.State      { return dumpState(s.dump.State) }
+func (s *dumpSession) Events() session.Events    { return viewEvents(s.dump.Events) }
+func (s *dumpSession) LastUpdateTime() time.Time { return s.dump.LastUpdateTime }
+func (s *dumpSession) Tags() map[string]string   { return s.dump.Tags }
+
+// dumpState implements session.State over the state of a dump.
+type dumpState map[string]any
+
+func (s dumpState) Get(key string) (any, error) {
+	if v, ok := s[key]; ok {
+		return v, nil
+	}
+	return nil, session.ErrStateKeyNotExist
+}
+
+func (s dumpState) Set(key string, value any) error {
+	s[key] = value
+	return nil
+}
+
+func (s dumpState) All() iter.Seq2[string, any] { return maps.All(s) }
@@ -0,0 +1,74 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"google.golang.org/adk/session"
+)
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+
+	src := session.InMemoryService()
+	created, err := src.Create(ctx, &session.CreateRequest{
+		AppName: "app", UserID: "u1", SessionID: "s1", State: map[string]any{"lang": "fr"},
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	viewTestEvents(t, src, created.Session)
+
+	dump, err := Export(ctx, src, "app", "u1", "s1")
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if dump.Version != DumpVersion || len(dump.Events) != 4 || dump.State["lang"] != "fr" {
+		t.Fatalf("unexpected dump: %+v", dump)
+	}
+
+	data, err := json.Marshal(dump)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var decoded SessionDump
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	dst := session.InMemoryService()
+	imported, err := Import(ctx, dst, &decoded)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if imported.ID() != "s1" || imported.Events().Len() != 4 {
+		t.Errorf("expected session s1 with 4 events, got %s with %d", imported.ID(), imported.Events().Len())
+	}
+	if lang, _ := imported.State().Get("lang"); lang != "fr" {
+		t.Errorf("expected lang=fr, got %v", lang)
+	}
+	for i, evt := range decoded.Events {
+		if got := imported.Events().At(i); got.ID != evt.ID || got.Content.Parts[0].Text != evt.Content.Parts[0].Text {
+			t.Errorf("event %d: got %+v, want %+v", i, got, evt)
+		}
+	}
+
+	t.Run("missing session", func(t *testing.T) {
+		if _, err := Export(ctx, src, "app", "u1", "missing"); err == nil {
+			t.Error("expected an error")
+		}
+	})
+
+	t.Run("invalid dumps", func(t *testing.T) {
+		for _, dump := range []*SessionDump{
+			{Version: DumpVersion + 1, AppName: "app", UserID: "u1", ID: "s2"},
+			{Version: DumpVersion, AppName: "app", UserID: "u1"},
+		} {
+			if _, err := Import(ctx, dst, dump); !errors.Is(err, ErrInvalidDump) {
+				t.Errorf("expected ErrInvalidDump, got %v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,32 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ksess "github.com/kydenul/k-adk/session"
+)
+
+var _ ksess.HealthChecker = (*SessionService)(nil)
+
+// healthKey is the key of the item read by Ping, which no session uses: '#' is
+// escaped in the keys of the sessions.
+const healthKey = "#health"
+
+// Ping checks that the table is reachable, with a read of an item that does not
+// exist, consuming half a read capacity unit.
+func (s *SessionService) Ping(ctx context.Context) error {
+	_, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.table),
+		Key:       itemKey(healthKey, healthKey),
+	})
+	if err != nil {
+		return fmt.Errorf("dynamodb ping failed: %w", err)
+	}
+	return nil
+}
+
+// Healthy checks that the table is reachable, as Ping does.
+func (s *SessionService) Healthy(ctx context.Context) error { return s.Ping(ctx) }
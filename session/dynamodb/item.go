@@ -0,0 +1,125 @@
+package dynamodb
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/bytedance/sonic"
+	ksess "github.com/kydenul/k-adk/session"
+)
+
+// Attributes of the items.
+const (
+	attrPK         = "pk"
+	attrSK         = "sk"
+	attrAppName    = "app_name"
+	attrUserID     = "user_id"
+	attrSessionID  = "session_id"
+	attrState      = "state"
+	attrTags       = "tags"
+	attrLastUpdate = "last_update_time"
+	attrCreatedAt  = "created_at"
+	attrEventCount = "event_count"
+	attrEventID    = "event_id"
+	attrEventTime  = "event_time"
+	attrContent    = "content"
+	attrExpiresAt  = "expires_at"
+)
+
+// Prefixes of the sort keys of the sessions and the events.
+const (
+	sessionKeyPrefix = "session#"
+	eventKeyPrefix   = "event#"
+)
+
+// partitionKey returns the partition key of the items of a user.
+func partitionKey(appName, userID string) string {
+	return escapeKey(appName) + "#" + escapeKey(userID)
+}
+
+// sessionSortKey returns the sort key of a session item.
+func sessionSortKey(sessionID string) string {
+	return sessionKeyPrefix + escapeKey(sessionID)
+}
+
+// eventKeysPrefix returns the prefix of the sort keys of the events of a session.
+func eventKeysPrefix(sessionID string) string {
+	return eventKeyPrefix + escapeKey(sessionID) + "#"
+}
+
+// eventSortKey returns the sort key of the event of a session at order, zero
+// padded so the keys sort in append order.
+func eventSortKey(sessionID string, order int64) string {
+	return fmt.Sprintf("%s%020d", eventKeysPrefix(sessionID), order)
+}
+
+// escapeKey escapes an ID in a key, so it holds no '#' separator.
+func escapeKey(id string) string {
+	return url.PathEscape(id)
+}
+
+// itemKey returns the primary key of an item.
+func itemKey(pk, sk string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		attrPK: stringValue(pk),
+		attrSK: stringValue(sk),
+	}
+}
+
+func stringValue(s string) types.AttributeValue {
+	return &types.AttributeValueMemberS{Value: s}
+}
+
+func numberValue(n int64) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(n, 10)}
+}
+
+// stringAttr returns the string attribute name of item, or "".
+func stringAttr(item map[string]types.AttributeValue, name string) string {
+	if v, ok := item[name].(*types.AttributeValueMemberS); ok {
+		return v.Value
+	}
+	return ""
+}
+
+// numberAttr returns the integer attribute name of item, or 0.
+func numberAttr(item map[string]types.AttributeValue, name string) int64 {
+	if v, ok := item[name].(*types.AttributeValueMemberN); ok {
+		n, _ := strconv.ParseInt(v.Value, 10, 64)
+		return n
+	}
+	return 0
+}
+
+// expired reports whether item has expired at now. DynamoDB deletes expired items
+// in the background, up to days later, so reads skip them.
+func expired(item map[string]types.AttributeValue, now time.Time) bool {
+	expiresAt := numberAttr(item, attrExpiresAt)
+	return expiresAt > 0 && expiresAt <= now.Unix()
+}
+
+// decodeSession returns the stored session of a session item, without its events.
+func (s *SessionService) decodeSession(item map[string]types.AttributeValue) (*ksess.StoredSession, error) {
+	stored := &ksess.StoredSession{
+		ID:             stringAttr(item, attrSessionID),
+		AppName:        stringAttr(item, attrAppName),
+		UserID:         stringAttr(item, attrUserID),
+		LastUpdateTime: time.Unix(0, numberAttr(item, attrLastUpdate)),
+	}
+
+	if state := stringAttr(item, attrState); state != "" {
+		if err := sonic.UnmarshalString(state, &stored.State); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal state of session %s: %w", stored.ID, err)
+		}
+	}
+	if tags := stringAttr(item, attrTags); tags != "" {
+		if err := sonic.UnmarshalString(tags, &stored.Tags); err != nil || len(stored.Tags) == 0 {
+			stored.Tags = nil
+		}
+	}
+
+	return stored, nil
+}
@@ -0,0 +1,415 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/bytedance/sonic"
+	ksess "github.com/kydenul/k-adk/session"
+	"google.golang.org/adk/session"
+)
+
+const (
+	// maxBatchWriteItems is the most requests of a BatchWriteItem call.
+	maxBatchWriteItems = 25
+
+	// maxBatchWriteAttempts bounds the retries of the unprocessed items of a batch.
+	maxBatchWriteAttempts = 5
+)
+
+// PersistSession saves or updates a session, its state and tags, as a
+// ksess.Persister. The events of the session are kept.
+func (s *SessionService) PersistSession(ctx context.Context, sess session.Session) error {
+	stateJSON, err := sonic.MarshalString(maps.Collect(sess.State().All()))
+	if err != nil {
+		return fmt.Errorf("failed to marshal session state: %w", err)
+	}
+	tags := ksess.TagsOf(sess)
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	tagsJSON, err := sonic.MarshalString(tags)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session tags: %w", err)
+	}
+
+	now := time.Now()
+	update := "SET #app = :app, #user = :user, #sid = :sid, #state = :state, #tags = :tags, #last = :last, " +
+		"#count = if_not_exists(#count, :zero), #created = if_not_exists(#created, :now)"
+	names := map[string]string{
+		"#app": attrAppName, "#user": attrUserID, "#sid": attrSessionID, "#state": attrState,
+		"#tags": attrTags, "#last": attrLastUpdate, "#count": attrEventCount, "#created": attrCreatedAt,
+	}
+	values := map[string]types.AttributeValue{
+		":app":   stringValue(sess.AppName()),
+		":user":  stringValue(sess.UserID()),
+		":sid":   stringValue(sess.ID()),
+		":state": stringValue(stateJSON),
+		":tags":  stringValue(tagsJSON),
+		":last":  numberValue(sess.LastUpdateTime().UnixNano()),
+		":zero":  numberValue(0),
+		":now":   numberValue(now.UnixNano()),
+	}
+	if s.ttl > 0 {
+		update += ", #expires = :expires"
+		names["#expires"] = attrExpiresAt
+		values[":expires"] = numberValue(s.expiresAt(now))
+	}
+
+	if _, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(s.table),
+		Key:                       itemKey(partitionKey(sess.AppName(), sess.UserID()), sessionSortKey(sess.ID())),
+		UpdateExpression:          aws.String(update),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}); err != nil {
+		s.logger.Errorf("failed to persist session %s: %v", sess.ID(), err)
+		return fmt.Errorf("failed to persist session: %w", err)
+	}
+
+	s.logger.Debugf("session persisted: session=%s", sess.ID())
+	return nil
+}
+
+// PersistEvent saves an event of a stored session, as a ksess.Persister. It
+// fails with ErrSessionNotFound if the session is not stored.
+func (s *SessionService) PersistEvent(ctx context.Context, sess session.Session, evt *session.Event) error {
+	if evt == nil {
+		return ErrNilEvent
+	}
+	return s.insertEvent(ctx, sess, evt, false)
+}
+
+// DeleteSession removes a session and its events.
+func (s *SessionService) DeleteSession(ctx context.Context, appName, userID, sessionID string) error {
+	pk := partitionKey(appName, userID)
+
+	// NOTE: The session goes first, so a failed delete leaves no partial session,
+	// only events that deleting it again removes
+	if _, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.table),
+		Key:       itemKey(pk, sessionSortKey(sessionID)),
+	}); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+
+	paginator := dynamodb.NewQueryPaginator(s.client, &dynamodb.QueryInput{
+		TableName:              aws.String(s.table),
+		KeyConditionExpression: aws.String("pk = :pk AND begins_with(sk, :prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":     stringValue(pk),
+			":prefix": stringValue(eventKeysPrefix(sessionID)),
+		},
+		ProjectionExpression: aws.String("pk, sk"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list events: %w", err)
+		}
+		if err := s.batchDelete(ctx, page.Items); err != nil {
+			return fmt.Errorf("failed to delete events: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// LoadSession reads a session and all its events, as a ksess.Loader. It returns
+// nil, nil if the session is not stored.
+func (s *SessionService) LoadSession(
+	ctx context.Context,
+	appName, userID, sessionID string,
+) (*ksess.StoredSession, error) {
+	return s.loadSession(ctx, appName, userID, sessionID, 0, time.Time{})
+}
+
+// ListSessionIDs returns the IDs of the stored sessions of a user, as a
+// ksess.Loader.
+func (s *SessionService) ListSessionIDs(ctx context.Context, appName, userID string) ([]string, error) {
+	items, err := s.sessionItems(ctx, appName, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list session IDs: %w", err)
+	}
+
+	now := time.Now()
+	var ids []string
+	for _, item := range items {
+		if !expired(item, now) {
+			ids = append(ids, stringAttr(item, attrSessionID))
+		}
+	}
+	return ids, nil
+}
+
+// insertEvent stores evt with the next order of its session and moves the last
+// update time of the session forward. With withState, the state of sess is
+// written in the same transaction.
+func (s *SessionService) insertEvent(
+	ctx context.Context,
+	sess session.Session,
+	evt *session.Event,
+	withState bool,
+) error {
+	evtJSON, err := sonic.MarshalString(evt)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event %s: %w", evt.ID, err)
+	}
+	var stateJSON string
+	if withState {
+		stateJSON, err = sonic.MarshalString(maps.Collect(sess.State().All()))
+		if err != nil {
+			return fmt.Errorf("failed to marshal session state: %w", err)
+		}
+	}
+
+	pk := partitionKey(sess.AppName(), sess.UserID())
+	sessionKey := itemKey(pk, sessionSortKey(sess.ID()))
+
+	for range maxAppendAttempts {
+		out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName:            aws.String(s.table),
+			Key:                  sessionKey,
+			ConsistentRead:       aws.Bool(true),
+			ProjectionExpression: aws.String("#count, #last, #expires"),
+			ExpressionAttributeNames: map[string]string{
+				"#count": attrEventCount, "#last": attrLastUpdate, "#expires": attrExpiresAt,
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read session: %w", err)
+		}
+		now := time.Now()
+		if len(out.Item) == 0 || expired(out.Item, now) {
+			return fmt.Errorf("%w: %s", ErrSessionNotFound, sess.ID())
+		}
+
+		// NOTE: An older event, e.g. imported, does not move last_update_time back
+		order := numberAttr(out.Item, attrEventCount)
+		lastUpdate := max(numberAttr(out.Item, attrLastUpdate), evt.Timestamp.UnixNano())
+
+		update := "SET #count = :next, #last = :last"
+		names := map[string]string{"#count": attrEventCount, "#last": attrLastUpdate}
+		values := map[string]types.AttributeValue{
+			":order": numberValue(order),
+			":next":  numberValue(order + 1),
+			":last":  numberValue(lastUpdate),
+		}
+		if withState {
+			update += ", #state = :state"
+			names["#state"] = attrState
+			values[":state"] = stringValue(stateJSON)
+		}
+
+		eventItem := map[string]types.AttributeValue{
+			attrPK:        stringValue(pk),
+			attrSK:        stringValue(eventSortKey(sess.ID(), order)),
+			attrSessionID: stringValue(sess.ID()),
+			attrEventID:   stringValue(evt.ID),
+			attrEventTime: numberValue(evt.Timestamp.UnixNano()),
+			attrContent:   stringValue(evtJSON),
+		}
+		if s.ttl > 0 {
+			update += ", #expires = :expires"
+			names["#expires"] = attrExpiresAt
+			values[":expires"] = numberValue(s.expiresAt(now))
+			eventItem[attrExpiresAt] = numberValue(s.expiresAt(now))
+		}
+
+		// NOTE: The order taken by a concurrent append fails the condition
+		_, err = s.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: []types.TransactWriteItem{
+				{Update: &types.Update{
+					TableName:                 aws.String(s.table),
+					Key:                       sessionKey,
+					UpdateExpression:          aws.String(update),
+					ConditionExpression:       aws.String("#count = :order"),
+					ExpressionAttributeNames:  names,
+					ExpressionAttributeValues: values,
+				}},
+				{Put: &types.Put{
+					TableName:           aws.String(s.table),
+					Item:                eventItem,
+					ConditionExpression: aws.String("attribute_not_exists(sk)"),
+				}},
+			},
+		})
+		if err == nil {
+			return nil
+		}
+		if !isConditionFailed(err) {
+			s.logger.Errorf("failed to insert event %s: %v", evt.ID, err)
+			return fmt.Errorf("failed to insert event: %w", err)
+		}
+
+		s.logger.Debugf("event order taken, retrying: session=%s, event=%s, order=%d", sess.ID(), evt.ID, order)
+	}
+
+	return fmt.Errorf("%w: %s", ErrAppendConflict, sess.ID())
+}
+
+// loadSession reads a session with its last numRecent events (0 reads all) not
+// older than after. It returns nil, nil if the session is not stored.
+func (s *SessionService) loadSession(
+	ctx context.Context,
+	appName, userID, sessionID string,
+	numRecent int,
+	after time.Time,
+) (*ksess.StoredSession, error) {
+	pk := partitionKey(appName, userID)
+
+	out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.table),
+		Key:            itemKey(pk, sessionSortKey(sessionID)),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	now := time.Now()
+	if len(out.Item) == 0 || expired(out.Item, now) {
+		return nil, nil
+	}
+
+	stored, err := s.decodeSession(out.Item)
+	if err != nil {
+		return nil, err
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(s.table),
+		KeyConditionExpression: aws.String("pk = :pk AND begins_with(sk, :prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":     stringValue(pk),
+			":prefix": stringValue(eventKeysPrefix(sessionID)),
+		},
+		ConsistentRead: aws.Bool(true),
+	}
+	if numRecent > 0 {
+		// NOTE: Read the most recent events first and stop once there are enough
+		input.ScanIndexForward = aws.Bool(false)
+		input.Limit = aws.Int32(int32(min(numRecent, 1000)))
+	}
+	if !after.IsZero() {
+		input.FilterExpression = aws.String("#time >= :after")
+		input.ExpressionAttributeNames = map[string]string{"#time": attrEventTime}
+		input.ExpressionAttributeValues[":after"] = numberValue(after.UnixNano())
+	}
+
+	paginator := dynamodb.NewQueryPaginator(s.client, input)
+	for paginator.HasMorePages() && (numRecent <= 0 || len(stored.Events) < numRecent) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load events: %w", err)
+		}
+
+		for _, item := range page.Items {
+			if expired(item, now) {
+				continue
+			}
+			var evt session.Event
+			if err := sonic.UnmarshalString(stringAttr(item, attrContent), &evt); err != nil {
+				s.logger.Warnf("failed to unmarshal event of session %s: %v", sessionID, err)
+				continue
+			}
+			stored.Events = append(stored.Events, &evt)
+		}
+	}
+
+	if numRecent > 0 {
+		if len(stored.Events) > numRecent {
+			stored.Events = stored.Events[:numRecent]
+		}
+		slices.Reverse(stored.Events)
+	}
+
+	return stored, nil
+}
+
+// sessionItems returns the session items of a user, or of every user of the app
+// if userID is empty.
+func (s *SessionService) sessionItems(
+	ctx context.Context,
+	appName, userID string,
+) ([]map[string]types.AttributeValue, error) {
+	var items []map[string]types.AttributeValue
+
+	if userID != "" {
+		paginator := dynamodb.NewQueryPaginator(s.client, &dynamodb.QueryInput{
+			TableName:              aws.String(s.table),
+			KeyConditionExpression: aws.String("pk = :pk AND begins_with(sk, :prefix)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pk":     stringValue(partitionKey(appName, userID)),
+				":prefix": stringValue(sessionKeyPrefix),
+			},
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, page.Items...)
+		}
+		return items, nil
+	}
+
+	// NOTE: The sessions of every user span partitions, which only a scan reads
+	paginator := dynamodb.NewScanPaginator(s.client, &dynamodb.ScanInput{
+		TableName:                aws.String(s.table),
+		FilterExpression:         aws.String("#app = :app AND begins_with(sk, :prefix)"),
+		ExpressionAttributeNames: map[string]string{"#app": attrAppName},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":app":    stringValue(appName),
+			":prefix": stringValue(sessionKeyPrefix),
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page.Items...)
+	}
+	return items, nil
+}
+
+// batchDelete deletes the items with the keys of items, retrying the items
+// DynamoDB leaves unprocessed.
+func (s *SessionService) batchDelete(ctx context.Context, items []map[string]types.AttributeValue) error {
+	for chunk := range slices.Chunk(items, maxBatchWriteItems) {
+		requests := make([]types.WriteRequest, len(chunk))
+		for i, item := range chunk {
+			requests[i] = types.WriteRequest{DeleteRequest: &types.DeleteRequest{
+				Key: itemKey(stringAttr(item, attrPK), stringAttr(item, attrSK)),
+			}}
+		}
+
+		pending := map[string][]types.WriteRequest{s.table: requests}
+		for attempt := 1; len(pending) > 0; attempt++ {
+			if attempt > maxBatchWriteAttempts {
+				return fmt.Errorf("%d items left unprocessed", len(pending[s.table]))
+			}
+
+			out, err := s.client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: pending})
+			if err != nil {
+				return err
+			}
+			pending = out.UnprocessedItems
+
+			if len(pending) > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(time.Duration(attempt) * 50 * time.Millisecond):
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,461 @@
+// Package dynamodb provides a session.Service and a Persister on a single
+// DynamoDB table, so serverless deployments on AWS get managed sessions without
+// running Redis or PostgreSQL. Sessions and events share the table:
+//
+//	pk = {app}#{user}   sk = session#{session}               the session and its state
+//	pk = {app}#{user}   sk = event#{session}#{order}         an event, in append order
+//
+// With WithTTL, the items carry an expires_at attribute in Unix seconds, the TTL
+// attribute of the table created by CreateTable.
+package dynamodb
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/bytedance/sonic"
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	ksess "github.com/kydenul/k-adk/session"
+	"github.com/kydenul/log"
+	"google.golang.org/adk/session"
+)
+
+var (
+	_ session.Service = (*SessionService)(nil)
+	_ ksess.Persister = (*SessionService)(nil)
+	_ ksess.Loader    = (*SessionService)(nil)
+	_ ksess.Store     = (*SessionService)(nil)
+
+	_ API = (*dynamodb.Client)(nil)
+)
+
+const (
+	// sessionIDByteLength defines the length of the generated session IDs in bytes.
+	sessionIDByteLength = 16
+
+	// maxAppendAttempts bounds the attempts of an event append losing the race for
+	// the next event order to concurrent appends.
+	maxAppendAttempts = 5
+)
+
+var (
+	// ErrSessionNotFound is returned for a session that is not stored, or expired.
+	ErrSessionNotFound = ksess.ErrSessionNotFound
+
+	// ErrSessionExists is returned by Create for a session ID in use.
+	ErrSessionExists = errors.New("session already exists")
+
+	// ErrAppendConflict is returned when concurrent appends to a session kept
+	// taking the order of an event.
+	ErrAppendConflict = errors.New("concurrent appends to the session conflict")
+
+	// ErrNilSession and ErrNilEvent are returned by AppendEvent.
+	ErrNilSession = errors.New("session cannot be nil")
+	ErrNilEvent   = errors.New("event cannot be nil")
+)
+
+// API is the subset of the DynamoDB client used by the SessionService, which
+// *dynamodb.Client implements.
+type API interface {
+	GetItem(context.Context, *dynamodb.GetItemInput, ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(context.Context, *dynamodb.PutItemInput, ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(context.Context, *dynamodb.UpdateItemInput, ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(context.Context, *dynamodb.DeleteItemInput, ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	Query(context.Context, *dynamodb.QueryInput, ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	Scan(context.Context, *dynamodb.ScanInput, ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	BatchWriteItem(
+		context.Context, *dynamodb.BatchWriteItemInput, ...func(*dynamodb.Options),
+	) (*dynamodb.BatchWriteItemOutput, error)
+	TransactWriteItems(
+		context.Context, *dynamodb.TransactWriteItemsInput, ...func(*dynamodb.Options),
+	) (*dynamodb.TransactWriteItemsOutput, error)
+}
+
+// SessionService implements session.Service on a DynamoDB table. It also
+// implements ksess.Persister and ksess.Loader on the same items, so it can back
+// a Redis or in-memory service as its long-term store, and read back what they
+// persisted.
+//
+// Every event is an item of its own, ordered by a counter of its session item
+// which appends take in a transaction, so concurrent appends to a session keep
+// their events. The state written by AppendEvent is the state of the session
+// passed to it: concurrent writers of a session should each get it again first,
+// the last write wins. A session item, state included, and an event must each fit
+// in the 400 KB item size limit of DynamoDB.
+type SessionService struct {
+	client API
+	table  string
+	logger log.Logger
+
+	// Optional. ttl is the retention of the items after their last write.
+	ttl time.Duration
+	// Optional. idPolicy validates the client-supplied session IDs.
+	idPolicy *ksess.IDPolicy
+	// Optional. stampEvents replaces the caller-supplied event timestamps.
+	stampEvents bool
+}
+
+// ServiceOption configures the SessionService.
+type ServiceOption func(*SessionService)
+
+// WithLogger sets the optional logger for the SessionService.
+func WithLogger(logger log.Logger) ServiceOption {
+	return func(s *SessionService) { s.logger = logger }
+}
+
+// WithTTL expires the sessions ttl after their last write, through the native
+// TTL of DynamoDB. An event expires ttl after it is written, so a session written
+// for longer than ttl loses its oldest events first. Expired items are hidden
+// from reads until DynamoDB deletes them. Default is no expiry.
+func WithTTL(ttl time.Duration) ServiceOption {
+	return func(s *SessionService) { s.ttl = ttl }
+}
+
+// WithIDPolicy validates the session IDs supplied to Create against p, or replaces
+// them with generated IDs if p.AlwaysGenerate is set. Invalid IDs fail with an
+// error matching ksess.ErrInvalidSessionID.
+func WithIDPolicy(p ksess.IDPolicy) ServiceOption {
+	return func(s *SessionService) { s.idPolicy = &p }
+}
+
+// WithAppendTimestamps stamps every appended event with the time of the append,
+// replacing the timestamp set by the caller. By default only the events without a
+// timestamp are stamped, so replayed or imported events keep their own.
+func WithAppendTimestamps() ServiceOption {
+	return func(s *SessionService) { s.stampEvents = true }
+}
+
+// NewSessionService creates a SessionService on table, a table with the string
+// keys pk and sk, e.g. created by CreateTable.
+func NewSessionService(client API, table string, opts ...ServiceOption) (*SessionService, error) {
+	if client == nil {
+		return nil, errors.New("dynamodb client cannot be nil")
+	}
+	if table == "" {
+		return nil, errors.New("dynamodb table name cannot be empty")
+	}
+
+	svc := &SessionService{client: client, table: table}
+	for _, opt := range opts {
+		opt(svc)
+	}
+
+	if svc.logger == nil {
+		svc.logger = discardlog.NewDiscardLog()
+	}
+
+	svc.logger.Infof("DynamoDB session service initialized: table=%s, ttl=%s", table, svc.ttl)
+
+	return svc, nil
+}
+
+// Close implements ksess.Persister. The client is not closed.
+func (s *SessionService) Close() error { return nil }
+
+// CreateTable creates table with the keys of the SessionService, billed on
+// demand, waits for it to be active and enables its TTL on expires_at. A table
+// already existing is left as is.
+func CreateTable(ctx context.Context, client *dynamodb.Client, table string) error {
+	_, err := client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName: aws.String(table),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String(attrPK), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String(attrSK), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String(attrPK), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String(attrSK), KeyType: types.KeyTypeRange},
+		},
+		BillingMode: types.BillingModePayPerRequest,
+	})
+	var inUse *types.ResourceInUseException
+	if errors.As(err, &inUse) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create table %s: %w", table, err)
+	}
+
+	if err := dynamodb.NewTableExistsWaiter(client).Wait(ctx,
+		&dynamodb.DescribeTableInput{TableName: aws.String(table)}, 5*time.Minute); err != nil {
+		return fmt.Errorf("failed to wait for table %s: %w", table, err)
+	}
+
+	if _, err := client.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(table),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String(attrExpiresAt),
+			Enabled:       aws.Bool(true),
+		},
+	}); err != nil {
+		return fmt.Errorf("failed to enable TTL of table %s: %w", table, err)
+	}
+
+	return nil
+}
+
+// generateSessionID generates a unique session ID using crypto/rand.
+func generateSessionID() string {
+	b := make([]byte, sessionIDByteLength)
+
+	if _, err := rand.Read(b); err != nil {
+		// Fallback to timestamp if crypto/rand fails
+		return strconv.FormatInt(time.Now().UnixNano(), 10)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// resolveSessionID returns the ID of a new session: the requested ID, validated by
+// the ID policy if one is set, or a generated ID.
+func (s *SessionService) resolveSessionID(requested string) (string, error) {
+	if s.idPolicy != nil {
+		return s.idPolicy.Resolve(requested, generateSessionID)
+	}
+	if requested == "" {
+		return generateSessionID(), nil
+	}
+	return requested, nil
+}
+
+// Create creates a new session. Keys prefixed with session.KeyPrefixTemp are not
+// stored.
+func (s *SessionService) Create(
+	ctx context.Context,
+	req *session.CreateRequest,
+) (*session.CreateResponse, error) {
+	sessionID, err := s.resolveSessionID(req.SessionID)
+	if err != nil {
+		s.logger.Warnf("rejected session ID: app=%s, user=%s, err=%v", req.AppName, req.UserID, err)
+		return nil, err
+	}
+
+	state := make(map[string]any, len(req.State))
+	for key, value := range req.State {
+		if !strings.HasPrefix(key, session.KeyPrefixTemp) {
+			state[key] = value
+		}
+	}
+
+	now := time.Now()
+	stored := &ksess.StoredSession{
+		ID:             sessionID,
+		AppName:        req.AppName,
+		UserID:         req.UserID,
+		State:          state,
+		LastUpdateTime: now,
+	}
+	item, err := s.sessionItem(stored, now)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(s.table),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(" + attrPK + ")"),
+	})
+	if isConditionFailed(err) {
+		return nil, fmt.Errorf("%w: %s", ErrSessionExists, sessionID)
+	}
+	if err != nil {
+		s.logger.Errorf("failed to create session %s: %v", sessionID, err)
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	s.logger.Infof("session created: app=%s, user=%s, session=%s", req.AppName, req.UserID, sessionID)
+
+	return &session.CreateResponse{Session: newDynamoSession(stored)}, nil
+}
+
+// Get retrieves a session by ID, with its last NumRecentEvents events (0 gets
+// all) not older than After.
+func (s *SessionService) Get(
+	ctx context.Context,
+	req *session.GetRequest,
+) (*session.GetResponse, error) {
+	stored, err := s.loadSession(ctx, req.AppName, req.UserID, req.SessionID, req.NumRecentEvents, req.After)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, req.SessionID)
+	}
+
+	s.logger.Debugf("session retrieved: session=%s, events=%d", req.SessionID, len(stored.Events))
+
+	return &session.GetResponse{Session: newDynamoSession(stored)}, nil
+}
+
+// List returns the sessions of a user, most recently updated first and without
+// their events. With an empty UserID it lists every user of the app, which scans
+// the whole table.
+func (s *SessionService) List(
+	ctx context.Context,
+	req *session.ListRequest,
+) (*session.ListResponse, error) {
+	items, err := s.sessionItems(ctx, req.AppName, req.UserID)
+	if err != nil {
+		s.logger.Errorf("failed to list sessions of app %s: %v", req.AppName, err)
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	now := time.Now()
+	stored := make([]*ksess.StoredSession, 0, len(items))
+	for _, item := range items {
+		if expired(item, now) {
+			continue
+		}
+		sess, err := s.decodeSession(item)
+		if err != nil {
+			return nil, err
+		}
+		stored = append(stored, sess)
+	}
+	slices.SortFunc(stored, func(a, b *ksess.StoredSession) int {
+		return b.LastUpdateTime.Compare(a.LastUpdateTime)
+	})
+
+	sessions := make([]session.Session, len(stored))
+	for i, sess := range stored {
+		sessions[i] = newDynamoSession(sess)
+	}
+
+	s.logger.Debugf("listed %d sessions for user %s", len(sessions), req.UserID)
+
+	return &session.ListResponse{Sessions: sessions}, nil
+}
+
+// Delete removes a session and its events.
+func (s *SessionService) Delete(ctx context.Context, req *session.DeleteRequest) error {
+	if err := s.DeleteSession(ctx, req.AppName, req.UserID, req.SessionID); err != nil {
+		s.logger.Errorf("failed to delete session %s: %v", req.SessionID, err)
+		return err
+	}
+
+	s.logger.Infof("session deleted: app=%s, user=%s, session=%s",
+		req.AppName, req.UserID, req.SessionID)
+
+	return nil
+}
+
+// AppendEvent appends an event to a session, and applies its state delta; keys
+// prefixed with session.KeyPrefixTemp are not stored. Partial events are ignored.
+// Events without a timestamp are stamped with the current time (see
+// WithAppendTimestamps); the last update time of the session does not go back to
+// the timestamp of an older event.
+func (s *SessionService) AppendEvent(
+	ctx context.Context,
+	sess session.Session,
+	evt *session.Event,
+) error {
+	if sess == nil {
+		return ErrNilSession
+	}
+	if evt == nil {
+		return ErrNilEvent
+	}
+	if evt.Partial {
+		return nil
+	}
+
+	// NOTE: Keep the timestamp of events replayed or imported by the caller
+	if evt.Timestamp.IsZero() || s.stampEvents {
+		evt.Timestamp = time.Now()
+	}
+	if evt.ID == "" {
+		evt.ID = generateSessionID()
+	}
+
+	// NOTE: Apply the state delta to the caller's session, then store its state
+	for key, value := range evt.Actions.StateDelta {
+		if strings.HasPrefix(key, session.KeyPrefixTemp) {
+			continue
+		}
+		if err := sess.State().Set(key, value); err != nil {
+			return fmt.Errorf("failed to apply state delta: %w", err)
+		}
+	}
+
+	if err := s.insertEvent(ctx, sess, evt, true); err != nil {
+		return err
+	}
+
+	if ds, ok := sess.(*dynamoSession); ok {
+		ds.events.append(evt)
+		if evt.Timestamp.After(ds.lastUpdateTime) {
+			ds.lastUpdateTime = evt.Timestamp
+		}
+	}
+
+	s.logger.Debugf("event appended: session=%s, event=%s", sess.ID(), evt.ID)
+
+	return nil
+}
+
+// sessionItem returns the session item of stored, written at now.
+func (s *SessionService) sessionItem(
+	stored *ksess.StoredSession,
+	now time.Time,
+) (map[string]types.AttributeValue, error) {
+	stateJSON, err := sonic.MarshalString(stored.State)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal session state: %w", err)
+	}
+	tags := stored.Tags
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	tagsJSON, err := sonic.MarshalString(tags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal session tags: %w", err)
+	}
+
+	item := map[string]types.AttributeValue{
+		attrPK:         stringValue(partitionKey(stored.AppName, stored.UserID)),
+		attrSK:         stringValue(sessionSortKey(stored.ID)),
+		attrAppName:    stringValue(stored.AppName),
+		attrUserID:     stringValue(stored.UserID),
+		attrSessionID:  stringValue(stored.ID),
+		attrState:      stringValue(stateJSON),
+		attrTags:       stringValue(tagsJSON),
+		attrLastUpdate: numberValue(stored.LastUpdateTime.UnixNano()),
+		attrCreatedAt:  numberValue(now.UnixNano()),
+		attrEventCount: numberValue(0),
+	}
+	if s.ttl > 0 {
+		item[attrExpiresAt] = numberValue(s.expiresAt(now))
+	}
+	return item, nil
+}
+
+// expiresAt returns the expiry, in Unix seconds, of an item written at now.
+func (s *SessionService) expiresAt(now time.Time) int64 {
+	return now.Add(s.ttl).Unix()
+}
+
+// isConditionFailed reports whether err is the failure of a condition
+// expression, of a single write or of a transaction.
+func isConditionFailed(err error) bool {
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return true
+	}
+	var tce *types.TransactionCanceledException
+	if errors.As(err, &tce) {
+		return slices.ContainsFunc(tce.CancellationReasons, func(r types.CancellationReason) bool {
+			return aws.ToString(r.Code) == "ConditionalCheckFailed"
+		})
+	}
+	return false
+}
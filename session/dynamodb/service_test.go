@@ -0,0 +1,260 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	ksess "github.com/kydenul/k-adk/session"
+	"google.golang.org/adk/session"
+)
+
+// setupTestService creates a service on a new table of the DynamoDB endpoint of
+// TEST_DYNAMODB_ENDPOINT, e.g. DynamoDB Local on http://localhost:8000.
+func setupTestService(t *testing.T, opts ...ServiceOption) *SessionService {
+	t.Helper()
+
+	endpoint := os.Getenv("TEST_DYNAMODB_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("TEST_DYNAMODB_ENDPOINT not set, skipping test")
+	}
+
+	client := dynamodb.New(dynamodb.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(endpoint),
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "test", SecretAccessKey: "test"}, nil
+		}),
+	})
+
+	ctx := context.Background()
+	table := fmt.Sprintf("sessions_test_%d", time.Now().UnixNano())
+	if err := CreateTable(ctx, client, table); err != nil {
+		t.Skipf("DynamoDB not available, skipping test: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = client.DeleteTable(context.Background(), &dynamodb.DeleteTableInput{TableName: aws.String(table)})
+	})
+
+	svc, err := NewSessionService(client, table, opts...)
+	if err != nil {
+		t.Fatalf("NewSessionService failed: %v", err)
+	}
+	return svc
+}
+
+func TestNewSessionServiceValidation(t *testing.T) {
+	if _, err := NewSessionService(nil, "sessions"); err == nil {
+		t.Error("expected an error for a nil client")
+	}
+	if _, err := NewSessionService(&dynamodb.Client{}, ""); err == nil {
+		t.Error("expected an error for an empty table name")
+	}
+}
+
+func TestKeys(t *testing.T) {
+	// NOTE: A '#' in an ID must not let the keys of two users or sessions collide
+	if partitionKey("a#b", "c") == partitionKey("a", "b#c") {
+		t.Error("partition keys collide")
+	}
+	if strings.HasPrefix(eventSortKey("a#b", 0), eventKeysPrefix("a")) {
+		t.Error("events of session a#b match the prefix of session a")
+	}
+
+	keys := []string{eventSortKey("s", 10), eventSortKey("s", 2), eventSortKey("s", 1)}
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{eventSortKey("s", 1), eventSortKey("s", 2), eventSortKey("s", 10)}) {
+		t.Errorf("event keys not sorted in order: %v", keys)
+	}
+}
+
+func TestIsConditionFailed(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"other", errors.New("throttled"), false},
+		{"conditional check", fmt.Errorf("put: %w", &types.ConditionalCheckFailedException{}), true},
+		{"transaction condition", &types.TransactionCanceledException{CancellationReasons: []types.CancellationReason{
+			{Code: aws.String("None")}, {Code: aws.String("ConditionalCheckFailed")},
+		}}, true},
+		{"transaction conflict", &types.TransactionCanceledException{CancellationReasons: []types.CancellationReason{
+			{Code: aws.String("TransactionConflict")},
+		}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isConditionFailed(tt.err); got != tt.want {
+				t.Errorf("isConditionFailed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionItemRoundTrip(t *testing.T) {
+	svc := &SessionService{ttl: time.Hour}
+	now := time.Now()
+	want := &ksess.StoredSession{
+		ID: "sess#1", AppName: "app", UserID: "user",
+		State:          map[string]any{"topic": "go"},
+		Tags:           map[string]string{"channel": "web"},
+		LastUpdateTime: now,
+	}
+
+	item, err := svc.sessionItem(want, now)
+	if err != nil {
+		t.Fatalf("sessionItem failed: %v", err)
+	}
+	if expired(item, now) || !expired(item, now.Add(2*time.Hour)) {
+		t.Error("unexpected expiry of the session item")
+	}
+
+	got, err := svc.decodeSession(item)
+	if err != nil {
+		t.Fatalf("decodeSession failed: %v", err)
+	}
+	if got.ID != want.ID || got.UserID != want.UserID || got.State["topic"] != "go" ||
+		got.Tags["channel"] != "web" || !got.LastUpdateTime.Equal(want.LastUpdateTime) {
+		t.Errorf("unexpected decoded session: %+v", got)
+	}
+}
+
+func TestSessionService(t *testing.T) {
+	svc := setupTestService(t)
+	ctx := context.Background()
+
+	resp, err := svc.Create(ctx, &session.CreateRequest{
+		AppName: "app", UserID: "user", SessionID: "sess-1",
+		State: map[string]any{"topic": "go", session.KeyPrefixTemp + "scratch": 1},
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := svc.Create(ctx, &session.CreateRequest{AppName: "app", UserID: "user", SessionID: "sess-1"}); !errors.Is(err, ErrSessionExists) {
+		t.Errorf("expected ErrSessionExists, got %v", err)
+	}
+
+	for i, id := range []string{"e1", "e2", "e3"} {
+		evt := &session.Event{ID: id, Author: "user", Timestamp: time.Now().Add(time.Duration(i) * time.Second)}
+		evt.Actions.StateDelta = map[string]any{"step": i}
+		if err := svc.AppendEvent(ctx, resp.Session, evt); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+	}
+
+	got, err := svc.Get(ctx, &session.GetRequest{AppName: "app", UserID: "user", SessionID: "sess-1"})
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Session.Events().Len() != 3 {
+		t.Errorf("expected 3 events, got %d", got.Session.Events().Len())
+	}
+	if step, _ := got.Session.State().Get("step"); step != float64(2) {
+		t.Errorf("step = %v, want 2", step)
+	}
+	if _, err := got.Session.State().Get(session.KeyPrefixTemp + "scratch"); err == nil {
+		t.Error("temp key stored")
+	}
+
+	recent, err := svc.Get(ctx, &session.GetRequest{AppName: "app", UserID: "user", SessionID: "sess-1", NumRecentEvents: 2})
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if n := recent.Session.Events().Len(); n != 2 || recent.Session.Events().At(1).ID != "e3" {
+		t.Errorf("expected the last 2 events, got %d", n)
+	}
+
+	list, err := svc.List(ctx, &session.ListRequest{AppName: "app"})
+	if err != nil || len(list.Sessions) != 1 {
+		t.Fatalf("List = %v, %v", list, err)
+	}
+
+	if err := svc.Delete(ctx, &session.DeleteRequest{AppName: "app", UserID: "user", SessionID: "sess-1"}); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := svc.Get(ctx, &session.GetRequest{AppName: "app", UserID: "user", SessionID: "sess-1"}); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestConcurrentAppends(t *testing.T) {
+	svc := setupTestService(t)
+	ctx := context.Background()
+
+	resp, err := svc.Create(ctx, &session.CreateRequest{AppName: "app", UserID: "user", SessionID: "busy"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := range 3 {
+		wg.Go(func() {
+			evt := &session.Event{ID: fmt.Sprintf("e%d", i), Author: "user"}
+			if err := svc.AppendEvent(ctx, resp.Session, evt); err != nil {
+				t.Errorf("AppendEvent failed: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+
+	stored, err := svc.LoadSession(ctx, "app", "user", "busy")
+	if err != nil || stored == nil || len(stored.Events) != 3 {
+		t.Fatalf("expected 3 events, got %+v, %v", stored, err)
+	}
+}
+
+func TestPersister(t *testing.T) {
+	svc := setupTestService(t, WithTTL(time.Hour))
+	ctx := context.Background()
+
+	resp, err := session.InMemoryService().Create(ctx, &session.CreateRequest{
+		AppName: "app", UserID: "user", SessionID: "sess-persisted", State: map[string]any{"topic": "go"},
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	sess := resp.Session
+
+	if err := svc.PersistEvent(ctx, sess, &session.Event{ID: "e0"}); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound for an event of a missing session, got %v", err)
+	}
+	if err := svc.PersistSession(ctx, sess); err != nil {
+		t.Fatalf("PersistSession failed: %v", err)
+	}
+	for _, id := range []string{"e1", "e2"} {
+		if err := svc.PersistEvent(ctx, sess, &session.Event{ID: id, Author: "user", Timestamp: time.Now()}); err != nil {
+			t.Fatalf("PersistEvent failed: %v", err)
+		}
+	}
+
+	stored, err := svc.LoadSession(ctx, "app", "user", "sess-persisted")
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+	if stored == nil || len(stored.Events) != 2 || stored.State["topic"] != "go" {
+		t.Fatalf("unexpected stored session: %+v", stored)
+	}
+
+	ids, err := svc.ListSessionIDs(ctx, "app", "user")
+	if err != nil || !slices.Equal(ids, []string{"sess-persisted"}) {
+		t.Errorf("ListSessionIDs = %v, %v", ids, err)
+	}
+
+	if err := svc.DeleteSession(ctx, "app", "user", "sess-persisted"); err != nil {
+		t.Fatalf("DeleteSession failed: %v", err)
+	}
+	if stored, err := svc.LoadSession(ctx, "app", "user", "sess-persisted"); err != nil || stored != nil {
+		t.Errorf("expected the session deleted, got %+v, %v", stored, err)
+	}
+}
@@ -0,0 +1,135 @@
+package dynamodb
+
+import (
+	"iter"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	ksess "github.com/kydenul/k-adk/session"
+	"google.golang.org/adk/session"
+)
+
+var (
+	_ session.Session = (*dynamoSession)(nil)
+	_ ksess.Tagged    = (*dynamoSession)(nil)
+)
+
+// dynamoSession implements the session.Session interface over a copy of a stored
+// session: changes reach DynamoDB through SessionService.AppendEvent only.
+type dynamoSession struct {
+	id             string
+	appName        string
+	userID         string
+	state          *dynamoState
+	events         *dynamoEvents
+	tags           map[string]string
+	lastUpdateTime time.Time
+}
+
+// newDynamoSession returns the session of a stored one.
+func newDynamoSession(stored *ksess.StoredSession) *dynamoSession {
+	state := maps.Clone(stored.State)
+	if state == nil {
+		state = make(map[string]any)
+	}
+
+	return &dynamoSession{
+		id:             stored.ID,
+		appName:        stored.AppName,
+		userID:         stored.UserID,
+		state:          &dynamoState{values: state},
+		events:         &dynamoEvents{events: slices.Clone(stored.Events)},
+		tags:           stored.Tags,
+		lastUpdateTime: stored.LastUpdateTime,
+	}
+}
+
+func (s *dynamoSession) ID() string                { return s.id }
+func (s *dynamoSession) AppName() string           { return s.appName }
+func (s *dynamoSession) UserID() string            { return s.userID }
+func (s *dynamoSession) State() session.State      { return s.state }
+func (s *dynamoSession) Events() session.Events    { return s.events }
+func (s *dynamoSession) LastUpdateTime() time.Time { return s.lastUpdateTime }
+
+// Tags implements ksess.Tagged.
+func (s *dynamoSession) Tags() map[string]string { return maps.Clone(s.tags) }
+
+var _ session.State = (*dynamoState)(nil)
+
+// dynamoState implements session.State with a map. It is thread-safe.
+type dynamoState struct {
+	mu     sync.RWMutex
+	values map[string]any
+}
+
+func (s *dynamoState) Get(key string) (any, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, ok := s.values[key]
+	if !ok {
+		return nil, session.ErrStateKeyNotExist
+	}
+	return value, nil
+}
+
+func (s *dynamoState) Set(key string, value any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.values == nil {
+		s.values = make(map[string]any)
+	}
+	s.values[key] = value
+	return nil
+}
+
+func (s *dynamoState) All() iter.Seq2[string, any] {
+	s.mu.RLock()
+	values := maps.Clone(s.values)
+	s.mu.RUnlock()
+
+	return maps.All(values)
+}
+
+var _ session.Events = (*dynamoEvents)(nil)
+
+// dynamoEvents implements session.Events with a slice. It is thread-safe.
+type dynamoEvents struct {
+	mu     sync.RWMutex
+	events []*session.Event
+}
+
+func (e *dynamoEvents) All() iter.Seq[*session.Event] {
+	e.mu.RLock()
+	events := slices.Clone(e.events)
+	e.mu.RUnlock()
+
+	return slices.Values(events)
+}
+
+func (e *dynamoEvents) Len() int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return len(e.events)
+}
+
+func (e *dynamoEvents) At(i int) *session.Event {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if i < 0 || i >= len(e.events) {
+		return nil
+	}
+	return e.events[i]
+}
+
+func (e *dynamoEvents) append(evt *session.Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.events = append(e.events, evt)
+}
@@ -0,0 +1,332 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/log"
+	"google.golang.org/adk/session"
+)
+
+var (
+	_ session.Service = (*FaultyService)(nil)
+	_ Persister       = (*FaultyPersister)(nil)
+)
+
+// ErrInjectedFault is the default error of injected faults.
+var ErrInjectedFault = errors.New("injected fault")
+
+// Operations of the fault injection wrappers, for FaultConfig.Operations.
+const (
+	OpCreate         = "Create"
+	OpGet            = "Get"
+	OpList           = "List"
+	OpDelete         = "Delete"
+	OpAppendEvent    = "AppendEvent"
+	OpPersistSession = "PersistSession"
+	OpPersistEvent   = "PersistEvent"
+	OpDeleteSession  = "DeleteSession"
+)
+
+// FaultConfig configures the faults injected by WithFaults and WithPersisterFaults.
+type FaultConfig struct {
+	// Optional. Operations restricts the faults to these operations (Op*).
+	// Default: every operation.
+	Operations []string
+
+	// Optional. Latency is added to every faulted operation, plus a random
+	// duration up to Jitter.
+	Latency time.Duration
+	Jitter  time.Duration
+
+	// Optional. ErrorRate is the probability, in [0, 1], that an operation fails
+	// without being applied.
+	ErrorRate float64
+
+	// Optional. PartialRate is the probability, in [0, 1], that a write operation
+	// is applied but reports a failure, as when a connection drops after the
+	// write. Reads are never partially failed.
+	PartialRate float64
+
+	// Optional. Err is the error of failed operations. Default: ErrInjectedFault.
+	Err error
+
+	// Optional. Seed makes the injected faults reproducible. Default: random.
+	Seed uint64
+
+	// Optional. Logger for logging. Falls back to `DiscardLog` if nil.
+	Logger log.Logger
+}
+
+// FaultStats counts the faults injected by a wrapper.
+type FaultStats struct {
+	Calls    int64
+	Delayed  int64
+	Failed   int64
+	Partials int64
+}
+
+// faultInjector decides and applies the faults of a wrapper.
+type faultInjector struct {
+	log.Logger
+
+	cfg     FaultConfig
+	enabled atomic.Bool
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+
+	calls, delayed, failed, partials atomic.Int64
+}
+
+func newFaultInjector(cfg FaultConfig) (*faultInjector, error) {
+	if cfg.ErrorRate < 0 || cfg.ErrorRate > 1 || cfg.PartialRate < 0 || cfg.PartialRate > 1 {
+		return nil, errors.New("fault rates must be in [0, 1]")
+	}
+	if cfg.Err == nil {
+		cfg.Err = ErrInjectedFault
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = discardlog.NewDiscardLog()
+	}
+
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+
+	f := &faultInjector{
+		Logger: cfg.Logger,
+		cfg:    cfg,
+		rnd:    rand.New(rand.NewPCG(seed, seed)),
+	}
+	f.enabled.Store(true)
+
+	f.Infof("fault injection enabled: operations=%v, latency=%s, jitter=%s, error_rate=%.2f, partial_rate=%.2f",
+		cfg.Operations, cfg.Latency, cfg.Jitter, cfg.ErrorRate, cfg.PartialRate)
+
+	return f, nil
+}
+
+// fault is the outcome drawn for an operation.
+type fault int
+
+const (
+	faultNone fault = iota
+	faultError
+	faultPartial
+)
+
+// before delays the operation and draws its outcome. A non-nil error means the
+// context ended during the delay.
+func (f *faultInjector) before(ctx context.Context, op string, write bool) (fault, error) {
+	if !f.enabled.Load() || (len(f.cfg.Operations) > 0 && !slices.Contains(f.cfg.Operations, op)) {
+		return faultNone, nil
+	}
+	f.calls.Add(1)
+
+	f.mu.Lock()
+	delay := f.cfg.Latency
+	if f.cfg.Jitter > 0 {
+		delay += time.Duration(f.rnd.Int64N(int64(f.cfg.Jitter)))
+	}
+	draw := f.rnd.Float64()
+	f.mu.Unlock()
+
+	if delay > 0 {
+		f.delayed.Add(1)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return faultNone, ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	switch {
+	case draw < f.cfg.ErrorRate:
+		f.failed.Add(1)
+		f.Warnf("injected fault: op=%s", op)
+		return faultError, nil
+	case write && draw < f.cfg.ErrorRate+f.cfg.PartialRate:
+		f.partials.Add(1)
+		f.Warnf("injected partial fault: op=%s", op)
+		return faultPartial, nil
+	default:
+		return faultNone, nil
+	}
+}
+
+// run applies the faults of op around call.
+func (f *faultInjector) run(ctx context.Context, op string, write bool, call func() error) error {
+	outcome, err := f.before(ctx, op, write)
+	if err != nil {
+		return err
+	}
+	if outcome == faultError {
+		return fmt.Errorf("%s: %w", op, f.cfg.Err)
+	}
+
+	if err := call(); err != nil {
+		return err
+	}
+	if outcome == faultPartial {
+		return fmt.Errorf("%s (applied): %w", op, f.cfg.Err)
+	}
+	return nil
+}
+
+func (f *faultInjector) stats() FaultStats {
+	return FaultStats{
+		Calls:    f.calls.Load(),
+		Delayed:  f.delayed.Load(),
+		Failed:   f.failed.Load(),
+		Partials: f.partials.Load(),
+	}
+}
+
+// FaultyService is a session.Service injecting faults into the calls of another.
+type FaultyService struct {
+	next   session.Service
+	faults *faultInjector
+}
+
+// WithFaults wraps svc to inject configurable latency, errors and partial failures,
+// e.g. in integration tests or staging, to verify that the retry and recovery
+// paths protect conversation data. Faults can be switched off with SetEnabled.
+func WithFaults(svc session.Service, cfg FaultConfig) (*FaultyService, error) {
+	if svc == nil {
+		return nil, ErrNilService
+	}
+
+	faults, err := newFaultInjector(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FaultyService{next: svc, faults: faults}, nil
+}
+
+// SetEnabled switches the fault injection on or off.
+func (s *FaultyService) SetEnabled(enabled bool) { s.faults.enabled.Store(enabled) }
+
+// Stats returns the counts of the injected faults.
+func (s *FaultyService) Stats() FaultStats { return s.faults.stats() }
+
+// Create creates a session, with faults.
+func (s *FaultyService) Create(
+	ctx context.Context,
+	req *session.CreateRequest,
+) (resp *session.CreateResponse, err error) {
+	err = s.faults.run(ctx, OpCreate, true, func() error {
+		resp, err = s.next.Create(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Get gets a session, with faults.
+func (s *FaultyService) Get(
+	ctx context.Context,
+	req *session.GetRequest,
+) (resp *session.GetResponse, err error) {
+	err = s.faults.run(ctx, OpGet, false, func() error {
+		resp, err = s.next.Get(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// List lists sessions, with faults.
+func (s *FaultyService) List(
+	ctx context.Context,
+	req *session.ListRequest,
+) (resp *session.ListResponse, err error) {
+	err = s.faults.run(ctx, OpList, false, func() error {
+		resp, err = s.next.List(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Delete deletes a session, with faults.
+func (s *FaultyService) Delete(ctx context.Context, req *session.DeleteRequest) error {
+	return s.faults.run(ctx, OpDelete, true, func() error {
+		return s.next.Delete(ctx, req)
+	})
+}
+
+// AppendEvent appends an event to a session, with faults.
+func (s *FaultyService) AppendEvent(ctx context.Context, sess session.Session, evt *session.Event) error {
+	return s.faults.run(ctx, OpAppendEvent, true, func() error {
+		return s.next.AppendEvent(ctx, sess, evt)
+	})
+}
+
+// FaultyPersister is a Persister injecting faults into the calls of another.
+type FaultyPersister struct {
+	next   Persister
+	faults *faultInjector
+}
+
+// WithPersisterFaults wraps p to inject configurable latency, errors and partial
+// failures, like WithFaults. Close is never faulted.
+func WithPersisterFaults(p Persister, cfg FaultConfig) (*FaultyPersister, error) {
+	if p == nil {
+		return nil, errors.New("persister cannot be nil")
+	}
+
+	faults, err := newFaultInjector(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FaultyPersister{next: p, faults: faults}, nil
+}
+
+// SetEnabled switches the fault injection on or off.
+func (p *FaultyPersister) SetEnabled(enabled bool) { p.faults.enabled.Store(enabled) }
+
+// Stats returns the counts of the injected faults.
+func (p *FaultyPersister) Stats() FaultStats { return p.faults.stats() }
+
+// PersistSession saves a session, with faults.
+func (p *FaultyPersister) PersistSession(ctx context.Context, sess session.Session) error {
+	return p.faults.run(ctx, OpPersistSession, true, func() error {
+		return p.next.PersistSession(ctx, sess)
+	})
+}
+
+// PersistEvent saves an event, with faults.
+func (p *FaultyPersister) PersistEvent(ctx context.Context, sess session.Session, evt *session.Event) error {
+	return p.faults.run(ctx, OpPersistEvent, true, func() error {
+		return p.next.PersistEvent(ctx, sess, evt)
+	})
+}
+
+// DeleteSession deletes a session, with faults.
+func (p *FaultyPersister) DeleteSession(ctx context.Context, appName, userID, sessionID string) error {
+	return p.faults.run(ctx, OpDeleteSession, true, func() error {
+		return p.next.DeleteSession(ctx, appName, userID, sessionID)
+	})
+}
+
+// Close closes the wrapped persister.
+func (p *FaultyPersister) Close() error { return p.next.Close() }
@@ -0,0 +1,104 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/adk/session"
+)
+
+// countingPersister counts the applied persister calls.
+type countingPersister struct {
+	events int
+}
+
+func (p *countingPersister) PersistSession(context.Context, session.Session) error { return nil }
+func (p *countingPersister) DeleteSession(context.Context, string, string, string) error {
+	return nil
+}
+func (p *countingPersister) Close() error { return nil }
+
+func (p *countingPersister) PersistEvent(context.Context, session.Session, *session.Event) error {
+	p.events++
+	return nil
+}
+
+func TestWithFaults(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("errors are not applied", func(t *testing.T) {
+		inner := session.InMemoryService()
+		svc, err := WithFaults(inner, FaultConfig{Operations: []string{OpAppendEvent}, ErrorRate: 1})
+		if err != nil {
+			t.Fatalf("WithFaults failed: %v", err)
+		}
+
+		resp, err := svc.Create(ctx, &session.CreateRequest{AppName: "app", UserID: "u1"})
+		if err != nil {
+			t.Fatalf("expected Create to be left alone, got %v", err)
+		}
+
+		err = svc.AppendEvent(ctx, resp.Session, &session.Event{ID: "e1", Author: "user"})
+		if !errors.Is(err, ErrInjectedFault) {
+			t.Fatalf("expected ErrInjectedFault, got %v", err)
+		}
+
+		got, _ := inner.Get(ctx, &session.GetRequest{AppName: "app", UserID: "u1", SessionID: resp.Session.ID()})
+		if got.Session.Events().Len() != 0 {
+			t.Error("expected the failed event not to be applied")
+		}
+
+		if stats := svc.Stats(); stats.Calls != 1 || stats.Failed != 1 {
+			t.Errorf("unexpected stats: %+v", stats)
+		}
+
+		svc.SetEnabled(false)
+		if err := svc.AppendEvent(ctx, resp.Session, &session.Event{ID: "e2", Author: "user"}); err != nil {
+			t.Errorf("expected no fault once disabled, got %v", err)
+		}
+	})
+
+	t.Run("partial failures are applied", func(t *testing.T) {
+		inner := &countingPersister{}
+		custom := errors.New("connection reset")
+		p, err := WithPersisterFaults(inner, FaultConfig{PartialRate: 1, Err: custom})
+		if err != nil {
+			t.Fatalf("WithPersisterFaults failed: %v", err)
+		}
+
+		if err := p.PersistEvent(ctx, nil, &session.Event{}); !errors.Is(err, custom) {
+			t.Fatalf("expected the custom error, got %v", err)
+		}
+		if inner.events != 1 {
+			t.Error("expected the event to be applied")
+		}
+		if stats := p.Stats(); stats.Partials != 1 {
+			t.Errorf("unexpected stats: %+v", stats)
+		}
+	})
+
+	t.Run("latency honors the context", func(t *testing.T) {
+		svc, err := WithFaults(session.InMemoryService(), FaultConfig{Latency: time.Hour})
+		if err != nil {
+			t.Fatalf("WithFaults failed: %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+
+		if _, err := svc.List(ctx, &session.ListRequest{AppName: "app"}); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected the deadline, got %v", err)
+		}
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		if _, err := WithFaults(session.InMemoryService(), FaultConfig{ErrorRate: 1.5}); err == nil {
+			t.Error("expected an invalid rate to fail")
+		}
+		if _, err := WithFaults(nil, FaultConfig{}); !errors.Is(err, ErrNilService) {
+			t.Errorf("expected ErrNilService, got %v", err)
+		}
+	})
+}
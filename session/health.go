@@ -0,0 +1,206 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/log"
+)
+
+// ErrQueueSaturated is returned by the health checks of the persisters whose
+// async queues are nearly full.
+var ErrQueueSaturated = errors.New("async queue saturated")
+
+// QueueSaturation is the fill ratio of its async queues above which a persister
+// reports ErrQueueSaturated.
+const QueueSaturation = 0.9
+
+// Statuses of the health reports.
+const (
+	HealthStatusOK        = "ok"
+	HealthStatusUnhealthy = "unhealthy"
+)
+
+const defaultHealthTimeout = 2 * time.Second
+
+// HealthChecker is implemented by the persisters and session services reporting
+// their health, such as postgres.SessionPersister and redis.RedisSessionService.
+type HealthChecker interface {
+	// Ping checks that the backend is reachable, for liveness probes.
+	Ping(ctx context.Context) error
+
+	// Healthy checks that the backend is reachable and the component can take
+	// more work, e.g. its async queues are not saturated, for readiness probes.
+	Healthy(ctx context.Context) error
+}
+
+// CheckQueue returns ErrQueueSaturated if depth reaches QueueSaturation of
+// capacity. A zero capacity, in sync mode, is never saturated.
+func CheckQueue(depth, capacity int) error {
+	if capacity > 0 && float64(depth) >= QueueSaturation*float64(capacity) {
+		return fmt.Errorf("%w: %d/%d operations queued", ErrQueueSaturated, depth, capacity)
+	}
+	return nil
+}
+
+// HealthCheckFunc is a HealthChecker calling a function for both checks, e.g.
+// for a Redis client:
+//
+//	ksession.HealthCheckFunc(func(ctx context.Context) error { return rdb.Ping(ctx).Err() })
+type HealthCheckFunc func(ctx context.Context) error
+
+// Ping implements HealthChecker.
+func (f HealthCheckFunc) Ping(ctx context.Context) error { return f(ctx) }
+
+// Healthy implements HealthChecker.
+func (f HealthCheckFunc) Healthy(ctx context.Context) error { return f(ctx) }
+
+// CheckResult is the result of a check of a HealthReport.
+type CheckResult struct {
+	Status  string        `json:"status"`
+	Error   string        `json:"error,omitempty"`
+	Latency time.Duration `json:"latency_ns"`
+}
+
+// HealthReport is the result of the checks of a HealthReporter.
+type HealthReport struct {
+	// Status is HealthStatusOK if every check passed.
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Healthy reports whether every check passed.
+func (r *HealthReport) Healthy() bool { return r.Status == HealthStatusOK }
+
+// healthCheck is a named check of a HealthReporter.
+type healthCheck struct {
+	name    string
+	checker HealthChecker
+}
+
+// HealthReporter combines the health checks of the components of a deployment,
+// e.g. Redis, the PostgreSQL pool and the async queues of the persister, into a
+// report. It is an http.Handler serving the readiness report, for a /health
+// endpoint.
+type HealthReporter struct {
+	logger  log.Logger
+	checks  []healthCheck
+	timeout time.Duration
+}
+
+// HealthOption configures the HealthReporter.
+type HealthOption func(*HealthReporter)
+
+// WithHealthCheck adds the checks of c, reported as name. Checks are reported
+// in the order they are added.
+func WithHealthCheck(name string, c HealthChecker) HealthOption {
+	return func(r *HealthReporter) { r.checks = append(r.checks, healthCheck{name: name, checker: c}) }
+}
+
+// WithHealthTimeout bounds each check. Default: 2s.
+func WithHealthTimeout(d time.Duration) HealthOption {
+	return func(r *HealthReporter) { r.timeout = d }
+}
+
+// WithHealthLogger sets the optional logger for the HealthReporter.
+func WithHealthLogger(logger log.Logger) HealthOption {
+	return func(r *HealthReporter) { r.logger = logger }
+}
+
+// NewHealthReporter creates a HealthReporter from its checks.
+// Returns an error if a check is nil or its name is taken.
+func NewHealthReporter(opts ...HealthOption) (*HealthReporter, error) {
+	r := &HealthReporter{timeout: defaultHealthTimeout}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.logger == nil {
+		r.logger = discardlog.NewDiscardLog()
+	}
+	if r.timeout <= 0 {
+		r.timeout = defaultHealthTimeout
+	}
+
+	names := make(map[string]bool, len(r.checks))
+	for _, c := range r.checks {
+		if c.checker == nil {
+			return nil, fmt.Errorf("health check %q cannot be nil", c.name)
+		}
+		if names[c.name] {
+			return nil, fmt.Errorf("duplicate health check %q", c.name)
+		}
+		names[c.name] = true
+	}
+
+	return r, nil
+}
+
+// Live runs the Ping of every check, in parallel, for liveness probes.
+func (r *HealthReporter) Live(ctx context.Context) *HealthReport {
+	return r.run(ctx, HealthChecker.Ping)
+}
+
+// Ready runs the Healthy check of every component, in parallel, for readiness
+// probes.
+func (r *HealthReporter) Ready(ctx context.Context) *HealthReport {
+	return r.run(ctx, HealthChecker.Healthy)
+}
+
+// ServeHTTP serves the readiness report as JSON, with status 200 if every check
+// passed and 503 otherwise.
+func (r *HealthReporter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	report := r.Ready(req.Context())
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !report.Healthy() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_, _ = w.Write(data)
+}
+
+// run runs check on every component, each within the timeout.
+func (r *HealthReporter) run(ctx context.Context, check func(HealthChecker, context.Context) error) *HealthReport {
+	results := make([]CheckResult, len(r.checks))
+
+	var wg sync.WaitGroup
+	for i, c := range r.checks {
+		wg.Go(func() {
+			checkCtx, cancel := context.WithTimeout(ctx, r.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check(c.checker, checkCtx)
+			results[i] = CheckResult{Status: HealthStatusOK, Latency: time.Since(start)}
+			if err != nil {
+				results[i].Status = HealthStatusUnhealthy
+				results[i].Error = err.Error()
+				r.logger.Warnf("health check %s failed: %v", c.name, err)
+			}
+		})
+	}
+	wg.Wait()
+
+	report := &HealthReport{Status: HealthStatusOK, Checks: make(map[string]CheckResult, len(r.checks))}
+	for i, c := range r.checks {
+		report.Checks[c.name] = results[i]
+		if results[i].Status != HealthStatusOK {
+			report.Status = HealthStatusUnhealthy
+		}
+	}
+
+	return report
+}
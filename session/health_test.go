@@ -0,0 +1,114 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/adk/session"
+)
+
+// healthPersister is a Persister reporting err as its health.
+type healthPersister struct {
+	countingPersister
+	err error
+}
+
+func (p *healthPersister) Ping(context.Context) error    { return p.err }
+func (p *healthPersister) Healthy(context.Context) error { return p.err }
+
+func TestCheckQueue(t *testing.T) {
+	if err := CheckQueue(0, 0); err != nil {
+		t.Errorf("sync mode reported %v", err)
+	}
+	if err := CheckQueue(899, 1000); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := CheckQueue(900, 1000); !errors.Is(err, ErrQueueSaturated) {
+		t.Errorf("expected ErrQueueSaturated, got %v", err)
+	}
+}
+
+func TestHealthReporter(t *testing.T) {
+	if _, err := NewHealthReporter(WithHealthCheck("redis", nil)); err == nil {
+		t.Error("expected an error for a nil check")
+	}
+	ok := HealthCheckFunc(func(context.Context) error { return nil })
+	if _, err := NewHealthReporter(WithHealthCheck("redis", ok), WithHealthCheck("redis", ok)); err == nil {
+		t.Error("expected an error for a duplicate check")
+	}
+
+	down := &healthPersister{err: errors.New("connection refused")}
+	slow := HealthCheckFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	reporter, err := NewHealthReporter(
+		WithHealthCheck("redis", ok),
+		WithHealthCheck("postgres", down),
+		WithHealthCheck("slow", slow),
+		WithHealthTimeout(10*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("NewHealthReporter failed: %v", err)
+	}
+
+	report := reporter.Ready(context.Background())
+	if report.Healthy() || report.Checks["redis"].Status != HealthStatusOK {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if got := report.Checks["postgres"]; got.Status != HealthStatusUnhealthy || got.Error != "connection refused" {
+		t.Errorf("unexpected postgres check: %+v", got)
+	}
+	if got := report.Checks["slow"]; got.Status != HealthStatusUnhealthy {
+		t.Errorf("expected the slow check to time out, got %+v", got)
+	}
+
+	rec := httptest.NewRecorder()
+	reporter.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+	var served HealthReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &served); err != nil || len(served.Checks) != 3 {
+		t.Errorf("unexpected body %s: %v", rec.Body, err)
+	}
+
+	down.err = nil
+	reporter, _ = NewHealthReporter(WithHealthCheck("redis", ok), WithHealthCheck("postgres", down))
+	rec = httptest.NewRecorder()
+	reporter.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", rec.Code)
+	}
+}
+
+func TestMultiPersisterHealth(t *testing.T) {
+	ctx := context.Background()
+	up, down := &healthPersister{}, &healthPersister{err: errors.New("down")}
+
+	m, _ := NewMultiPersister(WithTarget("up", up), WithTarget("down", down), WithTarget("plain", &countingPersister{}))
+	if err := m.Healthy(ctx); err != nil {
+		t.Errorf("best effort with a healthy target reported %v", err)
+	}
+
+	m, _ = NewMultiPersister(WithTarget("up", up), WithTarget("down", down), WithFanOutMode(FanOutAllOrNothing))
+	var targetErr *TargetError
+	if err := m.Ping(ctx); !errors.As(err, &targetErr) || targetErr.Target != "down" {
+		t.Errorf("expected the error of target down, got %v", err)
+	}
+}
+
+func TestTieredServiceHealth(t *testing.T) {
+	svc, err := NewTieredService(importingCache{session.InMemoryService()}, newMapStore())
+	if err != nil {
+		t.Fatalf("NewTieredService failed: %v", err)
+	}
+	if err := svc.Healthy(context.Background()); err != nil {
+		t.Errorf("tiers without health checks reported %v", err)
+	}
+}
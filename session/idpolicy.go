@@ -0,0 +1,116 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultMaxIDLength is the default maximum length of a session ID, in bytes.
+const defaultMaxIDLength = 128
+
+// ErrInvalidSessionID is matched by every InvalidIDError.
+var ErrInvalidSessionID = errors.New("invalid session ID")
+
+// InvalidIDError reports a client-supplied session ID rejected by an IDPolicy.
+type InvalidIDError struct {
+	ID     string
+	Reason string
+}
+
+func (e *InvalidIDError) Error() string {
+	return fmt.Sprintf("invalid session ID %q: %s", e.ID, e.Reason)
+}
+
+// Is makes errors.Is(err, ErrInvalidSessionID) match an InvalidIDError.
+func (e *InvalidIDError) Is(target error) bool { return target == ErrInvalidSessionID }
+
+// IDPolicy validates the session IDs supplied by clients, so IDs that would break
+// storage keys (colons in Redis keys, very long or non-ASCII strings) are rejected.
+// The zero value applies the defaults.
+type IDPolicy struct {
+	// Optional. MaxLength is the maximum length of an ID, in bytes. Default: 128.
+	MaxLength int
+
+	// Optional. Charset reports whether a rune is allowed in an ID.
+	// Default: ASCII letters, digits, '-', '_' and '.'.
+	Charset func(r rune) bool
+
+	// Optional. ReservedPrefixes are prefixes clients may not use, e.g. for IDs
+	// the server generates itself.
+	ReservedPrefixes []string
+
+	// Optional. AlwaysGenerate ignores the client-supplied IDs: every session gets
+	// a server-generated ID.
+	AlwaysGenerate bool
+
+	// Optional. Generate generates the server-side IDs. Default: the generator of
+	// the session service.
+	Generate func() string
+}
+
+// DefaultCharset is the default IDPolicy charset: ASCII letters, digits, '-', '_' and '.'.
+func DefaultCharset(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	default:
+		return r == '-' || r == '_' || r == '.'
+	}
+}
+
+// Validate returns an *InvalidIDError if id breaks the policy. The empty ID, which
+// asks the service to generate one, is valid.
+func (p *IDPolicy) Validate(id string) error {
+	if id == "" {
+		return nil
+	}
+
+	maxLength := p.MaxLength
+	if maxLength <= 0 {
+		maxLength = defaultMaxIDLength
+	}
+	if len(id) > maxLength {
+		return &InvalidIDError{ID: id, Reason: fmt.Sprintf("longer than %d bytes", maxLength)}
+	}
+
+	if !utf8.ValidString(id) {
+		return &InvalidIDError{ID: id, Reason: "not valid UTF-8"}
+	}
+
+	charset := p.Charset
+	if charset == nil {
+		charset = DefaultCharset
+	}
+	for _, r := range id {
+		if !charset(r) {
+			return &InvalidIDError{ID: id, Reason: fmt.Sprintf("character %q not allowed", r)}
+		}
+	}
+
+	for _, prefix := range p.ReservedPrefixes {
+		if strings.HasPrefix(id, prefix) {
+			return &InvalidIDError{ID: id, Reason: fmt.Sprintf("prefix %q is reserved", prefix)}
+		}
+	}
+
+	return nil
+}
+
+// Resolve returns the ID to create a session with: a generated ID if id is empty or
+// the policy always generates, else the validated id. generate is used when the
+// policy has no Generate function.
+func (p *IDPolicy) Resolve(id string, generate func() string) (string, error) {
+	if id == "" || p.AlwaysGenerate {
+		if p.Generate != nil {
+			return p.Generate(), nil
+		}
+		return generate(), nil
+	}
+
+	if err := p.Validate(id); err != nil {
+		return "", err
+	}
+	return id, nil
+}
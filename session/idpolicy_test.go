@@ -0,0 +1,72 @@
+package session
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestIDPolicyValidate(t *testing.T) {
+	policy := &IDPolicy{ReservedPrefixes: []string{"sys-"}}
+
+	tests := []struct {
+		id    string
+		valid bool
+	}{
+		{"", true},
+		{"abc-123_X.y", true},
+		{strings.Repeat("a", 128), true},
+		{strings.Repeat("a", 129), false},
+		{"a:b", false},
+		{"a b", false},
+		{"héllo", false},
+		{"sys-1", false},
+		{"system", true},
+	}
+
+	for _, tt := range tests {
+		err := policy.Validate(tt.id)
+		if tt.valid && err != nil {
+			t.Errorf("Validate(%q) = %v, want nil", tt.id, err)
+		}
+		if !tt.valid {
+			var invalid *InvalidIDError
+			if !errors.Is(err, ErrInvalidSessionID) || !errors.As(err, &invalid) || invalid.ID != tt.id {
+				t.Errorf("Validate(%q) = %v, want an InvalidIDError", tt.id, err)
+			}
+		}
+	}
+}
+
+func TestIDPolicyResolve(t *testing.T) {
+	generate := func() string { return "generated" }
+
+	t.Run("keeps valid ID", func(t *testing.T) {
+		id, err := (&IDPolicy{}).Resolve("client-id", generate)
+		if err != nil || id != "client-id" {
+			t.Errorf("Resolve() = %q, %v", id, err)
+		}
+	})
+
+	t.Run("generates empty ID", func(t *testing.T) {
+		id, err := (&IDPolicy{}).Resolve("", generate)
+		if err != nil || id != "generated" {
+			t.Errorf("Resolve() = %q, %v", id, err)
+		}
+	})
+
+	t.Run("always generates", func(t *testing.T) {
+		policy := &IDPolicy{AlwaysGenerate: true, Generate: func() string { return "custom" }}
+		id, err := policy.Resolve("a:b", generate)
+		if err != nil || id != "custom" {
+			t.Errorf("Resolve() = %q, %v", id, err)
+		}
+	})
+
+	t.Run("custom charset", func(t *testing.T) {
+		policy := &IDPolicy{Charset: func(r rune) bool { return r >= '0' && r <= '9' }}
+		if _, err := policy.Resolve("abc", generate); !errors.Is(err, ErrInvalidSessionID) {
+			t.Errorf("expected ErrInvalidSessionID, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,490 @@
+// Package inmemory provides a session.Service keeping sessions in process memory,
+// for tests and small deployments. Unlike ADK's built-in in-memory service, it syncs
+// sessions to a k-adk Persister and recovers them from a Loader after a restart.
+package inmemory
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	ksess "github.com/kydenul/k-adk/session"
+	"github.com/kydenul/log"
+	"github.com/spf13/cast"
+	"google.golang.org/adk/session"
+)
+
+var (
+	_ session.Service = (*InMemorySessionService)(nil)
+	_ ksess.Importer  = (*InMemorySessionService)(nil)
+	_ ksess.Cache     = (*InMemorySessionService)(nil)
+)
+
+// sessionIDByteLength defines the length of the session ID in bytes.
+const sessionIDByteLength = 16
+
+var (
+	ErrSessionNotFound = ksess.ErrSessionNotFound
+	ErrSessionExists   = errors.New("session already exists")
+	ErrNilSession      = errors.New("session cannot be nil")
+	ErrNilEvent        = errors.New("event cannot be nil")
+)
+
+// userKey identifies the sessions of a user.
+type userKey struct {
+	appName string
+	userID  string
+}
+
+// InMemorySessionService implements session.Service with process memory as the
+// backend. It is safe for concurrent use.
+type InMemorySessionService struct {
+	mu    sync.RWMutex
+	users map[userKey]map[string]*record
+
+	// Optional.
+	logger log.Logger
+	// Optional. persister for long-term storage.
+	persister ksess.Persister
+	// Optional. loader recovers the sessions missing from memory, e.g. after a restart.
+	loader ksess.Loader
+	// Optional. idPolicy validates the client-supplied session IDs.
+	idPolicy *ksess.IDPolicy
+	// Optional. stampEvents replaces the caller-supplied event timestamps.
+	stampEvents bool
+}
+
+// ServiceOption configures the InMemorySessionService.
+type ServiceOption func(*InMemorySessionService)
+
+// WithPersister sets the optional persister for long-term session storage.
+// When set, all session operations will be automatically synced to the persister.
+func WithPersister(p ksess.Persister) ServiceOption {
+	return func(s *InMemorySessionService) { s.persister = p }
+}
+
+// WithLogger sets the optional logger for the InMemorySessionService.
+func WithLogger(logger log.Logger) ServiceOption {
+	return func(s *InMemorySessionService) { s.logger = logger }
+}
+
+// WithLoader sets the optional loader recovering the sessions missing from memory,
+// e.g. after a restart, on Get and List. It is usually the persister:
+//
+//	inmemory.WithPersister(pgPersister), inmemory.WithLoader(pgPersister)
+func WithLoader(l ksess.Loader) ServiceOption {
+	return func(s *InMemorySessionService) { s.loader = l }
+}
+
+// WithIDPolicy validates the session IDs supplied to Create against p, or replaces
+// them with generated IDs if p.AlwaysGenerate is set. Invalid IDs fail with an
+// error matching ksess.ErrInvalidSessionID.
+func WithIDPolicy(p ksess.IDPolicy) ServiceOption {
+	return func(s *InMemorySessionService) { s.idPolicy = &p }
+}
+
+// WithAppendTimestamps stamps every appended event with the time of the append,
+// replacing the timestamp set by the caller. By default only the events without a
+// timestamp are stamped, so replayed or imported events keep their own.
+func WithAppendTimestamps() ServiceOption {
+	return func(s *InMemorySessionService) { s.stampEvents = true }
+}
+
+// NewInMemorySessionService creates a new InMemorySessionService.
+// If logger is nil, a no-op logger will be used internally.
+func NewInMemorySessionService(opts ...ServiceOption) *InMemorySessionService {
+	svc := &InMemorySessionService{users: make(map[userKey]map[string]*record)}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(svc)
+	}
+
+	if svc.logger == nil {
+		svc.logger = &discardlog.DiscardLog{}
+	}
+
+	if svc.persister != nil {
+		svc.logger.Info("persister enabled for long-term session storage")
+	}
+	if svc.loader != nil {
+		svc.logger.Info("recovery of missing sessions enabled")
+	}
+
+	return svc
+}
+
+// generateSessionID generates a unique session ID using crypto/rand.
+func generateSessionID() string {
+	b := make([]byte, sessionIDByteLength)
+
+	if _, err := rand.Read(b); err != nil {
+		// Fallback to timestamp if crypto/rand fails
+		return cast.ToString(time.Now().UnixNano())
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// resolveSessionID returns the ID of a new session: the requested ID, validated by
+// the ID policy if one is set, or a generated ID.
+func (s *InMemorySessionService) resolveSessionID(requested string) (string, error) {
+	if s.idPolicy != nil {
+		return s.idPolicy.Resolve(requested, generateSessionID)
+	}
+	if requested == "" {
+		return generateSessionID(), nil
+	}
+	return requested, nil
+}
+
+// lookup returns the record of a session. Called with the lock held.
+func (s *InMemorySessionService) lookup(appName, userID, sessionID string) *record {
+	return s.users[userKey{appName, userID}][sessionID]
+}
+
+// store adds a record, keeping the record already stored under its ID, if any.
+// Called with the lock held.
+func (s *InMemorySessionService) store(rec *record) *record {
+	key := userKey{rec.appName, rec.userID}
+	sessions, ok := s.users[key]
+	if !ok {
+		sessions = make(map[string]*record)
+		s.users[key] = sessions
+	}
+
+	if existing, ok := sessions[rec.id]; ok {
+		return existing
+	}
+	sessions[rec.id] = rec
+
+	return rec
+}
+
+// Create creates a new session.
+func (s *InMemorySessionService) Create(
+	ctx context.Context,
+	req *session.CreateRequest,
+) (*session.CreateResponse, error) {
+	sessionID, err := s.resolveSessionID(req.SessionID)
+	if err != nil {
+		s.logger.Warnf("rejected session ID: app=%s, user=%s, err=%v", req.AppName, req.UserID, err)
+		return nil, err
+	}
+
+	s.logger.Debugf("creating session: app=%s, user=%s, session=%s",
+		req.AppName, req.UserID, sessionID)
+
+	rec := &record{
+		id:             sessionID,
+		appName:        req.AppName,
+		userID:         req.UserID,
+		state:          maps.Clone(req.State),
+		lastUpdateTime: time.Now(),
+	}
+	if rec.state == nil {
+		rec.state = make(map[string]any)
+	}
+
+	s.mu.Lock()
+	if s.lookup(req.AppName, req.UserID, sessionID) != nil {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrSessionExists, sessionID)
+	}
+	s.store(rec)
+	sess := rec.snapshot(0, time.Time{})
+	s.mu.Unlock()
+
+	// NOTE: Persist if persister is configured
+	if s.persister != nil {
+		if err := s.persister.PersistSession(ctx, sess); err != nil {
+			s.logger.Warnf("failed to persist session %s: %v", sessionID, err)
+			// Don't fail the request, memory is the primary storage
+		}
+	}
+
+	s.logger.Infof("session created: app=%s, user=%s, session=%s", req.AppName, req.UserID, sessionID)
+
+	return &session.CreateResponse{Session: sess}, nil
+}
+
+// Get retrieves a session by ID. With WithLoader, a session missing from memory is
+// recovered from the loader.
+func (s *InMemorySessionService) Get(
+	ctx context.Context,
+	req *session.GetRequest,
+) (*session.GetResponse, error) {
+	s.logger.Debugf("getting session: app=%s, user=%s, session=%s",
+		req.AppName, req.UserID, req.SessionID)
+
+	s.mu.RLock()
+	rec := s.lookup(req.AppName, req.UserID, req.SessionID)
+	var sess *memSession
+	if rec != nil {
+		sess = rec.snapshot(req.NumRecentEvents, req.After)
+	}
+	s.mu.RUnlock()
+
+	// NOTE: Recover a session missing from memory from the loader
+	if sess == nil && s.loader != nil {
+		rec, err := s.recover(ctx, req.AppName, req.UserID, req.SessionID)
+		if err != nil {
+			return nil, err
+		}
+		if rec != nil {
+			s.mu.RLock()
+			sess = rec.snapshot(req.NumRecentEvents, req.After)
+			s.mu.RUnlock()
+		}
+	}
+
+	if sess == nil {
+		s.logger.Errorf("session not found: %s", req.SessionID)
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, req.SessionID)
+	}
+
+	s.logger.Infof("session retrieved: session=%s, events=%d", req.SessionID, sess.events.Len())
+
+	return &session.GetResponse{Session: sess}, nil
+}
+
+// List returns the sessions of a user, or of every user of the app if UserID is
+// empty, without their events. With WithLoader, the sessions of the loader missing
+// from memory are recovered and listed too.
+func (s *InMemorySessionService) List(
+	ctx context.Context,
+	req *session.ListRequest,
+) (*session.ListResponse, error) {
+	s.logger.Debugf("listing sessions: app=%s, user=%s", req.AppName, req.UserID)
+
+	// NOTE: Recover the sessions of the loader first, so they are listed in order
+	if s.loader != nil && req.UserID != "" {
+		if err := s.recoverList(ctx, req.AppName, req.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.RLock()
+	var sessions []session.Session
+	for key, records := range s.users {
+		if key.appName != req.AppName || (req.UserID != "" && key.userID != req.UserID) {
+			continue
+		}
+		for _, rec := range records {
+			sess := rec.snapshot(0, time.Time{})
+			sess.events = &memEvents{}
+			sessions = append(sessions, sess)
+		}
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(sessions, func(a, b session.Session) int {
+		return b.LastUpdateTime().Compare(a.LastUpdateTime())
+	})
+
+	s.logger.Infof("listed %d sessions for user %s", len(sessions), req.UserID)
+
+	return &session.ListResponse{Sessions: sessions}, nil
+}
+
+// Delete removes a session.
+func (s *InMemorySessionService) Delete(ctx context.Context, req *session.DeleteRequest) error {
+	s.logger.Debugf("deleting session: app=%s, user=%s, session=%s",
+		req.AppName, req.UserID, req.SessionID)
+
+	key := userKey{req.AppName, req.UserID}
+
+	s.mu.Lock()
+	delete(s.users[key], req.SessionID)
+	if len(s.users[key]) == 0 {
+		delete(s.users, key)
+	}
+	s.mu.Unlock()
+
+	// NOTE: Delete from the persister if configured
+	if s.persister != nil {
+		err := s.persister.DeleteSession(ctx, req.AppName, req.UserID, req.SessionID)
+		if err != nil {
+			s.logger.Warnf("failed to delete session %s from the persister: %v", req.SessionID, err)
+			// Don't fail the request, memory deletion succeeded
+		}
+	}
+
+	s.logger.Infof("session deleted: app=%s, user=%s, session=%s",
+		req.AppName, req.UserID, req.SessionID)
+
+	return nil
+}
+
+// AppendEvent appends an event to a session, and applies its state delta; keys
+// prefixed with session.KeyPrefixTemp are not stored. Partial events are ignored.
+// Events without a timestamp are stamped with the current time (see
+// WithAppendTimestamps); the last update time of the session does not go back to
+// the timestamp of an older event.
+func (s *InMemorySessionService) AppendEvent(
+	ctx context.Context,
+	sess session.Session,
+	evt *session.Event,
+) error {
+	if sess == nil {
+		return ErrNilSession
+	}
+	if evt == nil {
+		return ErrNilEvent
+	}
+	if evt.Partial {
+		return nil
+	}
+
+	// NOTE: Keep the timestamp of events replayed or imported by the caller
+	if evt.Timestamp.IsZero() || s.stampEvents {
+		evt.Timestamp = time.Now()
+	}
+	if evt.ID == "" {
+		evt.ID = generateSessionID()
+	}
+
+	s.logger.Debugf("appending event to session %s: event_id=%s, author=%s",
+		sess.ID(), evt.ID, evt.Author)
+
+	// NOTE: Apply the state delta to the caller's session, then store its state
+	for key, value := range evt.Actions.StateDelta {
+		if strings.HasPrefix(key, session.KeyPrefixTemp) {
+			continue
+		}
+		if err := sess.State().Set(key, value); err != nil {
+			return fmt.Errorf("failed to apply state delta: %w", err)
+		}
+	}
+
+	state := make(map[string]any)
+	if sess.State() != nil {
+		for key, value := range sess.State().All() {
+			if !strings.HasPrefix(key, session.KeyPrefixTemp) {
+				state[key] = value
+			}
+		}
+	}
+
+	s.mu.Lock()
+	rec := s.lookup(sess.AppName(), sess.UserID(), sess.ID())
+	if rec == nil {
+		s.mu.Unlock()
+		s.logger.Errorf("session not found: %s", sess.ID())
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, sess.ID())
+	}
+	rec.events = append(rec.events, evt)
+	rec.state = state
+	if evt.Timestamp.After(rec.lastUpdateTime) {
+		rec.lastUpdateTime = evt.Timestamp
+	}
+	lastUpdateTime := rec.lastUpdateTime
+	s.mu.Unlock()
+
+	if ms, ok := sess.(*memSession); ok {
+		ms.events.append(evt)
+		ms.lastUpdateTime = lastUpdateTime
+	}
+
+	// NOTE: Real-time sync to the persister if configured
+	if s.persister != nil {
+		if err := s.persister.PersistEvent(ctx, sess, evt); err != nil {
+			s.logger.Warnf("failed to persist event %s: %v", evt.ID, err)
+			// Don't fail the request, memory is the primary storage
+		}
+	}
+
+	s.logger.Infof("event appended: session=%s, event=%s", sess.ID(), evt.ID)
+
+	return nil
+}
+
+// recover stores a session read from the loader. It returns nil if the loader does
+// not have the session either. A session created concurrently is kept.
+func (s *InMemorySessionService) recover(ctx context.Context, appName, userID, sessionID string) (*record, error) {
+	stored, err := s.loader.LoadSession(ctx, appName, userID, sessionID)
+	if err != nil {
+		s.logger.Errorf("failed to load session %s from the persistent store: %v", sessionID, err)
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+	if stored == nil {
+		return nil, nil
+	}
+
+	rec := newRecord(stored)
+	rec.id, rec.appName, rec.userID = sessionID, appName, userID
+
+	s.mu.Lock()
+	rec = s.store(rec)
+	s.mu.Unlock()
+
+	s.logger.Infof("session recovered from the persistent store: session=%s, events=%d",
+		sessionID, len(stored.Events))
+
+	return rec, nil
+}
+
+// recoverList recovers the sessions of a user kept by the loader but missing from
+// memory. Sessions that fail to load are logged and skipped.
+func (s *InMemorySessionService) recoverList(ctx context.Context, appName, userID string) error {
+	ids, err := s.loader.ListSessionIDs(ctx, appName, userID)
+	if err != nil {
+		s.logger.Errorf("failed to list sessions of user %s in the persistent store: %v", userID, err)
+		return fmt.Errorf("failed to list stored sessions: %w", err)
+	}
+
+	for _, id := range ids {
+		s.mu.RLock()
+		exists := s.lookup(appName, userID, id) != nil
+		s.mu.RUnlock()
+		if exists {
+			continue
+		}
+
+		if _, err := s.recover(ctx, appName, userID, id); err != nil {
+			s.logger.Warnf("failed to recover session %s: %v", id, err)
+		}
+	}
+
+	return nil
+}
+
+// ImportSession stores a session as is, keeping the timestamps of its events, and
+// syncs it to the persister. It implements ksess.Importer.
+func (s *InMemorySessionService) ImportSession(ctx context.Context, stored *ksess.StoredSession) error {
+	rec := newRecord(stored)
+
+	s.mu.Lock()
+	if s.lookup(rec.appName, rec.userID, rec.id) != nil {
+		s.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrSessionExists, rec.id)
+	}
+	s.store(rec)
+	sess := rec.snapshot(0, time.Time{})
+	s.mu.Unlock()
+
+	// NOTE: Persist if persister is configured
+	if s.persister != nil {
+		if err := s.persister.PersistSession(ctx, sess); err != nil {
+			s.logger.Warnf("failed to persist session %s: %v", rec.id, err)
+		}
+		for evt := range sess.Events().All() {
+			if err := s.persister.PersistEvent(ctx, sess, evt); err != nil {
+				s.logger.Warnf("failed to persist event %s: %v", evt.ID, err)
+			}
+		}
+	}
+
+	s.logger.Infof("session imported: app=%s, user=%s, session=%s, events=%d",
+		rec.appName, rec.userID, rec.id, len(rec.events))
+
+	return nil
+}
@@ -0,0 +1,325 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	ksess "github.com/kydenul/k-adk/session"
+	"google.golang.org/adk/session"
+)
+
+// memStore is an in-memory ksess.Persister and ksess.Loader.
+type memStore struct {
+	mu       sync.Mutex
+	sessions map[string]*ksess.StoredSession
+}
+
+func newMemStore() *memStore {
+	return &memStore{sessions: make(map[string]*ksess.StoredSession)}
+}
+
+func (m *memStore) PersistSession(_ context.Context, sess session.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state := make(map[string]any)
+	for k, v := range sess.State().All() {
+		state[k] = v
+	}
+	m.sessions[sess.ID()] = &ksess.StoredSession{
+		ID:             sess.ID(),
+		AppName:        sess.AppName(),
+		UserID:         sess.UserID(),
+		State:          state,
+		LastUpdateTime: sess.LastUpdateTime(),
+	}
+	return nil
+}
+
+func (m *memStore) PersistEvent(_ context.Context, sess session.Session, evt *session.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.sessions[sess.ID()]
+	if !ok {
+		return ksess.ErrInvalidSessionID
+	}
+	stored.Events = append(stored.Events, evt)
+	for k, v := range sess.State().All() {
+		stored.State[k] = v
+	}
+	return nil
+}
+
+func (m *memStore) DeleteSession(_ context.Context, _, _, sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, sessionID)
+	return nil
+}
+
+func (m *memStore) Close() error { return nil }
+
+func (m *memStore) LoadSession(_ context.Context, _, _, sessionID string) (*ksess.StoredSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.sessions[sessionID], nil
+}
+
+func (m *memStore) ListSessionIDs(_ context.Context, _, _ string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := make([]string, 0, len(m.sessions))
+	for id := range m.sessions {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func TestCreateAndGet(t *testing.T) {
+	ctx := context.Background()
+	svc := NewInMemorySessionService()
+
+	resp, err := svc.Create(ctx, &session.CreateRequest{
+		AppName: "app", UserID: "user", State: map[string]any{"city": "Paris"},
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if resp.Session.ID() == "" {
+		t.Fatal("Expected a generated session ID")
+	}
+
+	_, err = svc.Create(ctx, &session.CreateRequest{AppName: "app", UserID: "user", SessionID: resp.Session.ID()})
+	if !errors.Is(err, ErrSessionExists) {
+		t.Errorf("Create() duplicate error = %v, want ErrSessionExists", err)
+	}
+
+	got, err := svc.Get(ctx, &session.GetRequest{AppName: "app", UserID: "user", SessionID: resp.Session.ID()})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if city, _ := got.Session.State().Get("city"); city != "Paris" {
+		t.Errorf("state city = %v, want Paris", city)
+	}
+
+	_, err = svc.Get(ctx, &session.GetRequest{AppName: "app", UserID: "user", SessionID: "missing"})
+	if !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Get() error = %v, want ErrSessionNotFound", err)
+	}
+}
+
+func TestAppendEvent(t *testing.T) {
+	ctx := context.Background()
+	svc := NewInMemorySessionService()
+
+	resp, _ := svc.Create(ctx, &session.CreateRequest{AppName: "app", UserID: "user", SessionID: "s1"})
+	sess := resp.Session
+
+	evt := session.NewEvent("inv-1")
+	evt.Author = "agent"
+	evt.Actions.StateDelta = map[string]any{"step": 1, session.KeyPrefixTemp + "scratch": true}
+	if err := svc.AppendEvent(ctx, sess, evt); err != nil {
+		t.Fatalf("AppendEvent() error = %v", err)
+	}
+
+	partial := session.NewEvent("inv-1")
+	partial.Partial = true
+	if err := svc.AppendEvent(ctx, sess, partial); err != nil {
+		t.Fatalf("AppendEvent() partial error = %v", err)
+	}
+
+	if sess.Events().Len() != 1 {
+		t.Errorf("caller session events = %d, want 1", sess.Events().Len())
+	}
+
+	got, _ := svc.Get(ctx, &session.GetRequest{AppName: "app", UserID: "user", SessionID: "s1"})
+	if got.Session.Events().Len() != 1 {
+		t.Errorf("stored events = %d, want 1", got.Session.Events().Len())
+	}
+	if step, _ := got.Session.State().Get("step"); step != 1 {
+		t.Errorf("state step = %v, want 1", step)
+	}
+	if _, err := got.Session.State().Get(session.KeyPrefixTemp + "scratch"); err == nil {
+		t.Error("Expected temp state not to be stored")
+	}
+
+	t.Run("filters events", func(t *testing.T) {
+		second := session.NewEvent("inv-2")
+		_ = svc.AppendEvent(ctx, sess, second)
+
+		got, _ := svc.Get(ctx, &session.GetRequest{
+			AppName: "app", UserID: "user", SessionID: "s1", NumRecentEvents: 1,
+		})
+		if got.Session.Events().Len() != 1 || got.Session.Events().At(0).ID != second.ID {
+			t.Error("Expected only the most recent event")
+		}
+
+		got, _ = svc.Get(ctx, &session.GetRequest{
+			AppName: "app", UserID: "user", SessionID: "s1", After: time.Now().Add(time.Hour),
+		})
+		if got.Session.Events().Len() != 0 {
+			t.Errorf("events after the future = %d, want 0", got.Session.Events().Len())
+		}
+	})
+
+	t.Run("deleted session", func(t *testing.T) {
+		_ = svc.Delete(ctx, &session.DeleteRequest{AppName: "app", UserID: "user", SessionID: "s1"})
+		if err := svc.AppendEvent(ctx, sess, session.NewEvent("inv-3")); !errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("AppendEvent() error = %v, want ErrSessionNotFound", err)
+		}
+	})
+}
+
+func TestAppendEventTimestamps(t *testing.T) {
+	ctx := context.Background()
+	past := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, tt := range []struct {
+		name string
+		opts []ServiceOption
+		kept bool
+	}{
+		{"caller timestamps kept", nil, true},
+		{"append timestamps", []ServiceOption{WithAppendTimestamps()}, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewInMemorySessionService(tt.opts...)
+			resp, _ := svc.Create(ctx, &session.CreateRequest{AppName: "app", UserID: "user", SessionID: "s1"})
+			created := resp.Session.LastUpdateTime()
+
+			evt := &session.Event{ID: "e1", Author: "user", Timestamp: past}
+			if err := svc.AppendEvent(ctx, resp.Session, evt); err != nil {
+				t.Fatalf("AppendEvent() error = %v", err)
+			}
+			if kept := evt.Timestamp.Equal(past); kept != tt.kept {
+				t.Errorf("event timestamp = %v, kept = %v, want %v", evt.Timestamp, kept, tt.kept)
+			}
+			if resp.Session.LastUpdateTime().Before(created) {
+				t.Errorf("last update time moved back to %v", resp.Session.LastUpdateTime())
+			}
+
+			unstamped := &session.Event{ID: "e2", Author: "user"}
+			if err := svc.AppendEvent(ctx, resp.Session, unstamped); err != nil {
+				t.Fatalf("AppendEvent() error = %v", err)
+			}
+			if unstamped.Timestamp.IsZero() {
+				t.Error("Expected an event without timestamp to be stamped")
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+	svc := NewInMemorySessionService()
+
+	for _, req := range []*session.CreateRequest{
+		{AppName: "app", UserID: "alice", SessionID: "a1"},
+		{AppName: "app", UserID: "alice", SessionID: "a2"},
+		{AppName: "app", UserID: "bob", SessionID: "b1"},
+		{AppName: "other", UserID: "alice", SessionID: "o1"},
+	} {
+		if _, err := svc.Create(ctx, req); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	resp, err := svc.List(ctx, &session.ListRequest{AppName: "app", UserID: "alice"})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(resp.Sessions) != 2 {
+		t.Errorf("List() = %d sessions, want 2", len(resp.Sessions))
+	}
+
+	resp, _ = svc.List(ctx, &session.ListRequest{AppName: "app"})
+	if len(resp.Sessions) != 3 {
+		t.Errorf("List() of every user = %d sessions, want 3", len(resp.Sessions))
+	}
+}
+
+func TestPersisterRecovery(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+
+	svc := NewInMemorySessionService(WithPersister(store), WithLoader(store))
+	resp, err := svc.Create(ctx, &session.CreateRequest{
+		AppName: "app", UserID: "user", SessionID: "s1", State: map[string]any{"lang": "fr"},
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := svc.AppendEvent(ctx, resp.Session, session.NewEvent("inv-1")); err != nil {
+		t.Fatalf("AppendEvent() error = %v", err)
+	}
+
+	// A new service, e.g. after a restart, recovers the session from the store
+	restarted := NewInMemorySessionService(WithPersister(store), WithLoader(store))
+
+	got, err := restarted.Get(ctx, &session.GetRequest{AppName: "app", UserID: "user", SessionID: "s1"})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Session.Events().Len() != 1 {
+		t.Errorf("recovered events = %d, want 1", got.Session.Events().Len())
+	}
+	if lang, _ := got.Session.State().Get("lang"); lang != "fr" {
+		t.Errorf("recovered state lang = %v, want fr", lang)
+	}
+
+	restarted = NewInMemorySessionService(WithLoader(store))
+	list, err := restarted.List(ctx, &session.ListRequest{AppName: "app", UserID: "user"})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(list.Sessions) != 1 {
+		t.Errorf("List() = %d sessions, want 1 recovered", len(list.Sessions))
+	}
+
+	if err := svc.Delete(ctx, &session.DeleteRequest{AppName: "app", UserID: "user", SessionID: "s1"}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := NewInMemorySessionService(WithLoader(store)).Get(ctx, &session.GetRequest{
+		AppName: "app", UserID: "user", SessionID: "s1",
+	}); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Get() after Delete error = %v, want ErrSessionNotFound", err)
+	}
+}
+
+func TestImportSession(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	svc := NewInMemorySessionService(WithPersister(store))
+
+	at := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	evt := session.NewEvent("inv-1")
+	evt.ID, evt.Timestamp = "e1", at
+	dump := ksess.NewDump(&ksess.StoredSession{
+		ID: "s1", AppName: "app", UserID: "user",
+		State:          map[string]any{"lang": "fr"},
+		Events:         []*session.Event{evt},
+		LastUpdateTime: at,
+	})
+
+	sess, err := ksess.Import(ctx, svc, dump)
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if !sess.LastUpdateTime().Equal(at) || !sess.Events().At(0).Timestamp.Equal(at) {
+		t.Errorf("imported timestamps = %v, %v, want %v", sess.LastUpdateTime(), sess.Events().At(0).Timestamp, at)
+	}
+	if stored := store.sessions["s1"]; stored == nil || len(stored.Events) != 1 {
+		t.Errorf("persisted session = %+v, want 1 event", stored)
+	}
+
+	if _, err := ksess.Import(ctx, svc, dump); !errors.Is(err, ErrSessionExists) {
+		t.Errorf("Import() of an existing session error = %v, want ErrSessionExists", err)
+	}
+}
@@ -0,0 +1,159 @@
+package inmemory
+
+import (
+	"iter"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	ksess "github.com/kydenul/k-adk/session"
+	"google.golang.org/adk/session"
+)
+
+// record is a session as stored by the service.
+type record struct {
+	id             string
+	appName        string
+	userID         string
+	state          map[string]any
+	events         []*session.Event
+	lastUpdateTime time.Time
+}
+
+// newRecord returns the record of a stored session.
+func newRecord(stored *ksess.StoredSession) *record {
+	rec := &record{
+		id:             stored.ID,
+		appName:        stored.AppName,
+		userID:         stored.UserID,
+		state:          maps.Clone(stored.State),
+		events:         slices.Clone(stored.Events),
+		lastUpdateTime: stored.LastUpdateTime,
+	}
+	if rec.state == nil {
+		rec.state = make(map[string]any)
+	}
+	return rec
+}
+
+// snapshot returns a session holding a copy of the record, keeping the last
+// numRecent events (0 keeps all) not older than after.
+func (r *record) snapshot(numRecent int, after time.Time) *memSession {
+	events := r.events
+	if numRecent > 0 && len(events) > numRecent {
+		events = events[len(events)-numRecent:]
+	}
+	if !after.IsZero() {
+		events = slices.DeleteFunc(slices.Clone(events), func(evt *session.Event) bool {
+			return evt.Timestamp.Before(after)
+		})
+	}
+
+	return &memSession{
+		id:             r.id,
+		appName:        r.appName,
+		userID:         r.userID,
+		state:          &memState{values: maps.Clone(r.state)},
+		events:         &memEvents{events: slices.Clone(events)},
+		lastUpdateTime: r.lastUpdateTime,
+	}
+}
+
+var _ session.Session = (*memSession)(nil)
+
+// memSession implements the session.Session interface over a copy of a record:
+// changes reach the service through AppendEvent only.
+type memSession struct {
+	id             string
+	appName        string
+	userID         string
+	state          *memState
+	events         *memEvents
+	lastUpdateTime time.Time
+}
+
+func (s *memSession) ID() string                { return s.id }
+func (s *memSession) AppName() string           { return s.appName }
+func (s *memSession) UserID() string            { return s.userID }
+func (s *memSession) State() session.State      { return s.state }
+func (s *memSession) Events() session.Events    { return s.events }
+func (s *memSession) LastUpdateTime() time.Time { return s.lastUpdateTime }
+
+var _ session.State = (*memState)(nil)
+
+// memState implements session.State with a map. It is thread-safe.
+type memState struct {
+	mu     sync.RWMutex
+	values map[string]any
+}
+
+func (s *memState) Get(key string) (any, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, ok := s.values[key]
+	if !ok {
+		return nil, session.ErrStateKeyNotExist
+	}
+	return value, nil
+}
+
+func (s *memState) Set(key string, value any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.values == nil {
+		s.values = make(map[string]any)
+	}
+	s.values[key] = value
+	return nil
+}
+
+func (s *memState) All() iter.Seq2[string, any] {
+	s.mu.RLock()
+	values := maps.Clone(s.values)
+	s.mu.RUnlock()
+
+	return maps.All(values)
+}
+
+var _ session.Events = (*memEvents)(nil)
+
+// memEvents implements session.Events with a slice. It is thread-safe.
+type memEvents struct {
+	mu     sync.RWMutex
+	events []*session.Event
+}
+
+func (e *memEvents) All() iter.Seq[*session.Event] {
+	e.mu.RLock()
+	events := slices.Clone(e.events)
+	e.mu.RUnlock()
+
+	return slices.Values(events)
+}
+
+func (e *memEvents) Len() int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return len(e.events)
+}
+
+func (e *memEvents) At(i int) *session.Event {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if i < 0 || i >= len(e.events) {
+		return nil
+	}
+	return e.events[i]
+}
+
+func (e *memEvents) append(evt *session.Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.events = append(e.events, evt)
+}
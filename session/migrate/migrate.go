@@ -0,0 +1,296 @@
+// Package migrate copies sessions and their events from one session.Service to
+// another, e.g. to move an existing deployment from ADK's in-memory service or a
+// Redis-only setup onto the Redis + PostgreSQL hybrid, with progress reporting and
+// resumable runs.
+package migrate
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	ksess "github.com/kydenul/k-adk/session"
+	"github.com/kydenul/log"
+	"google.golang.org/adk/session"
+)
+
+var (
+	// ErrNoAppName is returned by Migrate for a filter without app name.
+	ErrNoAppName = errors.New("app name is required")
+
+	// ErrInvalidCursor is returned by ParseCursor for a malformed cursor.
+	ErrInvalidCursor = errors.New("invalid migration cursor")
+)
+
+// Filter selects the sessions to migrate.
+type Filter struct {
+	// AppName is the app of the sessions.
+	AppName string
+
+	// Optional. UserIDs restricts the migration to the sessions of these users.
+	// Default: the sessions src lists for an empty user ID, every user of the app
+	// with the in-memory services. The Redis service lists the sessions of a user
+	// only, so pass its users, e.g. from RedisSessionService.AllSessions.
+	UserIDs []string
+
+	// Optional. UpdatedAfter skips the sessions last updated before it.
+	UpdatedAfter time.Time
+
+	// Optional. Match selects the sessions to migrate, as listed by src: without
+	// their events.
+	Match func(sess session.Session) bool
+}
+
+// Cursor is the last session handled by a migration. Sessions are migrated in
+// order of user ID, then session ID.
+type Cursor struct {
+	UserID    string
+	SessionID string
+}
+
+// String encodes the cursor for ParseCursor, e.g. to print it on the command line.
+func (c Cursor) String() string {
+	if c == (Cursor{}) {
+		return ""
+	}
+	return url.QueryEscape(c.UserID) + ":" + url.QueryEscape(c.SessionID)
+}
+
+// ParseCursor decodes a cursor encoded by Cursor.String. The empty string is the
+// zero cursor, starting from the first session.
+func ParseCursor(text string) (Cursor, error) {
+	if text == "" {
+		return Cursor{}, nil
+	}
+
+	user, sess, ok := strings.Cut(text, ":")
+	if !ok {
+		return Cursor{}, fmt.Errorf("%w: %q", ErrInvalidCursor, text)
+	}
+	userID, err := url.QueryUnescape(user)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("%w: %q", ErrInvalidCursor, text)
+	}
+	sessionID, err := url.QueryUnescape(sess)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("%w: %q", ErrInvalidCursor, text)
+	}
+
+	return Cursor{UserID: userID, SessionID: sessionID}, nil
+}
+
+// compare orders the sessions of a migration.
+func (c Cursor) compare(other Cursor) int {
+	return cmp.Or(strings.Compare(c.UserID, other.UserID), strings.Compare(c.SessionID, other.SessionID))
+}
+
+// Result reports the progress of Migrate.
+type Result struct {
+	// Sessions is the number of sessions migrated and Events the number of their
+	// events. Skipped is the number of sessions dst already had, and Failed the
+	// number of sessions that could not be migrated.
+	Sessions int
+	Events   int
+	Skipped  int
+	Failed   int
+
+	// Cursor is the last session handled. Pass it to WithResume to continue an
+	// interrupted migration. Done reports that every session was handled.
+	Cursor Cursor
+	Done   bool
+
+	Duration time.Duration
+}
+
+// migrator holds the options of a migration.
+type migrator struct {
+	resume            Cursor
+	onProgress        func(*Result)
+	sessionsPerSecond int
+	logger            log.Logger
+}
+
+// Option configures Migrate.
+type Option func(*migrator)
+
+// WithResume continues an interrupted migration after the Cursor of its Result.
+func WithResume(c Cursor) Option {
+	return func(m *migrator) { m.resume = c }
+}
+
+// WithProgress sets a function called with the result so far after every session,
+// e.g. to report progress or save the cursor.
+func WithProgress(fn func(*Result)) Option {
+	return func(m *migrator) { m.onProgress = fn }
+}
+
+// WithSessionsPerSecond caps the sessions migrated per second, to keep the
+// migration light on both backends. Default: no limit.
+func WithSessionsPerSecond(n int) Option {
+	return func(m *migrator) { m.sessionsPerSecond = n }
+}
+
+// WithLogger sets the logger of the migration.
+func WithLogger(logger log.Logger) Option {
+	return func(m *migrator) { m.logger = logger }
+}
+
+// Migrate copies the sessions of src selected by filter, with their state, tags
+// and events, to dst under the same IDs, with ksess.Export and ksess.Import:
+// services implementing ksess.Importer, as the Redis and in-memory services of
+// this module do, keep the event timestamps and sync the sessions to their
+// persister.
+//
+// Sessions dst already has are skipped, so a migration can be run again. Sessions
+// that fail to be migrated are logged and counted, and the migration goes on.
+// When ctx ends, Migrate returns the result so far with the context error; pass
+// its Cursor to WithResume to continue. A resumed run skips the sessions sorting
+// before its cursor, including those created since; a full run checks them all.
+func Migrate(ctx context.Context, src, dst session.Service, filter Filter, opts ...Option) (*Result, error) {
+	if src == nil || dst == nil {
+		return nil, ksess.ErrNilService
+	}
+	if filter.AppName == "" {
+		return nil, ErrNoAppName
+	}
+
+	m := &migrator{}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.logger == nil {
+		m.logger = &discardlog.DiscardLog{}
+	}
+
+	users := slices.Compact(slices.Sorted(slices.Values(filter.UserIDs)))
+	if len(users) == 0 {
+		users = []string{""}
+	}
+
+	m.logger.Infof("migrating sessions: app=%s, users=%d, resume=%s", filter.AppName, len(filter.UserIDs), m.resume)
+
+	start := time.Now()
+	result := &Result{Cursor: m.resume}
+
+	var tick <-chan time.Time
+	if m.sessionsPerSecond > 0 {
+		// NOTE: Past 1e9 sessions per second the interval rounds to 0, on which
+		// NewTicker panics
+		ticker := time.NewTicker(max(time.Second/time.Duration(m.sessionsPerSecond), time.Nanosecond))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for _, userID := range users {
+		if userID != "" && userID < m.resume.UserID {
+			continue
+		}
+
+		refs, err := m.list(ctx, src, filter, userID)
+		if err != nil {
+			result.Duration = time.Since(start)
+			return result, err
+		}
+
+		for _, ref := range refs {
+			if err := wait(ctx, tick); err != nil {
+				result.Duration = time.Since(start)
+				return result, err
+			}
+
+			events, err := migrateSession(ctx, src, dst, filter.AppName, ref)
+			switch {
+			case err != nil:
+				result.Failed++
+				m.logger.Warnf("failed to migrate session %s of user %s: %v", ref.SessionID, ref.UserID, err)
+			case events < 0:
+				result.Skipped++
+			default:
+				result.Sessions++
+				result.Events += events
+			}
+
+			result.Cursor = ref
+			if m.onProgress != nil {
+				m.onProgress(result)
+			}
+		}
+	}
+
+	result.Done = true
+	result.Duration = time.Since(start)
+
+	m.logger.Infof("sessions migrated: app=%s, sessions=%d, events=%d, skipped=%d, failed=%d, duration=%s",
+		filter.AppName, result.Sessions, result.Events, result.Skipped, result.Failed, result.Duration)
+
+	return result, nil
+}
+
+// wait waits for the next tick of the rate limit, if any, or the end of ctx.
+func wait(ctx context.Context, tick <-chan time.Time) error {
+	if tick == nil {
+		return ctx.Err()
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-tick:
+		return nil
+	}
+}
+
+// list returns the sessions of a user selected by filter and not handled before
+// the resume cursor, in order.
+func (m *migrator) list(ctx context.Context, src session.Service, filter Filter, userID string) ([]Cursor, error) {
+	resp, err := src.List(ctx, &session.ListRequest{AppName: filter.AppName, UserID: userID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions of user %q: %w", userID, err)
+	}
+
+	var refs []Cursor
+	for _, sess := range resp.Sessions {
+		ref := Cursor{UserID: sess.UserID(), SessionID: sess.ID()}
+		if m.resume != (Cursor{}) && ref.compare(m.resume) <= 0 {
+			continue
+		}
+		if !filter.UpdatedAfter.IsZero() && sess.LastUpdateTime().Before(filter.UpdatedAfter) {
+			continue
+		}
+		if filter.Match != nil && !filter.Match(sess) {
+			continue
+		}
+		refs = append(refs, ref)
+	}
+	slices.SortFunc(refs, Cursor.compare)
+
+	return refs, nil
+}
+
+// migrateSession copies a session to dst and returns the number of its events,
+// -1 if dst already has it.
+func migrateSession(ctx context.Context, src, dst session.Service, appName string, ref Cursor) (int, error) {
+	// NOTE: A failed Get means a missing session for every service of this module
+	existing, err := dst.Get(ctx, &session.GetRequest{
+		AppName: appName, UserID: ref.UserID, SessionID: ref.SessionID, NumRecentEvents: 1,
+	})
+	if err == nil && existing != nil && existing.Session != nil {
+		return -1, nil
+	}
+
+	dump, err := ksess.Export(ctx, src, appName, ref.UserID, ref.SessionID)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := ksess.Import(ctx, dst, dump); err != nil {
+		return 0, err
+	}
+
+	return len(dump.Events), nil
+}
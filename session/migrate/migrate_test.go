@@ -0,0 +1,131 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/kydenul/k-adk/session/inmemory"
+	"google.golang.org/adk/session"
+)
+
+// seed creates sessions s0..s{n-1} of every user, with one event each.
+func seed(t *testing.T, svc session.Service, users []string, n int) {
+	t.Helper()
+	ctx := context.Background()
+
+	for _, userID := range users {
+		for i := range n {
+			resp, err := svc.Create(ctx, &session.CreateRequest{
+				AppName: "app", UserID: userID, SessionID: fmt.Sprintf("s%d", i),
+				State: map[string]any{"step": i},
+			})
+			if err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+			evt := session.NewEvent("inv")
+			evt.Author = "user"
+			if err := svc.AppendEvent(ctx, resp.Session, evt); err != nil {
+				t.Fatalf("AppendEvent failed: %v", err)
+			}
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+
+	src := session.InMemoryService()
+	seed(t, src, []string{"u1", "u2"}, 3)
+
+	if _, err := Migrate(ctx, src, nil, Filter{AppName: "app"}); err == nil {
+		t.Error("expected an error for a nil destination")
+	}
+	if _, err := Migrate(ctx, src, src, Filter{}); !errors.Is(err, ErrNoAppName) {
+		t.Errorf("expected ErrNoAppName, got %v", err)
+	}
+
+	t.Run("interrupted and resumed", func(t *testing.T) {
+		dst := inmemory.NewInMemorySessionService()
+
+		stopCtx, stop := context.WithCancel(ctx)
+		defer stop()
+		result, err := Migrate(stopCtx, src, dst, Filter{AppName: "app"}, WithProgress(func(r *Result) {
+			if r.Sessions == 2 {
+				stop()
+			}
+		}))
+		if !errors.Is(err, context.Canceled) || result.Done {
+			t.Fatalf("expected an interrupted migration, got %+v, %v", result, err)
+		}
+		if want := (Cursor{UserID: "u1", SessionID: "s1"}); result.Cursor != want {
+			t.Errorf("expected cursor %v, got %v", want, result.Cursor)
+		}
+
+		cursor, err := ParseCursor(result.Cursor.String())
+		if err != nil || cursor != result.Cursor {
+			t.Fatalf("cursor round trip failed: %v, %v", cursor, err)
+		}
+
+		result, err = Migrate(ctx, src, dst, Filter{AppName: "app"}, WithResume(cursor))
+		if err != nil || !result.Done {
+			t.Fatalf("Migrate failed: %+v, %v", result, err)
+		}
+		if result.Sessions != 4 || result.Events != 4 || result.Skipped != 0 {
+			t.Errorf("expected 4 sessions with 4 events, got %+v", result)
+		}
+
+		got, err := dst.Get(ctx, &session.GetRequest{AppName: "app", UserID: "u2", SessionID: "s2"})
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if step, _ := got.Session.State().Get("step"); step != 2 || got.Session.Events().Len() != 1 {
+			t.Errorf("unexpected migrated session: step=%v, events=%d", step, got.Session.Events().Len())
+		}
+
+		result, err = Migrate(ctx, src, dst, Filter{AppName: "app"})
+		if err != nil || result.Skipped != 6 || result.Sessions != 0 {
+			t.Errorf("expected every session to be skipped, got %+v, %v", result, err)
+		}
+	})
+
+	t.Run("filtered", func(t *testing.T) {
+		dst := inmemory.NewInMemorySessionService()
+
+		result, err := Migrate(ctx, src, dst, Filter{
+			AppName: "app",
+			UserIDs: []string{"u2"},
+			Match:   func(sess session.Session) bool { return sess.ID() != "s0" },
+		}, WithSessionsPerSecond(1000))
+		if err != nil {
+			t.Fatalf("Migrate failed: %v", err)
+		}
+		if result.Sessions != 2 {
+			t.Errorf("expected 2 sessions, got %+v", result)
+		}
+	})
+	t.Run("rate above a billion", func(t *testing.T) {
+		dst := inmemory.NewInMemorySessionService()
+
+		result, err := Migrate(ctx, src, dst, Filter{AppName: "app"}, WithSessionsPerSecond(2_000_000_000))
+		if err != nil || result.Sessions != 6 {
+			t.Errorf("expected 6 sessions, got %+v, %v", result, err)
+		}
+	})
+}
+
+func TestParseCursor(t *testing.T) {
+	want := Cursor{UserID: "a:b c", SessionID: "s/1"}
+	got, err := ParseCursor(want.String())
+	if err != nil || got != want {
+		t.Errorf("got %v, %v, want %v", got, err, want)
+	}
+
+	if c, err := ParseCursor(""); err != nil || c != (Cursor{}) {
+		t.Errorf("expected the zero cursor, got %v, %v", c, err)
+	}
+	if _, err := ParseCursor("no-separator"); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("expected ErrInvalidCursor, got %v", err)
+	}
+}
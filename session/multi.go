@@ -0,0 +1,309 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/log"
+	"google.golang.org/adk/session"
+)
+
+var (
+	_ Persister     = (*MultiPersister)(nil)
+	_ HealthChecker = (*MultiPersister)(nil)
+)
+
+// ErrNoTargets is returned by NewMultiPersister without targets.
+var ErrNoTargets = errors.New("multi persister needs at least one target")
+
+// FanOutMode selects how a MultiPersister reports the failures of its targets.
+type FanOutMode int
+
+const (
+	// FanOutBestEffort writes to every target in parallel. An operation fails only
+	// if every target fails; the failures of the others are logged and counted.
+	FanOutBestEffort FanOutMode = iota
+
+	// FanOutAllOrNothing writes to the targets one by one, in order, and fails the
+	// operation at the first target failing, which the next targets do not get.
+	// A PersistSession creating the session is rolled back: it is deleted from the
+	// targets written before that did not store it, as reported by their Loader.
+	// Events, deletions and the updates of stored sessions cannot be undone, so
+	// the targets written before keep them and a retry should be idempotent.
+	FanOutAllOrNothing
+)
+
+// TargetError is the error of a target of a MultiPersister.
+type TargetError struct {
+	Target string
+	Err    error
+}
+
+func (e *TargetError) Error() string {
+	return fmt.Sprintf("persister %q: %v", e.Target, e.Err)
+}
+
+func (e *TargetError) Unwrap() error { return e.Err }
+
+// TargetStats counts the operations of a target of a MultiPersister.
+type TargetStats struct {
+	Name     string
+	Calls    int64
+	Failures int64
+}
+
+// persistTarget is a named target of a MultiPersister.
+type persistTarget struct {
+	name      string
+	persister Persister
+
+	calls, failures atomic.Int64
+}
+
+// MultiPersister implements Persister by fanning out every operation to several
+// persisters, e.g. PostgreSQL for reads plus a Kafka stream and an S3 archive.
+// Under FanOutBestEffort the failure of a target does not prevent the write to
+// the others.
+type MultiPersister struct {
+	logger  log.Logger
+	targets []*persistTarget
+	mode    FanOutMode
+}
+
+// MultiPersisterOption configures the MultiPersister.
+type MultiPersisterOption func(*MultiPersister)
+
+// WithTarget adds p as a target named name, in the errors and stats. Targets
+// are written in the order they are added.
+func WithTarget(name string, p Persister) MultiPersisterOption {
+	return func(m *MultiPersister) {
+		m.targets = append(m.targets, &persistTarget{name: name, persister: p})
+	}
+}
+
+// WithFanOutMode sets how the failures of the targets are reported.
+// Default is FanOutBestEffort.
+func WithFanOutMode(mode FanOutMode) MultiPersisterOption {
+	return func(m *MultiPersister) { m.mode = mode }
+}
+
+// WithMultiPersisterLogger sets the optional logger for the MultiPersister.
+func WithMultiPersisterLogger(logger log.Logger) MultiPersisterOption {
+	return func(m *MultiPersister) { m.logger = logger }
+}
+
+// NewMultiPersister creates a MultiPersister from its targets.
+// Returns an error without targets, or if a target is nil or its name is taken.
+func NewMultiPersister(opts ...MultiPersisterOption) (*MultiPersister, error) {
+	m := &MultiPersister{}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if m.logger == nil {
+		m.logger = discardlog.NewDiscardLog()
+	}
+
+	if len(m.targets) == 0 {
+		return nil, ErrNoTargets
+	}
+	names := make(map[string]bool, len(m.targets))
+	for _, t := range m.targets {
+		if t.persister == nil {
+			return nil, fmt.Errorf("persister %q cannot be nil", t.name)
+		}
+		if names[t.name] {
+			return nil, fmt.Errorf("duplicate persister %q", t.name)
+		}
+		names[t.name] = true
+	}
+
+	m.logger.Infof("multi persister created: targets=%d, mode=%d", len(m.targets), m.mode)
+
+	return m, nil
+}
+
+// Stats returns the calls and failures of each target, in order.
+func (m *MultiPersister) Stats() []TargetStats {
+	stats := make([]TargetStats, len(m.targets))
+	for i, t := range m.targets {
+		stats[i] = TargetStats{Name: t.name, Calls: t.calls.Load(), Failures: t.failures.Load()}
+	}
+	return stats
+}
+
+// PersistSession saves or updates a session in every target.
+func (m *MultiPersister) PersistSession(ctx context.Context, sess session.Session) error {
+	if m.mode == FanOutAllOrNothing {
+		return m.persistSessionOrRollback(ctx, sess)
+	}
+	return m.fanOut(OpPersistSession, func(p Persister) error {
+		return p.PersistSession(ctx, sess)
+	})
+}
+
+// PersistEvent saves an event in every target.
+func (m *MultiPersister) PersistEvent(ctx context.Context, sess session.Session, evt *session.Event) error {
+	return m.fanOut(OpPersistEvent, func(p Persister) error {
+		return p.PersistEvent(ctx, sess, evt)
+	})
+}
+
+// DeleteSession removes a session and its events from every target.
+func (m *MultiPersister) DeleteSession(ctx context.Context, appName, userID, sessionID string) error {
+	return m.fanOut(OpDeleteSession, func(p Persister) error {
+		return p.DeleteSession(ctx, appName, userID, sessionID)
+	})
+}
+
+// Close closes every target, and returns the errors of those failing to close.
+func (m *MultiPersister) Close() error {
+	var errs []error
+	for _, t := range m.targets {
+		if err := t.persister.Close(); err != nil {
+			errs = append(errs, &TargetError{Target: t.name, Err: err})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fanOut runs op on the targets as the mode of m requires.
+func (m *MultiPersister) fanOut(op string, fn func(Persister) error) error {
+	if m.mode == FanOutAllOrNothing {
+		for _, t := range m.targets {
+			if err := m.call(t, op, fn); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	errs := make([]error, len(m.targets))
+	var wg sync.WaitGroup
+	for i, t := range m.targets {
+		wg.Go(func() { errs[i] = m.call(t, op, fn) })
+	}
+	wg.Wait()
+
+	// NOTE: The write succeeds as long as one target has it
+	for _, err := range errs {
+		if err == nil {
+			return nil
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// persistSessionOrRollback persists sess in the targets in order. When a target
+// fails, sess is deleted from the targets written before that did not store it,
+// and the errors of this rollback are joined to the error of the target.
+func (m *MultiPersister) persistSessionOrRollback(ctx context.Context, sess session.Session) error {
+	var created []*persistTarget
+	for _, t := range m.targets {
+		isNew := m.isNewSession(ctx, t, sess)
+
+		err := m.call(t, OpPersistSession, func(p Persister) error {
+			return p.PersistSession(ctx, sess)
+		})
+		if err != nil {
+			return errors.Join(err, m.rollbackSession(ctx, created, sess))
+		}
+		if isNew {
+			created = append(created, t)
+		}
+	}
+	return nil
+}
+
+// isNewSession reports whether target t does not store sess yet. Targets that do
+// not implement Loader, or fail to read, are assumed to store it, so that a
+// rollback never deletes a session they had.
+func (m *MultiPersister) isNewSession(ctx context.Context, t *persistTarget, sess session.Session) bool {
+	loader, ok := t.persister.(Loader)
+	if !ok {
+		return false
+	}
+
+	stored, err := loader.LoadSession(ctx, sess.AppName(), sess.UserID(), sess.ID())
+	if err != nil {
+		m.logger.Warnf("failed to check session %s on persister %s: %v", sess.ID(), t.name, err)
+		return false
+	}
+	return stored == nil
+}
+
+// rollbackSession deletes sess from the targets it was created in, last first.
+func (m *MultiPersister) rollbackSession(ctx context.Context, created []*persistTarget, sess session.Session) error {
+	var errs []error
+	for _, t := range slices.Backward(created) {
+		err := m.call(t, OpDeleteSession, func(p Persister) error {
+			if err := p.DeleteSession(ctx, sess.AppName(), sess.UserID(), sess.ID()); err != nil {
+				return fmt.Errorf("failed to roll back session %s: %w", sess.ID(), err)
+			}
+			return nil
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// call runs op on target t, isolating the failure, or panic, of t.
+func (m *MultiPersister) call(t *persistTarget, op string, fn func(Persister) error) (err error) {
+	t.calls.Add(1)
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+		if err != nil {
+			t.failures.Add(1)
+			m.logger.Warnf("%s failed on persister %s: %v", op, t.name, err)
+			err = &TargetError{Target: t.name, Err: err}
+		}
+	}()
+
+	return fn(t.persister)
+}
+
+// Ping pings the targets implementing HealthChecker. As the writes, it fails
+// under FanOutBestEffort only if every target checked fails, and under
+// FanOutAllOrNothing if any does.
+func (m *MultiPersister) Ping(ctx context.Context) error {
+	return m.checkHealth(ctx, HealthChecker.Ping)
+}
+
+// Healthy checks the health of the targets implementing HealthChecker, failing
+// as Ping does.
+func (m *MultiPersister) Healthy(ctx context.Context) error {
+	return m.checkHealth(ctx, HealthChecker.Healthy)
+}
+
+// checkHealth runs check on the targets implementing HealthChecker.
+func (m *MultiPersister) checkHealth(ctx context.Context, check func(HealthChecker, context.Context) error) error {
+	var (
+		errs    []error
+		checked int
+	)
+	for _, t := range m.targets {
+		hc, ok := t.persister.(HealthChecker)
+		if !ok {
+			continue
+		}
+		checked++
+		if err := check(hc, ctx); err != nil {
+			errs = append(errs, &TargetError{Target: t.name, Err: err})
+		}
+	}
+
+	if m.mode == FanOutBestEffort && len(errs) < checked {
+		return nil
+	}
+	return errors.Join(errs...)
+}
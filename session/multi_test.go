@@ -0,0 +1,196 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	"google.golang.org/adk/session"
+)
+
+// recordingPersister records the sessions persisted, or fails with err.
+type recordingPersister struct {
+	mu       sync.Mutex
+	sessions []string
+	err      error
+	closed   bool
+}
+
+func (p *recordingPersister) PersistSession(_ context.Context, sess session.Session) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sessions = append(p.sessions, sess.ID())
+	return nil
+}
+
+func (p *recordingPersister) PersistEvent(context.Context, session.Session, *session.Event) error {
+	return p.err
+}
+
+func (p *recordingPersister) DeleteSession(context.Context, string, string, string) error {
+	panic("delete not supported")
+}
+
+func (p *recordingPersister) Close() error {
+	p.closed = true
+	return p.err
+}
+
+// loadingPersister is a recordingPersister implementing Loader, whose deletions
+// are recorded, or fail with deleteErr.
+type loadingPersister struct {
+	recordingPersister
+	deleted   []string
+	deleteErr error
+}
+
+func (p *loadingPersister) LoadSession(_ context.Context, _, _, sessionID string) (*StoredSession, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !slices.Contains(p.sessions, sessionID) {
+		return nil, nil
+	}
+	return &StoredSession{ID: sessionID}, nil
+}
+
+func (p *loadingPersister) ListSessionIDs(context.Context, string, string) ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.sessions), nil
+}
+
+func (p *loadingPersister) DeleteSession(_ context.Context, _, _, sessionID string) error {
+	if p.deleteErr != nil {
+		return p.deleteErr
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sessions = slices.DeleteFunc(p.sessions, func(id string) bool { return id == sessionID })
+	p.deleted = append(p.deleted, sessionID)
+	return nil
+}
+
+func TestNewMultiPersister(t *testing.T) {
+	if _, err := NewMultiPersister(); !errors.Is(err, ErrNoTargets) {
+		t.Errorf("expected ErrNoTargets, got %v", err)
+	}
+	if _, err := NewMultiPersister(WithTarget("pg", nil)); err == nil {
+		t.Error("expected an error for a nil target")
+	}
+	p := &recordingPersister{}
+	if _, err := NewMultiPersister(WithTarget("pg", p), WithTarget("pg", p)); err == nil {
+		t.Error("expected an error for a duplicate target")
+	}
+}
+
+func TestMultiPersister(t *testing.T) {
+	ctx := context.Background()
+	resp, err := session.InMemoryService().Create(ctx, &session.CreateRequest{
+		AppName: "app", UserID: "user", SessionID: "multi-1",
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	sess := resp.Session
+	errDown := errors.New("target down")
+
+	t.Run("best effort", func(t *testing.T) {
+		pg, kafka := &recordingPersister{}, &recordingPersister{err: errDown}
+		m, err := NewMultiPersister(WithTarget("pg", pg), WithTarget("kafka", kafka))
+		if err != nil {
+			t.Fatalf("NewMultiPersister failed: %v", err)
+		}
+
+		if err := m.PersistSession(ctx, sess); err != nil {
+			t.Errorf("PersistSession failed: %v", err)
+		}
+		if len(pg.sessions) != 1 {
+			t.Errorf("expected the session in pg, got %v", pg.sessions)
+		}
+
+		// NOTE: DeleteSession panics in every target
+		err = m.DeleteSession(ctx, "app", "user", "multi-1")
+		var targetErr *TargetError
+		if !errors.As(err, &targetErr) {
+			t.Errorf("expected a TargetError when every target fails, got %v", err)
+		}
+
+		stats := m.Stats()
+		if stats[0].Calls != 2 || stats[0].Failures != 1 || stats[1].Failures != 2 {
+			t.Errorf("unexpected stats: %+v", stats)
+		}
+
+		if err := m.Close(); !errors.Is(err, errDown) || !pg.closed || !kafka.closed {
+			t.Errorf("expected every target closed, got %v", err)
+		}
+	})
+
+	t.Run("all or nothing", func(t *testing.T) {
+		pg, kafka, archive := &recordingPersister{}, &recordingPersister{err: errDown}, &recordingPersister{}
+		m, err := NewMultiPersister(
+			WithTarget("pg", pg), WithTarget("kafka", kafka), WithTarget("archive", archive),
+			WithFanOutMode(FanOutAllOrNothing),
+		)
+		if err != nil {
+			t.Fatalf("NewMultiPersister failed: %v", err)
+		}
+
+		err = m.PersistSession(ctx, sess)
+		var targetErr *TargetError
+		if !errors.As(err, &targetErr) || targetErr.Target != "kafka" || !errors.Is(err, errDown) {
+			t.Fatalf("expected the kafka error, got %v", err)
+		}
+		if len(pg.sessions) != 1 || len(archive.sessions) != 0 {
+			t.Errorf("expected the session in pg only, got pg=%v archive=%v", pg.sessions, archive.sessions)
+		}
+	})
+
+	t.Run("all or nothing rolls back a creation", func(t *testing.T) {
+		pg := &loadingPersister{}
+		stored := &loadingPersister{recordingPersister: recordingPersister{sessions: []string{sess.ID()}}}
+		kafka := &recordingPersister{err: errDown}
+		m, err := NewMultiPersister(
+			WithTarget("pg", pg), WithTarget("stored", stored), WithTarget("kafka", kafka),
+			WithFanOutMode(FanOutAllOrNothing),
+		)
+		if err != nil {
+			t.Fatalf("NewMultiPersister failed: %v", err)
+		}
+
+		if err := m.PersistSession(ctx, sess); !errors.Is(err, errDown) {
+			t.Fatalf("expected the kafka error, got %v", err)
+		}
+		if len(pg.sessions) != 0 || !slices.Equal(pg.deleted, []string{sess.ID()}) {
+			t.Errorf("expected the session rolled back from pg, got sessions=%v deleted=%v", pg.sessions, pg.deleted)
+		}
+		// NOTE: The session was stored before the write, so it is kept
+		if len(stored.deleted) != 0 {
+			t.Errorf("expected the stored session kept, got deleted=%v", stored.deleted)
+		}
+	})
+
+	t.Run("all or nothing reports a failed rollback", func(t *testing.T) {
+		errRollback := errors.New("delete failed")
+		pg := &loadingPersister{deleteErr: errRollback}
+		m, err := NewMultiPersister(
+			WithTarget("pg", pg), WithTarget("kafka", &recordingPersister{err: errDown}),
+			WithFanOutMode(FanOutAllOrNothing),
+		)
+		if err != nil {
+			t.Fatalf("NewMultiPersister failed: %v", err)
+		}
+
+		err = m.PersistSession(ctx, sess)
+		if !errors.Is(err, errDown) || !errors.Is(err, errRollback) {
+			t.Fatalf("expected the kafka and rollback errors, got %v", err)
+		}
+		if stats := m.Stats(); stats[0].Failures != 1 {
+			t.Errorf("expected the rollback failure counted, got %+v", stats)
+		}
+	})
+}
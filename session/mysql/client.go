@@ -0,0 +1,303 @@
+// Package mysql provides a session Persister and Loader on MySQL (5.7.8 or
+// later) and MariaDB (10.2.7 or later), a port of the PostgreSQL persister for
+// deployments standardized on MySQL. Session states, tags and events are stored in
+// JSON columns, and the events in tables sharded by user, whose primary keys keep
+// the events of a session together in the clustered index of InnoDB.
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/log"
+)
+
+const (
+	defaultConnMaxIdleTime = 10 * time.Minute
+	defaultConnMaxLifetime = 30 * time.Minute
+	defaultMaxOpenConns    = 25
+	defaultMaxIdleConns    = 10
+	defaultPingRetries     = 3
+	defaultPingTimeout     = 3 * time.Second
+	defaultShardCount      = 8
+
+	defaultSessionsTable     = "sessions"
+	defaultEventsTablePrefix = "session_events_"
+)
+
+// validIdentifier matches the table names of Config, which are inlined unquoted
+// in the queries.
+var validIdentifier = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// Config holds MySQL connection configuration for session persistence.
+type Config struct {
+	// DSN is the data source name of go-sql-driver/mysql. The times are read and
+	// written in UTC, whatever its parseTime and loc parameters.
+	//
+	// e.g., "user:pass@tcp(localhost:3306)/dbname"
+	DSN string `mapstructure:"dsn"`
+
+	// Connection pool settings.
+	MaxOpenConns    int           `mapstructure:"max_open_conns"`
+	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
+	ConnMaxIdleTime time.Duration `mapstructure:"conn_max_idle_time"`
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
+
+	// PingRetries is the number of ping retries during connection validation.
+	PingRetries int `mapstructure:"ping_retries"`
+	// PingTimeout is the timeout for each ping attempt.
+	PingTimeout time.Duration `mapstructure:"ping_timeout"`
+
+	// ShardCount is the number of table shards for events.
+	// Events are distributed across shards based on user_id hash.
+	// Must be a power of 2 (e.g., 4, 8, 16). Default: 8
+	ShardCount int `mapstructure:"shard_count"`
+
+	// Optional. SessionsTable is the name of the sessions table. Names are
+	// lowercase letters, digits and underscores. Default: "sessions".
+	SessionsTable string `mapstructure:"sessions_table"`
+
+	// Optional. EventsTablePrefix prefixes the shard number in the names of the
+	// events tables. Default: "session_events_".
+	EventsTablePrefix string `mapstructure:"events_table_prefix"`
+
+	// Logger is an optional custom logger. If nil, DiscardLog will be used.
+	Logger log.Logger `mapstructure:"-"`
+}
+
+func (c *Config) String() string {
+	maskedDSN := "[REDACTED]"
+	if c.DSN == "" {
+		maskedDSN = "(empty)"
+	}
+
+	return fmt.Sprintf(
+		"MySQLConfig ==> DSN: %s, MaxOpenConns: %d, MaxIdleConns: %d, ConnMaxIdleTime: %s, "+
+			"ConnMaxLifetime: %s, PingRetries: %d, PingTimeout: %s, ShardCount: %d, "+
+			"SessionsTable: %s, EventsTablePrefix: %s",
+		maskedDSN, c.MaxOpenConns, c.MaxIdleConns, c.ConnMaxIdleTime,
+		c.ConnMaxLifetime, c.PingRetries, c.PingTimeout, c.ShardCount,
+		c.SessionsTable, c.EventsTablePrefix)
+}
+
+// DefaultConfig returns a Config with default values.
+func DefaultConfig() *Config {
+	return &Config{
+		DSN:               "",
+		MaxOpenConns:      defaultMaxOpenConns,
+		MaxIdleConns:      defaultMaxIdleConns,
+		ConnMaxIdleTime:   defaultConnMaxIdleTime,
+		ConnMaxLifetime:   defaultConnMaxLifetime,
+		PingRetries:       defaultPingRetries,
+		PingTimeout:       defaultPingTimeout,
+		ShardCount:        defaultShardCount,
+		SessionsTable:     defaultSessionsTable,
+		EventsTablePrefix: defaultEventsTablePrefix,
+		Logger:            discardlog.NewDiscardLog(),
+	}
+}
+
+// Client wraps a MySQL database connection for session persistence.
+type Client struct {
+	db         *sql.DB
+	logger     log.Logger
+	shardCount int
+
+	// sessionsTable and eventsTablePrefix name the tables
+	sessionsTable     string
+	eventsTablePrefix string
+}
+
+// NewMySQLClient creates a new MySQL client with the given configuration.
+// The caller is responsible for closing the client when done.
+func NewMySQLClient(ctx context.Context, cfg *Config) (*Client, error) {
+	if cfg == nil {
+		return nil, errors.New("mysql config cannot be nil")
+	}
+
+	if cfg.DSN == "" {
+		return nil, errors.New("mysql DSN cannot be empty")
+	}
+
+	// Apply defaults for zero values
+	pingRetries := cfg.PingRetries
+	if pingRetries <= 0 {
+		pingRetries = defaultPingRetries
+	}
+
+	pingTimeout := cfg.PingTimeout
+	if pingTimeout <= 0 {
+		pingTimeout = defaultPingTimeout
+	}
+
+	shardCount := cfg.ShardCount
+	if shardCount <= 0 {
+		shardCount = defaultShardCount
+	}
+	// Ensure shard count is power of 2
+	if !isPowerOfTwo(shardCount) {
+		shardCount = nextPowerOfTwo(shardCount)
+	}
+
+	sessionsTable := cfg.SessionsTable
+	if sessionsTable == "" {
+		sessionsTable = defaultSessionsTable
+	}
+	eventsTablePrefix := cfg.EventsTablePrefix
+	if eventsTablePrefix == "" {
+		eventsTablePrefix = defaultEventsTablePrefix
+	}
+	for _, name := range []string{sessionsTable, eventsTablePrefix} {
+		if !validIdentifier.MatchString(name) {
+			return nil, fmt.Errorf("invalid mysql table name %q", name)
+		}
+	}
+
+	// Use DiscardLog if no custom logger is provided
+	logger := cfg.Logger
+	if logger == nil {
+		logger = discardlog.NewDiscardLog()
+	}
+
+	db, err := openDB(cfg)
+	if err != nil {
+		logger.Errorf("failed to open mysql connection: %v", err)
+		return nil, fmt.Errorf("failed to open mysql connection: %w", err)
+	}
+
+	// Validate connection with retries
+	var pingErr error
+	for i := range pingRetries {
+		pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+		pingErr = db.PingContext(pingCtx)
+		cancel()
+
+		if pingErr == nil {
+			break
+		}
+
+		logger.Errorf("mysql ping failed (attempt %d/%d): %v",
+			i+1, pingRetries, pingErr)
+
+		if i < pingRetries-1 {
+			// Exponential backoff
+			time.Sleep(time.Duration(i+1) * time.Second)
+		}
+	}
+
+	if pingErr != nil {
+		if closeErr := db.Close(); closeErr != nil {
+			logger.Errorf("failed to close mysql connection: %v", closeErr)
+		}
+		return nil, fmt.Errorf("mysql ping failed after %d retries: %w",
+			pingRetries, pingErr)
+	}
+
+	logger.Info("mysql client initialized successfully")
+
+	return &Client{
+		db:         db,
+		logger:     logger,
+		shardCount: shardCount,
+
+		sessionsTable:     sessionsTable,
+		eventsTablePrefix: eventsTablePrefix,
+	}, nil
+}
+
+// openDB opens the pool of cfg, with the times of the connections in UTC.
+func openDB(cfg *Config) (*sql.DB, error) {
+	dsn, err := mysql.ParseDSN(cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mysql DSN: %w", err)
+	}
+	// NOTE: DATETIME columns hold no time zone, so every connection uses UTC
+	dsn.ParseTime = true
+	dsn.Loc = time.UTC
+
+	connector, err := mysql.NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	db := sql.OpenDB(connector)
+
+	// Configure connection pool
+	if cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+
+	return db, nil
+}
+
+// DB returns the underlying database connection.
+func (c *Client) DB() *sql.DB { return c.db }
+
+// Logger returns the logger instance.
+func (c *Client) Logger() log.Logger { return c.logger }
+
+// ShardCount returns the number of event table shards.
+func (c *Client) ShardCount() int { return c.shardCount }
+
+// Close closes the database connection.
+func (c *Client) Close() error {
+	if c.db == nil {
+		return nil
+	}
+	return c.db.Close()
+}
+
+// GetShardIndex calculates the shard index for a given user ID.
+// Uses FNV-1a hash for consistent distribution.
+func (c *Client) GetShardIndex(userID string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(userID))
+	return int(h.Sum32()) & (c.shardCount - 1) // Bitwise AND for power-of-2 modulo
+}
+
+// GetEventsTableName returns the sharded events table name for a user.
+func (c *Client) GetEventsTableName(userID string) string {
+	return c.EventsTableName(c.GetShardIndex(userID))
+}
+
+// EventsTableName returns the name of the events table of a shard.
+func (c *Client) EventsTableName(shard int) string {
+	return fmt.Sprintf("%s%d", c.eventsTablePrefix, shard)
+}
+
+// SessionsTableName returns the name of the sessions table.
+func (c *Client) SessionsTableName() string { return c.sessionsTable }
+
+// isPowerOfTwo checks if n is a power of 2.
+func isPowerOfTwo(n int) bool {
+	return n > 0 && (n&(n-1)) == 0
+}
+
+// nextPowerOfTwo returns the next power of 2 >= n.
+func nextPowerOfTwo(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	n--
+	n |= n >> 1
+	n |= n >> 2
+	n |= n >> 4
+	n |= n >> 8
+	n |= n >> 16
+	return n + 1
+}
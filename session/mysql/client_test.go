@@ -0,0 +1,82 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestNewMySQLClientValidation(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		cfg  *Config
+	}{
+		{"nil config", nil},
+		{"empty DSN", &Config{}},
+		{"invalid DSN", &Config{DSN: "not a dsn"}},
+		{"invalid sessions table", &Config{DSN: "u:p@tcp(localhost:3306)/db", SessionsTable: "Sessions; DROP"}},
+		{"invalid events prefix", &Config{DSN: "u:p@tcp(localhost:3306)/db", EventsTablePrefix: "events-"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewMySQLClient(ctx, tt.cfg); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestConfigStringRedactsDSN(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DSN = "root:secret@tcp(localhost:3306)/db"
+
+	if s := cfg.String(); strings.Contains(s, "secret") || !strings.Contains(s, "[REDACTED]") {
+		t.Errorf("DSN not redacted: %s", s)
+	}
+}
+
+func TestShardIndex(t *testing.T) {
+	client := &Client{shardCount: 8, eventsTablePrefix: defaultEventsTablePrefix}
+
+	for i := range 100 {
+		userID := fmt.Sprintf("user-%d", i)
+		idx := client.GetShardIndex(userID)
+		if idx < 0 || idx >= 8 {
+			t.Fatalf("shard index %d out of range", idx)
+		}
+		if idx != client.GetShardIndex(userID) {
+			t.Fatal("shard index not stable")
+		}
+		if want := fmt.Sprintf("session_events_%d", idx); client.GetEventsTableName(userID) != want {
+			t.Errorf("events table = %s, want %s", client.GetEventsTableName(userID), want)
+		}
+	}
+
+	for n, want := range map[int]int{0: 1, 1: 1, 3: 4, 8: 8, 9: 16} {
+		if got := nextPowerOfTwo(n); got != want {
+			t.Errorf("nextPowerOfTwo(%d) = %d, want %d", n, got, want)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&mysql.MySQLError{Number: errLockDeadlock}, true},
+		{fmt.Errorf("insert: %w", &mysql.MySQLError{Number: errLockWaitTimeout}), true},
+		{&mysql.MySQLError{Number: 1062}, false}, // Duplicate entry
+		{context.Canceled, false},
+	}
+	for _, tt := range tests {
+		if got := isRetryable(tt.err); got != tt.want {
+			t.Errorf("isRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
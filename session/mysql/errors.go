@@ -0,0 +1,34 @@
+package mysql
+
+import (
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+var (
+	// ErrPersisterClosed is returned by the operations of a closed SessionPersister.
+	ErrPersisterClosed = errors.New("persister is closed")
+
+	// ErrSessionMissing is returned when the missing session of an event cannot be
+	// stored with it. SessionPersister stores the session of an event when it is
+	// not in MySQL, e.g. because persisting the session failed.
+	ErrSessionMissing = errors.New("session missing")
+)
+
+// MySQL error numbers of the transactions rolled back by InnoDB, which may
+// succeed if run again.
+const (
+	errLockWaitTimeout = 1205
+	errLockDeadlock    = 1213
+)
+
+// isRetryable reports whether err rolled back a transaction on a lock conflict
+// with another one.
+func isRetryable(err error) bool {
+	var myErr *mysql.MySQLError
+	if !errors.As(err, &myErr) {
+		return false
+	}
+	return myErr.Number == errLockDeadlock || myErr.Number == errLockWaitTimeout
+}
@@ -0,0 +1,47 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+
+	ksess "github.com/kydenul/k-adk/session"
+)
+
+var _ ksess.HealthChecker = (*SessionPersister)(nil)
+
+// Ping checks that MySQL is reachable. It waits for a connection of the pool,
+// so a pool exhausted for longer than the deadline of ctx fails it.
+func (c *Client) Ping(ctx context.Context) error {
+	if err := c.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("mysql ping failed: %w", err)
+	}
+	return nil
+}
+
+// Ping checks that MySQL is reachable, see Client.Ping.
+func (p *SessionPersister) Ping(ctx context.Context) error {
+	return p.client.Ping(ctx)
+}
+
+// Healthy checks that the persister is open, MySQL reachable and the async
+// queues not saturated: it fails with ksess.ErrQueueSaturated when they are
+// ksess.QueueSaturation full.
+func (p *SessionPersister) Healthy(ctx context.Context) error {
+	p.mu.RLock()
+	closed := p.closed
+	p.mu.RUnlock()
+	if closed {
+		return ErrPersisterClosed
+	}
+
+	if err := p.Ping(ctx); err != nil {
+		return err
+	}
+
+	var depth, capacity int
+	for _, queue := range p.asyncChans {
+		depth += len(queue)
+		capacity += cap(queue)
+	}
+	return ksess.CheckQueue(depth, capacity)
+}
@@ -0,0 +1,243 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bytedance/sonic"
+	ksess "github.com/kydenul/k-adk/session"
+	"google.golang.org/adk/session"
+)
+
+var _ ksess.RecentLoader = (*SessionPersister)(nil)
+
+// LoadSession reads a persisted session and its events, in order. It returns
+// nil, nil if the session is not stored.
+func (p *SessionPersister) LoadSession(
+	ctx context.Context,
+	appName, userID, sessionID string,
+) (*ksess.StoredSession, error) {
+	stored := &ksess.StoredSession{
+		ID:      sessionID,
+		AppName: appName,
+		UserID:  userID,
+		State:   map[string]any{},
+	}
+
+	var (
+		stateJSON, tagsJSON []byte
+		ttlSeconds          int64
+	)
+	//nolint:gosec // table name is validated by the client
+	err := p.client.DB().QueryRowContext(ctx, `
+		SELECT state, tags, last_update_time, parent_id, fork_event_id, ttl_seconds
+		FROM `+p.client.SessionsTableName()+`
+		WHERE app_name = ? AND user_id = ? AND id = ?
+	`, appName, userID, sessionID).Scan(&stateJSON, &tagsJSON, &stored.LastUpdateTime,
+		&stored.ParentID, &stored.ForkEventID, &ttlSeconds)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		p.logger.Errorf("failed to load session %s: %v", sessionID, err)
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+	stored.TTL = time.Duration(ttlSeconds) * time.Second
+	if len(stateJSON) > 0 {
+		if err := sonic.Unmarshal(stateJSON, &stored.State); err != nil {
+			p.logger.Warnf("failed to unmarshal session state: session=%s, err=%v", sessionID, err)
+		}
+	}
+	if len(tagsJSON) > 0 {
+		if err := sonic.Unmarshal(tagsJSON, &stored.Tags); err != nil {
+			p.logger.Warnf("failed to unmarshal session tags: session=%s, err=%v", sessionID, err)
+		}
+		if len(stored.Tags) == 0 {
+			stored.Tags = nil
+		}
+	}
+
+	stored.Events, err = p.loadEvents(ctx, appName, userID, sessionID)
+	if err != nil {
+		p.logger.Errorf("failed to load events of session %s: %v", sessionID, err)
+		return nil, err
+	}
+
+	p.logger.Debugf("session loaded: session=%s, events=%d", sessionID, len(stored.Events))
+
+	return stored, nil
+}
+
+// loadEvents reads the events of a session in order, a range of the primary key
+// of its shard.
+func (p *SessionPersister) loadEvents(
+	ctx context.Context,
+	appName, userID, sessionID string,
+) ([]*session.Event, error) {
+	tableName := p.client.GetEventsTableName(userID)
+	//nolint:gosec // table name is generated internally
+	rows, err := p.client.DB().QueryContext(ctx, `
+		SELECT content FROM `+tableName+`
+		WHERE app_name = ? AND user_id = ? AND session_id = ?
+		ORDER BY event_order
+	`, appName, userID, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session events from %s: %w", tableName, err)
+	}
+	defer rows.Close()
+
+	var events []*session.Event
+	for rows.Next() {
+		var content []byte
+		if err := rows.Scan(&content); err != nil {
+			return nil, fmt.Errorf("failed to scan session event: %w", err)
+		}
+
+		var evt session.Event
+		if err := sonic.Unmarshal(content, &evt); err != nil {
+			p.logger.Warnf("failed to unmarshal event of session %s: %v", sessionID, err)
+			continue
+		}
+		events = append(events, &evt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate session events: %w", err)
+	}
+
+	return events, nil
+}
+
+// ListSessionIDs returns the IDs of the persisted sessions of a user, most
+// recently updated first.
+func (p *SessionPersister) ListSessionIDs(ctx context.Context, appName, userID string) ([]string, error) {
+	//nolint:gosec // table name is validated by the client
+	rows, err := p.client.DB().QueryContext(ctx, `
+		SELECT id FROM `+p.client.SessionsTableName()+`
+		WHERE app_name = ? AND user_id = ?
+		ORDER BY last_update_time DESC
+	`, appName, userID)
+	if err != nil {
+		p.logger.Errorf("failed to list sessions of user %s: %v", userID, err)
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan session ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate sessions: %w", err)
+	}
+
+	return ids, nil
+}
+
+// ListRecentSessions returns up to limit sessions of appName updated at or after
+// since, most recently updated first, starting after the cursor.
+func (p *SessionPersister) ListRecentSessions(
+	ctx context.Context,
+	appName string,
+	since time.Time,
+	after *ksess.SessionRef,
+	limit int,
+) ([]ksess.SessionRef, error) {
+	sessionsTable := p.client.SessionsTableName()
+	//nolint:gosec // table name is validated by the client
+	query := `
+		SELECT user_id, id, last_update_time FROM ` + sessionsTable + `
+		WHERE app_name = ? AND last_update_time >= ?
+		ORDER BY last_update_time DESC, user_id DESC, id DESC
+		LIMIT ?
+	`
+	args := []any{appName, since, limit}
+	if after != nil {
+		//nolint:gosec // table name is validated by the client
+		query = `
+			SELECT user_id, id, last_update_time FROM ` + sessionsTable + `
+			WHERE app_name = ? AND last_update_time >= ?
+				AND (last_update_time, user_id, id) < (?, ?, ?)
+			ORDER BY last_update_time DESC, user_id DESC, id DESC
+			LIMIT ?
+		`
+		args = []any{appName, since, after.LastUpdateTime, after.UserID, after.ID, limit}
+	}
+
+	rows, err := p.client.DB().QueryContext(ctx, query, args...)
+	if err != nil {
+		p.logger.Errorf("failed to list recent sessions of app %s: %v", appName, err)
+		return nil, fmt.Errorf("failed to list recent sessions: %w", err)
+	}
+	defer rows.Close()
+
+	return scanSessionRefs(rows, appName)
+}
+
+// FindSessions returns the persisted sessions of appName having all the given
+// tags, with the same values, most recently updated first. An empty userID finds
+// the sessions of every user. The tags are matched with JSON_CONTAINS, which
+// reads every session of the app: MySQL cannot index a JSON object.
+func (p *SessionPersister) FindSessions(
+	ctx context.Context,
+	appName, userID string,
+	tags map[string]string,
+) ([]ksess.SessionRef, error) {
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	tagsJSON, err := sonic.MarshalString(tags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal tags: %w", err)
+	}
+
+	sessionsTable := p.client.SessionsTableName()
+	//nolint:gosec // table name is validated by the client
+	query := `
+		SELECT user_id, id, last_update_time FROM ` + sessionsTable + `
+		WHERE app_name = ? AND JSON_CONTAINS(tags, ?)
+		ORDER BY last_update_time DESC
+	`
+	args := []any{appName, tagsJSON}
+	if userID != "" {
+		//nolint:gosec // table name is validated by the client
+		query = `
+			SELECT user_id, id, last_update_time FROM ` + sessionsTable + `
+			WHERE app_name = ? AND JSON_CONTAINS(tags, ?) AND user_id = ?
+			ORDER BY last_update_time DESC
+		`
+		args = append(args, userID)
+	}
+
+	rows, err := p.client.DB().QueryContext(ctx, query, args...)
+	if err != nil {
+		p.logger.Errorf("failed to find sessions of app %s by tags: %v", appName, err)
+		return nil, fmt.Errorf("failed to find sessions: %w", err)
+	}
+	defer rows.Close()
+
+	return scanSessionRefs(rows, appName)
+}
+
+// scanSessionRefs scans rows of user_id, id and last_update_time.
+func scanSessionRefs(rows *sql.Rows, appName string) ([]ksess.SessionRef, error) {
+	var refs []ksess.SessionRef
+	for rows.Next() {
+		ref := ksess.SessionRef{AppName: appName}
+		if err := rows.Scan(&ref.UserID, &ref.ID, &ref.LastUpdateTime); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		refs = append(refs, ref)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate sessions: %w", err)
+	}
+
+	return refs, nil
+}
@@ -0,0 +1,591 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	ksess "github.com/kydenul/k-adk/session"
+	"github.com/kydenul/log"
+	"google.golang.org/adk/session"
+)
+
+var _ ksess.Persister = (*SessionPersister)(nil)
+
+// Default configuration values.
+const (
+	defaultAsyncBufferSize = 1000
+	defaultAsyncWorkers    = 1
+	defaultAsyncOpTimeout  = 30 * time.Second
+
+	// maxTxAttempts bounds the attempts of a transaction rolled back on a deadlock.
+	maxTxAttempts = 3
+
+	operationSession = "session"
+	operationEvent   = "event"
+	operationDelete  = "delete"
+)
+
+// SessionPersister implements Persister for MySQL session persistence.
+// It is designed to work alongside RedisSessionService as a long-term storage backend.
+type SessionPersister struct {
+	client *Client
+
+	logger log.Logger
+	wg     sync.WaitGroup
+	closed bool
+	mu     sync.RWMutex
+
+	// asyncChans holds the queue of each async worker, nil in sync mode.
+	asyncChans      []chan asyncOperation
+	asyncBufferSize int
+	asyncWorkers    int
+}
+
+type asyncOperation struct {
+	operationType string // "session", "event", "delete"
+	sess          session.Session
+	evt           *session.Event
+	appName       string
+	userID        string
+	sessionID     string
+}
+
+// key returns the IDs of the session of op.
+func (op asyncOperation) key() (appName, userID, sessionID string) {
+	if op.sess != nil {
+		return op.sess.AppName(), op.sess.UserID(), op.sess.ID()
+	}
+	return op.appName, op.userID, op.sessionID
+}
+
+// PersisterOption configures the SessionPersister.
+type PersisterOption func(*SessionPersister)
+
+// WithAsyncBufferSize sets the buffer size for async operations, per worker.
+// Default is 1000. Set to 0 to disable async mode (all operations are synchronous).
+func WithAsyncBufferSize(size int) PersisterOption {
+	return func(p *SessionPersister) {
+		p.asyncBufferSize = max(size, 0)
+	}
+}
+
+// WithAsyncWorkers sets the number of workers writing async operations.
+// Operations are routed to a worker by session, so the operations of a session
+// are still written in order while different sessions are written in parallel.
+// Default is 1.
+func WithAsyncWorkers(n int) PersisterOption {
+	return func(p *SessionPersister) {
+		p.asyncWorkers = max(n, 1)
+	}
+}
+
+// NewSessionPersister creates a new MySQL session persister, and its tables if
+// needed.
+func NewSessionPersister(
+	ctx context.Context,
+	client *Client,
+	opts ...PersisterOption,
+) (*SessionPersister, error) {
+	if client == nil {
+		return nil, errors.New("mysql client cannot be nil")
+	}
+
+	logger := client.Logger()
+	if logger == nil {
+		logger = discardlog.NewDiscardLog()
+	}
+
+	p := &SessionPersister{
+		client:          client,
+		logger:          logger,
+		asyncBufferSize: defaultAsyncBufferSize,
+		asyncWorkers:    defaultAsyncWorkers,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	// Initialize database schema
+	if err := p.initSchema(ctx); err != nil {
+		return nil, fmt.Errorf("failed to initialize schema: %w", err)
+	}
+
+	// Start async workers if async mode is enabled
+	if p.asyncBufferSize > 0 {
+		p.asyncChans = make([]chan asyncOperation, p.asyncWorkers)
+		for i := range p.asyncChans {
+			p.asyncChans[i] = make(chan asyncOperation, p.asyncBufferSize)
+			p.wg.Add(1)
+			go p.asyncWorker(p.asyncChans[i]) //nolint:contextcheck // async worker manages its own context
+		}
+	}
+
+	logger.Info("MySQL session persister initialized")
+
+	return p, nil
+}
+
+// tableOptions are the options of the tables. The binary collation compares the
+// IDs as PostgreSQL does, case and accent sensitive.
+const tableOptions = `ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin`
+
+// initSchema creates the sessions table and the events shard tables.
+//
+// NOTE: The primary keys fit the 3072 bytes of an InnoDB index key with the
+// DYNAMIC row format, the default since MySQL 5.7.9 and MariaDB 10.2.2: three
+// utf8mb4 VARCHAR(255) IDs take 3060 bytes.
+func (p *SessionPersister) initSchema(ctx context.Context) error {
+	//nolint:gosec // table name is validated by the client
+	sessionsSchema := `
+		CREATE TABLE IF NOT EXISTS ` + p.client.SessionsTableName() + ` (
+			id VARCHAR(255) NOT NULL,
+			app_name VARCHAR(255) NOT NULL,
+			user_id VARCHAR(255) NOT NULL,
+			state JSON NOT NULL,
+			tags JSON NOT NULL,
+			parent_id VARCHAR(255) NOT NULL DEFAULT '',
+			fork_event_id VARCHAR(255) NOT NULL DEFAULT '',
+			ttl_seconds BIGINT NOT NULL DEFAULT 0,
+			next_event_order INT NOT NULL DEFAULT 0,
+			last_update_time DATETIME(6) NOT NULL,
+			created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+			PRIMARY KEY (app_name, user_id, id),
+			KEY idx_user (user_id),
+			KEY idx_last_update (last_update_time),
+			KEY idx_app_last_update (app_name, last_update_time)
+		) ` + tableOptions
+
+	p.logger.Infof("Init Session Schema SQL: %s", sessionsSchema)
+
+	if _, err := p.client.DB().ExecContext(ctx, sessionsSchema); err != nil {
+		p.logger.Errorf("failed to create sessions table: %v", err)
+		return fmt.Errorf("failed to create sessions table: %w", err)
+	}
+
+	// NOTE: The events of a session are contiguous in the clustered primary key, so
+	// loading them reads a range of pages in order
+	for i := range p.client.ShardCount() {
+		//nolint:gosec // table name is generated internally
+		eventsSchema := `
+			CREATE TABLE IF NOT EXISTS ` + p.client.EventsTableName(i) + ` (
+				id VARCHAR(255) NOT NULL,
+				app_name VARCHAR(255) NOT NULL,
+				user_id VARCHAR(255) NOT NULL,
+				session_id VARCHAR(255) NOT NULL,
+				event_order INT NOT NULL,
+				content JSON NOT NULL,
+				author VARCHAR(255),
+				branch VARCHAR(1024) NOT NULL DEFAULT '',
+				timestamp DATETIME(6) NOT NULL,
+				created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+				PRIMARY KEY (app_name, user_id, session_id, event_order),
+				KEY idx_timestamp (timestamp)
+			) ` + tableOptions
+
+		if _, err := p.client.DB().ExecContext(ctx, eventsSchema); err != nil {
+			p.logger.Errorf("failed to create events shard table %d: %v", i, err)
+			return fmt.Errorf("failed to create events shard table %d: %w", i, err)
+		}
+	}
+
+	p.logger.Infof("schema initialized with %d event shards", p.client.ShardCount())
+
+	return nil
+}
+
+// asyncWorker processes async operations from the channel.
+func (p *SessionPersister) asyncWorker(ops <-chan asyncOperation) {
+	defer p.wg.Done()
+
+	for op := range ops {
+		p.processAsyncOp(op)
+	}
+}
+
+// enqueue queues op on the worker of its session, waiting while its queue is
+// full. It reports false in sync mode, for op to be run synchronously.
+func (p *SessionPersister) enqueue(ctx context.Context, op asyncOperation) (bool, error) {
+	if p.asyncChans == nil {
+		return false, nil
+	}
+
+	// NOTE: Close waits for the blocked senders before closing the queues
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return false, ErrPersisterClosed
+	}
+
+	select {
+	case p.asyncChans[p.workerIndex(op)] <- op:
+		return true, nil
+
+	case <-ctx.Done():
+		return false, fmt.Errorf("failed to queue async %s operation: %w", op.operationType, ctx.Err())
+	}
+}
+
+// workerIndex returns the worker of the session of op: all the operations of a
+// session go to the same worker, which writes them in the order they came.
+func (p *SessionPersister) workerIndex(op asyncOperation) int {
+	if len(p.asyncChans) == 1 {
+		return 0
+	}
+
+	appName, userID, sessionID := op.key()
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(appName + "\x00" + userID + "\x00" + sessionID))
+	return int(h.Sum32() % uint32(len(p.asyncChans)))
+}
+
+// processAsyncOp processes a single async operation with its own timeout.
+func (p *SessionPersister) processAsyncOp(op asyncOperation) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultAsyncOpTimeout)
+	defer cancel()
+
+	var err error
+	switch op.operationType {
+	case operationSession:
+		err = p.persistSessionSync(ctx, op.sess)
+	case operationEvent:
+		err = p.persistEventSync(ctx, op.sess, op.evt)
+	case operationDelete:
+		err = p.deleteSessionSync(ctx, op.appName, op.userID, op.sessionID)
+	}
+
+	if err != nil {
+		_, _, sessionID := op.key()
+		p.logger.Errorf("async %s operation of session %s failed: %v", op.operationType, sessionID, err)
+	}
+}
+
+// PersistSession saves or updates a session in MySQL.
+// If async mode is enabled, the operation is queued and returns immediately.
+func (p *SessionPersister) PersistSession(ctx context.Context, sess session.Session) error {
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		return ErrPersisterClosed
+	}
+	p.mu.RUnlock()
+
+	if queued, err := p.enqueue(ctx, asyncOperation{operationType: operationSession, sess: sess}); queued || err != nil {
+		return err
+	}
+
+	return p.persistSessionSync(ctx, sess)
+}
+
+// execer runs the statements of upsertSession: the database, or a transaction.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// persistSessionSync upserts the row of sess.
+func (p *SessionPersister) persistSessionSync(ctx context.Context, sess session.Session) error {
+	return p.upsertSession(ctx, p.client.DB(), sess)
+}
+
+// upsertSession writes the row of sess with db.
+func (p *SessionPersister) upsertSession(ctx context.Context, db execer, sess session.Session) error {
+	stateJSON := "{}"
+	if state := sess.State(); state != nil {
+		data, err := sonic.MarshalString(maps.Collect(state.All()))
+		if err != nil {
+			p.logger.Errorf("failed to marshal state of session %s: %v", sess.ID(), err)
+			return fmt.Errorf("failed to marshal session state: %w", err)
+		}
+		stateJSON = data
+	}
+
+	// NOTE: The tags of a session not carrying tags are kept
+	var tagsJSON any
+	if tagged, ok := sess.(ksess.Tagged); ok {
+		tags := tagged.Tags()
+		if tags == nil {
+			tags = map[string]string{}
+		}
+		data, err := sonic.MarshalString(tags)
+		if err != nil {
+			return fmt.Errorf("failed to marshal session tags: %w", err)
+		}
+		tagsJSON = data
+	}
+
+	// NOTE: So are the branch link and the TTL of a session not carrying them
+	var parentID, forkEventID, ttlSeconds any
+	if forked, ok := sess.(ksess.Forked); ok {
+		parentID, forkEventID = forked.ParentID(), forked.ForkEventID()
+	}
+	if expiring, ok := sess.(ksess.Expiring); ok {
+		ttlSeconds = int64(expiring.TTL().Seconds())
+	}
+
+	// NOTE: VALUES() reads the inserted row on both MySQL and MariaDB, which lacks
+	// the row alias of MySQL 8.0.19
+	//nolint:gosec // table name is validated by the client
+	stmt := `
+		INSERT INTO ` + p.client.SessionsTableName() + `
+			(id, app_name, user_id, state, tags, parent_id, fork_event_id, ttl_seconds, last_update_time)
+		VALUES (?, ?, ?, ?, COALESCE(?, '{}'), COALESCE(?, ''), COALESCE(?, ''), COALESCE(?, 0), ?)
+		ON DUPLICATE KEY UPDATE
+			state = VALUES(state),
+			last_update_time = VALUES(last_update_time),
+			tags = COALESCE(?, tags),
+			parent_id = COALESCE(?, parent_id),
+			fork_event_id = COALESCE(?, fork_event_id),
+			ttl_seconds = COALESCE(?, ttl_seconds)
+	`
+
+	p.logger.Debugf("Persist Session SQL: %s", stmt)
+
+	_, err := db.ExecContext(ctx, stmt,
+		sess.ID(), sess.AppName(), sess.UserID(), stateJSON, tagsJSON,
+		parentID, forkEventID, ttlSeconds, sess.LastUpdateTime(),
+		tagsJSON, parentID, forkEventID, ttlSeconds)
+	if err != nil {
+		p.logger.Errorf("failed to persist session %s: %v", sess.ID(), err)
+		return fmt.Errorf("failed to persist session: %w", err)
+	}
+
+	p.logger.Infof("session persisted: %s", sess.ID())
+
+	return nil
+}
+
+// PersistEvent saves a single event to MySQL (real-time sync).
+// If async mode is enabled, the operation is queued and returns immediately.
+func (p *SessionPersister) PersistEvent(
+	ctx context.Context,
+	sess session.Session,
+	evt *session.Event,
+) error {
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		return ErrPersisterClosed
+	}
+	p.mu.RUnlock()
+
+	if queued, err := p.enqueue(ctx, asyncOperation{operationType: operationEvent, sess: sess, evt: evt}); queued || err != nil {
+		return err
+	}
+
+	return p.persistEventSync(ctx, sess, evt)
+}
+
+// persistEventSync inserts an event of sess with the next order of its session.
+func (p *SessionPersister) persistEventSync(
+	ctx context.Context,
+	sess session.Session,
+	evt *session.Event,
+) error {
+	evtJSON, err := sonic.MarshalString(evt)
+	if err != nil {
+		p.logger.Errorf("failed to marshal event %s: %v", evt.ID, err)
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	err = p.retryTx(ctx, func(tx *sql.Tx) error {
+		return p.insertEvent(ctx, tx, sess, evt, evtJSON)
+	})
+	if err != nil {
+		return err
+	}
+
+	p.logger.Debugf("event persisted: session=%s, event=%s, shard=%s",
+		sess.ID(), evt.ID, p.client.GetEventsTableName(sess.UserID()))
+	return nil
+}
+
+// insertEvent claims the next event order of the session of evt and writes evt
+// within tx.
+func (p *SessionPersister) insertEvent(
+	ctx context.Context,
+	tx *sql.Tx,
+	sess session.Session,
+	evt *session.Event,
+	evtJSON string,
+) error {
+	sessionsTable := p.client.SessionsTableName()
+	tableName := p.client.GetEventsTableName(sess.UserID())
+
+	// NOTE: The row lock on the session serializes the events of a session until
+	// commit. The event keeps its order of arrival, whatever its timestamp, and an
+	// imported older event does not move last_update_time back
+	var order int
+	claim := func() error {
+		//nolint:gosec // table name is validated by the client
+		return tx.QueryRowContext(ctx, `
+			SELECT next_event_order FROM `+sessionsTable+`
+			WHERE app_name = ? AND user_id = ? AND id = ?
+			FOR UPDATE
+		`, sess.AppName(), sess.UserID(), sess.ID()).Scan(&order)
+	}
+	err := claim()
+
+	// NOTE: The session of an event may be missing, e.g. when persisting it failed
+	// or its async operation is still queued: store it with the event
+	if errors.Is(err, sql.ErrNoRows) {
+		p.logger.Warnf("session %s missing, storing it with event %s", sess.ID(), evt.ID)
+		if err := p.upsertSession(ctx, tx, sess); err != nil {
+			return err
+		}
+		err = claim()
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		p.logger.Errorf("session %s missing, dropping event %s", sess.ID(), evt.ID)
+		return fmt.Errorf("%w: %s", ErrSessionMissing, sess.ID())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to claim event order: %w", err)
+	}
+
+	//nolint:gosec // table name is validated by the client
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE `+sessionsTable+` SET
+			next_event_order = next_event_order + 1,
+			last_update_time = GREATEST(last_update_time, ?)
+		WHERE app_name = ? AND user_id = ? AND id = ?
+	`, evt.Timestamp, sess.AppName(), sess.UserID(), sess.ID()); err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+
+	//nolint:gosec // table name is generated internally
+	insertQuery := `INSERT INTO ` + tableName +
+		` (id, app_name, user_id, session_id, event_order, content, author, branch, timestamp)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	if _, err := tx.ExecContext(ctx, insertQuery,
+		evt.ID, sess.AppName(), sess.UserID(), sess.ID(),
+		order, evtJSON, evt.Author, evt.Branch, evt.Timestamp); err != nil {
+		p.logger.Errorf("failed to insert event %s: %v", evt.ID, err)
+		return fmt.Errorf("failed to insert event into %s: %w", tableName, err)
+	}
+
+	return nil
+}
+
+// DeleteSession removes a session and all its events from MySQL.
+// If async mode is enabled, the operation is queued and returns immediately.
+func (p *SessionPersister) DeleteSession(
+	ctx context.Context,
+	appName, userID, sessionID string,
+) error {
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		return ErrPersisterClosed
+	}
+	p.mu.RUnlock()
+
+	queued, err := p.enqueue(ctx, asyncOperation{
+		operationType: operationDelete,
+		appName:       appName,
+		userID:        userID,
+		sessionID:     sessionID,
+	})
+	if queued || err != nil {
+		return err
+	}
+
+	return p.deleteSessionSync(ctx, appName, userID, sessionID)
+}
+
+// deleteSessionSync deletes a session and its events in a transaction.
+func (p *SessionPersister) deleteSessionSync(
+	ctx context.Context,
+	appName, userID, sessionID string,
+) error {
+	err := p.retryTx(ctx, func(tx *sql.Tx) error {
+		// NOTE: Deleted first, so that an event being persisted concurrently waits on
+		// the session row, then finds it missing
+		//nolint:gosec // table name is validated by the client
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+p.client.SessionsTableName()+
+			` WHERE app_name = ? AND user_id = ? AND id = ?`, appName, userID, sessionID); err != nil {
+			return fmt.Errorf("failed to delete session: %w", err)
+		}
+
+		tableName := p.client.GetEventsTableName(userID)
+		//nolint:gosec // table name is generated internally
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+tableName+
+			` WHERE app_name = ? AND user_id = ? AND session_id = ?`, appName, userID, sessionID); err != nil {
+			return fmt.Errorf("failed to delete events from %s: %w", tableName, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	p.logger.Debugf("session deleted from mysql: %s", sessionID)
+	return nil
+}
+
+// retryTx runs fn in a transaction, run again when InnoDB rolls it back on a
+// deadlock or a lock wait timeout.
+func (p *SessionPersister) retryTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	var err error
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		if err = p.runTx(ctx, fn); err == nil || !isRetryable(err) {
+			return err
+		}
+		p.logger.Warnf("transaction rolled back (attempt %d/%d): %v", attempt, maxTxAttempts, err)
+	}
+	return err
+}
+
+// runTx runs fn in a transaction committed if fn succeeds.
+func (p *SessionPersister) runTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := p.client.DB().BeginTx(ctx, nil)
+	if err != nil {
+		p.logger.Errorf("failed to begin transaction: %v", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		p.logger.Errorf("failed to commit transaction: %v", err)
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Close closes the persister and releases resources.
+// It waits for all pending async operations to complete before returning.
+// The client is not closed.
+func (p *SessionPersister) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+
+	// NOTE: No operation is being queued while the lock is held
+	for _, ch := range p.asyncChans {
+		close(ch)
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait() // Wait for all async operations to complete
+
+	p.logger.Info("MySQL session persister closed")
+	return nil
+}
+
+// Client returns the underlying MySQL client.
+func (p *SessionPersister) Client() *Client { return p.client }
@@ -0,0 +1,181 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/adk/session"
+)
+
+func getTestDSN() string {
+	/*
+	  docker run -d \
+	    --name mysql-test \
+	    -e MYSQL_ROOT_PASSWORD=mysql \
+	    -e MYSQL_DATABASE=sessions \
+	    -p 3306:3306 \
+	    mysql:8
+	*/
+
+	if dsn := os.Getenv("TEST_MYSQL_DSN"); dsn != "" {
+		return dsn
+	}
+	return "root:mysql@tcp(localhost:3306)/sessions"
+}
+
+func setupTestDB(t *testing.T, opts ...PersisterOption) *SessionPersister {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client, err := NewMySQLClient(ctx, &Config{
+		DSN:         getTestDSN(),
+		ShardCount:  4,
+		PingRetries: 1,
+		PingTimeout: time.Second,
+	})
+	if err != nil {
+		t.Skipf("MySQL not available, skipping test: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+
+	persister, err := NewSessionPersister(ctx, client, opts...)
+	if err != nil {
+		t.Fatalf("Failed to create persister: %v", err)
+	}
+	t.Cleanup(func() { _ = persister.Close() })
+
+	// Clean up test data
+	if _, err := client.DB().ExecContext(ctx, "DELETE FROM sessions WHERE app_name LIKE 'test_%'"); err != nil {
+		t.Logf("Warning: failed to clean up sessions: %v", err)
+	}
+	for i := range client.ShardCount() {
+		query := fmt.Sprintf("DELETE FROM %s WHERE app_name LIKE 'test_%%'", client.EventsTableName(i))
+		if _, err := client.DB().ExecContext(ctx, query); err != nil {
+			t.Logf("Warning: failed to clean up events shard %d: %v", i, err)
+		}
+	}
+
+	return persister
+}
+
+func createTestSession(t *testing.T, sessionID, appName, userID string) session.Session {
+	t.Helper()
+
+	resp, err := session.InMemoryService().Create(context.Background(), &session.CreateRequest{
+		AppName: appName, UserID: userID, SessionID: sessionID, State: map[string]any{"topic": "go"},
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	return resp.Session
+}
+
+func TestPersistAndLoadSession(t *testing.T) {
+	persister := setupTestDB(t, WithAsyncBufferSize(0))
+	ctx := context.Background()
+	sess := createTestSession(t, "sess-1", "test_app", "user-1")
+
+	// The missing session of an event is stored with it
+	if err := persister.PersistEvent(ctx, sess, &session.Event{ID: "e0", Author: "user", Timestamp: time.Now()}); err != nil {
+		t.Fatalf("PersistEvent of a missing session failed: %v", err)
+	}
+	if err := persister.PersistSession(ctx, sess); err != nil {
+		t.Fatalf("PersistSession failed: %v", err)
+	}
+	for i, id := range []string{"e1", "e2", "e3"} {
+		evt := &session.Event{ID: id, Author: "user", Timestamp: time.Now().Add(time.Duration(i+1) * time.Second)}
+		if err := persister.PersistEvent(ctx, sess, evt); err != nil {
+			t.Fatalf("PersistEvent failed: %v", err)
+		}
+	}
+
+	stored, err := persister.LoadSession(ctx, "test_app", "user-1", "sess-1")
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+	if stored == nil || stored.State["topic"] != "go" || len(stored.Events) != 4 {
+		t.Fatalf("unexpected stored session: %+v", stored)
+	}
+	for i, id := range []string{"e0", "e1", "e2", "e3"} {
+		if stored.Events[i].ID != id {
+			t.Errorf("event %d = %s, want %s", i, stored.Events[i].ID, id)
+		}
+	}
+
+	ids, err := persister.ListSessionIDs(ctx, "test_app", "user-1")
+	if err != nil || !slices.Equal(ids, []string{"sess-1"}) {
+		t.Errorf("ListSessionIDs = %v, %v", ids, err)
+	}
+
+	refs, err := persister.ListRecentSessions(ctx, "test_app", time.Now().Add(-time.Hour), nil, 10)
+	if err != nil || len(refs) != 1 || refs[0].ID != "sess-1" {
+		t.Errorf("ListRecentSessions = %v, %v", refs, err)
+	}
+
+	if err := persister.DeleteSession(ctx, "test_app", "user-1", "sess-1"); err != nil {
+		t.Fatalf("DeleteSession failed: %v", err)
+	}
+	if stored, err := persister.LoadSession(ctx, "test_app", "user-1", "sess-1"); err != nil || stored != nil {
+		t.Errorf("expected the session deleted, got %+v, %v", stored, err)
+	}
+}
+
+func TestConcurrentEvents(t *testing.T) {
+	persister := setupTestDB(t, WithAsyncBufferSize(0))
+	ctx := context.Background()
+	sess := createTestSession(t, "sess-busy", "test_app", "user-1")
+
+	if err := persister.PersistSession(ctx, sess); err != nil {
+		t.Fatalf("PersistSession failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Go(func() {
+			evt := &session.Event{ID: fmt.Sprintf("e%d", i), Timestamp: time.Now()}
+			if err := persister.PersistEvent(ctx, sess, evt); err != nil {
+				t.Errorf("PersistEvent failed: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+
+	stored, err := persister.LoadSession(ctx, "test_app", "user-1", "sess-busy")
+	if err != nil || stored == nil || len(stored.Events) != 10 {
+		t.Fatalf("expected 10 events, got %+v, %v", stored, err)
+	}
+}
+
+func TestAsyncPersist(t *testing.T) {
+	persister := setupTestDB(t, WithAsyncWorkers(2))
+	ctx := context.Background()
+	sess := createTestSession(t, "sess-async", "test_app", "user-1")
+
+	if err := persister.PersistSession(ctx, sess); err != nil {
+		t.Fatalf("PersistSession failed: %v", err)
+	}
+	if err := persister.PersistEvent(ctx, sess, &session.Event{ID: "e1", Timestamp: time.Now()}); err != nil {
+		t.Fatalf("PersistEvent failed: %v", err)
+	}
+
+	// NOTE: Close drains the queues
+	if err := persister.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := persister.PersistSession(ctx, sess); !errors.Is(err, ErrPersisterClosed) {
+		t.Errorf("expected ErrPersisterClosed, got %v", err)
+	}
+
+	stored, err := persister.LoadSession(ctx, "test_app", "user-1", "sess-async")
+	if err != nil || stored == nil || len(stored.Events) != 1 {
+		t.Fatalf("expected the session with 1 event, got %+v, %v", stored, err)
+	}
+}
@@ -0,0 +1,123 @@
+package objstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// Compact merges the event objects of every session into its events.jsonl, so
+// a session is read in two objects, and returns the number of sessions
+// compacted. The events persisted during the compaction are kept for the next
+// one. A session failing to compact is logged and skipped.
+func (p *Persister) Compact(ctx context.Context) (int, error) {
+	keys, err := p.store.List(ctx, p.prefix)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list archived sessions: %w", err)
+	}
+
+	// NOTE: The keys are sorted, so the parts of a session are contiguous
+	var (
+		compacted int
+		prefix    string
+		parts     []string
+	)
+	flush := func() {
+		if len(parts) == 0 {
+			return
+		}
+		if err := p.compactSession(ctx, prefix, parts); err != nil {
+			p.logger.Warnf("failed to compact archived session %s: %v", prefix, err)
+		} else {
+			compacted++
+		}
+		parts = nil
+	}
+	for _, key := range keys {
+		i := strings.LastIndex(key, "/"+eventPartsDir)
+		if i < 0 || !strings.HasSuffix(key, eventPartSuffix) {
+			continue
+		}
+		if sessionPrefix := key[:i+1]; sessionPrefix != prefix {
+			flush()
+			prefix = sessionPrefix
+		}
+		parts = append(parts, key)
+	}
+	flush()
+
+	if err := ctx.Err(); err != nil {
+		return compacted, err
+	}
+
+	p.logger.Infof("archived sessions compacted: sessions=%d", compacted)
+	return compacted, nil
+}
+
+// compactSession rewrites events.jsonl of the session at prefix with the events
+// of parts appended, then deletes parts.
+func (p *Persister) compactSession(ctx context.Context, prefix string, parts []string) error {
+	p.compactMu.Lock()
+	defer p.compactMu.Unlock()
+
+	// NOTE: A session deleted since the listing is not brought back
+	if _, err := p.store.Get(ctx, prefix+snapshotObject); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	events, err := p.readEvents(ctx, prefix, parts)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	for _, evt := range events {
+		line, err := sonic.Marshal(evt)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event %s: %w", evt.ID, err)
+		}
+		data = append(append(data, line...), '\n')
+	}
+	if err := p.store.Put(ctx, prefix+eventsObject, data); err != nil {
+		return fmt.Errorf("failed to write compacted events: %w", err)
+	}
+
+	// NOTE: A part left by a failed delete is skipped by ID when read
+	for _, key := range parts {
+		if err := p.store.Delete(ctx, key); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", key, err)
+		}
+	}
+
+	p.logger.Debugf("archived session compacted: prefix=%s, events=%d, parts=%d", prefix, len(events), len(parts))
+	return nil
+}
+
+// startCompacting runs Compact every interval until Close.
+func (p *Persister) startCompacting(interval time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.stopCompacting = cancel
+
+	p.wg.Go(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := p.Compact(ctx); err != nil && ctx.Err() == nil {
+					p.logger.Errorf("failed to compact archived sessions: %v", err)
+				}
+			}
+		}
+	})
+}
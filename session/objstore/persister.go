@@ -0,0 +1,311 @@
+package objstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bytedance/sonic"
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	ksess "github.com/kydenul/k-adk/session"
+	"github.com/kydenul/log"
+	"google.golang.org/adk/session"
+)
+
+var (
+	_ ksess.Persister = (*Persister)(nil)
+	_ ksess.Loader    = (*Persister)(nil)
+)
+
+// Object names under the prefix of a session.
+const (
+	snapshotObject  = "session.json"
+	eventsObject    = "events.jsonl"
+	eventPartsDir   = "events/"
+	eventPartSuffix = ".jsonl"
+)
+
+// ErrPersisterClosed is returned when the persister is closed.
+var ErrPersisterClosed = errors.New("persister is closed")
+
+// Config configures a Persister.
+type Config struct {
+	// Store holds the objects.
+	Store Store
+
+	// Optional. Prefix is prepended to the keys, e.g. "sessions/". Default: "".
+	Prefix string
+
+	// Optional. CompactInterval compacts the event objects of the sessions in the
+	// background, see Persister.Compact. Default: 0, compaction on demand only.
+	CompactInterval time.Duration
+
+	// Optional. Logger for logging. Falls back to `DiscardLog` if nil.
+	Logger log.Logger
+}
+
+// snapshot is the session.json object of a session.
+type snapshot struct {
+	AppName        string            `json:"app_name"`
+	UserID         string            `json:"user_id"`
+	ID             string            `json:"id"`
+	State          map[string]any    `json:"state"`
+	Tags           map[string]string `json:"tags,omitempty"`
+	LastUpdateTime time.Time         `json:"last_update_time"`
+}
+
+// Persister implements ksess.Persister and ksess.Loader on an object store. Under
+// {Prefix}{app}/{user}/{session}/, a session is stored as:
+//
+//	session.json               the last snapshot of the session, without its events
+//	events/{time}-{seq}.jsonl  an event, one object per PersistEvent
+//	events.jsonl               the compacted events, one per line
+//
+// Object stores cannot append, so every event is an object of its own until
+// Compact merges them into events.jsonl. The events of a session are ordered by
+// the time they are persisted, which should come from one process at a time, as
+// with a Redis session service. It is safe for concurrent use.
+type Persister struct {
+	store  Store
+	prefix string
+	logger log.Logger
+
+	// seq orders the events persisted within the same nanosecond.
+	seq atomic.Uint64
+
+	// compactMu keeps a session from being compacted while it is deleted.
+	compactMu sync.Mutex
+
+	closed         atomic.Bool
+	stopCompacting context.CancelFunc
+	wg             sync.WaitGroup
+}
+
+// NewPersister creates a Persister on cfg.Store and starts its background
+// compaction if cfg.CompactInterval is set.
+func NewPersister(cfg Config) (*Persister, error) {
+	if cfg.Store == nil {
+		return nil, errors.New("object store cannot be nil")
+	}
+
+	p := &Persister{store: cfg.Store, prefix: cfg.Prefix, logger: cfg.Logger}
+	if p.logger == nil {
+		p.logger = discardlog.NewDiscardLog()
+	}
+
+	if cfg.CompactInterval > 0 {
+		p.startCompacting(cfg.CompactInterval)
+	}
+
+	p.logger.Infof("object store session persister initialized: prefix=%q, compact_interval=%s",
+		cfg.Prefix, cfg.CompactInterval)
+
+	return p, nil
+}
+
+// PersistSession writes the snapshot of sess, replacing the previous one.
+func (p *Persister) PersistSession(ctx context.Context, sess session.Session) error {
+	if p.closed.Load() {
+		return ErrPersisterClosed
+	}
+
+	data, err := sonic.Marshal(snapshot{
+		AppName:        sess.AppName(),
+		UserID:         sess.UserID(),
+		ID:             sess.ID(),
+		State:          maps.Collect(sess.State().All()),
+		Tags:           ksess.TagsOf(sess),
+		LastUpdateTime: sess.LastUpdateTime(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal session %s: %w", sess.ID(), err)
+	}
+
+	key := p.sessionPrefix(sess.AppName(), sess.UserID(), sess.ID()) + snapshotObject
+	if err := p.store.Put(ctx, key, data); err != nil {
+		return fmt.Errorf("failed to write session %s: %w", sess.ID(), err)
+	}
+
+	p.logger.Debugf("session archived: key=%s", key)
+	return nil
+}
+
+// PersistEvent writes evt as an object of its own, until Compact merges it.
+func (p *Persister) PersistEvent(ctx context.Context, sess session.Session, evt *session.Event) error {
+	if p.closed.Load() {
+		return ErrPersisterClosed
+	}
+
+	data, err := sonic.Marshal(evt)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event %s: %w", evt.ID, err)
+	}
+
+	// NOTE: The zero padded time and sequence sort the parts in persist order
+	part := fmt.Sprintf("%020d-%010d", time.Now().UnixNano(), p.seq.Add(1)%1e10)
+	key := p.sessionPrefix(sess.AppName(), sess.UserID(), sess.ID()) + eventPartsDir + part + eventPartSuffix
+	if err := p.store.Put(ctx, key, append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write event %s: %w", evt.ID, err)
+	}
+
+	p.logger.Debugf("event archived: key=%s", key)
+	return nil
+}
+
+// DeleteSession removes the objects of a session.
+func (p *Persister) DeleteSession(ctx context.Context, appName, userID, sessionID string) error {
+	if p.closed.Load() {
+		return ErrPersisterClosed
+	}
+
+	p.compactMu.Lock()
+	defer p.compactMu.Unlock()
+
+	prefix := p.sessionPrefix(appName, userID, sessionID)
+	keys, err := p.store.List(ctx, prefix)
+	if err != nil {
+		return fmt.Errorf("failed to list session %s: %w", sessionID, err)
+	}
+
+	// NOTE: The snapshot goes first, so a failed delete leaves no partial session,
+	// only objects that deleting it again removes
+	for i := len(keys) - 1; i >= 0; i-- {
+		if err := p.store.Delete(ctx, keys[i]); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", keys[i], err)
+		}
+	}
+
+	p.logger.Debugf("archived session deleted: prefix=%s, objects=%d", prefix, len(keys))
+	return nil
+}
+
+// Close stops the background compaction. The store is not closed.
+func (p *Persister) Close() error {
+	if !p.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	if p.stopCompacting != nil {
+		p.stopCompacting()
+	}
+	p.wg.Wait()
+	return nil
+}
+
+// LoadSession reads a session and its events, the compacted ones then the others.
+// It returns nil, nil if the session has no snapshot.
+func (p *Persister) LoadSession(
+	ctx context.Context,
+	appName, userID, sessionID string,
+) (*ksess.StoredSession, error) {
+	prefix := p.sessionPrefix(appName, userID, sessionID)
+
+	data, err := p.store.Get(ctx, prefix+snapshotObject)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session %s: %w", sessionID, err)
+	}
+
+	var snap snapshot
+	if err := sonic.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal session %s: %w", sessionID, err)
+	}
+
+	parts, err := p.store.List(ctx, prefix+eventPartsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events of session %s: %w", sessionID, err)
+	}
+	events, err := p.readEvents(ctx, prefix, parts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ksess.StoredSession{
+		ID:             snap.ID,
+		AppName:        snap.AppName,
+		UserID:         snap.UserID,
+		State:          snap.State,
+		Tags:           snap.Tags,
+		Events:         events,
+		LastUpdateTime: snap.LastUpdateTime,
+	}, nil
+}
+
+// ListSessionIDs returns the IDs of the sessions of a user with a snapshot.
+func (p *Persister) ListSessionIDs(ctx context.Context, appName, userID string) ([]string, error) {
+	prefix := p.prefix + escapeKey(appName) + "/" + escapeKey(userID) + "/"
+	keys, err := p.store.List(ctx, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions of user %s: %w", userID, err)
+	}
+
+	var ids []string
+	for _, key := range keys {
+		escaped, ok := strings.CutSuffix(strings.TrimPrefix(key, prefix), "/"+snapshotObject)
+		if !ok || strings.Contains(escaped, "/") {
+			continue
+		}
+		if id, err := url.PathUnescape(escaped); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// readEvents reads the compacted events of the session at prefix, then those of
+// parts. Events already read are skipped by ID, as an interrupted compaction
+// leaves them in both.
+func (p *Persister) readEvents(ctx context.Context, prefix string, parts []string) ([]*session.Event, error) {
+	var events []*session.Event
+	seen := make(map[string]bool)
+
+	for _, key := range append([]string{prefix + eventsObject}, parts...) {
+		data, err := p.store.Get(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", key, err)
+		}
+
+		for line := range bytes.SplitSeq(data, []byte("\n")) {
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			var evt session.Event
+			if err := sonic.Unmarshal(line, &evt); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal an event of %s: %w", key, err)
+			}
+			if seen[evt.ID] {
+				continue
+			}
+			seen[evt.ID] = true
+			events = append(events, &evt)
+		}
+	}
+
+	return events, nil
+}
+
+// sessionPrefix returns the key prefix of the objects of a session.
+func (p *Persister) sessionPrefix(appName, userID, sessionID string) string {
+	return p.prefix + escapeKey(appName) + "/" + escapeKey(userID) + "/" + escapeKey(sessionID) + "/"
+}
+
+// escapeKey escapes an ID in a key segment, so it holds no slash nor a dot
+// segment.
+func escapeKey(id string) string {
+	switch id {
+	case ".", "..":
+		return strings.ReplaceAll(id, ".", "%2E")
+	}
+	return url.PathEscape(id)
+}
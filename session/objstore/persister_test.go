@@ -0,0 +1,132 @@
+package objstore
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	ksess "github.com/kydenul/k-adk/session"
+	"google.golang.org/adk/session"
+)
+
+func newTestPersister(t *testing.T) (*Persister, *DirStore) {
+	t.Helper()
+
+	store, err := NewDirStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDirStore failed: %v", err)
+	}
+	p, err := NewPersister(Config{Store: store, Prefix: "archive/"})
+	if err != nil {
+		t.Fatalf("NewPersister failed: %v", err)
+	}
+	t.Cleanup(func() { _ = p.Close() })
+	return p, store
+}
+
+func createSession(t *testing.T, id string) session.Session {
+	t.Helper()
+
+	resp, err := session.InMemoryService().Create(context.Background(), &session.CreateRequest{
+		AppName: "app", UserID: "user/1", SessionID: id, State: map[string]any{"topic": id},
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	return resp.Session
+}
+
+func eventIDs(stored *ksess.StoredSession) []string {
+	ids := make([]string, len(stored.Events))
+	for i, evt := range stored.Events {
+		ids[i] = evt.ID
+	}
+	return ids
+}
+
+func TestPersister(t *testing.T) {
+	ctx := context.Background()
+	p, store := newTestPersister(t)
+	sess := createSession(t, "..")
+
+	if err := p.PersistSession(ctx, sess); err != nil {
+		t.Fatalf("PersistSession failed: %v", err)
+	}
+	for _, id := range []string{"e1", "e2"} {
+		if err := p.PersistEvent(ctx, sess, &session.Event{ID: id, Author: "user", Timestamp: time.Now()}); err != nil {
+			t.Fatalf("PersistEvent failed: %v", err)
+		}
+	}
+
+	keys, err := store.List(ctx, "archive/app/user%2F1/%2E%2E/")
+	if err != nil || len(keys) != 3 {
+		t.Fatalf("expected a snapshot and 2 event objects, got %v, %v", keys, err)
+	}
+
+	stored, err := p.LoadSession(ctx, "app", "user/1", "..")
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+	if stored == nil || stored.State["topic"] != ".." || !slices.Equal(eventIDs(stored), []string{"e1", "e2"}) {
+		t.Fatalf("unexpected stored session: %+v", stored)
+	}
+
+	ids, err := p.ListSessionIDs(ctx, "app", "user/1")
+	if err != nil || !slices.Equal(ids, []string{".."}) {
+		t.Errorf("ListSessionIDs = %v, %v", ids, err)
+	}
+
+	if err := p.DeleteSession(ctx, "app", "user/1", ".."); err != nil {
+		t.Fatalf("DeleteSession failed: %v", err)
+	}
+	if stored, err := p.LoadSession(ctx, "app", "user/1", ".."); err != nil || stored != nil {
+		t.Errorf("expected the session deleted, got %+v, %v", stored, err)
+	}
+	if keys, _ := store.List(ctx, "archive/"); len(keys) != 0 {
+		t.Errorf("expected no object left, got %v", keys)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	ctx := context.Background()
+	p, store := newTestPersister(t)
+	sess := createSession(t, "sess-compact")
+
+	if err := p.PersistSession(ctx, sess); err != nil {
+		t.Fatalf("PersistSession failed: %v", err)
+	}
+	persist := func(ids ...string) {
+		t.Helper()
+		for _, id := range ids {
+			if err := p.PersistEvent(ctx, sess, &session.Event{ID: id, Author: "user"}); err != nil {
+				t.Fatalf("PersistEvent failed: %v", err)
+			}
+		}
+	}
+
+	persist("e1", "e2", "e3")
+	if n, err := p.Compact(ctx); err != nil || n != 1 {
+		t.Fatalf("Compact = %d, %v, want 1 session", n, err)
+	}
+	persist("e4")
+	if n, err := p.Compact(ctx); err != nil || n != 1 {
+		t.Fatalf("Compact = %d, %v, want 1 session", n, err)
+	}
+	if n, err := p.Compact(ctx); err != nil || n != 0 {
+		t.Errorf("Compact = %d, %v, want nothing to compact", n, err)
+	}
+
+	keys, _ := store.List(ctx, "archive/")
+	if len(keys) != 2 {
+		t.Errorf("expected the snapshot and the compacted events, got %v", keys)
+	}
+
+	stored, err := p.LoadSession(ctx, "app", "user/1", "sess-compact")
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+	if got := eventIDs(stored); !slices.Equal(got, []string{"e1", "e2", "e3", "e4"}) {
+		t.Errorf("events = %v", got)
+	}
+}
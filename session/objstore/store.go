@@ -0,0 +1,135 @@
+// Package objstore provides a Persister archiving sessions in an object store,
+// such as S3 or GCS, for cheap long-term storage of conversations beyond the
+// retention window of a database. Sessions are stored as a JSON snapshot and
+// their events as JSONL objects under an app/user/session/ prefix, compacted
+// periodically into a single object.
+package objstore
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrNotFound is returned by Store.Get for a missing object.
+var ErrNotFound = errors.New("object not found")
+
+// Store is an object store holding the archived sessions. Implement it on the
+// SDK of the bucket (S3, GCS, Azure Blob), or use DirStore for a directory or a
+// mounted bucket. Put must replace an object atomically, as object stores do.
+type Store interface {
+	// Put writes data to key, replacing the object if it exists.
+	Put(ctx context.Context, key string, data []byte) error
+
+	// Get reads the object at key, or fails with ErrNotFound.
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// List returns the keys starting with prefix, sorted.
+	List(ctx context.Context, prefix string) ([]string, error)
+
+	// Delete removes the object at key. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// DirStore is a Store keeping the objects as files under a directory, with the
+// slashes of the keys as path separators.
+type DirStore struct {
+	dir string
+}
+
+var _ Store = (*DirStore)(nil)
+
+// NewDirStore creates a DirStore under dir, created if missing.
+func NewDirStore(dir string) (*DirStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &DirStore{dir: dir}, nil
+}
+
+// Put writes data to a temporary file renamed to key, so readers never see a
+// partial object.
+func (s *DirStore) Put(_ context.Context, key string, data []byte) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".put-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Get reads the file of key.
+func (s *DirStore) Get(_ context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// List walks the files under the directory of prefix.
+func (s *DirStore) List(_ context.Context, prefix string) ([]string, error) {
+	// NOTE: Walk from the deepest directory holding every key with the prefix
+	root := s.dir
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		root = s.path(prefix[:i])
+	}
+
+	var keys []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".put-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// Delete removes the file of key.
+func (s *DirStore) Delete(_ context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *DirStore) path(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(key))
+}
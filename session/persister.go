@@ -3,6 +3,7 @@ package session
 
 import (
 	"context"
+	"time"
 
 	"google.golang.org/adk/session"
 )
@@ -10,7 +11,8 @@ import (
 // Persister defines the interface for optional long-term session persistence.
 // When configured, sessions and events are automatically synced to the persister.
 // This interface is implemented by postgres.SessionPersister and can be used
-// with redis.RedisSessionService via WithPersister option.
+// with redis.RedisSessionService via WithPersister option. Its read path is the
+// Loader interface, kept separate so write-only persisters need not implement it.
 type Persister interface {
 	// PersistSession saves or updates a session.
 	PersistSession(ctx context.Context, sess session.Session) error
@@ -24,3 +26,80 @@ type Persister interface {
 	// Close closes the persister and releases resources.
 	Close() error
 }
+
+// StoredSession is a session read back from a persistent store.
+type StoredSession struct {
+	ID             string
+	AppName        string
+	UserID         string
+	State          map[string]any
+	Tags           map[string]string
+	Events         []*session.Event
+	LastUpdateTime time.Time
+
+	// ParentID and ForkEventID link a branch to the session and event it was forked
+	// from, see Forked.
+	ParentID    string
+	ForkEventID string
+
+	// TTL is the own TTL of the session in its cache, see Expiring; 0 for the TTL of
+	// the cache.
+	TTL time.Duration
+}
+
+// Forked is implemented by the sessions forked from another session, such as the
+// branches of redis.RedisSessionService.CreateBranch, for the persisters to keep
+// the link.
+type Forked interface {
+	// ParentID returns the ID of the session forked, empty for a session that is
+	// not a branch.
+	ParentID() string
+
+	// ForkEventID returns the ID of the last event copied from the parent.
+	ForkEventID() string
+}
+
+// Expiring is implemented by the sessions with a TTL of their own, such as those
+// created with redis.SessionTTLStateKey, for the persisters to keep it.
+type Expiring interface {
+	// TTL returns the TTL of the session, 0 for the TTL of its service.
+	TTL() time.Duration
+}
+
+// Loader is the read counterpart of Persister: it reads back the sessions a
+// Persister stored, so a cache such as redis.RedisSessionService can rebuild the
+// sessions it evicted. It is implemented by postgres.SessionPersister and used
+// via the redis.WithLoader option.
+type Loader interface {
+	// LoadSession reads a session and its events, in order. It returns nil, nil if
+	// the session is not stored.
+	LoadSession(ctx context.Context, appName, userID, sessionID string) (*StoredSession, error)
+
+	// ListSessionIDs returns the IDs of the stored sessions of a user.
+	ListSessionIDs(ctx context.Context, appName, userID string) ([]string, error)
+}
+
+// SessionRef identifies a stored session and its last update.
+type SessionRef struct {
+	AppName        string
+	UserID         string
+	ID             string
+	LastUpdateTime time.Time
+}
+
+// RecentLoader is a Loader that also lists the sessions recently updated in an
+// app, to preload them into a cache. It is implemented by postgres.SessionPersister.
+type RecentLoader interface {
+	Loader
+
+	// ListRecentSessions returns up to limit sessions of appName updated at or after
+	// since, most recently updated first, starting after the cursor (nil for the
+	// first page): the last session of the previous page.
+	ListRecentSessions(
+		ctx context.Context,
+		appName string,
+		since time.Time,
+		after *SessionRef,
+		limit int,
+	) ([]SessionRef, error)
+}
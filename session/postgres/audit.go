@@ -0,0 +1,395 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/kydenul/k-adk/internal/pgerr"
+	"google.golang.org/adk/session"
+)
+
+// Actions of the audit entries.
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditAppend = "append"
+	AuditDelete = "delete"
+)
+
+// AuditActorRetention is the actor of the deletions of Prune, unless its context
+// carries one.
+const AuditActorRetention = "retention"
+
+const defaultAuditLimit = 100
+
+// auditSchema creates the table of WithAuditLog. diff holds the AuditDiff, encoded
+// as the session states are.
+const auditSchema = `
+	CREATE TABLE IF NOT EXISTS %[1]s (
+		id BIGSERIAL PRIMARY KEY,
+		app_name VARCHAR(255) NOT NULL,
+		user_id VARCHAR(255) NOT NULL,
+		session_id VARCHAR(255) NOT NULL,
+		action VARCHAR(16) NOT NULL,
+		actor VARCHAR(255) NOT NULL DEFAULT '',
+		event_id VARCHAR(255) NOT NULL DEFAULT '',
+		diff JSONB,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_session_audit_session ON %[1]s(app_name, user_id, session_id, id);
+	CREATE INDEX IF NOT EXISTS idx_session_audit_created ON %[1]s(app_name, created_at);
+`
+
+// ErrAuditDisabled is returned by AuditLog without WithAuditLog.
+var ErrAuditDisabled = errors.New("audit log is not enabled")
+
+// WithAuditLog records every session creation, state update, event append and
+// deletion in the session_audit table, with its actor, time and state diff, in
+// the transaction of the change. AuditLog reads them back. The entries outlive
+// the sessions they describe, Prune included. A session update leaving the state
+// as it was is not recorded.
+func WithAuditLog() PersisterOption {
+	return func(p *SessionPersister) { p.audit = true }
+}
+
+// auditActorKey is the context key of the audit actor.
+type auditActorKey struct{}
+
+// WithAuditActor returns a context recording actor, e.g. the ID of an operator or
+// a service, as the actor of the changes persisted with it. The actor of the
+// async operations is taken when they are queued.
+func WithAuditActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+// AuditActor returns the actor set on ctx by WithAuditActor, or "".
+func AuditActor(ctx context.Context) string {
+	actor, _ := ctx.Value(auditActorKey{}).(string)
+	return actor
+}
+
+// AuditDiff is the change of the state of a session recorded by an audit entry.
+type AuditDiff struct {
+	// Set holds the keys added or changed, with their new values.
+	Set map[string]any `json:"set,omitempty"`
+	// Removed holds the keys removed.
+	Removed []string `json:"removed,omitempty"`
+}
+
+// empty reports whether d changes nothing.
+func (d *AuditDiff) empty() bool {
+	return d == nil || (len(d.Set) == 0 && len(d.Removed) == 0)
+}
+
+// AuditEntry is a change of a session recorded by WithAuditLog.
+type AuditEntry struct {
+	ID        int64
+	AppName   string
+	UserID    string
+	SessionID string
+
+	// Action is AuditCreate, AuditUpdate, AuditAppend or AuditDelete.
+	Action string
+
+	// Actor is the actor of the context of the change (see WithAuditActor) or, for
+	// an appended event without one, the author of the event.
+	Actor string
+
+	// EventID is the ID of the appended event of an AuditAppend entry.
+	EventID string
+
+	// Diff is the initial state of a created session, the changed keys of an
+	// updated one and the state delta of an appended event; nil for a deletion.
+	Diff *AuditDiff
+
+	CreatedAt time.Time
+}
+
+// AuditQuery selects the audit entries read by AuditLog.
+type AuditQuery struct {
+	AppName string
+
+	// Optional. UserID and SessionID restrict the entries to a user or a session.
+	UserID    string
+	SessionID string
+
+	// Optional. Actor restricts the entries to an actor.
+	Actor string
+
+	// Optional. Since and Until restrict the entries to [Since, Until).
+	Since time.Time
+	Until time.Time
+
+	// Optional. AfterID is the ID of the last entry of the previous page.
+	AfterID int64
+
+	// Optional. Limit is the maximum number of entries. Default: 100.
+	Limit int
+}
+
+// AuditLog returns the audit entries selected by q, oldest first. Page through
+// them with AfterID.
+func (p *SessionPersister) AuditLog(ctx context.Context, q AuditQuery) ([]AuditEntry, error) {
+	if !p.audit {
+		return nil, ErrAuditDisabled
+	}
+	if q.Limit <= 0 {
+		q.Limit = defaultAuditLimit
+	}
+
+	conds := []string{"app_name = $1", "id > $2"}
+	args := []any{q.AppName, q.AfterID}
+	addCond := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, cond+" $"+strconv.Itoa(len(args)))
+	}
+	if q.UserID != "" {
+		addCond("user_id =", q.UserID)
+	}
+	if q.SessionID != "" {
+		addCond("session_id =", q.SessionID)
+	}
+	if q.Actor != "" {
+		addCond("actor =", q.Actor)
+	}
+	if !q.Since.IsZero() {
+		addCond("created_at >=", q.Since)
+	}
+	if !q.Until.IsZero() {
+		addCond("created_at <", q.Until)
+	}
+	args = append(args, q.Limit)
+
+	//nolint:gosec // table name is validated by the client
+	query := `
+		SELECT id, user_id, session_id, action, actor, event_id, diff, created_at
+		FROM ` + p.client.tableName("session_audit") + `
+		WHERE ` + strings.Join(conds, " AND ") + `
+		ORDER BY id
+		LIMIT $` + strconv.Itoa(len(args))
+
+	rows, err := p.client.DB().QueryContext(ctx, query, args...)
+	if err != nil {
+		p.logger.Errorf("failed to query audit log of app %s: %v", q.AppName, err)
+		return nil, pgerr.Wrap("failed to query audit log", err)
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		entry := AuditEntry{AppName: q.AppName}
+		var diff []byte
+		if err := rows.Scan(&entry.ID, &entry.UserID, &entry.SessionID, &entry.Action,
+			&entry.Actor, &entry.EventID, &diff, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		if len(diff) > 0 {
+			entry.Diff = new(AuditDiff)
+			if err := unmarshalJSON(ctx, p.encryptor, q.AppName, diff, entry.Diff); err != nil {
+				return nil, fmt.Errorf("failed to decode audit entry %d: %w", entry.ID, err)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, pgerr.Wrap("failed to read audit log", err)
+	}
+
+	return entries, nil
+}
+
+// persistSessionAudited upserts sess and records the change in one transaction.
+func (p *SessionPersister) persistSessionAudited(ctx context.Context, sess session.Session) error {
+	tx, err := p.client.DB().BeginTx(ctx, nil)
+	if err != nil {
+		return pgerr.Wrap("failed to begin transaction", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := p.upsertSession(ctx, p.client.stmts.Tx(tx), sess); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return pgerr.Wrap("failed to commit transaction", err)
+	}
+	return nil
+}
+
+// storedState reads the state in the row of a session, locking the row until
+// the end of the transaction of q. It reports false if the session is not stored.
+func (p *SessionPersister) storedState(
+	ctx context.Context,
+	q txQueryer,
+	appName, userID, sessionID string,
+) (map[string]any, bool, error) {
+	var data []byte
+	//nolint:gosec // table name is validated by the client
+	err := q.QueryRowContext(ctx, `
+		SELECT state FROM `+p.client.SessionsTableName()+`
+		WHERE app_name = $1 AND user_id = $2 AND id = $3
+		FOR UPDATE
+	`, appName, userID, sessionID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, pgerr.Wrap("failed to read session state", err)
+	}
+
+	state := map[string]any{}
+	if err := unmarshalJSON(ctx, p.encryptor, appName, data, &state); err != nil {
+		return nil, false, fmt.Errorf("failed to decode session state: %w", err)
+	}
+	return state, true, nil
+}
+
+// auditRecord is an audit entry to write.
+type auditRecord struct {
+	appName, userID, sessionID string
+	action, actor, eventID     string
+	diff                       *AuditDiff
+}
+
+// writeAudit inserts rec in session_audit with db, within the transaction of the
+// change it records.
+func (p *SessionPersister) writeAudit(ctx context.Context, db execer, rec auditRecord) error {
+	var diff any
+	if rec.diff != nil {
+		data, err := sonic.Marshal(rec.diff)
+		if err != nil {
+			return fmt.Errorf("failed to marshal audit diff: %w", err)
+		}
+		if data, err = p.encodeJSON(ctx, rec.appName, data); err != nil {
+			return fmt.Errorf("failed to encode audit diff: %w", err)
+		}
+		diff = data
+	}
+
+	//nolint:gosec // table name is validated by the client
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO `+p.client.tableName("session_audit")+`
+			(app_name, user_id, session_id, action, actor, event_id, diff)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, rec.appName, rec.userID, rec.sessionID, rec.action, rec.actor, rec.eventID, diff)
+	if err != nil {
+		p.logger.Errorf("failed to write audit entry: session=%s, action=%s, err=%v",
+			rec.sessionID, rec.action, err)
+		return pgerr.Wrap("failed to write audit entry", err)
+	}
+	return nil
+}
+
+// auditSession records the upsert of sess over prev, the stored state if existed.
+func (p *SessionPersister) auditSession(
+	ctx context.Context,
+	db execer,
+	sess session.Session,
+	prev map[string]any,
+	existed bool,
+) error {
+	diff, err := diffState(prev, p.rowState(sess))
+	if err != nil {
+		return err
+	}
+
+	rec := auditRecord{
+		appName:   sess.AppName(),
+		userID:    sess.UserID(),
+		sessionID: sess.ID(),
+		action:    AuditCreate,
+		actor:     AuditActor(ctx),
+		diff:      diff,
+	}
+	if existed {
+		if diff.empty() {
+			return nil
+		}
+		rec.action = AuditUpdate
+	}
+
+	return p.writeAudit(ctx, db, rec)
+}
+
+// auditEvent returns the audit record of evt appended to sess. The actor is
+// that of ctx or, without one, the author of evt.
+func auditEvent(ctx context.Context, sess session.Session, evt *session.Event) auditRecord {
+	rec := auditRecord{
+		appName:   sess.AppName(),
+		userID:    sess.UserID(),
+		sessionID: sess.ID(),
+		action:    AuditAppend,
+		actor:     AuditActor(ctx),
+		eventID:   evt.ID,
+	}
+	if rec.actor == "" {
+		rec.actor = evt.Author
+	}
+
+	for key, value := range evt.Actions.StateDelta {
+		if strings.HasPrefix(key, session.KeyPrefixTemp) {
+			continue
+		}
+		if rec.diff == nil {
+			rec.diff = &AuditDiff{Set: map[string]any{}}
+		}
+		rec.diff.Set[key] = value
+	}
+
+	return rec
+}
+
+// diffState returns the keys of next added, changed or removed from prev. The
+// values are compared as JSON, the way they are stored.
+func diffState(prev, next map[string]any) (*AuditDiff, error) {
+	data, err := sonic.Marshal(next)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal session state: %w", err)
+	}
+	normalized := map[string]any{}
+	if err := sonic.Unmarshal(data, &normalized); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal session state: %w", err)
+	}
+
+	diff := &AuditDiff{}
+	for key, value := range normalized {
+		if old, ok := prev[key]; ok && reflect.DeepEqual(old, value) {
+			continue
+		}
+		if diff.Set == nil {
+			diff.Set = map[string]any{}
+		}
+		diff.Set[key] = next[key]
+	}
+	for key := range prev {
+		if _, ok := normalized[key]; !ok {
+			diff.Removed = append(diff.Removed, key)
+		}
+	}
+	slices.Sort(diff.Removed)
+
+	return diff, nil
+}
+
+// rowState returns the state of sess stored in its row: without its app and user
+// keys with WithScopedState.
+func (p *SessionPersister) rowState(sess session.Session) map[string]any {
+	state := map[string]any{}
+	if sessState := sess.State(); sessState != nil {
+		state = maps.Collect(sessState.All())
+	}
+	if p.scopedState {
+		state, _, _ = splitScopedState(state)
+	}
+	return state
+}
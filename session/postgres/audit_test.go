@@ -0,0 +1,131 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/adk/session"
+)
+
+func TestDiffState(t *testing.T) {
+	prev := map[string]any{"lang": "fr", "step": float64(1), "cart": []any{"a"}, "old": true}
+	next := map[string]any{"lang": "fr", "step": 2, "cart": []string{"a"}, "topic": "billing"}
+
+	diff, err := diffState(prev, next)
+	if err != nil {
+		t.Fatalf("diffState failed: %v", err)
+	}
+	if len(diff.Set) != 2 || diff.Set["step"] != 2 || diff.Set["topic"] != "billing" {
+		t.Errorf("Set = %v, want step and topic", diff.Set)
+	}
+	if !slices.Equal(diff.Removed, []string{"old"}) {
+		t.Errorf("Removed = %v, want [old]", diff.Removed)
+	}
+
+	diff, err = diffState(map[string]any{"step": float64(2)}, map[string]any{"step": 2})
+	if err != nil || !diff.empty() {
+		t.Errorf("expected no change, got %+v, %v", diff, err)
+	}
+}
+
+func TestAuditEvent(t *testing.T) {
+	sess := createTestSession("sess-audit", "test_app", "user-audit")
+	evt := createTestEvent("evt-1", "agent")
+	evt.Actions.StateDelta = map[string]any{"topic": "billing", session.KeyPrefixTemp + "scratch": 1}
+
+	rec := auditEvent(context.Background(), sess, evt)
+	if rec.action != AuditAppend || rec.actor != "agent" || rec.eventID != "evt-1" {
+		t.Errorf("unexpected record: %+v", rec)
+	}
+	if len(rec.diff.Set) != 1 || rec.diff.Set["topic"] != "billing" {
+		t.Errorf("diff = %+v, want the delta without temp keys", rec.diff)
+	}
+
+	rec = auditEvent(WithAuditActor(context.Background(), "operator-1"), sess, createTestEvent("evt-2", "user"))
+	if rec.actor != "operator-1" || rec.diff != nil {
+		t.Errorf("unexpected record: %+v", rec)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	base, client := setupTestDB(t)
+	if base == nil {
+		return
+	}
+	defer base.Close()
+	defer client.Close()
+
+	ctx := context.Background()
+
+	persister, err := NewSessionPersister(ctx, client, WithAuditLog(), WithAsyncBufferSize(0))
+	if err != nil {
+		t.Fatalf("Failed to create persister: %v", err)
+	}
+	defer persister.Close()
+	if _, err := client.DB().ExecContext(ctx, "DELETE FROM session_audit WHERE app_name LIKE 'test_%'"); err != nil {
+		t.Fatalf("Failed to clean up audit log: %v", err)
+	}
+
+	opCtx := WithAuditActor(ctx, "operator-1")
+	sess := createTestSessionWithState("sess-audit", "test_app", "user-audit", map[string]any{"lang": "fr"})
+	if err := persister.PersistSession(opCtx, sess); err != nil {
+		t.Fatalf("PersistSession failed: %v", err)
+	}
+	// NOTE: An update leaving the state as it was is not recorded
+	if err := persister.PersistSession(opCtx, sess); err != nil {
+		t.Fatalf("PersistSession failed: %v", err)
+	}
+
+	evt := createTestEvent("evt-audit", "agent")
+	evt.Actions.StateDelta = map[string]any{"topic": "billing"}
+	if err := persister.PersistEvent(ctx, sess, evt); err != nil {
+		t.Fatalf("PersistEvent failed: %v", err)
+	}
+
+	sess.state = &mockState{data: map[string]any{"topic": "billing"}}
+	if err := persister.PersistSession(opCtx, sess); err != nil {
+		t.Fatalf("PersistSession failed: %v", err)
+	}
+	if err := persister.DeleteSession(opCtx, "test_app", "user-audit", "sess-audit"); err != nil {
+		t.Fatalf("DeleteSession failed: %v", err)
+	}
+
+	entries, err := persister.AuditLog(ctx, AuditQuery{AppName: "test_app", SessionID: "sess-audit"})
+	if err != nil {
+		t.Fatalf("AuditLog failed: %v", err)
+	}
+	var actions []string
+	for _, entry := range entries {
+		actions = append(actions, entry.Action)
+	}
+	if want := []string{AuditCreate, AuditAppend, AuditUpdate, AuditDelete}; !slices.Equal(actions, want) {
+		t.Fatalf("actions = %v, want %v", actions, want)
+	}
+
+	if entries[0].Actor != "operator-1" || entries[0].Diff == nil || entries[0].Diff.Set["lang"] != "fr" {
+		t.Errorf("unexpected create entry: %+v", entries[0])
+	}
+	if entries[1].Actor != "agent" || entries[1].EventID != "evt-audit" || entries[1].Diff.Set["topic"] != "billing" {
+		t.Errorf("unexpected append entry: %+v", entries[1])
+	}
+	if !slices.Equal(entries[2].Diff.Removed, []string{"lang"}) {
+		t.Errorf("unexpected update entry: %+v", entries[2].Diff)
+	}
+	if entries[3].Diff != nil || entries[3].CreatedAt.IsZero() {
+		t.Errorf("unexpected delete entry: %+v", entries[3])
+	}
+
+	page, err := persister.AuditLog(ctx, AuditQuery{
+		AppName: "test_app", Actor: "operator-1", AfterID: entries[0].ID, Since: time.Now().Add(-time.Hour), Limit: 1,
+	})
+	if err != nil || len(page) != 1 || page[0].Action != AuditUpdate {
+		t.Errorf("expected the update entry, got %+v, %v", page, err)
+	}
+
+	if _, err := base.AuditLog(ctx, AuditQuery{AppName: "test_app"}); !errors.Is(err, ErrAuditDisabled) {
+		t.Errorf("expected ErrAuditDisabled, got %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package postgres
+
+import "sync/atomic"
+
+// BackpressurePolicy decides what happens to an async operation whose worker
+// queue is full.
+type BackpressurePolicy int
+
+const (
+	// BackpressureFallbackSync runs the operation synchronously, in the calling
+	// goroutine.
+	BackpressureFallbackSync BackpressurePolicy = iota
+
+	// BackpressureBlock waits for room in the queue, or for the context of the
+	// call to be done. Close fails the waiting calls with ErrPersisterClosed.
+	BackpressureBlock
+
+	// BackpressureDropOldest drops the oldest operation of the queue to make room.
+	// The dropped operation is logged and lost, and the session it belongs to may
+	// miss its row or events.
+	BackpressureDropOldest
+
+	// BackpressureReject fails the operation with ErrQueueFull.
+	BackpressureReject
+)
+
+// WithBackpressurePolicy sets what happens to an async operation whose worker
+// queue is full. Default: BackpressureFallbackSync.
+func WithBackpressurePolicy(policy BackpressurePolicy) PersisterOption {
+	return func(p *SessionPersister) { p.backpressure = policy }
+}
+
+// PersisterStats reports the async queues of a SessionPersister.
+type PersisterStats struct {
+	// QueueDepth and QueueCapacity sum the queues of the async workers.
+	QueueDepth    int
+	QueueCapacity int
+
+	// Dropped counts the operations dropped by BackpressureDropOldest.
+	Dropped int64
+
+	// Rejected counts the operations failed by BackpressureReject.
+	Rejected int64
+
+	// SyncFallbacks counts the operations run synchronously by
+	// BackpressureFallbackSync.
+	SyncFallbacks int64
+}
+
+// queueStats counts the operations the backpressure policy applied to.
+type queueStats struct {
+	dropped       atomic.Int64
+	rejected      atomic.Int64
+	syncFallbacks atomic.Int64
+}
+
+// Stats returns the depth of the async queues and the operations the
+// backpressure policy applied to. The depth is zero in sync mode.
+func (p *SessionPersister) Stats() PersisterStats {
+	stats := PersisterStats{
+		Dropped:       p.stats.dropped.Load(),
+		Rejected:      p.stats.rejected.Load(),
+		SyncFallbacks: p.stats.syncFallbacks.Load(),
+	}
+	for _, queue := range p.asyncChans {
+		stats.QueueDepth += len(queue)
+		stats.QueueCapacity += cap(queue)
+	}
+
+	return stats
+}
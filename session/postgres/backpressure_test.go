@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+)
+
+func TestBackpressurePolicy(t *testing.T) {
+	sess := createTestSession("sess-full", "test_app", "user-full")
+	first := asyncOperation{operationType: operationSession, sess: sess}
+	second := asyncOperation{operationType: operationEvent, sess: sess, evt: createTestEvent("evt-full", "user")}
+
+	// newFullPersister returns a persister whose single queue holds first.
+	newFullPersister := func(policy BackpressurePolicy) *SessionPersister {
+		p := &SessionPersister{
+			logger:       discardlog.NewDiscardLog(),
+			asyncChans:   []chan asyncOperation{make(chan asyncOperation, 1)},
+			backpressure: policy,
+		}
+		if queued, err := p.enqueue(context.Background(), first); !queued || err != nil {
+			t.Fatalf("enqueue() = %v, %v, want the operation queued", queued, err)
+		}
+		return p
+	}
+
+	t.Run("fallback sync", func(t *testing.T) {
+		p := newFullPersister(BackpressureFallbackSync)
+		if queued, err := p.enqueue(context.Background(), second); queued || err != nil {
+			t.Errorf("enqueue() = %v, %v, want a sync fallback", queued, err)
+		}
+		if stats := p.Stats(); stats.SyncFallbacks != 1 || stats.QueueDepth != 1 || stats.QueueCapacity != 1 {
+			t.Errorf("unexpected stats: %+v", stats)
+		}
+	})
+
+	t.Run("block", func(t *testing.T) {
+		p := newFullPersister(BackpressureBlock)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := p.enqueue(ctx, second); !errors.Is(err, context.Canceled) {
+			t.Errorf("enqueue() error = %v, want context.Canceled", err)
+		}
+	})
+
+	t.Run("drop oldest", func(t *testing.T) {
+		p := newFullPersister(BackpressureDropOldest)
+		if queued, err := p.enqueue(context.Background(), second); !queued || err != nil {
+			t.Errorf("enqueue() = %v, %v, want the operation queued", queued, err)
+		}
+		if op := <-p.asyncChans[0]; op.operationType != operationEvent {
+			t.Errorf("queued %s operation, want the newest one", op.operationType)
+		}
+		if stats := p.Stats(); stats.Dropped != 1 {
+			t.Errorf("dropped = %d, want 1", stats.Dropped)
+		}
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		p := newFullPersister(BackpressureReject)
+		if _, err := p.enqueue(context.Background(), second); !errors.Is(err, ErrQueueFull) {
+			t.Errorf("enqueue() error = %v, want ErrQueueFull", err)
+		}
+		if stats := p.Stats(); stats.Rejected != 1 {
+			t.Errorf("rejected = %d, want 1", stats.Rejected)
+		}
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		p := newFullPersister(BackpressureBlock)
+		if err := p.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+		if _, err := p.enqueue(context.Background(), second); !errors.Is(err, ErrPersisterClosed) {
+			t.Errorf("enqueue() error = %v, want ErrPersisterClosed", err)
+		}
+	})
+}
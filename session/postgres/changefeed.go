@@ -0,0 +1,167 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/jackc/pgx/v5"
+)
+
+// sessionChangeBuffer is the number of changes buffered for a slow subscriber
+// before the notifications queue up in the connection.
+const sessionChangeBuffer = 64
+
+// ChangeType is the kind of change a SessionChange reports.
+type ChangeType string
+
+const (
+	// ChangeCreated reports a session row inserted.
+	ChangeCreated ChangeType = "created"
+
+	// ChangeUpdated reports a session row updated: its state, its tags, or its
+	// event counter on every persisted event.
+	ChangeUpdated ChangeType = "updated"
+
+	// ChangeDeleted reports a session row deleted, by DeleteSession or Prune.
+	ChangeDeleted ChangeType = "deleted"
+)
+
+// SessionChange is a change of the sessions table, notified by the trigger of
+// WithChangeFeed.
+type SessionChange struct {
+	Type      ChangeType `json:"type"`
+	AppName   string     `json:"app_name"`
+	UserID    string     `json:"user_id"`
+	SessionID string     `json:"session_id"`
+
+	// LastUpdateTime is the last update time of the session row, before its
+	// deletion for ChangeDeleted.
+	LastUpdateTime time.Time `json:"last_update_time"`
+}
+
+// changeFeedSchema creates the trigger notifying the changes of the sessions
+// table on channel %[3]s.
+const changeFeedSchema = `
+	CREATE OR REPLACE FUNCTION %[2]s() RETURNS trigger AS $$
+	DECLARE
+		r RECORD;
+	BEGIN
+		IF TG_OP = 'DELETE' THEN
+			r := OLD;
+		ELSE
+			r := NEW;
+		END IF;
+		PERFORM pg_notify('%[3]s', json_build_object(
+			'type', CASE TG_OP WHEN 'INSERT' THEN 'created' WHEN 'UPDATE' THEN 'updated' ELSE 'deleted' END,
+			'app_name', r.app_name,
+			'user_id', r.user_id,
+			'session_id', r.id,
+			'last_update_time', r.last_update_time
+		)::text);
+		RETURN NULL;
+	END;
+	$$ LANGUAGE plpgsql;
+
+	DROP TRIGGER IF EXISTS %[4]s ON %[1]s;
+	CREATE TRIGGER %[4]s AFTER INSERT OR UPDATE OR DELETE ON %[1]s
+		FOR EACH ROW EXECUTE PROCEDURE %[2]s();
+`
+
+// WithChangeFeed installs a trigger on the sessions table notifying every session
+// created, updated or deleted with LISTEN/NOTIFY, so analytics or cache
+// invalidation react to the persisted changes: receive them with
+// Client.SubscribeSessionChanges. The trigger notifies the changes committed by
+// any writer of the table, at a small cost per write. It is not supported with
+// Config.DistributedSQL.
+func WithChangeFeed() PersisterOption {
+	return func(p *SessionPersister) { p.changeFeed = true }
+}
+
+// changeChannel returns the notification channel of the sessions table.
+func (c *Client) changeChannel() string {
+	if c.schema == "" {
+		return c.sessionsTable + "_changes"
+	}
+	return c.schema + "_" + c.sessionsTable + "_changes"
+}
+
+// initChangeFeed creates the trigger of WithChangeFeed.
+func (p *SessionPersister) initChangeFeed(ctx context.Context) error {
+	if p.client.DistributedSQL() {
+		return errors.New("the change feed is not supported with DistributedSQL")
+	}
+
+	schema := fmt.Sprintf(changeFeedSchema,
+		p.client.SessionsTableName(),
+		p.client.tableName(p.client.sessionsTable+"_notify_change"),
+		p.client.changeChannel(),
+		p.client.sessionsTable+"_change_feed")
+
+	// NOTE: The function body holds semicolons, so the script is not split
+	if _, err := p.client.DB().ExecContext(ctx, schema); err != nil {
+		p.logger.Errorf("failed to create change feed trigger: %v", err)
+		return fmt.Errorf("failed to create change feed trigger: %w", err)
+	}
+
+	return nil
+}
+
+// SubscribeSessionChanges receives the changes of the sessions table notified by
+// the trigger of WithChangeFeed, which a persister of the same tables must have
+// installed. It listens on a connection of its own, outside of the pool, with
+// either driver. The channel is closed when ctx is done or the connection fails.
+//
+// Changes are delivered at most once: those committed while no subscriber is
+// connected are lost, so a consumer should resynchronize after subscribing.
+func (c *Client) SubscribeSessionChanges(ctx context.Context) (<-chan SessionChange, error) {
+	if c.distributedSQL {
+		return nil, errors.New("the change feed is not supported with DistributedSQL")
+	}
+
+	conn, err := pgx.Connect(ctx, c.connStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect for session changes: %w", err)
+	}
+
+	// NOTE: LISTEN returns once the subscription is registered, so no change
+	// committed after SubscribeSessionChanges returns is missed
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{c.changeChannel()}.Sanitize()); err != nil {
+		_ = conn.Close(context.Background())
+		return nil, fmt.Errorf("failed to listen for session changes: %w", err)
+	}
+
+	c.logger.Debugf("subscribed to session changes: channel=%s", c.changeChannel())
+
+	out := make(chan SessionChange, sessionChangeBuffer)
+	go func() {
+		defer close(out)
+		defer func() { _ = conn.Close(context.Background()) }()
+
+		for {
+			notification, err := conn.WaitForNotification(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					c.logger.Warnf("session change feed stopped: %v", err)
+				}
+				return
+			}
+
+			var change SessionChange
+			if err := sonic.UnmarshalString(notification.Payload, &change); err != nil {
+				c.logger.Warnf("invalid session change on %s: %v", notification.Channel, err)
+				continue
+			}
+
+			select {
+			case out <- change:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
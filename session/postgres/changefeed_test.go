@@ -0,0 +1,85 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestChangeChannel(t *testing.T) {
+	c := &Client{sessionsTable: "sessions"}
+	if got := c.changeChannel(); got != "sessions_changes" {
+		t.Errorf("changeChannel() = %q, want sessions_changes", got)
+	}
+
+	c.schema = "agents"
+	if got := c.changeChannel(); got != "agents_sessions_changes" {
+		t.Errorf("changeChannel() = %q, want agents_sessions_changes", got)
+	}
+}
+
+func TestChangeFeed(t *testing.T) {
+	persister, client := setupTestDB(t)
+	if persister == nil {
+		return
+	}
+	defer persister.Close()
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	feed, err := NewSessionPersister(ctx, client, WithAsyncBufferSize(0), WithChangeFeed())
+	if err != nil {
+		t.Fatalf("Failed to create persister: %v", err)
+	}
+	defer feed.Close()
+	defer func() {
+		_, _ = client.DB().ExecContext(context.Background(), `DROP TRIGGER IF EXISTS sessions_change_feed ON sessions`)
+	}()
+
+	changes, err := client.SubscribeSessionChanges(ctx)
+	if err != nil {
+		t.Fatalf("SubscribeSessionChanges failed: %v", err)
+	}
+
+	// next returns the next change of the test session.
+	next := func() SessionChange {
+		t.Helper()
+		for {
+			select {
+			case change, ok := <-changes:
+				if !ok {
+					t.Fatal("change feed closed")
+				}
+				if change.AppName == "test_app" && change.SessionID == "sess-feed" {
+					return change
+				}
+			case <-ctx.Done():
+				t.Fatal("timed out waiting for a session change")
+			}
+		}
+	}
+
+	sess := createTestSessionWithState("sess-feed", "test_app", "user-feed", map[string]any{"step": 1})
+	if err := feed.PersistSession(ctx, sess); err != nil {
+		t.Fatalf("PersistSession failed: %v", err)
+	}
+	if change := next(); change.Type != ChangeCreated || change.UserID != "user-feed" {
+		t.Errorf("unexpected change: %+v", change)
+	}
+
+	if err := feed.PersistSession(ctx, sess); err != nil {
+		t.Fatalf("PersistSession failed: %v", err)
+	}
+	if change := next(); change.Type != ChangeUpdated {
+		t.Errorf("expected an update, got %+v", change)
+	}
+
+	if err := feed.DeleteSession(ctx, "test_app", "user-feed", "sess-feed"); err != nil {
+		t.Fatalf("DeleteSession failed: %v", err)
+	}
+	if change := next(); change.Type != ChangeDeleted || change.LastUpdateTime.IsZero() {
+		t.Errorf("expected a deletion, got %+v", change)
+	}
+}
@@ -0,0 +1,109 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bytedance/sonic"
+	"github.com/kydenul/k-adk/compression"
+	"github.com/kydenul/k-adk/encryption"
+)
+
+// errNoEncryptor is returned for an encrypted payload read without an encryptor.
+var errNoEncryptor = errors.New("payload is encrypted and no encryptor is set")
+
+// WithCodec compresses the session states and events written to PostgreSQL with
+// c, e.g. compression.Zstd(). A compressed payload is stored as a JSON string in
+// its JSONB column, so the columns can no longer be queried by their fields.
+// Payloads written before, or with another codec, stay readable.
+func WithCodec(c compression.Codec) PersisterOption {
+	return func(p *SessionPersister) { p.codec = c }
+}
+
+// WithEncryption encrypts the session states and events written to PostgreSQL
+// with the keys of their app, after their compression. Like a compressed payload,
+// an encrypted one is stored as a JSON string in its JSONB column, which
+// encryption.SessionTables rotates. Payloads written before stay readable.
+//
+// The app and user state keys stored by WithScopedState are merged across
+// sessions by the database, and are not encrypted.
+func WithEncryption(enc *encryption.Encryptor) PersisterOption {
+	return func(p *SessionPersister) { p.encryptor = enc }
+}
+
+// EncryptionTargets returns the rotation targets of the tables of c, as
+// encryption.SessionTables does for the default schema and table names.
+func (c *Client) EncryptionTargets() []encryption.Target {
+	targets := []encryption.Target{&encryption.PostgresTarget{
+		DB:         c.db,
+		Schema:     c.schema,
+		Table:      c.sessionsTable,
+		Column:     "state",
+		KeyColumns: []string{"app_name", "user_id", "id"},
+		JSONB:      true,
+	}}
+
+	for _, table := range c.eventsTables() {
+		targets = append(targets, &encryption.PostgresTarget{
+			DB:         c.db,
+			Schema:     c.schema,
+			Table:      table,
+			Column:     "content",
+			KeyColumns: []string{"app_name", "user_id", "session_id", "event_order"},
+			JSONB:      true,
+		})
+	}
+
+	return targets
+}
+
+// encodeJSON compresses a JSON payload with the persister codec and encrypts it
+// with the keys of appName, keeping it valid JSON for its JSONB column.
+func (p *SessionPersister) encodeJSON(ctx context.Context, appName string, data []byte) ([]byte, error) {
+	encoded, err := compression.Encode(p.codec, data)
+	if err != nil {
+		return nil, err
+	}
+	if p.encryptor != nil {
+		if encoded, err = p.encryptor.Encrypt(ctx, appName, encoded); err != nil {
+			return nil, fmt.Errorf("failed to encrypt payload: %w", err)
+		}
+	}
+	if !compression.IsCompressed(encoded) && !encryption.IsEncrypted(encoded) {
+		return encoded, nil
+	}
+
+	return sonic.Marshal(string(encoded))
+}
+
+// unmarshalJSON deserializes a JSONB payload of appName into v, encrypted,
+// compressed or not. enc may be nil if no payload is encrypted.
+func unmarshalJSON(ctx context.Context, enc *encryption.Encryptor, appName string, content []byte, v any) error {
+	if len(content) > 0 && content[0] == '"' {
+		var text string
+		if err := sonic.Unmarshal(content, &text); err != nil {
+			return err
+		}
+
+		payload := []byte(text)
+		if encryption.IsEncrypted(payload) {
+			if enc == nil {
+				return errNoEncryptor
+			}
+
+			var err error
+			if payload, err = enc.Decrypt(ctx, appName, payload); err != nil {
+				return fmt.Errorf("failed to decrypt payload: %w", err)
+			}
+		}
+
+		decoded, err := compression.Decode(payload)
+		if err != nil {
+			return fmt.Errorf("failed to decode payload: %w", err)
+		}
+		content = decoded
+	}
+
+	return sonic.Unmarshal(content, v)
+}
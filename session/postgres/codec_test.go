@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kydenul/k-adk/compression"
+	"github.com/kydenul/k-adk/encryption"
+)
+
+func TestCodecJSON(t *testing.T) {
+	ctx := context.Background()
+	state := `{"notes":"` + strings.Repeat("tool output ", 100) + `"}`
+
+	p := &SessionPersister{codec: compression.Zstd()}
+	stored, err := p.encodeJSON(ctx, "app", []byte(state))
+	if err != nil {
+		t.Fatalf("encodeJSON failed: %v", err)
+	}
+	if !strings.HasPrefix(string(stored), `"z:v1:zstd:`) || len(stored) >= len(state) {
+		t.Fatalf("Unexpected stored payload: %.40s", stored)
+	}
+
+	for name, content := range map[string][]byte{"compressed": stored, "plain": []byte(state)} {
+		t.Run(name, func(t *testing.T) {
+			var got map[string]any
+			if err := unmarshalJSON(ctx, nil, "app", content, &got); err != nil {
+				t.Fatalf("unmarshalJSON failed: %v", err)
+			}
+			if got["notes"] != strings.Repeat("tool output ", 100) {
+				t.Errorf("unmarshalJSON() = %.40v", got)
+			}
+		})
+	}
+
+	t.Run("without codec", func(t *testing.T) {
+		stored, err := (&SessionPersister{}).encodeJSON(ctx, "app", []byte(state))
+		if err != nil || string(stored) != state {
+			t.Errorf("encodeJSON() = %.40s, %v, want the payload as is", stored, err)
+		}
+	})
+}
+
+func TestEncryptedJSON(t *testing.T) {
+	ctx := context.Background()
+	state := `{"notes":"` + strings.Repeat("tool output ", 100) + `"}`
+
+	keys := encryption.NewKeyring()
+	if err := keys.Set("app", encryption.Key{ID: "k1", Material: bytes.Repeat([]byte{1}, 32)}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	enc := encryption.NewEncryptor(keys)
+
+	p := &SessionPersister{codec: compression.Zstd(), encryptor: enc}
+	stored, err := p.encodeJSON(ctx, "app", []byte(state))
+	if err != nil {
+		t.Fatalf("encodeJSON failed: %v", err)
+	}
+	if !strings.HasPrefix(string(stored), `"enc:v1:k1:`) || strings.Contains(string(stored), "tool output") {
+		t.Fatalf("Unexpected stored payload: %.40s", stored)
+	}
+
+	var got map[string]any
+	if err := unmarshalJSON(ctx, enc, "app", stored, &got); err != nil {
+		t.Fatalf("unmarshalJSON failed: %v", err)
+	}
+	if got["notes"] != strings.Repeat("tool output ", 100) {
+		t.Errorf("unmarshalJSON() = %.40v", got)
+	}
+
+	if err := unmarshalJSON(ctx, nil, "app", stored, &got); !errors.Is(err, errNoEncryptor) {
+		t.Errorf("unmarshalJSON() error = %v without encryptor, want errNoEncryptor", err)
+	}
+	if err := unmarshalJSON(ctx, enc, "other", stored, &got); err == nil {
+		t.Error("unmarshalJSON() error = nil for the payload of another app")
+	}
+}
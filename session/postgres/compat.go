@@ -0,0 +1,82 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/kydenul/k-adk/internal/pgerr"
+)
+
+// Serialization retries of Config.DistributedSQL.
+const (
+	serializationRetries = 3
+	serializationBackoff = 20 * time.Millisecond
+)
+
+// DistributedSQL reports whether the client targets a distributed SQL engine,
+// see Config.DistributedSQL.
+func (c *Client) DistributedSQL() bool { return c.distributedSQL }
+
+// execScript runs the statements of a schema script. With DistributedSQL, they
+// run one by one: distributed engines restrict the schema changes of a single
+// transaction, which a multi-statement script is.
+func (c *Client) execScript(ctx context.Context, script string) error {
+	if !c.distributedSQL {
+		_, err := c.db.ExecContext(ctx, script)
+		return err
+	}
+
+	for _, stmt := range splitStatements(script) {
+		if _, err := c.db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitStatements splits a script of the schema constants into its statements.
+// NOTE: The scripts hold no semicolon but the statement terminators
+func splitStatements(script string) []string {
+	var stmts []string
+	for stmt := range strings.SplitSeq(script, ";") {
+		if hasStatement(stmt) {
+			stmts = append(stmts, strings.TrimSpace(stmt))
+		}
+	}
+	return stmts
+}
+
+// hasStatement reports whether s holds more than blanks and comment lines.
+func hasStatement(s string) bool {
+	for line := range strings.SplitSeq(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "--") {
+			return true
+		}
+	}
+	return false
+}
+
+// retrySerializable runs fn, a transaction, again when it fails to serialize with
+// DistributedSQL: distributed engines abort one of two conflicting transactions
+// rather than making it wait, and leave the retry to the client.
+func (p *SessionPersister) retrySerializable(ctx context.Context, fn func() error) error {
+	if !p.client.distributedSQL {
+		return fn()
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt > serializationRetries || !errors.Is(err, pgerr.ErrSerialization) {
+			return err
+		}
+
+		p.logger.Debugf("transaction failed to serialize (attempt %d), retrying: %v", attempt, err)
+		select {
+		case <-time.After(time.Duration(attempt) * serializationBackoff):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	script := fmt.Sprintf(scopedStateSchema, "app_states", "user_states")
+
+	stmts := splitStatements(script)
+	if len(stmts) == 0 {
+		t.Fatal("no statement split from the scoped state schema")
+	}
+	for _, stmt := range stmts {
+		if !hasStatement(stmt) {
+			t.Errorf("empty statement split: %q", stmt)
+		}
+	}
+
+	stmts = splitStatements("-- comment\nCREATE TABLE a (id INT);\n\n  -- trailing comment\n")
+	if len(stmts) != 1 || stmts[0] != "-- comment\nCREATE TABLE a (id INT)" {
+		t.Errorf("unexpected statements: %q", stmts)
+	}
+}
+
+func TestDistributedSQLPartitioning(t *testing.T) {
+	_, err := NewPostgresClient(context.Background(), &Config{
+		ConnStr:        getTestConnString(),
+		DistributedSQL: true,
+		Partitioning:   PartitionRange,
+	})
+	if err == nil {
+		t.Fatal("expected an error for DistributedSQL with partitioning")
+	}
+}
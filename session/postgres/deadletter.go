@@ -0,0 +1,354 @@
+package postgres
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/kydenul/k-adk/internal/pgerr"
+	ksess "github.com/kydenul/k-adk/session"
+	"google.golang.org/adk/session"
+)
+
+// Default retry values.
+const (
+	defaultRetryAttempts   = 3
+	defaultRetryBackoff    = 100 * time.Millisecond
+	defaultRetryMaxBackoff = 10 * time.Second
+)
+
+// deadLetterSchema creates the table of WithDeadLetterTable. payload holds the
+// DeadLetter, encoded as the session states and events are.
+const deadLetterSchema = `
+	CREATE TABLE IF NOT EXISTS %[1]s (
+		id BIGSERIAL PRIMARY KEY,
+		operation VARCHAR(16) NOT NULL,
+		app_name VARCHAR(255) NOT NULL,
+		user_id VARCHAR(255) NOT NULL,
+		session_id VARCHAR(255) NOT NULL,
+		payload JSONB NOT NULL,
+		attempts INT NOT NULL,
+		error TEXT NOT NULL DEFAULT '',
+		failed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_session_persist_dlq_session
+		ON %[1]s(app_name, user_id, session_id);
+`
+
+// RetryConfig configures the retries of the failing async operations.
+type RetryConfig struct {
+	// Optional. MaxAttempts is the number of attempts of an operation, the first
+	// one included. Default: 3.
+	MaxAttempts int
+
+	// Optional. Backoff is the delay before the first retry, doubled on every
+	// retry. Default: 100ms.
+	Backoff time.Duration
+
+	// Optional. MaxBackoff caps the retry delay. Default: 10s.
+	MaxBackoff time.Duration
+}
+
+// WithRetry retries the failing async operations with exponential backoff;
+// constraint violations are not retried. The worker of an operation waits for
+// its retries, so the operations of a session keep their order, and Close waits
+// for them too. Without WithRetry, an async operation is attempted once.
+func WithRetry(cfg RetryConfig) PersisterOption {
+	return func(p *SessionPersister) {
+		if cfg.MaxAttempts <= 0 {
+			cfg.MaxAttempts = defaultRetryAttempts
+		}
+		if cfg.Backoff <= 0 {
+			cfg.Backoff = defaultRetryBackoff
+		}
+		if cfg.MaxBackoff <= 0 {
+			cfg.MaxBackoff = defaultRetryMaxBackoff
+		}
+		p.retry = cfg
+	}
+}
+
+// DeadLetter is an async operation that failed on its last attempt.
+// SessionPersister.ReplayDeadLetter applies it again.
+type DeadLetter struct {
+	// Operation is "session", "event" or "delete".
+	Operation string `json:"operation"`
+	AppName   string `json:"app_name"`
+	UserID    string `json:"user_id"`
+	SessionID string `json:"session_id"`
+
+	// Session holds the state, tags and last update time of a session operation,
+	// as they were when it failed. Its events are not kept.
+	Session *ksess.StoredSession `json:"session,omitempty"`
+
+	// Event is the event of an event operation, as WithEventTransformer stores it.
+	Event *session.Event `json:"event,omitempty"`
+
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// DeadLetterSink receives the async operations a SessionPersister gave up on.
+type DeadLetterSink interface {
+	WriteDeadLetter(ctx context.Context, dl *DeadLetter) error
+}
+
+// DeadLetterFunc is a DeadLetterSink calling a function.
+type DeadLetterFunc func(ctx context.Context, dl *DeadLetter) error
+
+// WriteDeadLetter implements DeadLetterSink.
+func (f DeadLetterFunc) WriteDeadLetter(ctx context.Context, dl *DeadLetter) error { return f(ctx, dl) }
+
+// WithDeadLetter hands the async operations failing on their last attempt to
+// sink, to be replayed with ReplayDeadLetter. A dead letter the sink fails to
+// write is logged and lost. Without a sink, failed operations are only logged.
+func WithDeadLetter(sink DeadLetterSink) PersisterOption {
+	return func(p *SessionPersister) { p.deadLetter = sink }
+}
+
+// WithDeadLetterTable stores the dead letters in the session_persist_dlq table,
+// where ReplayDeadLetters replays them from. The table is in the database that
+// failed: during an outage of PostgreSQL, prefer a sink outside of it, such as a
+// FileDeadLetterSink.
+func WithDeadLetterTable() PersisterOption {
+	return func(p *SessionPersister) {
+		p.deadLetterTable = true
+		p.deadLetter = DeadLetterFunc(p.insertDeadLetter)
+	}
+}
+
+// retryBackoff returns the delay before the retry following attempt.
+func (p *SessionPersister) retryBackoff(attempt int) time.Duration {
+	backoff := p.retry.Backoff << min(attempt-1, 16)
+	if backoff <= 0 || backoff > p.retry.MaxBackoff {
+		backoff = p.retry.MaxBackoff
+	}
+	return backoff
+}
+
+// newDeadLetter returns the dead letter of op, failed after attempts with err.
+func newDeadLetter(op asyncOperation, attempts int, err error) *DeadLetter {
+	dl := &DeadLetter{
+		Operation: op.operationType,
+		Attempts:  attempts,
+		Error:     err.Error(),
+		FailedAt:  time.Now(),
+	}
+	dl.AppName, dl.UserID, dl.SessionID = op.key()
+
+	switch op.operationType {
+	case operationSession:
+		dl.Session = &ksess.StoredSession{
+			ID:             dl.SessionID,
+			AppName:        dl.AppName,
+			UserID:         dl.UserID,
+			State:          map[string]any{},
+			LastUpdateTime: op.sess.LastUpdateTime(),
+		}
+		if state := op.sess.State(); state != nil {
+			dl.Session.State = maps.Collect(state.All())
+		}
+		// NOTE: Nil tags are kept on replay, as for a session not carrying tags
+		if tagged, ok := op.sess.(ksess.Tagged); ok {
+			if dl.Session.Tags = tagged.Tags(); dl.Session.Tags == nil {
+				dl.Session.Tags = map[string]string{}
+			}
+		}
+
+	case operationEvent:
+		dl.Event = op.evt
+	}
+
+	return dl
+}
+
+// sendDeadLetter hands the dead letter of op to the dead letter sink, if any.
+func (p *SessionPersister) sendDeadLetter(op asyncOperation, attempts int, err error) {
+	if p.deadLetter == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultAsyncOpTimeout)
+	defer cancel()
+
+	dl := newDeadLetter(op, attempts, err)
+	// NOTE: The sink stores the transformed event, or none if it has none
+	dl.Event, _ = p.storedEvent(dl.Event)
+	if err := p.deadLetter.WriteDeadLetter(ctx, dl); err != nil {
+		p.logger.Errorf("failed to write dead letter: operation=%s, session=%s, err=%v",
+			dl.Operation, dl.SessionID, err)
+	}
+}
+
+// insertDeadLetter stores a dead letter in session_persist_dlq.
+func (p *SessionPersister) insertDeadLetter(ctx context.Context, dl *DeadLetter) error {
+	data, err := sonic.Marshal(dl)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter: %w", err)
+	}
+	if data, err = p.encodeJSON(ctx, dl.AppName, data); err != nil {
+		return fmt.Errorf("failed to encode dead letter: %w", err)
+	}
+
+	_, err = p.client.DB().ExecContext(ctx, `
+		INSERT INTO `+p.client.tableName("session_persist_dlq")+`
+			(operation, app_name, user_id, session_id, payload, attempts, error, failed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, dl.Operation, dl.AppName, dl.UserID, dl.SessionID, data, dl.Attempts, dl.Error, dl.FailedAt)
+	if err != nil {
+		return pgerr.Wrap("failed to insert dead letter", err)
+	}
+	return nil
+}
+
+// untaggedSession hides the tags of a replayed session stored without tags, so
+// the stored ones are kept.
+type untaggedSession struct{ session.Session }
+
+// ReplayDeadLetter applies a dead letter synchronously.
+func (p *SessionPersister) ReplayDeadLetter(ctx context.Context, dl *DeadLetter) error {
+	switch dl.Operation {
+	case operationSession:
+		if dl.Session == nil {
+			return fmt.Errorf("dead letter of session %s has no session", dl.SessionID)
+		}
+		var sess session.Session = newPGSession(dl.Session)
+		if dl.Session.Tags == nil {
+			sess = untaggedSession{sess}
+		}
+		return p.persistSessionSync(ctx, sess)
+
+	case operationEvent:
+		if dl.Event == nil {
+			return fmt.Errorf("dead letter of session %s has no event", dl.SessionID)
+		}
+		sess := newPGSession(&ksess.StoredSession{ID: dl.SessionID, AppName: dl.AppName, UserID: dl.UserID})
+		return p.persistEventSync(ctx, sess, dl.Event, false)
+
+	case operationDelete:
+		return p.deleteSessionSync(ctx, dl.AppName, dl.UserID, dl.SessionID)
+	}
+
+	return fmt.Errorf("unknown dead letter operation %q", dl.Operation)
+}
+
+// ReplayDeadLetters replays up to limit dead letters of session_persist_dlq, in
+// the order they failed, and removes the replayed ones. It stops at the first
+// one failing again, so the order of a session is kept, and returns the number
+// replayed.
+func (p *SessionPersister) ReplayDeadLetters(ctx context.Context, limit int) (int, error) {
+	table := p.client.tableName("session_persist_dlq")
+	rows, err := p.client.DB().QueryContext(ctx, `
+		SELECT id, app_name, payload FROM `+table+` ORDER BY id LIMIT $1
+	`, limit)
+	if err != nil {
+		return 0, pgerr.Wrap("failed to query dead letters", err)
+	}
+
+	type entry struct {
+		id int64
+		dl DeadLetter
+	}
+	var entries []entry
+	for rows.Next() {
+		var (
+			e       entry
+			appName string
+			payload []byte
+		)
+		if err := rows.Scan(&e.id, &appName, &payload); err != nil {
+			_ = rows.Close()
+			return 0, fmt.Errorf("failed to scan dead letter: %w", err)
+		}
+		if err := unmarshalJSON(ctx, p.encryptor, appName, payload, &e.dl); err != nil {
+			_ = rows.Close()
+			return 0, fmt.Errorf("failed to decode dead letter %d: %w", e.id, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := errors.Join(rows.Err(), rows.Close()); err != nil {
+		return 0, pgerr.Wrap("failed to read dead letters", err)
+	}
+
+	for i, e := range entries {
+		if err := p.ReplayDeadLetter(ctx, &e.dl); err != nil {
+			return i, fmt.Errorf("failed to replay dead letter %d: %w", e.id, err)
+		}
+		if _, err := p.client.DB().ExecContext(ctx, `DELETE FROM `+table+` WHERE id = $1`, e.id); err != nil {
+			return i, pgerr.Wrap("failed to delete dead letter", err)
+		}
+	}
+
+	return len(entries), nil
+}
+
+// FileDeadLetterSink appends the dead letters to a file, one JSON object per
+// line, which ReadDeadLetters reads back. Their states and events are written
+// in clear, whatever WithEncryption.
+type FileDeadLetterSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+var _ DeadLetterSink = (*FileDeadLetterSink)(nil)
+
+// NewFileDeadLetterSink opens, or creates, the dead letter file at path.
+func NewFileDeadLetterSink(path string) (*FileDeadLetterSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dead letter file: %w", err)
+	}
+	return &FileDeadLetterSink{file: file}, nil
+}
+
+// WriteDeadLetter implements DeadLetterSink.
+func (s *FileDeadLetterSink) WriteDeadLetter(_ context.Context, dl *DeadLetter) error {
+	data, err := sonic.Marshal(dl)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write dead letter: %w", err)
+	}
+	return nil
+}
+
+// Close closes the dead letter file. Close the persister first.
+func (s *FileDeadLetterSink) Close() error { return s.file.Close() }
+
+// ReadDeadLetters reads the dead letters written by a FileDeadLetterSink.
+func ReadDeadLetters(r io.Reader) ([]*DeadLetter, error) {
+	var letters []*DeadLetter
+
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if line := bytes.TrimSpace(line); len(line) > 0 {
+			dl := new(DeadLetter)
+			if err := sonic.Unmarshal(line, dl); err != nil {
+				return letters, fmt.Errorf("failed to unmarshal dead letter %d: %w", len(letters)+1, err)
+			}
+			letters = append(letters, dl)
+		}
+		if errors.Is(err, io.EOF) {
+			return letters, nil
+		}
+		if err != nil {
+			return letters, fmt.Errorf("failed to read dead letters: %w", err)
+		}
+	}
+}
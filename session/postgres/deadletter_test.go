@@ -0,0 +1,153 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	var p SessionPersister
+	WithRetry(RetryConfig{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second})(&p)
+
+	if p.retry.MaxAttempts != defaultRetryAttempts {
+		t.Errorf("MaxAttempts = %d, want %d", p.retry.MaxAttempts, defaultRetryAttempts)
+	}
+	for attempt, want := range map[int]time.Duration{
+		1:  100 * time.Millisecond,
+		2:  200 * time.Millisecond,
+		4:  800 * time.Millisecond,
+		5:  time.Second,
+		80: time.Second,
+	} {
+		if got := p.retryBackoff(attempt); got != want {
+			t.Errorf("retryBackoff(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+}
+
+func TestNewDeadLetter(t *testing.T) {
+	sess := createTestSessionWithState("sess-dlq", "test_app", "user-dlq", map[string]any{"step": 2})
+	cause := errors.New("connection refused")
+
+	dl := newDeadLetter(asyncOperation{operationType: operationSession, sess: sess}, 3, cause)
+	if dl.SessionID != "sess-dlq" || dl.Attempts != 3 || dl.Error != "connection refused" {
+		t.Errorf("unexpected dead letter: %+v", dl)
+	}
+	if dl.Session == nil || dl.Session.State["step"] != 2 {
+		t.Fatalf("session state not kept: %+v", dl.Session)
+	}
+	if dl.Session.Tags != nil {
+		t.Errorf("tags = %v for a session without tags, want nil", dl.Session.Tags)
+	}
+
+	dl = newDeadLetter(asyncOperation{
+		operationType: operationDelete, appName: "test_app", userID: "user-dlq", sessionID: "sess-dlq",
+	}, 1, cause)
+	if dl.AppName != "test_app" || dl.UserID != "user-dlq" || dl.Session != nil || dl.Event != nil {
+		t.Errorf("unexpected delete dead letter: %+v", dl)
+	}
+}
+
+func TestFileDeadLetterSink(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "dlq.jsonl")
+
+	sink, err := NewFileDeadLetterSink(path)
+	if err != nil {
+		t.Fatalf("NewFileDeadLetterSink() error = %v", err)
+	}
+	sess := createTestSession("sess-dlq", "test_app", "user-dlq")
+	cause := errors.New("connection refused")
+	for _, op := range []asyncOperation{
+		{operationType: operationSession, sess: sess},
+		{operationType: operationEvent, sess: sess, evt: createTestEvent("evt-dlq", "user")},
+	} {
+		if err := sink.WriteDeadLetter(ctx, newDeadLetter(op, 3, cause)); err != nil {
+			t.Fatalf("WriteDeadLetter() error = %v", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	letters, err := ReadDeadLetters(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadDeadLetters() error = %v", err)
+	}
+	if len(letters) != 2 {
+		t.Fatalf("read %d dead letters, want 2", len(letters))
+	}
+	if letters[0].Operation != operationSession || letters[0].Session == nil {
+		t.Errorf("unexpected session dead letter: %+v", letters[0])
+	}
+	if letters[1].Operation != operationEvent || letters[1].Event == nil || letters[1].Event.ID != "evt-dlq" {
+		t.Errorf("unexpected event dead letter: %+v", letters[1])
+	}
+}
+
+func TestDeadLetterTable(t *testing.T) {
+	persister, client := setupTestDB(t)
+	if persister == nil {
+		return
+	}
+	defer persister.Close()
+	defer client.Close()
+
+	ctx := context.Background()
+
+	failing, err := NewSessionPersister(ctx, client,
+		WithRetry(RetryConfig{MaxAttempts: 2, Backoff: time.Millisecond}),
+		WithDeadLetterTable())
+	if err != nil {
+		t.Fatalf("Failed to create persister: %v", err)
+	}
+	if _, err := client.DB().ExecContext(ctx, "DELETE FROM session_persist_dlq WHERE app_name LIKE 'test_%'"); err != nil {
+		t.Fatalf("Failed to clean up dead letters: %v", err)
+	}
+
+	// NOTE: The event of a session not stored yet fails on every attempt
+	sess := createTestSession("sess-dlq", "test_app", "user-dlq")
+	if err := failing.PersistEvent(ctx, sess, createTestEvent("evt-dlq", "user")); err != nil {
+		t.Fatalf("PersistEvent failed: %v", err)
+	}
+	failing.Close() // Wait for the retries
+
+	var attempts int
+	err = client.DB().QueryRowContext(ctx,
+		"SELECT attempts FROM session_persist_dlq WHERE app_name = $1 AND session_id = $2",
+		"test_app", "sess-dlq").Scan(&attempts)
+	if err != nil {
+		t.Fatalf("Failed to query dead letter: %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want 2", attempts)
+	}
+
+	if err := persister.persistSessionSync(ctx, sess); err != nil {
+		t.Fatalf("persistSessionSync failed: %v", err)
+	}
+	replayed, err := persister.ReplayDeadLetters(ctx, 100)
+	if err != nil {
+		t.Fatalf("ReplayDeadLetters failed: %v", err)
+	}
+	if replayed != 1 {
+		t.Errorf("replayed %d dead letters, want 1", replayed)
+	}
+
+	stored, err := persister.LoadSession(ctx, "test_app", "user-dlq", "sess-dlq")
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+	if len(stored.Events) != 1 || stored.Events[0].ID != "evt-dlq" {
+		t.Errorf("events = %d after replay, want the dead lettered one", len(stored.Events))
+	}
+}
@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kydenul/k-adk/internal/pgerr"
+	ksess "github.com/kydenul/k-adk/session"
+)
+
+var (
+	// ErrPersisterClosed is returned by the operations of a closed SessionPersister.
+	ErrPersisterClosed = errors.New("persister is closed")
+
+	// ErrSessionNotFound is returned by SessionService for a session that is not stored.
+	ErrSessionNotFound = ksess.ErrSessionNotFound
+
+	// ErrSessionExists is returned by SessionService.Create for a session ID in use.
+	ErrSessionExists = errors.New("session already exists")
+
+	// ErrNilSession and ErrNilEvent are returned by SessionService.AppendEvent.
+	ErrNilSession = errors.New("session cannot be nil")
+	ErrNilEvent   = errors.New("event cannot be nil")
+
+	// ErrQueueFull is returned for an async operation whose queue is full, under
+	// BackpressureReject.
+	ErrQueueFull = errors.New("async queue is full")
+
+	// ErrOperationAbandoned is returned by SessionPersister.CloseWithContext, and
+	// set on the dead letters of the async operations abandoned at its deadline.
+	ErrOperationAbandoned = errors.New("async operation abandoned on close")
+
+	// ErrSessionMissing is returned when appending an event to a session that is
+	// not in PostgreSQL, e.g. because it was deleted. SessionPersister stores the
+	// missing session of an event instead.
+	ErrSessionMissing = errors.New("session missing")
+
+	// ErrShardUnavailable is returned when the events shard table of a user is
+	// missing or cannot be locked.
+	ErrShardUnavailable = errors.New("events shard unavailable")
+
+	// ErrConnection is returned when PostgreSQL cannot be reached.
+	ErrConnection = pgerr.ErrConnection
+
+	// ErrConstraintViolation is returned when a write violates a constraint.
+	ErrConstraintViolation = pgerr.ErrConstraint
+)
+
+// shardError wraps an error of a query on the events shard table, as pgerr.Wrap
+// does, and with ErrShardUnavailable if the table is unavailable.
+func shardError(msg, tableName string, err error) error {
+	if errors.Is(pgerr.Classify(err), pgerr.ErrUnavailableTable) {
+		return fmt.Errorf("%s: %w: %s: %w", msg, ErrShardUnavailable, tableName, err)
+	}
+	return pgerr.Wrap(msg, err)
+}
@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/kydenul/k-adk/internal/pgerr"
+	ksess "github.com/kydenul/k-adk/session"
+)
+
+var _ ksess.HealthChecker = (*SessionPersister)(nil)
+
+// Ping checks that PostgreSQL is reachable. It waits for a connection of the
+// pool, so a pool exhausted for longer than the deadline of ctx fails it.
+func (c *Client) Ping(ctx context.Context) error {
+	if err := c.db.PingContext(ctx); err != nil {
+		return pgerr.Wrap("postgres ping failed", err)
+	}
+	return nil
+}
+
+// Ping checks that PostgreSQL is reachable, see Client.Ping.
+func (p *SessionPersister) Ping(ctx context.Context) error {
+	return p.client.Ping(ctx)
+}
+
+// Healthy checks that the persister is open, PostgreSQL reachable and the async
+// queues not saturated: it fails with ksess.ErrQueueSaturated when they are
+// ksess.QueueSaturation full.
+func (p *SessionPersister) Healthy(ctx context.Context) error {
+	p.mu.RLock()
+	closed := p.closed
+	p.mu.RUnlock()
+	if closed {
+		return ErrPersisterClosed
+	}
+
+	if err := p.Ping(ctx); err != nil {
+		return err
+	}
+
+	stats := p.Stats()
+	return ksess.CheckQueue(stats.QueueDepth, stats.QueueCapacity)
+}
@@ -0,0 +1,30 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPersisterHealth(t *testing.T) {
+	persister, client := setupTestDB(t)
+	if persister == nil {
+		return
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	if err := persister.Ping(ctx); err != nil {
+		t.Errorf("Ping failed: %v", err)
+	}
+	if err := persister.Healthy(ctx); err != nil {
+		t.Errorf("Healthy failed: %v", err)
+	}
+
+	if err := persister.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := persister.Healthy(ctx); !errors.Is(err, ErrPersisterClosed) {
+		t.Errorf("expected ErrPersisterClosed, got %v", err)
+	}
+}
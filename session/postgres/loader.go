@@ -0,0 +1,269 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/kydenul/k-adk/internal/pgerr"
+	ksess "github.com/kydenul/k-adk/session"
+	"google.golang.org/adk/session"
+)
+
+var _ ksess.RecentLoader = (*SessionPersister)(nil)
+
+// LoadSession reads a persisted session and its events, in order. It returns
+// nil, nil if the session is not stored.
+func (p *SessionPersister) LoadSession(
+	ctx context.Context,
+	appName, userID, sessionID string,
+) (*ksess.StoredSession, error) {
+	return p.loadSession(ctx, appName, userID, sessionID, 0, time.Time{})
+}
+
+// loadSession reads a persisted session with the events loadEvents reads.
+func (p *SessionPersister) loadSession(
+	ctx context.Context,
+	appName, userID, sessionID string,
+	numRecent int,
+	after time.Time,
+) (*ksess.StoredSession, error) {
+	stored := &ksess.StoredSession{
+		ID:      sessionID,
+		AppName: appName,
+		UserID:  userID,
+		State:   map[string]any{},
+	}
+
+	var (
+		stateJSON, tagsJSON []byte
+		ttlSeconds          int64
+	)
+	err := p.client.DB().QueryRowContext(ctx, `
+		SELECT state, tags, last_update_time, parent_id, fork_event_id, ttl_seconds
+		FROM `+p.client.SessionsTableName()+`
+		WHERE app_name = $1 AND user_id = $2 AND id = $3
+	`, appName, userID, sessionID).Scan(&stateJSON, &tagsJSON, &stored.LastUpdateTime,
+		&stored.ParentID, &stored.ForkEventID, &ttlSeconds)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		p.logger.Errorf("failed to load session %s: %v", sessionID, err)
+		return nil, pgerr.Wrap("failed to load session", err)
+	}
+	stored.TTL = time.Duration(ttlSeconds) * time.Second
+	if len(stateJSON) > 0 {
+		if err := unmarshalJSON(ctx, p.encryptor, appName, stateJSON, &stored.State); err != nil {
+			p.logger.Warnf("failed to unmarshal session state: session=%s, err=%v", sessionID, err)
+		}
+	}
+	if p.scopedState {
+		if err := p.loadScopedState(ctx, appName, userID, stored.State); err != nil {
+			p.logger.Errorf("failed to load scoped state of session %s: %v", sessionID, err)
+			return nil, err
+		}
+	}
+	if len(tagsJSON) > 0 {
+		if err := sonic.Unmarshal(tagsJSON, &stored.Tags); err != nil {
+			p.logger.Warnf("failed to unmarshal session tags: session=%s, err=%v", sessionID, err)
+		}
+		if len(stored.Tags) == 0 {
+			stored.Tags = nil
+		}
+	}
+
+	stored.Events, err = p.loadEvents(ctx, appName, userID, sessionID, numRecent, after)
+	if err != nil {
+		p.logger.Errorf("failed to load events of session %s: %v", sessionID, err)
+		return nil, err
+	}
+
+	p.logger.Debugf("session loaded: session=%s, events=%d", sessionID, len(stored.Events))
+
+	return stored, nil
+}
+
+// loadEvents reads the events of a session in order: the last numRecent ones, or
+// all if numRecent is 0, with a timestamp at or after after.
+func (p *SessionPersister) loadEvents(
+	ctx context.Context,
+	appName, userID, sessionID string,
+	numRecent int,
+	after time.Time,
+) ([]*session.Event, error) {
+	// NOTE: A NULL limit reads every event
+	limit := sql.NullInt64{Int64: int64(numRecent), Valid: numRecent > 0}
+
+	tableName := p.client.GetEventsTableName(userID)
+	//nolint:gosec // table name is generated internally
+	query := `SELECT content FROM (SELECT content, event_order FROM ` + tableName +
+		` WHERE app_name = $1 AND user_id = $2 AND session_id = $3 AND timestamp >= $4` +
+		` ORDER BY event_order DESC LIMIT $5) recent ORDER BY event_order`
+
+	rows, err := p.client.stmts.QueryContext(ctx, query, appName, userID, sessionID, after, limit)
+	if err != nil {
+		return nil, shardError("failed to load session events", tableName, err)
+	}
+	defer rows.Close()
+
+	var events []*session.Event
+	for rows.Next() {
+		var content []byte
+		if err := rows.Scan(&content); err != nil {
+			return nil, pgerr.Wrap("failed to scan session event", err)
+		}
+
+		var evt session.Event
+		if err := unmarshalJSON(ctx, p.encryptor, appName, content, &evt); err != nil {
+			p.logger.Warnf("failed to unmarshal event of session %s: %v", sessionID, err)
+			continue
+		}
+		events = append(events, &evt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, pgerr.Wrap("failed to iterate session events", err)
+	}
+
+	return events, nil
+}
+
+// ListSessionIDs returns the IDs of the persisted sessions of a user, most
+// recently updated first.
+func (p *SessionPersister) ListSessionIDs(ctx context.Context, appName, userID string) ([]string, error) {
+	rows, err := p.client.stmts.QueryContext(ctx, `
+		SELECT id FROM `+p.client.SessionsTableName()+`
+		WHERE app_name = $1 AND user_id = $2
+		ORDER BY last_update_time DESC
+	`, appName, userID)
+	if err != nil {
+		p.logger.Errorf("failed to list sessions of user %s: %v", userID, err)
+		return nil, pgerr.Wrap("failed to list sessions", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, pgerr.Wrap("failed to scan session ID", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, pgerr.Wrap("failed to iterate sessions", err)
+	}
+
+	return ids, nil
+}
+
+// ListRecentSessions returns up to limit sessions of appName updated at or after
+// since, most recently updated first, starting after the cursor.
+func (p *SessionPersister) ListRecentSessions(
+	ctx context.Context,
+	appName string,
+	since time.Time,
+	after *ksess.SessionRef,
+	limit int,
+) ([]ksess.SessionRef, error) {
+	sessionsTable := p.client.SessionsTableName()
+	//nolint:gosec // table name is validated by the client
+	query := `
+		SELECT user_id, id, last_update_time FROM ` + sessionsTable + `
+		WHERE app_name = $1 AND last_update_time >= $2
+		ORDER BY last_update_time DESC, user_id DESC, id DESC
+		LIMIT $3
+	`
+	args := []any{appName, since, limit}
+	if after != nil {
+		//nolint:gosec // table name is validated by the client
+		query = `
+			SELECT user_id, id, last_update_time FROM ` + sessionsTable + `
+			WHERE app_name = $1 AND last_update_time >= $2
+				AND (last_update_time, user_id, id) < ($4, $5, $6)
+			ORDER BY last_update_time DESC, user_id DESC, id DESC
+			LIMIT $3
+		`
+		args = append(args, after.LastUpdateTime, after.UserID, after.ID)
+	}
+
+	rows, err := p.client.stmts.QueryContext(ctx, query, args...)
+	if err != nil {
+		p.logger.Errorf("failed to list recent sessions of app %s: %v", appName, err)
+		return nil, pgerr.Wrap("failed to list recent sessions", err)
+	}
+	defer rows.Close()
+
+	var refs []ksess.SessionRef
+	for rows.Next() {
+		ref := ksess.SessionRef{AppName: appName}
+		if err := rows.Scan(&ref.UserID, &ref.ID, &ref.LastUpdateTime); err != nil {
+			return nil, pgerr.Wrap("failed to scan session", err)
+		}
+		refs = append(refs, ref)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, pgerr.Wrap("failed to iterate recent sessions", err)
+	}
+
+	return refs, nil
+}
+
+// FindSessions returns the persisted sessions of appName having all the given
+// tags, with the same values, most recently updated first. An empty userID finds
+// the sessions of every user. The lookup uses the GIN index on the tags column.
+func (p *SessionPersister) FindSessions(
+	ctx context.Context,
+	appName, userID string,
+	tags map[string]string,
+) ([]ksess.SessionRef, error) {
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	tagsJSON, err := sonic.Marshal(tags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal tags: %w", err)
+	}
+
+	sessionsTable := p.client.SessionsTableName()
+	//nolint:gosec // table name is validated by the client
+	query := `
+		SELECT user_id, id, last_update_time FROM ` + sessionsTable + `
+		WHERE app_name = $1 AND tags @> $2::jsonb
+		ORDER BY last_update_time DESC
+	`
+	args := []any{appName, tagsJSON}
+	if userID != "" {
+		//nolint:gosec // table name is validated by the client
+		query = `
+			SELECT user_id, id, last_update_time FROM ` + sessionsTable + `
+			WHERE app_name = $1 AND tags @> $2::jsonb AND user_id = $3
+			ORDER BY last_update_time DESC
+		`
+		args = append(args, userID)
+	}
+
+	rows, err := p.client.stmts.QueryContext(ctx, query, args...)
+	if err != nil {
+		p.logger.Errorf("failed to find sessions of app %s by tags: %v", appName, err)
+		return nil, pgerr.Wrap("failed to find sessions", err)
+	}
+	defer rows.Close()
+
+	var refs []ksess.SessionRef
+	for rows.Next() {
+		ref := ksess.SessionRef{AppName: appName}
+		if err := rows.Scan(&ref.UserID, &ref.ID, &ref.LastUpdateTime); err != nil {
+			return nil, pgerr.Wrap("failed to scan session", err)
+		}
+		refs = append(refs, ref)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, pgerr.Wrap("failed to iterate found sessions", err)
+	}
+
+	return refs, nil
+}
@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLoadSession(t *testing.T) {
+	persister, client := setupTestDB(t)
+	if persister == nil {
+		return
+	}
+	defer persister.Close()
+	defer client.Close()
+
+	ctx := context.Background()
+	sess := createTestSessionWithState("sess-load", "test_app", "user-load", map[string]any{"step": 2})
+	if err := persister.persistSessionSync(ctx, sess); err != nil {
+		t.Fatalf("persistSessionSync failed: %v", err)
+	}
+	for _, id := range []string{"evt-load-1", "evt-load-2", "evt-load-3"} {
+		if err := persister.persistEventSync(ctx, sess, createTestEvent(id, "user"), false); err != nil {
+			t.Fatalf("persistEventSync(%s) failed: %v", id, err)
+		}
+	}
+
+	t.Run("stored session", func(t *testing.T) {
+		stored, err := persister.LoadSession(ctx, "test_app", "user-load", "sess-load")
+		if err != nil || stored == nil {
+			t.Fatalf("LoadSession = %v, %v", stored, err)
+		}
+		if stored.ID != "sess-load" || stored.AppName != "test_app" || stored.UserID != "user-load" {
+			t.Errorf("unexpected session %s/%s/%s", stored.AppName, stored.UserID, stored.ID)
+		}
+		if stored.State["step"] != float64(2) {
+			t.Errorf("state = %v, want step 2", stored.State)
+		}
+
+		var ids []string
+		for _, evt := range stored.Events {
+			ids = append(ids, evt.ID)
+		}
+		if want := []string{"evt-load-1", "evt-load-2", "evt-load-3"}; !slices.Equal(ids, want) {
+			t.Errorf("events = %v, want %v", ids, want)
+		}
+	})
+
+	t.Run("missing session", func(t *testing.T) {
+		stored, err := persister.LoadSession(ctx, "test_app", "user-load", "sess-missing")
+		if stored != nil || err != nil {
+			t.Errorf("LoadSession = %v, %v, want nil, nil", stored, err)
+		}
+	})
+}
+
+func TestListSessionIDs(t *testing.T) {
+	persister, client := setupTestDB(t)
+	if persister == nil {
+		return
+	}
+	defer persister.Close()
+	defer client.Close()
+
+	ctx := context.Background()
+	now := time.Now()
+	for _, s := range []struct {
+		id  string
+		age time.Duration
+	}{
+		{"sess-list-old", 3 * time.Hour},
+		{"sess-list-new", time.Hour},
+		{"sess-list-mid", 2 * time.Hour},
+	} {
+		sess := createTestSession(s.id, "test_app", "user-list")
+		sess.lastUpdateTime = now.Add(-s.age)
+		if err := persister.persistSessionSync(ctx, sess); err != nil {
+			t.Fatalf("persistSessionSync(%s) failed: %v", s.id, err)
+		}
+	}
+	if err := persister.persistSessionSync(ctx, createTestSession("sess-list-other", "test_app", "user-other")); err != nil {
+		t.Fatalf("persistSessionSync failed: %v", err)
+	}
+
+	ids, err := persister.ListSessionIDs(ctx, "test_app", "user-list")
+	if err != nil {
+		t.Fatalf("ListSessionIDs failed: %v", err)
+	}
+	if want := []string{"sess-list-new", "sess-list-mid", "sess-list-old"}; !slices.Equal(ids, want) {
+		t.Errorf("ListSessionIDs = %v, want %v, most recently updated first", ids, want)
+	}
+
+	if ids, err := persister.ListSessionIDs(ctx, "test_app", "user-none"); err != nil || len(ids) != 0 {
+		t.Errorf("ListSessionIDs of a user without sessions = %v, %v", ids, err)
+	}
+}
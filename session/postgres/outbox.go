@@ -0,0 +1,534 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"iter"
+	"sync"
+	"time"
+
+	"github.com/kydenul/k-adk/encryption"
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/log"
+	"google.golang.org/adk/memory"
+	"google.golang.org/adk/session"
+)
+
+// Default memory ingestion values.
+const (
+	defaultIngestInterval   = 5 * time.Second
+	defaultIngestLease      = 2 * time.Minute
+	defaultIngestBatch      = 10
+	defaultIngestMaxBackoff = 10 * time.Minute
+)
+
+// outboxSchema creates the memory outbox and the per-session ingestion state.
+//
+// memory_outbox holds at most one pending entry per session; target_order is the
+// last event of a completed turn that must reach memory. memory_ingestion_state
+// records, per session, the last event order that was ingested.
+const outboxSchema = `
+	CREATE TABLE IF NOT EXISTS %[1]s (
+		app_name VARCHAR(255) NOT NULL,
+		user_id VARCHAR(255) NOT NULL,
+		session_id VARCHAR(255) NOT NULL,
+		target_order INT NOT NULL,
+		attempts INT NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		available_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (app_name, user_id, session_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_memory_outbox_available ON %[1]s(available_at);
+
+	CREATE TABLE IF NOT EXISTS %[2]s (
+		app_name VARCHAR(255) NOT NULL,
+		user_id VARCHAR(255) NOT NULL,
+		session_id VARCHAR(255) NOT NULL,
+		ingested_order INT NOT NULL,
+		ingested_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (app_name, user_id, session_id)
+	);
+`
+
+// outboxSchema returns outboxSchema with the table names of c.
+func (c *Client) outboxSchema() string {
+	return fmt.Sprintf(outboxSchema, c.tableName("memory_outbox"), c.tableName("memory_ingestion_state"))
+}
+
+// WithMemoryOutbox makes the persister record completed turns in the memory_outbox
+// table, in the same transaction as the event that completes them. A MemoryIngester
+// then feeds them into the memory service.
+func WithMemoryOutbox() PersisterOption {
+	return func(p *SessionPersister) { p.memoryOutbox = true }
+}
+
+// completesTurn reports whether evt is the final answer of an agent turn, after
+// which the session is worth ingesting into memory.
+func completesTurn(evt *session.Event) bool {
+	if evt == nil || evt.Partial || evt.Author == "" || evt.Author == "user" {
+		return false
+	}
+	if evt.Content == nil || len(evt.Content.Parts) == 0 {
+		return false
+	}
+
+	for _, part := range evt.Content.Parts {
+		if part.FunctionCall != nil || part.FunctionResponse != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// enqueueOutbox records that the session must be ingested up to order. It runs in
+// the transaction that inserts the event.
+func (p *SessionPersister) enqueueOutbox(
+	ctx context.Context,
+	tx *sql.Tx,
+	appName, userID, sessionID string,
+	order int,
+) error {
+	//nolint:gosec // table name is validated by the client
+	stmt := `
+		INSERT INTO ` + p.client.tableName("memory_outbox") + ` AS o (app_name, user_id, session_id, target_order)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (app_name, user_id, session_id) DO UPDATE
+		SET target_order = GREATEST(o.target_order, EXCLUDED.target_order)
+	`
+
+	if _, err := tx.ExecContext(ctx, stmt, appName, userID, sessionID, order); err != nil {
+		return fmt.Errorf("failed to enqueue memory outbox: %w", err)
+	}
+	return nil
+}
+
+// IngesterConfig configures a MemoryIngester.
+type IngesterConfig struct {
+	// Optional. Interval between polls of the outbox. Default: 5s.
+	Interval time.Duration
+
+	// Optional. Lease is how long a claimed entry is hidden from other workers.
+	// An entry whose worker crashed is retried once its lease expires. Default: 2m.
+	Lease time.Duration
+
+	// Optional. BatchSize is the maximum number of entries claimed per poll. Default: 10.
+	BatchSize int
+
+	// Optional. MaxBackoff caps the retry delay of failing entries. Default: 10m.
+	MaxBackoff time.Duration
+
+	// Optional. Encryptor decrypts the sessions written by a persister with
+	// WithEncryption.
+	Encryptor *encryption.Encryptor
+
+	// Optional. Logger for logging. Falls back to the client's logger.
+	Logger log.Logger
+}
+
+// MemoryIngester feeds the sessions recorded in the memory outbox into a memory
+// service exactly once.
+//
+// Ingestion is tracked per session by the last ingested event order. A claimed
+// entry is leased, so a crash mid-ingestion only delays it; the watermark advances
+// in the same transaction that completes the entry, and every retry starts from
+// the watermark. A retry after a crash between the memory write and that commit
+// re-sends the same events, which memory/postgres upserts by event ID, so each
+// event is stored once.
+type MemoryIngester struct {
+	logger log.Logger
+
+	client *Client
+	memory memory.Service
+	cfg    IngesterConfig
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewMemoryIngester creates a MemoryIngester and the outbox tables if needed.
+// Call Start to begin processing.
+func NewMemoryIngester(
+	ctx context.Context,
+	client *Client,
+	memSvc memory.Service,
+	cfg IngesterConfig,
+) (*MemoryIngester, error) {
+	if client == nil {
+		return nil, errors.New("postgres client cannot be nil")
+	}
+	if memSvc == nil {
+		return nil, errors.New("memory service cannot be nil")
+	}
+
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultIngestInterval
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = defaultIngestLease
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultIngestBatch
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaultIngestMaxBackoff
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = client.Logger()
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = discardlog.NewDiscardLog()
+	}
+
+	if err := client.execScript(ctx, client.outboxSchema()); err != nil {
+		return nil, fmt.Errorf("failed to create memory outbox tables: %w", err)
+	}
+
+	return &MemoryIngester{
+		logger: cfg.Logger,
+		client: client,
+		memory: memSvc,
+		cfg:    cfg,
+	}, nil
+}
+
+// Start launches the ingestion loop. It stops when ctx is cancelled or Stop is called.
+func (m *MemoryIngester) Start(ctx context.Context) {
+	ctx, m.cancel = context.WithCancel(ctx)
+
+	m.wg.Go(func() {
+		ticker := time.NewTicker(m.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := m.ProcessOnce(ctx); err != nil && ctx.Err() == nil {
+				m.logger.Errorf("memory ingestion poll failed: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+
+	m.logger.Infof("memory ingester started: interval=%s", m.cfg.Interval)
+}
+
+// Stop stops the ingestion loop and waits for the in-progress batch.
+func (m *MemoryIngester) Stop() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.wg.Wait()
+
+	m.logger.Info("memory ingester stopped")
+}
+
+type outboxEntry struct {
+	appName     string
+	userID      string
+	sessionID   string
+	targetOrder int
+	attempts    int
+}
+
+// ProcessOnce claims a batch of outbox entries and ingests them. It returns the
+// number of sessions ingested.
+func (m *MemoryIngester) ProcessOnce(ctx context.Context) (int, error) {
+	entries, err := m.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	ingested := 0
+	for _, e := range entries {
+		if err := m.ingest(ctx, e); err != nil {
+			m.logger.Warnf("memory ingestion failed: session=%s, attempt=%d, err=%v",
+				e.sessionID, e.attempts, err)
+			m.fail(context.WithoutCancel(ctx), e, err)
+			continue
+		}
+		ingested++
+	}
+
+	return ingested, nil
+}
+
+// claim leases up to BatchSize available entries.
+func (m *MemoryIngester) claim(ctx context.Context) ([]outboxEntry, error) {
+	outbox := m.client.tableName("memory_outbox")
+	//nolint:gosec // table name is validated by the client
+	query := `
+		UPDATE ` + outbox + ` o
+		SET available_at = NOW() + $1 * INTERVAL '1 millisecond', attempts = o.attempts + 1
+		FROM (
+			SELECT app_name, user_id, session_id FROM ` + outbox + `
+			WHERE available_at <= NOW()
+			ORDER BY available_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		) c
+		WHERE o.app_name = c.app_name AND o.user_id = c.user_id AND o.session_id = c.session_id
+		RETURNING o.app_name, o.user_id, o.session_id, o.target_order, o.attempts
+	`
+	if m.client.DistributedSQL() {
+		// NOTE: Without row locks, the claims of two ingesters conflict: available_at
+		// is checked again on update, so an entry goes to one of them, and the
+		// distributed engines abort the other claim, retried on the next poll
+		//nolint:gosec // table name is validated by the client
+		query = `
+			UPDATE ` + outbox + ` o
+			SET available_at = NOW() + $1 * INTERVAL '1 millisecond', attempts = o.attempts + 1
+			WHERE o.available_at <= NOW() AND (o.app_name, o.user_id, o.session_id) IN (
+				SELECT app_name, user_id, session_id FROM ` + outbox + `
+				WHERE available_at <= NOW()
+				ORDER BY available_at
+				LIMIT $2
+			)
+			RETURNING o.app_name, o.user_id, o.session_id, o.target_order, o.attempts
+		`
+	}
+
+	rows, err := m.client.DB().QueryContext(ctx, query, m.cfg.Lease.Milliseconds(), m.cfg.BatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim memory outbox entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []outboxEntry
+	for rows.Next() {
+		var e outboxEntry
+		if err := rows.Scan(&e.appName, &e.userID, &e.sessionID, &e.targetOrder, &e.attempts); err != nil {
+			return nil, fmt.Errorf("failed to scan memory outbox entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate memory outbox entries: %w", err)
+	}
+
+	return entries, nil
+}
+
+// ingest adds the events after the session's watermark, up to the entry's target,
+// to memory, then advances the watermark and completes the entry.
+func (m *MemoryIngester) ingest(ctx context.Context, e outboxEntry) error {
+	db := m.client.DB()
+	outbox, ingestionState := m.client.tableName("memory_outbox"), m.client.tableName("memory_ingestion_state")
+
+	var watermark int
+	err := db.QueryRowContext(ctx, `
+		SELECT ingested_order FROM `+ingestionState+`
+		WHERE app_name = $1 AND user_id = $2 AND session_id = $3
+	`, e.appName, e.userID, e.sessionID).Scan(&watermark)
+	if errors.Is(err, sql.ErrNoRows) {
+		watermark = -1
+	} else if err != nil {
+		return fmt.Errorf("failed to read ingestion state: %w", err)
+	}
+
+	if e.targetOrder > watermark {
+		sess, err := m.loadSession(ctx, e, watermark)
+		if err != nil {
+			return err
+		}
+
+		if sess.events.Len() > 0 {
+			if err := m.memory.AddSession(ctx, sess); err != nil {
+				return fmt.Errorf("failed to add session to memory: %w", err)
+			}
+		}
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO `+ingestionState+` AS s (app_name, user_id, session_id, ingested_order, ingested_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (app_name, user_id, session_id) DO UPDATE
+		SET ingested_order = GREATEST(s.ingested_order, EXCLUDED.ingested_order),
+			ingested_at = NOW()
+	`, e.appName, e.userID, e.sessionID, e.targetOrder); err != nil {
+		return fmt.Errorf("failed to update ingestion state: %w", err)
+	}
+
+	// A turn completed during ingestion raised target_order: keep the entry, due now
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM `+outbox+`
+		WHERE app_name = $1 AND user_id = $2 AND session_id = $3 AND target_order <= $4
+	`, e.appName, e.userID, e.sessionID, e.targetOrder); err != nil {
+		return fmt.Errorf("failed to complete memory outbox entry: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE `+outbox+` SET available_at = NOW(), attempts = 0, last_error = ''
+		WHERE app_name = $1 AND user_id = $2 AND session_id = $3
+	`, e.appName, e.userID, e.sessionID); err != nil {
+		return fmt.Errorf("failed to reschedule memory outbox entry: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	m.logger.Debugf("session ingested into memory: session=%s, from=%d, to=%d",
+		e.sessionID, watermark+1, e.targetOrder)
+
+	return nil
+}
+
+// fail records the error and delays the entry with exponential backoff.
+func (m *MemoryIngester) fail(ctx context.Context, e outboxEntry, cause error) {
+	backoff := m.cfg.Interval << min(e.attempts, 16)
+	if backoff <= 0 || backoff > m.cfg.MaxBackoff {
+		backoff = m.cfg.MaxBackoff
+	}
+
+	if _, err := m.client.DB().ExecContext(ctx, `
+		UPDATE `+m.client.tableName("memory_outbox")+`
+		SET last_error = $4, available_at = NOW() + $5 * INTERVAL '1 millisecond'
+		WHERE app_name = $1 AND user_id = $2 AND session_id = $3
+	`, e.appName, e.userID, e.sessionID, cause.Error(), backoff.Milliseconds()); err != nil {
+		m.logger.Errorf("failed to record memory ingestion failure: session=%s, err=%v", e.sessionID, err)
+	}
+}
+
+// loadSession reads the session and its events in (after, target] from PostgreSQL.
+func (m *MemoryIngester) loadSession(ctx context.Context, e outboxEntry, after int) (*storedSession, error) {
+	db := m.client.DB()
+
+	sess := &storedSession{
+		id:      e.sessionID,
+		appName: e.appName,
+		userID:  e.userID,
+		state:   &storedState{data: map[string]any{}},
+		events:  &storedEvents{},
+	}
+
+	var stateJSON []byte
+	err := db.QueryRowContext(ctx, `
+		SELECT state, last_update_time FROM `+m.client.SessionsTableName()+`
+		WHERE app_name = $1 AND user_id = $2 AND id = $3
+	`, e.appName, e.userID, e.sessionID).Scan(&stateJSON, &sess.lastUpdateTime)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+	if len(stateJSON) > 0 {
+		if err := unmarshalJSON(ctx, m.cfg.Encryptor, e.appName, stateJSON, &sess.state.data); err != nil {
+			m.logger.Warnf("failed to unmarshal session state: session=%s, err=%v", e.sessionID, err)
+		}
+	}
+
+	tableName := m.client.GetEventsTableName(e.userID)
+	//nolint:gosec // table name is generated internally
+	query := `SELECT content FROM ` + tableName + `
+		WHERE app_name = $1 AND user_id = $2 AND session_id = $3 AND event_order > $4 AND event_order <= $5
+		ORDER BY event_order`
+
+	rows, err := db.QueryContext(ctx, query, e.appName, e.userID, e.sessionID, after, e.targetOrder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var content []byte
+		if err := rows.Scan(&content); err != nil {
+			return nil, fmt.Errorf("failed to scan session event: %w", err)
+		}
+
+		var evt session.Event
+		if err := unmarshalJSON(ctx, m.cfg.Encryptor, e.appName, content, &evt); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal session event: %w", err)
+		}
+		sess.events.events = append(sess.events.events, &evt)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate session events: %w", err)
+	}
+
+	return sess, nil
+}
+
+var _ session.Session = (*storedSession)(nil)
+
+// storedSession is a read-only session rebuilt from PostgreSQL for ingestion.
+type storedSession struct {
+	id             string
+	appName        string
+	userID         string
+	state          *storedState
+	events         *storedEvents
+	lastUpdateTime time.Time
+}
+
+func (s *storedSession) ID() string                { return s.id }
+func (s *storedSession) AppName() string           { return s.appName }
+func (s *storedSession) UserID() string            { return s.userID }
+func (s *storedSession) State() session.State      { return s.state }
+func (s *storedSession) Events() session.Events    { return s.events }
+func (s *storedSession) LastUpdateTime() time.Time { return s.lastUpdateTime }
+
+var _ session.Events = (*storedEvents)(nil)
+
+type storedEvents struct {
+	events []*session.Event
+}
+
+func (e *storedEvents) All() iter.Seq[*session.Event] {
+	return func(yield func(*session.Event) bool) {
+		for _, evt := range e.events {
+			if !yield(evt) {
+				return
+			}
+		}
+	}
+}
+
+func (e *storedEvents) Len() int { return len(e.events) }
+
+func (e *storedEvents) At(i int) *session.Event {
+	if i < 0 || i >= len(e.events) {
+		return nil
+	}
+	return e.events[i]
+}
+
+var _ session.State = (*storedState)(nil)
+
+type storedState struct {
+	data map[string]any
+}
+
+func (s *storedState) Get(key string) (any, error) {
+	v, ok := s.data[key]
+	if !ok {
+		return nil, session.ErrStateKeyNotExist
+	}
+	return v, nil
+}
+
+func (s *storedState) Set(string, any) error {
+	return errors.New("stored session state is read-only")
+}
+
+func (s *storedState) All() iter.Seq2[string, any] {
+	return func(yield func(string, any) bool) {
+		for k, v := range s.data {
+			if !yield(k, v) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/adk/memory"
+	"google.golang.org/adk/model"
+	"google.golang.org/adk/session"
+	"google.golang.org/genai"
+)
+
+func TestCompletesTurn(t *testing.T) {
+	text := genai.NewContentFromText("hello", genai.RoleModel)
+	call := &genai.Content{Role: genai.RoleModel, Parts: []*genai.Part{
+		{FunctionCall: &genai.FunctionCall{Name: "get_weather"}},
+	}}
+
+	tests := []struct {
+		name string
+		evt  *session.Event
+		want bool
+	}{
+		{"nil", nil, false},
+		{"user message", &session.Event{Author: "user", LLMResponse: model.LLMResponse{Content: text}}, false},
+		{"partial", &session.Event{Author: "agent", LLMResponse: model.LLMResponse{Content: text, Partial: true}}, false},
+		{"function call", &session.Event{Author: "agent", LLMResponse: model.LLMResponse{Content: call}}, false},
+		{"no content", &session.Event{Author: "agent"}, false},
+		{"final answer", &session.Event{Author: "agent", LLMResponse: model.LLMResponse{Content: text}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := completesTurn(tt.evt); got != tt.want {
+				t.Errorf("completesTurn() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// recordingMemory implements memory.Service and records the ingested events.
+type recordingMemory struct {
+	mu       sync.Mutex
+	eventIDs []string
+	failNext bool
+}
+
+func (m *recordingMemory) AddSession(_ context.Context, sess session.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.failNext {
+		m.failNext = false
+		return errors.New("memory unavailable")
+	}
+
+	for evt := range sess.Events().All() {
+		m.eventIDs = append(m.eventIDs, evt.ID)
+	}
+	return nil
+}
+
+func (m *recordingMemory) Search(context.Context, *memory.SearchRequest) (*memory.SearchResponse, error) {
+	return &memory.SearchResponse{}, nil
+}
+
+func createTestTurnEvent(id, author, text string) *session.Event {
+	evt := createTestEvent(id, author)
+	evt.Content = genai.NewContentFromText(text, genai.RoleModel)
+	return evt
+}
+
+func TestMemoryIngester(t *testing.T) {
+	defaultPersister, client := setupTestDB(t)
+	if defaultPersister == nil {
+		return
+	}
+	defer client.Close()
+	defer defaultPersister.Close()
+
+	ctx := context.Background()
+
+	persister, err := NewSessionPersister(ctx, client, WithAsyncBufferSize(0), WithMemoryOutbox())
+	if err != nil {
+		t.Fatalf("Failed to create persister: %v", err)
+	}
+	defer persister.Close()
+
+	for _, table := range []string{"memory_outbox", "memory_ingestion_state"} {
+		_, _ = client.DB().ExecContext(ctx, "DELETE FROM "+table+" WHERE app_name LIKE 'test_%'")
+	}
+
+	mem := &recordingMemory{failNext: true}
+	ingester, err := NewMemoryIngester(ctx, client, mem, IngesterConfig{Interval: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to create ingester: %v", err)
+	}
+
+	sess := createTestSession("outbox-session", "test_outbox", "user1")
+	if err := persister.PersistSession(ctx, sess); err != nil {
+		t.Fatalf("PersistSession failed: %v", err)
+	}
+
+	for _, evt := range []*session.Event{
+		createTestTurnEvent("e1", "user", "What is the weather?"),
+		createTestTurnEvent("e2", "agent", "It is sunny."),
+	} {
+		if err := persister.PersistEvent(ctx, sess, evt); err != nil {
+			t.Fatalf("PersistEvent failed: %v", err)
+		}
+	}
+
+	// First attempt fails and is backed off
+	n, err := ingester.ProcessOnce(ctx)
+	if err != nil || n != 0 {
+		t.Fatalf("ProcessOnce() = %d, %v; want failed attempt", n, err)
+	}
+
+	var lastError string
+	if err := client.DB().QueryRowContext(ctx,
+		`SELECT last_error FROM memory_outbox WHERE app_name = 'test_outbox'`).Scan(&lastError); err != nil {
+		t.Fatalf("outbox entry missing after failure: %v", err)
+	}
+	if lastError == "" {
+		t.Error("expected last_error to be recorded")
+	}
+
+	// Make the entry available again and retry
+	_, _ = client.DB().ExecContext(ctx,
+		`UPDATE memory_outbox SET available_at = NOW() WHERE app_name = 'test_outbox'`)
+
+	if n, err := ingester.ProcessOnce(ctx); err != nil || n != 1 {
+		t.Fatalf("ProcessOnce() = %d, %v; want 1", n, err)
+	}
+	if len(mem.eventIDs) != 2 {
+		t.Fatalf("ingested events = %v, want [e1 e2]", mem.eventIDs)
+	}
+
+	// Nothing left to ingest
+	if n, _ := ingester.ProcessOnce(ctx); n != 0 {
+		t.Errorf("ProcessOnce() = %d after completion, want 0", n)
+	}
+
+	// The next turn only ingests the new events
+	for _, evt := range []*session.Event{
+		createTestTurnEvent("e3", "user", "And tomorrow?"),
+		createTestTurnEvent("e4", "agent", "Rain."),
+	} {
+		if err := persister.PersistEvent(ctx, sess, evt); err != nil {
+			t.Fatalf("PersistEvent failed: %v", err)
+		}
+	}
+
+	if n, err := ingester.ProcessOnce(ctx); err != nil || n != 1 {
+		t.Fatalf("ProcessOnce() = %d, %v; want 1", n, err)
+	}
+
+	want := []string{"e1", "e2", "e3", "e4"}
+	if len(mem.eventIDs) != len(want) {
+		t.Fatalf("ingested events = %v, want %v", mem.eventIDs, want)
+	}
+	for i := range want {
+		if mem.eventIDs[i] != want[i] {
+			t.Errorf("ingested events = %v, want %v", mem.eventIDs, want)
+			break
+		}
+	}
+
+	var ingestedOrder int
+	if err := client.DB().QueryRowContext(ctx,
+		`SELECT ingested_order FROM memory_ingestion_state WHERE app_name = 'test_outbox'`,
+	).Scan(&ingestedOrder); err != nil {
+		t.Fatalf("ingestion state missing: %v", err)
+	}
+	if ingestedOrder != 3 {
+		t.Errorf("ingested_order = %d, want 3", ingestedOrder)
+	}
+
+	// Deleting the session drops its ingestion state
+	if err := persister.DeleteSession(ctx, "test_outbox", "user1", "outbox-session"); err != nil {
+		t.Fatalf("DeleteSession failed: %v", err)
+	}
+	var count int
+	_ = client.DB().QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM memory_ingestion_state WHERE app_name = 'test_outbox'`).Scan(&count)
+	if count != 0 {
+		t.Errorf("ingestion state rows = %d after delete, want 0", count)
+	}
+}
@@ -0,0 +1,193 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kydenul/k-adk/internal/pgerr"
+)
+
+// Partitioning selects how the events are split across tables, see
+// Config.Partitioning.
+type Partitioning string
+
+const (
+	// PartitionNone splits the events in ShardCount tables by user_id hash, which
+	// the persister picks itself. It works on every PostgreSQL version.
+	PartitionNone Partitioning = ""
+
+	// PartitionHash partitions the events table by user_id hash, in ShardCount
+	// partitions. The partition count is fixed when the table is created.
+	PartitionHash Partitioning = "hash"
+
+	// PartitionRange partitions the events table by month of the event timestamp.
+	// The persister creates the partition of a month with its first event, and
+	// DropEventPartitions drops the old ones. The events of a month whose partition
+	// cannot be created, e.g. as the default partition already holds some of them,
+	// go to the default partition.
+	PartitionRange Partitioning = "range"
+)
+
+// rangePartitionsAhead is the number of months after the current one whose
+// range partitions are created with the schema.
+const rangePartitionsAhead = 2
+
+// rangePartitionLayout formats the month in the name of a range partition, e.g.
+// session_events_2026_10.
+const rangePartitionLayout = "2006_01"
+
+// partitionedEventsSchema creates the partitioned events table and its indexes,
+// which PostgreSQL creates on every partition.
+const partitionedEventsSchema = `
+	CREATE TABLE IF NOT EXISTS %[1]s (` + eventsColumns + `,
+		PRIMARY KEY (%[3]s)
+	) PARTITION BY %[4]s;
+
+	CREATE INDEX IF NOT EXISTS %[2]s_session ON %[1]s(app_name, user_id, session_id);
+	CREATE INDEX IF NOT EXISTS %[2]s_timestamp ON %[1]s(timestamp);
+	CREATE INDEX IF NOT EXISTS %[2]s_branch ON %[1]s(app_name, user_id, session_id, branch);
+`
+
+// initPartitionedEvents creates the partitioned events table and its partitions:
+// the hash partitions, or the default partition and the range partitions of the
+// coming months.
+func (p *SessionPersister) initPartitionedEvents(ctx context.Context) error {
+	parent := p.client.eventsParentTable()
+	table := p.client.tableName(parent)
+
+	key, partitionBy := "app_name, user_id, session_id, event_order", "HASH (user_id)"
+	if p.client.Partitioning() == PartitionRange {
+		// NOTE: The primary key of a partitioned table holds its partition key
+		key, partitionBy = key+", timestamp", "RANGE (timestamp)"
+	}
+
+	stmts := []string{fmt.Sprintf(partitionedEventsSchema, table, "idx_"+parent, key, partitionBy)}
+	switch p.client.Partitioning() {
+	case PartitionHash:
+		for i := range p.client.ShardCount() {
+			stmts = append(stmts, fmt.Sprintf(
+				`CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES WITH (MODULUS %d, REMAINDER %d)`,
+				p.client.tableName(fmt.Sprintf("%s_p%d", parent, i)), table, p.client.ShardCount(), i))
+		}
+
+	case PartitionRange:
+		stmts = append(stmts, fmt.Sprintf(
+			`CREATE TABLE IF NOT EXISTS %s PARTITION OF %s DEFAULT`, p.client.tableName(parent+"_default"), table))
+	}
+
+	for _, stmt := range stmts {
+		p.logger.Infof("Init Event Schema SQL: %s", stmt)
+
+		if _, err := p.client.DB().ExecContext(ctx, stmt); err != nil {
+			p.logger.Errorf("failed to create partitioned events table: %v", err)
+			return fmt.Errorf("failed to create partitioned events table: %w", err)
+		}
+	}
+
+	if p.client.Partitioning() == PartitionRange {
+		return p.EnsureEventPartitions(ctx, time.Now().AddDate(0, rangePartitionsAhead, 0))
+	}
+	return nil
+}
+
+// EnsureEventPartitions creates the range partitions of the months from the
+// current one to the one of until. It does nothing unless Config.Partitioning is
+// PartitionRange.
+func (p *SessionPersister) EnsureEventPartitions(ctx context.Context, until time.Time) error {
+	if p.client.Partitioning() != PartitionRange {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	for month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC); !month.After(until); month = month.AddDate(0, 1, 0) {
+		if err := p.ensureEventPartition(ctx, month); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureEventPartition creates the range partition of the month of t, once per
+// persister: a partition failing to be created is not retried, and the events
+// of its month go to the default partition.
+func (p *SessionPersister) ensureEventPartition(ctx context.Context, t time.Time) error {
+	if p.client.Partitioning() != PartitionRange || t.IsZero() {
+		return nil
+	}
+
+	t = t.UTC()
+	month := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if _, loaded := p.eventPartitions.LoadOrStore(month, struct{}{}); loaded {
+		return nil
+	}
+
+	parent := p.client.eventsParentTable()
+	stmt := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')`,
+		p.client.tableName(parent+"_"+month.Format(rangePartitionLayout)), p.client.tableName(parent),
+		month.Format(time.RFC3339), month.AddDate(0, 1, 0).Format(time.RFC3339))
+	if _, err := p.client.DB().ExecContext(ctx, stmt); err != nil {
+		return pgerr.Wrap("failed to create event partition "+month.Format(rangePartitionLayout), err)
+	}
+
+	p.logger.Debugf("event partition created: %s", month.Format(rangePartitionLayout))
+	return nil
+}
+
+// DropEventPartitions drops the range partitions of the months ending at or
+// before before, with their events, and returns their names. Dropping a
+// partition is much cheaper than deleting its rows, which makes it the retention
+// of PartitionRange; the sessions and the events of the default partition are
+// kept. It does nothing unless Config.Partitioning is PartitionRange.
+func (p *SessionPersister) DropEventPartitions(ctx context.Context, before time.Time) ([]string, error) {
+	if p.client.Partitioning() != PartitionRange {
+		return nil, nil
+	}
+
+	parent := p.client.eventsParentTable()
+	rows, err := p.client.DB().QueryContext(ctx, `
+		SELECT c.relname FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = $1::regclass
+	`, p.client.tableName(parent))
+	if err != nil {
+		return nil, pgerr.Wrap("failed to list event partitions", err)
+	}
+
+	var partitions []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("failed to scan event partition: %w", err)
+		}
+		partitions = append(partitions, name)
+	}
+	if err := errors.Join(rows.Err(), rows.Close()); err != nil {
+		return nil, pgerr.Wrap("failed to list event partitions", err)
+	}
+
+	var dropped []string
+	for _, name := range partitions {
+		suffix, ok := strings.CutPrefix(name, parent+"_")
+		if !ok {
+			continue
+		}
+		month, err := time.Parse(rangePartitionLayout, suffix)
+		if err != nil || month.AddDate(0, 1, 0).After(before) {
+			continue
+		}
+
+		if _, err := p.client.DB().ExecContext(ctx, `DROP TABLE IF EXISTS `+p.client.tableName(name)); err != nil {
+			return dropped, pgerr.Wrap("failed to drop event partition "+name, err)
+		}
+		p.eventPartitions.Delete(month)
+		dropped = append(dropped, name)
+	}
+
+	if len(dropped) > 0 {
+		p.logger.Infof("dropped %d event partitions before %s", len(dropped), before.Format(time.DateOnly))
+	}
+	return dropped, nil
+}
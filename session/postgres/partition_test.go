@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/adk/session"
+)
+
+func TestPartitioningConfig(t *testing.T) {
+	if _, err := NewPostgresClient(context.Background(), &Config{
+		ConnStr:      getTestConnString(),
+		Partitioning: "list",
+	}); err == nil {
+		t.Error("NewPostgresClient() accepted an unknown partitioning")
+	}
+
+	c := &Client{schema: "chat", eventsTablePrefix: defaultEventsTablePrefix, shardCount: 4, partitioning: PartitionHash}
+	if got := c.GetEventsTableName("user-1"); got != "chat.session_events" {
+		t.Errorf("GetEventsTableName() = %s, want chat.session_events", got)
+	}
+	if got := c.eventsTables(); !slices.Equal(got, []string{"session_events"}) {
+		t.Errorf("eventsTables() = %v, want the partitioned table", got)
+	}
+
+	c.partitioning = PartitionNone
+	if got := c.eventsTables(); len(got) != 4 || got[3] != "session_events_3" {
+		t.Errorf("eventsTables() = %v, want the 4 shard tables", got)
+	}
+}
+
+func TestPartitionedEvents(t *testing.T) {
+	for _, partitioning := range []Partitioning{PartitionHash, PartitionRange} {
+		t.Run(string(partitioning), func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			client, err := NewPostgresClient(ctx, &Config{
+				ConnStr:      getTestConnString(),
+				ShardCount:   4,
+				Schema:       "test_kadk_" + string(partitioning),
+				Partitioning: partitioning,
+			})
+			if err != nil {
+				t.Skipf("PostgreSQL not available, skipping test: %v", err)
+				return
+			}
+			defer client.Close()
+
+			persister, err := NewSessionPersister(ctx, client, WithAsyncBufferSize(0))
+			if err != nil {
+				t.Fatalf("Failed to create persister: %v", err)
+			}
+			defer persister.Close()
+
+			if err := persister.deleteSessionSync(ctx, "test_app", "user-parts", "sess-parts"); err != nil {
+				t.Fatalf("deleteSessionSync failed: %v", err)
+			}
+			sess := createTestSession("sess-parts", "test_app", "user-parts")
+			if err := persister.PersistSession(ctx, sess); err != nil {
+				t.Fatalf("PersistSession failed: %v", err)
+			}
+
+			// NOTE: An event of an old month gets a range partition of its own
+			old := createTestEvent("evt-parts-old", "user")
+			old.Timestamp = time.Date(2020, 3, 15, 0, 0, 0, 0, time.UTC)
+			for _, evt := range []*session.Event{old, createTestEvent("evt-parts-new", "agent")} {
+				if err := persister.PersistEvent(ctx, sess, evt); err != nil {
+					t.Fatalf("PersistEvent failed: %v", err)
+				}
+			}
+
+			stored, err := persister.LoadSession(ctx, "test_app", "user-parts", "sess-parts")
+			if err != nil || stored == nil {
+				t.Fatalf("LoadSession = %v, %v", stored, err)
+			}
+			if len(stored.Events) != 2 || stored.Events[0].ID != "evt-parts-old" {
+				t.Fatalf("events = %d, want the 2 events in order", len(stored.Events))
+			}
+
+			dropped, err := persister.DropEventPartitions(ctx, time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC))
+			if err != nil {
+				t.Fatalf("DropEventPartitions failed: %v", err)
+			}
+			want := 0
+			if partitioning == PartitionRange {
+				want = 1
+			}
+			if len(dropped) != want {
+				t.Fatalf("dropped %v, want %d partitions", dropped, want)
+			}
+
+			var count int
+			query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE session_id = 'sess-parts'", client.GetEventsTableName("user-parts"))
+			if err := client.DB().QueryRowContext(ctx, query).Scan(&count); err != nil {
+				t.Fatalf("Failed to count events: %v", err)
+			}
+			if count != 2-want {
+				t.Errorf("events = %d after dropping partitions, want %d", count, 2-want)
+			}
+		})
+	}
+}
@@ -2,14 +2,19 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"maps"
 	"sync"
 	"time"
 
 	"github.com/bytedance/sonic"
+	"github.com/kydenul/k-adk/compression"
+	"github.com/kydenul/k-adk/encryption"
 	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/k-adk/internal/pgerr"
 	ksess "github.com/kydenul/k-adk/session"
 	"github.com/kydenul/log"
 	"google.golang.org/adk/session"
@@ -20,6 +25,7 @@ var _ ksess.Persister = (*SessionPersister)(nil)
 // Default configuration values.
 const (
 	defaultAsyncBufferSize = 1000
+	defaultAsyncWorkers    = 1
 	defaultAsyncOpTimeout  = 30 * time.Second
 
 	operationSession = "session"
@@ -32,11 +38,71 @@ const (
 type SessionPersister struct {
 	client *Client
 
-	logger    log.Logger
-	asyncChan chan asyncOperation // Channel for async operations
-	wg        sync.WaitGroup
-	closed    bool
-	mu        sync.Mutex
+	logger log.Logger
+	wg     sync.WaitGroup
+	closed bool
+	mu     sync.RWMutex
+
+	// asyncChans holds the queue of each async worker, nil in sync mode.
+	asyncChans      []chan asyncOperation
+	asyncBufferSize int
+	asyncWorkers    int
+
+	// closing is closed when Close starts, waking the senders blocked on a full
+	// queue so they release the lock Close takes.
+	closing     chan struct{}
+	closingOnce sync.Once
+
+	// backpressure applies when the queue of a worker is full.
+	backpressure BackpressurePolicy
+	stats        queueStats
+
+	// ops counts the outcomes of the async operations, for CloseWithContext.
+	ops opCounts
+
+	// drainCtx is the parent context of the async operations, canceled by
+	// abortDrain when CloseWithContext stops draining the queues.
+	drainCtx   context.Context
+	abortDrain context.CancelCauseFunc
+
+	// memoryOutbox records completed turns for a MemoryIngester.
+	memoryOutbox bool
+
+	// changeFeed notifies the changes of the sessions table, see WithChangeFeed.
+	changeFeed bool
+
+	// transformEvent returns the events to store, nil to store them as is.
+	transformEvent EventTransformer
+
+	// codec compresses the session states and events, nil to store them as is.
+	codec compression.Codec
+
+	// encryptor encrypts the session states and events, nil to store them as is.
+	encryptor *encryption.Encryptor
+
+	// scopedState stores the app and user state keys in their own tables.
+	scopedState bool
+
+	// eventPartitions holds the months whose range partition was created.
+	eventPartitions sync.Map
+
+	// retry configures the retries of the failing async operations.
+	retry RetryConfig
+
+	// deadLetter receives the async operations failing on their last attempt.
+	deadLetter      DeadLetterSink
+	deadLetterTable bool
+
+	// audit records the changes of the sessions in session_audit, see WithAuditLog.
+	audit bool
+
+	// stateIndex is the operator class of the GIN index on the states, see
+	// WithStateIndex; empty for none.
+	stateIndex StateIndex
+
+	// retention is the policy of Prune, run in the background until stopPruning.
+	retention   Retention
+	stopPruning context.CancelFunc
 }
 
 type asyncOperation struct {
@@ -46,20 +112,35 @@ type asyncOperation struct {
 	appName       string
 	userID        string
 	sessionID     string
+	actor         string // audit actor of the context the operation was queued with
+}
+
+// key returns the IDs of the session of op.
+func (op asyncOperation) key() (appName, userID, sessionID string) {
+	if op.sess != nil {
+		return op.sess.AppName(), op.sess.UserID(), op.sess.ID()
+	}
+	return op.appName, op.userID, op.sessionID
 }
 
 // PersisterOption configures the SessionPersister.
 type PersisterOption func(*SessionPersister)
 
-// WithAsyncBufferSize sets the buffer size for async operations.
+// WithAsyncBufferSize sets the buffer size for async operations, per worker.
 // Default is 1000. Set to 0 to disable async mode (all operations are synchronous).
 func WithAsyncBufferSize(size int) PersisterOption {
 	return func(p *SessionPersister) {
-		if size > 0 {
-			p.asyncChan = make(chan asyncOperation, size)
-		} else {
-			p.asyncChan = nil
-		}
+		p.asyncBufferSize = max(size, 0)
+	}
+}
+
+// WithAsyncWorkers sets the number of workers writing async operations.
+// Operations are routed to a worker by session, so the operations of a session
+// are still written in order while different sessions are written in parallel.
+// Default is 1.
+func WithAsyncWorkers(n int) PersisterOption {
+	return func(p *SessionPersister) {
+		p.asyncWorkers = max(n, 1)
 	}
 }
 
@@ -79,9 +160,10 @@ func NewSessionPersister(
 	}
 
 	p := &SessionPersister{
-		client:    client,
-		logger:    logger,
-		asyncChan: make(chan asyncOperation, defaultAsyncBufferSize),
+		client:          client,
+		logger:          logger,
+		asyncBufferSize: defaultAsyncBufferSize,
+		asyncWorkers:    defaultAsyncWorkers,
 	}
 
 	// Apply options
@@ -94,10 +176,21 @@ func NewSessionPersister(
 		return nil, fmt.Errorf("failed to initialize schema: %w", err)
 	}
 
-	// Start async worker if async mode is enabled
-	if p.asyncChan != nil {
-		p.wg.Add(1)
-		go p.asyncWorker() //nolint:contextcheck // async worker manages its own context
+	// Start async workers if async mode is enabled
+	if p.asyncBufferSize > 0 {
+		p.drainCtx, p.abortDrain = context.WithCancelCause(context.Background())
+		p.closing = make(chan struct{})
+		p.asyncChans = make([]chan asyncOperation, p.asyncWorkers)
+		for i := range p.asyncChans {
+			p.asyncChans[i] = make(chan asyncOperation, p.asyncBufferSize)
+			p.wg.Add(1)
+			go p.asyncWorker(p.asyncChans[i]) //nolint:contextcheck // async worker manages its own context
+		}
+	}
+
+	// Start pruning old sessions if a retention interval is set
+	if p.retention.Interval > 0 {
+		p.startPruning()
 	}
 
 	logger.Info("PostgreSQL session persister initialized")
@@ -107,9 +200,21 @@ func NewSessionPersister(
 
 // initSchema creates the necessary tables and indexes.
 func (p *SessionPersister) initSchema(ctx context.Context) error {
+	if schema := p.client.Schema(); schema != "" {
+		if _, err := p.client.DB().ExecContext(ctx, `CREATE SCHEMA IF NOT EXISTS `+schema); err != nil {
+			p.logger.Errorf("failed to create schema %s: %v", schema, err)
+			return fmt.Errorf("failed to create schema %s: %w", schema, err)
+		}
+	}
+
 	// NOTE: Create sessions table
-	const sessionsSchema = `
-		CREATE TABLE IF NOT EXISTS sessions (
+	// The distributed engines only index JSONB with its default operator class
+	tagsOpClass := "jsonb_path_ops"
+	if p.client.DistributedSQL() {
+		tagsOpClass = ""
+	}
+	sessionsSchema := fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %[1]s (
 			id VARCHAR(255) NOT NULL,
 			app_name VARCHAR(255) NOT NULL,
 			user_id VARCHAR(255) NOT NULL,
@@ -119,131 +224,377 @@ func (p *SessionPersister) initSchema(ctx context.Context) error {
 			PRIMARY KEY (app_name, user_id, id)
 		);
 
-		CREATE INDEX IF NOT EXISTS idx_sessions_app_user ON sessions(app_name, user_id);
-		CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions(user_id);
-		CREATE INDEX IF NOT EXISTS idx_sessions_last_update ON sessions(last_update_time);
-	`
+		CREATE INDEX IF NOT EXISTS %[2]s_app_user ON %[1]s(app_name, user_id);
+		CREATE INDEX IF NOT EXISTS %[2]s_user ON %[1]s(user_id);
+		CREATE INDEX IF NOT EXISTS %[2]s_last_update ON %[1]s(last_update_time);
+		CREATE INDEX IF NOT EXISTS %[2]s_app_last_update ON %[1]s(app_name, last_update_time DESC);
+
+		ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS tags JSONB NOT NULL DEFAULT '{}';
+		ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS parent_id VARCHAR(255) NOT NULL DEFAULT '';
+		ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS fork_event_id VARCHAR(255) NOT NULL DEFAULT '';
+		ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS ttl_seconds BIGINT NOT NULL DEFAULT 0;
+
+		-- The existing rows keep a NULL counter, set from their events on the next one
+		ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS next_event_order INT;
+		ALTER TABLE %[1]s ALTER COLUMN next_event_order SET DEFAULT 0;
+
+		CREATE INDEX IF NOT EXISTS %[2]s_tags ON %[1]s USING GIN (tags %[3]s);
+	`, p.client.SessionsTableName(), indexPrefix(p.client.sessionsTable), tagsOpClass)
+
+	stateIndexSchema, err := p.stateIndexSchema()
+	if err != nil {
+		return err
+	}
+	sessionsSchema += stateIndexSchema
 
 	p.logger.Infof("Init Session Schema SQL: %s", sessionsSchema)
 
-	if _, err := p.client.DB().ExecContext(ctx, sessionsSchema); err != nil {
+	if err := p.client.execScript(ctx, sessionsSchema); err != nil {
 		p.logger.Errorf("failed to create sessions table: %v", err)
 		return fmt.Errorf("failed to create sessions table: %w", err)
 	}
 
-	// NOTE: Create sharded events tables
+	// NOTE: Create the events tables
+	initEvents := p.initEventShards
+	if p.client.Partitioning() != PartitionNone {
+		initEvents = p.initPartitionedEvents
+	}
+	if err := initEvents(ctx); err != nil {
+		return err
+	}
+
+	if p.scopedState {
+		schema := fmt.Sprintf(scopedStateSchema, p.client.tableName("app_states"), p.client.tableName("user_states"))
+		if err := p.client.execScript(ctx, schema); err != nil {
+			p.logger.Errorf("failed to create scoped state tables: %v", err)
+			return fmt.Errorf("failed to create scoped state tables: %w", err)
+		}
+	}
+
+	if p.memoryOutbox {
+		if err := p.client.execScript(ctx, p.client.outboxSchema()); err != nil {
+			p.logger.Errorf("failed to create memory outbox tables: %v", err)
+			return fmt.Errorf("failed to create memory outbox tables: %w", err)
+		}
+	}
+
+	if p.changeFeed {
+		if err := p.initChangeFeed(ctx); err != nil {
+			return err
+		}
+	}
+
+	if p.deadLetterTable {
+		schema := fmt.Sprintf(deadLetterSchema, p.client.tableName("session_persist_dlq"))
+		if err := p.client.execScript(ctx, schema); err != nil {
+			p.logger.Errorf("failed to create dead letter table: %v", err)
+			return fmt.Errorf("failed to create dead letter table: %w", err)
+		}
+	}
+
+	if p.audit {
+		schema := fmt.Sprintf(auditSchema, p.client.tableName("session_audit"))
+		if err := p.client.execScript(ctx, schema); err != nil {
+			p.logger.Errorf("failed to create audit table: %v", err)
+			return fmt.Errorf("failed to create audit table: %w", err)
+		}
+	}
+
+	p.logger.Infof("schema initialized with %d event shards", p.client.ShardCount())
+
+	return nil
+}
+
+// eventsColumns are the columns of the events tables.
+const eventsColumns = `
+	id VARCHAR(255) NOT NULL,
+	app_name VARCHAR(255) NOT NULL,
+	user_id VARCHAR(255) NOT NULL,
+	session_id VARCHAR(255) NOT NULL,
+	event_order INT NOT NULL,
+	content JSONB NOT NULL,
+	author VARCHAR(255),
+	branch VARCHAR(1024) NOT NULL DEFAULT '',
+	timestamp TIMESTAMPTZ NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()`
+
+// initEventShards creates the sharded events tables.
+func (p *SessionPersister) initEventShards(ctx context.Context) error {
 	for i := range p.client.ShardCount() {
 		eventsSchema := fmt.Sprintf(`
-			CREATE TABLE IF NOT EXISTS session_events_%d (
-				id VARCHAR(255) NOT NULL,
-				app_name VARCHAR(255) NOT NULL,
-				user_id VARCHAR(255) NOT NULL,
-				session_id VARCHAR(255) NOT NULL,
-				event_order INT NOT NULL,
-				content JSONB NOT NULL,
-				author VARCHAR(255),
-				timestamp TIMESTAMPTZ NOT NULL,
-				created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			CREATE TABLE IF NOT EXISTS %[1]s (`+eventsColumns+`,
 				PRIMARY KEY (app_name, user_id, session_id, event_order)
 			);
 
-			CREATE INDEX IF NOT EXISTS idx_events_%d_session ON session_events_%d(app_name, user_id, session_id);
-			CREATE INDEX IF NOT EXISTS idx_events_%d_timestamp ON session_events_%d(timestamp);
-		`, i, i, i, i, i)
+			ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS branch VARCHAR(1024) NOT NULL DEFAULT '';
+
+			CREATE INDEX IF NOT EXISTS %[2]s%[3]d_session ON %[1]s(app_name, user_id, session_id);
+			CREATE INDEX IF NOT EXISTS %[2]s%[3]d_timestamp ON %[1]s(timestamp);
+			CREATE INDEX IF NOT EXISTS %[2]s%[3]d_branch ON %[1]s(app_name, user_id, session_id, branch);
+		`, p.client.EventsTableName(i), indexPrefix(p.client.eventsTablePrefix), i)
 
 		log.Infof("Init Event Schema SQL: %s", eventsSchema)
 
-		if _, err := p.client.DB().ExecContext(ctx, eventsSchema); err != nil {
+		if err := p.client.execScript(ctx, eventsSchema); err != nil {
 			p.logger.Errorf("failed to create events shard table %d: %v", i, err)
 			return fmt.Errorf("failed to create events shard table %d: %w", i, err)
 		}
 	}
 
-	p.logger.Infof("schema initialized with %d event shards", p.client.ShardCount())
-
 	return nil
 }
 
 // asyncWorker processes async operations from the channel.
-func (p *SessionPersister) asyncWorker() {
+func (p *SessionPersister) asyncWorker(ops <-chan asyncOperation) {
 	defer p.wg.Done()
 
-	for op := range p.asyncChan {
+	for op := range ops {
+		if p.drainAborted() {
+			p.abandonAsyncOp(op, 0)
+			continue
+		}
 		p.processAsyncOp(op)
 	}
 }
 
-// processAsyncOp processes a single async operation with proper context management.
+// enqueue queues op on the worker of its session, applying the backpressure
+// policy if its queue is full. It reports false if op is to be run synchronously
+// instead: in sync mode, or under BackpressureFallbackSync.
+func (p *SessionPersister) enqueue(ctx context.Context, op asyncOperation) (bool, error) {
+	if p.asyncChans == nil {
+		return false, nil
+	}
+
+	// NOTE: Close wakes the blocked senders, and closes the queues once they left
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return false, ErrPersisterClosed
+	}
+
+	op.actor = AuditActor(ctx)
+	queue := p.asyncChans[p.workerIndex(op)]
+	select {
+	case queue <- op:
+		return true, nil
+
+	default:
+	}
+
+	switch p.backpressure {
+	case BackpressureBlock:
+		select {
+		case queue <- op:
+			return true, nil
+
+		case <-ctx.Done():
+			return false, fmt.Errorf("failed to queue async %s operation: %w", op.operationType, ctx.Err())
+
+		case <-p.closing:
+			return false, ErrPersisterClosed
+		}
+
+	case BackpressureDropOldest:
+		for {
+			select {
+			case dropped := <-queue:
+				p.stats.dropped.Add(1)
+				_, _, sessionID := dropped.key()
+				p.logger.Errorf("async queue full, dropped %s operation of session %s",
+					dropped.operationType, sessionID)
+
+			default:
+			}
+
+			select {
+			case queue <- op:
+				return true, nil
+
+			default:
+			}
+		}
+
+	case BackpressureReject:
+		p.stats.rejected.Add(1)
+		return false, ErrQueueFull
+	}
+
+	p.stats.syncFallbacks.Add(1)
+	p.logger.Warnf("async queue full, falling back to sync %s", op.operationType)
+	return false, nil
+}
+
+// workerIndex returns the worker of the session of op: all the operations of a
+// session go to the same worker, which writes them in the order they came.
+func (p *SessionPersister) workerIndex(op asyncOperation) int {
+	if len(p.asyncChans) == 1 {
+		return 0
+	}
+
+	appName, userID, sessionID := op.key()
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(appName + "\x00" + userID + "\x00" + sessionID))
+	return int(h.Sum32() % uint32(len(p.asyncChans)))
+}
+
+// processAsyncOp processes a single async operation, retrying it as configured
+// by WithRetry, and hands it to the dead letter sink if its last attempt fails.
 func (p *SessionPersister) processAsyncOp(op asyncOperation) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultAsyncOpTimeout)
-	defer cancel()
+	attempts := max(p.retry.MaxAttempts, 1)
 
 	var err error
+	for attempt := 1; ; attempt++ {
+		if err = p.applyAsyncOp(op); err == nil {
+			p.ops.flushed.Add(1)
+			return
+		}
+		if p.drainAborted() {
+			p.abandonAsyncOp(op, attempt)
+			return
+		}
+
+		// NOTE: A constraint violation fails the same way on every attempt
+		if attempt >= attempts || errors.Is(err, ErrConstraintViolation) {
+			p.ops.failed.Add(1)
+			p.logger.Errorf("async %s operation failed: %v", op.operationType, err)
+			p.sendDeadLetter(op, attempt, err)
+			return
+		}
+
+		backoff := p.retryBackoff(attempt)
+		p.logger.Warnf("async %s operation failed (attempt %d/%d), retrying in %s: %v",
+			op.operationType, attempt, attempts, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-p.drainContext().Done():
+			p.abandonAsyncOp(op, attempt)
+			return
+		}
+	}
+}
+
+// applyAsyncOp runs an attempt of an async operation with its own timeout.
+func (p *SessionPersister) applyAsyncOp(op asyncOperation) error {
+	ctx, cancel := context.WithTimeout(p.drainContext(), defaultAsyncOpTimeout)
+	defer cancel()
+	if op.actor != "" {
+		ctx = WithAuditActor(ctx, op.actor)
+	}
+
 	switch op.operationType {
 	case operationSession:
-		err = p.persistSessionSync(ctx, op.sess)
+		return p.persistSessionSync(ctx, op.sess)
 	case operationEvent:
-		err = p.persistEventSync(ctx, op.sess, op.evt)
+		return p.persistEventSync(ctx, op.sess, op.evt, false)
 	case operationDelete:
-		err = p.deleteSessionSync(ctx, op.appName, op.userID, op.sessionID)
-	}
-
-	if err != nil {
-		p.logger.Errorf("async %s operation failed: %v", op.operationType, err)
+		return p.deleteSessionSync(ctx, op.appName, op.userID, op.sessionID)
 	}
+	return nil
 }
 
 // PersistSession saves or updates a session in PostgreSQL.
 // If async mode is enabled, the operation is queued and returns immediately.
 func (p *SessionPersister) PersistSession(ctx context.Context, sess session.Session) error {
-	p.mu.Lock()
+	p.mu.RLock()
 	if p.closed {
-		p.mu.Unlock()
-		return errors.New("persister is closed")
+		p.mu.RUnlock()
+		return ErrPersisterClosed
 	}
-	p.mu.Unlock()
-
-	if p.asyncChan != nil {
-		select {
-		case p.asyncChan <- asyncOperation{operationType: operationSession, sess: sess}:
-			return nil
+	p.mu.RUnlock()
 
-		default:
-			p.logger.Warn("async channel full, falling back to sync persist")
-		}
+	if queued, err := p.enqueue(ctx, asyncOperation{operationType: operationSession, sess: sess}); queued || err != nil {
+		return err
 	}
 
 	return p.persistSessionSync(ctx, sess)
 }
 
 func (p *SessionPersister) persistSessionSync(ctx context.Context, sess session.Session) error {
-	// Collect state
-	var stateJSON []byte
-	if state := sess.State(); state != nil {
-		stateMap := maps.Collect(state.All())
-		var err error
-		stateJSON, err = sonic.Marshal(stateMap)
+	if p.audit {
+		return p.persistSessionAudited(ctx, sess)
+	}
+	return p.upsertSession(ctx, p.client.stmts, sess)
+}
+
+// upsertSession writes the row of sess, and its scoped state, with db. With
+// WithAuditLog, db runs a transaction, which records the change too.
+func (p *SessionPersister) upsertSession(ctx context.Context, db txQueryer, sess session.Session) error {
+	stateJSON, appState, userState, err := p.encodeState(ctx, sess)
+	if err != nil {
+		p.logger.Errorf("failed to encode state of session %s: %v", sess.ID(), err)
+		return err
+	}
+
+	// NOTE: The state replaced is read, and locked, before the upsert
+	var (
+		prevState map[string]any
+		existed   bool
+	)
+	if p.audit {
+		prevState, existed, err = p.storedState(ctx, db, sess.AppName(), sess.UserID(), sess.ID())
 		if err != nil {
-			stateJSON = []byte("{}")
+			p.logger.Errorf("failed to read state of session %s: %v", sess.ID(), err)
+			return err
+		}
+	}
+
+	// NOTE: The tags of a session not carrying tags are kept
+	var tagsJSON any
+	if tagged, ok := sess.(ksess.Tagged); ok {
+		tags := tagged.Tags()
+		if tags == nil {
+			tags = map[string]string{}
+		}
+		if tagsJSON, err = sonic.MarshalString(tags); err != nil {
+			return fmt.Errorf("failed to marshal session tags: %w", err)
 		}
-	} else {
-		stateJSON = []byte("{}")
 	}
 
+	// NOTE: So are the branch link and the TTL of a session not carrying them
+	var parentID, forkEventID, ttlSeconds any
+	if forked, ok := sess.(ksess.Forked); ok {
+		parentID, forkEventID = forked.ParentID(), forked.ForkEventID()
+	}
+	if expiring, ok := sess.(ksess.Expiring); ok {
+		ttlSeconds = int64(expiring.TTL().Seconds())
+	}
+
+	//nolint:gosec // table name is validated by the client
 	stmt := `
-		INSERT INTO sessions (id, app_name, user_id, state, last_update_time, created_at)
-		VALUES ($1, $2, $3, $4, $5, NOW())
+		INSERT INTO ` + p.client.SessionsTableName() + ` AS s
+			(id, app_name, user_id, state, last_update_time, tags,
+			 parent_id, fork_event_id, ttl_seconds, created_at)
+		VALUES ($1, $2, $3, $4, $5, COALESCE($6::jsonb, '{}'::jsonb),
+			COALESCE($7::varchar, ''), COALESCE($8::varchar, ''), COALESCE($9::bigint, 0), NOW())
 		ON CONFLICT (app_name, user_id, id) DO UPDATE
-		SET state = EXCLUDED.state, last_update_time = EXCLUDED.last_update_time
+		SET state = EXCLUDED.state, last_update_time = EXCLUDED.last_update_time,
+			tags = COALESCE($6::jsonb, s.tags),
+			parent_id = COALESCE($7::varchar, s.parent_id),
+			fork_event_id = COALESCE($8::varchar, s.fork_event_id),
+			ttl_seconds = COALESCE($9::bigint, s.ttl_seconds)
 	`
 
 	p.logger.Infof("Persist Session SQL: %s", stmt)
 
-	_, err := p.client.DB().ExecContext(ctx, stmt,
-		sess.ID(), sess.AppName(), sess.UserID(), stateJSON, sess.LastUpdateTime())
+	_, err = db.ExecContext(ctx, stmt,
+		sess.ID(), sess.AppName(), sess.UserID(), stateJSON, sess.LastUpdateTime(), tagsJSON,
+		parentID, forkEventID, ttlSeconds)
 	if err != nil {
 		p.logger.Errorf("failed to persist session %s: %v", sess.ID(), err)
-		return fmt.Errorf("failed to persist session: %w", err)
+		return pgerr.Wrap("failed to persist session", err)
+	}
+
+	// NOTE: The scoped keys of a session may be stale, only add the missing ones
+	err = p.upsertScopedState(ctx, db, sess.AppName(), sess.UserID(), appState, userState, false)
+	if err != nil {
+		p.logger.Errorf("failed to persist scoped state of session %s: %v", sess.ID(), err)
+		return err
+	}
+
+	if p.audit {
+		if err := p.auditSession(ctx, db, sess, prevState, existed); err != nil {
+			return err
+		}
 	}
 
 	p.logger.Infof("session persisted: %s", sess.ID())
@@ -251,6 +602,30 @@ func (p *SessionPersister) persistSessionSync(ctx context.Context, sess session.
 	return nil
 }
 
+// encodeState returns the state of sess as stored in its row and, with
+// WithScopedState, its app and user keys.
+func (p *SessionPersister) encodeState(
+	ctx context.Context,
+	sess session.Session,
+) (stateJSON []byte, appState, userState map[string]any, err error) {
+	stateJSON = []byte("{}")
+	if state := sess.State(); state != nil {
+		stateMap := maps.Collect(state.All())
+		if p.scopedState {
+			stateMap, appState, userState = splitScopedState(stateMap)
+		}
+		if data, err := sonic.Marshal(stateMap); err == nil {
+			stateJSON = data
+		}
+	}
+
+	if stateJSON, err = p.encodeJSON(ctx, sess.AppName(), stateJSON); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to encode session state: %w", err)
+	}
+
+	return stateJSON, appState, userState, nil
+}
+
 // PersistEvent saves a single event to PostgreSQL (real-time sync).
 // If async mode is enabled, the operation is queued and returns immediately.
 func (p *SessionPersister) PersistEvent(
@@ -258,89 +633,189 @@ func (p *SessionPersister) PersistEvent(
 	sess session.Session,
 	evt *session.Event,
 ) error {
-	p.mu.Lock()
+	p.mu.RLock()
 	if p.closed {
-		p.mu.Unlock()
-		return errors.New("persister is closed")
+		p.mu.RUnlock()
+		return ErrPersisterClosed
 	}
-	p.mu.Unlock()
-
-	if p.asyncChan != nil {
-		select {
-		case p.asyncChan <- asyncOperation{operationType: operationEvent, sess: sess, evt: evt}:
-			return nil
+	p.mu.RUnlock()
 
-		default:
-			p.logger.Warn("async channel full, falling back to sync persist")
-		}
+	if queued, err := p.enqueue(ctx, asyncOperation{operationType: operationEvent, sess: sess, evt: evt}); queued || err != nil {
+		return err
 	}
 
-	return p.persistEventSync(ctx, sess, evt)
+	return p.persistEventSync(ctx, sess, evt, false)
 }
 
+// persistEventSync inserts an event of sess. With withState, the state of sess
+// is written in the same transaction, as SessionService does.
 func (p *SessionPersister) persistEventSync(
 	ctx context.Context,
 	sess session.Session,
 	evt *session.Event,
+	withState bool,
 ) error {
-	// Serialize event
-	evtData, err := sonic.Marshal(evt)
+	stored, ok := p.storedEvent(evt)
+	if !ok {
+		p.logger.Debugf("event dropped by the event transformer: session=%s, event=%s", sess.ID(), evt.ID)
+		if withState {
+			return p.persistSessionSync(ctx, sess)
+		}
+		return nil
+	}
+	evt = stored
+
+	evtData, stateArg, err := p.prepareEvent(ctx, sess, evt, withState)
 	if err != nil {
-		p.logger.Errorf("failed to marshal event %s: %v", evt.ID, err)
-		return fmt.Errorf("failed to marshal event: %w", err)
+		return err
 	}
 
-	tableName := p.client.GetEventsTableName(sess.UserID())
+	err = p.retrySerializable(ctx, func() error {
+		return p.commitEvent(ctx, sess, evt, evtData, stateArg)
+	})
+	if err != nil {
+		return err
+	}
+
+	p.logger.Debugf("event persisted: session=%s, event=%s, shard=%s",
+		sess.ID(), evt.ID, p.client.GetEventsTableName(sess.UserID()))
+	return nil
+}
 
+// commitEvent writes an event prepared by prepareEvent in its own transaction.
+func (p *SessionPersister) commitEvent(
+	ctx context.Context,
+	sess session.Session,
+	evt *session.Event,
+	evtData []byte,
+	stateArg any,
+) error {
 	// Use transaction to ensure atomicity when getting next order and inserting
 	tx, err := p.client.DB().BeginTx(ctx, nil)
 	if err != nil {
 		p.logger.Errorf("failed to begin transaction: %v", err)
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return pgerr.Wrap("failed to begin transaction", err)
 	}
 	defer func() { _ = tx.Rollback() }()
 
-	// Lock the session row to serialize event inserts for this session
-	lockQuery := `SELECT id FROM sessions WHERE app_name = $1 AND user_id = $2 AND id = $3 FOR UPDATE`
-	p.logger.Debugf(
-		"Lock Session SQL: %s, args: [%s, %s, %s]",
-		lockQuery,
-		sess.AppName(),
-		sess.UserID(),
-		sess.ID(),
-	)
-	var lockedID string
-	_ = tx.QueryRowContext(ctx, lockQuery, sess.AppName(), sess.UserID(), sess.ID()).Scan(&lockedID)
-	// Ignore error - session may not exist yet, but we still need the order
+	// The queries of every event use cached prepared statements
+	if err := p.insertEvent(ctx, tx, p.client.stmts.Tx(tx), sess, evt, evtData, stateArg); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		p.logger.Errorf("failed to commit transaction: %v", err)
+		return pgerr.Wrap("failed to commit transaction", err)
+	}
+	return nil
+}
+
+// prepareEvent encodes evt and, with withState, the state of sess, and creates
+// the range partition of evt if needed. The state is nil without withState.
+func (p *SessionPersister) prepareEvent(
+	ctx context.Context,
+	sess session.Session,
+	evt *session.Event,
+	withState bool,
+) (evtData []byte, stateArg any, err error) {
+	// Serialize event
+	evtData, err = sonic.Marshal(evt)
+	if err != nil {
+		p.logger.Errorf("failed to marshal event %s: %v", evt.ID, err)
+		return nil, nil, fmt.Errorf("failed to marshal event: %w", err)
+	}
+	evtData, err = p.encodeJSON(ctx, sess.AppName(), evtData)
+	if err != nil {
+		p.logger.Errorf("failed to encode event %s: %v", evt.ID, err)
+		return nil, nil, fmt.Errorf("failed to encode event: %w", err)
+	}
 
-	// Get next event order
+	if withState {
+		// NOTE: The scoped keys are written from the state delta of the event
+		stateJSON, _, _, err := p.encodeState(ctx, sess)
+		if err != nil {
+			p.logger.Errorf("failed to encode state of session %s: %v", sess.ID(), err)
+			return nil, nil, err
+		}
+		stateArg = stateJSON
+	}
+
+	if err := p.ensureEventPartition(ctx, evt.Timestamp); err != nil {
+		p.logger.Warnf("failed to create event partition, using the default partition: %v", err)
+	}
+
+	return evtData, stateArg, nil
+}
+
+// insertEvent writes an event prepared by prepareEvent within tx, running its
+// queries with q: tx itself, or tx bound to the statement cache.
+func (p *SessionPersister) insertEvent(
+	ctx context.Context,
+	tx *sql.Tx,
+	q txQueryer,
+	sess session.Session,
+	evt *session.Event,
+	evtData []byte,
+	stateArg any,
+) error {
+	tableName := p.client.GetEventsTableName(sess.UserID())
+
+	// NOTE: Claim the next event order from the counter of the session row, which
+	// locks the row until commit, and write the session update with it. The rows
+	// stored before the counter get one on their first event, after their events.
+	// The event keeps its order of arrival, whatever its timestamp, and an imported
+	// older event does not move last_update_time back
 	//nolint:gosec // table name is generated internally
-	orderQuery := `SELECT COALESCE(MAX(event_order), -1) + 1 FROM ` + tableName +
-		` WHERE app_name = $1 AND user_id = $2 AND session_id = $3`
+	claimQuery := `UPDATE ` + p.client.SessionsTableName() + ` SET
+			next_event_order = COALESCE(next_event_order, (SELECT COALESCE(MAX(event_order), -1) + 1 FROM ` +
+		tableName + ` WHERE app_name = $1 AND user_id = $2 AND session_id = $3)) + 1,
+			last_update_time = GREATEST(last_update_time, $4),
+			state = COALESCE($5::jsonb, state)
+		WHERE app_name = $1 AND user_id = $2 AND id = $3
+		RETURNING next_event_order - 1`
 
 	var nextOrder int
-	p.logger.Debugf(
-		"Get Next Order SQL: %s, args: [%s, %s, %s]",
-		orderQuery,
-		sess.AppName(),
-		sess.UserID(),
-		sess.ID(),
-	)
-	err = tx.QueryRowContext(ctx, orderQuery,
-		sess.AppName(), sess.UserID(), sess.ID()).Scan(&nextOrder)
+	claim := func() error {
+		p.logger.Debugf(
+			"Claim Event Order SQL: %s, args: [%s, %s, %s, %s, <state>]",
+			claimQuery,
+			sess.AppName(),
+			sess.UserID(),
+			sess.ID(),
+			evt.Timestamp,
+		)
+		return q.QueryRowContext(ctx, claimQuery,
+			sess.AppName(), sess.UserID(), sess.ID(), evt.Timestamp, stateArg).Scan(&nextOrder)
+	}
+	err := claim()
+
+	// NOTE: The session of a persisted event may be missing, e.g. when persisting
+	// it failed or its async operation is still queued: store it with the event.
+	// The events of SessionService, written with their state, require it
+	if errors.Is(err, sql.ErrNoRows) && stateArg == nil {
+		p.logger.Warnf("session %s missing, storing it with event %s", sess.ID(), evt.ID)
+		if err := p.upsertSession(ctx, q, sess); err != nil {
+			return err
+		}
+		err = claim()
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		p.logger.Errorf("session %s missing, dropping event %s", sess.ID(), evt.ID)
+		return fmt.Errorf("%w: %s", ErrSessionMissing, sess.ID())
+	}
 	if err != nil {
-		p.logger.Errorf("failed to get next event order: %v", err)
-		return fmt.Errorf("failed to get next event order: %w", err)
+		p.logger.Errorf("failed to claim event order of session %s: %v", sess.ID(), err)
+		return shardError("failed to claim event order", tableName, err)
 	}
 
 	// Insert event
 	//nolint:gosec // table name is generated internally
 	insertQuery := `INSERT INTO ` + tableName +
-		` (id, app_name, user_id, session_id, event_order, content, author, timestamp, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())`
+		` (id, app_name, user_id, session_id, event_order, content, author, branch, timestamp, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())`
 
 	p.logger.Debugf(
-		"Insert Event SQL: %s, args: [%s, %s, %s, %s, %d, <content>, %s, %s]",
+		"Insert Event SQL: %s, args: [%s, %s, %s, %s, %d, <content>, %s, %s, %s]",
 		insertQuery,
 		evt.ID,
 		sess.AppName(),
@@ -348,33 +823,40 @@ func (p *SessionPersister) persistEventSync(
 		sess.ID(),
 		nextOrder,
 		evt.Author,
+		evt.Branch,
 		evt.Timestamp,
 	)
-	_, err = tx.ExecContext(ctx, insertQuery,
+	_, err = q.ExecContext(ctx, insertQuery,
 		evt.ID, sess.AppName(), sess.UserID(), sess.ID(),
-		nextOrder, evtData, evt.Author, evt.Timestamp)
+		nextOrder, evtData, evt.Author, evt.Branch, evt.Timestamp)
 	if err != nil {
 		p.logger.Errorf("failed to insert event %s: %v", evt.ID, err)
-		return fmt.Errorf("failed to insert event: %w", err)
+		return shardError("failed to insert event", tableName, err)
 	}
 
-	// Also update session's last_update_time
-	updateQuery := `UPDATE sessions SET last_update_time = $1 WHERE app_name = $2 AND user_id = $3 AND id = $4`
-	p.logger.Debugf("Update Session SQL: %s, args: [%s, %s, %s, %s]",
-		updateQuery, evt.Timestamp, sess.AppName(), sess.UserID(), sess.ID())
-	if _, err = tx.ExecContext(ctx, updateQuery,
-		evt.Timestamp, sess.AppName(), sess.UserID(), sess.ID()); err != nil {
-		p.logger.Warnf("failed to update session last_update_time: %v", err)
-		// Don't fail the whole operation for this
+	// Apply the scoped keys of the state delta, atomically with the event
+	if p.scopedState && len(evt.Actions.StateDelta) > 0 {
+		_, appDelta, userDelta := splitScopedState(evt.Actions.StateDelta)
+		if err := p.upsertScopedState(ctx, q, sess.AppName(), sess.UserID(), appDelta, userDelta, true); err != nil {
+			p.logger.Errorf("failed to persist scoped state delta: session=%s, err=%v", sess.ID(), err)
+			return err
+		}
 	}
 
-	if err := tx.Commit(); err != nil {
-		p.logger.Errorf("failed to commit transaction: %v", err)
-		return fmt.Errorf("failed to commit transaction: %w", err)
+	// Record the completed turn for memory ingestion, atomically with the event
+	if p.memoryOutbox && completesTurn(evt) {
+		if err := p.enqueueOutbox(ctx, tx, sess.AppName(), sess.UserID(), sess.ID(), nextOrder); err != nil {
+			p.logger.Errorf("failed to enqueue memory outbox: session=%s, err=%v", sess.ID(), err)
+			return err
+		}
+	}
+
+	if p.audit {
+		if err := p.writeAudit(ctx, q, auditEvent(ctx, sess, evt)); err != nil {
+			return err
+		}
 	}
 
-	p.logger.Debugf("event persisted: session=%s, event=%s, shard=%s",
-		sess.ID(), evt.ID, tableName)
 	return nil
 }
 
@@ -384,26 +866,21 @@ func (p *SessionPersister) DeleteSession(
 	ctx context.Context,
 	appName, userID, sessionID string,
 ) error {
-	p.mu.Lock()
+	p.mu.RLock()
 	if p.closed {
-		p.mu.Unlock()
-		return errors.New("persister is closed")
+		p.mu.RUnlock()
+		return ErrPersisterClosed
 	}
-	p.mu.Unlock()
+	p.mu.RUnlock()
 
-	if p.asyncChan != nil {
-		select {
-		case p.asyncChan <- asyncOperation{
-			operationType: operationDelete,
-			appName:       appName,
-			userID:        userID,
-			sessionID:     sessionID,
-		}:
-			return nil
-
-		default:
-			p.logger.Warn("async channel full, falling back to sync delete")
-		}
+	queued, err := p.enqueue(ctx, asyncOperation{
+		operationType: operationDelete,
+		appName:       appName,
+		userID:        userID,
+		sessionID:     sessionID,
+	})
+	if queued || err != nil {
+		return err
 	}
 
 	return p.deleteSessionSync(ctx, appName, userID, sessionID)
@@ -413,12 +890,73 @@ func (p *SessionPersister) deleteSessionSync(
 	ctx context.Context,
 	appName, userID, sessionID string,
 ) error {
+	_, err := p.deleteSession(ctx, appName, userID, sessionID, time.Time{})
+	return err
+}
+
+// deleteSession deletes a session with its events and memory ingestion rows.
+// With a non-zero updatedAt, the session is only deleted if it was not updated
+// since, and deleteSession reports whether it was.
+func (p *SessionPersister) deleteSession(
+	ctx context.Context,
+	appName, userID, sessionID string,
+	updatedAt time.Time,
+) (deleted bool, err error) {
+	err = p.retrySerializable(ctx, func() error {
+		deleted, err = p.commitDelete(ctx, appName, userID, sessionID, updatedAt)
+		return err
+	})
+	return deleted, err
+}
+
+// commitDelete runs the transaction of deleteSession.
+func (p *SessionPersister) commitDelete(
+	ctx context.Context,
+	appName, userID, sessionID string,
+	updatedAt time.Time,
+) (bool, error) {
 	tx, err := p.client.DB().BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return false, pgerr.Wrap("failed to begin transaction", err)
 	}
 	defer func() { _ = tx.Rollback() }()
 
+	// Delete session
+	// NOTE: Deleted first, so that an event being persisted concurrently waits on
+	// the session row, then finds it missing
+	//nolint:gosec // table name is validated by the client
+	sessionQuery := `DELETE FROM ` + p.client.SessionsTableName() + ` WHERE app_name = $1 AND user_id = $2 AND id = $3`
+	args := []any{appName, userID, sessionID}
+	if !updatedAt.IsZero() {
+		sessionQuery += ` AND last_update_time = $4`
+		args = append(args, updatedAt)
+	}
+	p.logger.Debugf("Delete Session SQL: %s, args: %v", sessionQuery, args)
+	res, err := tx.ExecContext(ctx, sessionQuery, args...)
+	if err != nil {
+		return false, pgerr.Wrap("failed to delete session", err)
+	}
+	if !updatedAt.IsZero() || p.audit {
+		n, err := res.RowsAffected()
+		if err != nil {
+			return false, pgerr.Wrap("failed to delete session", err)
+		}
+		if n == 0 && !updatedAt.IsZero() {
+			return false, nil // Updated since, or already deleted
+		}
+
+		// NOTE: Deleting a session not stored changes nothing to audit
+		if n > 0 && p.audit {
+			rec := auditRecord{
+				appName: appName, userID: userID, sessionID: sessionID,
+				action: AuditDelete, actor: AuditActor(ctx),
+			}
+			if err := p.writeAudit(ctx, tx, rec); err != nil {
+				return false, err
+			}
+		}
+	}
+
 	// Delete events from sharded table
 	tableName := p.client.GetEventsTableName(userID)
 	//nolint:gosec // table name is generated internally
@@ -432,49 +970,34 @@ func (p *SessionPersister) deleteSessionSync(
 	)
 	_, err = tx.ExecContext(ctx, eventsQuery, appName, userID, sessionID)
 	if err != nil {
-		return fmt.Errorf("failed to delete events: %w", err)
+		return false, shardError("failed to delete events", tableName, err)
 	}
 
-	// Delete session
-	sessionQuery := `DELETE FROM sessions WHERE app_name = $1 AND user_id = $2 AND id = $3`
-	p.logger.Debugf(
-		"Delete Session SQL: %s, args: [%s, %s, %s]",
-		sessionQuery,
-		appName,
-		userID,
-		sessionID,
-	)
-	_, err = tx.ExecContext(ctx, sessionQuery, appName, userID, sessionID)
-	if err != nil {
-		return fmt.Errorf("failed to delete session: %w", err)
+	// Drop pending memory ingestion and its state
+	if p.memoryOutbox {
+		for _, table := range []string{"memory_outbox", "memory_ingestion_state"} {
+			//nolint:gosec // table name is validated by the client
+			query := `DELETE FROM ` + p.client.tableName(table) + ` WHERE app_name = $1 AND user_id = $2 AND session_id = $3`
+			if _, err = tx.ExecContext(ctx, query, appName, userID, sessionID); err != nil {
+				return false, pgerr.Wrap("failed to delete "+table+" entries", err)
+			}
+		}
 	}
 
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		return false, pgerr.Wrap("failed to commit transaction", err)
 	}
 
 	p.logger.Debugf("session deleted from postgres: %s", sessionID)
-	return nil
+	return true, nil
 }
 
 // Close closes the persister and releases resources.
-// It waits for all pending async operations to complete before returning.
+// It waits for all pending async operations to complete before returning; see
+// CloseWithContext to bound the wait.
 func (p *SessionPersister) Close() error {
-	p.mu.Lock()
-	if p.closed {
-		p.mu.Unlock()
-		return nil
-	}
-	p.closed = true
-	p.mu.Unlock()
-
-	if p.asyncChan != nil {
-		close(p.asyncChan)
-		p.wg.Wait() // Wait for all async operations to complete
-	}
-
-	p.logger.Info("PostgreSQL session persister closed")
-	return nil
+	_, err := p.CloseWithContext(context.Background())
+	return err
 }
 
 // Client returns the underlying PostgreSQL client.
@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"iter"
 	"os"
@@ -346,6 +347,32 @@ func TestPersistEvent(t *testing.T) {
 		_, _ = client.DB().ExecContext(ctx, query)
 	}
 
+	t.Run("missing session", func(t *testing.T) {
+		sess := createTestSession("sess-evt-missing", "test_app", "user-evt")
+
+		// The missing session is stored with its event, not dropped
+		if err := persister.PersistEvent(ctx, sess, createTestEvent("evt-missing", "user")); err != nil {
+			t.Fatalf("PersistEvent failed: %v", err)
+		}
+
+		var sessions, events int
+		err := client.DB().QueryRowContext(ctx,
+			"SELECT COUNT(*) FROM sessions WHERE app_name = $1 AND id = $2",
+			"test_app", "sess-evt-missing").Scan(&sessions)
+		if err != nil {
+			t.Fatalf("Failed to count sessions: %v", err)
+		}
+		err = client.DB().QueryRowContext(ctx,
+			"SELECT COUNT(*) FROM "+client.GetEventsTableName("user-evt")+" WHERE session_id = $1 AND id = $2",
+			"sess-evt-missing", "evt-missing").Scan(&events)
+		if err != nil {
+			t.Fatalf("Failed to count events: %v", err)
+		}
+		if sessions != 1 || events != 1 {
+			t.Errorf("expected the session and its event stored, got %d sessions and %d events", sessions, events)
+		}
+	})
+
 	t.Run("basic event persist", func(t *testing.T) {
 		sess := createTestSession("sess-evt-1", "test_app", "user-evt")
 		evt := createTestEvent("evt-1", "user")
@@ -431,6 +458,38 @@ func TestPersistEvent(t *testing.T) {
 
 		t.Logf("✓ multiple events ordering: events ordered correctly %v", orders)
 	})
+
+	t.Run("session stored before the order counter", func(t *testing.T) {
+		sess := createTestSession("sess-evt-legacy", "test_app", "user-evt-legacy")
+		if err := persister.PersistSession(ctx, sess); err != nil {
+			t.Fatalf("PersistSession failed: %v", err)
+		}
+		for i := range 2 {
+			if err := persister.PersistEvent(ctx, sess, createTestEvent(fmt.Sprintf("evt-legacy-%d", i), "user")); err != nil {
+				t.Fatalf("PersistEvent %d failed: %v", i, err)
+			}
+		}
+
+		// NOTE: The rows of older versions have no counter
+		if _, err := client.DB().ExecContext(ctx,
+			"UPDATE sessions SET next_event_order = NULL WHERE id = $1", "sess-evt-legacy"); err != nil {
+			t.Fatalf("Failed to clear the counter: %v", err)
+		}
+		if err := persister.PersistEvent(ctx, sess, createTestEvent("evt-legacy-2", "user")); err != nil {
+			t.Fatalf("PersistEvent failed: %v", err)
+		}
+
+		var order int
+		err := client.DB().QueryRowContext(ctx,
+			"SELECT event_order FROM "+client.GetEventsTableName("user-evt-legacy")+" WHERE id = $1",
+			"evt-legacy-2").Scan(&order)
+		if err != nil {
+			t.Fatalf("Failed to query event: %v", err)
+		}
+		if order != 2 {
+			t.Errorf("event order = %d, want 2", order)
+		}
+	})
 }
 
 func TestDeleteSession(t *testing.T) {
@@ -528,8 +587,8 @@ func TestClose(t *testing.T) {
 
 	// Should not accept new operations after close
 	err = persister.PersistSession(ctx, sess)
-	if err == nil {
-		t.Error("Expected error after Close")
+	if !errors.Is(err, ErrPersisterClosed) {
+		t.Errorf("Expected ErrPersisterClosed after Close, got: %v", err)
 	}
 
 	// Double close should be safe
@@ -595,6 +654,71 @@ func TestAsyncBufferSize(t *testing.T) {
 
 		t.Logf("✓ sync mode: operations executed synchronously")
 	})
+
+	t.Run("async workers", func(t *testing.T) {
+		persister, err := NewSessionPersister(ctx, client, WithAsyncWorkers(4))
+		if err != nil {
+			t.Fatalf("Failed to create persister: %v", err)
+		}
+
+		for i := range 8 {
+			sess := createTestSession(fmt.Sprintf("sess-workers-%d", i), "test_app", "user-workers")
+			if err := persister.deleteSessionSync(ctx, "test_app", "user-workers", sess.ID()); err != nil {
+				t.Fatalf("deleteSessionSync failed: %v", err)
+			}
+			if err := persister.PersistSession(ctx, sess); err != nil {
+				t.Fatalf("PersistSession failed: %v", err)
+			}
+			for j := range 5 {
+				evt := createTestEvent(fmt.Sprintf("evt-workers-%d-%d", i, j), "user")
+				if err := persister.PersistEvent(ctx, sess, evt); err != nil {
+					t.Fatalf("PersistEvent failed: %v", err)
+				}
+			}
+		}
+		persister.Close() // Wait for the workers to drain
+
+		for i := range 8 {
+			stored, err := persister.LoadSession(ctx, "test_app", "user-workers", fmt.Sprintf("sess-workers-%d", i))
+			if err != nil {
+				t.Fatalf("LoadSession failed: %v", err)
+			}
+			if len(stored.Events) != 5 {
+				t.Fatalf("session %d has %d events, want 5", i, len(stored.Events))
+			}
+			for j, evt := range stored.Events {
+				if want := fmt.Sprintf("evt-workers-%d-%d", i, j); evt.ID != want {
+					t.Errorf("event %d = %s, want %s", j, evt.ID, want)
+				}
+			}
+		}
+	})
+}
+
+func TestWorkerIndex(t *testing.T) {
+	p := &SessionPersister{asyncChans: make([]chan asyncOperation, 4)}
+
+	sess := createTestSession("sess-worker", "test_app", "user-worker")
+	worker := p.workerIndex(asyncOperation{operationType: operationSession, sess: sess})
+
+	// NOTE: every operation of a session goes to the same worker, keeping its order
+	ops := []asyncOperation{
+		{operationType: operationEvent, sess: sess, evt: createTestEvent("evt-worker", "user")},
+		{operationType: operationDelete, appName: "test_app", userID: "user-worker", sessionID: "sess-worker"},
+	}
+	for _, op := range ops {
+		if got := p.workerIndex(op); got != worker {
+			t.Errorf("%s operation on worker %d, want %d", op.operationType, got, worker)
+		}
+	}
+
+	used := make(map[int]bool)
+	for i := range 64 {
+		used[p.workerIndex(asyncOperation{sess: createTestSession(fmt.Sprintf("sess-%d", i), "test_app", "user-worker")})] = true
+	}
+	if len(used) != len(p.asyncChans) {
+		t.Errorf("sessions spread over %d workers, want %d", len(used), len(p.asyncChans))
+	}
 }
 
 func TestShardDistribution(t *testing.T) {
@@ -752,3 +876,89 @@ func TestSchemaCreation(t *testing.T) {
 	t.Logf("✓ schema creation: sessions table and %d event shard tables created",
 		client.ShardCount())
 }
+
+func TestTableNames(t *testing.T) {
+	c := &Client{schema: "chat", sessionsTable: "chat_sessions", eventsTablePrefix: "chat_events_", shardCount: 4}
+	if got := c.SessionsTableName(); got != "chat.chat_sessions" {
+		t.Errorf("SessionsTableName() = %s, want chat.chat_sessions", got)
+	}
+	if got := c.EventsTableName(2); got != "chat.chat_events_2" {
+		t.Errorf("EventsTableName(2) = %s, want chat.chat_events_2", got)
+	}
+
+	// NOTE: The indexes of the default tables keep their names
+	for table, want := range map[string]string{
+		defaultSessionsTable:     "idx_sessions",
+		defaultEventsTablePrefix: "idx_events_",
+		"chat_events_":           "idx_chat_events_",
+	} {
+		if got := indexPrefix(table); got != want {
+			t.Errorf("indexPrefix(%s) = %s, want %s", table, got, want)
+		}
+	}
+
+	for _, cfg := range []*Config{
+		{ConnStr: getTestConnString(), Schema: "chat; DROP TABLE sessions"},
+		{ConnStr: getTestConnString(), SessionsTable: "Sessions"},
+		{ConnStr: getTestConnString(), EventsTablePrefix: "events-"},
+	} {
+		if _, err := NewPostgresClient(context.Background(), cfg); err == nil {
+			t.Errorf("NewPostgresClient() accepted %s", cfg)
+		}
+	}
+}
+
+func TestCustomTableNames(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := NewPostgresClient(ctx, &Config{
+		ConnStr:           getTestConnString(),
+		ShardCount:        2,
+		Schema:            "test_kadk",
+		SessionsTable:     "chat_sessions",
+		EventsTablePrefix: "chat_events_",
+	})
+	if err != nil {
+		t.Skipf("PostgreSQL not available, skipping test: %v", err)
+		return
+	}
+	defer client.Close()
+
+	persister, err := NewSessionPersister(ctx, client, WithAsyncBufferSize(0))
+	if err != nil {
+		t.Fatalf("Failed to create persister: %v", err)
+	}
+	defer persister.Close()
+
+	if err := persister.deleteSessionSync(ctx, "test_app", "user-names", "sess-names"); err != nil {
+		t.Fatalf("deleteSessionSync failed: %v", err)
+	}
+	sess := createTestSessionWithState("sess-names", "test_app", "user-names", map[string]any{"step": 1})
+	if err := persister.PersistSession(ctx, sess); err != nil {
+		t.Fatalf("PersistSession failed: %v", err)
+	}
+	if err := persister.PersistEvent(ctx, sess, createTestEvent("evt-names", "user")); err != nil {
+		t.Fatalf("PersistEvent failed: %v", err)
+	}
+
+	for _, table := range []string{client.SessionsTableName(), client.GetEventsTableName("user-names")} {
+		var count int
+		//nolint:gosec // table name is validated by the client
+		query := "SELECT COUNT(*) FROM " + table + " WHERE app_name = 'test_app' AND user_id = 'user-names'"
+		if err := client.DB().QueryRowContext(ctx, query).Scan(&count); err != nil {
+			t.Fatalf("Failed to count rows of %s: %v", table, err)
+		}
+		if count != 1 {
+			t.Errorf("%s has %d rows, want 1", table, count)
+		}
+	}
+
+	stored, err := persister.LoadSession(ctx, "test_app", "user-names", "sess-names")
+	if err != nil || stored == nil {
+		t.Fatalf("LoadSession = %v, %v", stored, err)
+	}
+	if len(stored.Events) != 1 || stored.State["step"] != float64(1) {
+		t.Errorf("unexpected session: %d events, state %v", len(stored.Events), stored.State)
+	}
+}
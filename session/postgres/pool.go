@@ -0,0 +1,151 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// Driver selects the PostgreSQL driver of a Client, see Config.Driver.
+type Driver string
+
+const (
+	// DriverPQ connects with lib/pq and pools the connections in database/sql.
+	DriverPQ Driver = "pq"
+
+	// DriverPGX connects with pgx and pools the connections in a pgxpool.Pool,
+	// which DB wraps. pgx sends and reads the parameters and results in the binary
+	// format where it can, which is cheaper for the large JSONB states and events.
+	DriverPGX Driver = "pgx"
+)
+
+// PoolStats reports the state of the connection pool of a Client.
+type PoolStats struct {
+	Driver Driver
+
+	// MaxConns is the maximum number of open connections, 0 for no limit.
+	MaxConns int
+
+	// TotalConns, AcquiredConns and IdleConns are the connections open, in use and
+	// idle.
+	TotalConns    int
+	AcquiredConns int
+	IdleConns     int
+
+	// WaitCount is the number of connections waited for, as the pool was
+	// exhausted, and WaitDuration the total time waited for them.
+	WaitCount    int64
+	WaitDuration time.Duration
+
+	// AcquireCount, AcquireDuration and CanceledAcquireCount are the number of
+	// connections acquired, the total time taken to acquire them and the number
+	// of acquisitions canceled by their context. They are only set with DriverPGX.
+	AcquireCount         int64
+	AcquireDuration      time.Duration
+	CanceledAcquireCount int64
+}
+
+// openDB opens the database of cfg with its driver. The pgx pool is nil with
+// DriverPQ.
+func openDB(ctx context.Context, cfg *Config, driver Driver) (*sql.DB, *pgxpool.Pool, error) {
+	if driver == DriverPGX {
+		return openPGX(ctx, cfg)
+	}
+
+	db, err := sql.Open("postgres", cfg.ConnStr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Configure connection pool
+	if cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+
+	return db, nil, nil
+}
+
+// openPGX opens a pgxpool.Pool and the database/sql wrapper of it.
+func openPGX(ctx context.Context, cfg *Config) (*sql.DB, *pgxpool.Pool, error) {
+	poolCfg, err := pgxpool.ParseConfig(cfg.ConnStr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid connection string: %w", err)
+	}
+
+	// Configure connection pool
+	// NOTE: The pool keeps its idle connections until ConnMaxIdleTime, so
+	// MaxIdleConns does not apply
+	if cfg.MaxOpenConns > 0 {
+		poolCfg.MaxConns = int32(min(cfg.MaxOpenConns, 1<<31-1)) //nolint:gosec // capped to int32
+	}
+	if cfg.ConnMaxIdleTime > 0 {
+		poolCfg.MaxConnIdleTime = cfg.ConnMaxIdleTime
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		poolCfg.MaxConnLifetime = cfg.ConnMaxLifetime
+	}
+
+	// pgx prepares and caches every query by default, which poolers in transaction
+	// mode cannot follow
+	if cfg.DisableStatementCache {
+		poolCfg.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeExec
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return stdlib.OpenDBFromPool(pool), pool, nil
+}
+
+// Driver returns the driver of the client.
+func (c *Client) Driver() Driver { return c.driver }
+
+// Pool returns the pgx pool of the client, nil with DriverPQ. Queries on it skip
+// database/sql, e.g. for pgx.CopyFrom.
+func (c *Client) Pool() *pgxpool.Pool { return c.pool }
+
+// PoolStats returns the statistics of the connection pool.
+func (c *Client) PoolStats() PoolStats {
+	if c.pool != nil {
+		stat := c.pool.Stat()
+		return PoolStats{
+			Driver:               DriverPGX,
+			MaxConns:             int(stat.MaxConns()),
+			TotalConns:           int(stat.TotalConns()),
+			AcquiredConns:        int(stat.AcquiredConns()),
+			IdleConns:            int(stat.IdleConns()),
+			WaitCount:            stat.EmptyAcquireCount(),
+			WaitDuration:         stat.EmptyAcquireWaitTime(),
+			AcquireCount:         stat.AcquireCount(),
+			AcquireDuration:      stat.AcquireDuration(),
+			CanceledAcquireCount: stat.CanceledAcquireCount(),
+		}
+	}
+
+	stat := c.db.Stats()
+	return PoolStats{
+		Driver:        DriverPQ,
+		MaxConns:      stat.MaxOpenConnections,
+		TotalConns:    stat.OpenConnections,
+		AcquiredConns: stat.InUse,
+		IdleConns:     stat.Idle,
+		WaitCount:     stat.WaitCount,
+		WaitDuration:  stat.WaitDuration,
+	}
+}
@@ -0,0 +1,93 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kydenul/k-adk/internal/pgerr"
+)
+
+func TestInvalidDriver(t *testing.T) {
+	_, err := NewPostgresClient(context.Background(), &Config{
+		ConnStr: getTestConnString(),
+		Driver:  "mysql",
+	})
+	if err == nil {
+		t.Fatal("expected an error for an invalid driver")
+	}
+}
+
+func TestPoolStats(t *testing.T) {
+	persister, client := setupTestDB(t)
+	if persister == nil {
+		return
+	}
+	defer persister.Close()
+	defer client.Close()
+
+	stats := client.PoolStats()
+	if stats.Driver != DriverPQ || client.Pool() != nil {
+		t.Errorf("driver = %s, want %s without a pgx pool", stats.Driver, DriverPQ)
+	}
+	if stats.TotalConns < stats.IdleConns+stats.AcquiredConns {
+		t.Errorf("unexpected pool stats: %+v", stats)
+	}
+}
+
+func TestPGXDriver(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := NewPostgresClient(ctx, &Config{
+		ConnStr:      getTestConnString(),
+		Driver:       DriverPGX,
+		MaxOpenConns: 5,
+		ShardCount:   4,
+	})
+	if err != nil {
+		t.Skipf("PostgreSQL not available, skipping test: %v", err)
+		return
+	}
+	defer client.Close()
+
+	persister, err := NewSessionPersister(ctx, client, WithAsyncBufferSize(0))
+	if err != nil {
+		t.Fatalf("Failed to create persister: %v", err)
+	}
+	defer persister.Close()
+
+	if err := persister.deleteSessionSync(ctx, "test_app", "user-pgx", "sess-pgx"); err != nil {
+		t.Fatalf("deleteSessionSync failed: %v", err)
+	}
+	sess := createTestSessionWithState("sess-pgx", "test_app", "user-pgx", map[string]any{"step": 1})
+	if err := persister.PersistSession(ctx, sess); err != nil {
+		t.Fatalf("PersistSession failed: %v", err)
+	}
+	if err := persister.PersistEvent(ctx, sess, createTestEvent("evt-pgx", "user")); err != nil {
+		t.Fatalf("PersistEvent failed: %v", err)
+	}
+
+	stored, err := persister.LoadSession(ctx, "test_app", "user-pgx", "sess-pgx")
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+	if stored == nil || stored.State["step"] != float64(1) || len(stored.Events) != 1 {
+		t.Fatalf("unexpected session: %+v", stored)
+	}
+
+	// The pgx errors are classified as the lib/pq ones
+	_, err = client.DB().ExecContext(ctx, "SELECT 1 FROM test_kadk_missing_table")
+	if !errors.Is(pgerr.Classify(err), pgerr.ErrUnavailableTable) {
+		t.Errorf("Classify(%v) is not ErrUnavailableTable", err)
+	}
+
+	stats := client.PoolStats()
+	if stats.Driver != DriverPGX || client.Pool() == nil {
+		t.Errorf("driver = %s, want %s with a pgx pool", stats.Driver, DriverPGX)
+	}
+	if stats.MaxConns != 5 || stats.AcquireCount == 0 {
+		t.Errorf("unexpected pool stats: %+v", stats)
+	}
+}
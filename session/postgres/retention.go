@@ -0,0 +1,246 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/kydenul/k-adk/internal/pgerr"
+	ksess "github.com/kydenul/k-adk/session"
+)
+
+// defaultRetentionBatchSize is the number of sessions Prune reads at once.
+const defaultRetentionBatchSize = 100
+
+// Retention configures the pruning of old sessions, with their events, from
+// PostgreSQL. A session is pruned when it is older than MaxAge or when its user
+// has more than MaxSessionsPerUser more recently updated sessions.
+type Retention struct {
+	// Optional. MaxAge prunes the sessions not updated for longer.
+	// Default: 0, no age limit.
+	MaxAge time.Duration
+
+	// Optional. MaxSessionsPerUser keeps the most recently updated sessions of
+	// each user of an app, and prunes the others. Default: 0, no limit.
+	MaxSessionsPerUser int
+
+	// Optional. Interval runs Prune in the background until Close.
+	// Default: 0, Prune only runs when called.
+	Interval time.Duration
+
+	// Optional. AppName prunes only the sessions of this app. Default: "", the
+	// sessions of every app.
+	AppName string
+
+	// Optional. DryRun counts the sessions Prune would delete, without archiving
+	// or deleting them. Default: false.
+	DryRun bool
+
+	// Optional. BatchSize is the number of sessions read at once. Default: 100.
+	BatchSize int
+
+	// Optional. Archive receives each session, with its events, before it is
+	// deleted. A session that fails to be archived is kept until the next Prune,
+	// and a session updated while being archived is kept too, so Archive may see
+	// a session more than once. Default: nil, sessions are deleted.
+	Archive func(ctx context.Context, sess *ksess.StoredSession) error
+}
+
+// WithRetention sets the retention policy applied by Prune, and runs Prune in
+// the background if r.Interval is set.
+func WithRetention(r Retention) PersisterOption {
+	return func(p *SessionPersister) {
+		r.MaxAge = max(r.MaxAge, 0)
+		r.MaxSessionsPerUser = max(r.MaxSessionsPerUser, 0)
+		r.Interval = max(r.Interval, 0)
+		if r.BatchSize <= 0 {
+			r.BatchSize = defaultRetentionBatchSize
+		}
+		p.retention = r
+	}
+}
+
+// sessionRef identifies a session to prune, as it was when it was read.
+type sessionRef struct {
+	appName        string
+	userID         string
+	sessionID      string
+	lastUpdateTime time.Time
+}
+
+// Prune deletes, or archives then deletes, the sessions out of the retention
+// policy set by WithRetention, with their events. It returns the number of
+// sessions pruned, or that would be with DryRun. Without a policy, Prune does
+// nothing.
+func (p *SessionPersister) Prune(ctx context.Context) (int, error) {
+	pruned := 0
+
+	// NOTE: A dry run deletes nothing, so a session out of both limits would be
+	// selected twice
+	var counted map[sessionRef]bool
+	if p.retention.DryRun {
+		counted = make(map[sessionRef]bool)
+	}
+
+	if p.retention.MaxAge > 0 {
+		cutoff := time.Now().Add(-p.retention.MaxAge)
+		//nolint:gosec // table name is validated by the client
+		query := `
+			SELECT app_name, user_id, id, last_update_time FROM ` + p.client.SessionsTableName() + `
+			WHERE last_update_time < $1 AND ($4 = '' OR app_name = $4)
+			ORDER BY last_update_time, id
+			LIMIT $2 OFFSET $3`
+		n, err := p.pruneBatches(ctx, query, cutoff, counted)
+		pruned += n
+		if err != nil {
+			return pruned, err
+		}
+	}
+
+	if p.retention.MaxSessionsPerUser > 0 {
+		//nolint:gosec // table name is validated by the client
+		query := `
+			SELECT app_name, user_id, id, last_update_time FROM (
+				SELECT app_name, user_id, id, last_update_time,
+					ROW_NUMBER() OVER (
+						PARTITION BY app_name, user_id
+						ORDER BY last_update_time DESC, id DESC
+					) AS session_rank
+				FROM ` + p.client.SessionsTableName() + `
+				WHERE $4 = '' OR app_name = $4
+			) AS ranked
+			WHERE session_rank > $1
+			ORDER BY last_update_time, id
+			LIMIT $2 OFFSET $3`
+		n, err := p.pruneBatches(ctx, query, p.retention.MaxSessionsPerUser, counted)
+		pruned += n
+		if err != nil {
+			return pruned, err
+		}
+	}
+
+	if pruned > 0 && !p.retention.DryRun {
+		p.logger.Infof("pruned %d sessions from postgres", pruned)
+	}
+	return pruned, nil
+}
+
+// pruneBatches prunes the sessions query selects, given arg, a batch size, an
+// offset and the app name, until it selects no more sessions. A dry run counts
+// the sessions not in counted instead, and adds them to it.
+func (p *SessionPersister) pruneBatches(
+	ctx context.Context,
+	query string,
+	arg any,
+	counted map[sessionRef]bool,
+) (int, error) {
+	pruned, kept := 0, 0
+	for {
+		// NOTE: The sessions kept, e.g. failing to be archived, are selected again
+		// ahead of the others, so skip them
+		refs, err := p.selectSessionRefs(ctx, query, arg, kept)
+		if err != nil {
+			return pruned, err
+		}
+
+		for _, ref := range refs {
+			if err := ctx.Err(); err != nil {
+				return pruned, err
+			}
+			if counted != nil {
+				if !counted[ref] {
+					counted[ref] = true
+					pruned++
+				}
+				kept++
+				continue
+			}
+			ok, err := p.pruneSession(ctx, ref)
+			if err != nil {
+				return pruned, err
+			}
+			if ok {
+				pruned++
+			} else {
+				kept++
+			}
+		}
+
+		if len(refs) < p.retention.BatchSize {
+			return pruned, nil
+		}
+	}
+}
+
+func (p *SessionPersister) selectSessionRefs(
+	ctx context.Context,
+	query string,
+	arg any,
+	offset int,
+) ([]sessionRef, error) {
+	rows, err := p.client.DB().QueryContext(ctx, query, arg, p.retention.BatchSize, offset, p.retention.AppName)
+	if err != nil {
+		return nil, pgerr.Wrap("failed to select sessions to prune", err)
+	}
+	defer rows.Close()
+
+	var refs []sessionRef
+	for rows.Next() {
+		var ref sessionRef
+		if err := rows.Scan(&ref.appName, &ref.userID, &ref.sessionID, &ref.lastUpdateTime); err != nil {
+			return nil, pgerr.Wrap("failed to scan session to prune", err)
+		}
+		refs = append(refs, ref)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, pgerr.Wrap("failed to select sessions to prune", err)
+	}
+	return refs, nil
+}
+
+// pruneSession archives and deletes the session of ref, unless it was updated
+// since ref was read. It reports whether the session was deleted.
+func (p *SessionPersister) pruneSession(ctx context.Context, ref sessionRef) (bool, error) {
+	if p.retention.Archive != nil {
+		stored, err := p.loadSession(ctx, ref.appName, ref.userID, ref.sessionID, 0, time.Time{})
+		if err != nil {
+			return false, err
+		}
+		if stored == nil {
+			return false, nil // Deleted since
+		}
+		if err := p.retention.Archive(ctx, stored); err != nil {
+			p.logger.Warnf("failed to archive session %s, keeping it: %v", ref.sessionID, err)
+			return false, nil
+		}
+	}
+
+	if AuditActor(ctx) == "" {
+		ctx = WithAuditActor(ctx, AuditActorRetention)
+	}
+	return p.deleteSession(ctx, ref.appName, ref.userID, ref.sessionID, ref.lastUpdateTime)
+}
+
+// startPruning runs Prune at the retention interval until Close.
+func (p *SessionPersister) startPruning() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.stopPruning = cancel
+
+	p.wg.Go(func() {
+		ticker := time.NewTicker(p.retention.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if _, err := p.Prune(ctx); err != nil && ctx.Err() == nil {
+				p.logger.Errorf("session pruning failed: %v", err)
+			}
+		}
+	})
+
+	p.logger.Infof("session pruning started: interval=%s", p.retention.Interval)
+}
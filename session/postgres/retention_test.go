@@ -0,0 +1,157 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	ksess "github.com/kydenul/k-adk/session"
+)
+
+func TestWithRetention(t *testing.T) {
+	var p SessionPersister
+	WithRetention(Retention{MaxAge: -time.Hour, MaxSessionsPerUser: 10})(&p)
+
+	if p.retention.MaxAge != 0 {
+		t.Errorf("MaxAge = %s, want 0", p.retention.MaxAge)
+	}
+	if p.retention.BatchSize != defaultRetentionBatchSize {
+		t.Errorf("BatchSize = %d, want %d", p.retention.BatchSize, defaultRetentionBatchSize)
+	}
+	if p.retention.MaxSessionsPerUser != 10 {
+		t.Errorf("MaxSessionsPerUser = %d, want 10", p.retention.MaxSessionsPerUser)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	persister, client := setupTestDB(t)
+	if persister == nil {
+		return
+	}
+	defer persister.Close()
+	defer client.Close()
+
+	ctx := context.Background()
+
+	var archived []string
+	pruner, err := NewSessionPersister(ctx, client, WithAsyncBufferSize(0), WithRetention(Retention{
+		MaxAge:             24 * time.Hour,
+		MaxSessionsPerUser: 2,
+		BatchSize:          1,
+		Archive: func(_ context.Context, sess *ksess.StoredSession) error {
+			if sess.ID == "sess-prune-fail" {
+				return errors.New("archive unavailable")
+			}
+			archived = append(archived, sess.ID)
+			return nil
+		},
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create persister: %v", err)
+	}
+	defer pruner.Close()
+
+	now := time.Now()
+	for id, age := range map[string]time.Duration{
+		"sess-prune-expired": 48 * time.Hour,
+		"sess-prune-fail":    72 * time.Hour,
+		"sess-prune-1":       3 * time.Hour,
+		"sess-prune-2":       2 * time.Hour,
+		"sess-prune-3":       time.Hour,
+	} {
+		sess := createTestSession(id, "test_app", "user-prune")
+		sess.lastUpdateTime = now.Add(-age)
+		if err := persister.persistSessionSync(ctx, sess); err != nil {
+			t.Fatalf("persistSessionSync failed: %v", err)
+		}
+		evt := createTestEvent("evt-"+id, "user")
+		evt.Timestamp = sess.lastUpdateTime
+		if err := persister.persistEventSync(ctx, sess, evt, false); err != nil {
+			t.Fatalf("persistEventSync failed: %v", err)
+		}
+	}
+
+	for appName, want := range map[string]int{"test_app": 3, "other_app": 0} {
+		dryRun, err := NewSessionPersister(ctx, client, WithAsyncBufferSize(0), WithRetention(Retention{
+			MaxAge:             24 * time.Hour,
+			MaxSessionsPerUser: 2,
+			AppName:            appName,
+			DryRun:             true,
+			BatchSize:          1,
+		}))
+		if err != nil {
+			t.Fatalf("Failed to create persister: %v", err)
+		}
+		counted, err := dryRun.Prune(ctx)
+		dryRun.Close()
+		if err != nil {
+			t.Fatalf("dry run Prune failed: %v", err)
+		}
+		if counted != want {
+			t.Errorf("dry run of %s counted %d sessions, want %d", appName, counted, want)
+		}
+	}
+
+	pruned, err := pruner.Prune(ctx)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if pruned != 2 {
+		t.Errorf("pruned %d sessions, want 2", pruned)
+	}
+	slices.Sort(archived)
+	if !slices.Equal(archived, []string{"sess-prune-1", "sess-prune-expired"}) {
+		t.Errorf("archived = %v", archived)
+	}
+
+	for id, want := range map[string]bool{
+		"sess-prune-expired": false,
+		"sess-prune-fail":    true, // Kept, failing to be archived
+		"sess-prune-1":       false,
+		"sess-prune-2":       true,
+		"sess-prune-3":       true,
+	} {
+		stored, err := persister.LoadSession(ctx, "test_app", "user-prune", id)
+		if err != nil {
+			t.Fatalf("LoadSession failed: %v", err)
+		}
+		if (stored != nil) != want {
+			t.Errorf("session %s stored = %t, want %t", id, stored != nil, want)
+		}
+	}
+}
+
+func TestDeleteSessionUpdatedSince(t *testing.T) {
+	persister, client := setupTestDB(t)
+	if persister == nil {
+		return
+	}
+	defer persister.Close()
+	defer client.Close()
+
+	ctx := context.Background()
+
+	sess := createTestSession("sess-prune-updated", "test_app", "user-prune")
+	if err := persister.persistSessionSync(ctx, sess); err != nil {
+		t.Fatalf("persistSessionSync failed: %v", err)
+	}
+
+	deleted, err := persister.deleteSession(ctx, "test_app", "user-prune", "sess-prune-updated",
+		sess.lastUpdateTime.Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("deleteSession failed: %v", err)
+	}
+	if deleted {
+		t.Error("deleted a session updated since")
+	}
+
+	stored, err := persister.LoadSession(ctx, "test_app", "user-prune", "sess-prune-updated")
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+	if stored == nil {
+		t.Fatal("session updated since was deleted")
+	}
+}
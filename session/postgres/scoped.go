@@ -0,0 +1,170 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	"github.com/kydenul/k-adk/internal/pgerr"
+	"google.golang.org/adk/session"
+)
+
+// scopedStateSchema creates the tables of the app-scoped and user-scoped state,
+// keyed without their "app:" and "user:" prefixes as in the ADK database session
+// service.
+const scopedStateSchema = `
+	CREATE TABLE IF NOT EXISTS %[1]s (
+		app_name VARCHAR(255) NOT NULL,
+		state JSONB NOT NULL DEFAULT '{}',
+		update_time TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (app_name)
+	);
+
+	CREATE TABLE IF NOT EXISTS %[2]s (
+		app_name VARCHAR(255) NOT NULL,
+		user_id VARCHAR(255) NOT NULL,
+		state JSONB NOT NULL DEFAULT '{}',
+		update_time TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (app_name, user_id)
+	);
+`
+
+// execer runs a statement, in a transaction or not.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// WithScopedState stores the state keys prefixed with session.KeyPrefixApp and
+// session.KeyPrefixUser in the app_states and user_states tables, shared by the
+// sessions of an app and of a user, rather than in every session row, and drops
+// the session.KeyPrefixTemp keys, as the ADK database session service does.
+// LoadSession merges them back, so the preferences of a user survive a restart
+// and reach the sessions restored from PostgreSQL.
+//
+// The scoped keys are written from the state delta of every persisted event; the
+// state of a persisted session only adds the scoped keys not stored yet, since it
+// may hold stale copies. Scoped states are not compressed by WithCodec.
+func WithScopedState() PersisterOption {
+	return func(p *SessionPersister) { p.scopedState = true }
+}
+
+// splitScopedState splits state into the session keys, and the app and user keys
+// without their prefix. Temporary keys are dropped.
+func splitScopedState(state map[string]any) (sessionState, appState, userState map[string]any) {
+	sessionState = make(map[string]any, len(state))
+	appState = make(map[string]any)
+	userState = make(map[string]any)
+
+	for k, v := range state {
+		if key, ok := strings.CutPrefix(k, session.KeyPrefixApp); ok {
+			appState[key] = v
+		} else if key, ok := strings.CutPrefix(k, session.KeyPrefixUser); ok {
+			userState[key] = v
+		} else if !strings.HasPrefix(k, session.KeyPrefixTemp) {
+			sessionState[k] = v
+		}
+	}
+
+	return sessionState, appState, userState
+}
+
+// upsertScopedState writes the app and user states of a session. With overwrite,
+// the given keys replace the stored ones (a state delta); otherwise only the keys
+// not stored yet are added (a session snapshot).
+func (p *SessionPersister) upsertScopedState(
+	ctx context.Context,
+	db execer,
+	appName, userID string,
+	appState, userState map[string]any,
+	overwrite bool,
+) error {
+	// NOTE: jsonb || keeps the keys of its right operand
+	merge := "EXCLUDED.state || t.state"
+	if overwrite {
+		merge = "t.state || EXCLUDED.state"
+	}
+
+	if len(appState) > 0 {
+		appJSON, err := sonic.Marshal(appState)
+		if err != nil {
+			return fmt.Errorf("failed to marshal app state: %w", err)
+		}
+
+		//nolint:gosec // table name is validated by the client
+		stmt := `
+			INSERT INTO ` + p.client.tableName("app_states") + ` AS t (app_name, state, update_time)
+			VALUES ($1, $2, NOW())
+			ON CONFLICT (app_name) DO UPDATE
+			SET state = ` + merge + `, update_time = NOW()
+		`
+		if _, err := db.ExecContext(ctx, stmt, appName, appJSON); err != nil {
+			return pgerr.Wrap("failed to persist app state", err)
+		}
+	}
+
+	if len(userState) > 0 {
+		userJSON, err := sonic.Marshal(userState)
+		if err != nil {
+			return fmt.Errorf("failed to marshal user state: %w", err)
+		}
+
+		//nolint:gosec // table name is validated by the client
+		stmt := `
+			INSERT INTO ` + p.client.tableName("user_states") + ` AS t (app_name, user_id, state, update_time)
+			VALUES ($1, $2, $3, NOW())
+			ON CONFLICT (app_name, user_id) DO UPDATE
+			SET state = ` + merge + `, update_time = NOW()
+		`
+		if _, err := db.ExecContext(ctx, stmt, appName, userID, userJSON); err != nil {
+			return pgerr.Wrap("failed to persist user state", err)
+		}
+	}
+
+	return nil
+}
+
+// loadScopedState adds the app and user states of a user to state, prefixed. They
+// take precedence over the copies stored in the session row before WithScopedState.
+func (p *SessionPersister) loadScopedState(ctx context.Context, appName, userID string, state map[string]any) error {
+	scopes := []struct {
+		prefix string
+		query  string
+		args   []any
+	}{
+		{
+			session.KeyPrefixApp,
+			`SELECT state FROM ` + p.client.tableName("app_states") + ` WHERE app_name = $1`,
+			[]any{appName},
+		},
+		{
+			session.KeyPrefixUser,
+			`SELECT state FROM ` + p.client.tableName("user_states") + ` WHERE app_name = $1 AND user_id = $2`,
+			[]any{appName, userID},
+		},
+	}
+
+	for _, scope := range scopes {
+		var stateJSON []byte
+		err := p.client.DB().QueryRowContext(ctx, scope.query, scope.args...).Scan(&stateJSON)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return pgerr.Wrap("failed to load "+strings.TrimSuffix(scope.prefix, ":")+" state", err)
+		}
+
+		var scoped map[string]any
+		if err := sonic.Unmarshal(stateJSON, &scoped); err != nil {
+			p.logger.Warnf("failed to unmarshal %sstate: app=%s, user=%s, err=%v", scope.prefix, appName, userID, err)
+			continue
+		}
+		for k, v := range scoped {
+			state[scope.prefix+k] = v
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSplitScopedState(t *testing.T) {
+	sessionState, appState, userState := splitScopedState(map[string]any{
+		"step":         2,
+		"app:theme":    "dark",
+		"user:lang":    "fr",
+		"temp:scratch": "x",
+	})
+
+	if len(sessionState) != 1 || sessionState["step"] != 2 {
+		t.Errorf("unexpected session state: %v", sessionState)
+	}
+	if len(appState) != 1 || appState["theme"] != "dark" {
+		t.Errorf("unexpected app state: %v", appState)
+	}
+	if len(userState) != 1 || userState["lang"] != "fr" {
+		t.Errorf("unexpected user state: %v", userState)
+	}
+}
+
+func TestScopedState(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := NewPostgresClient(ctx, &Config{
+		ConnStr:    getTestConnString(),
+		ShardCount: 4,
+	})
+	if err != nil {
+		t.Skipf("PostgreSQL not available, skipping test: %v", err)
+		return
+	}
+	defer client.Close()
+
+	// Use sync mode (buffer size 0) to read the sessions back right away
+	persister, err := NewSessionPersister(ctx, client, WithAsyncBufferSize(0), WithScopedState())
+	if err != nil {
+		t.Fatalf("Failed to create persister: %v", err)
+	}
+	defer persister.Close()
+
+	_, _ = client.DB().ExecContext(ctx, "DELETE FROM sessions WHERE app_name = 'test_scoped'")
+	_, _ = client.DB().ExecContext(ctx, "DELETE FROM app_states WHERE app_name = 'test_scoped'")
+	_, _ = client.DB().ExecContext(ctx, "DELETE FROM user_states WHERE app_name = 'test_scoped'")
+
+	sess1 := createTestSessionWithState("scoped-1", "test_scoped", "user-1", map[string]any{
+		"step": "intro", "app:theme": "dark", "user:lang": "fr", "temp:scratch": "x",
+	})
+	if err := persister.PersistSession(ctx, sess1); err != nil {
+		t.Fatalf("PersistSession failed: %v", err)
+	}
+
+	t.Run("scoped keys are not stored in the session row", func(t *testing.T) {
+		var stateJSON string
+		err := client.DB().QueryRowContext(ctx,
+			"SELECT state FROM sessions WHERE app_name = 'test_scoped' AND id = 'scoped-1'").Scan(&stateJSON)
+		if err != nil {
+			t.Fatalf("failed to read session row: %v", err)
+		}
+		if stateJSON != `{"step": "intro"}` {
+			t.Errorf("unexpected session row state: %s", stateJSON)
+		}
+	})
+
+	t.Run("event deltas update the scoped state", func(t *testing.T) {
+		evt := createTestEvent("scoped-evt-1", "agent")
+		evt.Actions.StateDelta = map[string]any{"user:lang": "de", "app:theme": "light"}
+		if err := persister.PersistEvent(ctx, sess1, evt); err != nil {
+			t.Fatalf("PersistEvent failed: %v", err)
+		}
+
+		// NOTE: A stale snapshot of another session does not revert them
+		sess2 := createTestSessionWithState("scoped-2", "test_scoped", "user-1", map[string]any{
+			"user:lang": "fr", "user:tz": "CET",
+		})
+		if err := persister.PersistSession(ctx, sess2); err != nil {
+			t.Fatalf("PersistSession failed: %v", err)
+		}
+
+		stored, err := persister.LoadSession(ctx, "test_scoped", "user-1", "scoped-2")
+		if err != nil || stored == nil {
+			t.Fatalf("LoadSession failed: %v", err)
+		}
+		want := map[string]any{"app:theme": "light", "user:lang": "de", "user:tz": "CET"}
+		for k, v := range want {
+			if stored.State[k] != v {
+				t.Errorf("expected %s=%v, got %v", k, v, stored.State[k])
+			}
+		}
+	})
+
+	t.Run("other users do not share user state", func(t *testing.T) {
+		sess := createTestSession("scoped-3", "test_scoped", "user-2")
+		if err := persister.PersistSession(ctx, sess); err != nil {
+			t.Fatalf("PersistSession failed: %v", err)
+		}
+
+		stored, err := persister.LoadSession(ctx, "test_scoped", "user-2", "scoped-3")
+		if err != nil || stored == nil {
+			t.Fatalf("LoadSession failed: %v", err)
+		}
+		if _, ok := stored.State["user:lang"]; ok {
+			t.Error("expected no user state for user-2")
+		}
+		if stored.State["app:theme"] != "light" {
+			t.Errorf("expected the app state, got %v", stored.State)
+		}
+	})
+}
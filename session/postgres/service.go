@@ -0,0 +1,394 @@
+package postgres
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/kydenul/k-adk/internal/pgerr"
+	ksess "github.com/kydenul/k-adk/session"
+	"github.com/kydenul/log"
+	"google.golang.org/adk/session"
+)
+
+var _ session.Service = (*SessionService)(nil)
+
+// sessionIDByteLength defines the length of the generated session IDs in bytes.
+const sessionIDByteLength = 16
+
+// SessionService implements session.Service directly on PostgreSQL, for small
+// deployments without Redis. It uses the sessions and sharded events tables of
+// SessionPersister, so the persister options (WithCodec, WithEncryption,
+// WithScopedState, WithMemoryOutbox) apply, and the sessions it stores can be
+// loaded, found by tags or ingested into memory as the persisted ones.
+//
+// Every event is written synchronously, in a transaction with the state of its
+// session after its state delta. The state written is the state of the session
+// passed to AppendEvent: concurrent writers of a session should each get it again
+// first, the last write wins.
+type SessionService struct {
+	store  *SessionPersister
+	logger log.Logger
+
+	// Optional. storeOpts configure the tables, see WithPersisterOptions.
+	storeOpts []PersisterOption
+	// Optional. idPolicy validates the client-supplied session IDs.
+	idPolicy *ksess.IDPolicy
+	// Optional. stampEvents replaces the caller-supplied event timestamps.
+	stampEvents bool
+}
+
+// ServiceOption configures the SessionService.
+type ServiceOption func(*SessionService)
+
+// WithPersisterOptions sets the options of the persister storing the sessions,
+// e.g. WithCodec or WithScopedState. WithAsyncBufferSize is ignored: the service
+// writes synchronously.
+func WithPersisterOptions(opts ...PersisterOption) ServiceOption {
+	return func(s *SessionService) { s.storeOpts = append(s.storeOpts, opts...) }
+}
+
+// WithIDPolicy validates the session IDs supplied to Create against p, or replaces
+// them with generated IDs if p.AlwaysGenerate is set. Invalid IDs fail with an
+// error matching ksess.ErrInvalidSessionID.
+func WithIDPolicy(p ksess.IDPolicy) ServiceOption {
+	return func(s *SessionService) { s.idPolicy = &p }
+}
+
+// WithAppendTimestamps stamps every appended event with the time of the append,
+// replacing the timestamp set by the caller. By default only the events without a
+// timestamp are stamped, so replayed or imported events keep their own.
+func WithAppendTimestamps() ServiceOption {
+	return func(s *SessionService) { s.stampEvents = true }
+}
+
+// NewSessionService creates a SessionService and its tables if needed.
+func NewSessionService(ctx context.Context, client *Client, opts ...ServiceOption) (*SessionService, error) {
+	svc := &SessionService{}
+	for _, opt := range opts {
+		opt(svc)
+	}
+
+	store, err := NewSessionPersister(ctx, client, append(svc.storeOpts, WithAsyncBufferSize(0))...)
+	if err != nil {
+		return nil, err
+	}
+	svc.store = store
+	svc.logger = store.logger
+
+	svc.logger.Info("PostgreSQL session service initialized")
+
+	return svc, nil
+}
+
+// Persister returns the persister storing the sessions, e.g. for FindSessions or
+// as the Loader of another service.
+func (s *SessionService) Persister() *SessionPersister { return s.store }
+
+// generateSessionID generates a unique session ID using crypto/rand.
+func generateSessionID() string {
+	b := make([]byte, sessionIDByteLength)
+
+	if _, err := rand.Read(b); err != nil {
+		// Fallback to timestamp if crypto/rand fails
+		return strconv.FormatInt(time.Now().UnixNano(), 10)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// resolveSessionID returns the ID of a new session: the requested ID, validated by
+// the ID policy if one is set, or a generated ID.
+func (s *SessionService) resolveSessionID(requested string) (string, error) {
+	if s.idPolicy != nil {
+		return s.idPolicy.Resolve(requested, generateSessionID)
+	}
+	if requested == "" {
+		return generateSessionID(), nil
+	}
+	return requested, nil
+}
+
+// Create creates a new session. Keys prefixed with session.KeyPrefixTemp are not
+// stored.
+func (s *SessionService) Create(
+	ctx context.Context,
+	req *session.CreateRequest,
+) (*session.CreateResponse, error) {
+	sessionID, err := s.resolveSessionID(req.SessionID)
+	if err != nil {
+		s.logger.Warnf("rejected session ID: app=%s, user=%s, err=%v", req.AppName, req.UserID, err)
+		return nil, err
+	}
+
+	s.logger.Debugf("creating session: app=%s, user=%s, session=%s",
+		req.AppName, req.UserID, sessionID)
+
+	state := make(map[string]any, len(req.State))
+	for key, value := range req.State {
+		if !strings.HasPrefix(key, session.KeyPrefixTemp) {
+			state[key] = value
+		}
+	}
+
+	sess := newPGSession(&ksess.StoredSession{
+		ID:             sessionID,
+		AppName:        req.AppName,
+		UserID:         req.UserID,
+		State:          state,
+		LastUpdateTime: time.Now(),
+	})
+	if err := s.insertSession(ctx, sess); err != nil {
+		return nil, err
+	}
+
+	// NOTE: The session sees the app and user keys of the other sessions
+	if s.store.scopedState {
+		if err := s.store.loadScopedState(ctx, req.AppName, req.UserID, sess.state.values); err != nil {
+			s.logger.Errorf("failed to load scoped state of session %s: %v", sessionID, err)
+			return nil, err
+		}
+	}
+
+	s.logger.Infof("session created: app=%s, user=%s, session=%s", req.AppName, req.UserID, sessionID)
+
+	return &session.CreateResponse{Session: sess}, nil
+}
+
+// insertSession stores a new session, with its app and user keys if scoped. It
+// fails with ErrSessionExists if the ID is in use.
+func (s *SessionService) insertSession(ctx context.Context, sess *pgSession) error {
+	stateJSON, appState, userState, err := s.store.encodeState(ctx, sess)
+	if err != nil {
+		s.logger.Errorf("failed to encode state of session %s: %v", sess.id, err)
+		return err
+	}
+
+	tx, err := s.store.client.DB().BeginTx(ctx, nil)
+	if err != nil {
+		return pgerr.Wrap("failed to begin transaction", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO `+s.store.client.SessionsTableName()+` (id, app_name, user_id, state, last_update_time, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (app_name, user_id, id) DO NOTHING
+	`, sess.id, sess.appName, sess.userID, stateJSON, sess.lastUpdateTime)
+	if err != nil {
+		s.logger.Errorf("failed to create session %s: %v", sess.id, err)
+		return pgerr.Wrap("failed to create session", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: %s", ErrSessionExists, sess.id)
+	}
+
+	// NOTE: The initial state of a session sets its app and user keys
+	if err := s.store.upsertScopedState(ctx, tx, sess.appName, sess.userID, appState, userState, true); err != nil {
+		s.logger.Errorf("failed to persist scoped state of session %s: %v", sess.id, err)
+		return err
+	}
+
+	if s.store.audit {
+		if err := s.store.auditSession(ctx, tx, sess, nil, false); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return pgerr.Wrap("failed to commit transaction", err)
+	}
+
+	return nil
+}
+
+// Get retrieves a session by ID, with its last NumRecentEvents events (0 gets
+// all) not older than After.
+func (s *SessionService) Get(
+	ctx context.Context,
+	req *session.GetRequest,
+) (*session.GetResponse, error) {
+	s.logger.Debugf("getting session: app=%s, user=%s, session=%s",
+		req.AppName, req.UserID, req.SessionID)
+
+	stored, err := s.store.loadSession(ctx, req.AppName, req.UserID, req.SessionID, req.NumRecentEvents, req.After)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		s.logger.Errorf("session not found: %s", req.SessionID)
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, req.SessionID)
+	}
+
+	s.logger.Infof("session retrieved: session=%s, events=%d", req.SessionID, len(stored.Events))
+
+	return &session.GetResponse{Session: newPGSession(stored)}, nil
+}
+
+// List returns the sessions of a user, or of every user of the app if UserID is
+// empty, most recently updated first and without their events.
+func (s *SessionService) List(
+	ctx context.Context,
+	req *session.ListRequest,
+) (*session.ListResponse, error) {
+	s.logger.Debugf("listing sessions: app=%s, user=%s", req.AppName, req.UserID)
+
+	sessionsTable := s.store.client.SessionsTableName()
+	//nolint:gosec // table name is validated by the client
+	query := `
+		SELECT user_id, id, state, tags, last_update_time FROM ` + sessionsTable + `
+		WHERE app_name = $1
+		ORDER BY last_update_time DESC
+	`
+	args := []any{req.AppName}
+	if req.UserID != "" {
+		//nolint:gosec // table name is validated by the client
+		query = `
+			SELECT user_id, id, state, tags, last_update_time FROM ` + sessionsTable + `
+			WHERE app_name = $1 AND user_id = $2
+			ORDER BY last_update_time DESC
+		`
+		args = append(args, req.UserID)
+	}
+
+	rows, err := s.store.client.stmts.QueryContext(ctx, query, args...)
+	if err != nil {
+		s.logger.Errorf("failed to list sessions of app %s: %v", req.AppName, err)
+		return nil, pgerr.Wrap("failed to list sessions", err)
+	}
+	defer rows.Close()
+
+	var listed []*pgSession
+	for rows.Next() {
+		var stateJSON, tagsJSON []byte
+		sess := &pgSession{
+			appName: req.AppName,
+			state:   &pgState{values: map[string]any{}},
+			events:  &pgEvents{},
+		}
+		if err := rows.Scan(&sess.userID, &sess.id, &stateJSON, &tagsJSON, &sess.lastUpdateTime); err != nil {
+			return nil, pgerr.Wrap("failed to scan session", err)
+		}
+
+		if err := unmarshalJSON(ctx, s.store.encryptor, req.AppName, stateJSON, &sess.state.values); err != nil {
+			s.logger.Warnf("failed to unmarshal session state: session=%s, err=%v", sess.id, err)
+		}
+		if err := sonic.Unmarshal(tagsJSON, &sess.tags); err != nil || len(sess.tags) == 0 {
+			sess.tags = nil
+		}
+		listed = append(listed, sess)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, pgerr.Wrap("failed to iterate sessions", err)
+	}
+	rows.Close()
+
+	// NOTE: The scoped keys are loaded once per user
+	if s.store.scopedState {
+		scoped := make(map[string]map[string]any)
+		for _, sess := range listed {
+			state, ok := scoped[sess.userID]
+			if !ok {
+				state = make(map[string]any)
+				if err := s.store.loadScopedState(ctx, req.AppName, sess.userID, state); err != nil {
+					return nil, err
+				}
+				scoped[sess.userID] = state
+			}
+			maps.Copy(sess.state.values, state)
+		}
+	}
+
+	sessions := make([]session.Session, 0, len(listed))
+	for _, sess := range listed {
+		sessions = append(sessions, sess)
+	}
+
+	s.logger.Infof("listed %d sessions for user %s", len(sessions), req.UserID)
+
+	return &session.ListResponse{Sessions: sessions}, nil
+}
+
+// Delete removes a session and its events.
+func (s *SessionService) Delete(ctx context.Context, req *session.DeleteRequest) error {
+	s.logger.Debugf("deleting session: app=%s, user=%s, session=%s",
+		req.AppName, req.UserID, req.SessionID)
+
+	if err := s.store.deleteSessionSync(ctx, req.AppName, req.UserID, req.SessionID); err != nil {
+		s.logger.Errorf("failed to delete session %s: %v", req.SessionID, err)
+		return err
+	}
+
+	s.logger.Infof("session deleted: app=%s, user=%s, session=%s",
+		req.AppName, req.UserID, req.SessionID)
+
+	return nil
+}
+
+// AppendEvent appends an event to a session, and applies its state delta; keys
+// prefixed with session.KeyPrefixTemp are not stored. Partial events are ignored.
+// Events without a timestamp are stamped with the current time (see
+// WithAppendTimestamps); the last update time of the session does not go back to
+// the timestamp of an older event.
+func (s *SessionService) AppendEvent(
+	ctx context.Context,
+	sess session.Session,
+	evt *session.Event,
+) error {
+	if sess == nil {
+		return ErrNilSession
+	}
+	if evt == nil {
+		return ErrNilEvent
+	}
+	if evt.Partial {
+		return nil
+	}
+
+	// NOTE: Keep the timestamp of events replayed or imported by the caller
+	if evt.Timestamp.IsZero() || s.stampEvents {
+		evt.Timestamp = time.Now()
+	}
+	if evt.ID == "" {
+		evt.ID = generateSessionID()
+	}
+
+	s.logger.Debugf("appending event to session %s: event_id=%s, author=%s",
+		sess.ID(), evt.ID, evt.Author)
+
+	// NOTE: Apply the state delta to the caller's session, then store its state
+	for key, value := range evt.Actions.StateDelta {
+		if strings.HasPrefix(key, session.KeyPrefixTemp) {
+			continue
+		}
+		if err := sess.State().Set(key, value); err != nil {
+			return fmt.Errorf("failed to apply state delta: %w", err)
+		}
+	}
+
+	err := s.store.persistEventSync(ctx, sess, evt, true)
+	if errors.Is(err, ErrSessionMissing) {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, sess.ID())
+	}
+	if err != nil {
+		return err
+	}
+
+	if ps, ok := sess.(*pgSession); ok {
+		ps.events.append(evt)
+		if evt.Timestamp.After(ps.lastUpdateTime) {
+			ps.lastUpdateTime = evt.Timestamp
+		}
+	}
+
+	s.logger.Infof("event appended: session=%s, event=%s", sess.ID(), evt.ID)
+
+	return nil
+}
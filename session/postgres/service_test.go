@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/adk/model"
+	"google.golang.org/adk/session"
+)
+
+func setupTestService(t *testing.T, opts ...ServiceOption) *SessionService {
+	t.Helper()
+
+	persister, client := setupTestDB(t)
+	if persister == nil {
+		return nil
+	}
+	t.Cleanup(func() {
+		persister.Close()
+		client.Close()
+	})
+
+	svc, err := NewSessionService(context.Background(), client, opts...)
+	if err != nil {
+		t.Fatalf("NewSessionService() error = %v", err)
+	}
+	return svc
+}
+
+func TestSessionService(t *testing.T) {
+	svc := setupTestService(t)
+	if svc == nil {
+		return
+	}
+	ctx := context.Background()
+
+	const appName, userID = "test_service_app", "test_service_user"
+
+	resp, err := svc.Create(ctx, &session.CreateRequest{
+		AppName: appName, UserID: userID, SessionID: "s1",
+		State: map[string]any{"topic": "billing", "temp:scratch": 1},
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	sess := resp.Session
+	if _, err := sess.State().Get("temp:scratch"); !errors.Is(err, session.ErrStateKeyNotExist) {
+		t.Errorf("temp key stored: %v", err)
+	}
+
+	_, err = svc.Create(ctx, &session.CreateRequest{AppName: appName, UserID: userID, SessionID: "s1"})
+	if !errors.Is(err, ErrSessionExists) {
+		t.Errorf("Create() error = %v, want ErrSessionExists", err)
+	}
+
+	start := time.Now().Add(-time.Minute)
+	for i, author := range []string{"user", "agent", "user"} {
+		evt := &session.Event{Author: author, Timestamp: start.Add(time.Duration(i) * time.Second)}
+		evt.Actions.StateDelta = map[string]any{"turns": i + 1, "temp:step": i}
+		if err := svc.AppendEvent(ctx, sess, evt); err != nil {
+			t.Fatalf("AppendEvent() error = %v", err)
+		}
+	}
+	if err := svc.AppendEvent(ctx, sess, &session.Event{Author: "agent", LLMResponse: model.LLMResponse{Partial: true}}); err != nil {
+		t.Fatalf("AppendEvent() error = %v for a partial event", err)
+	}
+
+	got, err := svc.Get(ctx, &session.GetRequest{AppName: appName, UserID: userID, SessionID: "s1"})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Session.Events().Len() != 3 {
+		t.Errorf("events = %d, want 3", got.Session.Events().Len())
+	}
+	if turns, _ := got.Session.State().Get("turns"); turns != float64(3) {
+		t.Errorf("turns = %v, want 3", turns)
+	}
+	if _, err := got.Session.State().Get("temp:step"); err == nil {
+		t.Error("temp key of a state delta stored")
+	}
+
+	recent, err := svc.Get(ctx, &session.GetRequest{AppName: appName, UserID: userID, SessionID: "s1", NumRecentEvents: 2})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if recent.Session.Events().Len() != 2 || recent.Session.Events().At(1).Author != "user" {
+		t.Errorf("recent events = %d, want the last 2", recent.Session.Events().Len())
+	}
+
+	if _, err := svc.Create(ctx, &session.CreateRequest{AppName: appName, UserID: "test_service_other"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	for user, want := range map[string]int{userID: 1, "": 2} {
+		list, err := svc.List(ctx, &session.ListRequest{AppName: appName, UserID: user})
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		if len(list.Sessions) != want {
+			t.Errorf("List(%q) = %d sessions, want %d", user, len(list.Sessions), want)
+		}
+	}
+
+	if err := svc.Delete(ctx, &session.DeleteRequest{AppName: appName, UserID: userID, SessionID: "s1"}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := svc.Get(ctx, &session.GetRequest{AppName: appName, UserID: userID, SessionID: "s1"}); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Get() error = %v after Delete, want ErrSessionNotFound", err)
+	}
+	if err := svc.AppendEvent(ctx, sess, &session.Event{Author: "user"}); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("AppendEvent() error = %v after Delete, want ErrSessionNotFound", err)
+	}
+}
@@ -0,0 +1,135 @@
+package postgres
+
+import (
+	"iter"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	ksess "github.com/kydenul/k-adk/session"
+	"google.golang.org/adk/session"
+)
+
+var (
+	_ session.Session = (*pgSession)(nil)
+	_ ksess.Tagged    = (*pgSession)(nil)
+)
+
+// pgSession implements the session.Session interface over a copy of a stored
+// session: changes reach PostgreSQL through SessionService.AppendEvent only.
+type pgSession struct {
+	id             string
+	appName        string
+	userID         string
+	state          *pgState
+	events         *pgEvents
+	tags           map[string]string
+	lastUpdateTime time.Time
+}
+
+// newPGSession returns the session of a stored one.
+func newPGSession(stored *ksess.StoredSession) *pgSession {
+	state := maps.Clone(stored.State)
+	if state == nil {
+		state = make(map[string]any)
+	}
+
+	return &pgSession{
+		id:             stored.ID,
+		appName:        stored.AppName,
+		userID:         stored.UserID,
+		state:          &pgState{values: state},
+		events:         &pgEvents{events: slices.Clone(stored.Events)},
+		tags:           stored.Tags,
+		lastUpdateTime: stored.LastUpdateTime,
+	}
+}
+
+func (s *pgSession) ID() string                { return s.id }
+func (s *pgSession) AppName() string           { return s.appName }
+func (s *pgSession) UserID() string            { return s.userID }
+func (s *pgSession) State() session.State      { return s.state }
+func (s *pgSession) Events() session.Events    { return s.events }
+func (s *pgSession) LastUpdateTime() time.Time { return s.lastUpdateTime }
+
+// Tags implements ksess.Tagged.
+func (s *pgSession) Tags() map[string]string { return maps.Clone(s.tags) }
+
+var _ session.State = (*pgState)(nil)
+
+// pgState implements session.State with a map. It is thread-safe.
+type pgState struct {
+	mu     sync.RWMutex
+	values map[string]any
+}
+
+func (s *pgState) Get(key string) (any, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, ok := s.values[key]
+	if !ok {
+		return nil, session.ErrStateKeyNotExist
+	}
+	return value, nil
+}
+
+func (s *pgState) Set(key string, value any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.values == nil {
+		s.values = make(map[string]any)
+	}
+	s.values[key] = value
+	return nil
+}
+
+func (s *pgState) All() iter.Seq2[string, any] {
+	s.mu.RLock()
+	values := maps.Clone(s.values)
+	s.mu.RUnlock()
+
+	return maps.All(values)
+}
+
+var _ session.Events = (*pgEvents)(nil)
+
+// pgEvents implements session.Events with a slice. It is thread-safe.
+type pgEvents struct {
+	mu     sync.RWMutex
+	events []*session.Event
+}
+
+func (e *pgEvents) All() iter.Seq[*session.Event] {
+	e.mu.RLock()
+	events := slices.Clone(e.events)
+	e.mu.RUnlock()
+
+	return slices.Values(events)
+}
+
+func (e *pgEvents) Len() int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return len(e.events)
+}
+
+func (e *pgEvents) At(i int) *session.Event {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if i < 0 || i >= len(e.events) {
+		return nil
+	}
+	return e.events[i]
+}
+
+func (e *pgEvents) append(evt *session.Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.events = append(e.events, evt)
+}
@@ -0,0 +1,165 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// CloseReport reports the async operations Close drained.
+type CloseReport struct {
+	// Flushed counts the operations written while closing.
+	Flushed int64
+
+	// Failed counts the operations failing on their last attempt while closing,
+	// handed to the dead letter sink.
+	Failed int64
+
+	// Abandoned counts the operations still queued, or being retried, at the
+	// deadline of CloseWithContext.
+	Abandoned int64
+
+	// AbandonedOps are the dead letters of the abandoned operations, to be stored
+	// or replayed with ReplayDeadLetter. They are not written to the dead letter
+	// sink: past the deadline, its writes would hold Close up.
+	AbandonedOps []*DeadLetter
+}
+
+// opCounts counts the outcomes of the async operations.
+type opCounts struct {
+	flushed   atomic.Int64
+	failed    atomic.Int64
+	abandoned atomic.Int64
+
+	// abandonedOps holds the dead letters of the abandoned operations.
+	abandonedMu  sync.Mutex
+	abandonedOps []*DeadLetter
+}
+
+func (c *opCounts) report() CloseReport {
+	return CloseReport{
+		Flushed:   c.flushed.Load(),
+		Failed:    c.failed.Load(),
+		Abandoned: c.abandoned.Load(),
+	}
+}
+
+// abandon records the dead letter of an abandoned operation.
+func (c *opCounts) abandon(dl *DeadLetter) {
+	c.abandonedMu.Lock()
+	defer c.abandonedMu.Unlock()
+
+	c.abandoned.Add(1)
+	c.abandonedOps = append(c.abandonedOps, dl)
+}
+
+// takeAbandoned returns the dead letters of the abandoned operations, and forgets
+// them.
+func (c *opCounts) takeAbandoned() []*DeadLetter {
+	c.abandonedMu.Lock()
+	defer c.abandonedMu.Unlock()
+
+	ops := c.abandonedOps
+	c.abandonedOps = nil
+	return ops
+}
+
+// CloseWithContext closes the persister, as Close does, but stops draining the
+// async queues when ctx is done: the operation being written is canceled, and
+// it and the queued ones are abandoned, with ErrOperationAbandoned. The abandoned
+// operations are returned as dead letters in CloseReport.AbandonedOps, to be
+// stored or replayed with ReplayDeadLetter; they are not written to the dead letter
+// sink of WithDeadLetter, so Close returns soon after the deadline. The calls
+// waiting for room in a queue, under BackpressureBlock, fail with
+// ErrPersisterClosed.
+//
+// It returns the operations flushed, failed and abandoned while closing, and
+// ErrOperationAbandoned if any was abandoned.
+func (p *SessionPersister) CloseWithContext(ctx context.Context) (CloseReport, error) {
+	// NOTE: Senders blocked on a full queue hold the read lock, wake them first
+	p.closingOnce.Do(func() {
+		if p.closing != nil {
+			close(p.closing)
+		}
+	})
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return CloseReport{}, nil
+	}
+	p.closed = true
+
+	if p.stopPruning != nil {
+		p.stopPruning()
+	}
+
+	// NOTE: No operation is being queued while the lock is held
+	start := p.ops.report()
+	for _, ch := range p.asyncChans {
+		close(ch)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait() // Wait for all async operations to complete
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		if p.abortDrain != nil {
+			p.abortDrain(fmt.Errorf("%w: %w", ErrOperationAbandoned, context.Cause(ctx)))
+		}
+		<-done // Wait for the workers to abandon their operations
+	}
+	if p.abortDrain != nil {
+		p.abortDrain(nil) // Release the context once drained
+	}
+
+	end := p.ops.report()
+	report := CloseReport{
+		Flushed:   end.Flushed - start.Flushed,
+		Failed:    end.Failed - start.Failed,
+		Abandoned: end.Abandoned - start.Abandoned,
+	}
+	report.AbandonedOps = p.ops.takeAbandoned()
+
+	p.logger.Infof("PostgreSQL session persister closed: flushed=%d, failed=%d, abandoned=%d",
+		report.Flushed, report.Failed, report.Abandoned)
+
+	if report.Abandoned > 0 {
+		return report, fmt.Errorf("failed to flush %d async operations: %w", report.Abandoned, ErrOperationAbandoned)
+	}
+	return report, nil
+}
+
+// drainContext returns the parent context of the async operations, canceled at
+// the deadline of CloseWithContext.
+func (p *SessionPersister) drainContext() context.Context {
+	if p.drainCtx == nil {
+		return context.Background()
+	}
+	return p.drainCtx
+}
+
+// drainAborted reports whether CloseWithContext stopped draining the queues.
+func (p *SessionPersister) drainAborted() bool {
+	return p.drainCtx != nil && p.drainCtx.Err() != nil
+}
+
+// abandonAsyncOp records the dead letter of an operation CloseWithContext gave up
+// on, after attempts attempts, for its report.
+func (p *SessionPersister) abandonAsyncOp(op asyncOperation, attempts int) {
+	err := context.Cause(p.drainCtx)
+	_, _, sessionID := op.key()
+	p.logger.Errorf("async %s operation of session %s abandoned: %v", op.operationType, sessionID, err)
+
+	dl := newDeadLetter(op, attempts, err)
+	// NOTE: The dead letter holds the transformed event, as the sink stores it
+	dl.Event, _ = p.storedEvent(dl.Event)
+	p.ops.abandon(dl)
+}
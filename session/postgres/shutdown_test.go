@@ -0,0 +1,136 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+)
+
+func TestCloseWithContextAbandons(t *testing.T) {
+	sess := createTestSession("sess-close", "test_app", "user-close")
+	queue := make(chan asyncOperation, 2)
+	queue <- asyncOperation{operationType: operationSession, sess: sess}
+	queue <- asyncOperation{operationType: operationEvent, sess: sess, evt: createTestEvent("evt-close", "user")}
+
+	var sent int
+	p := &SessionPersister{
+		logger:     discardlog.NewDiscardLog(),
+		asyncChans: []chan asyncOperation{queue},
+		deadLetter: DeadLetterFunc(func(context.Context, *DeadLetter) error {
+			sent++
+			return nil
+		}),
+	}
+	p.drainCtx, p.abortDrain = context.WithCancelCause(context.Background())
+
+	// NOTE: The worker is busy until the deadline
+	p.wg.Add(1)
+	go func() {
+		<-p.drainCtx.Done()
+		p.asyncWorker(queue)
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report, err := p.CloseWithContext(ctx)
+	if !errors.Is(err, ErrOperationAbandoned) {
+		t.Errorf("CloseWithContext() error = %v, want ErrOperationAbandoned", err)
+	}
+	if report.Abandoned != 2 || report.Flushed != 0 || report.Failed != 0 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if sent != 0 {
+		t.Errorf("got %d dead letters written to the sink, want none", sent)
+	}
+	if len(report.AbandonedOps) != 2 {
+		t.Fatalf("got %d abandoned operations, want 2", len(report.AbandonedOps))
+	}
+	for _, dl := range report.AbandonedOps {
+		if dl.Attempts != 0 || !strings.Contains(dl.Error, ErrOperationAbandoned.Error()) {
+			t.Errorf("unexpected dead letter: %+v", dl)
+		}
+	}
+
+	if report, err := p.CloseWithContext(ctx); err != nil || report.Abandoned != 0 || report.AbandonedOps != nil {
+		t.Errorf("second CloseWithContext() = %+v, %v", report, err)
+	}
+}
+
+func TestCloseWithContextFlushes(t *testing.T) {
+	persister, client := setupTestDB(t)
+	if persister == nil {
+		return
+	}
+	defer persister.Close()
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sess := createTestSession("sess-close", "test_app", "user-close")
+	if err := persister.PersistSession(ctx, sess); err != nil {
+		t.Fatalf("PersistSession failed: %v", err)
+	}
+	for _, id := range []string{"evt-close-1", "evt-close-2", "evt-close-3"} {
+		if err := persister.PersistEvent(ctx, sess, createTestEvent(id, "user")); err != nil {
+			t.Fatalf("PersistEvent failed: %v", err)
+		}
+	}
+
+	report, err := persister.CloseWithContext(ctx)
+	if err != nil {
+		t.Fatalf("CloseWithContext failed: %v", err)
+	}
+	if report.Abandoned != 0 || report.Flushed+report.Failed > 4 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
+
+func TestCloseWithContextWakesBlockedSenders(t *testing.T) {
+	sess := createTestSession("sess-blocked", "test_app", "user-blocked")
+	queue := make(chan asyncOperation, 1)
+	queue <- asyncOperation{operationType: operationSession, sess: sess}
+
+	p := &SessionPersister{
+		logger:       discardlog.NewDiscardLog(),
+		asyncChans:   []chan asyncOperation{queue},
+		backpressure: BackpressureBlock,
+		closing:      make(chan struct{}),
+	}
+	p.drainCtx, p.abortDrain = context.WithCancelCause(context.Background())
+
+	// NOTE: The worker is busy until the deadline, so the queue stays full
+	p.wg.Add(1)
+	go func() {
+		<-p.drainCtx.Done()
+		p.asyncWorker(queue)
+	}()
+
+	sent := make(chan error, 1)
+	go func() {
+		_, err := p.enqueue(context.Background(), asyncOperation{operationType: operationSession, sess: sess})
+		sent <- err
+	}()
+	time.Sleep(20 * time.Millisecond) // Let the sender block on the full queue
+
+	closed := make(chan struct{})
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, _ = p.CloseWithContext(ctx)
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("CloseWithContext blocked past its deadline on a blocked sender")
+	}
+	if err := <-sent; !errors.Is(err, ErrPersisterClosed) {
+		t.Errorf("enqueue() error = %v, want ErrPersisterClosed", err)
+	}
+}
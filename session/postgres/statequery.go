@@ -0,0 +1,147 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kydenul/k-adk/internal/pgerr"
+	ksess "github.com/kydenul/k-adk/session"
+)
+
+// StateIndex is the operator class of the GIN index on the session states, see
+// WithStateIndex.
+type StateIndex string
+
+const (
+	// StateIndexPathOps indexes the states with jsonb_path_ops: a smaller index
+	// serving the containment (@>) and JSON path (@@, @?) operators of
+	// QuerySessionsByState.
+	StateIndexPathOps StateIndex = "jsonb_path_ops"
+
+	// StateIndexOps indexes the states with jsonb_ops, the default operator class:
+	// a larger index also serving the key existence operators (?, ?|, ?&) of ad hoc
+	// queries.
+	StateIndexOps StateIndex = "jsonb_ops"
+)
+
+// ErrEmptyStateFilter is returned by QuerySessionsByState without a filter.
+var ErrEmptyStateFilter = errors.New("state filter cannot be empty")
+
+const defaultStateQueryLimit = 100
+
+// WithStateIndex creates a GIN index on the state column of the sessions table
+// with the operator class idx, so QuerySessionsByState does not read every
+// session of the app. The index grows with the states and slows down their
+// writes a little. An index created with another operator class is kept.
+//
+// Distributed SQL engines only index JSONB with its default operator class:
+// StateIndexPathOps falls back to it with Config.DistributedSQL, under the name
+// of the StateIndexOps index, so switching to StateIndexOps later keeps it.
+func WithStateIndex(idx StateIndex) PersisterOption {
+	return func(p *SessionPersister) { p.stateIndex = idx }
+}
+
+// stateIndexSchema returns the statement creating the GIN index on the session
+// states, empty without WithStateIndex.
+func (p *SessionPersister) stateIndexSchema() (string, error) {
+	var name, opClass string
+	switch p.stateIndex {
+	case "":
+		return "", nil
+	case StateIndexPathOps:
+		name, opClass = "state_path", string(StateIndexPathOps)
+		// NOTE: The index of the default operator class has one name
+		if p.client.DistributedSQL() {
+			name, opClass = "state", ""
+		}
+	case StateIndexOps:
+		name = "state"
+	default:
+		return "", fmt.Errorf("invalid state index %q", p.stateIndex)
+	}
+
+	return fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %[2]s_%[3]s ON %[1]s USING GIN (state %[4]s);`,
+		p.client.SessionsTableName(), indexPrefix(p.client.sessionsTable), name, opClass), nil
+}
+
+// QuerySessionsByState returns up to limit sessions of appName whose state matches
+// jsonPathFilter, a SQL/JSON path predicate on the state, most recently updated
+// first, starting after the cursor (nil for the first page): the last session of
+// the previous page. A limit <= 0 returns 100 sessions. E.g. every premium session:
+//
+//	var after *ksess.SessionRef
+//	for {
+//		refs, err := client.QuerySessionsByState(ctx, "shop", `$.plan == "premium"`, after, 500)
+//		if err != nil || len(refs) == 0 {
+//			break
+//		}
+//		// ...
+//		after = &refs[len(refs)-1]
+//	}
+//
+// Predicates combine with && and ||, and reach nested fields and arrays, e.g.
+// `$.cart.total > 100 && $.country == "FR"` or `exists($.items[*] ? (@.sku == "A1"))`.
+// A state without the queried fields does not match. The query uses the index of
+// WithStateIndex if the persister created it, and reads every session of the app
+// otherwise.
+//
+// Only the session state keys are stored in the state column: the app and user
+// keys of WithScopedState are not matched, nor the states stored compressed or
+// encrypted by WithCodec and WithEncryption. JSON path needs PostgreSQL 12 or
+// later. An invalid filter is reported by PostgreSQL.
+func (c *Client) QuerySessionsByState(
+	ctx context.Context,
+	appName, jsonPathFilter string,
+	after *ksess.SessionRef,
+	limit int,
+) ([]ksess.SessionRef, error) {
+	if strings.TrimSpace(jsonPathFilter) == "" {
+		return nil, ErrEmptyStateFilter
+	}
+	if limit <= 0 {
+		limit = defaultStateQueryLimit
+	}
+
+	//nolint:gosec // table name is validated by the client
+	query := `
+		SELECT user_id, id, last_update_time FROM ` + c.SessionsTableName() + `
+		WHERE app_name = $1 AND state @@ $2::jsonpath
+		ORDER BY last_update_time DESC, user_id DESC, id DESC
+		LIMIT $3
+	`
+	args := []any{appName, jsonPathFilter, limit}
+	if after != nil {
+		//nolint:gosec // table name is validated by the client
+		query = `
+			SELECT user_id, id, last_update_time FROM ` + c.SessionsTableName() + `
+			WHERE app_name = $1 AND state @@ $2::jsonpath
+				AND (last_update_time, user_id, id) < ($4, $5, $6)
+			ORDER BY last_update_time DESC, user_id DESC, id DESC
+			LIMIT $3
+		`
+		args = append(args, after.LastUpdateTime, after.UserID, after.ID)
+	}
+
+	rows, err := c.stmts.QueryContext(ctx, query, args...)
+	if err != nil {
+		c.logger.Errorf("failed to query sessions of app %s by state: %v", appName, err)
+		return nil, pgerr.Wrap("failed to query sessions by state", err)
+	}
+	defer rows.Close()
+
+	var refs []ksess.SessionRef
+	for rows.Next() {
+		ref := ksess.SessionRef{AppName: appName}
+		if err := rows.Scan(&ref.UserID, &ref.ID, &ref.LastUpdateTime); err != nil {
+			return nil, pgerr.Wrap("failed to scan session", err)
+		}
+		refs = append(refs, ref)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, pgerr.Wrap("failed to iterate queried sessions", err)
+	}
+
+	return refs, nil
+}
@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	ksess "github.com/kydenul/k-adk/session"
+)
+
+func TestStateIndexSchema(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		idx        StateIndex
+		distribute bool
+		want       string
+	}{
+		{"none", "", false, ""},
+		{"path ops", StateIndexPathOps, false, "sessions_state_path ON sessions USING GIN (state jsonb_path_ops)"},
+		{"path ops distributed", StateIndexPathOps, true, "sessions_state ON sessions USING GIN (state )"},
+		{"default ops", StateIndexOps, false, "sessions_state ON sessions USING GIN (state )"},
+		{"default ops distributed", StateIndexOps, true, "sessions_state ON sessions USING GIN (state )"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &SessionPersister{
+				client:     &Client{sessionsTable: "sessions", distributedSQL: tc.distribute},
+				stateIndex: tc.idx,
+			}
+			schema, err := p.stateIndexSchema()
+			if err != nil {
+				t.Fatalf("stateIndexSchema failed: %v", err)
+			}
+			if !strings.Contains(schema, tc.want) || (tc.want == "") != (schema == "") {
+				t.Errorf("expected %q, got %q", tc.want, schema)
+			}
+		})
+	}
+
+	p := &SessionPersister{client: &Client{sessionsTable: "sessions"}, stateIndex: "btree"}
+	if _, err := p.stateIndexSchema(); err == nil {
+		t.Error("expected an error for an invalid state index")
+	}
+}
+
+func TestQuerySessionsByState(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := NewPostgresClient(ctx, &Config{
+		ConnStr:    getTestConnString(),
+		ShardCount: 4,
+	})
+	if err != nil {
+		t.Skipf("PostgreSQL not available, skipping test: %v", err)
+		return
+	}
+	defer client.Close()
+
+	persister, err := NewSessionPersister(ctx, client,
+		WithAsyncBufferSize(0), WithStateIndex(StateIndexPathOps))
+	if err != nil {
+		t.Fatalf("Failed to create persister: %v", err)
+	}
+	defer persister.Close()
+
+	_, _ = client.DB().ExecContext(ctx, "DELETE FROM sessions WHERE app_name = 'test_state_query'")
+
+	for id, state := range map[string]map[string]any{
+		"state-1": {"plan": "premium", "cart": map[string]any{"total": 150}},
+		"state-2": {"plan": "premium", "cart": map[string]any{"total": 20}},
+		"state-3": {"plan": "free"},
+	} {
+		if err := persister.PersistSession(ctx, createTestSessionWithState(id, "test_state_query", "user-1", state)); err != nil {
+			t.Fatalf("PersistSession failed: %v", err)
+		}
+	}
+
+	refs, err := client.QuerySessionsByState(ctx, "test_state_query", `$.plan == "premium"`, nil, 0)
+	if err != nil {
+		t.Fatalf("QuerySessionsByState failed: %v", err)
+	}
+	if len(refs) != 2 {
+		t.Errorf("expected 2 premium sessions, got %v", refs)
+	}
+
+	// NOTE: Page through the premium sessions one at a time
+	var paged []string
+	var after *ksess.SessionRef
+	for range 3 {
+		page, err := client.QuerySessionsByState(ctx, "test_state_query", `$.plan == "premium"`, after, 1)
+		if err != nil {
+			t.Fatalf("QuerySessionsByState failed: %v", err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		paged = append(paged, page[0].ID)
+		after = &page[0]
+	}
+	if len(paged) != 2 || paged[0] != refs[0].ID || paged[1] != refs[1].ID {
+		t.Errorf("expected the pages %v, got %v", refs, paged)
+	}
+
+	refs, err = client.QuerySessionsByState(ctx, "test_state_query", `$.plan == "premium" && $.cart.total > 100`, nil, 0)
+	if err != nil {
+		t.Fatalf("QuerySessionsByState failed: %v", err)
+	}
+	if len(refs) != 1 || refs[0].ID != "state-1" || refs[0].UserID != "user-1" {
+		t.Errorf("expected state-1, got %v", refs)
+	}
+
+	if _, err := client.QuerySessionsByState(ctx, "test_state_query", " ", nil, 0); !errors.Is(err, ErrEmptyStateFilter) {
+		t.Errorf("expected ErrEmptyStateFilter, got %v", err)
+	}
+	if _, err := client.QuerySessionsByState(ctx, "test_state_query", `$.plan ==`, nil, 0); err == nil {
+		t.Error("expected an error for an invalid filter")
+	}
+}
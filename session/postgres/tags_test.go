@@ -0,0 +1,127 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// taggedSession is a mockSession carrying tags.
+type taggedSession struct {
+	*mockSession
+	tags map[string]string
+}
+
+func (s *taggedSession) Tags() map[string]string { return s.tags }
+
+// branchSession is a mockSession forked from another, with a TTL of its own.
+type branchSession struct {
+	*mockSession
+	parentID, forkEventID string
+	ttl                   time.Duration
+}
+
+func (s *branchSession) ParentID() string    { return s.parentID }
+func (s *branchSession) ForkEventID() string { return s.forkEventID }
+func (s *branchSession) TTL() time.Duration  { return s.ttl }
+
+func TestSessionTags(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := NewPostgresClient(ctx, &Config{
+		ConnStr:    getTestConnString(),
+		ShardCount: 4,
+	})
+	if err != nil {
+		t.Skipf("PostgreSQL not available, skipping test: %v", err)
+		return
+	}
+	defer client.Close()
+
+	// Use sync mode (buffer size 0) to read the sessions back right away
+	persister, err := NewSessionPersister(ctx, client, WithAsyncBufferSize(0))
+	if err != nil {
+		t.Fatalf("Failed to create persister: %v", err)
+	}
+	defer persister.Close()
+
+	_, _ = client.DB().ExecContext(ctx, "DELETE FROM sessions WHERE app_name = 'test_tags'")
+
+	for id, tags := range map[string]map[string]string{
+		"tagged-1": {"topic": "billing", "channel": "web"},
+		"tagged-2": {"topic": "billing", "channel": "email"},
+		"tagged-3": {"topic": "support"},
+	} {
+		sess := &taggedSession{createTestSession(id, "test_tags", "user-1"), tags}
+		if err := persister.PersistSession(ctx, sess); err != nil {
+			t.Fatalf("PersistSession failed: %v", err)
+		}
+	}
+
+	t.Run("find by tags", func(t *testing.T) {
+		refs, err := persister.FindSessions(ctx, "test_tags", "user-1", map[string]string{"topic": "billing"})
+		if err != nil {
+			t.Fatalf("FindSessions failed: %v", err)
+		}
+		if len(refs) != 2 {
+			t.Errorf("expected 2 sessions, got %v", refs)
+		}
+
+		refs, err = persister.FindSessions(ctx, "test_tags", "", map[string]string{"topic": "billing", "channel": "web"})
+		if err != nil {
+			t.Fatalf("FindSessions failed: %v", err)
+		}
+		if len(refs) != 1 || refs[0].ID != "tagged-1" || refs[0].UserID != "user-1" {
+			t.Errorf("expected tagged-1, got %v", refs)
+		}
+	})
+
+	t.Run("untagged session keeps tags", func(t *testing.T) {
+		if err := persister.PersistSession(ctx, createTestSession("tagged-3", "test_tags", "user-1")); err != nil {
+			t.Fatalf("PersistSession failed: %v", err)
+		}
+
+		stored, err := persister.LoadSession(ctx, "test_tags", "user-1", "tagged-3")
+		if err != nil {
+			t.Fatalf("LoadSession failed: %v", err)
+		}
+		if stored.Tags["topic"] != "support" {
+			t.Errorf("expected the tags to be kept, got %v", stored.Tags)
+		}
+	})
+
+	t.Run("branch link and TTL", func(t *testing.T) {
+		sess := &branchSession{createTestSession("branch-1", "test_tags", "user-1"), "tagged-1", "evt-1", 2 * time.Hour}
+		if err := persister.PersistSession(ctx, sess); err != nil {
+			t.Fatalf("PersistSession failed: %v", err)
+		}
+		// NOTE: A session not carrying them keeps them
+		if err := persister.PersistSession(ctx, createTestSession("branch-1", "test_tags", "user-1")); err != nil {
+			t.Fatalf("PersistSession failed: %v", err)
+		}
+
+		stored, err := persister.LoadSession(ctx, "test_tags", "user-1", "branch-1")
+		if err != nil {
+			t.Fatalf("LoadSession failed: %v", err)
+		}
+		if stored.ParentID != "tagged-1" || stored.ForkEventID != "evt-1" || stored.TTL != 2*time.Hour {
+			t.Errorf("expected the branch link and TTL, got %q, %q, %v", stored.ParentID, stored.ForkEventID, stored.TTL)
+		}
+	})
+
+	t.Run("cleared tags", func(t *testing.T) {
+		sess := &taggedSession{createTestSession("tagged-3", "test_tags", "user-1"), nil}
+		if err := persister.PersistSession(ctx, sess); err != nil {
+			t.Fatalf("PersistSession failed: %v", err)
+		}
+
+		stored, err := persister.LoadSession(ctx, "test_tags", "user-1", "tagged-3")
+		if err != nil {
+			t.Fatalf("LoadSession failed: %v", err)
+		}
+		if stored.Tags != nil {
+			t.Errorf("expected no tags, got %v", stored.Tags)
+		}
+	})
+}
@@ -0,0 +1,36 @@
+package postgres
+
+import (
+	"google.golang.org/adk/session"
+)
+
+// EventTransformer returns the event to store for evt, e.g. a copy with its PII
+// masked or its inline binary parts removed, or nil to drop it.
+type EventTransformer func(evt *session.Event) *session.Event
+
+// WithEventTransformer stores the events returned by fn instead of the persisted
+// ones, so applications strip or mask their content before it reaches the
+// database. fn runs before the compression and encryption of the event, in the
+// async worker in async mode. The dead letters of the events hold them
+// transformed too, and are transformed again when replayed, so fn should be
+// idempotent.
+//
+// The caller, e.g. the Redis session service, still holds evt: fn must copy the
+// parts it changes rather than modify them in place, and keep the ID and the
+// timestamp of the event. Return evt itself to store it unchanged. An event for
+// which fn returns nil is dropped: it is not stored, and its persistence
+// succeeds. The session state of a SessionService append is stored still.
+func WithEventTransformer(fn EventTransformer) PersisterOption {
+	return func(p *SessionPersister) { p.transformEvent = fn }
+}
+
+// storedEvent returns the event to store for evt, transformed by the
+// WithEventTransformer function. It reports false if the function dropped evt.
+func (p *SessionPersister) storedEvent(evt *session.Event) (*session.Event, bool) {
+	if p.transformEvent == nil || evt == nil {
+		return evt, true
+	}
+
+	stored := p.transformEvent(evt)
+	return stored, stored != nil
+}
@@ -0,0 +1,116 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"google.golang.org/adk/session"
+	"google.golang.org/genai"
+)
+
+// maskSecrets returns a copy of evt with the text parts mentioning a secret masked.
+func maskSecrets(evt *session.Event) *session.Event {
+	masked := *evt
+	if evt.Content == nil {
+		return &masked
+	}
+
+	content := *evt.Content
+	content.Parts = make([]*genai.Part, len(evt.Content.Parts))
+	for i, part := range evt.Content.Parts {
+		if strings.Contains(part.Text, "sk-") {
+			part = genai.NewPartFromText("[REDACTED]")
+		}
+		content.Parts[i] = part
+	}
+	masked.Content = &content
+	return &masked
+}
+
+func TestStoredEvent(t *testing.T) {
+	evt := createTestEvent("evt-secret", "user")
+	evt.Content = genai.NewContentFromText("my key is sk-123", genai.RoleUser)
+
+	var p SessionPersister
+	if stored, ok := p.storedEvent(evt); !ok || stored != evt {
+		t.Fatalf("expected the event as is without a transformer, got %v, %t", stored, ok)
+	}
+
+	WithEventTransformer(maskSecrets)(&p)
+	stored, ok := p.storedEvent(evt)
+	if !ok {
+		t.Fatal("storedEvent dropped the event")
+	}
+	if got := stored.Content.Parts[0].Text; got != "[REDACTED]" {
+		t.Errorf("stored text = %q, want [REDACTED]", got)
+	}
+	if evt.Content.Parts[0].Text != "my key is sk-123" {
+		t.Error("the persisted event was modified")
+	}
+
+	WithEventTransformer(func(*session.Event) *session.Event { return nil })(&p)
+	if _, ok := p.storedEvent(evt); ok {
+		t.Error("expected a nil transformed event to be dropped")
+	}
+}
+
+func TestDeadLetterTransformedEvent(t *testing.T) {
+	evt := createTestEvent("evt-dead", "user")
+	evt.Content = genai.NewContentFromText("sk-456", genai.RoleUser)
+
+	var dead *DeadLetter
+	p := &SessionPersister{
+		deadLetter: DeadLetterFunc(func(_ context.Context, dl *DeadLetter) error {
+			dead = dl
+			return nil
+		}),
+		transformEvent: maskSecrets,
+	}
+	sess := createTestSession("sess-dead", "test_app", "user-dead")
+	p.sendDeadLetter(asyncOperation{operationType: operationEvent, sess: sess, evt: evt}, 1, context.Canceled)
+
+	if dead == nil || dead.Event == nil || dead.Event.Content.Parts[0].Text != "[REDACTED]" {
+		t.Fatalf("expected a dead letter with the transformed event, got %+v", dead)
+	}
+}
+
+func TestDroppedEvent(t *testing.T) {
+	persister, client := setupTestDB(t)
+	if persister == nil {
+		return
+	}
+	defer persister.Close()
+	defer client.Close()
+
+	ctx := context.Background()
+	dropper, err := NewSessionPersister(ctx, client, WithAsyncBufferSize(0),
+		WithEventTransformer(func(evt *session.Event) *session.Event {
+			if evt.Author == "tool" {
+				return nil
+			}
+			return evt
+		}))
+	if err != nil {
+		t.Fatalf("Failed to create persister: %v", err)
+	}
+	defer dropper.Close()
+
+	sess := createTestSession("sess-drop", "test_app", "user-drop")
+	if err := dropper.PersistSession(ctx, sess); err != nil {
+		t.Fatalf("PersistSession failed: %v", err)
+	}
+	for _, evt := range []*session.Event{createTestEvent("evt-kept", "user"), createTestEvent("evt-dropped", "tool")} {
+		if err := dropper.PersistEvent(ctx, sess, evt); err != nil {
+			t.Fatalf("PersistEvent(%s) failed: %v", evt.ID, err)
+		}
+	}
+
+	stored, err := dropper.LoadSession(ctx, "test_app", "user-drop", "sess-drop")
+	if err != nil || stored == nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+	if len(stored.Events) != 1 || stored.Events[0].ID != "evt-kept" {
+		t.Errorf("expected only evt-kept to be stored, got %d events", len(stored.Events))
+	}
+}
@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"google.golang.org/adk/session"
+)
+
+// txQueryer runs the queries of a transaction: a *sql.Tx, or a transaction bound
+// to the statement cache.
+type txQueryer interface {
+	execer
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// PersistSessionTx saves or updates a session within tx, a transaction the caller
+// began on the database of the persister, e.g. from Client.DB(). The session is
+// written if and when the caller commits tx, atomically with the rest of it.
+// It is always synchronous, whatever the async mode.
+func (p *SessionPersister) PersistSessionTx(ctx context.Context, tx *sql.Tx, sess session.Session) error {
+	if err := p.checkTx(tx); err != nil {
+		return err
+	}
+
+	return p.upsertSession(ctx, tx, sess)
+}
+
+// PersistEventTx saves an event of sess within tx, as PersistSessionTx does, so
+// the event and the domain data the caller writes in tx are committed or rolled
+// back together. The session must be stored, by PersistSession before tx or by
+// PersistSessionTx within it. With WithMemoryOutbox, the completed turn is
+// recorded in tx too.
+//
+// The event takes the next order of its session when it is written, and holds
+// the session row until tx ends: the async operations of the session wait for
+// it. With PartitionRange, create the partitions ahead with
+// EnsureEventPartitions, since a missing one is created outside of tx.
+func (p *SessionPersister) PersistEventTx(
+	ctx context.Context,
+	tx *sql.Tx,
+	sess session.Session,
+	evt *session.Event,
+) error {
+	if err := p.checkTx(tx); err != nil {
+		return err
+	}
+
+	evt, ok := p.storedEvent(evt)
+	if !ok {
+		return nil // Dropped by the event transformer
+	}
+
+	evtData, stateArg, err := p.prepareEvent(ctx, sess, evt, false)
+	if err != nil {
+		return err
+	}
+
+	// NOTE: The statement cache only binds to the transactions of its own database
+	return p.insertEvent(ctx, tx, tx, sess, evt, evtData, stateArg)
+}
+
+func (p *SessionPersister) checkTx(tx *sql.Tx) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrPersisterClosed
+	}
+	if tx == nil {
+		return errors.New("transaction cannot be nil")
+	}
+	return nil
+}
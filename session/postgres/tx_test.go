@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPersistTxNil(t *testing.T) {
+	var p SessionPersister
+	sess := createTestSession("sess-tx", "test_app", "user-tx")
+
+	if err := p.PersistSessionTx(context.Background(), nil, sess); err == nil {
+		t.Error("expected an error for a nil transaction")
+	}
+	if err := p.PersistEventTx(context.Background(), nil, sess, createTestEvent("evt-tx", "user")); err == nil {
+		t.Error("expected an error for a nil transaction")
+	}
+}
+
+func TestPersistEventTx(t *testing.T) {
+	persister, client := setupTestDB(t)
+	if persister == nil {
+		return
+	}
+	defer persister.Close()
+	defer client.Close()
+
+	ctx := context.Background()
+	sess := createTestSessionWithState("sess-tx", "test_app", "user-tx", map[string]any{"step": 1})
+
+	// persist writes the session and an event in a transaction, and ends it.
+	persist := func(evtID string, commit bool) {
+		t.Helper()
+
+		tx, err := client.DB().BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatalf("BeginTx failed: %v", err)
+		}
+		defer func() { _ = tx.Rollback() }()
+
+		if err := persister.PersistSessionTx(ctx, tx, sess); err != nil {
+			t.Fatalf("PersistSessionTx failed: %v", err)
+		}
+		if err := persister.PersistEventTx(ctx, tx, sess, createTestEvent(evtID, "user")); err != nil {
+			t.Fatalf("PersistEventTx failed: %v", err)
+		}
+		if commit {
+			if err := tx.Commit(); err != nil {
+				t.Fatalf("Commit failed: %v", err)
+			}
+		}
+	}
+
+	persist("evt-tx-rolled-back", false)
+	stored, err := persister.LoadSession(ctx, "test_app", "user-tx", "sess-tx")
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+	if stored != nil {
+		t.Fatal("session stored by a rolled back transaction")
+	}
+
+	persist("evt-tx-committed", true)
+	stored, err = persister.LoadSession(ctx, "test_app", "user-tx", "sess-tx")
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+	if stored == nil || len(stored.Events) != 1 || stored.Events[0].ID != "evt-tx-committed" {
+		t.Fatalf("unexpected session after commit: %+v", stored)
+	}
+}
@@ -0,0 +1,140 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/kydenul/k-adk/internal/pgerr"
+)
+
+// SessionStats reports the sessions and events stored for an app.
+type SessionStats struct {
+	// AppName is the app reported, empty for all apps.
+	AppName string
+
+	// Sessions and Events count the stored sessions and events.
+	Sessions int64
+	Events   int64
+
+	// AvgEventsPerSession is Events / Sessions, 0 without sessions.
+	AvgEventsPerSession float64
+
+	// SessionsPerUser counts the sessions of each user.
+	SessionsPerUser map[string]int64
+
+	// EventsPerShard counts the events of each events table: the shard tables, or
+	// the partitions with Config.Partitioning. Tables without events are omitted.
+	EventsPerShard map[string]int64
+
+	// StorageBytes is the stored size of the states, tags and events, after
+	// compression, as pg_column_size reports it; indexes and bloat not included.
+	StorageBytes int64
+
+	// TableBytes is the size on disk of each table, indexes and TOAST included,
+	// whatever its app. It is empty with Config.DistributedSQL.
+	TableBytes map[string]int64
+}
+
+// SessionStats returns the session and event counts and the storage of appName,
+// or of all apps if appName is empty. It scans the sessions and events of the
+// app, so call it for monitoring, not per request.
+func (p *SessionPersister) SessionStats(ctx context.Context, appName string) (*SessionStats, error) {
+	stats := &SessionStats{
+		AppName:         appName,
+		SessionsPerUser: make(map[string]int64),
+		EventsPerShard:  make(map[string]int64),
+		TableBytes:      make(map[string]int64),
+	}
+
+	// Sessions per user
+	//nolint:gosec // table name is validated by the client
+	rows, err := p.client.DB().QueryContext(ctx, `
+		SELECT user_id, COUNT(*), COALESCE(SUM(pg_column_size(state) + pg_column_size(tags)), 0)
+		FROM `+p.client.SessionsTableName()+`
+		WHERE $1 = '' OR app_name = $1
+		GROUP BY user_id
+	`, appName)
+	if err != nil {
+		return nil, pgerr.Wrap("failed to count sessions", err)
+	}
+	for rows.Next() {
+		var (
+			userID      string
+			count, size int64
+		)
+		if err := rows.Scan(&userID, &count, &size); err != nil {
+			rows.Close()
+			return nil, pgerr.Wrap("failed to scan session counts", err)
+		}
+		stats.SessionsPerUser[userID] = count
+		stats.Sessions += count
+		stats.StorageBytes += size
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, pgerr.Wrap("failed to count sessions", err)
+	}
+
+	// Events per shard table or partition
+	// NOTE: The distributed engines have no tableoid; their events are not partitioned
+	shardColumn := "tableoid::regclass::text"
+	if p.client.DistributedSQL() {
+		shardColumn = "$2::text"
+	}
+	for _, table := range p.client.eventsTables() {
+		//nolint:gosec // table name is validated by the client
+		query := `
+			SELECT ` + shardColumn + `, COUNT(*), COALESCE(SUM(pg_column_size(content)), 0)
+			FROM ` + p.client.tableName(table) + `
+			WHERE $1 = '' OR app_name = $1
+			GROUP BY 1`
+		args := []any{appName}
+		if p.client.DistributedSQL() {
+			args = append(args, table)
+		}
+		rows, err := p.client.DB().QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, shardError("failed to count events", table, err)
+		}
+		for rows.Next() {
+			var (
+				shard       string
+				count, size int64
+			)
+			if err := rows.Scan(&shard, &count, &size); err != nil {
+				rows.Close()
+				return nil, pgerr.Wrap("failed to scan event counts", err)
+			}
+			stats.EventsPerShard[shard] = count
+			stats.Events += count
+			stats.StorageBytes += size
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, shardError("failed to count events", table, err)
+		}
+	}
+
+	if stats.Sessions > 0 {
+		stats.AvgEventsPerSession = float64(stats.Events) / float64(stats.Sessions)
+	}
+
+	// Table sizes, the partitions of a partitioned table included
+	// NOTE: The distributed engines do not report them as PostgreSQL does
+	if p.client.DistributedSQL() {
+		return stats, nil
+	}
+	// NOTE: pg_partition_tree needs PostgreSQL 12, so only use it when partitioned
+	sizeQuery := `SELECT pg_total_relation_size($1::regclass)`
+	if p.client.Partitioning() != PartitionNone {
+		sizeQuery = `SELECT COALESCE(SUM(pg_total_relation_size(relid)), 0) FROM pg_partition_tree($1::regclass)`
+	}
+	for _, table := range append([]string{p.client.sessionsTable}, p.client.eventsTables()...) {
+		var size int64
+		if err := p.client.DB().QueryRowContext(ctx, sizeQuery, p.client.tableName(table)).Scan(&size); err != nil {
+			return nil, pgerr.Wrap("failed to read the size of table "+table, err)
+		}
+		stats.TableBytes[table] = size
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSessionStats(t *testing.T) {
+	persister, client := setupTestDB(t)
+	if persister == nil {
+		return
+	}
+	defer persister.Close()
+	defer client.Close()
+
+	ctx := context.Background()
+
+	for _, ref := range []struct{ userID, sessionID string }{
+		{"user-stats-1", "sess-stats-1"},
+		{"user-stats-1", "sess-stats-2"},
+		{"user-stats-2", "sess-stats-3"},
+	} {
+		sess := createTestSessionWithState(ref.sessionID, "test_stats", ref.userID, map[string]any{"step": 1})
+		if err := persister.persistSessionSync(ctx, sess); err != nil {
+			t.Fatalf("persistSessionSync failed: %v", err)
+		}
+		for _, id := range []string{"evt-1", "evt-2"} {
+			if err := persister.persistEventSync(ctx, sess, createTestEvent(ref.sessionID+id, "user"), false); err != nil {
+				t.Fatalf("persistEventSync failed: %v", err)
+			}
+		}
+	}
+
+	stats, err := persister.SessionStats(ctx, "test_stats")
+	if err != nil {
+		t.Fatalf("SessionStats failed: %v", err)
+	}
+	if stats.Sessions != 3 || stats.Events != 6 || stats.AvgEventsPerSession != 2 {
+		t.Errorf("unexpected counts: %+v", stats)
+	}
+	if stats.SessionsPerUser["user-stats-1"] != 2 || stats.SessionsPerUser["user-stats-2"] != 1 {
+		t.Errorf("sessions per user = %v", stats.SessionsPerUser)
+	}
+
+	var perShard int64
+	for _, count := range stats.EventsPerShard {
+		perShard += count
+	}
+	if perShard != 6 {
+		t.Errorf("events per shard = %v, want 6 in total", stats.EventsPerShard)
+	}
+	if stats.StorageBytes <= 0 || stats.TableBytes["sessions"] <= 0 {
+		t.Errorf("unexpected storage: %d bytes, tables %v", stats.StorageBytes, stats.TableBytes)
+	}
+}
@@ -0,0 +1,333 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"sync"
+	"time"
+
+	"github.com/kydenul/k-adk/internal/redisscript"
+)
+
+const (
+	defaultArchiveInterval    = time.Hour
+	defaultArchiveBatchSize   = 100
+	defaultArchiveSessionsSec = 50
+)
+
+// ArchiverConfig configures an Archiver.
+type ArchiverConfig struct {
+	// IdleFor is how long a session must go without update to be archived.
+	IdleFor time.Duration
+
+	// Optional. AppName restricts the archival to the sessions of an app. Default:
+	// every app.
+	AppName string
+
+	// Optional. Interval between two archival runs when started. Default: 1h.
+	Interval time.Duration
+
+	// Optional. BatchSize is the number of sessions read per page. Default: 100.
+	BatchSize int
+
+	// Optional. SessionsPerSecond caps the sessions archived per second, to keep
+	// the archival light on Redis and the persistent store. Default: 50.
+	SessionsPerSecond int
+
+	// Optional. OnArchived is called after every archived session, e.g. to log or
+	// notify the archival.
+	OnArchived func(ArchivedSession)
+
+	// Optional. OnError is called for every session that could not be archived;
+	// the session is left in Redis and retried on the next run.
+	OnError func(ref SessionRef, err error)
+}
+
+// ArchivedSession describes a session moved out of Redis by an Archiver.
+type ArchivedSession struct {
+	AppName   string
+	UserID    string
+	SessionID string
+
+	// Events is the number of events written to the persister, the events it
+	// already had excluded.
+	Events int
+
+	LastUpdateTime time.Time
+	ArchivedAt     time.Time
+}
+
+// ArchiveResult reports an archival run.
+type ArchiveResult struct {
+	// Scanned is the number of sessions read, Archived the number of idle sessions
+	// persisted and removed from Redis, Failed the number of idle sessions that
+	// could not be. Idle sessions updated during the run are neither.
+	Scanned  int
+	Archived int
+	Failed   int
+
+	Duration time.Duration
+}
+
+// Archiver moves idle sessions out of Redis: it scans the sessions of the service,
+// writes those idle for longer than IdleFor through the persister of the service,
+// session and events, then deletes them from Redis, index entries included. With
+// WithLoader, archived sessions are restored from the persister when read again.
+//
+// Sessions are read from the bucketed index (see WithBucketedIndex), or by SCAN
+// without it. A session updated between its read and its deletion is kept. Use a
+// synchronous persister (postgres.WithAsyncBufferSize(0)), so a session is stored
+// before it is deleted from Redis.
+type Archiver struct {
+	svc *RedisSessionService
+	cfg ArchiverConfig
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewArchiver creates an Archiver of the sessions of svc. Call Archive for a single
+// run, or Start to archive periodically.
+//
+// Returns ErrNoPersister if svc has no persister.
+func NewArchiver(svc *RedisSessionService, cfg ArchiverConfig) (*Archiver, error) {
+	if svc == nil {
+		return nil, errors.New("session service cannot be nil")
+	}
+	if svc.persister == nil {
+		return nil, ErrNoPersister
+	}
+	if cfg.IdleFor <= 0 {
+		return nil, errors.New("idle duration must be positive")
+	}
+
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultArchiveInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultArchiveBatchSize
+	}
+	if cfg.SessionsPerSecond <= 0 {
+		cfg.SessionsPerSecond = defaultArchiveSessionsSec
+	}
+
+	return &Archiver{svc: svc, cfg: cfg}, nil
+}
+
+// Start launches the periodic archival. It stops when ctx is cancelled or Stop is
+// called.
+func (a *Archiver) Start(ctx context.Context) {
+	ctx, a.cancel = context.WithCancel(ctx)
+
+	a.wg.Go(func() {
+		ticker := time.NewTicker(a.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := a.Archive(ctx); err != nil && ctx.Err() == nil {
+				a.svc.logger.Errorf("session archival failed: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+
+	a.svc.logger.Infof("session archiver started: idle=%s, interval=%s", a.cfg.IdleFor, a.cfg.Interval)
+}
+
+// Stop stops the periodic archival and waits for the run in progress.
+func (a *Archiver) Stop() {
+	if a.cancel != nil {
+		a.cancel()
+	}
+	a.wg.Wait()
+
+	a.svc.logger.Info("session archiver stopped")
+}
+
+// Archive runs an archival of the sessions idle for longer than IdleFor. The
+// sessions that fail to be archived are logged, counted and reported to OnError,
+// and the run goes on. When ctx ends, it returns the result so far with the
+// context error.
+func (a *Archiver) Archive(ctx context.Context) (*ArchiveResult, error) {
+	s := a.svc
+	start := time.Now()
+	result := &ArchiveResult{}
+	cutoff := start.Add(-a.cfg.IdleFor)
+
+	ticker := time.NewTicker(rateInterval(a.cfg.SessionsPerSecond))
+	defer ticker.Stop()
+
+	for keys, err := range a.pages(ctx) {
+		if err != nil {
+			result.Duration = time.Since(start)
+			return result, err
+		}
+
+		sessions, err := s.scannedSessions(ctx, keys)
+		if err != nil {
+			result.Duration = time.Since(start)
+			return result, err
+		}
+
+		for _, storable := range sessions {
+			result.Scanned++
+			if !storable.LastUpdateTime.Before(cutoff) {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				result.Duration = time.Since(start)
+				return result, ctx.Err()
+			case <-ticker.C:
+			}
+
+			archived, err := a.archive(ctx, storable)
+			switch {
+			case err != nil:
+				result.Failed++
+				ref := SessionRef{UserID: storable.UserID, SessionID: storable.ID}
+				s.logger.Warnf("failed to archive session %s: %v", storable.ID, err)
+				if a.cfg.OnError != nil {
+					a.cfg.OnError(ref, err)
+				}
+			case archived != nil:
+				result.Archived++
+				if a.cfg.OnArchived != nil {
+					a.cfg.OnArchived(*archived)
+				}
+			}
+		}
+	}
+
+	result.Duration = time.Since(start)
+
+	s.logger.Infof("sessions archived: scanned=%d, archived=%d, failed=%d, duration=%s",
+		result.Scanned, result.Archived, result.Failed, result.Duration)
+
+	return result, nil
+}
+
+// pages yields the session keys to archive, BatchSize at a time: from the bucketed
+// index if the service has one, by SCAN otherwise.
+func (a *Archiver) pages(ctx context.Context) iter.Seq2[[]string, error] {
+	s := a.svc
+
+	return func(yield func([]string, error) bool) {
+		if s.buckets <= 0 {
+			match := "session:*"
+			if a.cfg.AppName != "" {
+				match = "session:" + escapeGlob(a.cfg.AppName) + ":*"
+			}
+
+			var cursor uint64
+			for {
+				keys, next, err := s.rdb.Scan(ctx, cursor, match, int64(a.cfg.BatchSize)).Result()
+				if err != nil {
+					yield(nil, fmt.Errorf("failed to scan sessions: %w", err))
+					return
+				}
+				if !yield(keys, nil) || next == 0 {
+					return
+				}
+				cursor = next
+			}
+		}
+
+		apps := []string{a.cfg.AppName}
+		if a.cfg.AppName == "" {
+			var err error
+			if apps, err = s.Apps(ctx); err != nil {
+				yield(nil, err)
+				return
+			}
+		}
+
+		for _, appName := range apps {
+			keys := make([]string, 0, a.cfg.BatchSize)
+			for ref, err := range s.AllSessions(ctx, appName) {
+				if err != nil {
+					yield(nil, err)
+					return
+				}
+
+				keys = append(keys, buildSessionKey(appName, ref.UserID, ref.SessionID))
+				if len(keys) == a.cfg.BatchSize {
+					if !yield(keys, nil) {
+						return
+					}
+					keys = make([]string, 0, a.cfg.BatchSize)
+				}
+			}
+			if len(keys) > 0 && !yield(keys, nil) {
+				return
+			}
+		}
+	}
+}
+
+// claimArchiveScript deletes the session at KEYS[1] if it was not updated since it
+// was read, so an archived session does not lose a later update.
+//
+// ARGV[1]: last_update_time of the session read (RFC3339Nano formatted string)
+//
+// Returns: 1 if the session was deleted, 0 if it is gone or was updated
+var claimArchiveScript = redisscript.New("session_claim_archive", `
+local data = redis.call('GET', KEYS[1])
+if not data then
+    return 0
+end
+
+local session = cjson.decode(data)
+if session.last_update_time ~= ARGV[1] then
+    return 0
+end
+
+redis.call('DEL', KEYS[1])
+return 1
+`)
+
+// archive persists a session and deletes it from Redis. It returns nil, without
+// error, if the session was updated or deleted in the meantime.
+func (a *Archiver) archive(ctx context.Context, storable *storableSession) (*ArchivedSession, error) {
+	s := a.svc
+
+	written, err := s.backfillSession(ctx, s.persister, storable)
+	if err != nil {
+		return nil, err
+	}
+
+	key := buildSessionKey(storable.AppName, storable.UserID, storable.ID)
+	claimed, err := claimArchiveScript.Run(ctx, s.rdb, []string{key},
+		storable.LastUpdateTime.Format(time.RFC3339Nano)).Int()
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete session: %w", err)
+	}
+	if claimed == 0 {
+		s.logger.Debugf("session %s updated during its archival, kept", storable.ID)
+		return nil, nil
+	}
+
+	if err := s.dropSession(ctx, storable.AppName, storable.UserID, storable.ID); err != nil {
+		return nil, err
+	}
+
+	s.logger.Infof("session archived: app=%s, user=%s, session=%s, events=%d",
+		storable.AppName, storable.UserID, storable.ID, max(written, 0))
+
+	return &ArchivedSession{
+		AppName:        storable.AppName,
+		UserID:         storable.UserID,
+		SessionID:      storable.ID,
+		Events:         max(written, 0),
+		LastUpdateTime: storable.LastUpdateTime,
+		ArchivedAt:     time.Now(),
+	}, nil
+}
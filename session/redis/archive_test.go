@@ -0,0 +1,120 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	ksess "github.com/kydenul/k-adk/session"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/adk/session"
+)
+
+func TestNewArchiver(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1"})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	redisOnly, err := NewRedisSessionService(rdb)
+	if err != nil {
+		t.Fatalf("NewRedisSessionService() error = %v", err)
+	}
+	if _, err := NewArchiver(redisOnly, ArchiverConfig{IdleFor: time.Hour}); !errors.Is(err, ErrNoPersister) {
+		t.Errorf("expected ErrNoPersister, got %v", err)
+	}
+
+	svc, err := NewRedisSessionService(rdb, WithPersister(&memStore{sessions: map[string]*ksess.StoredSession{}}))
+	if err != nil {
+		t.Fatalf("NewRedisSessionService() error = %v", err)
+	}
+	if _, err := NewArchiver(svc, ArchiverConfig{}); err == nil {
+		t.Error("expected an error without idle duration")
+	}
+
+	archiver, err := NewArchiver(svc, ArchiverConfig{IdleFor: time.Hour})
+	if err != nil {
+		t.Fatalf("NewArchiver() error = %v", err)
+	}
+	if archiver.cfg.Interval != defaultArchiveInterval ||
+		archiver.cfg.BatchSize != defaultArchiveBatchSize ||
+		archiver.cfg.SessionsPerSecond != defaultArchiveSessionsSec {
+		t.Errorf("unexpected defaults: %+v", archiver.cfg)
+	}
+}
+
+func TestArchiver(t *testing.T) {
+	const (
+		appName = "test_archive_app"
+		userID  = "test_archive_user"
+	)
+
+	store := &memStore{sessions: map[string]*ksess.StoredSession{}}
+	svc, rdb := setupTestRedis(t, WithTTL(30*time.Second), WithPersister(store))
+	t.Cleanup(func() {
+		cleanupTestKeys(t, rdb,
+			fmt.Sprintf("session:%s:*", appName),
+			fmt.Sprintf("events:%s:*", appName),
+			fmt.Sprintf("statelog:%s:*", appName),
+		)
+	})
+
+	ctx := context.Background()
+
+	resp, err := svc.Create(ctx, &session.CreateRequest{
+		AppName: appName, UserID: userID, SessionID: "idle",
+		State: map[string]any{"topic": "idle"},
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := svc.AppendEvent(ctx, resp.Session, &session.Event{ID: "e1", Author: "user"}); err != nil {
+		t.Fatalf("AppendEvent failed: %v", err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	if _, err := svc.Create(ctx, &session.CreateRequest{
+		AppName: appName, UserID: userID, SessionID: "active",
+	}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	var archived []ArchivedSession
+	archiver, err := NewArchiver(svc, ArchiverConfig{
+		IdleFor:           100 * time.Millisecond,
+		AppName:           appName,
+		SessionsPerSecond: 1000,
+		OnArchived:        func(a ArchivedSession) { archived = append(archived, a) },
+	})
+	if err != nil {
+		t.Fatalf("NewArchiver failed: %v", err)
+	}
+
+	result, err := archiver.Archive(ctx)
+	if err != nil {
+		t.Fatalf("Archive failed: %v", err)
+	}
+	if result.Scanned != 2 || result.Archived != 1 || result.Failed != 0 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if len(archived) != 1 || archived[0].SessionID != "idle" {
+		t.Fatalf("expected the idle session archived, got %+v", archived)
+	}
+
+	if n, err := rdb.Exists(ctx,
+		buildSessionKey(appName, userID, "idle"),
+		buildEventsKey(appName, userID, "idle"),
+	).Result(); err != nil || n != 0 {
+		t.Errorf("expected the idle session removed from redis, got %d keys, %v", n, err)
+	}
+	if stored := store.sessions["idle"]; stored == nil || len(stored.Events) != 1 || stored.State["topic"] != "idle" {
+		t.Errorf("unexpected stored session: %+v", stored)
+	}
+
+	if _, err := svc.Get(ctx, &session.GetRequest{
+		AppName: appName, UserID: userID, SessionID: "active",
+	}); err != nil {
+		t.Errorf("expected the active session kept, got %v", err)
+	}
+}
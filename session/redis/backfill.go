@@ -0,0 +1,271 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bytedance/sonic"
+	ksess "github.com/kydenul/k-adk/session"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/adk/session"
+)
+
+const (
+	defaultBackfillBatchSize   = 100
+	defaultBackfillSessionsSec = 50
+)
+
+// ErrNoPersister is returned by Backfill when neither the service nor the
+// BackfillConfig has a persister.
+var ErrNoPersister = errors.New("no persister")
+
+// BackfillConfig configures Backfill.
+type BackfillConfig struct {
+	// Optional. AppName restricts the backfill to the sessions of an app. Default:
+	// every app.
+	AppName string
+
+	// Optional. BatchSize is the number of keys scanned per page. Default: 100.
+	BatchSize int
+
+	// Optional. SessionsPerSecond caps the sessions written per second, to keep
+	// the backfill light on Redis and the persistent store. Default: 50.
+	SessionsPerSecond int
+
+	// Optional. Resume continues an interrupted backfill from the Cursor of its
+	// BackfillResult.
+	Resume uint64
+
+	// Optional. OnProgress is called after every page with the result so far,
+	// e.g. to save the cursor.
+	OnProgress func(*BackfillResult)
+
+	// Optional. Persister receives the sessions instead of the persister of the
+	// service, e.g. a synchronous persister for the backfill while the serving
+	// instances persist asynchronously. Default: the persister of WithPersister.
+	Persister ksess.Persister
+}
+
+// BackfillResult reports the progress of Backfill.
+type BackfillResult struct {
+	// Sessions is the number of sessions written to the persister and Events the
+	// number of their events written. Skipped is the number of sessions the
+	// persister already had with all their events, and Failed the number of
+	// sessions that could not be written.
+	Sessions int
+	Events   int
+	Skipped  int
+	Failed   int
+
+	// Cursor is the SCAN cursor of the next page. Pass it as BackfillConfig.Resume
+	// to continue an interrupted backfill. Done reports that the scan completed.
+	Cursor uint64
+	Done   bool
+
+	Duration time.Duration
+}
+
+// Backfill writes the sessions stored in Redis, and their events, through the
+// persister (see WithPersister and BackfillConfig.Persister), so a deployment that started with Redis-only
+// sessions can adopt the persister without losing live conversations. Enable the
+// persister on the serving instances first: sessions changed during the backfill
+// are then persisted either way.
+//
+// Session keys are walked with SCAN. When the persister is also a ksess.Loader, as
+// postgres.SessionPersister is, the events it already has are skipped by ID, so a
+// backfill can be run again or resumed without duplicating events; otherwise
+// every event is written. Events trimmed from Redis (see WithEventTrimming) are
+// lost. Use a synchronous persister (postgres.WithAsyncBufferSize(0)) so the
+// events of a session are written after the session.
+//
+// The backfill is rate limited. When ctx ends, it returns the result so far with
+// the context error; its Cursor resumes the backfill. Sessions that fail to be
+// written are logged and counted, and the backfill goes on.
+func (s *RedisSessionService) Backfill(ctx context.Context, cfg BackfillConfig) (*BackfillResult, error) {
+	persister := cfg.Persister
+	if persister == nil {
+		persister = s.persister
+	}
+	if persister == nil {
+		return nil, ErrNoPersister
+	}
+
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBackfillBatchSize
+	}
+	if cfg.SessionsPerSecond <= 0 {
+		cfg.SessionsPerSecond = defaultBackfillSessionsSec
+	}
+
+	match := "session:*"
+	if cfg.AppName != "" {
+		match = "session:" + escapeGlob(cfg.AppName) + ":*"
+	}
+
+	s.logger.Infof("backfilling sessions: match=%s, resume=%d", match, cfg.Resume)
+
+	start := time.Now()
+	result := &BackfillResult{Cursor: cfg.Resume}
+	ticker := time.NewTicker(rateInterval(cfg.SessionsPerSecond))
+	defer ticker.Stop()
+
+	for {
+		keys, next, err := s.rdb.Scan(ctx, result.Cursor, match, int64(cfg.BatchSize)).Result()
+		if err != nil {
+			result.Duration = time.Since(start)
+			return result, fmt.Errorf("failed to scan sessions: %w", err)
+		}
+
+		sessions, err := s.scannedSessions(ctx, keys)
+		if err != nil {
+			result.Duration = time.Since(start)
+			return result, err
+		}
+
+		for _, storable := range sessions {
+			select {
+			case <-ctx.Done():
+				// NOTE: The page is scanned again on resume, its sessions are skipped
+				result.Duration = time.Since(start)
+				return result, ctx.Err()
+			case <-ticker.C:
+			}
+
+			written, err := s.backfillSession(ctx, persister, storable)
+			switch {
+			case err != nil:
+				result.Failed++
+				s.logger.Warnf("failed to backfill session %s: %v", storable.ID, err)
+			case written < 0:
+				result.Skipped++
+			default:
+				result.Sessions++
+				result.Events += written
+			}
+		}
+
+		result.Cursor = next
+		if cfg.OnProgress != nil {
+			cfg.OnProgress(result)
+		}
+		if next == 0 {
+			result.Done = true
+			break
+		}
+	}
+
+	result.Duration = time.Since(start)
+
+	s.logger.Infof("sessions backfilled: sessions=%d, events=%d, skipped=%d, failed=%d, duration=%s",
+		result.Sessions, result.Events, result.Skipped, result.Failed, result.Duration)
+
+	return result, nil
+}
+
+// scannedSessions reads the sessions of scanned keys. The index sets sharing the
+// key prefix, and the sessions expired since the scan, are skipped.
+func (s *RedisSessionService) scannedSessions(ctx context.Context, keys []string) ([]*storableSession, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	pipe := s.rdb.Pipeline()
+	cmds := make([]*redis.StringCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.Get(ctx, key)
+	}
+	// NOTE: The errors of the index sets (WRONGTYPE) are checked per command
+	_, _ = pipe.Exec(ctx)
+
+	sessions := make([]*storableSession, 0, len(keys))
+	for i, cmd := range cmds {
+		data, err := cmd.Bytes()
+		switch {
+		case errors.Is(err, redis.Nil), redis.HasErrorPrefix(err, "WRONGTYPE"):
+			continue
+		case err != nil:
+			return nil, fmt.Errorf("failed to get %s: %w", keys[i], err)
+		}
+
+		var storable storableSession
+		if err := sonic.Unmarshal(data, &storable); err != nil {
+			s.logger.Warnf("failed to unmarshal session %s: %v", keys[i], err)
+			continue
+		}
+		if err := s.openStored(ctx, &storable); err != nil {
+			s.logger.Warnf("failed to decrypt session %s: %v", keys[i], err)
+			continue
+		}
+		sessions = append(sessions, &storable)
+	}
+
+	return sessions, nil
+}
+
+// backfillSession writes a session and the events the persister does not have
+// yet. It returns the number of events written, -1 if the persister already had
+// the session and all its events.
+func (s *RedisSessionService) backfillSession(
+	ctx context.Context,
+	persister ksess.Persister,
+	storable *storableSession,
+) (int, error) {
+	evKey := buildEventsKey(storable.AppName, storable.UserID, storable.ID)
+	eventData, err := s.rdb.LRange(ctx, evKey, 0, -1).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return 0, fmt.Errorf("failed to get events: %w", err)
+	}
+
+	events := make([]*session.Event, 0, len(eventData))
+	for i, ed := range eventData {
+		var evt session.Event
+		if err := s.codec.forApp(storable.AppName).unmarshal(ctx, ed, &evt); err != nil {
+			s.logger.Warnf("failed to unmarshal event at index %d of session %s: %v", i, storable.ID, err)
+			continue
+		}
+		events = append(events, &evt)
+	}
+
+	// NOTE: Skip the events the persister already has
+	if loader, ok := persister.(ksess.Loader); ok {
+		stored, err := loader.LoadSession(ctx, storable.AppName, storable.UserID, storable.ID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to load stored session: %w", err)
+		}
+
+		if stored != nil {
+			persisted := make(map[string]bool, len(stored.Events))
+			for _, evt := range stored.Events {
+				persisted[evt.ID] = true
+			}
+
+			missing := events[:0]
+			for _, evt := range events {
+				if !persisted[evt.ID] {
+					missing = append(missing, evt)
+				}
+			}
+			if len(missing) == 0 {
+				return -1, nil
+			}
+			events = missing
+		}
+	}
+
+	sess := s.listedSession(storable)
+	if err := persister.PersistSession(ctx, sess); err != nil {
+		return 0, fmt.Errorf("failed to persist session: %w", err)
+	}
+
+	for i, evt := range events {
+		if err := persister.PersistEvent(ctx, sess, evt); err != nil {
+			return i, fmt.Errorf("failed to persist event %s: %w", evt.ID, err)
+		}
+	}
+
+	s.logger.Debugf("session backfilled: session=%s, events=%d", storable.ID, len(events))
+
+	return len(events), nil
+}
@@ -0,0 +1,160 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"testing"
+	"time"
+
+	ksess "github.com/kydenul/k-adk/session"
+	"google.golang.org/adk/session"
+)
+
+// memStore is an in-memory persister and ksess.Loader.
+type memStore struct {
+	sessions map[string]*ksess.StoredSession
+}
+
+func (m *memStore) PersistSession(_ context.Context, sess session.Session) error {
+	stored, ok := m.sessions[sess.ID()]
+	if !ok {
+		stored = &ksess.StoredSession{ID: sess.ID(), AppName: sess.AppName(), UserID: sess.UserID()}
+		m.sessions[sess.ID()] = stored
+	}
+	stored.State = maps.Collect(sess.State().All())
+	stored.LastUpdateTime = sess.LastUpdateTime()
+	return nil
+}
+
+func (m *memStore) PersistEvent(_ context.Context, sess session.Session, evt *session.Event) error {
+	stored, ok := m.sessions[sess.ID()]
+	if !ok {
+		return errors.New("session missing")
+	}
+	stored.Events = append(stored.Events, evt)
+	return nil
+}
+
+func (m *memStore) DeleteSession(_ context.Context, _, _, sessionID string) error {
+	delete(m.sessions, sessionID)
+	return nil
+}
+
+func (m *memStore) Close() error { return nil }
+
+func (m *memStore) LoadSession(_ context.Context, _, _, sessionID string) (*ksess.StoredSession, error) {
+	return m.sessions[sessionID], nil
+}
+
+func (m *memStore) ListSessionIDs(context.Context, string, string) ([]string, error) {
+	return nil, nil
+}
+
+func TestBackfill(t *testing.T) {
+	const (
+		appName = "test_backfill_app"
+		userID  = "test_backfill_user"
+	)
+
+	// NOTE: The sessions are created by a Redis-only service
+	redisOnly, rdb := setupTestRedis(t, WithTTL(30*time.Second))
+	t.Cleanup(func() {
+		cleanupTestKeys(t, rdb,
+			fmt.Sprintf("session:%s:*", appName),
+			fmt.Sprintf("events:%s:*", appName),
+			fmt.Sprintf("statelog:%s:*", appName),
+		)
+	})
+
+	ctx := context.Background()
+
+	sessions := make(map[string]session.Session)
+	for _, id := range []string{"s1", "s2"} {
+		resp, err := redisOnly.Create(ctx, &session.CreateRequest{
+			AppName: appName, UserID: userID, SessionID: id,
+			State: map[string]any{"topic": id},
+		})
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		sessions[id] = resp.Session
+	}
+	for _, evt := range []*session.Event{{ID: "e1", Author: "user"}, {ID: "e2", Author: "agent"}} {
+		if err := redisOnly.AppendEvent(ctx, sessions["s1"], evt); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+	}
+
+	if _, err := redisOnly.Backfill(ctx, BackfillConfig{}); !errors.Is(err, ErrNoPersister) {
+		t.Fatalf("expected ErrNoPersister, got %v", err)
+	}
+
+	store := &memStore{sessions: map[string]*ksess.StoredSession{}}
+	svc, _ := setupTestRedis(t, WithTTL(30*time.Second), WithPersister(store))
+	cfg := BackfillConfig{AppName: appName, BatchSize: 10, SessionsPerSecond: 1000}
+
+	backfill := func(t *testing.T) *BackfillResult {
+		t.Helper()
+		result, err := svc.Backfill(ctx, cfg)
+		if err != nil {
+			t.Fatalf("Backfill failed: %v", err)
+		}
+		if !result.Done || result.Cursor != 0 || result.Failed != 0 {
+			t.Errorf("unexpected result: %+v", result)
+		}
+		return result
+	}
+
+	t.Run("writes sessions and events", func(t *testing.T) {
+		result := backfill(t)
+		if result.Sessions != 2 || result.Events != 2 {
+			t.Errorf("expected 2 sessions and 2 events, got %+v", result)
+		}
+
+		stored := store.sessions["s1"]
+		if stored == nil || len(stored.Events) != 2 || stored.State["topic"] != "s1" {
+			t.Fatalf("unexpected stored session: %+v", stored)
+		}
+	})
+
+	t.Run("skips persisted events", func(t *testing.T) {
+		if result := backfill(t); result.Skipped != 2 || result.Sessions != 0 {
+			t.Errorf("expected 2 skipped sessions, got %+v", result)
+		}
+
+		if err := redisOnly.AppendEvent(ctx, sessions["s1"], &session.Event{ID: "e3", Author: "user"}); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+		if result := backfill(t); result.Sessions != 1 || result.Events != 1 || result.Skipped != 1 {
+			t.Errorf("expected the new event only, got %+v", result)
+		}
+		if n := len(store.sessions["s1"].Events); n != 3 {
+			t.Errorf("expected 3 stored events, got %d", n)
+		}
+	})
+
+	t.Run("config persister", func(t *testing.T) {
+		other := &memStore{sessions: map[string]*ksess.StoredSession{}}
+		result, err := redisOnly.Backfill(ctx, BackfillConfig{
+			AppName: appName, SessionsPerSecond: 1000, Persister: other,
+		})
+		if err != nil {
+			t.Fatalf("Backfill failed: %v", err)
+		}
+		if result.Sessions != 2 || result.Events != 3 || len(other.sessions) != 2 {
+			t.Errorf("expected 2 sessions and 3 events, got %+v", result)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		result, err := svc.Backfill(cancelled, cfg)
+		if !errors.Is(err, context.Canceled) || result.Done {
+			t.Errorf("expected an interrupted backfill, got %+v, %v", result, err)
+		}
+	})
+}
@@ -0,0 +1,221 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/bytedance/sonic"
+	ksess "github.com/kydenul/k-adk/session"
+	"google.golang.org/adk/session"
+)
+
+var (
+	ErrEventNotFound = errors.New("event not found")
+	ErrSessionExists = errors.New("session already exists")
+)
+
+// GetBranch retrieves a session like Get, keeping only the events visible on branch
+// (see ksess.BranchVisible): the events of the root branch, of branch and of its
+// ancestors. The returned session keeps that view when its events are reloaded.
+func (s *RedisSessionService) GetBranch(
+	ctx context.Context,
+	req *session.GetRequest,
+	branch string,
+) (*session.GetResponse, error) {
+	start := time.Now()
+	resp, err := s.get(s.metricsContext(ctx), req, func(evt *session.Event) bool {
+		return ksess.BranchVisible(evt.Branch, branch)
+	})
+	s.observe(OperationGet, start, err)
+	return resp, err
+}
+
+// CreateBranchRequest describes the fork of a session.
+type CreateBranchRequest struct {
+	AppName   string
+	UserID    string
+	SessionID string
+
+	// EventID is the last event of the forked history. Empty forks the whole history.
+	EventID string
+
+	// Optional. BranchSessionID is the ID of the new session. Generated if empty.
+	BranchSessionID string
+
+	// Optional. State is merged over the state copied from the session.
+	State map[string]any
+}
+
+// CreateBranch forks a session at an event: the new session starts with a copy of
+// the events up to and including EventID, and of the current state and tags of the
+// session.
+// Conversations forked from the same event share their prefix, which makes
+// tree-structured conversations and A/B comparisons of agents on the same history
+// possible. The fork is linked to its origin, see ParentOf.
+//
+// Returns ErrSessionNotFound if the session does not exist, ErrEventNotFound if the
+// event is not in its history, and ErrSessionExists if BranchSessionID is taken.
+func (s *RedisSessionService) CreateBranch(
+	ctx context.Context,
+	req *CreateBranchRequest,
+) (*session.CreateResponse, error) {
+	s.logger.Debugf("creating branch: app=%s, user=%s, session=%s, event=%s",
+		req.AppName, req.UserID, req.SessionID, req.EventID)
+
+	parent, err := s.Get(ctx, &session.GetRequest{
+		AppName:   req.AppName,
+		UserID:    req.UserID,
+		SessionID: req.SessionID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// NOTE: Copy the history up to the fork event
+	var events []*session.Event
+	found := req.EventID == ""
+	for evt := range parent.Session.Events().All() {
+		events = append(events, evt)
+		if evt.ID == req.EventID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		s.logger.Errorf("event %s not found in session %s", req.EventID, req.SessionID)
+		return nil, fmt.Errorf("%w: %s", ErrEventNotFound, req.EventID)
+	}
+
+	// NOTE: The copied state passed validation already, only the overrides are checked
+	if validate := s.stateValidator(req.AppName); validate != nil {
+		if err := validate(req.State, nil); err != nil {
+			s.logger.Warnf("rejected branch state: app=%s, user=%s, err=%v", req.AppName, req.UserID, err)
+			return nil, err
+		}
+	}
+
+	state := maps.Collect(parent.Session.State().All())
+	maps.Copy(state, req.State)
+
+	branchID, err := s.resolveSessionID(req.BranchSessionID)
+	if err != nil {
+		s.logger.Warnf("rejected branch session ID: app=%s, user=%s, err=%v", req.AppName, req.UserID, err)
+		return nil, err
+	}
+
+	// NOTE: A branch lives as long as its parent
+	var ownTTL time.Duration
+	if rparent, ok := parent.Session.(*redisSession); ok {
+		ownTTL = rparent.ttl
+	}
+	ttl := s.sessionTTL(int64(ownTTL.Seconds()))
+
+	key := buildSessionKey(req.AppName, req.UserID, branchID)
+	evKey := buildEventsKey(req.AppName, req.UserID, branchID)
+	logKey := buildStateLogKey(req.AppName, req.UserID, branchID)
+
+	sess := &redisSession{
+		id:             branchID,
+		appName:        req.AppName,
+		userID:         req.UserID,
+		state:          newRedisState(state, 1, s.rdb, key, logKey, ttl, s.logger),
+		events:         newRedisEvents(events, s.rdb, evKey, s.codec.forApp(req.AppName), s.logger),
+		lastUpdateTime: time.Now(),
+		parentID:       req.SessionID,
+		forkEventID:    req.EventID,
+		tags:           maps.Clone(ksess.TagsOf(parent.Session)),
+		ttl:            ownTTL,
+	}
+	sess.state.reindex = s.stateReindexer(req.AppName, req.UserID, branchID, ttl)
+	sess.state.validate = s.stateValidator(req.AppName)
+	sess.state.cipher = s.stateCipher(req.AppName)
+
+	// NOTE: Store the session, refusing to overwrite an existing one
+	storable := sess.toStorable()
+	storable.StateVersion = 1
+	if storable.State, err = sess.state.cipher.seal(ctx, storable.State); err != nil {
+		s.logger.Errorf("failed to encrypt state of session %s: %v", branchID, err)
+		return nil, err
+	}
+	data, err := sonic.Marshal(storable)
+	if err != nil {
+		s.logger.Errorf("failed to marshal session %s: %v", branchID, err)
+		return nil, fmt.Errorf("failed to marshal session: %w", err)
+	}
+
+	ok, err := s.rdb.SetNX(ctx, key, data, ttl).Result()
+	if err != nil {
+		s.logger.Errorf("failed to set session %s in redis: %v", branchID, err)
+		return nil, fmt.Errorf("failed to set session: %w", err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrSessionExists, branchID)
+	}
+
+	// NOTE: Copy the events
+	if len(events) > 0 {
+		values := make([]any, 0, len(events))
+		for _, evt := range events {
+			evtData, err := s.codec.forApp(req.AppName).marshal(ctx, evt)
+			if err != nil {
+				s.rdb.Del(ctx, key)
+				s.logger.Errorf("failed to marshal event %s: %v", evt.ID, err)
+				return nil, fmt.Errorf("failed to marshal event: %w", err)
+			}
+			values = append(values, evtData)
+		}
+
+		pipe := s.rdb.TxPipeline()
+		pipe.Del(ctx, evKey)
+		pipe.RPush(ctx, evKey, values...)
+		pipe.Expire(ctx, evKey, ttl)
+		if _, err := pipe.Exec(ctx); err != nil {
+			s.rdb.Del(ctx, key)
+			s.logger.Errorf("failed to copy events to branch %s: %v", branchID, err)
+			return nil, fmt.Errorf("failed to copy events: %w", err)
+		}
+	}
+
+	s.armExpiry(ctx, req.AppName, req.UserID, branchID, ttl)
+
+	if err := s.indexAdd(ctx, req.AppName, req.UserID, branchID, ttl); err != nil {
+		s.logger.Errorf("failed to add session %s to index: %v", branchID, err)
+		return nil, fmt.Errorf("failed to add session to index: %w", err)
+	}
+
+	s.reindexState(ctx, req.AppName, req.UserID, branchID, state, ttl)
+
+	// NOTE: Persist to PostgreSQL if persister is configured
+	if s.persister != nil {
+		if err := s.persister.PersistSession(ctx, sess); err != nil {
+			s.logger.Warnf("failed to persist branch %s to postgres: %v", branchID, err)
+		}
+		for _, evt := range events {
+			if err := s.persister.PersistEvent(ctx, sess, evt); err != nil {
+				s.logger.Warnf("failed to persist event %s of branch %s to postgres: %v", evt.ID, branchID, err)
+			}
+		}
+	}
+
+	s.logger.Infof("branch created: session=%s, parent=%s, event=%s, events=%d",
+		branchID, req.SessionID, req.EventID, len(events))
+
+	s.notify(ctx, Notification{
+		Type: NotificationSessionCreated, AppName: req.AppName, UserID: req.UserID, SessionID: branchID,
+	})
+
+	return &session.CreateResponse{Session: sess}, nil
+}
+
+// ParentOf returns the session and event a session created by CreateBranch was
+// forked from. ok is false for sessions that are not branches.
+func ParentOf(sess session.Session) (sessionID, eventID string, ok bool) {
+	rs, isRedis := sess.(*redisSession)
+	if !isRedis || rs.parentID == "" {
+		return "", "", false
+	}
+	return rs.parentID, rs.forkEventID, true
+}
@@ -0,0 +1,137 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/adk/session"
+)
+
+func eventIDs(sess session.Session) []string {
+	var ids []string
+	for evt := range sess.Events().All() {
+		ids = append(ids, evt.ID)
+	}
+	return ids
+}
+
+func TestBranches(t *testing.T) {
+	const (
+		appName = "test_branch_app"
+		userID  = "test_branch_user"
+	)
+
+	svc, rdb := setupTestRedis(t, WithTTL(30*time.Second))
+	t.Cleanup(func() {
+		cleanupTestKeys(t, rdb,
+			fmt.Sprintf("session:%s:*", appName),
+			fmt.Sprintf("events:%s:*", appName),
+		)
+	})
+
+	ctx := context.Background()
+
+	createResp, err := svc.Create(ctx, &session.CreateRequest{
+		AppName: appName, UserID: userID, SessionID: "main",
+		State: map[string]any{"topic": "go"},
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	for _, evt := range []*session.Event{
+		{ID: "e1", Author: "user"},
+		{ID: "e2", Author: "a", Branch: "root.a"},
+		{ID: "e3", Author: "b", Branch: "root.b"},
+		{ID: "e4", Author: "root", Branch: "root"},
+	} {
+		if err := svc.AppendEvent(ctx, createResp.Session, evt); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+	}
+
+	getReq := &session.GetRequest{AppName: appName, UserID: userID, SessionID: "main"}
+
+	t.Run("get branch filters events", func(t *testing.T) {
+		resp, err := svc.GetBranch(ctx, getReq, "root.a")
+		if err != nil {
+			t.Fatalf("GetBranch failed: %v", err)
+		}
+		if ids := eventIDs(resp.Session); fmt.Sprint(ids) != "[e1 e2 e4]" {
+			t.Errorf("expected [e1 e2 e4], got %v", ids)
+		}
+	})
+
+	t.Run("create branch forks at event", func(t *testing.T) {
+		for _, id := range []string{"fork-a", "fork-b"} {
+			resp, err := svc.CreateBranch(ctx, &CreateBranchRequest{
+				AppName: appName, UserID: userID, SessionID: "main",
+				EventID: "e2", BranchSessionID: id,
+				State: map[string]any{"variant": id},
+			})
+			if err != nil {
+				t.Fatalf("CreateBranch failed: %v", err)
+			}
+
+			got, err := svc.Get(ctx, &session.GetRequest{AppName: appName, UserID: userID, SessionID: id})
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			if ids := eventIDs(got.Session); fmt.Sprint(ids) != "[e1 e2]" {
+				t.Errorf("expected [e1 e2], got %v", ids)
+			}
+			if v, _ := got.Session.State().Get("topic"); v != "go" {
+				t.Errorf("expected copied state, got %v", v)
+			}
+			if v, _ := got.Session.State().Get("variant"); v != id {
+				t.Errorf("expected state override %q, got %v", id, v)
+			}
+
+			parent, event, ok := ParentOf(got.Session)
+			if !ok || parent != "main" || event != "e2" {
+				t.Errorf("unexpected parent: %s, %s, %v", parent, event, ok)
+			}
+
+			// The fork grows independently of its parent
+			if err := svc.AppendEvent(ctx, resp.Session, &session.Event{ID: id + "-e3"}); err != nil {
+				t.Fatalf("AppendEvent failed: %v", err)
+			}
+		}
+
+		main, err := svc.Get(ctx, getReq)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if len(eventIDs(main.Session)) != 4 {
+			t.Errorf("expected the parent history untouched, got %v", eventIDs(main.Session))
+		}
+		if _, _, ok := ParentOf(main.Session); ok {
+			t.Error("expected no parent for the root session")
+		}
+	})
+
+	t.Run("create branch errors", func(t *testing.T) {
+		_, err := svc.CreateBranch(ctx, &CreateBranchRequest{
+			AppName: appName, UserID: userID, SessionID: "main", EventID: "missing",
+		})
+		if !errors.Is(err, ErrEventNotFound) {
+			t.Errorf("expected ErrEventNotFound, got %v", err)
+		}
+
+		_, err = svc.CreateBranch(ctx, &CreateBranchRequest{
+			AppName: appName, UserID: userID, SessionID: "main", BranchSessionID: "fork-a",
+		})
+		if !errors.Is(err, ErrSessionExists) {
+			t.Errorf("expected ErrSessionExists, got %v", err)
+		}
+
+		_, err = svc.CreateBranch(ctx, &CreateBranchRequest{
+			AppName: appName, UserID: userID, SessionID: "nope",
+		})
+		if !errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("expected ErrSessionNotFound, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kydenul/k-adk/compression"
+	"github.com/kydenul/k-adk/encryption"
+	"google.golang.org/adk/session"
+)
+
+// WithCodec compresses the events written to Redis with c, e.g.
+// compression.Zstd(). Events written before, or with another codec, stay readable.
+// The session key is kept as plain JSON, for the scripts updating its state.
+func WithCodec(c compression.Codec) ServiceOption {
+	return func(s *RedisSessionService) { s.codec.compression = c }
+}
+
+// eventCodec encodes the items of the events lists. The service shares it with
+// the events of its sessions.
+type eventCodec struct {
+	// serializer serializes the events, nil for JSON.
+	serializer Serializer
+	// compression compresses the serialized events, nil to store them as is.
+	compression compression.Codec
+	// encryptor encrypts the compressed events, nil to store them as is.
+	encryptor *encryption.Encryptor
+
+	// appName is the app whose keys encrypt the events, see forApp.
+	appName string
+}
+
+// forApp returns the codec of the events of appName.
+func (c eventCodec) forApp(appName string) eventCodec {
+	c.appName = appName
+	return c
+}
+
+// marshal serializes an event for the events list, then compresses and encrypts it.
+func (c eventCodec) marshal(ctx context.Context, evt *session.Event) ([]byte, error) {
+	serializer := c.serializer
+	if serializer == nil {
+		serializer = JSONSerializer()
+	}
+
+	data, err := serializer.Marshal(evt)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = compression.Encode(c.compression, data)
+	if err != nil || c.encryptor == nil {
+		return data, err
+	}
+
+	return c.encryptor.Encrypt(ctx, c.appName, data)
+}
+
+// unmarshal deserializes an item of an events list, encrypted, compressed or not.
+// JSON items are read whatever the serializer, so events written before it was set
+// stay readable.
+func (c eventCodec) unmarshal(ctx context.Context, data string, evt *session.Event) error {
+	payload := []byte(data)
+	if encryption.IsEncrypted(payload) {
+		if c.encryptor == nil {
+			return errNoEncryptor
+		}
+
+		var err error
+		if payload, err = c.encryptor.Decrypt(ctx, c.appName, payload); err != nil {
+			return fmt.Errorf("failed to decrypt event: %w", err)
+		}
+	}
+
+	decoded, err := compression.Decode(payload)
+	if err != nil {
+		return fmt.Errorf("failed to decode event: %w", err)
+	}
+
+	if c.serializer == nil || isJSONObject(decoded) {
+		return JSONSerializer().Unmarshal(decoded, evt)
+	}
+
+	return c.serializer.Unmarshal(decoded, evt)
+}
@@ -0,0 +1,79 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kydenul/k-adk/compression"
+	"google.golang.org/adk/session"
+	"google.golang.org/genai"
+)
+
+func TestCodec(t *testing.T) {
+	const (
+		appName = "test_codec_app"
+		userID  = "test_codec_user"
+	)
+
+	svc, rdb := setupTestRedis(t, WithTTL(30*time.Second), WithCodec(compression.Zstd()))
+	t.Cleanup(func() {
+		cleanupTestKeys(t, rdb,
+			fmt.Sprintf("session:%s:*", appName),
+			fmt.Sprintf("events:%s:*", appName),
+			fmt.Sprintf("statelog:%s:*", appName),
+		)
+	})
+
+	ctx := context.Background()
+	resp, err := svc.Create(ctx, &session.CreateRequest{AppName: appName, UserID: userID, SessionID: "codec"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	output := strings.Repeat("grounding chunk ", 200)
+	large := &session.Event{ID: "large", Author: "tool"}
+	large.Content = genai.NewContentFromText(output, genai.RoleModel)
+	small := &session.Event{ID: "small", Author: "user"}
+	for _, evt := range []*session.Event{large, small} {
+		if err := svc.AppendEvent(ctx, resp.Session, evt); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+	}
+
+	items := rdb.LRange(ctx, buildEventsKey(appName, userID, "codec"), 0, -1).Val()
+	if len(items) != 2 || !compression.IsCompressed([]byte(items[0])) || len(items[0]) >= len(output) {
+		t.Fatalf("expected the large event to be stored compressed, got %d items", len(items))
+	}
+
+	// NOTE: Encode compares the marshaled size, and an event writes out all its fields
+	smallData, err := JSONSerializer().Marshal(small)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	wantSmall, err := compression.Encode(compression.Zstd(), smallData)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if got, want := compression.IsCompressed([]byte(items[1])), compression.IsCompressed(wantSmall); got != want {
+		t.Errorf("small event of %d bytes stored compressed = %t, want %t", len(smallData), got, want)
+	}
+	if !compression.IsCompressed(wantSmall) && items[1] != string(smallData) {
+		t.Error("expected the small event to be stored as is")
+	}
+
+	// NOTE: A service without codec reads the compressed events
+	plain, err := NewRedisSessionService(rdb)
+	if err != nil {
+		t.Fatalf("NewRedisSessionService failed: %v", err)
+	}
+	got, err := plain.Get(ctx, &session.GetRequest{AppName: appName, UserID: userID, SessionID: "codec"})
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Session.Events().Len() != 2 || got.Session.Events().At(0).Content.Parts[0].Text != output {
+		t.Error("expected the compressed event to be decoded")
+	}
+}
@@ -0,0 +1,100 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bytedance/sonic"
+	"github.com/kydenul/k-adk/encryption"
+)
+
+// errNoEncryptor is returned for an encrypted payload read by a service without
+// WithEncryption.
+var errNoEncryptor = errors.New("payload is encrypted and no encryptor is set")
+
+// WithEncryption encrypts the session payloads written to Redis with the keys of
+// their app (see encryption.Encryptor): every event, after its serialization and
+// compression, and every state value, state change log included. The state values
+// are encrypted deterministically (see Encryptor.EncryptDeterministic), so the
+// scripts updating the state still detect the changed values; the session record
+// itself, its IDs, timestamps and tags, stays plain JSON. Payloads written before
+// stay readable. The state index, if set, keeps the indexed values in its keys.
+//
+// The events lists are rotated by encryption.RedisTarget; the state values are
+// re-encrypted with the current key as they are written.
+func WithEncryption(enc *encryption.Encryptor) ServiceOption {
+	return func(s *RedisSessionService) {
+		s.encryptor = enc
+		s.codec.encryptor = enc
+	}
+}
+
+// stateCipher encrypts and decrypts the state values of the sessions of an app.
+// A nil *stateCipher leaves the states as they are.
+type stateCipher struct {
+	enc     *encryption.Encryptor
+	appName string
+}
+
+// stateCipher returns the state cipher of appName, nil without WithEncryption.
+func (s *RedisSessionService) stateCipher(appName string) *stateCipher {
+	if s.encryptor == nil {
+		return nil
+	}
+	return &stateCipher{enc: s.encryptor, appName: appName}
+}
+
+// seal returns a copy of state with its values encrypted.
+func (c *stateCipher) seal(ctx context.Context, state map[string]any) (map[string]any, error) {
+	if c == nil || state == nil {
+		return state, nil
+	}
+
+	sealed := make(map[string]any, len(state))
+	for k, v := range state {
+		data, err := sonic.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal state value %s: %w", k, err)
+		}
+		ciphertext, err := c.enc.EncryptDeterministic(ctx, c.appName, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt state value %s: %w", k, err)
+		}
+		sealed[k] = string(ciphertext)
+	}
+
+	return sealed, nil
+}
+
+// open decrypts the encrypted values of state in place. Plain values, written
+// before WithEncryption, are kept.
+func (c *stateCipher) open(ctx context.Context, state map[string]any) error {
+	for k, v := range state {
+		text, ok := v.(string)
+		if !ok || !encryption.IsEncrypted([]byte(text)) {
+			continue
+		}
+		if c == nil {
+			return fmt.Errorf("state value %s: %w", k, errNoEncryptor)
+		}
+
+		data, err := c.enc.Decrypt(ctx, c.appName, []byte(text))
+		if err != nil {
+			return fmt.Errorf("failed to decrypt state value %s: %w", k, err)
+		}
+
+		var value any
+		if err := sonic.Unmarshal(data, &value); err != nil {
+			return fmt.Errorf("failed to unmarshal state value %s: %w", k, err)
+		}
+		state[k] = value
+	}
+
+	return nil
+}
+
+// openStored decrypts the state of a session read from Redis.
+func (s *RedisSessionService) openStored(ctx context.Context, storable *storableSession) error {
+	return s.stateCipher(storable.AppName).open(ctx, storable.State)
+}
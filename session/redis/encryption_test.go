@@ -0,0 +1,158 @@
+package redis
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/kydenul/k-adk/encryption"
+	"google.golang.org/adk/session"
+)
+
+func testEncryptor(t *testing.T, appNames ...string) *encryption.Encryptor {
+	t.Helper()
+
+	keys := encryption.NewKeyring()
+	for _, appName := range appNames {
+		if err := keys.Set(appName, encryption.Key{ID: "k1", Material: bytes.Repeat([]byte{7}, 32)}); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+	}
+	return encryption.NewEncryptor(keys)
+}
+
+func TestStateCipher(t *testing.T) {
+	ctx := context.Background()
+	svc := &RedisSessionService{}
+	WithEncryption(testEncryptor(t, "app"))(svc)
+	c := svc.stateCipher("app")
+
+	state := map[string]any{"topic": "billing", "turns": float64(2), "nested": map[string]any{"ok": true}}
+	sealed, err := c.seal(ctx, state)
+	if err != nil {
+		t.Fatalf("seal() error = %v", err)
+	}
+	for k, v := range sealed {
+		if text, _ := v.(string); !encryption.IsEncrypted([]byte(text)) {
+			t.Errorf("sealed value of %s = %v, want a ciphertext", k, v)
+		}
+	}
+
+	// NOTE: Equal values give equal ciphertexts, for the state update scripts
+	again, _ := c.seal(ctx, state)
+	if again["topic"] != sealed["topic"] {
+		t.Error("seal() of an unchanged value gave another ciphertext")
+	}
+
+	sealed["plain"] = "written before encryption"
+	if err := c.open(ctx, sealed); err != nil {
+		t.Fatalf("open() error = %v", err)
+	}
+	if sealed["topic"] != "billing" || sealed["turns"] != float64(2) || sealed["plain"] != "written before encryption" {
+		t.Errorf("open() = %v", sealed)
+	}
+	if nested, _ := sealed["nested"].(map[string]any); nested["ok"] != true {
+		t.Errorf("open() nested = %v", sealed["nested"])
+	}
+
+	again, _ = c.seal(ctx, state)
+	var none *stateCipher
+	if err := none.open(ctx, again); !errors.Is(err, errNoEncryptor) {
+		t.Errorf("open() error = %v without encryptor, want errNoEncryptor", err)
+	}
+	if (&RedisSessionService{}).stateCipher("app") != nil {
+		t.Error("stateCipher() without encryptor is not nil")
+	}
+}
+
+func TestEventCodecEncryption(t *testing.T) {
+	ctx := context.Background()
+	codec := eventCodec{encryptor: testEncryptor(t, "app")}.forApp("app")
+
+	data, err := codec.marshal(ctx, testEvent())
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	if !encryption.IsEncrypted(data) || bytes.Contains(data, []byte("weather")) {
+		t.Fatalf("event stored as %.40s, want a ciphertext", data)
+	}
+
+	var got session.Event
+	if err := codec.unmarshal(ctx, string(data), &got); err != nil || got.ID != "e1" {
+		t.Errorf("unmarshal = %v, %v", got.ID, err)
+	}
+	if err := (eventCodec{}).unmarshal(ctx, string(data), &got); !errors.Is(err, errNoEncryptor) {
+		t.Errorf("unmarshal error = %v without encryptor, want errNoEncryptor", err)
+	}
+}
+
+func TestEncryption(t *testing.T) {
+	const (
+		appName = "test_encryption_app"
+		userID  = "test_encryption_user"
+	)
+
+	svc, rdb := setupTestRedis(t, WithTTL(30*time.Second), WithEncryption(testEncryptor(t, appName)))
+	t.Cleanup(func() {
+		cleanupTestKeys(t, rdb,
+			fmt.Sprintf("session:%s:*", appName),
+			fmt.Sprintf("events:%s:*", appName),
+			fmt.Sprintf("statelog:%s:*", appName),
+		)
+	})
+
+	ctx := context.Background()
+	resp, err := svc.Create(ctx, &session.CreateRequest{
+		AppName: appName, UserID: userID, SessionID: "secret",
+		State: map[string]any{"card": "4242"},
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := svc.AppendEvent(ctx, resp.Session, &session.Event{
+		ID: "e1", Author: "user",
+		Actions: session.EventActions{StateDelta: map[string]any{"address": "1 Main St"}},
+	}); err != nil {
+		t.Fatalf("AppendEvent failed: %v", err)
+	}
+	if err := resp.Session.State().Set("card", "4343"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	data := rdb.Get(ctx, buildSessionKey(appName, userID, "secret")).Val()
+	items := rdb.LRange(ctx, buildEventsKey(appName, userID, "secret"), 0, -1).Val()
+	stored := data + strings.Join(items, "")
+	for _, secret := range []string{"4343", "1 Main St"} {
+		if strings.Contains(stored, secret) {
+			t.Errorf("%q stored in plain text", secret)
+		}
+	}
+	var storable storableSession
+	if err := sonic.UnmarshalString(data, &storable); err != nil || storable.ID != "secret" {
+		t.Errorf("session record is not plain JSON: %v", err)
+	}
+
+	got, err := svc.Get(ctx, &session.GetRequest{AppName: appName, UserID: userID, SessionID: "secret"})
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if card, _ := got.Session.State().Get("card"); card != "4343" {
+		t.Errorf("card = %v, want 4343", card)
+	}
+	if got.Session.Events().Len() != 1 || got.Session.Events().At(0).ID != "e1" {
+		t.Error("expected the encrypted event to be read")
+	}
+
+	synced, err := svc.Sync(ctx, &SyncRequest{AppName: appName, UserID: userID, SessionID: "secret", SinceStateVersion: 1})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if synced.StateSet["card"] != "4343" {
+		t.Errorf("synced state = %v, want the decrypted change", synced.StateSet)
+	}
+}
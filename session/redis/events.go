@@ -3,34 +3,72 @@ package redis
 import (
 	"context"
 	"iter"
+	"slices"
 	"sync"
 
-	"github.com/bytedance/sonic"
 	discardlog "github.com/kydenul/k-adk/internal/discard_log"
 	"github.com/kydenul/log"
 	"github.com/redis/go-redis/v9"
 	"google.golang.org/adk/session"
 )
 
-var _ session.Events = (*redisEvents)(nil)
+// eventPageSize is the number of events read per LRANGE by the windowed iterators.
+const eventPageSize = 100
+
+var (
+	_ session.Events = (*redisEvents)(nil)
+	_ WindowedEvents = (*redisEvents)(nil)
+)
+
+// WindowedEvents is implemented by the events of the sessions of
+// RedisSessionService. Unlike All, which reads the whole event list, its
+// iterators read the events they yield with partial LRANGE calls, page by page,
+// so an agent needing the last turns does not decode the whole history:
+//
+//	if events, ok := sess.Events().(redis.WindowedEvents); ok {
+//		for evt := range events.Recent(10) { ... }
+//	}
+//
+// Indexes are positions in the stored event list, oldest first; events trimmed
+// from Redis are not counted. On a branch view (see GetBranch), the events hidden
+// from the branch are skipped but keep their position. The iterators read Redis
+// live and do not update the events returned by Len and At.
+type WindowedEvents interface {
+	session.Events
+
+	// Range yields the events at positions [from, to), oldest first.
+	Range(from, to int) iter.Seq[*session.Event]
+	// Backward yields every event, newest first.
+	Backward() iter.Seq[*session.Event]
+	// Recent yields the last n events, oldest first.
+	Recent(n int) iter.Seq[*session.Event]
+}
 
 // redisEvents implements session.Events with live Redis reads.
 // It is thread-safe and uses sync.RWMutex to protect the cached events.
 type redisEvents struct {
 	client redis.UniversalClient
 	key    string
+	codec  eventCodec
 	logger log.Logger
 
 	// mu protects cached for concurrent access.
 	mu sync.RWMutex
 	// cached events loaded from Redis or provided at creation time.
 	cached []*session.Event
+
+	// Optional. filter keeps the events of a branch view, nil keeps all events.
+	filter func(*session.Event) bool
+	// Optional. recent keeps the last events of a Get with NumRecentEvents when the
+	// events are reloaded, 0 keeps all events.
+	recent int
 }
 
 func newRedisEvents(
 	events []*session.Event,
 	rdb redis.UniversalClient,
 	key string,
+	codec eventCodec,
 	logger log.Logger,
 ) *redisEvents {
 	if events == nil {
@@ -44,6 +82,7 @@ func newRedisEvents(
 	return &redisEvents{
 		client: rdb,
 		key:    key,
+		codec:  codec,
 		logger: logger,
 		cached: events,
 	}
@@ -52,28 +91,52 @@ func newRedisEvents(
 // refreshCache reloads events from Redis and updates the cache.
 // The caller must hold the write lock (mu.Lock()).
 func (e *redisEvents) refreshCacheLocked(ctx context.Context) {
-	if e.client == nil || e.key == "" {
+	if !e.live() {
 		return
 	}
 
-	eventData, err := e.client.LRange(ctx, e.key, 0, -1).Result()
+	// NOTE: Without a branch view, the last events are read alone
+	start := int64(0)
+	if e.recent > 0 && e.filter == nil {
+		start = -int64(e.recent)
+	}
+
+	events, _, err := e.load(ctx, start, -1)
 	if err != nil {
 		e.logger.Warnf("failed to load events from redis key %s: %v", e.key, err)
 		return
 	}
+	if e.recent > 0 && len(events) > e.recent {
+		events = events[len(events)-e.recent:]
+	}
+
+	e.cached = events
+}
+
+// load reads the events at the LRANGE positions [start, stop] and decodes those
+// kept by the filter. It also returns the number of stored events read.
+func (e *redisEvents) load(ctx context.Context, start, stop int64) ([]*session.Event, int, error) {
+	eventData, err := e.client.LRange(ctx, e.key, start, stop).Result()
+	if err != nil {
+		return nil, 0, err
+	}
 
 	events := make([]*session.Event, 0, len(eventData))
 	for i, ed := range eventData {
 		var evt session.Event
-		if err := sonic.Unmarshal([]byte(ed), &evt); err != nil {
-			e.logger.Warnf("failed to unmarshal event at index %d from key %s: %v", i, e.key, err)
+		if err := e.codec.unmarshal(ctx, ed, &evt); err != nil {
+			e.logger.Warnf("failed to unmarshal event at index %d from key %s: %v", start+int64(i), e.key, err)
+			continue
+		}
+
+		if e.filter != nil && !e.filter(&evt) {
 			continue
 		}
 
 		events = append(events, &evt)
 	}
 
-	e.cached = events
+	return events, len(eventData), nil
 }
 
 // All returns an iterator over all cached events.
@@ -118,3 +181,135 @@ func (e *redisEvents) At(idx int) *session.Event {
 
 	return e.cached[idx]
 }
+
+// live reports whether the events are read from Redis, rather than only cached.
+func (e *redisEvents) live() bool {
+	return e.client != nil && e.key != ""
+}
+
+// snapshot returns a copy of the cached events.
+func (e *redisEvents) snapshot() []*session.Event {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return slices.Clone(e.cached)
+}
+
+// Range yields the events at positions [from, to) of the stored list, oldest first,
+// reading them eventPageSize at a time.
+func (e *redisEvents) Range(from, to int) iter.Seq[*session.Event] {
+	from = max(from, 0)
+
+	return func(yield func(*session.Event) bool) {
+		if !e.live() {
+			cached := e.snapshot()
+			for _, evt := range cached[min(from, len(cached)):min(max(to, from), len(cached))] {
+				if !yield(evt) {
+					return
+				}
+			}
+			return
+		}
+
+		for start := from; start < to; start += eventPageSize {
+			stop := min(start+eventPageSize, to) - 1
+			events, read, err := e.load(context.Background(), int64(start), int64(stop))
+			if err != nil {
+				e.logger.Warnf("failed to load events %d-%d from redis key %s: %v", start, stop, e.key, err)
+				return
+			}
+
+			for _, evt := range events {
+				if !yield(evt) {
+					return
+				}
+			}
+
+			// NOTE: A short page is the end of the list
+			if read < stop-start+1 {
+				return
+			}
+		}
+	}
+}
+
+// Backward yields every event of the stored list, newest first, reading them
+// eventPageSize at a time.
+func (e *redisEvents) Backward() iter.Seq[*session.Event] {
+	return func(yield func(*session.Event) bool) {
+		if !e.live() {
+			cached := e.snapshot()
+			for _, evt := range slices.Backward(cached) {
+				if !yield(evt) {
+					return
+				}
+			}
+			return
+		}
+
+		ctx := context.Background()
+
+		// NOTE: Positions from the start stay valid while events are appended
+		length, err := e.client.LLen(ctx, e.key).Result()
+		if err != nil {
+			e.logger.Warnf("failed to get the length of redis key %s: %v", e.key, err)
+			return
+		}
+
+		for stop := length - 1; stop >= 0; stop -= eventPageSize {
+			start := max(stop-eventPageSize+1, 0)
+			events, _, err := e.load(ctx, start, stop)
+			if err != nil {
+				e.logger.Warnf("failed to load events %d-%d from redis key %s: %v", start, stop, e.key, err)
+				return
+			}
+
+			for _, evt := range slices.Backward(events) {
+				if !yield(evt) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Recent yields the last n events, oldest first. Without a branch view, they are
+// read with a single LRANGE; on a branch view, the last n events of the branch
+// are read backward.
+func (e *redisEvents) Recent(n int) iter.Seq[*session.Event] {
+	return func(yield func(*session.Event) bool) {
+		if n <= 0 {
+			return
+		}
+
+		var events []*session.Event
+		switch {
+		case !e.live():
+			events = e.snapshot()
+			events = events[max(len(events)-n, 0):]
+
+		case e.filter == nil:
+			var err error
+			events, _, err = e.load(context.Background(), -int64(n), -1)
+			if err != nil {
+				e.logger.Warnf("failed to load the last %d events from redis key %s: %v", n, e.key, err)
+				return
+			}
+
+		default:
+			for evt := range e.Backward() {
+				events = append(events, evt)
+				if len(events) == n {
+					break
+				}
+			}
+			slices.Reverse(events)
+		}
+
+		for _, evt := range events {
+			if !yield(evt) {
+				return
+			}
+		}
+	}
+}
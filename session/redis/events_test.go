@@ -0,0 +1,127 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"slices"
+	"testing"
+
+	"google.golang.org/adk/session"
+)
+
+// seqIDs collects the IDs of the events of seq.
+func seqIDs(seq iter.Seq[*session.Event]) []string {
+	var ids []string
+	for evt := range seq {
+		ids = append(ids, evt.ID)
+	}
+	return ids
+}
+
+func TestWindowedEvents(t *testing.T) {
+	const (
+		appName = "test_window_app"
+		userID  = "test_window_user"
+		count   = 250
+	)
+
+	svc, rdb := setupTestRedis(t)
+	t.Cleanup(func() {
+		cleanupTestKeys(t, rdb,
+			fmt.Sprintf("session:%s:*", appName),
+			fmt.Sprintf("events:%s:*", appName),
+		)
+	})
+
+	ctx := context.Background()
+	resp, err := svc.Create(ctx, &session.CreateRequest{AppName: appName, UserID: userID, SessionID: "window"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	want := make([]string, count)
+	for i := range count {
+		want[i] = fmt.Sprintf("e%03d", i)
+		branch := "root"
+		if i%2 == 1 {
+			branch = "root.other"
+		}
+		if err := svc.AppendEvent(ctx, resp.Session, &session.Event{ID: want[i], Author: "user", Branch: branch}); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+	}
+
+	got, err := svc.Get(ctx, &session.GetRequest{AppName: appName, UserID: userID, SessionID: "window"})
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	events, ok := got.Session.Events().(WindowedEvents)
+	if !ok {
+		t.Fatal("expected WindowedEvents")
+	}
+
+	t.Run("range", func(t *testing.T) {
+		if ids := seqIDs(events.Range(95, 205)); !slices.Equal(ids, want[95:205]) {
+			t.Errorf("Range(95, 205) = %v", ids)
+		}
+		if ids := seqIDs(events.Range(240, 1000)); !slices.Equal(ids, want[240:]) {
+			t.Errorf("Range past the end = %v", ids)
+		}
+		if ids := seqIDs(events.Range(10, 10)); len(ids) != 0 {
+			t.Errorf("empty Range = %v", ids)
+		}
+	})
+
+	t.Run("backward", func(t *testing.T) {
+		ids := seqIDs(events.Backward())
+		reversed := slices.Clone(want)
+		slices.Reverse(reversed)
+		if !slices.Equal(ids, reversed) {
+			t.Errorf("Backward yielded %d events, want %d newest first", len(ids), count)
+		}
+	})
+
+	t.Run("recent", func(t *testing.T) {
+		if ids := seqIDs(events.Recent(3)); !slices.Equal(ids, want[count-3:]) {
+			t.Errorf("Recent(3) = %v", ids)
+		}
+	})
+
+	t.Run("get recent events", func(t *testing.T) {
+		got, err := svc.Get(ctx, &session.GetRequest{
+			AppName: appName, UserID: userID, SessionID: "window", NumRecentEvents: 5,
+		})
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if ids := seqIDs(got.Session.Events().All()); !slices.Equal(ids, want[count-5:]) {
+			t.Errorf("All() after Get with NumRecentEvents = %v", ids)
+		}
+	})
+
+	t.Run("branch view", func(t *testing.T) {
+		got, err := svc.GetBranch(ctx, &session.GetRequest{AppName: appName, UserID: userID, SessionID: "window"}, "root")
+		if err != nil {
+			t.Fatalf("GetBranch failed: %v", err)
+		}
+		events := got.Session.Events().(WindowedEvents)
+		if ids := seqIDs(events.Recent(2)); !slices.Equal(ids, []string{want[count-4], want[count-2]}) {
+			t.Errorf("Recent(2) on the branch = %v", ids)
+		}
+	})
+}
+
+func TestWindowedEventsCached(t *testing.T) {
+	events := newRedisEvents([]*session.Event{{ID: "a"}, {ID: "b"}, {ID: "c"}}, nil, "", eventCodec{}, nil)
+
+	if ids := seqIDs(events.Range(1, 5)); !slices.Equal(ids, []string{"b", "c"}) {
+		t.Errorf("Range(1, 5) = %v", ids)
+	}
+	if ids := seqIDs(events.Backward()); !slices.Equal(ids, []string{"c", "b", "a"}) {
+		t.Errorf("Backward() = %v", ids)
+	}
+	if ids := seqIDs(events.Recent(2)); !slices.Equal(ids, []string{"b", "c"}) {
+		t.Errorf("Recent(2) = %v", ids)
+	}
+}
@@ -0,0 +1,241 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/log"
+)
+
+// defaultEventSizeBuckets are the default upper bounds of the event size
+// histogram, in bytes: 256B to 1MiB by powers of 4.
+var defaultEventSizeBuckets = []int64{256, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20}
+
+// defaultSessionByteThresholds are the default event list sizes flagged, in bytes.
+var defaultSessionByteThresholds = []int64{1 << 20, 8 << 20, 32 << 20}
+
+// EventSizeConfig configures an EventSizeTracker.
+type EventSizeConfig struct {
+	// Optional. Buckets are the upper bounds of the event size histogram, in
+	// bytes. Larger events fall into an overflow bucket. Default: 256B to 1MiB by
+	// powers of 4.
+	Buckets []int64
+
+	// Optional. SessionByteThresholds are the event list sizes, in bytes, whose
+	// crossing flags a session. Default: 1MiB, 8MiB and 32MiB.
+	SessionByteThresholds []int64
+
+	// Optional. OnOversized is called, synchronously, when the event list of a
+	// session crosses a threshold, e.g. to alert or to trim or offload the
+	// conversation.
+	OnOversized func(OversizedSession)
+
+	// Optional. Logger for logging. Falls back to `DiscardLog` if nil.
+	Logger log.Logger
+}
+
+// OversizedSession describes a session whose event list crossed a threshold.
+type OversizedSession struct {
+	AppName   string
+	UserID    string
+	SessionID string
+
+	// Events and Bytes are the length and serialized size of the event list.
+	Events int64
+	Bytes  int64
+
+	// Threshold is the crossed threshold, in bytes.
+	Threshold int64
+}
+
+// EventSizeBucket is a bucket of the event size histogram.
+type EventSizeBucket struct {
+	// UpperBound is the inclusive upper bound of the bucket, in bytes; 0 for the
+	// overflow bucket.
+	UpperBound int64 `json:"upper_bound"`
+	Count      int64 `json:"count"`
+}
+
+// EventSizeStats is a snapshot of the event size histogram.
+type EventSizeStats struct {
+	Buckets []EventSizeBucket `json:"buckets"`
+
+	// Count, Sum and Max are the number, total size and largest size of the events.
+	Count int64 `json:"count"`
+	Sum   int64 `json:"sum"`
+	Max   int64 `json:"max"`
+
+	// Oversized is the number of threshold crossings flagged.
+	Oversized int64 `json:"oversized"`
+}
+
+// EventSizeTracker records the serialized size of the appended events in a
+// histogram, and flags the sessions whose event list grows past byte thresholds,
+// so oversized conversations are caught before they degrade Redis. Attach it to
+// a RedisSessionService with WithEventSizeTracker.
+type EventSizeTracker struct {
+	logger log.Logger
+
+	buckets     []int64
+	thresholds  []int64
+	onOversized func(OversizedSession)
+
+	counts    []atomic.Int64
+	count     atomic.Int64
+	sum       atomic.Int64
+	max       atomic.Int64
+	oversized atomic.Int64
+}
+
+// NewEventSizeTracker creates an EventSizeTracker.
+func NewEventSizeTracker(cfg EventSizeConfig) (*EventSizeTracker, error) {
+	buckets := sortedOrDefault(cfg.Buckets, defaultEventSizeBuckets)
+	thresholds := sortedOrDefault(cfg.SessionByteThresholds, defaultSessionByteThresholds)
+	for _, bounds := range [][]int64{buckets, thresholds} {
+		if slices.ContainsFunc(bounds, func(b int64) bool { return b <= 0 }) {
+			return nil, errors.New("event size buckets and thresholds must be positive")
+		}
+	}
+
+	if cfg.Logger == nil {
+		cfg.Logger = &discardlog.DiscardLog{}
+	}
+
+	return &EventSizeTracker{
+		logger:      cfg.Logger,
+		buckets:     buckets,
+		thresholds:  thresholds,
+		onOversized: cfg.OnOversized,
+		counts:      make([]atomic.Int64, len(buckets)+1),
+	}, nil
+}
+
+// sortedOrDefault returns a sorted, deduplicated copy of values, or of def if
+// values is empty.
+func sortedOrDefault(values, def []int64) []int64 {
+	if len(values) == 0 {
+		values = def
+	}
+	return slices.Compact(slices.Sorted(slices.Values(values)))
+}
+
+// observe records the size of an appended event.
+func (t *EventSizeTracker) observe(size int64) {
+	i, _ := slices.BinarySearch(t.buckets, size)
+	t.counts[i].Add(1)
+	t.count.Add(1)
+	t.sum.Add(size)
+
+	for {
+		current := t.max.Load()
+		if size <= current || t.max.CompareAndSwap(current, size) {
+			return
+		}
+	}
+}
+
+// checkSession flags the session if its event list grew from before to after
+// bytes past a threshold. Only the highest crossed threshold is reported.
+func (t *EventSizeTracker) checkSession(appName, userID, sessionID string, events, before, after int64) {
+	var crossed int64
+	for _, threshold := range t.thresholds {
+		if before < threshold && after >= threshold {
+			crossed = threshold
+		}
+	}
+	if crossed == 0 {
+		return
+	}
+
+	t.oversized.Add(1)
+	t.logger.Warnf("session event list exceeds %d bytes: app=%s, user=%s, session=%s, events=%d, bytes=%d",
+		crossed, appName, userID, sessionID, events, after)
+
+	if t.onOversized != nil {
+		t.onOversized(OversizedSession{
+			AppName:   appName,
+			UserID:    userID,
+			SessionID: sessionID,
+			Events:    events,
+			Bytes:     after,
+			Threshold: crossed,
+		})
+	}
+}
+
+// Stats returns a snapshot of the event size histogram.
+func (t *EventSizeTracker) Stats() EventSizeStats {
+	stats := EventSizeStats{
+		Buckets:   make([]EventSizeBucket, len(t.counts)),
+		Count:     t.count.Load(),
+		Sum:       t.sum.Load(),
+		Max:       t.max.Load(),
+		Oversized: t.oversized.Load(),
+	}
+	for i := range t.counts {
+		if i < len(t.buckets) {
+			stats.Buckets[i].UpperBound = t.buckets[i]
+		}
+		stats.Buckets[i].Count = t.counts[i].Load()
+	}
+
+	return stats
+}
+
+// String formats the histogram, e.g. for logs.
+func (s EventSizeStats) String() string {
+	var b []byte
+	for _, bucket := range s.Buckets {
+		if bucket.UpperBound == 0 {
+			b = fmt.Appendf(b, "+Inf:%d", bucket.Count)
+			continue
+		}
+		b = fmt.Appendf(b, "<=%d:%d ", bucket.UpperBound, bucket.Count)
+	}
+	return fmt.Sprintf("count=%d, sum=%d, max=%d, oversized=%d, buckets=[%s]",
+		s.Count, s.Sum, s.Max, s.Oversized, b)
+}
+
+// trackEventSize records the size of an event appended to a session, and checks
+// the size of its event list, counted in the event bytes key. The counter of a
+// session appended to before it was tracked is seeded with the memory used by
+// its event list. The counter expires with the session, in ttl.
+func (s *RedisSessionService) trackEventSize(
+	ctx context.Context,
+	appName, userID, sessionID string,
+	size, length int64,
+	ttl time.Duration,
+) {
+	s.eventSizes.observe(size)
+
+	key := buildEventBytesKey(appName, userID, sessionID)
+	total, err := s.rdb.IncrBy(ctx, key, size).Result()
+	if err != nil {
+		s.logger.Warnf("failed to count event bytes of session %s: %v", sessionID, err)
+		return
+	}
+
+	before := total - size
+	if total == size && length > 1 {
+		evKey := buildEventsKey(appName, userID, sessionID)
+		if used, err := s.rdb.MemoryUsage(ctx, evKey, 0).Result(); err == nil && used > size {
+			total, err = s.rdb.IncrBy(ctx, key, used-size).Result()
+			if err != nil {
+				s.logger.Warnf("failed to seed event bytes of session %s: %v", sessionID, err)
+				return
+			}
+			before = 0
+		}
+	}
+
+	if err := s.rdb.Expire(ctx, key, ttl).Err(); err != nil {
+		s.logger.Warnf("failed to set expire for event bytes key %s: %v", key, err)
+	}
+
+	s.eventSizes.checkSession(appName, userID, sessionID, length, before, total)
+}
@@ -0,0 +1,104 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/adk/session"
+)
+
+func TestEventSizeTrackerHistogram(t *testing.T) {
+	tracker, err := NewEventSizeTracker(EventSizeConfig{Buckets: []int64{1000, 100}})
+	if err != nil {
+		t.Fatalf("NewEventSizeTracker failed: %v", err)
+	}
+
+	for _, size := range []int64{10, 100, 101, 5000} {
+		tracker.observe(size)
+	}
+
+	stats := tracker.Stats()
+	want := []EventSizeBucket{{UpperBound: 100, Count: 2}, {UpperBound: 1000, Count: 1}, {Count: 1}}
+	if fmt.Sprint(stats.Buckets) != fmt.Sprint(want) {
+		t.Errorf("expected buckets %v, got %v", want, stats.Buckets)
+	}
+	if stats.Count != 4 || stats.Sum != 5211 || stats.Max != 5000 {
+		t.Errorf("unexpected stats: %s", stats)
+	}
+
+	if _, err := NewEventSizeTracker(EventSizeConfig{SessionByteThresholds: []int64{0}}); err == nil {
+		t.Error("expected an error for a non-positive threshold")
+	}
+}
+
+func TestEventSizeTrackerThresholds(t *testing.T) {
+	var flagged []OversizedSession
+	tracker, _ := NewEventSizeTracker(EventSizeConfig{
+		SessionByteThresholds: []int64{100, 200, 300},
+		OnOversized:           func(o OversizedSession) { flagged = append(flagged, o) },
+	})
+
+	tracker.checkSession("app", "u1", "s1", 1, 0, 50)
+	tracker.checkSession("app", "u1", "s1", 2, 50, 150)
+	tracker.checkSession("app", "u1", "s1", 3, 150, 190)
+	tracker.checkSession("app", "u1", "s1", 4, 190, 350)
+
+	if len(flagged) != 2 || flagged[0].Threshold != 100 || flagged[1].Threshold != 300 {
+		t.Errorf("expected thresholds 100 then 300 flagged, got %+v", flagged)
+	}
+	if tracker.Stats().Oversized != 2 {
+		t.Errorf("expected 2 oversized, got %d", tracker.Stats().Oversized)
+	}
+}
+
+func TestAppendEventTracksSizes(t *testing.T) {
+	const (
+		appName = "test_evsize_app"
+		userID  = "test_evsize_user"
+	)
+
+	_, rdb := setupTestRedis(t)
+	t.Cleanup(func() {
+		cleanupTestKeys(t, rdb,
+			fmt.Sprintf("session:%s:*", appName),
+			fmt.Sprintf("events:%s:*", appName),
+			fmt.Sprintf("statelog:%s:*", appName),
+			fmt.Sprintf("evbytes:%s:*", appName),
+		)
+	})
+
+	// NOTE: The threshold is crossed by the third event, whatever the stored size
+	size := storedEventSize(t, rdb, appName, userID, 2000)
+
+	var flagged []OversizedSession
+	tracker, _ := NewEventSizeTracker(EventSizeConfig{
+		SessionByteThresholds: []int64{2*size + size/2},
+		OnOversized:           func(o OversizedSession) { flagged = append(flagged, o) },
+	})
+
+	svc, err := NewRedisSessionService(rdb, WithTTL(30*time.Second), WithEventSizeTracker(tracker))
+	if err != nil {
+		t.Fatalf("NewRedisSessionService failed: %v", err)
+	}
+
+	ctx := context.Background()
+	resp, err := svc.Create(ctx, &session.CreateRequest{AppName: appName, UserID: userID})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	for i := range 3 {
+		if err := svc.AppendEvent(ctx, resp.Session, textEvent(2000)); err != nil {
+			t.Fatalf("AppendEvent %d failed: %v", i, err)
+		}
+	}
+
+	if stats := tracker.Stats(); stats.Count != 3 || stats.Max < 2000 {
+		t.Errorf("unexpected stats: %s", stats)
+	}
+	if len(flagged) != 1 || flagged[0].SessionID != resp.Session.ID() || flagged[0].Events != 3 {
+		t.Errorf("expected the session flagged once at 3 events, got %+v", flagged)
+	}
+}
@@ -0,0 +1,252 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// expiryKeyPrefix prefixes the shadow keys expiring shortly before their session.
+	expiryKeyPrefix = "sessexp:"
+
+	// expiryClaimPrefix prefixes the keys electing the watcher persisting a session.
+	expiryClaimPrefix = "sessexp-claim:"
+
+	// expiryClaimTTL is how long a watcher holds the claim on an expiring session.
+	expiryClaimTTL = 10 * time.Second
+
+	// expiredChannels matches the expired keyevent channels of every database.
+	expiredChannels = "__keyevent@*__:expired"
+
+	defaultExpiryLead = time.Minute
+)
+
+// ErrExpiryPersistenceDisabled is returned by WatchExpiry when the service was not
+// created with WithExpiryPersistence.
+var ErrExpiryPersistenceDisabled = errors.New("expiry persistence is not enabled")
+
+// WithExpiryPersistence persists every session one last time before it expires
+// from Redis, so the persister copy reflects its latest state even if no event
+// was appended recently. Run WatchExpiry to do so.
+//
+// Redis reports expired keys only once they are gone, so every session gets a
+// shadow key, "sessexp:{app}:{user}:{session}", expiring lead before it (default:
+// 1 minute; at most half the TTL). When the shadow key expires, the session is
+// still there to be read and persisted. Redis expires keys with some delay on a
+// large keyspace, so keep lead well above it.
+func WithExpiryPersistence(lead time.Duration) ServiceOption {
+	return func(s *RedisSessionService) {
+		if lead <= 0 {
+			lead = defaultExpiryLead
+		}
+		s.expiryLead = lead
+	}
+}
+
+// ExpiryWatcherConfig configures WatchExpiry.
+type ExpiryWatcherConfig struct {
+	// Optional. ConfigureServer enables the expired keyevent notifications with
+	// CONFIG SET notify-keyspace-events. Leave it unset where CONFIG is denied,
+	// e.g. on managed Redis, and enable them in the server configuration ("Ex").
+	ConfigureServer bool
+}
+
+func buildExpiryKey(appName, userID, sessionID string) string {
+	return fmt.Sprintf("%s%s:%s:%s", expiryKeyPrefix, appName, userID, sessionID)
+}
+
+// expiryShadowTTL returns the TTL of the shadow key of a session expiring in ttl.
+func (s *RedisSessionService) expiryShadowTTL(ttl time.Duration) time.Duration {
+	return max(ttl-s.expiryLead, ttl/2)
+}
+
+// armExpiry sets the shadow key of a session just written with ttl, unless the
+// session does not expire. A failure is logged: the session is then not
+// persisted on expiry.
+func (s *RedisSessionService) armExpiry(ctx context.Context, appName, userID, sessionID string, ttl time.Duration) {
+	if s.expiryLead <= 0 || ttl <= 0 {
+		return
+	}
+
+	key := buildExpiryKey(appName, userID, sessionID)
+	if err := s.rdb.Set(ctx, key, 1, s.expiryShadowTTL(ttl)).Err(); err != nil {
+		s.logger.Warnf("failed to set expiry shadow key %s: %v", key, err)
+	}
+}
+
+// WatchExpiry persists the sessions about to expire from Redis, see
+// WithExpiryPersistence, until ctx is done. It subscribes to the expired keyevent
+// notifications of every database, on every master of a cluster; masters added
+// afterwards are not watched. Run it on one or more instances: each expiring
+// session is persisted by a single watcher.
+//
+// Only the session (state, tags, last update time) is persisted: its events were
+// persisted as they were appended.
+func (s *RedisSessionService) WatchExpiry(ctx context.Context, cfg ExpiryWatcherConfig) error {
+	if s.expiryLead <= 0 {
+		return ErrExpiryPersistenceDisabled
+	}
+	if s.persister == nil {
+		return ErrNoPersister
+	}
+
+	var (
+		mu      sync.Mutex
+		clients []*redis.Client
+	)
+	if cc, ok := s.rdb.(*redis.ClusterClient); ok {
+		// NOTE: Keyspace notifications are local to each node
+		err := cc.ForEachMaster(ctx, func(_ context.Context, node *redis.Client) error {
+			mu.Lock()
+			defer mu.Unlock()
+			clients = append(clients, node)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list cluster masters: %w", err)
+		}
+	} else if c, ok := s.rdb.(*redis.Client); ok {
+		clients = append(clients, c)
+	} else {
+		return fmt.Errorf("unsupported redis client %T", s.rdb)
+	}
+
+	pubsubs := make([]*redis.PubSub, 0, len(clients))
+	defer func() {
+		for _, pubsub := range pubsubs {
+			_ = pubsub.Close()
+		}
+	}()
+
+	for _, c := range clients {
+		if cfg.ConfigureServer {
+			if err := enableExpiredEvents(ctx, c); err != nil {
+				return err
+			}
+		}
+
+		pubsub := c.PSubscribe(ctx, expiredChannels)
+		if _, err := pubsub.Receive(ctx); err != nil {
+			_ = pubsub.Close()
+			return fmt.Errorf("failed to subscribe to expired keys: %w", err)
+		}
+		pubsubs = append(pubsubs, pubsub)
+	}
+
+	s.logger.Infof("watching session expiry: nodes=%d, lead=%s", len(pubsubs), s.expiryLead)
+
+	var wg sync.WaitGroup
+	for _, pubsub := range pubsubs {
+		wg.Go(func() {
+			msgs := pubsub.Channel()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+
+				case msg, ok := <-msgs:
+					if !ok {
+						return
+					}
+					if rest, ok := strings.CutPrefix(msg.Payload, expiryKeyPrefix); ok {
+						s.persistExpiring(ctx, rest)
+					}
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// enableExpiredEvents adds the expired keyevent notifications ("Ex") to the
+// notify-keyspace-events of a node, keeping the classes already enabled.
+func enableExpiredEvents(ctx context.Context, c *redis.Client) error {
+	current, err := c.ConfigGet(ctx, "notify-keyspace-events").Result()
+	if err != nil {
+		return fmt.Errorf("failed to get notify-keyspace-events: %w", err)
+	}
+
+	flags := current["notify-keyspace-events"]
+	for _, flag := range []string{"E", "x"} {
+		// NOTE: "A" is an alias for the classes including "x"
+		if !strings.Contains(flags, flag) && (flag != "x" || !strings.Contains(flags, "A")) {
+			flags += flag
+		}
+	}
+
+	if err := c.ConfigSet(ctx, "notify-keyspace-events", flags).Err(); err != nil {
+		return fmt.Errorf("failed to set notify-keyspace-events: %w", err)
+	}
+
+	return nil
+}
+
+// persistExpiring persists the session of an expired shadow key, rest being the
+// "{app}:{user}:{session}" part of the key shared with the session key. When the
+// session TTL was extended since the shadow key was set, e.g. by a state write,
+// the shadow key is set again for the new expiry.
+func (s *RedisSessionService) persistExpiring(ctx context.Context, rest string) {
+	claimed, err := s.rdb.SetNX(ctx, expiryClaimPrefix+rest, 1, expiryClaimTTL).Result()
+	if err != nil {
+		s.logger.Warnf("failed to claim expiring session %s: %v", rest, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	key := "session:" + rest
+
+	pipe := s.rdb.Pipeline()
+	getCmd := pipe.Get(ctx, key)
+	ttlCmd := pipe.PTTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		s.logger.Warnf("failed to read expiring session %s: %v", key, err)
+		return
+	}
+
+	data, err := getCmd.Bytes()
+	if errors.Is(err, redis.Nil) {
+		// NOTE: Deleted, or expired before its shadow key was processed
+		s.logger.Debugf("expiring session %s already gone", key)
+		return
+	}
+	if err != nil {
+		s.logger.Warnf("failed to get expiring session %s: %v", key, err)
+		return
+	}
+
+	var storable storableSession
+	if err := sonic.Unmarshal(data, &storable); err != nil {
+		s.logger.Warnf("failed to unmarshal expiring session %s: %v", key, err)
+		return
+	}
+	if err := s.openStored(ctx, &storable); err != nil {
+		s.logger.Warnf("failed to decrypt expiring session %s: %v", key, err)
+		return
+	}
+
+	if err := s.persister.PersistSession(ctx, s.listedSession(&storable)); err != nil {
+		s.logger.Warnf("failed to persist expiring session %s: %v", storable.ID, err)
+	} else {
+		s.logger.Infof("expiring session persisted: session=%s", storable.ID)
+	}
+
+	// NOTE: The session outlives its shadow key, watch its new expiry
+	if ttl := ttlCmd.Val(); ttl > 0 {
+		if shadowTTL := ttl - s.expiryLead; shadowTTL > expiryClaimTTL {
+			if err := s.rdb.Set(ctx, expiryKeyPrefix+rest, 1, shadowTTL).Err(); err != nil {
+				s.logger.Warnf("failed to set expiry shadow key of %s: %v", key, err)
+			}
+		}
+	}
+}
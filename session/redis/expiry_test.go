@@ -0,0 +1,117 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"testing"
+	"time"
+
+	"google.golang.org/adk/session"
+)
+
+func TestExpiryShadowTTL(t *testing.T) {
+	tests := []struct {
+		ttl, lead, want time.Duration
+	}{
+		{time.Hour, time.Minute, 59 * time.Minute},
+		{time.Minute, time.Minute, 30 * time.Second},
+		{10 * time.Second, time.Minute, 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		s := &RedisSessionService{expiryLead: tt.lead}
+		if got := s.expiryShadowTTL(tt.ttl); got != tt.want {
+			t.Errorf("expiryShadowTTL(%s) with lead %s = %s, want %s", tt.ttl, tt.lead, got, tt.want)
+		}
+	}
+}
+
+func TestArmExpiryWithoutTTL(t *testing.T) {
+	svc, rdb := setupTestRedis(t, WithExpiryPersistence(time.Minute))
+	ctx := context.Background()
+
+	key := buildExpiryKey("test_expiry_app", "test_expiry_user", "no-ttl")
+	t.Cleanup(func() { rdb.Del(context.Background(), key) })
+
+	svc.armExpiry(ctx, "test_expiry_app", "test_expiry_user", "no-ttl", 0)
+	if n, err := rdb.Exists(ctx, key).Result(); err != nil || n != 0 {
+		t.Errorf("expected no shadow key for a session without TTL, got %d, %v", n, err)
+	}
+}
+
+// expiryRecorder is a persister sending the states of the persisted sessions.
+type expiryRecorder struct {
+	persisted chan map[string]any
+}
+
+func (r *expiryRecorder) PersistSession(_ context.Context, sess session.Session) error {
+	r.persisted <- maps.Collect(sess.State().All())
+	return nil
+}
+
+func (r *expiryRecorder) PersistEvent(context.Context, session.Session, *session.Event) error {
+	return nil
+}
+
+func (r *expiryRecorder) DeleteSession(context.Context, string, string, string) error { return nil }
+
+func (r *expiryRecorder) Close() error { return nil }
+
+func TestWatchExpiry(t *testing.T) {
+	const (
+		appName = "test_expiry_app"
+		userID  = "test_expiry_user"
+	)
+
+	recorder := &expiryRecorder{persisted: make(chan map[string]any, 10)}
+	svc, rdb := setupTestRedis(t, WithTTL(3*time.Second), WithExpiryPersistence(2*time.Second),
+		WithPersister(recorder))
+	t.Cleanup(func() {
+		cleanupTestKeys(t, rdb,
+			fmt.Sprintf("session:%s:*", appName),
+			fmt.Sprintf("sessexp*:%s:*", appName),
+		)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := (&RedisSessionService{}).WatchExpiry(ctx, ExpiryWatcherConfig{}); !errors.Is(
+		err, ErrExpiryPersistenceDisabled) {
+		t.Fatalf("expected ErrExpiryPersistenceDisabled, got %v", err)
+	}
+
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	watchErr := make(chan error, 1)
+	go func() { watchErr <- svc.WatchExpiry(watchCtx, ExpiryWatcherConfig{ConfigureServer: true}) }()
+	time.Sleep(200 * time.Millisecond)
+
+	resp, err := svc.Create(ctx, &session.CreateRequest{AppName: appName, UserID: userID, SessionID: "s1"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	<-recorder.persisted // Create
+
+	// NOTE: A state write without event, only persisted on expiry
+	if err := resp.Session.State().Set("draft", "hello"); err != nil {
+		t.Fatalf("State().Set failed: %v", err)
+	}
+
+	select {
+	case state := <-recorder.persisted:
+		if state["draft"] != "hello" {
+			t.Errorf("expected the latest state, got %v", state)
+		}
+	case err := <-watchErr:
+		t.Skipf("expiry watcher stopped, keyspace notifications unavailable: %v", err)
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the session to be persisted on expiry")
+	}
+
+	stopWatch()
+	if err := <-watchErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+
+	ksess "github.com/kydenul/k-adk/session"
+)
+
+var _ ksess.HealthChecker = (*RedisSessionService)(nil)
+
+// Ping checks that Redis is reachable.
+func (s *RedisSessionService) Ping(ctx context.Context) error {
+	if err := s.rdb.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("redis ping failed: %w", err)
+	}
+	return nil
+}
+
+// Healthy checks that Redis and, with WithReadClient, the read client are
+// reachable. The persister is not checked: report it on its own.
+func (s *RedisSessionService) Healthy(ctx context.Context) error {
+	if err := s.Ping(ctx); err != nil {
+		return err
+	}
+	if s.reader != nil && s.reader != s.rdb {
+		if err := s.reader.Ping(ctx).Err(); err != nil {
+			return fmt.Errorf("redis read client ping failed: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+
+	ksess "github.com/kydenul/k-adk/session"
+	"google.golang.org/adk/session"
+)
+
+var _ ksess.Importer = (*RedisSessionService)(nil)
+
+// ImportSession writes a session as is, keeping the timestamps of its events and
+// its tags, under the service TTL, and syncs it to the persister. Events beyond the
+// trim policy are counted as trimmed, as for the sessions restored from the loader.
+// It fails with ErrSessionExists if the session is in Redis. It implements
+// ksess.Importer.
+func (s *RedisSessionService) ImportSession(ctx context.Context, stored *ksess.StoredSession) error {
+	written, err := s.writeStored(ctx, stored.AppName, stored.UserID, stored.ID, stored)
+	if err != nil {
+		return err
+	}
+	if !written {
+		return fmt.Errorf("%w: %s", ErrSessionExists, stored.ID)
+	}
+
+	// NOTE: Persist to PostgreSQL if persister is configured
+	if s.persister != nil {
+		resp, err := s.get(ctx, &session.GetRequest{
+			AppName: stored.AppName, UserID: stored.UserID, SessionID: stored.ID, NumRecentEvents: 1,
+		}, nil)
+		if err != nil {
+			return fmt.Errorf("failed to get imported session: %w", err)
+		}
+
+		if err := s.persister.PersistSession(ctx, resp.Session); err != nil {
+			s.logger.Warnf("failed to persist session %s to postgres: %v", stored.ID, err)
+		}
+		for _, evt := range stored.Events {
+			if err := s.persister.PersistEvent(ctx, resp.Session, evt); err != nil {
+				s.logger.Warnf("failed to persist event %s of session %s to postgres: %v", evt.ID, stored.ID, err)
+			}
+		}
+	}
+
+	s.logger.Infof("session imported: app=%s, user=%s, session=%s, events=%d",
+		stored.AppName, stored.UserID, stored.ID, len(stored.Events))
+
+	s.notify(ctx, Notification{
+		Type: NotificationSessionCreated, AppName: stored.AppName, UserID: stored.UserID, SessionID: stored.ID,
+	})
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	ksess "github.com/kydenul/k-adk/session"
+	"google.golang.org/adk/session"
+)
+
+func TestImportSession(t *testing.T) {
+	svc, rdb := setupTestRedis(t)
+	defer cleanupTestKeys(t, rdb, "session:import_app:*", "events:import_app:*")
+
+	ctx := context.Background()
+
+	at := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	evt := session.NewEvent("inv-1")
+	evt.ID, evt.Timestamp, evt.Author = "e1", at, "user"
+	dump := ksess.NewDump(&ksess.StoredSession{
+		ID: "s1", AppName: "import_app", UserID: "u1",
+		State:          map[string]any{"lang": "fr"},
+		Tags:           map[string]string{"channel": "web"},
+		Events:         []*session.Event{evt},
+		LastUpdateTime: at,
+	})
+
+	sess, err := ksess.Import(ctx, svc, dump)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if sess.Events().Len() != 1 || !sess.Events().At(0).Timestamp.Equal(at) {
+		t.Errorf("expected the event with its timestamp, got %d events", sess.Events().Len())
+	}
+	if ksess.TagsOf(sess)["channel"] != "web" {
+		t.Errorf("expected the tags to be imported, got %v", ksess.TagsOf(sess))
+	}
+
+	list, err := svc.List(ctx, &session.ListRequest{AppName: "import_app", UserID: "u1"})
+	if err != nil || len(list.Sessions) != 1 {
+		t.Errorf("expected the session to be indexed, got %v, %v", list, err)
+	}
+
+	if _, err := ksess.Import(ctx, svc, dump); !errors.Is(err, ErrSessionExists) {
+		t.Errorf("expected ErrSessionExists, got %v", err)
+	}
+}
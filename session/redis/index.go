@@ -0,0 +1,570 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"iter"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// indexRegistryKey is a hash of app name to the bucket count of its index.
+	indexRegistryKey = "session-index:apps"
+
+	// indexMemberSep separates the user and session IDs of an index member.
+	indexMemberSep = "\x00"
+
+	// indexBatchSize is the page size of index scans and migrations.
+	indexBatchSize = 1000
+)
+
+// ErrIndexNotBucketed is returned by the index admin operations of a service
+// without WithBucketedIndex.
+var ErrIndexNotBucketed = errors.New("session index is not bucketed")
+
+// WithBucketedIndex replaces the per-user session index sets with buckets: every
+// app has a fixed number of sorted sets, each holding the sessions of the users
+// hashed to it as "{userID}\x00{sessionID}" members. List and Delete stay
+// O(log bucket), and admin operations (AllSessions, CountSessions, PruneIndex)
+// walk the buckets instead of SCANning the keyspace.
+//
+// The bucket keys are "session-index:{app:N}", so buckets spread across the hash
+// slots of a Redis Cluster. The bucket count of an app is recorded in the registry
+// hash "session-index:apps" on first use and cannot change afterwards; buckets <= 0
+// disables the option. Existing per-user sets are read as a fallback until
+// MigrateIndex has moved them.
+//
+// Buckets have no TTL: entries of expired sessions are removed by List and
+// PruneIndex.
+func WithBucketedIndex(buckets int) ServiceOption {
+	return func(s *RedisSessionService) { s.buckets = buckets }
+}
+
+// SessionRef identifies a session of an app.
+type SessionRef struct {
+	UserID    string
+	SessionID string
+}
+
+func buildBucketKey(appName string, bucket int) string {
+	return fmt.Sprintf("session-index:{%s:%d}", appName, bucket)
+}
+
+func bucketOf(userID string, buckets int) int {
+	h := fnv.New32a()
+	h.Write([]byte(userID))
+	return int(h.Sum32() % uint32(buckets))
+}
+
+func buildIndexMember(userID, sessionID string) string {
+	return userID + indexMemberSep + sessionID
+}
+
+func parseIndexMember(member string) (SessionRef, bool) {
+	userID, sessionID, ok := strings.Cut(member, indexMemberSep)
+	return SessionRef{UserID: userID, SessionID: sessionID}, ok
+}
+
+// appBuckets returns the bucket count of appName, registering it on first use.
+func (s *RedisSessionService) appBuckets(ctx context.Context, appName string) (int, error) {
+	if n, ok := s.bucketCounts.Load(appName); ok {
+		return n.(int), nil
+	}
+
+	if err := s.rdb.HSetNX(ctx, indexRegistryKey, appName, s.buckets).Err(); err != nil {
+		return 0, fmt.Errorf("failed to register app index: %w", err)
+	}
+	registered, err := s.rdb.HGet(ctx, indexRegistryKey, appName).Int()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get app index: %w", err)
+	}
+
+	if registered != s.buckets {
+		s.logger.Warnf("app %s index has %d buckets, ignoring configured %d",
+			appName, registered, s.buckets)
+	}
+	s.bucketCounts.Store(appName, registered)
+
+	return registered, nil
+}
+
+func (s *RedisSessionService) bucketKey(
+	ctx context.Context,
+	appName, userID string,
+) (string, error) {
+	n, err := s.appBuckets(ctx, appName)
+	if err != nil {
+		return "", err
+	}
+	return buildBucketKey(appName, bucketOf(userID, n)), nil
+}
+
+// indexAdd adds a session to the index of its user.
+func (s *RedisSessionService) indexAdd(
+	ctx context.Context,
+	appName, userID, sessionID string,
+	ttl time.Duration,
+) error {
+	if s.buckets <= 0 {
+		indexKey := buildSessionIndexKey(appName, userID)
+		if err := s.rdb.SAdd(ctx, indexKey, sessionID).Err(); err != nil {
+			return err
+		}
+		if err := s.rdb.Expire(ctx, indexKey, s.indexTTL(ttl)).Err(); err != nil {
+			s.logger.Warnf("failed to set expire for index key %s: %v", indexKey, err)
+		}
+		return nil
+	}
+
+	key, err := s.bucketKey(ctx, appName, userID)
+	if err != nil {
+		return err
+	}
+
+	// Score 0: members are ordered by "{userID}\x00{sessionID}" for range-by-lex
+	return s.rdb.ZAdd(ctx, key, redis.Z{Member: buildIndexMember(userID, sessionID)}).Err()
+}
+
+// indexRemove queues the removal of a session from the index of its user.
+func (s *RedisSessionService) indexRemove(
+	ctx context.Context,
+	pipe redis.Pipeliner,
+	appName, userID, sessionID string,
+) error {
+	// Also removed in bucketed mode: the session may not be migrated yet
+	pipe.SRem(ctx, buildSessionIndexKey(appName, userID), sessionID)
+
+	if s.buckets <= 0 {
+		return nil
+	}
+
+	key, err := s.bucketKey(ctx, appName, userID)
+	if err != nil {
+		return err
+	}
+	pipe.ZRem(ctx, key, buildIndexMember(userID, sessionID))
+
+	return nil
+}
+
+// indexSessionIDs returns the session IDs of a user.
+func (s *RedisSessionService) indexSessionIDs(
+	ctx context.Context,
+	appName, userID string,
+) ([]string, error) {
+	legacyKey := buildSessionIndexKey(appName, userID)
+	if s.buckets <= 0 {
+		return s.rdb.SMembers(ctx, legacyKey).Result()
+	}
+
+	key, err := s.bucketKey(ctx, appName, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sessions not migrated yet are still in the per-user set
+	pipe := s.rdb.Pipeline()
+	prefix := userID + indexMemberSep
+	bucketCmd := pipe.ZRangeByLex(ctx, key, &redis.ZRangeBy{
+		Min: "[" + prefix,
+		Max: "(" + userID + "\x01",
+	})
+	legacyCmd := pipe.SMembers(ctx, legacyKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	sessionIDs := legacyCmd.Val()
+	for _, m := range bucketCmd.Val() {
+		if id := strings.TrimPrefix(m, prefix); !slices.Contains(legacyCmd.Val(), id) {
+			sessionIDs = append(sessionIDs, id)
+		}
+	}
+
+	return sessionIDs, nil
+}
+
+// pruneBucket removes the members of a bucket whose session keys no longer exist,
+// like cleanStaleSessionIDs for the buckets.
+func (s *RedisSessionService) pruneBucket(
+	ctx context.Context,
+	appName, bucketKey string,
+	members []string,
+) (int, error) {
+	refs := make([]string, 0, len(members))
+	sessionKeys := make([]string, 0, len(members))
+	for _, m := range members {
+		ref, ok := parseIndexMember(m)
+		if !ok {
+			continue
+		}
+		refs = append(refs, m)
+		sessionKeys = append(sessionKeys, buildSessionKey(appName, ref.UserID, ref.SessionID))
+	}
+
+	return s.removeStaleMembers(ctx, bucketKey, true, refs, sessionKeys)
+}
+
+// removeStaleMembers removes from the index at key, a bucket if bucketed, the
+// members whose session keys, at the same positions, no longer exist. It returns
+// the number removed.
+//
+// NOTE: The session keys are spread over the slots of a cluster, so the members are
+// checked and removed client-side, and checked again after the removal: a member of
+// a session created concurrently, whose key is written before it is indexed, is
+// added back.
+func (s *RedisSessionService) removeStaleMembers(
+	ctx context.Context,
+	key string,
+	bucketed bool,
+	members, sessionKeys []string,
+) (int, error) {
+	missing, err := s.missingKeys(ctx, sessionKeys)
+	if err != nil || len(missing) == 0 {
+		return 0, err
+	}
+
+	stale := make([]string, len(missing))
+	staleKeys := make([]string, len(missing))
+	for i, j := range missing {
+		stale[i], staleKeys[i] = members[j], sessionKeys[j]
+	}
+
+	var removed int64
+	if bucketed {
+		removed, err = s.rdb.ZRem(ctx, key, toAny(stale)...).Result()
+	} else {
+		removed, err = s.rdb.SRem(ctx, key, toAny(stale)...).Result()
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	gone, err := s.missingKeys(ctx, staleKeys)
+	if err != nil {
+		return int(removed), err
+	}
+	recreated := make([]any, 0, len(stale)-len(gone))
+	for i, m := range stale {
+		if !slices.Contains(gone, i) {
+			recreated = append(recreated, m)
+		}
+	}
+	if len(recreated) == 0 {
+		return int(removed), nil
+	}
+
+	if bucketed {
+		zs := make([]redis.Z, 0, len(recreated))
+		for _, m := range recreated {
+			zs = append(zs, redis.Z{Member: m})
+		}
+		err = s.rdb.ZAdd(ctx, key, zs...).Err()
+	} else {
+		err = s.rdb.SAdd(ctx, key, recreated...).Err()
+	}
+	if err != nil {
+		return int(removed), fmt.Errorf("failed to index recreated sessions: %w", err)
+	}
+
+	return max(int(removed)-len(recreated), 0), nil
+}
+
+// missingKeys returns the positions of the keys that do not exist, in order.
+func (s *RedisSessionService) missingKeys(ctx context.Context, keys []string) ([]int, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	pipe := s.rdb.Pipeline()
+	cmds := make([]*redis.IntCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.Exists(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	var missing []int
+	for i, cmd := range cmds {
+		if cmd.Val() == 0 {
+			missing = append(missing, i)
+		}
+	}
+	return missing, nil
+}
+
+func toAny(values []string) []any {
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
+
+// cleanStaleIndex removes stale session IDs of a user from the index.
+func (s *RedisSessionService) cleanStaleIndex(
+	ctx context.Context,
+	appName, userID string,
+	staleIDs []string,
+) {
+	if s.buckets <= 0 {
+		s.cleanStaleSessionIDs(ctx, appName, userID, staleIDs)
+		return
+	}
+
+	key, err := s.bucketKey(ctx, appName, userID)
+	if err != nil {
+		s.logger.Warnf("failed to clean up stale session IDs from index: %v", err)
+		return
+	}
+
+	members := make([]string, 0, len(staleIDs))
+	for _, id := range staleIDs {
+		members = append(members, buildIndexMember(userID, id))
+	}
+
+	removed, err := s.pruneBucket(ctx, appName, key, members)
+	if err != nil {
+		s.logger.Warnf("failed to clean up stale session IDs from index: %v", err)
+		return
+	}
+	if removed > 0 {
+		s.logger.Infof("cleaned up %d stale session IDs from index", removed)
+	}
+
+	// Sessions not migrated yet
+	s.cleanStaleSessionIDs(ctx, appName, userID, staleIDs)
+}
+
+// Apps returns the apps of the bucketed index.
+func (s *RedisSessionService) Apps(ctx context.Context) ([]string, error) {
+	if s.buckets <= 0 {
+		return nil, ErrIndexNotBucketed
+	}
+
+	apps, err := s.rdb.HKeys(ctx, indexRegistryKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list apps: %w", err)
+	}
+
+	return apps, nil
+}
+
+// AllSessions yields every indexed session of appName, bucket by bucket. Sessions
+// created or deleted during the iteration may or may not be yielded.
+func (s *RedisSessionService) AllSessions(
+	ctx context.Context,
+	appName string,
+) iter.Seq2[SessionRef, error] {
+	return func(yield func(SessionRef, error) bool) {
+		for page, err := range s.bucketPages(ctx, appName) {
+			if err != nil {
+				yield(SessionRef{}, err)
+				return
+			}
+
+			for _, m := range page.members {
+				ref, ok := parseIndexMember(m)
+				if !ok {
+					s.logger.Warnf("invalid session index member in %s: %q", page.key, m)
+					continue
+				}
+				if !yield(ref, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// CountSessions returns the number of indexed sessions of appName, including
+// expired sessions not pruned yet.
+func (s *RedisSessionService) CountSessions(ctx context.Context, appName string) (int64, error) {
+	if s.buckets <= 0 {
+		return 0, ErrIndexNotBucketed
+	}
+
+	n, err := s.appBuckets(ctx, appName)
+	if err != nil {
+		return 0, err
+	}
+
+	pipe := s.rdb.Pipeline()
+	cmds := make([]*redis.IntCmd, n)
+	for i := range n {
+		cmds[i] = pipe.ZCard(ctx, buildBucketKey(appName, i))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to count sessions: %w", err)
+	}
+
+	var total int64
+	for _, cmd := range cmds {
+		total += cmd.Val()
+	}
+
+	return total, nil
+}
+
+// PruneIndex removes the index entries of the expired sessions of appName and
+// returns how many were removed. Run it periodically in bucketed mode, where index
+// entries do not expire with their sessions.
+func (s *RedisSessionService) PruneIndex(ctx context.Context, appName string) (int, error) {
+	removed := 0
+	for page, err := range s.bucketPages(ctx, appName) {
+		if err != nil {
+			return removed, err
+		}
+
+		n, err := s.pruneBucket(ctx, appName, page.key, page.members)
+		if err != nil {
+			return removed, fmt.Errorf("failed to prune %s: %w", page.key, err)
+		}
+		removed += n
+	}
+
+	if removed > 0 {
+		s.logger.Infof("pruned %d expired sessions from index: app=%s", removed, appName)
+	}
+
+	return removed, nil
+}
+
+// bucketPage is a page of the members of a bucket.
+type bucketPage struct {
+	key     string
+	members []string
+}
+
+// bucketPages yields the members of every bucket of appName, in pages. Pages
+// resume after the last member read, so concurrent changes shift no member out.
+func (s *RedisSessionService) bucketPages(
+	ctx context.Context,
+	appName string,
+) iter.Seq2[bucketPage, error] {
+	return func(yield func(bucketPage, error) bool) {
+		if s.buckets <= 0 {
+			yield(bucketPage{}, ErrIndexNotBucketed)
+			return
+		}
+
+		n, err := s.appBuckets(ctx, appName)
+		if err != nil {
+			yield(bucketPage{}, err)
+			return
+		}
+
+		for i := range n {
+			key := buildBucketKey(appName, i)
+			for minMember := "-"; ; {
+				members, err := s.rdb.ZRangeByLex(ctx, key, &redis.ZRangeBy{
+					Min:   minMember,
+					Max:   "+",
+					Count: indexBatchSize,
+				}).Result()
+				if err != nil {
+					yield(bucketPage{}, fmt.Errorf("failed to read %s: %w", key, err))
+					return
+				}
+				if len(members) == 0 {
+					break
+				}
+				if !yield(bucketPage{key: key, members: members}, nil) {
+					return
+				}
+				if len(members) < indexBatchSize {
+					break
+				}
+				minMember = "(" + members[len(members)-1]
+			}
+		}
+	}
+}
+
+// MigrateIndex moves the per-user session index sets of appName into the buckets
+// and returns the number of sessions moved. It can run while the service is
+// serving: the sets are read as a fallback until they are moved. With removeLegacy,
+// the sets are deleted once moved; set it only once every instance uses
+// WithBucketedIndex, the sets stay otherwise so that older instances keep working
+// during a rolling deploy.
+func (s *RedisSessionService) MigrateIndex(
+	ctx context.Context,
+	appName string,
+	removeLegacy bool,
+) (int, error) {
+	if s.buckets <= 0 {
+		return 0, ErrIndexNotBucketed
+	}
+
+	prefix := fmt.Sprintf("session:%s:", appName)
+	moved := 0
+
+	it := s.rdb.Scan(ctx, 0, escapeGlob(prefix)+"*", indexBatchSize).Iterator()
+	for it.Next(ctx) {
+		legacyKey := it.Val()
+
+		typ, err := s.rdb.Type(ctx, legacyKey).Result()
+		if err != nil {
+			return moved, fmt.Errorf("failed to get type of %s: %w", legacyKey, err)
+		}
+		if typ != "set" {
+			// Session data keys share the prefix
+			continue
+		}
+
+		userID := strings.TrimPrefix(legacyKey, prefix)
+		sessionIDs, err := s.rdb.SMembers(ctx, legacyKey).Result()
+		if err != nil {
+			return moved, fmt.Errorf("failed to read %s: %w", legacyKey, err)
+		}
+
+		key, err := s.bucketKey(ctx, appName, userID)
+		if err != nil {
+			return moved, err
+		}
+
+		members := make([]redis.Z, 0, len(sessionIDs))
+		for _, id := range sessionIDs {
+			members = append(members, redis.Z{Member: buildIndexMember(userID, id)})
+		}
+		if len(members) > 0 {
+			if err := s.rdb.ZAdd(ctx, key, members...).Err(); err != nil {
+				return moved, fmt.Errorf("failed to migrate %s: %w", legacyKey, err)
+			}
+		}
+		moved += len(members)
+
+		if removeLegacy {
+			if err := s.rdb.Del(ctx, legacyKey).Err(); err != nil {
+				return moved, fmt.Errorf("failed to remove %s: %w", legacyKey, err)
+			}
+		}
+	}
+	if err := it.Err(); err != nil {
+		return moved, fmt.Errorf("failed to scan session indexes: %w", err)
+	}
+
+	s.logger.Infof("session index migrated: app=%s, sessions=%d, removeLegacy=%v",
+		appName, moved, removeLegacy)
+
+	return moved, nil
+}
+
+// escapeGlob escapes the glob metacharacters of a SCAN MATCH pattern.
+func escapeGlob(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
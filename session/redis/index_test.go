@@ -0,0 +1,163 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/adk/session"
+)
+
+func TestBucketOf(t *testing.T) {
+	for _, userID := range []string{"", "user1", "user:with:colons"} {
+		b := bucketOf(userID, 16)
+		if b < 0 || b >= 16 {
+			t.Errorf("bucketOf(%q) = %d, out of range", userID, b)
+		}
+		if bucketOf(userID, 16) != b {
+			t.Errorf("bucketOf(%q) is not deterministic", userID)
+		}
+	}
+
+	ref, ok := parseIndexMember(buildIndexMember("user:1", "sess1"))
+	if !ok || ref.UserID != "user:1" || ref.SessionID != "sess1" {
+		t.Errorf("parseIndexMember() = %+v, %v", ref, ok)
+	}
+}
+
+func cleanupBucketedIndex(t *testing.T, svc *RedisSessionService, appName string) {
+	t.Helper()
+
+	t.Cleanup(func() {
+		ctx := context.Background()
+		cleanupTestKeys(t, svc.rdb,
+			fmt.Sprintf("session:%s:*", appName),
+			fmt.Sprintf("events:%s:*", appName),
+			fmt.Sprintf("session-index:{%s:*", appName),
+		)
+		svc.rdb.HDel(ctx, indexRegistryKey, appName)
+	})
+}
+
+func TestBucketedIndex(t *testing.T) {
+	const appName = "test_bucketed_app"
+
+	svc, _ := setupTestRedis(t, WithBucketedIndex(4), WithTTL(time.Minute))
+	cleanupBucketedIndex(t, svc, appName)
+	ctx := context.Background()
+
+	for _, userID := range []string{"alice", "bob"} {
+		for i := range 3 {
+			_, err := svc.Create(ctx, &session.CreateRequest{
+				AppName:   appName,
+				UserID:    userID,
+				SessionID: fmt.Sprintf("%s-%d", userID, i),
+			})
+			if err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+		}
+	}
+
+	resp, err := svc.List(ctx, &session.ListRequest{AppName: appName, UserID: "alice"})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(resp.Sessions) != 3 {
+		t.Fatalf("Expected 3 sessions of alice, got %d", len(resp.Sessions))
+	}
+	for _, sess := range resp.Sessions {
+		if sess.UserID() != "alice" {
+			t.Errorf("Unexpected session of %s", sess.UserID())
+		}
+	}
+
+	err = svc.Delete(ctx, &session.DeleteRequest{AppName: appName, UserID: "alice", SessionID: "alice-0"})
+	if err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	if n, err := svc.CountSessions(ctx, appName); err != nil || n != 5 {
+		t.Errorf("CountSessions() = %d, %v", n, err)
+	}
+
+	var refs []string
+	for ref, err := range svc.AllSessions(ctx, appName) {
+		if err != nil {
+			t.Fatalf("AllSessions failed: %v", err)
+		}
+		refs = append(refs, ref.UserID+"/"+ref.SessionID)
+	}
+	slices.Sort(refs)
+	want := []string{"alice/alice-1", "alice/alice-2", "bob/bob-0", "bob/bob-1", "bob/bob-2"}
+	if !slices.Equal(refs, want) {
+		t.Errorf("AllSessions() = %v, want %v", refs, want)
+	}
+
+	apps, err := svc.Apps(ctx)
+	if err != nil || !slices.Contains(apps, appName) {
+		t.Errorf("Apps() = %v, %v", apps, err)
+	}
+
+	// Expired sessions leave index entries until pruned
+	svc.rdb.Del(ctx, buildSessionKey(appName, "bob", "bob-0"))
+	if n, err := svc.PruneIndex(ctx, appName); err != nil || n != 1 {
+		t.Errorf("PruneIndex() = %d, %v", n, err)
+	}
+	if n, _ := svc.CountSessions(ctx, appName); n != 4 {
+		t.Errorf("Expected 4 sessions after prune, got %d", n)
+	}
+}
+
+func TestMigrateIndex(t *testing.T) {
+	const appName = "test_migrate_index_app"
+
+	legacy, rdb := setupTestRedis(t, WithTTL(time.Minute))
+	ctx := context.Background()
+
+	for i := range 3 {
+		_, err := legacy.Create(ctx, &session.CreateRequest{
+			AppName:   appName,
+			UserID:    fmt.Sprintf("user%d", i),
+			SessionID: fmt.Sprintf("sess%d", i),
+		})
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	svc, err := NewRedisSessionService(rdb, WithBucketedIndex(8), WithTTL(time.Minute))
+	if err != nil {
+		t.Fatalf("NewRedisSessionService failed: %v", err)
+	}
+	cleanupBucketedIndex(t, svc, appName)
+
+	// Not migrated sessions are listed from the per-user sets
+	resp, err := svc.List(ctx, &session.ListRequest{AppName: appName, UserID: "user1"})
+	if err != nil || len(resp.Sessions) != 1 {
+		t.Fatalf("List before migration = %v, %v", resp, err)
+	}
+
+	moved, err := svc.MigrateIndex(ctx, appName, true)
+	if err != nil {
+		t.Fatalf("MigrateIndex failed: %v", err)
+	}
+	if moved != 3 {
+		t.Errorf("Expected 3 sessions moved, got %d", moved)
+	}
+
+	if n := rdb.Exists(ctx, buildSessionIndexKey(appName, "user1")).Val(); n != 0 {
+		t.Error("Expected the per-user set to be removed")
+	}
+
+	resp, err = svc.List(ctx, &session.ListRequest{AppName: appName, UserID: "user1"})
+	if err != nil || len(resp.Sessions) != 1 || resp.Sessions[0].ID() != "sess1" {
+		t.Fatalf("List after migration = %v, %v", resp, err)
+	}
+
+	if _, err := legacy.MigrateIndex(ctx, appName, false); err != ErrIndexNotBucketed {
+		t.Errorf("Expected ErrIndexNotBucketed, got %v", err)
+	}
+}
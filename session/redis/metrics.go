@@ -0,0 +1,148 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Operation is a session.Service operation reported to Metrics.
+type Operation string
+
+const (
+	OperationCreate      Operation = "create"
+	OperationGet         Operation = "get"
+	OperationList        Operation = "list"
+	OperationDelete      Operation = "delete"
+	OperationAppendEvent Operation = "append_event"
+)
+
+// CacheResult is the outcome of a session lookup in Redis, when sessions evicted
+// from Redis are restored from a loader (see WithLoader).
+type CacheResult string
+
+const (
+	// CacheHit is a session found in Redis.
+	CacheHit CacheResult = "hit"
+	// CacheRestored is a session missing from Redis and restored from the loader.
+	CacheRestored CacheResult = "restored"
+	// CacheMiss is a session found neither in Redis nor in the loader.
+	CacheMiss CacheResult = "miss"
+)
+
+// Metrics receives the measurements of a RedisSessionService, see WithMetrics.
+// Its methods are called on the hot path, concurrently: they must be safe for
+// concurrent use and must not block. See the prometheus sub-package for an
+// implementation.
+type Metrics interface {
+	// Operation reports a session.Service call (Get and GetBranch report
+	// OperationGet) with its duration and error, nil on success.
+	Operation(op Operation, d time.Duration, err error)
+
+	// RedisCommand reports a Redis command run by an operation of the service, with
+	// its duration and error. A pipeline or transaction is reported once, as "pipeline". A missing
+	// key (redis.Nil) is not an error.
+	RedisCommand(cmd string, d time.Duration, err error)
+
+	// CacheLookup reports the outcome of a session lookup of Get, GetBranch and the
+	// calls using them. Only reported with WithLoader.
+	CacheLookup(result CacheResult)
+}
+
+// WithMetrics reports the operations of the service, the Redis commands they run
+// and the outcome of its session lookups to m.
+//
+// NOTE: The Redis commands are measured by a hook added to the client passed to
+// NewRedisSessionService, which reports only the commands run by the operations
+// of this service: the client can be shared with other services, or users, whose
+// commands are not reported. go-redis hooks cannot be removed, so every service
+// created with metrics on a client leaves its hook on it.
+func WithMetrics(m Metrics) ServiceOption {
+	return func(s *RedisSessionService) { s.metrics = m }
+}
+
+// observe reports an operation started at start, if metrics are set.
+func (s *RedisSessionService) observe(op Operation, start time.Time, err error) {
+	if s.metrics == nil {
+		return
+	}
+	s.metrics.Operation(op, time.Since(start), err)
+}
+
+// metricsContextKey tags the context of the operations of a service, so that
+// its metricsHook reports their commands only.
+type metricsContextKey struct{}
+
+// metricsContext returns ctx tagged for the metricsHook of s, if metrics are set.
+func (s *RedisSessionService) metricsContext(ctx context.Context) context.Context {
+	if s.metrics == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, metricsContextKey{}, s)
+}
+
+// observeCache reports the outcome of a session lookup, if metrics are set.
+func (s *RedisSessionService) observeCache(result CacheResult) {
+	if s.metrics == nil {
+		return
+	}
+	s.metrics.CacheLookup(result)
+}
+
+// metricsHook is the go-redis hook timing the commands of the operations of owner
+// for Metrics. The commands run with other contexts are not reported.
+type metricsHook struct {
+	owner   *RedisSessionService
+	metrics Metrics
+}
+
+// owns reports whether ctx is the context of an operation of the owner of h.
+func (h metricsHook) owns(ctx context.Context) bool {
+	owner, _ := ctx.Value(metricsContextKey{}).(*RedisSessionService)
+	return owner == h.owner
+}
+
+var _ redis.Hook = metricsHook{}
+
+func (h metricsHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (h metricsHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if !h.owns(ctx) {
+			return next(ctx, cmd)
+		}
+
+		start := time.Now()
+		err := next(ctx, cmd)
+		h.metrics.RedisCommand(cmd.Name(), time.Since(start), commandError(err))
+		return err
+	}
+}
+
+func (h metricsHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if !h.owns(ctx) {
+			return next(ctx, cmds)
+		}
+
+		start := time.Now()
+		err := next(ctx, cmds)
+		h.metrics.RedisCommand("pipeline", time.Since(start), commandError(err))
+		return err
+	}
+}
+
+// commandError returns err, or nil for a missing key.
+func commandError(err error) error {
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,144 @@
+package redis
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	ksess "github.com/kydenul/k-adk/session"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/adk/session"
+)
+
+// recordedMetrics records the measurements reported to Metrics.
+type recordedMetrics struct {
+	mu         sync.Mutex
+	operations map[Operation][]error
+	commands   map[string]int
+	lookups    map[CacheResult]int
+}
+
+func newRecordedMetrics() *recordedMetrics {
+	return &recordedMetrics{
+		operations: make(map[Operation][]error),
+		commands:   make(map[string]int),
+		lookups:    make(map[CacheResult]int),
+	}
+}
+
+func (m *recordedMetrics) Operation(op Operation, _ time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.operations[op] = append(m.operations[op], err)
+}
+
+func (m *recordedMetrics) RedisCommand(cmd string, _ time.Duration, _ error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.commands[cmd]++
+}
+
+func (m *recordedMetrics) CacheLookup(result CacheResult) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lookups[result]++
+}
+
+func TestMetricsUnreachable(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:        "127.0.0.1:1",
+		DialTimeout: 100 * time.Millisecond,
+		MaxRetries:  -1,
+	})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	metrics := newRecordedMetrics()
+	svc, err := NewRedisSessionService(rdb, WithMetrics(metrics))
+	if err != nil {
+		t.Fatalf("NewRedisSessionService() error = %v", err)
+	}
+
+	ctx := context.Background()
+	if _, err := svc.Get(ctx, &session.GetRequest{AppName: "app", UserID: "u", SessionID: "s"}); err == nil {
+		t.Fatal("Get() error = nil, want an error")
+	}
+	if err := svc.Delete(ctx, &session.DeleteRequest{AppName: "app", UserID: "u", SessionID: "s"}); err == nil {
+		t.Fatal("Delete() error = nil, want an error")
+	}
+
+	for _, op := range []Operation{OperationGet, OperationDelete} {
+		errs := metrics.operations[op]
+		if len(errs) != 1 || errs[0] == nil {
+			t.Errorf("operation %s reported %v, want one error", op, errs)
+		}
+	}
+	if metrics.commands["pipeline"] == 0 {
+		t.Errorf("commands = %v, want the reads of the session reported", metrics.commands)
+	}
+	if len(metrics.lookups) != 0 {
+		t.Errorf("lookups = %v, want none without a loader", metrics.lookups)
+	}
+}
+
+func TestMetricsSharedClient(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:        "127.0.0.1:1",
+		DialTimeout: 100 * time.Millisecond,
+		MaxRetries:  -1,
+	})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	first, second := newRecordedMetrics(), newRecordedMetrics()
+	svc, err := NewRedisSessionService(rdb, WithMetrics(first))
+	if err != nil {
+		t.Fatalf("NewRedisSessionService() error = %v", err)
+	}
+	if _, err := NewRedisSessionService(rdb, WithMetrics(second)); err != nil {
+		t.Fatalf("NewRedisSessionService() error = %v", err)
+	}
+
+	ctx := context.Background()
+	_, _ = svc.Get(ctx, &session.GetRequest{AppName: "app", UserID: "u", SessionID: "s"})
+	_ = rdb.Get(ctx, "not-a-session").Err()
+
+	if first.commands["pipeline"] == 0 || first.commands["get"] != 0 {
+		t.Errorf("commands = %v, want the Get of the service reported only", first.commands)
+	}
+	if len(second.commands) != 0 {
+		t.Errorf("commands = %v, want none for the other service", second.commands)
+	}
+}
+
+func TestMetricsCacheLookups(t *testing.T) {
+	metrics := newRecordedMetrics()
+	loader := &memLoader{sessions: map[string]*ksess.StoredSession{}}
+	svc, rdb := setupTestRedis(t, WithMetrics(metrics), WithLoader(loader))
+	ctx := context.Background()
+
+	const appName, userID = "metrics-app", "metrics-user"
+	t.Cleanup(func() { cleanupTestKeys(t, rdb, "*:"+appName+":*") })
+
+	created, err := svc.Create(ctx, &session.CreateRequest{AppName: appName, UserID: userID})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	get := &session.GetRequest{AppName: appName, UserID: userID, SessionID: created.Session.ID()}
+	if _, err := svc.Get(ctx, get); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	get.SessionID = "missing"
+	if _, err := svc.Get(ctx, get); err == nil {
+		t.Fatal("Get() of a missing session error = nil")
+	}
+
+	if metrics.lookups[CacheHit] != 1 || metrics.lookups[CacheMiss] != 1 {
+		t.Errorf("lookups = %v, want 1 hit and 1 miss", metrics.lookups)
+	}
+	if errs := metrics.operations[OperationCreate]; len(errs) != 1 || errs[0] != nil {
+		t.Errorf("create reported %v, want one success", errs)
+	}
+	if errs := metrics.operations[OperationGet]; len(errs) != 2 || errs[0] != nil || errs[1] == nil {
+		t.Errorf("get reported %v, want a success and an error", errs)
+	}
+}
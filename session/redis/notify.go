@@ -0,0 +1,136 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/redis/go-redis/v9"
+)
+
+// notificationBuffer is the number of notifications buffered for a slow subscriber
+// before Redis messages queue up in the client.
+const notificationBuffer = 64
+
+// NotificationType is the kind of change a Notification reports.
+type NotificationType string
+
+const (
+	// NotificationSessionCreated reports a session created by Create or CreateBranch.
+	NotificationSessionCreated NotificationType = "session_created"
+
+	// NotificationEventAppended reports an event appended by AppendEvent.
+	NotificationEventAppended NotificationType = "event_appended"
+
+	// NotificationSessionDeleted reports a session deleted by Delete.
+	NotificationSessionDeleted NotificationType = "session_deleted"
+)
+
+// Notification is a change of a session, published over Redis Pub/Sub by the
+// services created with WithNotifications.
+type Notification struct {
+	Type      NotificationType `json:"type"`
+	AppName   string           `json:"app_name"`
+	UserID    string           `json:"user_id"`
+	SessionID string           `json:"session_id"`
+
+	// EventID and Author describe the appended event of NotificationEventAppended.
+	// Get or Sync the session to read it.
+	EventID string `json:"event_id,omitempty"`
+	Author  string `json:"author,omitempty"`
+
+	Time time.Time `json:"time"`
+}
+
+// WithNotifications publishes a Notification on Redis Pub/Sub for every session
+// created, event appended and session deleted, to the channel
+// "session-notify:{app}:{user}" of the session. Subscribe to receive them, from
+// any instance sharing the Redis. Publishing costs a round trip per change;
+// notifications published without subscribers are dropped by Redis.
+func WithNotifications() ServiceOption {
+	return func(s *RedisSessionService) { s.notifications = true }
+}
+
+func buildNotifyChannel(appName, userID string) string {
+	return fmt.Sprintf("session-notify:%s:%s", appName, userID)
+}
+
+// notify publishes n if notifications are enabled. A failed publish is logged: the
+// change it reports is already stored.
+func (s *RedisSessionService) notify(ctx context.Context, n Notification) {
+	if !s.notifications {
+		return
+	}
+
+	n.Time = time.Now()
+	payload, err := sonic.Marshal(n)
+	if err != nil {
+		s.logger.Warnf("failed to marshal %s notification of session %s: %v", n.Type, n.SessionID, err)
+		return
+	}
+
+	if err := s.rdb.Publish(ctx, buildNotifyChannel(n.AppName, n.UserID), payload).Err(); err != nil {
+		s.logger.Warnf("failed to publish %s notification of session %s: %v", n.Type, n.SessionID, err)
+	}
+}
+
+// Subscribe receives the notifications of the sessions of a user, or of every
+// user of the app if userID is empty, published by the services created with
+// WithNotifications. The channel is closed when ctx is done or the subscription
+// fails. Notifications are delivered at most once: those published while a
+// subscriber is disconnected are lost, so dashboards and websocket frontends
+// should Sync after subscribing.
+func (s *RedisSessionService) Subscribe(
+	ctx context.Context,
+	appName, userID string,
+) (<-chan Notification, error) {
+	var pubsub *redis.PubSub
+	if userID == "" {
+		pubsub = s.rdb.PSubscribe(ctx, buildNotifyChannel(escapeGlob(appName), "*"))
+	} else {
+		pubsub = s.rdb.Subscribe(ctx, buildNotifyChannel(appName, userID))
+	}
+
+	// NOTE: Wait for the subscription, so no notification published after
+	// Subscribe returns is missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe to session notifications: %w", err)
+	}
+
+	s.logger.Debugf("subscribed to session notifications: app=%s, user=%s", appName, userID)
+
+	out := make(chan Notification, notificationBuffer)
+	go func() {
+		defer close(out)
+		defer pubsub.Close()
+
+		msgs := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case msg, ok := <-msgs:
+				if !ok {
+					return
+				}
+
+				var n Notification
+				if err := sonic.UnmarshalString(msg.Payload, &n); err != nil {
+					s.logger.Warnf("invalid session notification on %s: %v", msg.Channel, err)
+					continue
+				}
+
+				select {
+				case out <- n:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
@@ -0,0 +1,87 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/adk/session"
+)
+
+func TestNotifications(t *testing.T) {
+	const (
+		appName = "test_notify_app"
+		userID  = "test_notify_user"
+	)
+
+	svc, rdb := setupTestRedis(t, WithTTL(30*time.Second), WithNotifications())
+	t.Cleanup(func() {
+		cleanupTestKeys(t, rdb,
+			fmt.Sprintf("session:%s:*", appName),
+			fmt.Sprintf("events:%s:*", appName),
+			fmt.Sprintf("statelog:%s:*", appName),
+		)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userCh, err := svc.Subscribe(ctx, appName, userID)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	appCh, err := svc.Subscribe(ctx, appName, "")
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	resp, err := svc.Create(ctx, &session.CreateRequest{AppName: appName, UserID: userID, SessionID: "s1"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := svc.AppendEvent(ctx, resp.Session, &session.Event{ID: "e1", Author: "user"}); err != nil {
+		t.Fatalf("AppendEvent failed: %v", err)
+	}
+	if err := svc.Delete(ctx, &session.DeleteRequest{AppName: appName, UserID: userID, SessionID: "s1"}); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	want := []NotificationType{NotificationSessionCreated, NotificationEventAppended, NotificationSessionDeleted}
+	for name, ch := range map[string]<-chan Notification{"user": userCh, "app": appCh} {
+		t.Run(name, func(t *testing.T) {
+			for _, typ := range want {
+				select {
+				case n := <-ch:
+					if n.Type != typ || n.SessionID != "s1" || n.UserID != userID {
+						t.Errorf("expected %s of s1, got %+v", typ, n)
+					}
+					if typ == NotificationEventAppended && (n.EventID != "e1" || n.Author != "user") {
+						t.Errorf("expected event e1 of user, got %+v", n)
+					}
+
+				case <-ctx.Done():
+					t.Fatalf("timed out waiting for %s", typ)
+				}
+			}
+		})
+	}
+
+	t.Run("closed on cancel", func(t *testing.T) {
+		subCtx, subCancel := context.WithCancel(ctx)
+		ch, err := svc.Subscribe(subCtx, appName, userID)
+		if err != nil {
+			t.Fatalf("Subscribe failed: %v", err)
+		}
+		subCancel()
+
+		select {
+		case _, ok := <-ch:
+			if ok {
+				t.Error("expected no notification")
+			}
+		case <-ctx.Done():
+			t.Fatal("expected the channel to be closed")
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	ksess "github.com/kydenul/k-adk/session"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultPreloadBatchSize   = 100
+	defaultPreloadSessionsSec = 200
+)
+
+// ErrNoRecentLoader is returned by PreloadRecent when the service has no loader,
+// or its loader cannot list recent sessions (ksess.RecentLoader).
+var ErrNoRecentLoader = errors.New("no loader of recent sessions")
+
+// PreloadConfig configures PreloadRecent.
+type PreloadConfig struct {
+	// Optional. BatchSize is the number of sessions listed per page. Default: 100.
+	BatchSize int
+
+	// Optional. SessionsPerSecond caps the sessions restored per second, to keep
+	// the preload light on Redis and the persistent store. Default: 200.
+	SessionsPerSecond int
+
+	// Optional. Resume continues an interrupted preload after this session: the
+	// Cursor of its PreloadResult.
+	Resume *ksess.SessionRef
+
+	// Optional. OnProgress is called after every page with the result so far,
+	// e.g. to save the cursor.
+	OnProgress func(*PreloadResult)
+}
+
+// PreloadResult reports the progress of PreloadRecent.
+type PreloadResult struct {
+	// Restored is the number of sessions written to Redis, Cached the number of
+	// sessions already there, and Failed the number of sessions that could not be
+	// restored.
+	Restored int
+	Cached   int
+	Failed   int
+
+	// Cursor is the last session processed. Pass it as PreloadConfig.Resume to
+	// continue an interrupted preload.
+	Cursor *ksess.SessionRef
+
+	Duration time.Duration
+}
+
+// PreloadRecent restores into Redis the sessions of appName updated since since,
+// most recent first, from the loader (see WithLoader), which must be a
+// ksess.RecentLoader. Run it after a cache flush or failover so users returning
+// right after the incident do not all hit the recovery path of Get at once.
+//
+// The preload is rate limited. When ctx ends, it returns the result so far with
+// the context error; its Cursor resumes the preload. Sessions that fail to restore
+// are logged and counted, and the preload goes on.
+func (s *RedisSessionService) PreloadRecent(
+	ctx context.Context,
+	appName string,
+	since time.Time,
+	cfg PreloadConfig,
+) (*PreloadResult, error) {
+	loader, ok := s.loader.(ksess.RecentLoader)
+	if !ok {
+		return nil, ErrNoRecentLoader
+	}
+
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultPreloadBatchSize
+	}
+	if cfg.SessionsPerSecond <= 0 {
+		cfg.SessionsPerSecond = defaultPreloadSessionsSec
+	}
+
+	s.logger.Infof("preloading recent sessions: app=%s, since=%s, resume=%v",
+		appName, since.Format(time.RFC3339), cfg.Resume != nil)
+
+	start := time.Now()
+	result := &PreloadResult{Cursor: cfg.Resume}
+	ticker := time.NewTicker(rateInterval(cfg.SessionsPerSecond))
+	defer ticker.Stop()
+
+	for {
+		refs, err := loader.ListRecentSessions(ctx, appName, since, result.Cursor, cfg.BatchSize)
+		if err != nil {
+			result.Duration = time.Since(start)
+			return result, fmt.Errorf("failed to list recent sessions: %w", err)
+		}
+		if len(refs) == 0 {
+			break
+		}
+
+		// NOTE: Skip the sessions already in Redis without reading the store
+		pipe := s.rdb.Pipeline()
+		exists := make([]*redis.IntCmd, len(refs))
+		for i, ref := range refs {
+			exists[i] = pipe.Exists(ctx, buildSessionKey(ref.AppName, ref.UserID, ref.ID))
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			result.Duration = time.Since(start)
+			return result, fmt.Errorf("failed to check cached sessions: %w", err)
+		}
+
+		for i, ref := range refs {
+			if exists[i].Val() > 0 {
+				result.Cached++
+				result.Cursor = &refs[i]
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				result.Duration = time.Since(start)
+				return result, ctx.Err()
+			case <-ticker.C:
+			}
+
+			restored, err := s.restore(ctx, ref.AppName, ref.UserID, ref.ID)
+			switch {
+			case err != nil:
+				result.Failed++
+				s.logger.Warnf("failed to preload session %s: %v", ref.ID, err)
+			case restored:
+				result.Restored++
+			}
+			result.Cursor = &refs[i]
+		}
+
+		if cfg.OnProgress != nil {
+			cfg.OnProgress(result)
+		}
+		if len(refs) < cfg.BatchSize {
+			break
+		}
+	}
+
+	result.Duration = time.Since(start)
+
+	s.logger.Infof("recent sessions preloaded: app=%s, restored=%d, cached=%d, failed=%d, duration=%s",
+		appName, result.Restored, result.Cached, result.Failed, result.Duration)
+
+	return result, nil
+}
+
+// rateInterval returns the interval between two sessions at perSecond, at least
+// a nanosecond: time.NewTicker panics on the zero interval of a rate above 1e9.
+func rateInterval(perSecond int) time.Duration {
+	return max(time.Second/time.Duration(perSecond), time.Nanosecond)
+}
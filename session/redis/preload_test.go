@@ -0,0 +1,97 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	ksess "github.com/kydenul/k-adk/session"
+	"google.golang.org/adk/session"
+)
+
+func TestPreloadRecent(t *testing.T) {
+	const (
+		appName = "test_preload_app"
+		userID  = "test_preload_user"
+	)
+
+	now := time.Now()
+	loader := &memLoader{sessions: map[string]*ksess.StoredSession{}}
+	for i := range 5 {
+		id := fmt.Sprintf("s%d", i)
+		loader.sessions[id] = &ksess.StoredSession{
+			ID: id, AppName: appName, UserID: userID,
+			State:          map[string]any{"n": i},
+			Events:         []*session.Event{{ID: id + "-e1"}},
+			LastUpdateTime: now.Add(-time.Duration(i) * time.Hour),
+		}
+	}
+
+	svc, rdb := setupTestRedis(t, WithTTL(30*time.Second), WithLoader(loader))
+	t.Cleanup(func() {
+		cleanupTestKeys(t, rdb,
+			fmt.Sprintf("session:%s:*", appName),
+			fmt.Sprintf("events:%s:*", appName),
+		)
+	})
+
+	ctx := context.Background()
+
+	// s0 is already cached
+	if _, err := svc.Get(ctx, &session.GetRequest{AppName: appName, UserID: userID, SessionID: "s0"}); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	// Preload the sessions of the last 3.5 hours, two per page
+	var pages int
+	first, err := svc.PreloadRecent(ctx, appName, now.Add(-210*time.Minute), PreloadConfig{
+		BatchSize:  2,
+		OnProgress: func(*PreloadResult) { pages++ },
+	})
+	if err != nil {
+		t.Fatalf("PreloadRecent failed: %v", err)
+	}
+	if first.Restored != 3 || first.Cached != 1 || first.Failed != 0 {
+		t.Errorf("unexpected result: %+v", first)
+	}
+	if pages != 2 {
+		t.Errorf("expected 2 pages, got %d", pages)
+	}
+	if n, _ := rdb.Exists(ctx, buildSessionKey(appName, userID, "s4")).Result(); n != 0 {
+		t.Error("expected s4, older than since, not to be preloaded")
+	}
+
+	t.Run("resume", func(t *testing.T) {
+		resumed, err := svc.PreloadRecent(ctx, appName, now.Add(-5*time.Hour), PreloadConfig{
+			Resume: first.Cursor,
+		})
+		if err != nil {
+			t.Fatalf("PreloadRecent failed: %v", err)
+		}
+		if resumed.Restored != 1 || resumed.Cached != 0 {
+			t.Errorf("expected only s4 restored, got %+v", resumed)
+		}
+	})
+
+	t.Run("no recent loader", func(t *testing.T) {
+		plain, _ := setupTestRedis(t)
+		if _, err := plain.PreloadRecent(ctx, appName, now, PreloadConfig{}); !errors.Is(err, ErrNoRecentLoader) {
+			t.Errorf("expected ErrNoRecentLoader, got %v", err)
+		}
+	})
+}
+
+func TestRateInterval(t *testing.T) {
+	for perSecond, want := range map[int]time.Duration{
+		1:             time.Second,
+		50:            20 * time.Millisecond,
+		1_000_000_000: time.Nanosecond,
+		2_000_000_000: time.Nanosecond,
+	} {
+		if got := rateInterval(perSecond); got != want {
+			t.Errorf("rateInterval(%d) = %s, want %s", perSecond, got, want)
+		}
+	}
+}
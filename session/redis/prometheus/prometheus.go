@@ -0,0 +1,293 @@
+// Package prometheus implements redis.Metrics with Prometheus metrics, exposed in
+// the Prometheus text format by the Metrics handler:
+//
+//	metrics := prometheus.New()
+//	svc, err := redis.NewRedisSessionService(rdb, redis.WithMetrics(metrics))
+//	http.Handle("/metrics", metrics)
+//
+// The metrics, prefixed with the namespace ("kadk" by default), are:
+//
+//	{ns}_session_operations_total{operation,status}           counter
+//	{ns}_session_operation_duration_seconds{operation}        histogram
+//	{ns}_session_redis_commands_total{command,status}         counter
+//	{ns}_session_redis_command_duration_seconds{command}      histogram
+//	{ns}_session_cache_lookups_total{result}                  counter
+//
+// status is "ok" or "error".
+package prometheus
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kydenul/k-adk/session/redis"
+)
+
+const (
+	defaultNamespace = "kadk"
+
+	statusOK    = "ok"
+	statusError = "error"
+
+	contentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the default histogram
+// buckets: from 0.5ms, for Redis commands, to 5s, for large sessions.
+var DefaultBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+var (
+	_ redis.Metrics = (*Metrics)(nil)
+	_ http.Handler  = (*Metrics)(nil)
+)
+
+// Option configures Metrics.
+type Option func(*Metrics)
+
+// WithNamespace sets the prefix of the metric names. Default: "kadk".
+func WithNamespace(namespace string) Option {
+	return func(m *Metrics) { m.namespace = namespace }
+}
+
+// WithBuckets sets the upper bounds, in seconds, of the histogram buckets.
+// Default: DefaultBuckets.
+func WithBuckets(buckets []float64) Option {
+	return func(m *Metrics) { m.buckets = buckets }
+}
+
+// Metrics collects the measurements of a RedisSessionService, see redis.WithMetrics.
+// It is safe for concurrent use.
+type Metrics struct {
+	namespace string
+	buckets   []float64
+
+	// mu protects the series below.
+	mu             sync.Mutex
+	operations     map[[2]string]uint64
+	operationTimes map[string]*histogram
+	commands       map[[2]string]uint64
+	commandTimes   map[string]*histogram
+	cacheLookups   map[string]uint64
+}
+
+// New creates Metrics.
+func New(opts ...Option) *Metrics {
+	m := &Metrics{
+		namespace:      defaultNamespace,
+		buckets:        DefaultBuckets,
+		operations:     make(map[[2]string]uint64),
+		operationTimes: make(map[string]*histogram),
+		commands:       make(map[[2]string]uint64),
+		commandTimes:   make(map[string]*histogram),
+		cacheLookups:   make(map[string]uint64),
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	m.buckets = slices.Sorted(slices.Values(m.buckets))
+	return m
+}
+
+// Operation implements redis.Metrics.
+func (m *Metrics) Operation(op redis.Operation, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.operations[[2]string{string(op), status(err)}]++
+	m.histogram(m.operationTimes, string(op)).observe(d.Seconds())
+}
+
+// RedisCommand implements redis.Metrics.
+func (m *Metrics) RedisCommand(cmd string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.commands[[2]string{cmd, status(err)}]++
+	m.histogram(m.commandTimes, cmd).observe(d.Seconds())
+}
+
+// CacheLookup implements redis.Metrics.
+func (m *Metrics) CacheLookup(result redis.CacheResult) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cacheLookups[string(result)]++
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	_, _ = m.WriteTo(w)
+}
+
+// WriteTo writes the metrics to w in the Prometheus text format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	// NOTE: The series are copied under the lock, and written after releasing it:
+	// a slow scraper must not block the measurements of the service
+	m.mu.Lock()
+	operations := maps.Clone(m.operations)
+	operationTimes := cloneHistograms(m.operationTimes)
+	commands := maps.Clone(m.commands)
+	commandTimes := cloneHistograms(m.commandTimes)
+	lookups := make(map[[2]string]uint64, len(m.cacheLookups))
+	for result, n := range m.cacheLookups {
+		lookups[[2]string{result}] = n
+	}
+	m.mu.Unlock()
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+
+	m.writeCounters(bw, "session_operations_total", "Session service operations.",
+		operations, "operation", "status")
+	m.writeHistograms(bw, "session_operation_duration_seconds",
+		"Duration of the session service operations.", operationTimes, "operation")
+	m.writeCounters(bw, "session_redis_commands_total", "Redis commands run by the session service.",
+		commands, "command", "status")
+	m.writeHistograms(bw, "session_redis_command_duration_seconds",
+		"Duration of the Redis commands run by the session service.", commandTimes, "command")
+	m.writeCounters(bw, "session_cache_lookups_total",
+		"Session lookups in Redis, by result: hit, restored from the loader, or miss.",
+		lookups, "result")
+
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// cloneHistograms copies series. The caller must hold mu.
+func cloneHistograms(series map[string]*histogram) map[string]*histogram {
+	clone := make(map[string]*histogram, len(series))
+	for label, h := range series {
+		c := *h
+		c.counts = slices.Clone(h.counts)
+		clone[label] = &c
+	}
+	return clone
+}
+
+// histogram returns the histogram of label in series, created if missing.
+// The caller must hold mu.
+func (m *Metrics) histogram(series map[string]*histogram, label string) *histogram {
+	h, ok := series[label]
+	if !ok {
+		h = &histogram{bounds: m.buckets, counts: make([]uint64, len(m.buckets))}
+		series[label] = h
+	}
+	return h
+}
+
+func (m *Metrics) name(name string) string {
+	if m.namespace == "" {
+		return name
+	}
+	return m.namespace + "_" + name
+}
+
+// writeCounters writes a counter with up to two labels, in label order.
+func (m *Metrics) writeCounters(
+	w *bufio.Writer,
+	name, help string,
+	series map[[2]string]uint64,
+	labels ...string,
+) {
+	name = m.name(name)
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+
+	for _, key := range slices.SortedFunc(maps.Keys(series), compareKeys) {
+		pairs := make([]string, len(labels))
+		for i, label := range labels {
+			pairs[i] = label + "=" + quote(key[i])
+		}
+		fmt.Fprintf(w, "%s{%s} %d\n", name, strings.Join(pairs, ","), series[key])
+	}
+}
+
+// writeHistograms writes a histogram with a single label, in label order.
+func (m *Metrics) writeHistograms(
+	w *bufio.Writer,
+	name, help string,
+	series map[string]*histogram,
+	label string,
+) {
+	name = m.name(name)
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+
+	for _, value := range slices.Sorted(maps.Keys(series)) {
+		h := series[value]
+		pair := label + "=" + quote(value)
+
+		for i, bound := range m.buckets {
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", name, pair, formatFloat(bound), h.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, pair, h.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", name, pair, formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", name, pair, h.count)
+	}
+}
+
+// histogram is a cumulative histogram.
+type histogram struct {
+	bounds []float64
+	// counts are the observations <= each bound.
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(v float64) {
+	h.sum += v
+	h.count++
+	for i, bound := range h.bounds {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+}
+
+// status returns the status label of an error.
+func status(err error) string {
+	if err != nil {
+		return statusError
+	}
+	return statusOK
+}
+
+func compareKeys(a, b [2]string) int {
+	if c := strings.Compare(a[0], b[0]); c != 0 {
+		return c
+	}
+	return strings.Compare(a[1], b[1])
+}
+
+// labelEscaper escapes the backslashes, quotes and newlines of label values.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func quote(value string) string {
+	return `"` + labelEscaper.Replace(value) + `"`
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,114 @@
+package prometheus
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kydenul/k-adk/session/redis"
+)
+
+func TestMetrics(t *testing.T) {
+	m := New(WithBuckets([]float64{0.1, 0.01}))
+
+	m.Operation(redis.OperationGet, 5*time.Millisecond, nil)
+	m.Operation(redis.OperationGet, 50*time.Millisecond, nil)
+	m.Operation(redis.OperationGet, time.Second, errors.New("boom"))
+	m.RedisCommand("get", 2*time.Millisecond, nil)
+	m.CacheLookup(redis.CacheHit)
+	m.CacheLookup(redis.CacheHit)
+	m.CacheLookup(redis.CacheMiss)
+
+	var out strings.Builder
+	n, err := m.WriteTo(&out)
+	if err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	if n != int64(out.Len()) {
+		t.Errorf("WriteTo() = %d, wrote %d bytes", n, out.Len())
+	}
+
+	for _, line := range []string{
+		"# TYPE kadk_session_operations_total counter",
+		`kadk_session_operations_total{operation="get",status="error"} 1`,
+		`kadk_session_operations_total{operation="get",status="ok"} 2`,
+		"# TYPE kadk_session_operation_duration_seconds histogram",
+		`kadk_session_operation_duration_seconds_bucket{operation="get",le="0.01"} 1`,
+		`kadk_session_operation_duration_seconds_bucket{operation="get",le="0.1"} 2`,
+		`kadk_session_operation_duration_seconds_bucket{operation="get",le="+Inf"} 3`,
+		`kadk_session_operation_duration_seconds_sum{operation="get"} 1.055`,
+		`kadk_session_operation_duration_seconds_count{operation="get"} 3`,
+		`kadk_session_redis_commands_total{command="get",status="ok"} 1`,
+		`kadk_session_redis_command_duration_seconds_count{command="get"} 1`,
+		`kadk_session_cache_lookups_total{result="hit"} 2`,
+		`kadk_session_cache_lookups_total{result="miss"} 1`,
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("output is missing %q:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	m := New(WithNamespace("app"))
+	m.Operation(redis.OperationCreate, time.Millisecond, nil)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain", ct)
+	}
+	if want := `app_session_operations_total{operation="create",status="ok"} 1`; !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("body is missing %q:\n%s", want, rec.Body.String())
+	}
+}
+
+// blockingWriter blocks its writes until release is closed.
+type blockingWriter struct {
+	writing chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.writing) })
+	<-w.release
+	return len(p), nil
+}
+
+func TestMetricsSlowScraper(t *testing.T) {
+	m := New()
+	// Enough series to fill the write buffer before the exposition ends
+	for i := range 200 {
+		m.RedisCommand(fmt.Sprintf("command-%d", i), time.Millisecond, nil)
+	}
+
+	w := &blockingWriter{writing: make(chan struct{}), release: make(chan struct{})}
+	defer close(w.release)
+	go func() { _, _ = m.WriteTo(w) }()
+	<-w.writing
+
+	done := make(chan struct{})
+	go func() {
+		m.Operation(redis.OperationGet, time.Millisecond, nil)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("a measurement blocked on a slow scraper")
+	}
+}
+
+func TestQuote(t *testing.T) {
+	if got, want := quote("a\"b\\c\nd"), `"a\"b\\c\nd"`; got != want {
+		t.Errorf("quote() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,144 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/kydenul/k-adk/internal/redisscript"
+)
+
+// ErrRateLimited is matched by the errors of the appends refused by the append rate
+// limit of a session, see WithAppendRateLimit.
+var ErrRateLimited = errors.New("event append rate limit exceeded")
+
+// RateLimitError reports an event append refused because its session appended
+// events faster than the rate limit allows.
+type RateLimitError struct {
+	// SessionID is the ID of the limited session.
+	SessionID string
+
+	// RetryAfter is how long until the session may append an event again.
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%v: session %s, retry after %s", ErrRateLimited, e.SessionID, e.RetryAfter)
+}
+
+// Is reports whether target is ErrRateLimited.
+func (e *RateLimitError) Is(target error) bool { return target == ErrRateLimited }
+
+// AppendRateLimit is a token bucket bounding the rate of the events appended to
+// every session, so an agent stuck in a loop cannot flood its session.
+type AppendRateLimit struct {
+	// Rate is the number of events per second a session may sustain.
+	Rate float64
+
+	// Optional. Burst is the number of events a session may append at once before
+	// being held to Rate. Default: Rate rounded up, at least 1.
+	Burst int
+}
+
+// WithAppendRateLimit refuses the events appended to a session beyond l with a
+// *RateLimitError, matching ErrRateLimited; the session and its state are left
+// unchanged. An append failing for another reason, e.g. a conflict, gives its
+// token back. The bucket of each session is shared by every instance using the same
+// Redis, in an extra key, "evrate:{app}:{user}:{session}", expiring once full
+// again. A limit with a non-positive rate is ignored.
+func WithAppendRateLimit(l AppendRateLimit) ServiceOption {
+	return func(s *RedisSessionService) {
+		if l.Rate <= 0 {
+			return
+		}
+		if l.Burst <= 0 {
+			l.Burst = max(int(math.Ceil(l.Rate)), 1)
+		}
+		s.rateLimit = &l
+	}
+}
+
+func buildEventRateKey(appName, userID, sessionID string) string {
+	return fmt.Sprintf("evrate:%s:%s:%s", appName, userID, sessionID)
+}
+
+// takeTokenScript takes a token from the bucket of a session, refilled at the rate
+// limit since the last take. The time is read from the Redis server, so the
+// instances sharing a bucket need not have synchronized clocks.
+//
+// KEYS[1]: bucket key, a hash of the tokens left and the time of the last take
+// ARGV[1]: rate, in tokens per second
+// ARGV[2]: burst, the capacity of the bucket
+//
+// Returns: {1 if a token was taken else 0, milliseconds until a token is available}
+var takeTokenScript = redisscript.New("session_take_append_token", `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1]) or burst
+local ts = tonumber(bucket[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+
+local taken = 0
+local wait = 0
+if tokens >= 1 then
+    tokens = tokens - 1
+    taken = 1
+else
+    wait = math.ceil((1 - tokens) * 1000 / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+
+return {taken, wait}
+`)
+
+// takeAppendToken takes a token from the bucket of a session, returning a
+// *RateLimitError if it is empty. A failure to reach the bucket is logged and lets
+// the append through: the limit guards against floods, not against Redis errors.
+func (s *RedisSessionService) takeAppendToken(ctx context.Context, appName, userID, sessionID string) error {
+	key := buildEventRateKey(appName, userID, sessionID)
+	result, err := takeTokenScript.Run(ctx, s.rdb, []string{key}, s.rateLimit.Rate, s.rateLimit.Burst).Int64Slice()
+	if err != nil || len(result) < 2 {
+		s.logger.Warnf("failed to check the append rate of session %s: %v", sessionID, err)
+		return nil
+	}
+
+	if result[0] == 1 {
+		return nil
+	}
+
+	return &RateLimitError{
+		SessionID:  sessionID,
+		RetryAfter: time.Duration(result[1]) * time.Millisecond,
+	}
+}
+
+// refundTokenScript gives back a token taken by takeTokenScript, up to the burst.
+// A bucket expired since is full already.
+//
+// KEYS[1]: bucket key
+// ARGV[1]: burst, the capacity of the bucket
+var refundTokenScript = redisscript.New("session_refund_append_token", `
+local tokens = tonumber(redis.call('HGET', KEYS[1], 'tokens'))
+if tokens then
+    redis.call('HSET', KEYS[1], 'tokens', tostring(math.min(tonumber(ARGV[1]), tokens + 1)))
+end
+return 1
+`)
+
+// refundAppendToken gives back the token of an append that failed after taking
+// it. A failure is logged: the session is then limited a little early.
+func (s *RedisSessionService) refundAppendToken(ctx context.Context, appName, userID, sessionID string) {
+	key := buildEventRateKey(appName, userID, sessionID)
+	if err := refundTokenScript.Run(ctx, s.rdb, []string{key}, s.rateLimit.Burst).Err(); err != nil {
+		s.logger.Warnf("failed to refund the append token of session %s: %v", sessionID, err)
+	}
+}
@@ -0,0 +1,127 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/adk/session"
+)
+
+func TestWithAppendRateLimit(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     AppendRateLimit
+		wantBurst int
+		wantNil   bool
+	}{
+		{name: "zero rate is ignored", limit: AppendRateLimit{}, wantNil: true},
+		{name: "burst defaults to the rate", limit: AppendRateLimit{Rate: 2.5}, wantBurst: 3},
+		{name: "burst is at least 1", limit: AppendRateLimit{Rate: 0.1}, wantBurst: 1},
+		{name: "burst is kept", limit: AppendRateLimit{Rate: 1, Burst: 10}, wantBurst: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &RedisSessionService{}
+			WithAppendRateLimit(tt.limit)(svc)
+
+			if tt.wantNil {
+				if svc.rateLimit != nil {
+					t.Fatalf("rateLimit = %+v, want nil", svc.rateLimit)
+				}
+				return
+			}
+			if svc.rateLimit == nil || svc.rateLimit.Burst != tt.wantBurst {
+				t.Fatalf("rateLimit = %+v, want burst %d", svc.rateLimit, tt.wantBurst)
+			}
+		})
+	}
+}
+
+func TestAppendRateLimit(t *testing.T) {
+	svc, rdb := setupTestRedis(t, WithAppendRateLimit(AppendRateLimit{Rate: 0.5, Burst: 2}))
+	ctx := context.Background()
+
+	const appName, userID = "ratelimit-app", "ratelimit-user"
+	t.Cleanup(func() { cleanupTestKeys(t, rdb, "*:"+appName+":*") })
+
+	create := func() session.Session {
+		t.Helper()
+		resp, err := svc.Create(ctx, &session.CreateRequest{AppName: appName, UserID: userID})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		return resp.Session
+	}
+
+	flooded := create()
+	for i := range 2 {
+		if err := svc.AppendEvent(ctx, flooded, &session.Event{Author: "agent"}); err != nil {
+			t.Fatalf("AppendEvent() %d error = %v", i, err)
+		}
+	}
+
+	err := svc.AppendEvent(ctx, flooded, &session.Event{Author: "agent"})
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("AppendEvent() beyond the burst error = %v, want ErrRateLimited", err)
+	}
+	var limited *RateLimitError
+	if !errors.As(err, &limited) || limited.SessionID != flooded.ID() || limited.RetryAfter <= 0 {
+		t.Errorf("error = %#v, want a *RateLimitError with a RetryAfter", err)
+	}
+
+	got, err := svc.Get(ctx, &session.GetRequest{AppName: appName, UserID: userID, SessionID: flooded.ID()})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if n := got.Session.Events().Len(); n != 2 {
+		t.Errorf("events = %d, want the refused event left out", n)
+	}
+
+	// Other sessions have their own bucket
+	if err := svc.AppendEvent(ctx, create(), &session.Event{Author: "agent"}); err != nil {
+		t.Errorf("AppendEvent() to another session error = %v", err)
+	}
+}
+
+func TestAppendRateLimitRefund(t *testing.T) {
+	svc, rdb := setupTestRedis(t, WithAppendRateLimit(AppendRateLimit{Rate: 0.01, Burst: 2}))
+	ctx := context.Background()
+
+	const appName, userID = "ratelimit-refund-app", "ratelimit-refund-user"
+	t.Cleanup(func() { cleanupTestKeys(t, rdb, "*:"+appName+":*") })
+
+	_, err := svc.Create(ctx, &session.CreateRequest{
+		AppName: appName, UserID: userID, SessionID: "refund", State: map[string]any{"step": 0},
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	get := func() session.Session {
+		t.Helper()
+		resp, err := svc.Get(ctx, &session.GetRequest{AppName: appName, UserID: userID, SessionID: "refund"})
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		return resp.Session
+	}
+
+	first, second := get(), get()
+	first.State().(*redisState).data.Store("step", 1)
+	if err := svc.AppendEvent(ctx, first, &session.Event{Author: "agent"}); err != nil {
+		t.Fatalf("AppendEvent() error = %v", err)
+	}
+
+	// The conflicting append takes the last token, then gives it back
+	second.State().(*redisState).data.Store("step", 2)
+	if err := svc.AppendEvent(ctx, second, &session.Event{Author: "agent"}); !errors.Is(err, ErrConflict) {
+		t.Fatalf("AppendEvent() error = %v, want ErrConflict", err)
+	}
+
+	third := get()
+	third.State().(*redisState).data.Store("step", 3)
+	if err := svc.AppendEvent(ctx, third, &session.Event{Author: "agent"}); err != nil {
+		t.Errorf("AppendEvent() after a refused append error = %v, want the token refunded", err)
+	}
+}
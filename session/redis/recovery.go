@@ -0,0 +1,207 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bytedance/sonic"
+	ksess "github.com/kydenul/k-adk/session"
+	"google.golang.org/adk/session"
+)
+
+// restore rebuilds the Redis keys of a session evicted from Redis from the loader.
+// It returns false if the loader does not have the session either. A session
+// written to Redis concurrently is kept.
+func (s *RedisSessionService) restore(ctx context.Context, appName, userID, sessionID string) (bool, error) {
+	stored, err := s.loader.LoadSession(ctx, appName, userID, sessionID)
+	if err != nil {
+		s.logger.Errorf("failed to load session %s from the persistent store: %v", sessionID, err)
+		return false, fmt.Errorf("failed to load session: %w", err)
+	}
+	if stored == nil {
+		return false, nil
+	}
+
+	written, err := s.writeStored(ctx, appName, userID, sessionID, stored)
+	if err != nil {
+		return false, err
+	}
+	if !written {
+		s.logger.Debugf("session %s recreated concurrently, skipping restore", sessionID)
+		return true, nil
+	}
+
+	s.logger.Infof("session restored from the persistent store: session=%s, events=%d",
+		sessionID, len(stored.Events))
+
+	return true, nil
+}
+
+// writeStored writes the Redis keys of a stored session, under its own TTL, the TTL
+// of its tenant or the service TTL, and adds it to the index of its user. It returns
+// false, writing nothing, if the session is in Redis already.
+func (s *RedisSessionService) writeStored(
+	ctx context.Context,
+	appName, userID, sessionID string,
+	stored *ksess.StoredSession,
+) (bool, error) {
+	key := buildSessionKey(appName, userID, sessionID)
+	evKey := buildEventsKey(appName, userID, sessionID)
+	restored := s.storedStorable(appName, userID, sessionID, stored)
+	ttl := s.sessionTTL(restored.TTL)
+
+	state, err := s.stateCipher(appName).seal(ctx, stored.State)
+	if err != nil {
+		return false, err
+	}
+	restored.State = state
+
+	data, err := sonic.Marshal(restored)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal session: %w", err)
+	}
+
+	values := make([]any, 0, len(stored.Events))
+	sizes := make([]int, 0, len(stored.Events))
+	for _, evt := range stored.Events {
+		evtData, err := s.codec.forApp(appName).marshal(ctx, evt)
+		if err != nil {
+			return false, fmt.Errorf("failed to marshal event: %w", err)
+		}
+		values = append(values, evtData)
+		sizes = append(sizes, len(evtData))
+	}
+
+	// NOTE: Only the events within the trim policy are restored, the others are
+	// counted as trimmed
+	trimmed := 0
+	if s.trim != nil {
+		trimmed = s.trim.excess(sizes)
+		values = values[trimmed:]
+	}
+
+	ok, err := s.rdb.SetNX(ctx, key, data, ttl).Result()
+	if err != nil {
+		s.logger.Errorf("failed to write session %s to redis: %v", sessionID, err)
+		return false, fmt.Errorf("failed to set session: %w", err)
+	}
+	if !ok {
+		return false, nil
+	}
+
+	if len(values) > 0 {
+		pipe := s.rdb.TxPipeline()
+		pipe.Del(ctx, evKey)
+		pipe.RPush(ctx, evKey, values...)
+		pipe.Expire(ctx, evKey, ttl)
+		pipe.Del(ctx, buildEventTrimKey(appName, userID, sessionID))
+		if trimmed > 0 {
+			pipe.Set(ctx, buildEventTrimKey(appName, userID, sessionID), trimmed, ttl)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			s.rdb.Del(ctx, key)
+			s.logger.Errorf("failed to write events of session %s: %v", sessionID, err)
+			return false, fmt.Errorf("failed to write events: %w", err)
+		}
+	}
+
+	s.armExpiry(ctx, appName, userID, sessionID, ttl)
+
+	if err := s.indexAdd(ctx, appName, userID, sessionID, ttl); err != nil {
+		s.logger.Errorf("failed to add session %s to index: %v", sessionID, err)
+		return false, fmt.Errorf("failed to add session to index: %w", err)
+	}
+
+	s.reindexState(ctx, appName, userID, sessionID, stored.State, ttl)
+
+	return true, nil
+}
+
+// storedStorable returns the session record of a stored session, under the identity
+// of the request. The state log is gone with the session, so the state starts a new
+// history.
+func (s *RedisSessionService) storedStorable(
+	appName, userID, sessionID string,
+	stored *ksess.StoredSession,
+) *storableSession {
+	// NOTE: A TTL requested for the session wins over the TTL of its tenant
+	ownTTL := stored.TTL
+	if ownTTL <= 0 {
+		ownTTL = s.tenant(appName).TTL
+	}
+
+	return &storableSession{
+		ID:             sessionID,
+		AppName:        appName,
+		UserID:         userID,
+		State:          stored.State,
+		LastUpdateTime: stored.LastUpdateTime,
+		StateVersion:   1,
+		ParentID:       stored.ParentID,
+		ForkEventID:    stored.ForkEventID,
+		Tags:           stored.Tags,
+		TTL:            int64(ownTTL.Seconds()),
+	}
+}
+
+// recoverList returns the sessions of a user kept by the loader but missing from
+// Redis, skipping the listed ones, as List does. They are read from the loader
+// only: Get restores a session into Redis when it is used.
+func (s *RedisSessionService) recoverList(
+	ctx context.Context,
+	appName, userID string,
+	listed map[string]bool,
+) ([]session.Session, error) {
+	ids, err := s.loader.ListSessionIDs(ctx, appName, userID)
+	if err != nil {
+		s.logger.Errorf("failed to list sessions of user %s from the persistent store: %v", userID, err)
+		return nil, fmt.Errorf("failed to list stored sessions: %w", err)
+	}
+
+	var sessions []session.Session
+	for _, sessionID := range ids {
+		if listed[sessionID] {
+			continue
+		}
+
+		stored, err := s.loader.LoadSession(ctx, appName, userID, sessionID)
+		if err != nil {
+			s.logger.Errorf("failed to load session %s from the persistent store: %v", sessionID, err)
+			return nil, fmt.Errorf("failed to load session: %w", err)
+		}
+		if stored == nil {
+			continue
+		}
+
+		sessions = append(sessions, s.listedSession(s.storedStorable(appName, userID, sessionID, stored)))
+	}
+
+	return sessions, nil
+}
+
+// listedSession builds the session of a List result, whose events are loaded on demand.
+func (s *RedisSessionService) listedSession(storable *storableSession) *redisSession {
+	key := buildSessionKey(storable.AppName, storable.UserID, storable.ID)
+	evKey := buildEventsKey(storable.AppName, storable.UserID, storable.ID)
+	logKey := buildStateLogKey(storable.AppName, storable.UserID, storable.ID)
+	ttl := s.sessionTTL(storable.TTL)
+
+	sess := &redisSession{
+		id:             storable.ID,
+		appName:        storable.AppName,
+		userID:         storable.UserID,
+		state:          newRedisState(storable.State, storable.StateVersion, s.rdb, key, logKey, ttl, s.logger),
+		events:         newRedisEvents(nil, s.rdb, evKey, s.codec.forApp(storable.AppName), s.logger),
+		lastUpdateTime: storable.LastUpdateTime,
+		parentID:       storable.ParentID,
+		forkEventID:    storable.ForkEventID,
+		tags:           storable.Tags,
+		ttl:            time.Duration(storable.TTL) * time.Second,
+	}
+	sess.state.reindex = s.stateReindexer(storable.AppName, storable.UserID, storable.ID, ttl)
+	sess.state.validate = s.stateValidator(storable.AppName)
+	sess.state.cipher = s.stateCipher(storable.AppName)
+
+	return sess
+}
@@ -0,0 +1,140 @@
+package redis
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	ksess "github.com/kydenul/k-adk/session"
+	"google.golang.org/adk/session"
+)
+
+// memLoader is an in-memory ksess.Loader.
+type memLoader struct {
+	sessions map[string]*ksess.StoredSession
+}
+
+func (l *memLoader) LoadSession(
+	_ context.Context,
+	_, _, sessionID string,
+) (*ksess.StoredSession, error) {
+	return l.sessions[sessionID], nil
+}
+
+func (l *memLoader) ListSessionIDs(_ context.Context, _, _ string) ([]string, error) {
+	ids := make([]string, 0, len(l.sessions))
+	for id := range l.sessions {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (l *memLoader) ListRecentSessions(
+	_ context.Context,
+	appName string,
+	since time.Time,
+	after *ksess.SessionRef,
+	limit int,
+) ([]ksess.SessionRef, error) {
+	var refs []ksess.SessionRef
+	for _, stored := range l.sessions {
+		if stored.AppName == appName && !stored.LastUpdateTime.Before(since) {
+			refs = append(refs, ksess.SessionRef{
+				AppName: appName, UserID: stored.UserID, ID: stored.ID, LastUpdateTime: stored.LastUpdateTime,
+			})
+		}
+	}
+	slices.SortFunc(refs, func(a, b ksess.SessionRef) int {
+		return cmp.Or(b.LastUpdateTime.Compare(a.LastUpdateTime), cmp.Compare(b.ID, a.ID))
+	})
+
+	if after != nil {
+		i := slices.IndexFunc(refs, func(r ksess.SessionRef) bool { return r.ID == after.ID })
+		refs = refs[i+1:]
+	}
+	return refs[:min(limit, len(refs))], nil
+}
+
+func TestReadThroughRecovery(t *testing.T) {
+	const (
+		appName = "test_recovery_app"
+		userID  = "test_recovery_user"
+	)
+
+	loader := &memLoader{sessions: map[string]*ksess.StoredSession{
+		"evicted": {
+			ID: "evicted", AppName: appName, UserID: userID,
+			State:          map[string]any{"topic": "go"},
+			Events:         []*session.Event{{ID: "e1", Author: "user"}, {ID: "e2", Author: "agent"}},
+			LastUpdateTime: time.Now().Add(-time.Hour),
+			ParentID:       "root",
+			ForkEventID:    "e0",
+			TTL:            2 * time.Hour,
+		},
+		"evicted-2": {ID: "evicted-2", AppName: appName, UserID: userID, State: map[string]any{}},
+	}}
+
+	svc, rdb := setupTestRedis(t, WithTTL(30*time.Second), WithLoader(loader))
+	t.Cleanup(func() {
+		cleanupTestKeys(t, rdb,
+			fmt.Sprintf("session:%s:*", appName),
+			fmt.Sprintf("events:%s:*", appName),
+			fmt.Sprintf("statelog:%s:*", appName),
+		)
+	})
+
+	ctx := context.Background()
+
+	t.Run("get restores an evicted session", func(t *testing.T) {
+		resp, err := svc.Get(ctx, &session.GetRequest{AppName: appName, UserID: userID, SessionID: "evicted"})
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if ids := eventIDs(resp.Session); fmt.Sprint(ids) != "[e1 e2]" {
+			t.Errorf("expected [e1 e2], got %v", ids)
+		}
+		if v, _ := resp.Session.State().Get("topic"); v != "go" {
+			t.Errorf("expected restored state, got %v", v)
+		}
+
+		forked := resp.Session.(ksess.Forked)
+		if forked.ParentID() != "root" || forked.ForkEventID() != "e0" {
+			t.Errorf("expected the branch link restored, got %q, %q", forked.ParentID(), forked.ForkEventID())
+		}
+		if ttl := resp.Session.(ksess.Expiring).TTL(); ttl != 2*time.Hour {
+			t.Errorf("expected the session TTL restored, got %v", ttl)
+		}
+
+		ttl, err := rdb.TTL(ctx, buildSessionKey(appName, userID, "evicted")).Result()
+		if err != nil || ttl <= 30*time.Second {
+			t.Errorf("expected the session cached again under its own TTL, got %v, %v", ttl, err)
+		}
+	})
+
+	t.Run("list reads evicted sessions from the loader", func(t *testing.T) {
+		resp, err := svc.List(ctx, &session.ListRequest{AppName: appName, UserID: userID})
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if len(resp.Sessions) != 2 {
+			t.Errorf("expected 2 sessions, got %d", len(resp.Sessions))
+		}
+
+		// NOTE: Only Get writes a session back to Redis
+		n, err := rdb.Exists(ctx, buildSessionKey(appName, userID, "evicted-2")).Result()
+		if err != nil || n != 0 {
+			t.Errorf("expected the listed session left out of Redis, got %d, %v", n, err)
+		}
+	})
+
+	t.Run("missing everywhere", func(t *testing.T) {
+		_, err := svc.Get(ctx, &session.GetRequest{AppName: appName, UserID: userID, SessionID: "nope"})
+		if !errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("expected ErrSessionNotFound, got %v", err)
+		}
+	})
+}
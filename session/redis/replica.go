@@ -0,0 +1,21 @@
+package redis
+
+import "github.com/redis/go-redis/v9"
+
+// WithReadClient reads the sessions of List and FindByState from reader, e.g. a
+// client of the Redis replicas, while every write, the session index, Get and
+// GetBranch go to the client passed to NewRedisSessionService: the session of a
+// Get feeds the state version checked by its writes, and a lagging replica would
+// fail them. A session not replicated yet is read from the primary; a listed
+// session may miss the changes of its last moments.
+func WithReadClient(reader redis.UniversalClient) ServiceOption {
+	return func(s *RedisSessionService) { s.reader = reader }
+}
+
+// readClient returns the client serving the session reads of List and FindByState.
+func (s *RedisSessionService) readClient() redis.UniversalClient {
+	if s.reader != nil {
+		return s.reader
+	}
+	return s.rdb
+}
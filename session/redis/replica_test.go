@@ -0,0 +1,114 @@
+package redis
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bytedance/sonic"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/adk/session"
+)
+
+// setupTestReplica returns a client of another database of the test Redis, standing
+// in for a replica that has not replicated anything yet.
+func setupTestReplica(t *testing.T) redis.UniversalClient {
+	t.Helper()
+
+	replica := redis.NewUniversalClient(&redis.UniversalOptions{
+		Addrs: []string{getTestRedisAddr()},
+		DB:    1,
+	})
+	t.Cleanup(func() { _ = replica.Close() })
+
+	return replica
+}
+
+func TestReadClient(t *testing.T) {
+	ctx := context.Background()
+	const appName, userID = "replica-app", "replica-user"
+
+	// NOTE: Skips without Redis, before the replica is used
+	_, rdb := setupTestRedis(t)
+	replica := setupTestReplica(t)
+	t.Cleanup(func() {
+		cleanupTestKeys(t, rdb, "*:"+appName+":*")
+		cleanupTestKeys(t, replica, "*:"+appName+":*")
+	})
+
+	svc, err := NewRedisSessionService(rdb, WithReadClient(replica))
+	if err != nil {
+		t.Fatalf("NewRedisSessionService() error = %v", err)
+	}
+
+	created, err := svc.Create(ctx, &session.CreateRequest{
+		AppName: appName,
+		UserID:  userID,
+		State:   map[string]any{"step": "primary"},
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := svc.AppendEvent(ctx, created.Session, &session.Event{ID: "e1", Author: "user"}); err != nil {
+		t.Fatalf("AppendEvent() error = %v", err)
+	}
+	get := &session.GetRequest{AppName: appName, UserID: userID, SessionID: created.Session.ID()}
+
+	t.Run("falls back to the primary", func(t *testing.T) {
+		got, err := svc.Get(ctx, get)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if n := got.Session.Events().Len(); n != 1 {
+			t.Errorf("events = %d, want 1", n)
+		}
+
+		listed, err := svc.List(ctx, &session.ListRequest{AppName: appName, UserID: userID})
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		if len(listed.Sessions) != 1 {
+			t.Errorf("listed %d sessions, want 1", len(listed.Sessions))
+		}
+	})
+
+	t.Run("lists from the replica", func(t *testing.T) {
+		// Replicate the session with another state
+		key := buildSessionKey(appName, userID, created.Session.ID())
+		data, err := rdb.Get(ctx, key).Result()
+		if err != nil {
+			t.Fatalf("GET error = %v", err)
+		}
+		var storable storableSession
+		if err := sonic.UnmarshalString(data, &storable); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		storable.State = map[string]any{"step": "replica"}
+		replicated, _ := sonic.MarshalString(&storable)
+		if err := replica.Set(ctx, key, replicated, 0).Err(); err != nil {
+			t.Fatalf("SET error = %v", err)
+		}
+
+		// Get reads the primary, whose state version the writes check
+		got, err := svc.Get(ctx, get)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if step, _ := got.Session.State().Get("step"); step != "primary" {
+			t.Errorf("step = %v, want the primary's", step)
+		}
+		if err := got.Session.State().Set("step", "next"); err != nil {
+			t.Errorf("Set() error = %v", err)
+		}
+
+		listed, err := svc.List(ctx, &session.ListRequest{AppName: appName, UserID: userID})
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		if len(listed.Sessions) != 1 {
+			t.Fatalf("listed %d sessions, want 1", len(listed.Sessions))
+		}
+		if step, _ := listed.Sessions[0].State().Get("step"); step != "replica" {
+			t.Errorf("listed step = %v, want the replica's", step)
+		}
+	})
+}
@@ -0,0 +1,32 @@
+package redis
+
+import (
+	ksess "github.com/kydenul/k-adk/session"
+)
+
+// WithStateSchema validates the state of the sessions of appName against schema:
+// Create refuses an initial state breaking it, and the state writes of the
+// sessions (Set, ApplyDelta, Delete) the keys they would break, with a
+// *ksess.StateValidationError listing the violating keys. Refused writes leave the
+// session state unchanged. Call it once per app.
+func WithStateSchema(appName string, schema *ksess.StateSchema) ServiceOption {
+	return func(s *RedisSessionService) {
+		if schema == nil {
+			return
+		}
+		if s.stateSchemas == nil {
+			s.stateSchemas = make(map[string]*ksess.StateSchema)
+		}
+		s.stateSchemas[appName] = schema
+	}
+}
+
+// stateValidator returns the hook validating the state writes of the sessions of
+// appName, nil without a schema.
+func (s *RedisSessionService) stateValidator(appName string) func(set map[string]any, removed []string) error {
+	schema := s.stateSchemas[appName]
+	if schema == nil {
+		return nil
+	}
+	return schema.ValidateDelta
+}
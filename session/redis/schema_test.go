@@ -0,0 +1,89 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	ksess "github.com/kydenul/k-adk/session"
+	"google.golang.org/adk/session"
+)
+
+func testStateSchema(t *testing.T) *ksess.StateSchema {
+	t.Helper()
+
+	schema, err := ksess.ParseStateSchema([]byte(`{
+		"type": "object",
+		"properties": {
+			"topic": {"type": "string"},
+			"turns": {"type": "integer", "minimum": 0}
+		},
+		"required": ["topic"]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseStateSchema() error = %v", err)
+	}
+	return schema
+}
+
+func TestStateSchemaLocal(t *testing.T) {
+	svc := &RedisSessionService{}
+	WithStateSchema("app", testStateSchema(t))(svc)
+
+	state := newRedisState(map[string]any{"topic": "billing"}, 1, nil, "key", "log", 0, nil)
+	state.validate = svc.stateValidator("app")
+
+	if err := state.Set("turns", -1); !errors.Is(err, ksess.ErrInvalidState) {
+		t.Fatalf("Set() error = %v, want ErrInvalidState", err)
+	}
+	if _, err := state.Get("turns"); !errors.Is(err, session.ErrStateKeyNotExist) {
+		t.Errorf("refused key was stored: %v", err)
+	}
+
+	if err := state.Delete("topic"); !errors.Is(err, ksess.ErrInvalidState) {
+		t.Errorf("Delete() error = %v, want ErrInvalidState", err)
+	}
+	if err := state.ApplyDelta(map[string]any{"turns": 2}); err != nil {
+		t.Errorf("ApplyDelta() error = %v", err)
+	}
+
+	if svc.stateValidator("other") != nil {
+		t.Error("stateValidator() of an app without schema is not nil")
+	}
+}
+
+func TestStateSchemaCreate(t *testing.T) {
+	svc, rdb := setupTestRedis(t, WithStateSchema("schema-app", testStateSchema(t)))
+	ctx := context.Background()
+
+	const appName, userID = "schema-app", "schema-user"
+	t.Cleanup(func() { cleanupTestKeys(t, rdb, "*:"+appName+":*") })
+
+	_, err := svc.Create(ctx, &session.CreateRequest{
+		AppName: appName, UserID: userID,
+		State: map[string]any{"turns": "many"},
+	})
+	var invalid *ksess.StateValidationError
+	if !errors.As(err, &invalid) || len(invalid.Violations) != 2 {
+		t.Fatalf("Create() error = %v, want 2 violations", err)
+	}
+
+	resp, err := svc.Create(ctx, &session.CreateRequest{
+		AppName: appName, UserID: userID,
+		State: map[string]any{"topic": "billing"},
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	got, err := svc.Get(ctx, &session.GetRequest{AppName: appName, UserID: userID, SessionID: resp.Session.ID()})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if err := got.Session.State().Set("topic", 42); !errors.Is(err, ksess.ErrInvalidState) {
+		t.Errorf("Set() error = %v, want ErrInvalidState", err)
+	}
+	if err := got.Session.State().Set("turns", 1); err != nil {
+		t.Errorf("Set() error = %v", err)
+	}
+}
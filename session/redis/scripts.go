@@ -0,0 +1,17 @@
+package redis
+
+import (
+	"context"
+
+	"github.com/kydenul/k-adk/internal/redisscript"
+	"github.com/redis/go-redis/v9"
+)
+
+// PruneScripts unloads from Redis 7+ the function libraries of the Lua scripts of
+// this module left by previous releases, on every master of a cluster, and returns
+// the number unloaded. The functions are named after a hash of their script, so
+// each release loads its own. Run it once every instance sharing the Redis runs the
+// current release; the scripts of the packages not linked in the binary are kept.
+func PruneScripts(ctx context.Context, rdb redis.UniversalClient) (int, error) {
+	return redisscript.Default.Prune(ctx, rdb)
+}
@@ -0,0 +1,68 @@
+package redis
+
+import (
+	"reflect"
+
+	"github.com/bytedance/sonic"
+	"github.com/ugorji/go/codec"
+)
+
+// Serializer serializes the events stored in Redis.
+type Serializer interface {
+	// Marshal serializes v.
+	Marshal(v any) ([]byte, error)
+
+	// Unmarshal deserializes data into v.
+	Unmarshal(data []byte, v any) error
+}
+
+// WithSerializer serializes the events written to Redis with sz instead of JSON,
+// e.g. MsgpackSerializer(). Events written in JSON stay readable. The session key
+// is kept as JSON, for the scripts updating its state.
+func WithSerializer(sz Serializer) ServiceOption {
+	return func(s *RedisSessionService) { s.codec.serializer = sz }
+}
+
+// jsonSerializer serializes with sonic.
+type jsonSerializer struct{}
+
+// JSONSerializer returns the default Serializer, encoding JSON with sonic.
+func JSONSerializer() Serializer { return jsonSerializer{} }
+
+func (jsonSerializer) Marshal(v any) ([]byte, error) { return sonic.Marshal(v) }
+
+func (jsonSerializer) Unmarshal(data []byte, v any) error { return sonic.Unmarshal(data, v) }
+
+// msgpackSerializer serializes with MessagePack.
+type msgpackSerializer struct {
+	handle *codec.MsgpackHandle
+}
+
+// MsgpackSerializer returns a Serializer encoding MessagePack, smaller and cheaper
+// to encode than JSON. Field names follow the json struct tags. Numbers in
+// untyped values, e.g. the state delta of an event, decode as int64 or float64,
+// where JSON decodes them all as float64.
+func MsgpackSerializer() Serializer {
+	h := &codec.MsgpackHandle{WriteExt: true}
+	h.MapType = reflect.TypeFor[map[string]any]()
+	h.RawToString = true
+	h.SignedInteger = true
+
+	return msgpackSerializer{handle: h}
+}
+
+func (m msgpackSerializer) Marshal(v any) ([]byte, error) {
+	var data []byte
+	err := codec.NewEncoderBytes(&data, m.handle).Encode(v)
+	return data, err
+}
+
+func (m msgpackSerializer) Unmarshal(data []byte, v any) error {
+	return codec.NewDecoderBytes(data, m.handle).Decode(v)
+}
+
+// isJSONObject reports whether data is a JSON object. A MessagePack map never
+// starts with '{'.
+func isJSONObject(data []byte) bool {
+	return len(data) > 0 && data[0] == '{'
+}
@@ -0,0 +1,91 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kydenul/k-adk/compression"
+	"google.golang.org/adk/model"
+	"google.golang.org/adk/session"
+	"google.golang.org/genai"
+)
+
+func testEvent() *session.Event {
+	evt := session.NewEvent("inv-1")
+	evt.ID = "e1"
+	evt.Author = "agent"
+	evt.Branch = "root.agent"
+	evt.Timestamp = time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC)
+	evt.LLMResponse = model.LLMResponse{
+		Content: &genai.Content{Role: genai.RoleModel, Parts: []*genai.Part{
+			genai.NewPartFromText("Looking up the weather"),
+			genai.NewPartFromFunctionCall("get_weather", map[string]any{"city": "Paris", "days": 3}),
+		}},
+		TurnComplete: true,
+	}
+	evt.Actions.StateDelta = map[string]any{"city": "Paris", "nested": map[string]any{"ok": true}}
+
+	return evt
+}
+
+func TestSerializers(t *testing.T) {
+	evt := testEvent()
+
+	for name, sz := range map[string]Serializer{"json": JSONSerializer(), "msgpack": MsgpackSerializer()} {
+		t.Run(name, func(t *testing.T) {
+			data, err := sz.Marshal(evt)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+
+			var got session.Event
+			if err := sz.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+
+			if got.ID != "e1" || got.Author != "agent" || got.Branch != "root.agent" || !got.TurnComplete {
+				t.Errorf("unexpected event: %+v", got)
+			}
+			if !got.Timestamp.Equal(evt.Timestamp) {
+				t.Errorf("timestamp = %v, want %v", got.Timestamp, evt.Timestamp)
+			}
+			if got.Content == nil || len(got.Content.Parts) != 2 || got.Content.Parts[0].Text != "Looking up the weather" {
+				t.Fatalf("unexpected content: %+v", got.Content)
+			}
+			call := got.Content.Parts[1].FunctionCall
+			if call == nil || call.Name != "get_weather" || call.Args["city"] != "Paris" {
+				t.Errorf("unexpected function call: %+v", call)
+			}
+			if nested, _ := got.Actions.StateDelta["nested"].(map[string]any); nested["ok"] != true {
+				t.Errorf("unexpected state delta: %v", got.Actions.StateDelta)
+			}
+		})
+	}
+}
+
+func TestEventCodec(t *testing.T) {
+	ctx := context.Background()
+	evt := testEvent()
+
+	jsonData, err := eventCodec{}.marshal(ctx, evt)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	msgpack := eventCodec{serializer: MsgpackSerializer(), compression: compression.Snappy()}
+	msgpackData, err := msgpack.marshal(ctx, evt)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	if len(msgpackData) >= len(jsonData) {
+		t.Errorf("msgpack event = %d bytes, want less than the %d bytes of JSON", len(msgpackData), len(jsonData))
+	}
+
+	// NOTE: JSON events stay readable once the serializer is set
+	for name, data := range map[string][]byte{"json": jsonData, "msgpack": msgpackData} {
+		var got session.Event
+		if err := msgpack.unmarshal(ctx, string(data), &got); err != nil || got.ID != "e1" {
+			t.Errorf("unmarshal %s event = %v, %v", name, got.ID, err)
+		}
+	}
+}
@@ -7,9 +7,11 @@ import (
 	"errors"
 	"fmt"
 	"maps"
+	"sync"
 	"time"
 
 	"github.com/bytedance/sonic"
+	"github.com/kydenul/k-adk/encryption"
 	discardlog "github.com/kydenul/k-adk/internal/discard_log"
 	ksess "github.com/kydenul/k-adk/session"
 	"github.com/kydenul/log"
@@ -29,7 +31,7 @@ const (
 )
 
 var (
-	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionNotFound = ksess.ErrSessionNotFound
 	ErrNilSession      = errors.New("session cannot be nil")
 	ErrNilRedisClient  = errors.New("redis client cannot be nil")
 )
@@ -37,6 +39,8 @@ var (
 // RedisSessionService implements session.Service with Redis as the backend.
 type RedisSessionService struct {
 	rdb redis.UniversalClient
+	// Optional. reader serves the session reads of List, see WithReadClient.
+	reader redis.UniversalClient
 
 	// Optional.
 	logger log.Logger
@@ -44,6 +48,58 @@ type RedisSessionService struct {
 	persister ksess.Persister
 	// ttl is the session expiration time (default: 7 days).
 	ttl time.Duration
+	// Optional. maxTTL caps the per-session TTLs, 0 for no limit.
+	maxTTL time.Duration
+
+	// Optional. loader rebuilds the sessions evicted from Redis.
+	loader ksess.Loader
+
+	// Optional. eventSizes records the sizes of the appended events.
+	eventSizes *EventSizeTracker
+
+	// Optional. idPolicy validates the client-supplied session IDs.
+	idPolicy *ksess.IDPolicy
+
+	// Optional. trim bounds the event list of the sessions.
+	trim *TrimPolicy
+
+	// Optional. stateKeys are the state keys of the state index, see WithStateIndex.
+	stateKeys []string
+
+	// Optional. stateSchemas validate the session states by app name, see
+	// WithStateSchema.
+	stateSchemas map[string]*ksess.StateSchema
+
+	// Optional. rateLimit bounds the rate of the events appended to each session.
+	rateLimit *AppendRateLimit
+
+	// Optional. tenants holds the quotas and TTLs of the apps, see WithTenants.
+	tenants *TenantRegistry
+
+	// Optional. codec serializes and compresses the events.
+	codec eventCodec
+
+	// Optional. encryptor encrypts the events and state values, see WithEncryption.
+	encryptor *encryption.Encryptor
+
+	// Optional. notifications publishes the session changes, see Subscribe.
+	notifications bool
+
+	// Optional. expiryLead is how long before its session a shadow key expires,
+	// 0 to not persist the sessions on expiry. See WatchExpiry.
+	expiryLead time.Duration
+
+	// Optional. stampEvents replaces the caller-supplied event timestamps with the
+	// time of the append.
+	stampEvents bool
+
+	// Optional. metrics receives the measurements of the service, see WithMetrics.
+	metrics Metrics
+
+	// Optional. buckets of the bucketed session index, 0 for per-user sets.
+	buckets int
+	// bucketCounts caches the registered bucket count of every app.
+	bucketCounts sync.Map
 }
 
 // ServiceOption configures the RedisSessionService.
@@ -66,6 +122,38 @@ func WithTTL(ttl time.Duration) ServiceOption {
 	return func(s *RedisSessionService) { s.ttl = ttl }
 }
 
+// WithLoader sets the optional loader rebuilding the sessions missing from Redis,
+// e.g. after their TTL expired, on Get and List. It is usually the persister:
+//
+//	ksess.WithPersister(pgPersister), ksess.WithLoader(pgPersister)
+//
+// With an async persister, a session read right after Delete may be restored
+// before its deletion reaches the store.
+func WithLoader(l ksess.Loader) ServiceOption {
+	return func(s *RedisSessionService) { s.loader = l }
+}
+
+// WithEventSizeTracker records the serialized size of every appended event in t,
+// and flags the sessions whose event list crosses its thresholds. The event list
+// size of each session is counted in an extra key, "evbytes:{app}:{user}:{session}".
+func WithEventSizeTracker(t *EventSizeTracker) ServiceOption {
+	return func(s *RedisSessionService) { s.eventSizes = t }
+}
+
+// WithIDPolicy validates the session IDs supplied to Create and CreateBranch
+// against p, or replaces them with generated IDs if p.AlwaysGenerate is set.
+// Invalid IDs fail with an error matching ksess.ErrInvalidSessionID.
+func WithIDPolicy(p ksess.IDPolicy) ServiceOption {
+	return func(s *RedisSessionService) { s.idPolicy = &p }
+}
+
+// WithAppendTimestamps stamps every appended event with the time of the append,
+// replacing the timestamp set by the caller. By default only the events without a
+// timestamp are stamped, so replayed or imported events keep their own.
+func WithAppendTimestamps() ServiceOption {
+	return func(s *RedisSessionService) { s.stampEvents = true }
+}
+
 // NewRedisSessionService creates a new RedisSessionService.
 // If ttl is <= 0, DefaultSessionTTL (7 days) will be used.
 // If logger is nil, a no-op logger will be used internally.
@@ -96,6 +184,19 @@ func NewRedisSessionService(
 	if svc.persister != nil {
 		svc.logger.Info("PostgreSQL persister enabled for long-term session storage")
 	}
+	if svc.loader != nil {
+		svc.logger.Info("read-through recovery of evicted sessions enabled")
+	}
+	if svc.reader != nil {
+		svc.logger.Info("session reads routed to the read client")
+	}
+	if svc.metrics != nil {
+		hook := metricsHook{owner: svc, metrics: svc.metrics}
+		rdb.AddHook(hook)
+		if svc.reader != nil && svc.reader != rdb {
+			svc.reader.AddHook(hook)
+		}
+	}
 
 	return svc, nil
 }
@@ -112,6 +213,40 @@ func buildEventsKey(appName, userID, sessionID string) string {
 	return fmt.Sprintf("events:%s:%s:%s", appName, userID, sessionID)
 }
 
+func buildStateLogKey(appName, userID, sessionID string) string {
+	return fmt.Sprintf("statelog:%s:%s:%s", appName, userID, sessionID)
+}
+
+func buildEventBytesKey(appName, userID, sessionID string) string {
+	return fmt.Sprintf("evbytes:%s:%s:%s", appName, userID, sessionID)
+}
+
+// readSession reads the session at key and its events from start, in a single
+// transaction so they match. It fails with redis.Nil if the session is missing.
+//
+// NOTE: The session is read from the primary, never from the read client: its
+// state version is checked by the writes of the session.
+func (s *RedisSessionService) readSession(
+	ctx context.Context,
+	key, evKey string,
+	start int64,
+) (data []byte, eventData []string, err error) {
+	pipe := s.rdb.TxPipeline()
+	sessCmd := pipe.Get(ctx, key)
+	eventsCmd := pipe.LRange(ctx, evKey, start, -1)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, nil, err
+	}
+
+	if data, err = sessCmd.Bytes(); err != nil {
+		return nil, nil, err
+	}
+	if eventData, err = eventsCmd.Result(); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, nil, fmt.Errorf("failed to get events: %w", err)
+	}
+	return data, eventData, nil
+}
+
 // generateSessionID generates a unique session ID using crypto/rand.
 func generateSessionID() string {
 	b := make([]byte, sessionIDByteLength)
@@ -124,15 +259,63 @@ func generateSessionID() string {
 	return hex.EncodeToString(b)
 }
 
+// resolveSessionID returns the ID of a new session: the requested ID, validated by
+// the ID policy if one is set, or a generated ID.
+func (s *RedisSessionService) resolveSessionID(requested string) (string, error) {
+	if s.idPolicy != nil {
+		return s.idPolicy.Resolve(requested, generateSessionID)
+	}
+	if requested == "" {
+		return generateSessionID(), nil
+	}
+	return requested, nil
+}
+
 // Create creates a new session.
 func (s *RedisSessionService) Create(
 	ctx context.Context,
 	req *session.CreateRequest,
+) (*session.CreateResponse, error) {
+	start := time.Now()
+	resp, err := s.create(s.metricsContext(ctx), req)
+	s.observe(OperationCreate, start, err)
+	return resp, err
+}
+
+func (s *RedisSessionService) create(
+	ctx context.Context,
+	req *session.CreateRequest,
 ) (*session.CreateResponse, error) {
 	// NOTE: build redis session
-	sessionID := req.SessionID
-	if sessionID == "" {
-		sessionID = generateSessionID()
+	sessionID, err := s.resolveSessionID(req.SessionID)
+	if err != nil {
+		s.logger.Warnf("rejected session ID: app=%s, user=%s, err=%v", req.AppName, req.UserID, err)
+		return nil, err
+	}
+
+	// NOTE: The TTL requested in the initial state is not part of the state
+	state := maps.Clone(req.State)
+	ttl, err := s.takeSessionTTL(state)
+	if err != nil {
+		s.logger.Warnf("rejected session TTL: app=%s, user=%s, err=%v", req.AppName, req.UserID, err)
+		return nil, err
+	}
+
+	// NOTE: A tenant TTL is kept by the session, like a requested one
+	if ttl == 0 {
+		ttl = s.tenant(req.AppName).TTL
+	}
+
+	if schema := s.stateSchemas[req.AppName]; schema != nil {
+		if err := schema.Validate(state); err != nil {
+			s.logger.Warnf("rejected session state: app=%s, user=%s, err=%v", req.AppName, req.UserID, err)
+			return nil, err
+		}
+	}
+
+	if err := s.checkSessionQuota(ctx, req.AppName, req.UserID); err != nil {
+		s.logger.Warnf("session refused: app=%s, user=%s, err=%v", req.AppName, req.UserID, err)
+		return nil, err
 	}
 
 	s.logger.Debugf("creating session: app=%s, user=%s, session=%s",
@@ -140,42 +323,53 @@ func (s *RedisSessionService) Create(
 
 	key := buildSessionKey(req.AppName, req.UserID, sessionID)
 	evKey := buildEventsKey(req.AppName, req.UserID, sessionID)
+	logKey := buildStateLogKey(req.AppName, req.UserID, sessionID)
 
 	sess := &redisSession{
 		id:             sessionID,
 		appName:        req.AppName,
 		userID:         req.UserID,
-		state:          newRedisState(req.State, s.rdb, key, s.ttl, s.logger),
-		events:         newRedisEvents(nil, s.rdb, evKey, s.logger),
+		state:          newRedisState(state, 1, s.rdb, key, logKey, s.sessionTTL(int64(ttl.Seconds())), s.logger),
+		events:         newRedisEvents(nil, s.rdb, evKey, s.codec.forApp(req.AppName), s.logger),
 		lastUpdateTime: time.Now(),
+		ttl:            ttl,
 	}
+	sess.state.reindex = s.stateReindexer(req.AppName, req.UserID, sessionID, sess.state.ttl)
+	sess.state.validate = s.stateValidator(req.AppName)
+	sess.state.cipher = s.stateCipher(req.AppName)
 
-	// NOTE: Marshal and Set session to redis
-	data, err := sonic.Marshal(sess.toStorable())
+	// NOTE: Marshal and Set session to redis, with the initial state as version 1
+	storable := sess.toStorable()
+	storable.StateVersion = 1
+	if storable.State, err = sess.state.cipher.seal(ctx, storable.State); err != nil {
+		s.logger.Errorf("failed to encrypt state of session %s: %v", sessionID, err)
+		return nil, err
+	}
+	data, err := sonic.Marshal(storable)
 	if err != nil {
 		s.logger.Errorf("failed to marshal session %s: %v", sessionID, err)
 		return nil, fmt.Errorf("failed to marshal session: %w", err)
 	}
 
-	if err := s.rdb.Set(ctx, key, data, s.ttl).Err(); err != nil {
+	sessTTL := sess.state.ttl
+	if err := s.rdb.Set(ctx, key, data, sessTTL).Err(); err != nil {
 		s.logger.Errorf("failed to set session %s in redis: %v", sessionID, err)
 		return nil, fmt.Errorf("failed to set session: %w", err)
 	}
 
-	s.logger.Infof("session stored in redis success: key=%s, ttl=%s, data=%s", key, s.ttl, data)
+	s.logger.Infof("session stored in redis success: key=%s, ttl=%s, data=%s", key, sessTTL, data)
+
+	s.armExpiry(ctx, req.AppName, req.UserID, sessionID, sessTTL)
 
 	// NOTE: Add to session index
-	indexKey := buildSessionIndexKey(req.AppName, req.UserID)
-	if err := s.rdb.SAdd(ctx, indexKey, sessionID).Err(); err != nil {
+	if err := s.indexAdd(ctx, req.AppName, req.UserID, sessionID, sessTTL); err != nil {
 		s.logger.Errorf("failed to add session %s to index: %v", sessionID, err)
 		return nil, fmt.Errorf("failed to add session to index: %w", err)
 	}
 
-	if err := s.rdb.Expire(ctx, indexKey, s.ttl).Err(); err != nil {
-		s.logger.Warnf("failed to set expire for index key %s: %v", indexKey, err)
-	}
+	s.logger.Infof("session added to index success: user=%s, session=%s", req.UserID, sessionID)
 
-	s.logger.Infof("session added to index success: key=%s, session=%s", indexKey, sessionID)
+	s.reindexState(ctx, req.AppName, req.UserID, sessionID, state, sessTTL)
 
 	// NOTE: Persist to PostgreSQL if persister is configured
 	if s.persister != nil {
@@ -187,30 +381,72 @@ func (s *RedisSessionService) Create(
 		s.logger.Infof("session persisted to postgres success")
 	}
 
+	s.notify(ctx, Notification{
+		Type: NotificationSessionCreated, AppName: req.AppName, UserID: req.UserID, SessionID: sessionID,
+	})
+
 	return &session.CreateResponse{Session: sess}, nil
 }
 
-// Get retrieves a session by ID from Redis only.
+// Get retrieves a session by ID from Redis.
 //
-// Design note: Get does NOT fall back to PostgreSQL when the session is missing from Redis.
-// Redis is the sole read source; the PostgreSQL persister (if configured) is write-only and
-// serves as a durable archive for auditing, analytics, or feeding the memory service.
-// Once a session's Redis TTL expires, it becomes inaccessible through this service.
+// Design note: Without a loader, Get does NOT fall back to PostgreSQL when the session is
+// missing from Redis: the PostgreSQL persister (if configured) is write-only and serves as a
+// durable archive for auditing, analytics, or feeding the memory service, and a session
+// becomes inaccessible through this service once its Redis TTL expires.
+//
+// With WithLoader, a session missing from Redis is read from the loader and its Redis keys
+// rebuilt, so sessions survive their TTL and cache eviction.
 //
 // Recommended: set the Redis TTL to at least 7 days (e.g., ksess.WithTTL(7 * 24 * time.Hour))
-// to keep sessions available for a reasonable window. Sessions older than the TTL will no longer
-// be retrievable, though their data remains in PostgreSQL if a persister was configured.
+// to keep sessions available for a reasonable window.
 func (s *RedisSessionService) Get(
 	ctx context.Context,
 	req *session.GetRequest,
+) (*session.GetResponse, error) {
+	start := time.Now()
+	resp, err := s.get(s.metricsContext(ctx), req, nil)
+	s.observe(OperationGet, start, err)
+	return resp, err
+}
+
+// get retrieves a session, keeping only the events accepted by filter (nil keeps all).
+// The filter also applies to the events reloaded by the session afterwards.
+func (s *RedisSessionService) get(
+	ctx context.Context,
+	req *session.GetRequest,
+	filter func(*session.Event) bool,
 ) (*session.GetResponse, error) {
 	s.logger.Debugf("getting session: app=%s, user=%s, session=%s",
 		req.AppName, req.UserID, req.SessionID)
 
-	// NOTE: Get session from redis
+	// NOTE: Get session from redis, with its events, only the recent ones if that is
+	// all the caller needs and no branch view hides some of them
 	key := buildSessionKey(req.AppName, req.UserID, req.SessionID)
+	evKey := buildEventsKey(req.AppName, req.UserID, req.SessionID)
+	start := int64(0)
+	if req.NumRecentEvents > 0 && filter == nil {
+		start = -int64(req.NumRecentEvents)
+	}
+
+	data, eventData, err := s.readSession(ctx, key, evKey, start)
+
+	// NOTE: Rebuild a session evicted from Redis from the loader
+	if errors.Is(err, redis.Nil) && s.loader != nil {
+		restored, rErr := s.restore(ctx, req.AppName, req.UserID, req.SessionID)
+		if rErr != nil {
+			return nil, rErr
+		}
+		if restored {
+			s.observeCache(CacheRestored)
+			data, eventData, err = s.readSession(ctx, key, evKey, start)
+		} else {
+			s.observeCache(CacheMiss)
+		}
+	} else if err == nil && s.loader != nil {
+		s.observeCache(CacheHit)
+	}
 
-	data, err := s.rdb.Get(ctx, key).Bytes()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
 			s.logger.Errorf("session not found: %s", req.SessionID)
@@ -229,23 +465,22 @@ func (s *RedisSessionService) Get(
 		s.logger.Errorf("failed to unmarshal session %s: %v", req.SessionID, err)
 		return nil, fmt.Errorf("failed to unmarshal session: %w", err)
 	}
-
-	// NOTE: Load events
-	evKey := buildEventsKey(req.AppName, req.UserID, req.SessionID)
-	eventData, err := s.rdb.LRange(ctx, evKey, 0, -1).Result()
-	if err != nil && !errors.Is(err, redis.Nil) {
-		s.logger.Errorf("failed to get events for session %s: %v", req.SessionID, err)
-		return nil, fmt.Errorf("failed to get events: %w", err)
+	if err := s.openStored(ctx, &storable); err != nil {
+		s.logger.Errorf("failed to decrypt session %s: %v", req.SessionID, err)
+		return nil, err
 	}
 
 	var events []*session.Event
 	var unmarshalErrors []error
 	for i, ed := range eventData {
 		var evt session.Event
-		if err := sonic.Unmarshal([]byte(ed), &evt); err != nil {
+		if err := s.codec.forApp(req.AppName).unmarshal(ctx, ed, &evt); err != nil {
 			unmarshalErrors = append(unmarshalErrors, fmt.Errorf("event at index %d: %w", i, err))
 			continue
 		}
+		if filter != nil && !filter(&evt) {
+			continue
+		}
 		events = append(events, &evt)
 	}
 
@@ -270,14 +505,25 @@ func (s *RedisSessionService) Get(
 	}
 
 	// NOTE: build session
+	logKey := buildStateLogKey(req.AppName, req.UserID, req.SessionID)
+	ttl := s.sessionTTL(storable.TTL)
 	sess := &redisSession{
 		id:             storable.ID,
 		appName:        storable.AppName,
 		userID:         storable.UserID,
-		state:          newRedisState(storable.State, s.rdb, key, s.ttl, s.logger),
-		events:         newRedisEvents(events, s.rdb, evKey, s.logger),
+		state:          newRedisState(storable.State, storable.StateVersion, s.rdb, key, logKey, ttl, s.logger),
+		events:         newRedisEvents(events, s.rdb, evKey, s.codec.forApp(req.AppName), s.logger),
 		lastUpdateTime: storable.LastUpdateTime,
+		parentID:       storable.ParentID,
+		forkEventID:    storable.ForkEventID,
+		tags:           storable.Tags,
+		ttl:            time.Duration(storable.TTL) * time.Second,
 	}
+	sess.events.filter = filter
+	sess.events.recent = req.NumRecentEvents
+	sess.state.reindex = s.stateReindexer(req.AppName, req.UserID, req.SessionID, ttl)
+	sess.state.validate = s.stateValidator(req.AppName)
+	sess.state.cipher = s.stateCipher(req.AppName)
 
 	s.logger.Infof("session retrieved: session=%s, events=%d", req.SessionID, len(events))
 
@@ -285,73 +531,75 @@ func (s *RedisSessionService) Get(
 }
 
 // List returns all sessions for a user using pipeline for batch fetching.
+// With WithLoader, the sessions of the loader missing from Redis are restored and listed too.
 func (s *RedisSessionService) List(
 	ctx context.Context,
 	req *session.ListRequest,
+) (*session.ListResponse, error) {
+	start := time.Now()
+	resp, err := s.list(s.metricsContext(ctx), req)
+	s.observe(OperationList, start, err)
+	return resp, err
+}
+
+func (s *RedisSessionService) list(
+	ctx context.Context,
+	req *session.ListRequest,
 ) (*session.ListResponse, error) {
 	s.logger.Debugf("listing sessions: app=%s, user=%s", req.AppName, req.UserID)
 
 	// NOTE: List sessions
-	indexKey := buildSessionIndexKey(req.AppName, req.UserID)
-
-	sessionIDs, err := s.rdb.SMembers(ctx, indexKey).Result()
+	sessionIDs, err := s.indexSessionIDs(ctx, req.AppName, req.UserID)
 	if err != nil {
 		s.logger.Errorf("failed to list sessions for user %s: %v", req.UserID, err)
 		return nil, fmt.Errorf("failed to list sessions: %w", err)
 	}
 
-	if len(sessionIDs) == 0 {
+	if len(sessionIDs) == 0 && s.loader == nil {
 		s.logger.Debugf("no sessions found for user %s", req.UserID)
 		return &session.ListResponse{Sessions: nil}, nil
 	}
 
 	// NOTE: Use pipeline to batch fetch all session data
-	pipe := s.rdb.Pipeline()
-	sessionCmds := make(map[string]*redis.StringCmd, len(sessionIDs))
-	for _, sessionID := range sessionIDs {
-		key := buildSessionKey(req.AppName, req.UserID, sessionID)
-		sessionCmds[sessionID] = pipe.Get(ctx, key)
+	found, missing, err := s.fetchSessions(ctx, s.readClient(), req.AppName, req.UserID, sessionIDs)
+	if err != nil {
+		return nil, err
 	}
 
-	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
-		s.logger.Errorf("failed to batch get sessions: %v", err)
-		return nil, fmt.Errorf("failed to batch get sessions: %w", err)
+	// NOTE: A replica may lag behind the primary, read the sessions it misses there
+	if len(missing) > 0 && s.readClient() != s.rdb {
+		var more map[string]*storableSession
+		more, missing, err = s.fetchSessions(ctx, s.rdb, req.AppName, req.UserID, missing)
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(found, more)
 	}
 
-	// NOTE: Parse results and collect stale session IDs for cleanup
+	// NOTE: Collect stale session IDs for cleanup
 	sessions := make([]session.Session, 0, len(sessionIDs))
-	var staleIDs []string
 	for _, sessionID := range sessionIDs {
-		cmd := sessionCmds[sessionID]
-		data, err := cmd.Bytes()
-		if err != nil {
-			if errors.Is(err, redis.Nil) {
-				s.logger.Warnf("session %s not found in redis, marking for cleanup", sessionID)
-				staleIDs = append(staleIDs, sessionID)
-			} else {
-				s.logger.Warnf("failed to get session %s: %v", sessionID, err)
-			}
-			continue
+		if storable, ok := found[sessionID]; ok {
+			sessions = append(sessions, s.listedSession(storable))
 		}
+	}
+	staleIDs := missing
+	for _, sessionID := range staleIDs {
+		s.logger.Warnf("session %s not found in redis, marking for cleanup", sessionID)
+	}
 
-		var storable storableSession
-		if err := sonic.Unmarshal(data, &storable); err != nil {
-			s.logger.Warnf("failed to unmarshal session %s: %v", sessionID, err)
-			continue
+	// NOTE: Restore the sessions evicted from Redis but kept by the loader
+	if s.loader != nil {
+		listed := make(map[string]bool, len(sessions))
+		for _, sess := range sessions {
+			listed[sess.ID()] = true
 		}
 
-		key := buildSessionKey(req.AppName, req.UserID, sessionID)
-		evKey := buildEventsKey(req.AppName, req.UserID, sessionID)
-
-		sess := &redisSession{
-			id:             storable.ID,
-			appName:        storable.AppName,
-			userID:         storable.UserID,
-			state:          newRedisState(storable.State, s.rdb, key, s.ttl, s.logger),
-			events:         newRedisEvents(nil, s.rdb, evKey, s.logger),
-			lastUpdateTime: storable.LastUpdateTime,
+		recovered, err := s.recoverList(ctx, req.AppName, req.UserID, listed)
+		if err != nil {
+			return nil, err
 		}
-		sessions = append(sessions, sess)
+		sessions = append(sessions, recovered...)
 	}
 
 	s.logger.Infof("listed %d sessions for user %s", len(sessions), req.UserID)
@@ -367,33 +615,109 @@ func (s *RedisSessionService) List(
 		go func() {
 			cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
 			defer cancel()
-			s.cleanStaleSessionIDs(cleanupCtx, appName, userID, staleIDsCopy)
+			s.cleanStaleIndex(cleanupCtx, appName, userID, staleIDsCopy)
 		}()
 	}
 
 	return &session.ListResponse{Sessions: sessions}, nil
 }
 
-// Delete removes a session.
-func (s *RedisSessionService) Delete(ctx context.Context, req *session.DeleteRequest) error {
-	s.logger.Debugf("deleting session: app=%s, user=%s, session=%s",
-		req.AppName, req.UserID, req.SessionID)
+// dropSession removes the Redis keys of a session and its index entries.
+func (s *RedisSessionService) dropSession(ctx context.Context, appName, userID, sessionID string) error {
+	// NOTE: Delete session, first from the state index, which reads its entry
+	s.reindexState(ctx, appName, userID, sessionID, nil, 0)
 
-	// NOTE: Delete session
-	key := buildSessionKey(req.AppName, req.UserID, req.SessionID)
-	evKey := buildEventsKey(req.AppName, req.UserID, req.SessionID)
-	indexKey := buildSessionIndexKey(req.AppName, req.UserID)
+	key := buildSessionKey(appName, userID, sessionID)
+	evKey := buildEventsKey(appName, userID, sessionID)
 
 	pipe := s.rdb.Pipeline()
 	pipe.Del(ctx, key)
 	pipe.Del(ctx, evKey)
-	pipe.SRem(ctx, indexKey, req.SessionID)
+	pipe.Del(ctx, buildStateLogKey(appName, userID, sessionID))
+	pipe.Del(ctx, buildEventBytesKey(appName, userID, sessionID))
+	pipe.Del(ctx, buildEventTrimKey(appName, userID, sessionID))
+	pipe.Del(ctx, buildEventRateKey(appName, userID, sessionID))
+	if s.expiryLead > 0 {
+		pipe.Del(ctx, buildExpiryKey(appName, userID, sessionID))
+	}
+	if err := s.indexRemove(ctx, pipe, appName, userID, sessionID); err != nil {
+		s.logger.Errorf("failed to delete session %s from index: %v", sessionID, err)
+		return fmt.Errorf("failed to delete session from index: %w", err)
+	}
 
 	if _, err := pipe.Exec(ctx); err != nil {
-		s.logger.Errorf("failed to delete session %s: %v", req.SessionID, err)
+		s.logger.Errorf("failed to delete session %s: %v", sessionID, err)
 		return fmt.Errorf("failed to delete session: %w", err)
 	}
 
+	return nil
+}
+
+// fetchSessions reads the sessions of sessionIDs from client with a pipeline. It
+// returns the sessions found by ID and the IDs of the sessions missing; the sessions
+// failing to be read or decoded are logged and left out of both.
+func (s *RedisSessionService) fetchSessions(
+	ctx context.Context,
+	client redis.UniversalClient,
+	appName, userID string,
+	sessionIDs []string,
+) (map[string]*storableSession, []string, error) {
+	pipe := client.Pipeline()
+	sessionCmds := make(map[string]*redis.StringCmd, len(sessionIDs))
+	for _, sessionID := range sessionIDs {
+		key := buildSessionKey(appName, userID, sessionID)
+		sessionCmds[sessionID] = pipe.Get(ctx, key)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		s.logger.Errorf("failed to batch get sessions: %v", err)
+		return nil, nil, fmt.Errorf("failed to batch get sessions: %w", err)
+	}
+
+	found := make(map[string]*storableSession, len(sessionIDs))
+	var missing []string
+	for _, sessionID := range sessionIDs {
+		data, err := sessionCmds[sessionID].Bytes()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				missing = append(missing, sessionID)
+			} else {
+				s.logger.Warnf("failed to get session %s: %v", sessionID, err)
+			}
+			continue
+		}
+
+		var storable storableSession
+		if err := sonic.Unmarshal(data, &storable); err != nil {
+			s.logger.Warnf("failed to unmarshal session %s: %v", sessionID, err)
+			continue
+		}
+		if err := s.openStored(ctx, &storable); err != nil {
+			s.logger.Warnf("failed to decrypt session %s: %v", sessionID, err)
+			continue
+		}
+		found[sessionID] = &storable
+	}
+
+	return found, missing, nil
+}
+
+// Delete removes a session.
+func (s *RedisSessionService) Delete(ctx context.Context, req *session.DeleteRequest) error {
+	start := time.Now()
+	err := s.deleteSession(s.metricsContext(ctx), req)
+	s.observe(OperationDelete, start, err)
+	return err
+}
+
+func (s *RedisSessionService) deleteSession(ctx context.Context, req *session.DeleteRequest) error {
+	s.logger.Debugf("deleting session: app=%s, user=%s, session=%s",
+		req.AppName, req.UserID, req.SessionID)
+
+	if err := s.dropSession(ctx, req.AppName, req.UserID, req.SessionID); err != nil {
+		return err
+	}
+
 	// NOTE: Delete from PostgreSQL if persister is configured
 	if s.persister != nil {
 		err := s.persister.DeleteSession(ctx, req.AppName, req.UserID, req.SessionID)
@@ -408,20 +732,61 @@ func (s *RedisSessionService) Delete(ctx context.Context, req *session.DeleteReq
 	s.logger.Infof("session deleted: app=%s, user=%s, session=%s",
 		req.AppName, req.UserID, req.SessionID)
 
+	s.notify(ctx, Notification{
+		Type: NotificationSessionDeleted, AppName: req.AppName, UserID: req.UserID, SessionID: req.SessionID,
+	})
+
 	return nil
 }
 
-// AppendEvent appends an event to a session.
+// AppendEvent appends an event to a session, stamping it with the current time if it
+// has no timestamp (see WithAppendTimestamps). The session state is written with
+// check-and-set semantics: if the stored state changed since sess was read, e.g. by
+// another runner, the event is refused with an error matching ErrConflict; get the
+// session again and retry.
 func (s *RedisSessionService) AppendEvent(
 	ctx context.Context,
 	sess session.Session,
 	evt *session.Event,
 ) error {
+	start := time.Now()
+	err := s.appendEvent(s.metricsContext(ctx), sess, evt)
+	s.observe(OperationAppendEvent, start, err)
+	return err
+}
+
+func (s *RedisSessionService) appendEvent(
+	ctx context.Context,
+	sess session.Session,
+	evt *session.Event,
+) (err error) {
 	if sess == nil {
 		return ErrNilSession
 	}
 
-	evt.Timestamp = time.Now()
+	// NOTE: Refuse the event before anything is written if the session floods,
+	// and give the token back if the append fails after all
+	if s.rateLimit != nil {
+		if err := s.takeAppendToken(ctx, sess.AppName(), sess.UserID(), sess.ID()); err != nil {
+			s.logger.Warnf("event refused, session %s is rate limited: %v", sess.ID(), err)
+			return err
+		}
+		defer func() {
+			if err != nil {
+				s.refundAppendToken(context.WithoutCancel(ctx), sess.AppName(), sess.UserID(), sess.ID())
+			}
+		}()
+	}
+
+	if err := s.checkEventQuota(ctx, sess.AppName(), sess.UserID(), sess.ID()); err != nil {
+		s.logger.Warnf("event refused for session %s: %v", sess.ID(), err)
+		return err
+	}
+
+	// NOTE: Keep the timestamp of events replayed or imported by the caller
+	if evt.Timestamp.IsZero() || s.stampEvents {
+		evt.Timestamp = time.Now()
+	}
 	if evt.ID == "" {
 		evt.ID = generateSessionID()
 	}
@@ -429,62 +794,88 @@ func (s *RedisSessionService) AppendEvent(
 	s.logger.Debugf("appending event to session %s: event_id=%s, author=%s",
 		sess.ID(), evt.ID, evt.Author)
 
-	data, err := sonic.Marshal(evt)
+	data, err := s.codec.forApp(sess.AppName()).marshal(ctx, evt)
 	if err != nil {
 		s.logger.Errorf("failed to marshal event %s: %v", evt.ID, err)
 		return fmt.Errorf("failed to marshal event: %w", err)
 	}
 
-	evKey := buildEventsKey(sess.AppName(), sess.UserID(), sess.ID())
-	if err := s.rdb.RPush(ctx, evKey, data).Err(); err != nil {
-		s.logger.Errorf("failed to append event %s to session %s: %v", evt.ID, sess.ID(), err)
-		return fmt.Errorf("failed to append event: %w", err)
-	}
+	// NOTE: Update session's last update time and persist current state first,
+	// bumping its state version if it changed. The write is refused if another
+	// writer changed the state since the session was read, and the event dropped.
+	key := buildSessionKey(sess.AppName(), sess.UserID(), sess.ID())
+	logKey := buildStateLogKey(sess.AppName(), sess.UserID(), sess.ID())
 
-	s.logger.Infof("event stored in redis: key=%s, event_id=%s", evKey, evt.ID)
+	var state map[string]any
+	if sess.State() != nil {
+		state = maps.Collect(sess.State().All())
+	}
+	if state == nil {
+		state = make(map[string]any)
+	}
 
-	if err := s.rdb.Expire(ctx, evKey, s.ttl).Err(); err != nil {
-		s.logger.Warnf("failed to set expire for events key %s: %v", evKey, err)
+	var rstate *redisState
+	var expected int64
+	if rsess, ok := sess.(*redisSession); ok {
+		rstate = rsess.state
+		expected = rstate.version.Load()
 	}
 
-	// NOTE: Update session's last update time and persist current state
-	key := buildSessionKey(sess.AppName(), sess.UserID(), sess.ID())
-	sessData, err := s.rdb.Get(ctx, key).Bytes()
+	sealed, err := s.stateCipher(sess.AppName()).seal(ctx, state)
 	if err != nil {
-		s.logger.Errorf("failed to get session %s for update: %v", sess.ID(), err)
-		return fmt.Errorf("failed to get session for update: %w", err)
+		s.logger.Errorf("failed to encrypt state of session %s: %v", sess.ID(), err)
+		return err
 	}
 
-	var storable storableSession
-	if err := sonic.Unmarshal(sessData, &storable); err != nil {
-		s.logger.Errorf("failed to unmarshal session %s: %v", sess.ID(), err)
-		return fmt.Errorf("failed to unmarshal session: %w", err)
+	// NOTE: The session record keeps its own TTL, ttl is the one applied
+	version, ttl, err := persistState(ctx, s.rdb, key, logKey, s.ttl, sealed, expected)
+	if errors.Is(err, ErrConflict) {
+		s.logger.Warnf("event %s refused, session %s changed concurrently: %v", evt.ID, sess.ID(), err)
+		return err
 	}
-
-	// Sync state from session to storable
-	state := sess.State()
-	if state != nil {
-		storable.State = maps.Collect(state.All())
+	if err != nil {
+		s.logger.Errorf("failed to update session %s: %v", sess.ID(), err)
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+	if rstate != nil {
+		rstate.version.Store(version)
 	}
+	if version != expected {
+		s.reindexState(ctx, sess.AppName(), sess.UserID(), sess.ID(), state, ttl)
+	} else {
+		s.touchStateIndex(ctx, sess.AppName(), sess.UserID(), sess.ID(), state, ttl)
+	}
+
+	s.logger.Debugf("session updated in redis: key=%s, state_version=%d", key, version)
 
-	storable.LastUpdateTime = time.Now()
-	updatedData, err := sonic.Marshal(storable)
+	evKey := buildEventsKey(sess.AppName(), sess.UserID(), sess.ID())
+	length, err := s.rdb.RPush(ctx, evKey, data).Result()
 	if err != nil {
-		s.logger.Errorf("failed to marshal updated session %s: %v", sess.ID(), err)
-		return fmt.Errorf("failed to marshal updated session: %w", err)
+		s.logger.Errorf("failed to append event %s to session %s: %v", evt.ID, sess.ID(), err)
+		return fmt.Errorf("failed to append event: %w", err)
 	}
 
-	if err := s.rdb.Set(ctx, key, updatedData, s.ttl).Err(); err != nil {
-		s.logger.Errorf("failed to update session %s: %v", sess.ID(), err)
-		return fmt.Errorf("failed to update session: %w", err)
+	s.logger.Infof("event stored in redis: key=%s, event_id=%s", evKey, evt.ID)
+
+	if err := s.rdb.Expire(ctx, evKey, ttl).Err(); err != nil {
+		s.logger.Warnf("failed to set expire for events key %s: %v", evKey, err)
 	}
 
-	s.logger.Debugf("session updated in redis: key=%s", key)
+	if s.eventSizes != nil {
+		s.trackEventSize(ctx, sess.AppName(), sess.UserID(), sess.ID(), int64(len(data)), length, ttl)
+	}
+
+	if s.trim != nil {
+		s.trimEvents(ctx, sess, length, ttl)
+	}
 
-	// NOTE: Refresh index key TTL to keep it aligned with active sessions
-	indexKey := buildSessionIndexKey(sess.AppName(), sess.UserID())
-	if err := s.rdb.Expire(ctx, indexKey, s.ttl).Err(); err != nil {
-		s.logger.Warnf("failed to refresh expire for index key %s: %v", indexKey, err)
+	// NOTE: Refresh index key TTL to keep it aligned with active sessions.
+	// Buckets do not expire, they are pruned instead.
+	if s.buckets <= 0 {
+		indexKey := buildSessionIndexKey(sess.AppName(), sess.UserID())
+		if err := s.rdb.Expire(ctx, indexKey, s.indexTTL(ttl)).Err(); err != nil {
+			s.logger.Warnf("failed to refresh expire for index key %s: %v", indexKey, err)
+		}
 	}
 
 	// NOTE: Real-time sync to PostgreSQL if persister is configured
@@ -499,44 +890,35 @@ func (s *RedisSessionService) AppendEvent(
 
 	s.logger.Infof("event appended: session=%s, event=%s", sess.ID(), evt.ID)
 
+	s.notify(ctx, Notification{
+		Type:      NotificationEventAppended,
+		AppName:   sess.AppName(),
+		UserID:    sess.UserID(),
+		SessionID: sess.ID(),
+		EventID:   evt.ID,
+		Author:    evt.Author,
+	})
+
 	return nil
 }
 
-// cleanStaleSessionIDs atomically removes session IDs from the index set only if
-// their corresponding session keys no longer exist in Redis. This prevents a race
-// condition where a concurrent Create() re-creates a session between the pipeline
-// GET (returning redis.Nil) and the cleanup removal.
-var cleanStaleScript = redis.NewScript(`
-local indexKey = KEYS[1]
-local prefix = ARGV[1]
-local removed = 0
-for i = 2, #ARGV do
-    local sessionKey = prefix .. ARGV[i]
-    if redis.call('EXISTS', sessionKey) == 0 then
-        redis.call('SREM', indexKey, ARGV[i])
-        removed = removed + 1
-    end
-end
-return removed
-`)
-
+// cleanStaleSessionIDs removes session IDs from the index set only if their
+// corresponding session keys no longer exist in Redis. A session re-created by a
+// concurrent Create() between the pipeline GET (returning redis.Nil) and the
+// cleanup stays indexed, see removeStaleMembers.
 func (s *RedisSessionService) cleanStaleSessionIDs(
 	ctx context.Context,
 	appName, userID string,
 	staleIDs []string,
 ) {
 	indexKey := buildSessionIndexKey(appName, userID)
-	// Session keys are "session:{appName}:{userID}:{sessionID}", so the prefix
-	// up to (and including) the last colon lets the Lua script reconstruct each key.
-	keyPrefix := fmt.Sprintf("session:%s:%s:", appName, userID)
 
-	args := make([]any, 0, len(staleIDs)+1)
-	args = append(args, keyPrefix)
-	for _, id := range staleIDs {
-		args = append(args, id)
+	sessionKeys := make([]string, len(staleIDs))
+	for i, id := range staleIDs {
+		sessionKeys[i] = buildSessionKey(appName, userID, id)
 	}
 
-	result, err := cleanStaleScript.Run(ctx, s.rdb, []string{indexKey}, args...).Int()
+	result, err := s.removeStaleMembers(ctx, indexKey, false, staleIDs, sessionKeys)
 	if err != nil {
 		s.logger.Warnf("failed to clean up stale session IDs from index: %v", err)
 		return
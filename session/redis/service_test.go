@@ -2,12 +2,14 @@ package redis
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"testing"
 	"time"
 
 	"github.com/bytedance/sonic"
+	ksess "github.com/kydenul/k-adk/session"
 	"github.com/redis/go-redis/v9"
 	"google.golang.org/adk/session"
 )
@@ -121,6 +123,52 @@ func TestNewRedisSessionService(t *testing.T) {
 
 // --- Create ---
 
+func TestCreateIDPolicy(t *testing.T) {
+	const (
+		appName = "test_idpolicy_app"
+		userID  = "test_idpolicy_user"
+	)
+
+	svc, rdb := setupTestRedis(t, WithTTL(30*time.Second), WithIDPolicy(ksess.IDPolicy{
+		ReservedPrefixes: []string{"sys-"},
+	}))
+	t.Cleanup(func() {
+		cleanupTestKeys(t, rdb,
+			fmt.Sprintf("session:%s:*", appName),
+			fmt.Sprintf("events:%s:*", appName),
+		)
+	})
+
+	ctx := context.Background()
+
+	for _, id := range []string{"a:b", "sys-1", "héllo"} {
+		_, err := svc.Create(ctx, &session.CreateRequest{AppName: appName, UserID: userID, SessionID: id})
+		if !errors.Is(err, ksess.ErrInvalidSessionID) {
+			t.Errorf("Create(%q): expected ErrInvalidSessionID, got %v", id, err)
+		}
+	}
+
+	resp, err := svc.Create(ctx, &session.CreateRequest{AppName: appName, UserID: userID, SessionID: "valid-id"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if resp.Session.ID() != "valid-id" {
+		t.Errorf("expected session ID 'valid-id', got %q", resp.Session.ID())
+	}
+
+	generating, _ := setupTestRedis(t, WithIDPolicy(ksess.IDPolicy{
+		AlwaysGenerate: true,
+		Generate:       func() string { return "server-id" },
+	}))
+	resp, err = generating.Create(ctx, &session.CreateRequest{AppName: appName, UserID: userID, SessionID: "a:b"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if resp.Session.ID() != "server-id" {
+		t.Errorf("expected server-generated ID, got %q", resp.Session.ID())
+	}
+}
+
 func TestCreate(t *testing.T) {
 	const (
 		appName = "test_create_app"
@@ -947,6 +995,222 @@ func TestAppendEventTTL(t *testing.T) {
 	})
 }
 
+func TestAppendEventTimestamps(t *testing.T) {
+	const (
+		appName = "test_event_ts_app"
+		userID  = "test_event_ts_user"
+	)
+
+	past := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, tt := range []struct {
+		name string
+		opts []ServiceOption
+		kept bool
+	}{
+		{"caller timestamps kept", nil, true},
+		{"append timestamps", []ServiceOption{WithAppendTimestamps()}, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, rdb := setupTestRedis(t, tt.opts...)
+			t.Cleanup(func() {
+				cleanupTestKeys(t, rdb,
+					fmt.Sprintf("session:%s:*", appName),
+					fmt.Sprintf("events:%s:*", appName),
+				)
+			})
+
+			ctx := context.Background()
+			createResp, err := svc.Create(ctx, &session.CreateRequest{
+				AppName: appName, UserID: userID, SessionID: "evt-ts",
+			})
+			if err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+
+			evt := &session.Event{ID: "past-evt", Author: "user", Timestamp: past}
+			if err := svc.AppendEvent(ctx, createResp.Session, evt); err != nil {
+				t.Fatalf("AppendEvent failed: %v", err)
+			}
+			unstamped := &session.Event{ID: "new-evt", Author: "user"}
+			if err := svc.AppendEvent(ctx, createResp.Session, unstamped); err != nil {
+				t.Fatalf("AppendEvent failed: %v", err)
+			}
+			if unstamped.Timestamp.IsZero() {
+				t.Error("expected an event without timestamp to be stamped")
+			}
+
+			got, err := svc.Get(ctx, &session.GetRequest{AppName: appName, UserID: userID, SessionID: "evt-ts"})
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			stored := got.Session.Events().At(0).Timestamp
+			if kept := stored.Equal(past); kept != tt.kept {
+				t.Errorf("stored timestamp %v, kept = %v, want %v", stored, kept, tt.kept)
+			}
+		})
+	}
+}
+
+// --- AppendEvent: Optimistic Concurrency ---
+
+func TestAppendEventConflict(t *testing.T) {
+	const (
+		appName = "test_conflict_app"
+		userID  = "test_conflict_user"
+	)
+
+	svc, rdb := setupTestRedis(t, WithTTL(30*time.Second))
+	t.Cleanup(func() {
+		cleanupTestKeys(t, rdb,
+			fmt.Sprintf("session:%s:*", appName),
+			fmt.Sprintf("events:%s:*", appName),
+			fmt.Sprintf("statelog:%s:*", appName),
+			fmt.Sprintf("sessions:%s:*", appName),
+		)
+	})
+
+	ctx := context.Background()
+
+	_, err := svc.Create(ctx, &session.CreateRequest{
+		AppName: appName, UserID: userID, SessionID: "occ", State: map[string]any{"step": 0},
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	get := func() session.Session {
+		resp, err := svc.Get(ctx, &session.GetRequest{AppName: appName, UserID: userID, SessionID: "occ"})
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		return resp.Session
+	}
+
+	// Two runners read the session at the same state version
+	first, second := get(), get()
+
+	first.State().(*redisState).data.Store("step", 1)
+	if err := svc.AppendEvent(ctx, first, &session.Event{ID: "occ-1", Author: "agent"}); err != nil {
+		t.Fatalf("AppendEvent failed: %v", err)
+	}
+
+	second.State().(*redisState).data.Store("step", 2)
+	err = svc.AppendEvent(ctx, second, &session.Event{ID: "occ-2", Author: "agent"})
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("AppendEvent error = %v, want ErrConflict", err)
+	}
+
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || conflict.Expected != 1 || conflict.Actual != 2 {
+		t.Errorf("ConflictError = %+v, want expected 1, actual 2", conflict)
+	}
+
+	got := get()
+	if step, _ := got.State().Get("step"); step != float64(1) {
+		t.Errorf("state step = %v, want 1", step)
+	}
+	if got.Events().Len() != 1 {
+		t.Errorf("events = %d, want 1: the refused event must not be appended", got.Events().Len())
+	}
+
+	t.Run("unchanged state does not conflict", func(t *testing.T) {
+		stale := second
+		if err := svc.AppendEvent(ctx, get(), &session.Event{ID: "occ-3", Author: "user"}); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+		stale.State().(*redisState).data.Store("step", 1)
+		if err := svc.AppendEvent(ctx, stale, &session.Event{ID: "occ-4", Author: "user"}); err != nil {
+			t.Errorf("AppendEvent with the stored state error = %v, want nil", err)
+		}
+	})
+
+	t.Run("retry after reading again", func(t *testing.T) {
+		fresh := get()
+		if err := fresh.State().Set("step", 2); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+		if err := svc.AppendEvent(ctx, fresh, &session.Event{ID: "occ-5", Author: "agent"}); err != nil {
+			t.Errorf("AppendEvent failed: %v", err)
+		}
+	})
+}
+
+func TestStateNestedKeyOrder(t *testing.T) {
+	const (
+		appName = "test_key_order_app"
+		userID  = "test_key_order_user"
+	)
+
+	svc, rdb := setupTestRedis(t, WithTTL(30*time.Second))
+	t.Cleanup(func() {
+		cleanupTestKeys(t, rdb,
+			fmt.Sprintf("session:%s:*", appName),
+			fmt.Sprintf("statelog:%s:*", appName),
+			fmt.Sprintf("sessions:%s:*", appName),
+		)
+	})
+
+	ctx := context.Background()
+
+	profile := make(map[string]any)
+	for i := range 10 {
+		profile[fmt.Sprintf("k%d", i)] = map[string]any{"a": i, "b": []any{i}}
+	}
+	resp, err := svc.Create(ctx, &session.CreateRequest{
+		AppName: appName, UserID: userID, SessionID: "order", State: map[string]any{"profile": profile},
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// NOTE: The delta makes cjson encode the stored state, in its own key order
+	if err := resp.Session.State().(DeltaState).ApplyDelta(map[string]any{"lang": "fr"}); err != nil {
+		t.Fatalf("ApplyDelta failed: %v", err)
+	}
+	version := resp.Session.State().(*redisState).version.Load()
+
+	// The same profile with its keys in reverse order
+	reversed := `{`
+	for i := 9; i >= 0; i-- {
+		reversed += fmt.Sprintf(`"k%d":{"b":[%d],"a":%d}`, i, i, i)
+		if i > 0 {
+			reversed += ","
+		}
+	}
+	reversed += `}`
+
+	key := buildSessionKey(appName, userID, "order")
+	timestamp := time.Now().Format(time.RFC3339Nano)
+
+	t.Run("whole state", func(t *testing.T) {
+		// NOTE: A stale expected version conflicts only if the state changed
+		result, err := updateStateScript.Run(ctx, rdb, []string{key},
+			`{"lang":"fr","profile":`+reversed+`}`, 30, timestamp, version+1).Slice()
+		if err != nil {
+			t.Fatalf("updateStateScript failed: %v", err)
+		}
+		if got, _ := result[0].(int64); got != version || result[1] != "" {
+			t.Errorf("result = %v, want version %d unchanged and no change", result, version)
+		}
+	})
+
+	t.Run("delta", func(t *testing.T) {
+		result, err := applyStateDeltaScript.Run(ctx, rdb, []string{key},
+			`{"profile":`+reversed+`}`, `[]`, 30, timestamp).Slice()
+		if err != nil {
+			t.Fatalf("applyStateDeltaScript failed: %v", err)
+		}
+		if got, _ := result[0].(int64); got != version || result[1] != "" {
+			t.Errorf("result = %v, want version %d unchanged and no change", result, version)
+		}
+	})
+
+	if n := rdb.LLen(ctx, buildStateLogKey(appName, userID, "order")).Val(); n != 1 {
+		t.Errorf("state log entries = %d, want 1: the delta only", n)
+	}
+}
+
 // --- AppendEvent: Index TTL Refresh ---
 
 func TestAppendEventRefreshesIndexTTL(t *testing.T) {
@@ -3,6 +3,7 @@ package redis
 import (
 	"time"
 
+	ksess "github.com/kydenul/k-adk/session"
 	"google.golang.org/adk/session"
 )
 
@@ -13,9 +14,29 @@ type storableSession struct {
 	UserID         string         `json:"user_id"`
 	State          map[string]any `json:"state"`
 	LastUpdateTime time.Time      `json:"last_update_time"`
+
+	// StateVersion starts at 1 and is bumped by every change of State, see Sync.
+	StateVersion int64 `json:"state_version,omitempty"`
+
+	// ParentID and ForkEventID link a branch session to the session and event it
+	// was forked from, see CreateBranch.
+	ParentID    string `json:"parent_id,omitempty"`
+	ForkEventID string `json:"fork_event_id,omitempty"`
+
+	// Tags are the key/value labels of the session, see SetTags.
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// TTL is the TTL of the session in seconds, set with SessionTTLStateKey or by its
+	// tenant; 0 for the service TTL. The state writes refresh the session with it.
+	TTL int64 `json:"ttl,omitempty"`
 }
 
-var _ session.Session = (*redisSession)(nil)
+var (
+	_ session.Session = (*redisSession)(nil)
+	_ ksess.Tagged    = (*redisSession)(nil)
+	_ ksess.Forked    = (*redisSession)(nil)
+	_ ksess.Expiring  = (*redisSession)(nil)
+)
 
 // redisSession implements the session.Session interface.
 type redisSession struct {
@@ -25,6 +46,10 @@ type redisSession struct {
 	state          *redisState
 	events         *redisEvents
 	lastUpdateTime time.Time
+	parentID       string
+	forkEventID    string
+	tags           map[string]string
+	ttl            time.Duration
 }
 
 func (s *redisSession) ID() string                { return s.id }
@@ -33,6 +58,10 @@ func (s *redisSession) UserID() string            { return s.userID }
 func (s *redisSession) State() session.State      { return s.state }
 func (s *redisSession) Events() session.Events    { return s.events }
 func (s *redisSession) LastUpdateTime() time.Time { return s.lastUpdateTime }
+func (s *redisSession) Tags() map[string]string   { return s.tags }
+func (s *redisSession) ParentID() string          { return s.parentID }
+func (s *redisSession) ForkEventID() string       { return s.forkEventID }
+func (s *redisSession) TTL() time.Duration        { return s.ttl }
 
 func (s *redisSession) toStorable() storableSession {
 	return storableSession{
@@ -41,5 +70,9 @@ func (s *redisSession) toStorable() storableSession {
 		UserID:         s.userID,
 		State:          s.state.toMap(),
 		LastUpdateTime: s.lastUpdateTime,
+		ParentID:       s.parentID,
+		ForkEventID:    s.forkEventID,
+		Tags:           s.tags,
+		TTL:            int64(s.ttl.Seconds()),
 	}
 }
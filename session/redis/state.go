@@ -2,13 +2,17 @@ package redis
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"iter"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bytedance/sonic"
 	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/k-adk/internal/redisscript"
 	"github.com/kydenul/log"
 	"github.com/redis/go-redis/v9"
 	"google.golang.org/adk/session"
@@ -16,19 +20,46 @@ import (
 
 var _ session.State = (*redisState)(nil)
 
+// deepEqualLua defines deep_equal, comparing two decoded JSON values key by key.
+// Comparing their cjson encodings is not enough: cjson writes the keys of an
+// object in the iteration order of its table, which depends on its insertion
+// history, so an unchanged nested object can encode differently.
+const deepEqualLua = `
+local function deep_equal(a, b)
+    if type(a) ~= 'table' or type(b) ~= 'table' then
+        return a == b
+    end
+    for k, v in pairs(a) do
+        if not deep_equal(v, b[k]) then
+            return false
+        end
+    end
+    for k, _ in pairs(b) do
+        if a[k] == nil then
+            return false
+        end
+    end
+    return true
+end
+`
+
 // updateStateScript is a Lua script that atomically updates the session state.
-// It performs a read-modify-write operation atomically to prevent race conditions.
+// It performs a read-modify-write operation atomically to prevent race conditions,
+// and bumps the state version of the session when the state changed. A change
+// made from a state version other than the stored one is refused (check-and-set).
 //
 // KEYS[1]: session key
 // ARGV[1]: new state JSON
-// ARGV[2]: TTL in seconds
-// ARGV[3]: last_update_time (RFC3339 formatted string from Go)
+// ARGV[2]: TTL in seconds, unless the session has its own
+// ARGV[3]: last_update_time (RFC3339Nano formatted string from Go)
+// ARGV[4]: expected state version, 0 to skip the check
 //
-// Returns: "OK" on success, error message on failure
+// Returns: {state version, stateChange JSON or "" if the state is unchanged, TTL
+// applied}, or {-1, stored state version} on conflict
 //
 // Note: We pass the timestamp from Go (ARGV[3]) instead of using Lua's os.date()
 // to ensure consistent time format parsing between Go and Redis.
-var updateStateScript = redis.NewScript(`
+var updateStateScript = redisscript.New("session_update_state", deepEqualLua+`
 local data = redis.call('GET', KEYS[1])
 if not data then
     return {err = "session not found"}
@@ -36,12 +67,52 @@ end
 
 local session = cjson.decode(data)
 local newState = cjson.decode(ARGV[1])
+local oldState = session.state
+if type(oldState) ~= 'table' then
+    oldState = {}
+end
+
+local set, removed = {}, {}
+local changed = false
+for k, v in pairs(newState) do
+    if not deep_equal(oldState[k], v) then
+        set[k] = v
+        changed = true
+    end
+end
+for k, _ in pairs(oldState) do
+    if newState[k] == nil then
+        table.insert(removed, k)
+        changed = true
+    end
+end
+
+local version = tonumber(session.state_version) or 0
+local expected = tonumber(ARGV[4]) or 0
+if changed and expected > 0 and version ~= expected then
+    return {-1, tostring(version)}
+end
 
 session.state = newState
 session.last_update_time = ARGV[3]
 
+local change = ""
+if changed then
+    version = version + 1
+    session.state_version = version
+
+    local entry = {version = version}
+    if next(set) ~= nil then
+        entry.set = set
+    end
+    if #removed > 0 then
+        entry.removed = removed
+    end
+    change = cjson.encode(entry)
+end
+
 local updated = cjson.encode(session)
-local ttl = tonumber(ARGV[2])
+local ttl = tonumber(session.ttl) or tonumber(ARGV[2])
 
 if ttl > 0 then
     redis.call('SET', KEYS[1], updated, 'EX', ttl)
@@ -49,23 +120,159 @@ else
     redis.call('SET', KEYS[1], updated)
 end
 
-return "OK"
+return {version, change, ttl}
 `)
 
+// ErrConflict is matched by the errors of state writes refused because the session
+// state was changed concurrently, e.g. by another runner.
+var ErrConflict = errors.New("session state conflict")
+
+// ConflictError reports a state write refused because the stored state changed
+// since the session was read. Get the session again and reapply the change.
+type ConflictError struct {
+	// Key is the Redis key of the session.
+	Key string
+
+	// Expected is the state version the session was read at, Actual the stored one.
+	Expected int64
+	Actual   int64
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%v: %s is at state version %d, expected %d", ErrConflict, e.Key, e.Actual, e.Expected)
+}
+
+// Is reports whether target is ErrConflict.
+func (e *ConflictError) Is(target error) bool { return target == ErrConflict }
+
+// stateChange is an entry of the state log: the keys set and removed by a state
+// version.
+type stateChange struct {
+	Version int64          `json:"version"`
+	Set     map[string]any `json:"set,omitempty"`
+	Removed []string       `json:"removed,omitempty"`
+}
+
+// persistState atomically replaces the state of the session at key and, when the
+// state changed, appends the change to the state log at logKey. It returns the
+// state version of the session and its TTL: its own, or ttl. If expected is
+// positive and the stored state version differs, a change is refused with a
+// *ConflictError.
+func persistState(
+	ctx context.Context,
+	rdb redis.UniversalClient,
+	key, logKey string,
+	ttl time.Duration,
+	state map[string]any,
+	expected int64,
+) (int64, time.Duration, error) {
+	stateJSON, err := sonic.Marshal(state)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	// Pass RFC3339 formatted timestamp to ensure consistent parsing with Go's time.Time,
+	// with nanoseconds to keep LastUpdateTime ordered within a second
+	timestamp := time.Now().Format(time.RFC3339Nano)
+
+	result, err := updateStateScript.Run(ctx, rdb, []string{key},
+		string(stateJSON), int64(ttl.Seconds()), timestamp, expected).Slice()
+	if err != nil {
+		return 0, 0, stateScriptError(err, key)
+	}
+	if len(result) < 2 {
+		return 0, 0, fmt.Errorf("unexpected result from state update script: %v", result)
+	}
+
+	version, _ := result[0].(int64)
+	change, _ := result[1].(string)
+	if version < 0 {
+		actual, _ := strconv.ParseInt(change, 10, 64)
+		return 0, 0, &ConflictError{Key: key, Expected: expected, Actual: actual}
+	}
+	if len(result) > 2 {
+		if seconds, _ := result[2].(int64); seconds > 0 {
+			ttl = time.Duration(seconds) * time.Second
+		}
+	}
+	if change == "" {
+		return version, ttl, nil
+	}
+
+	// The log is written outside the script to keep it single-key; a lost entry
+	// only makes Sync fall back to the full state.
+	if err := appendStateChange(ctx, rdb, logKey, change, ttl); err != nil {
+		return version, ttl, err
+	}
+
+	return version, ttl, nil
+}
+
+// stateScriptError returns the error of a state script for the session at key,
+// matching ErrSessionNotFound for a session gone from Redis.
+func stateScriptError(err error, key string) error {
+	var rerr redis.Error
+	if errors.As(err, &rerr) && rerr.Error() == "session not found" {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, key)
+	}
+	return err
+}
+
+// maxStateLogEntries caps the state log of a session: Sync falls back to the full
+// state for a checkpoint older than the versions kept.
+const maxStateLogEntries = 256
+
+// appendStateChange appends change to the state log at logKey, keeping its last
+// maxStateLogEntries entries.
+func appendStateChange(
+	ctx context.Context,
+	rdb redis.UniversalClient,
+	logKey, change string,
+	ttl time.Duration,
+) error {
+	pipe := rdb.Pipeline()
+	pipe.RPush(ctx, logKey, change)
+	pipe.LTrim(ctx, logKey, -maxStateLogEntries, -1)
+	if ttl > 0 {
+		pipe.Expire(ctx, logKey, ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to append state change: %w", err)
+	}
+	return nil
+}
+
 // redisState implements the session.State interface with Redis persistence.
 // It uses sync.Map for thread-safe concurrent access.
 type redisState struct {
 	data   sync.Map
 	client redis.UniversalClient
 	key    string
+	logKey string
 	ttl    time.Duration
 	logger log.Logger
+
+	// version is the stored state version this state was read at or last wrote,
+	// checked by the writes; 0 skips the check.
+	version atomic.Int64
+
+	// Optional. reindex updates the state index after a state write, see
+	// WithStateIndex.
+	reindex func(ctx context.Context, state map[string]any)
+
+	// Optional. validate refuses the state writes breaking the state schema, see
+	// WithStateSchema.
+	validate func(set map[string]any, removed []string) error
+
+	// Optional. cipher encrypts the stored state values, see WithEncryption.
+	cipher *stateCipher
 }
 
 func newRedisState(
 	initial map[string]any,
+	version int64,
 	rdb redis.UniversalClient,
-	key string,
+	key, logKey string,
 	ttl time.Duration,
 	logger log.Logger,
 ) *redisState {
@@ -76,9 +283,11 @@ func newRedisState(
 	s := &redisState{
 		client: rdb,
 		key:    key,
+		logKey: logKey,
 		ttl:    ttl,
 		logger: logger,
 	}
+	s.version.Store(version)
 
 	// Copy initial data to sync.Map
 	for k, v := range initial {
@@ -97,6 +306,12 @@ func (s *redisState) Get(key string) (any, error) {
 }
 
 func (s *redisState) Set(key string, value any) error {
+	if s.validate != nil {
+		if err := s.validate(map[string]any{key: value}, nil); err != nil {
+			return err
+		}
+	}
+
 	s.data.Store(key, value)
 
 	// Persist to Redis atomically using Lua script.
@@ -115,27 +330,24 @@ func (s *redisState) persistAtomic(ctx context.Context) error {
 		return nil
 	}
 
-	stateMap := s.toMap()
-	stateJSON, err := sonic.Marshal(stateMap)
+	state := s.toMap()
+	sealed, err := s.cipher.seal(ctx, state)
 	if err != nil {
-		return fmt.Errorf("failed to marshal state: %w", err)
+		return err
 	}
 
-	// Pass RFC3339 formatted timestamp to ensure consistent parsing with Go's time.Time
-	timestamp := time.Now().Format(time.RFC3339)
-
-	result, err := updateStateScript.Run(ctx, s.client, []string{s.key},
-		string(stateJSON), int64(s.ttl.Seconds()), timestamp).Result()
+	version, _, err := persistState(ctx, s.client, s.key, s.logKey, s.ttl, sealed, s.version.Load())
 	if err != nil {
-		if err == redis.Nil {
+		if errors.Is(err, redis.Nil) {
 			// Session does not exist in Redis yet, this is acceptable for new sessions
 			return nil
 		}
 		return fmt.Errorf("failed to persist state atomically: %w", err)
 	}
+	s.version.Store(version)
 
-	if result != "OK" {
-		return fmt.Errorf("unexpected result from state update script: %v", result)
+	if s.reindex != nil {
+		s.reindex(ctx, state)
 	}
 
 	return nil
@@ -0,0 +1,275 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/kydenul/k-adk/internal/redisscript"
+	"github.com/spf13/cast"
+	"google.golang.org/adk/session"
+)
+
+// ErrStateType is matched by the errors of the typed state reads of a value that
+// cannot be converted to the requested type.
+var ErrStateType = errors.New("state value has an unexpected type")
+
+var _ DeltaState = (*redisState)(nil)
+
+// DeltaState is implemented by the states of the sessions of RedisSessionService.
+// Set writes the whole state of the session; ApplyDelta and Delete send only the
+// keys they change, merged into the stored state by a single script call:
+//
+//	if state, ok := sess.State().(redis.DeltaState); ok {
+//		err := state.ApplyDelta(map[string]any{"step": 2, "draft": nil}) // nil removes "draft"
+//	}
+//
+// Unlike Set, ApplyDelta and Delete are not refused when the stored state changed
+// since the session was read: the delta is merged into the latest state, which the
+// session then reads.
+type DeltaState interface {
+	session.State
+
+	// GetString returns a state value as a string.
+	GetString(key string) (string, error)
+	// GetInt returns a state value as an integer, e.g. a JSON number.
+	GetInt(key string) (int64, error)
+	// GetTime returns a state value as a time, e.g. an RFC 3339 string.
+	GetTime(key string) (time.Time, error)
+
+	// ApplyDelta sets the keys of delta, removing those with a nil value, and
+	// persists them atomically.
+	ApplyDelta(delta map[string]any) error
+	// Delete removes a key and persists the removal.
+	Delete(key string) error
+}
+
+// applyStateDeltaScript merges a delta into the state of a session and bumps its
+// state version when the state changed, like updateStateScript does for a whole
+// state.
+//
+// KEYS[1]: session key
+// ARGV[1]: JSON object of the keys to set
+// ARGV[2]: JSON array of the keys to remove
+// ARGV[3]: TTL in seconds, unless the session has its own
+// ARGV[4]: last_update_time (RFC3339Nano formatted string from Go)
+//
+// Returns: {state version, stateChange JSON or "" if the state is unchanged, TTL
+// applied, merged state JSON}
+var applyStateDeltaScript = redisscript.New("session_apply_state_delta", deepEqualLua+`
+local data = redis.call('GET', KEYS[1])
+if not data then
+    return {err = "session not found"}
+end
+
+local session = cjson.decode(data)
+local state = session.state
+if type(state) ~= 'table' then
+    state = {}
+end
+
+local set, removed = {}, {}
+local changed = false
+for k, v in pairs(cjson.decode(ARGV[1])) do
+    if not deep_equal(state[k], v) then
+        state[k] = v
+        set[k] = v
+        changed = true
+    end
+end
+for _, k in ipairs(cjson.decode(ARGV[2])) do
+    if state[k] ~= nil then
+        state[k] = nil
+        table.insert(removed, k)
+        changed = true
+    end
+end
+
+local version = tonumber(session.state_version) or 0
+local change = ""
+if changed then
+    version = version + 1
+    session.state_version = version
+
+    local entry = {version = version}
+    if next(set) ~= nil then
+        entry.set = set
+    end
+    if #removed > 0 then
+        entry.removed = removed
+    end
+    change = cjson.encode(entry)
+end
+
+session.state = state
+session.last_update_time = ARGV[4]
+
+local updated = cjson.encode(session)
+local ttl = tonumber(session.ttl) or tonumber(ARGV[3])
+
+if ttl > 0 then
+    redis.call('SET', KEYS[1], updated, 'EX', ttl)
+else
+    redis.call('SET', KEYS[1], updated)
+end
+
+local merged = "{}"
+if next(state) ~= nil then
+    merged = cjson.encode(state)
+end
+
+return {version, change, ttl, merged}
+`)
+
+// lookup returns the value of key, session.ErrStateKeyNotExist if it is not set.
+func (s *redisState) lookup(key string) (any, error) {
+	val, ok := s.data.Load(key)
+	if !ok {
+		return nil, session.ErrStateKeyNotExist
+	}
+	return val, nil
+}
+
+func (s *redisState) GetString(key string) (string, error) {
+	val, err := s.lookup(key)
+	if err != nil {
+		return "", err
+	}
+
+	str, err := cast.ToStringE(val)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %v", ErrStateType, key, err)
+	}
+	return str, nil
+}
+
+func (s *redisState) GetInt(key string) (int64, error) {
+	val, err := s.lookup(key)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := cast.ToInt64E(val)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s: %v", ErrStateType, key, err)
+	}
+	return n, nil
+}
+
+func (s *redisState) GetTime(key string) (time.Time, error) {
+	val, err := s.lookup(key)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	t, err := cast.ToTimeE(val)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s: %v", ErrStateType, key, err)
+	}
+	return t, nil
+}
+
+func (s *redisState) ApplyDelta(delta map[string]any) error {
+	set := make(map[string]any, len(delta))
+	removed := make([]string, 0)
+	for k, v := range delta {
+		if v == nil {
+			removed = append(removed, k)
+		} else {
+			set[k] = v
+		}
+	}
+
+	return s.applyDelta(context.Background(), set, removed)
+}
+
+func (s *redisState) Delete(key string) error {
+	return s.applyDelta(context.Background(), nil, []string{key})
+}
+
+// applyDelta merges the keys set and removed into the stored state, appends the
+// change to the state log, and reloads the merged state. Without a client, only
+// the local state is changed.
+func (s *redisState) applyDelta(ctx context.Context, set map[string]any, removed []string) error {
+	if s.validate != nil {
+		if err := s.validate(set, removed); err != nil {
+			return err
+		}
+	}
+
+	if s.client == nil {
+		for k, v := range set {
+			s.data.Store(k, v)
+		}
+		for _, k := range removed {
+			s.data.Delete(k)
+		}
+		return nil
+	}
+
+	sealed, err := s.cipher.seal(ctx, set)
+	if err != nil {
+		return err
+	}
+	setJSON, err := sonic.Marshal(sealed)
+	if err != nil {
+		return fmt.Errorf("failed to marshal state delta: %w", err)
+	}
+	if set == nil {
+		setJSON = []byte("{}")
+	}
+	if removed == nil {
+		removed = []string{}
+	}
+	removedJSON, err := sonic.Marshal(removed)
+	if err != nil {
+		return fmt.Errorf("failed to marshal removed state keys: %w", err)
+	}
+
+	timestamp := time.Now().Format(time.RFC3339Nano)
+	result, err := applyStateDeltaScript.Run(ctx, s.client, []string{s.key},
+		string(setJSON), string(removedJSON), int64(s.ttl.Seconds()), timestamp).Slice()
+	if err != nil {
+		s.logger.Warnf("failed to apply state delta for key %s: %v", s.key, err)
+		return fmt.Errorf("failed to apply state delta: %w", stateScriptError(err, s.key))
+	}
+	if len(result) < 4 {
+		return fmt.Errorf("unexpected result from state delta script: %v", result)
+	}
+
+	version, _ := result[0].(int64)
+	change, _ := result[1].(string)
+	ttl := s.ttl
+	if seconds, _ := result[2].(int64); seconds > 0 {
+		ttl = time.Duration(seconds) * time.Second
+	}
+	mergedJSON, _ := result[3].(string)
+
+	var merged map[string]any
+	if err := sonic.UnmarshalString(mergedJSON, &merged); err != nil {
+		return fmt.Errorf("failed to unmarshal merged state: %w", err)
+	}
+	if err := s.cipher.open(ctx, merged); err != nil {
+		return err
+	}
+
+	// NOTE: The session reads the merged state, concurrent changes included
+	s.data.Clear()
+	for k, v := range merged {
+		s.data.Store(k, v)
+	}
+	s.version.Store(version)
+
+	if s.reindex != nil && change != "" {
+		s.reindex(ctx, merged)
+	}
+
+	if change == "" {
+		return nil
+	}
+
+	// The log is written outside the script to keep it single-key, as persistState does
+	return appendStateChange(ctx, s.client, s.logKey, change, ttl)
+}
@@ -0,0 +1,121 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/adk/session"
+)
+
+func TestTypedStateReads(t *testing.T) {
+	at := time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC)
+	state := newRedisState(map[string]any{
+		"name":  "ada",
+		"count": float64(42), // numbers decoded from JSON
+		"at":    at.Format(time.RFC3339),
+		"tags":  []any{"a"},
+	}, 0, nil, "", "", 0, nil)
+
+	if got, err := state.GetString("name"); err != nil || got != "ada" {
+		t.Errorf("GetString = %q, %v", got, err)
+	}
+	if got, err := state.GetInt("count"); err != nil || got != 42 {
+		t.Errorf("GetInt = %d, %v", got, err)
+	}
+	if got, err := state.GetTime("at"); err != nil || !got.Equal(at) {
+		t.Errorf("GetTime = %v, %v", got, err)
+	}
+	if _, err := state.GetInt("tags"); !errors.Is(err, ErrStateType) {
+		t.Errorf("expected ErrStateType, got %v", err)
+	}
+	if _, err := state.GetString("missing"); !errors.Is(err, session.ErrStateKeyNotExist) {
+		t.Errorf("expected ErrStateKeyNotExist, got %v", err)
+	}
+
+	if err := state.ApplyDelta(map[string]any{"count": 43, "name": nil}); err != nil {
+		t.Fatalf("ApplyDelta failed: %v", err)
+	}
+	if _, err := state.Get("name"); !errors.Is(err, session.ErrStateKeyNotExist) {
+		t.Error("expected a nil value to remove the key")
+	}
+	if err := state.Delete("count"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := state.Get("count"); !errors.Is(err, session.ErrStateKeyNotExist) {
+		t.Error("expected the key to be deleted")
+	}
+}
+
+func TestApplyDelta(t *testing.T) {
+	const (
+		appName = "test_delta_app"
+		userID  = "test_delta_user"
+	)
+
+	svc, rdb := setupTestRedis(t)
+	t.Cleanup(func() {
+		cleanupTestKeys(t, rdb,
+			fmt.Sprintf("session:%s:*", appName),
+			fmt.Sprintf("statelog:%s:*", appName),
+		)
+	})
+
+	ctx := context.Background()
+	resp, err := svc.Create(ctx, &session.CreateRequest{
+		AppName: appName, UserID: userID, SessionID: "delta",
+		State: map[string]any{"step": 1, "draft": "hello"},
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// A concurrent reader changes another key
+	other, err := svc.Get(ctx, &session.GetRequest{AppName: appName, UserID: userID, SessionID: "delta"})
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if err := other.Session.State().(DeltaState).ApplyDelta(map[string]any{"lang": "fr"}); err != nil {
+		t.Fatalf("ApplyDelta failed: %v", err)
+	}
+
+	state, ok := resp.Session.State().(DeltaState)
+	if !ok {
+		t.Fatal("expected DeltaState")
+	}
+	if err := state.ApplyDelta(map[string]any{"step": 2, "draft": nil}); err != nil {
+		t.Fatalf("ApplyDelta failed: %v", err)
+	}
+	if lang, err := state.GetString("lang"); err != nil || lang != "fr" {
+		t.Errorf("expected the merged state to hold the concurrent change, got %q, %v", lang, err)
+	}
+
+	got, err := svc.Get(ctx, &session.GetRequest{AppName: appName, UserID: userID, SessionID: "delta"})
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if step, err := got.Session.State().(DeltaState).GetInt("step"); err != nil || step != 2 {
+		t.Errorf("expected step=2, got %d, %v", step, err)
+	}
+	if _, err := got.Session.State().Get("draft"); !errors.Is(err, session.ErrStateKeyNotExist) {
+		t.Error("expected draft to be removed")
+	}
+
+	t.Run("delete", func(t *testing.T) {
+		if err := state.Delete("lang"); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		got, _ := svc.Get(ctx, &session.GetRequest{AppName: appName, UserID: userID, SessionID: "delta"})
+		if _, err := got.Session.State().Get("lang"); !errors.Is(err, session.ErrStateKeyNotExist) {
+			t.Error("expected lang to be deleted")
+		}
+	})
+
+	t.Run("appending events after a delta", func(t *testing.T) {
+		if err := svc.AppendEvent(ctx, resp.Session, &session.Event{ID: "after-delta", Author: "user"}); err != nil {
+			t.Errorf("expected no conflict after the delta, got %v", err)
+		}
+	})
+}
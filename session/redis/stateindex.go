@@ -0,0 +1,308 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/cast"
+	"google.golang.org/adk/session"
+)
+
+// ErrStateNotIndexed is returned by FindByState for a state key not indexed by
+// WithStateIndex, or a value that cannot be indexed.
+var ErrStateNotIndexed = errors.New("state key is not indexed")
+
+// FindByStateRequest selects sessions by the values of indexed state keys.
+type FindByStateRequest struct {
+	AppName string
+
+	// Optional. UserID restricts the search to the sessions of a user. Empty
+	// searches the sessions of every user of the app.
+	UserID string
+
+	// State are the state keys a session must all have, with the same values. The
+	// keys must be indexed, see WithStateIndex, and the values strings, numbers or
+	// booleans.
+	State map[string]any
+}
+
+// WithStateIndex indexes the sessions by the values of the given state keys, so
+// FindByState selects them without reading every session, e.g. every session whose
+// "topic" is "billing". Only string, number and boolean values are indexed, by their
+// string form: 1 and "1" match alike.
+//
+// The index is a set per app, key and value, "stateidx:{app}:{key}:{value}", of the
+// "{userID}\0{sessionID}" members, plus a hash per session of its indexed values,
+// "stateidx-entry:{app}:{user}:{session}". It is updated when the state is written by
+// Create, AppendEvent, CreateBranch, State().Set and ApplyDelta, and when a session
+// is restored or imported; sessions stored before the index was enabled are indexed
+// at their next state write. AppendEvent refreshes its TTLs with the session TTL.
+//
+// The sets and hashes are written client-side, so they may be in different slots of
+// a Redis Cluster; concurrent writes of a session may leave it in the set of a
+// value it no longer has, which FindByState checks.
+func WithStateIndex(keys ...string) ServiceOption {
+	return func(s *RedisSessionService) {
+		keys = slices.Clone(keys)
+		slices.Sort(keys)
+		s.stateKeys = slices.Compact(keys)
+	}
+}
+
+func buildStateIndexPrefix(appName string) string {
+	return fmt.Sprintf("stateidx:%s:", appName)
+}
+
+func buildStateIndexKey(appName, key, value string) string {
+	return buildStateIndexPrefix(appName) + key + ":" + value
+}
+
+func buildStateEntryKey(appName, userID, sessionID string) string {
+	return fmt.Sprintf("stateidx-entry:%s:%s:%s", appName, userID, sessionID)
+}
+
+// indexValue returns the indexed form of a state value, false if it cannot be
+// indexed.
+func indexValue(v any) (string, bool) {
+	switch v.(type) {
+	case string, bool, float32, float64, int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64:
+		return cast.ToString(v), true
+	default:
+		return "", false
+	}
+}
+
+// reindexState updates the state index of a session to its state, nil removing the
+// session from the index. A failure is logged: the state is already stored, and
+// FindByState checks the state of the sessions it finds.
+func (s *RedisSessionService) reindexState(
+	ctx context.Context,
+	appName, userID, sessionID string,
+	state map[string]any,
+	ttl time.Duration,
+) {
+	if len(s.stateKeys) == 0 {
+		return
+	}
+
+	entryKey := buildStateEntryKey(appName, userID, sessionID)
+	member := buildIndexMember(userID, sessionID)
+
+	indexed, err := s.rdb.HGetAll(ctx, entryKey).Result()
+	if err != nil {
+		s.logger.Warnf("failed to read the state index entry of session %s: %v", sessionID, err)
+		return
+	}
+
+	// NOTE: Move the session between the sets of the values of each indexed key
+	indexTTL := s.indexTTL(ttl)
+	pipe := s.rdb.Pipeline()
+	var sets []string
+	for _, k := range s.stateKeys {
+		prev, had := indexed[k]
+		next, has := indexValue(state[k])
+		if had && (!has || prev != next) {
+			pipe.SRem(ctx, buildStateIndexKey(appName, k, prev), member)
+			pipe.HDel(ctx, entryKey, k)
+		}
+		if has {
+			set := buildStateIndexKey(appName, k, next)
+			pipe.SAdd(ctx, set, member)
+			pipe.HSet(ctx, entryKey, k, next)
+			sets = append(sets, set)
+		}
+	}
+	if indexTTL > 0 {
+		pipe.Expire(ctx, entryKey, indexTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		s.logger.Warnf("failed to update the state index of session %s: %v", sessionID, err)
+		return
+	}
+
+	if err := s.extendTTLs(ctx, sets, indexTTL); err != nil {
+		s.logger.Warnf("failed to set expire for the state index of session %s: %v", sessionID, err)
+	}
+}
+
+// touchStateIndex refreshes the TTLs of the state index of a session whose TTL is
+// refreshed without an indexed state change, so an active session stays found by
+// FindByState. A failure is logged, as in reindexState.
+func (s *RedisSessionService) touchStateIndex(
+	ctx context.Context,
+	appName, userID, sessionID string,
+	state map[string]any,
+	ttl time.Duration,
+) {
+	indexTTL := s.indexTTL(ttl)
+	if len(s.stateKeys) == 0 || indexTTL <= 0 {
+		return
+	}
+
+	keys := []string{buildStateEntryKey(appName, userID, sessionID)}
+	for _, k := range s.stateKeys {
+		if v, ok := indexValue(state[k]); ok {
+			keys = append(keys, buildStateIndexKey(appName, k, v))
+		}
+	}
+	if err := s.extendTTLs(ctx, keys, indexTTL); err != nil {
+		s.logger.Warnf("failed to refresh expire for the state index of session %s: %v", sessionID, err)
+	}
+}
+
+// extendTTLs extends the TTL of keys to ttl, keeping the longer ones: a set of the
+// state index expires with the last of its sessions.
+func (s *RedisSessionService) extendTTLs(ctx context.Context, keys []string, ttl time.Duration) error {
+	if ttl <= 0 || len(keys) == 0 {
+		return nil
+	}
+
+	pipe := s.rdb.Pipeline()
+	cmds := make([]*redis.DurationCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.TTL(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	pipe = s.rdb.Pipeline()
+	for i, cmd := range cmds {
+		// NOTE: TTL is negative for a key without TTL, missing keys are left missing
+		if cmd.Val() < ttl && cmd.Val() != -2 {
+			pipe.Expire(ctx, keys[i], ttl)
+		}
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// stateReindexer returns the hook updating the state index of a session after its
+// state is written through the session, nil without a state index.
+func (s *RedisSessionService) stateReindexer(
+	appName, userID, sessionID string,
+	ttl time.Duration,
+) func(context.Context, map[string]any) {
+	if len(s.stateKeys) == 0 {
+		return nil
+	}
+
+	return func(ctx context.Context, state map[string]any) {
+		s.reindexState(ctx, appName, userID, sessionID, state, ttl)
+	}
+}
+
+// FindByState lists the sessions having all the requested state values, as List
+// does, in user and session ID order. The sessions are found with the state index,
+// and their state is checked against the request.
+//
+// Returns ErrStateNotIndexed if a requested key is not indexed or its value cannot
+// be indexed.
+func (s *RedisSessionService) FindByState(
+	ctx context.Context,
+	req *FindByStateRequest,
+) (*session.ListResponse, error) {
+	if len(req.State) == 0 {
+		return nil, fmt.Errorf("%w: no state key requested", ErrStateNotIndexed)
+	}
+
+	want := make(map[string]string, len(req.State))
+	for k, v := range req.State {
+		if !slices.Contains(s.stateKeys, k) {
+			return nil, fmt.Errorf("%w: %s", ErrStateNotIndexed, k)
+		}
+		indexed, ok := indexValue(v)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s has a %T value", ErrStateNotIndexed, k, v)
+		}
+		want[k] = indexed
+	}
+
+	// NOTE: Each set is read apart, so the sets may be in different cluster slots
+	setKeys := make([]string, 0, len(want))
+	for _, k := range slices.Sorted(maps.Keys(want)) {
+		setKeys = append(setKeys, buildStateIndexKey(req.AppName, k, want[k]))
+	}
+
+	pipe := s.rdb.Pipeline()
+	cmds := make([]*redis.StringSliceCmd, len(setKeys))
+	for i, key := range setKeys {
+		cmds[i] = pipe.SMembers(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		s.logger.Errorf("failed to read the state index of app %s: %v", req.AppName, err)
+		return nil, fmt.Errorf("failed to read state index: %w", err)
+	}
+
+	members := cmds[0].Val()
+	for _, cmd := range cmds[1:] {
+		members = slices.DeleteFunc(members, func(m string) bool { return !slices.Contains(cmd.Val(), m) })
+	}
+	slices.Sort(members)
+
+	// NOTE: Read the sessions of each user with a pipeline
+	byUser := make(map[string][]string)
+	var users []string
+	for _, m := range members {
+		ref, ok := parseIndexMember(m)
+		if !ok || (req.UserID != "" && ref.UserID != req.UserID) {
+			continue
+		}
+		if _, seen := byUser[ref.UserID]; !seen {
+			users = append(users, ref.UserID)
+		}
+		byUser[ref.UserID] = append(byUser[ref.UserID], ref.SessionID)
+	}
+
+	var sessions []session.Session
+	var stale []string
+	for _, userID := range users {
+		found, missing, err := s.fetchSessions(ctx, s.readClient(), req.AppName, userID, byUser[userID])
+		if err != nil {
+			return nil, err
+		}
+		for _, sessionID := range missing {
+			stale = append(stale, buildIndexMember(userID, sessionID))
+		}
+
+		for _, sessionID := range byUser[userID] {
+			storable, ok := found[sessionID]
+			if !ok || !matchState(storable.State, want) {
+				continue
+			}
+			sessions = append(sessions, s.listedSession(storable))
+		}
+	}
+
+	// NOTE: Drop the sessions gone from Redis from the sets read
+	if len(stale) > 0 {
+		pipe := s.rdb.Pipeline()
+		for _, key := range setKeys {
+			pipe.SRem(ctx, key, stale)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			s.logger.Warnf("failed to clean the state index of app %s: %v", req.AppName, err)
+		}
+	}
+
+	s.logger.Debugf("found %d sessions of app %s with state %v", len(sessions), req.AppName, req.State)
+
+	return &session.ListResponse{Sessions: sessions}, nil
+}
+
+// matchState reports whether state has the indexed values of want.
+func matchState(state map[string]any, want map[string]string) bool {
+	for k, v := range want {
+		indexed, ok := indexValue(state[k])
+		if !ok || indexed != v {
+			return false
+		}
+	}
+	return true
+}
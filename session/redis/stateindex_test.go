@@ -0,0 +1,153 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/adk/session"
+)
+
+func TestIndexValue(t *testing.T) {
+	tests := []struct {
+		value  any
+		want   string
+		wantOK bool
+	}{
+		{value: "billing", want: "billing", wantOK: true},
+		{value: float64(3), want: "3", wantOK: true},
+		{value: 3, want: "3", wantOK: true},
+		{value: true, want: "true", wantOK: true},
+		{value: nil},
+		{value: []any{"a"}},
+		{value: map[string]any{"a": 1}},
+	}
+
+	for _, tt := range tests {
+		got, ok := indexValue(tt.value)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("indexValue(%#v) = %q, %v, want %q, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestWithStateIndex(t *testing.T) {
+	svc := &RedisSessionService{}
+	WithStateIndex("topic", "channel", "topic")(svc)
+
+	if want := []string{"channel", "topic"}; !slices.Equal(svc.stateKeys, want) {
+		t.Errorf("stateKeys = %v, want %v", svc.stateKeys, want)
+	}
+
+	_, err := svc.FindByState(context.Background(), &FindByStateRequest{
+		AppName: "app",
+		State:   map[string]any{"priority": "high"},
+	})
+	if !errors.Is(err, ErrStateNotIndexed) {
+		t.Errorf("FindByState() of an unindexed key error = %v, want ErrStateNotIndexed", err)
+	}
+}
+
+func TestFindByState(t *testing.T) {
+	svc, rdb := setupTestRedis(t, WithStateIndex("topic", "step"))
+	ctx := context.Background()
+
+	const appName = "stateidx-app"
+	t.Cleanup(func() { cleanupTestKeys(t, rdb, "*:"+appName+":*") })
+
+	create := func(userID string, state map[string]any) session.Session {
+		t.Helper()
+		resp, err := svc.Create(ctx, &session.CreateRequest{AppName: appName, UserID: userID, State: state})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		return resp.Session
+	}
+	find := func(req *FindByStateRequest) []string {
+		t.Helper()
+		req.AppName = appName
+		resp, err := svc.FindByState(ctx, req)
+		if err != nil {
+			t.Fatalf("FindByState() error = %v", err)
+		}
+		var ids []string
+		for _, sess := range resp.Sessions {
+			ids = append(ids, sess.ID())
+		}
+		slices.Sort(ids)
+		return ids
+	}
+
+	billing := create("alice", map[string]any{"topic": "billing", "step": 1})
+	shipping := create("alice", map[string]any{"topic": "shipping"})
+	other := create("bob", map[string]any{"topic": "billing", "step": 2})
+
+	t.Run("across users", func(t *testing.T) {
+		got := find(&FindByStateRequest{State: map[string]any{"topic": "billing"}})
+		want := []string{billing.ID(), other.ID()}
+		slices.Sort(want)
+		if !slices.Equal(got, want) {
+			t.Errorf("found %v, want %v", got, want)
+		}
+	})
+
+	t.Run("of a user, several keys", func(t *testing.T) {
+		got := find(&FindByStateRequest{UserID: "bob", State: map[string]any{"topic": "billing", "step": 2}})
+		if !slices.Equal(got, []string{other.ID()}) {
+			t.Errorf("found %v, want %v", got, []string{other.ID()})
+		}
+	})
+
+	t.Run("follows state changes", func(t *testing.T) {
+		evt := &session.Event{Author: "agent"}
+		evt.Actions.StateDelta = map[string]any{"topic": "billing"}
+		if err := shipping.State().Set("topic", "billing"); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+		if err := svc.AppendEvent(ctx, shipping, evt); err != nil {
+			t.Fatalf("AppendEvent() error = %v", err)
+		}
+
+		if got := find(&FindByStateRequest{State: map[string]any{"topic": "shipping"}}); len(got) != 0 {
+			t.Errorf("found %v for the old value, want none", got)
+		}
+		if got := find(&FindByStateRequest{UserID: "alice", State: map[string]any{"topic": "billing"}}); len(got) != 2 {
+			t.Errorf("found %v, want both sessions of alice", got)
+		}
+	})
+
+	t.Run("refreshes the index TTLs", func(t *testing.T) {
+		keys := []string{
+			buildStateEntryKey(appName, "alice", billing.ID()),
+			buildStateIndexKey(appName, "topic", "billing"),
+			buildStateIndexKey(appName, "step", "1"),
+		}
+		for _, key := range keys {
+			if err := rdb.Expire(ctx, key, time.Minute).Err(); err != nil {
+				t.Fatalf("Expire() error = %v", err)
+			}
+		}
+
+		// NOTE: The event leaves the indexed state unchanged
+		if err := svc.AppendEvent(ctx, billing, &session.Event{Author: "agent"}); err != nil {
+			t.Fatalf("AppendEvent() error = %v", err)
+		}
+		for _, key := range keys {
+			if ttl := rdb.TTL(ctx, key).Val(); ttl <= time.Minute {
+				t.Errorf("TTL of %s = %v, want it refreshed", key, ttl)
+			}
+		}
+	})
+
+	t.Run("drops deleted sessions", func(t *testing.T) {
+		err := svc.Delete(ctx, &session.DeleteRequest{AppName: appName, UserID: "bob", SessionID: other.ID()})
+		if err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if got := find(&FindByStateRequest{UserID: "bob", State: map[string]any{"topic": "billing"}}); len(got) != 0 {
+			t.Errorf("found %v, want none", got)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package redis
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SessionStats is the Redis footprint of a session.
+type SessionStats struct {
+	SessionID string `json:"session_id"`
+
+	// Bytes is the MEMORY USAGE of every key of the session: the session, its
+	// events, its state log and its counters.
+	Bytes int64 `json:"bytes"`
+	// EventBytes is the MEMORY USAGE of the event list alone.
+	EventBytes int64 `json:"event_bytes"`
+
+	// Events is the number of events stored in Redis, trimmed events excluded.
+	Events int64 `json:"events"`
+
+	// TTL is the time left before the session expires, 0 if it does not expire.
+	TTL time.Duration `json:"ttl"`
+}
+
+// UserStats is the Redis footprint of the sessions of a user.
+type UserStats struct {
+	AppName string `json:"app_name"`
+	UserID  string `json:"user_id"`
+
+	// Sessions are the stats of each session, largest first.
+	Sessions []SessionStats `json:"sessions"`
+
+	// Bytes and Events are the totals of the sessions.
+	Bytes  int64 `json:"bytes"`
+	Events int64 `json:"events"`
+}
+
+// Stats reads the memory used, the event count and the TTL left of every session of
+// a user, to enforce quotas and find oversized sessions. It costs a pipeline of
+// MEMORY USAGE, LLEN and PTTL commands per call, so it suits admin tools rather than
+// the request path; see UsageInspector for app-wide reports. MEMORY USAGE samples
+// the elements of the event lists, so the bytes of long lists are estimates.
+func (s *RedisSessionService) Stats(ctx context.Context, appName, userID string) (*UserStats, error) {
+	sessionIDs, err := s.indexSessionIDs(ctx, appName, userID)
+	if err != nil {
+		s.logger.Errorf("failed to list sessions for user %s: %v", userID, err)
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	slices.Sort(sessionIDs)
+
+	type sessionCmds struct {
+		ttl        *redis.DurationCmd
+		events     *redis.IntCmd
+		eventBytes *redis.IntCmd
+		bytes      []*redis.IntCmd
+	}
+
+	pipe := s.rdb.Pipeline()
+	cmds := make([]sessionCmds, len(sessionIDs))
+	for i, sessionID := range sessionIDs {
+		cmds[i].ttl = pipe.PTTL(ctx, buildSessionKey(appName, userID, sessionID))
+		cmds[i].events = pipe.LLen(ctx, buildEventsKey(appName, userID, sessionID))
+
+		for _, prefix := range usagePrefixes {
+			key := fmt.Sprintf("%s:%s:%s:%s", prefix, appName, userID, sessionID)
+			cmd := pipe.MemoryUsage(ctx, key)
+			cmds[i].bytes = append(cmds[i].bytes, cmd)
+			if prefix == "events" {
+				cmds[i].eventBytes = cmd
+			}
+		}
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		s.logger.Errorf("failed to read the stats of user %s: %v", userID, err)
+		return nil, fmt.Errorf("failed to read session stats: %w", err)
+	}
+
+	stats := &UserStats{AppName: appName, UserID: userID, Sessions: make([]SessionStats, 0, len(sessionIDs))}
+	for i, sessionID := range sessionIDs {
+		// NOTE: -2 is a missing key, a session expired but still indexed
+		ttl := cmds[i].ttl.Val()
+		if ttl == -2 {
+			continue
+		}
+
+		sess := SessionStats{
+			SessionID:  sessionID,
+			Events:     cmds[i].events.Val(),
+			EventBytes: cmds[i].eventBytes.Val(),
+			TTL:        max(ttl, 0),
+		}
+		for _, cmd := range cmds[i].bytes {
+			sess.Bytes += cmd.Val()
+		}
+
+		stats.Sessions = append(stats.Sessions, sess)
+		stats.Bytes += sess.Bytes
+		stats.Events += sess.Events
+	}
+
+	slices.SortStableFunc(stats.Sessions, func(a, b SessionStats) int { return cmp.Compare(b.Bytes, a.Bytes) })
+
+	return stats, nil
+}
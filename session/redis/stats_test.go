@@ -0,0 +1,61 @@
+package redis
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/adk/session"
+)
+
+func TestStats(t *testing.T) {
+	svc, rdb := setupTestRedis(t)
+	ctx := context.Background()
+
+	const appName, userID = "stats-app", "stats-user"
+	t.Cleanup(func() { cleanupTestKeys(t, rdb, "*:"+appName+":*") })
+
+	create := func(events int) session.Session {
+		t.Helper()
+		resp, err := svc.Create(ctx, &session.CreateRequest{AppName: appName, UserID: userID})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		for range events {
+			if err := svc.AppendEvent(ctx, resp.Session, &session.Event{Author: "agent"}); err != nil {
+				t.Fatalf("AppendEvent() error = %v", err)
+			}
+		}
+		return resp.Session
+	}
+
+	large := create(20)
+	small := create(1)
+
+	stats, err := svc.Stats(ctx, appName, userID)
+	if err != nil {
+		t.Fatalf("Stats() error = %v", err)
+	}
+
+	if len(stats.Sessions) != 2 {
+		t.Fatalf("sessions = %d, want 2", len(stats.Sessions))
+	}
+	if got := stats.Sessions[0]; got.SessionID != large.ID() || got.Events != 20 {
+		t.Errorf("largest session = %+v, want %s with 20 events", got, large.ID())
+	}
+	if got := stats.Sessions[1]; got.SessionID != small.ID() || got.Events != 1 {
+		t.Errorf("smallest session = %+v, want %s with 1 event", got, small.ID())
+	}
+
+	for _, sess := range stats.Sessions {
+		if sess.Bytes <= sess.EventBytes || sess.EventBytes <= 0 {
+			t.Errorf("session %s bytes = %d, event bytes = %d", sess.SessionID, sess.Bytes, sess.EventBytes)
+		}
+		if sess.TTL <= 0 || sess.TTL > svc.ttl {
+			t.Errorf("session %s TTL = %s, want within %s", sess.SessionID, sess.TTL, svc.ttl)
+		}
+	}
+
+	if stats.Events != 21 || stats.Bytes != stats.Sessions[0].Bytes+stats.Sessions[1].Bytes {
+		t.Errorf("totals = %d events, %d bytes", stats.Events, stats.Bytes)
+	}
+}
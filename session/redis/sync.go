@@ -0,0 +1,227 @@
+package redis
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/bytedance/sonic"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/adk/session"
+)
+
+// SyncRequest identifies a session and the checkpoint of a client.
+type SyncRequest struct {
+	AppName   string
+	UserID    string
+	SessionID string
+
+	// SinceEvent is the event sequence of the checkpoint: the number of events the
+	// client has. 0 requests every event.
+	SinceEvent int
+
+	// SinceStateVersion is the state version of the checkpoint. 0 requests the full state.
+	// Sessions start at version 1.
+	SinceStateVersion int64
+}
+
+// SyncResponse holds the changes of a session since a checkpoint, and the new checkpoint.
+type SyncResponse struct {
+	// Events are the events after SinceEvent, in order.
+	Events []*session.Event
+
+	// EventSequence is the new event checkpoint: the number of events of the session.
+	EventSequence int
+
+	// EventsReset reports that SinceEvent was ahead of the session (e.g. it was
+	// recreated) or behind its trimmed events, so Events hold every event and
+	// replace the client's.
+	EventsReset bool
+
+	// StateVersion is the new state checkpoint.
+	StateVersion int64
+
+	// FullState reports that State holds the whole state, replacing the client's.
+	// It is set when SinceStateVersion is 0, ahead of the session, or older than
+	// the state log.
+	FullState bool
+	State     map[string]any
+
+	// StateSet and StateRemoved are the state diff since SinceStateVersion, when
+	// FullState is false.
+	StateSet     map[string]any
+	StateRemoved []string
+}
+
+// Sync returns the events appended and the state changed since the checkpoint of a
+// client, so clients can resync cheaply instead of reloading the session.
+//
+// Events are addressed by their position in the append-only event list, counting
+// the events trimmed by the trim policy, and the state by the version bumped on
+// every change; the diff since a version is merged
+// from the state log of the session.
+func (s *RedisSessionService) Sync(ctx context.Context, req *SyncRequest) (*SyncResponse, error) {
+	s.logger.Debugf("syncing session: app=%s, user=%s, session=%s, since_event=%d, since_state=%d",
+		req.AppName, req.UserID, req.SessionID, req.SinceEvent, req.SinceStateVersion)
+
+	key := buildSessionKey(req.AppName, req.UserID, req.SessionID)
+	evKey := buildEventsKey(req.AppName, req.UserID, req.SessionID)
+	logKey := buildStateLogKey(req.AppName, req.UserID, req.SessionID)
+
+	sinceEvent := max(req.SinceEvent, 0)
+
+	// NOTE: With trimming, list positions are offset by the trimmed events: the
+	// whole list, bounded by the policy, is read
+	start := sinceEvent
+	if s.trim != nil {
+		start = 0
+	}
+
+	// NOTE: Read a consistent snapshot of the session, its new events and the state log
+	pipe := s.rdb.TxPipeline()
+	sessCmd := pipe.Get(ctx, key)
+	trimmedCmd := pipe.Get(ctx, buildEventTrimKey(req.AppName, req.UserID, req.SessionID))
+	lenCmd := pipe.LLen(ctx, evKey)
+	eventsCmd := pipe.LRange(ctx, evKey, int64(start), -1)
+	logCmd := pipe.LRange(ctx, logKey, 0, -1)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		s.logger.Errorf("failed to sync session %s: %v", req.SessionID, err)
+		return nil, fmt.Errorf("failed to sync session: %w", err)
+	}
+
+	data, err := sessCmd.Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, req.SessionID)
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	var storable storableSession
+	if err := sonic.Unmarshal(data, &storable); err != nil {
+		s.logger.Errorf("failed to unmarshal session %s: %v", req.SessionID, err)
+		return nil, fmt.Errorf("failed to unmarshal session: %w", err)
+	}
+	if err := s.openStored(ctx, &storable); err != nil {
+		s.logger.Errorf("failed to decrypt session %s: %v", req.SessionID, err)
+		return nil, err
+	}
+
+	trimmed, _ := trimmedCmd.Int()
+	resp := &SyncResponse{
+		EventSequence: trimmed + int(lenCmd.Val()),
+		StateVersion:  storable.StateVersion,
+	}
+
+	// NOTE: Events after the checkpoint. The list starts at event trimmed+start.
+	eventData := eventsCmd.Val()
+	switch skip := sinceEvent - trimmed - start; {
+	case sinceEvent > resp.EventSequence || sinceEvent < trimmed:
+		// The checkpoint is ahead of the session, or its next events were trimmed
+		resp.EventsReset = true
+		eventData, err = s.rdb.LRange(ctx, evKey, 0, -1).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, fmt.Errorf("failed to get events: %w", err)
+		}
+		resp.EventSequence = trimmed + len(eventData)
+
+	case skip > 0:
+		eventData = eventData[min(skip, len(eventData)):]
+
+	case skip < 0:
+		// Trimming was disabled since the session was trimmed
+		eventData, err = s.rdb.LRange(ctx, evKey, int64(sinceEvent-trimmed), -1).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, fmt.Errorf("failed to get events: %w", err)
+		}
+	}
+
+	for i, ed := range eventData {
+		var evt session.Event
+		if err := s.codec.forApp(req.AppName).unmarshal(ctx, ed, &evt); err != nil {
+			s.logger.Warnf("failed to unmarshal event at index %d of session %s: %v", i, req.SessionID, err)
+			continue
+		}
+		resp.Events = append(resp.Events, &evt)
+	}
+
+	// NOTE: State diff since the checkpoint
+	since := req.SinceStateVersion
+	switch {
+	case since == storable.StateVersion:
+	case since <= 0 || since > storable.StateVersion:
+		resp.FullState, resp.State = true, storable.State
+	default:
+		set, removed, ok := s.mergeStateChanges(logCmd.Val(), since, storable.StateVersion)
+		if ok {
+			// NOTE: The log keeps the state values as they are stored
+			if err := s.stateCipher(req.AppName).open(ctx, set); err != nil {
+				s.logger.Errorf("failed to decrypt state changes of session %s: %v", req.SessionID, err)
+				return nil, err
+			}
+			resp.StateSet, resp.StateRemoved = set, removed
+		} else {
+			resp.FullState, resp.State = true, storable.State
+		}
+	}
+	if resp.FullState && resp.State == nil {
+		resp.State = make(map[string]any)
+	}
+
+	s.logger.Infof("session synced: session=%s, events=%d, event_sequence=%d, state_version=%d, full_state=%v",
+		req.SessionID, len(resp.Events), resp.EventSequence, resp.StateVersion, resp.FullState)
+
+	return resp, nil
+}
+
+// mergeStateChanges merges the state log entries of the versions in (since, until]
+// into the keys set and removed. ok is false if an entry is missing, e.g. the log
+// expired or an append failed.
+func (s *RedisSessionService) mergeStateChanges(
+	entries []string,
+	since, until int64,
+) (set map[string]any, removed []string, ok bool) {
+	changes := make([]stateChange, 0, until-since)
+	seen := make(map[int64]bool)
+	for _, entry := range entries {
+		var change stateChange
+		if err := sonic.UnmarshalString(entry, &change); err != nil {
+			s.logger.Warnf("failed to unmarshal state change: %v", err)
+			continue
+		}
+		if change.Version <= since || change.Version > until || seen[change.Version] {
+			continue
+		}
+		seen[change.Version] = true
+		changes = append(changes, change)
+	}
+	if int64(len(changes)) != until-since {
+		return nil, nil, false
+	}
+
+	// Concurrent writers may append their entries out of order
+	slices.SortFunc(changes, func(a, b stateChange) int { return cmp.Compare(a.Version, b.Version) })
+
+	set = make(map[string]any)
+	removedSet := make(map[string]bool)
+	for _, change := range changes {
+		for k, v := range change.Set {
+			set[k] = v
+			delete(removedSet, k)
+		}
+		for _, k := range change.Removed {
+			delete(set, k)
+			removedSet[k] = true
+		}
+	}
+
+	removed = make([]string, 0, len(removedSet))
+	for k := range removedSet {
+		removed = append(removed, k)
+	}
+	slices.Sort(removed)
+
+	return set, removed, true
+}
@@ -0,0 +1,140 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"google.golang.org/adk/session"
+)
+
+func TestSync(t *testing.T) {
+	const (
+		appName = "test_sync_app"
+		userID  = "test_sync_user"
+	)
+
+	svc, rdb := setupTestRedis(t, WithTTL(30*time.Second))
+	t.Cleanup(func() {
+		cleanupTestKeys(t, rdb,
+			fmt.Sprintf("session:%s:*", appName),
+			fmt.Sprintf("events:%s:*", appName),
+			fmt.Sprintf("statelog:%s:*", appName),
+		)
+	})
+
+	ctx := context.Background()
+
+	createResp, err := svc.Create(ctx, &session.CreateRequest{
+		AppName: appName, UserID: userID, SessionID: "sync",
+		State: map[string]any{"a": "1", "b": "1"},
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	sess := createResp.Session
+
+	sync := func(t *testing.T, sinceEvent int, sinceState int64) *SyncResponse {
+		t.Helper()
+		resp, err := svc.Sync(ctx, &SyncRequest{
+			AppName: appName, UserID: userID, SessionID: "sync",
+			SinceEvent: sinceEvent, SinceStateVersion: sinceState,
+		})
+		if err != nil {
+			t.Fatalf("Sync failed: %v", err)
+		}
+		return resp
+	}
+
+	if err := svc.AppendEvent(ctx, sess, &session.Event{ID: "e1"}); err != nil {
+		t.Fatalf("AppendEvent failed: %v", err)
+	}
+
+	initial := sync(t, 0, 0)
+	if len(initial.Events) != 1 || initial.EventSequence != 1 || !initial.FullState || initial.State["a"] != "1" {
+		t.Fatalf("unexpected initial sync: %+v", initial)
+	}
+
+	// Change the state through the session and through events
+	if err := sess.State().Set("a", "2"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := sess.State().Set("c", "1"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := svc.AppendEvent(ctx, sess, &session.Event{ID: "e2"}); err != nil {
+		t.Fatalf("AppendEvent failed: %v", err)
+	}
+
+	t.Run("returns new events and the state diff", func(t *testing.T) {
+		resp := sync(t, initial.EventSequence, initial.StateVersion)
+		if len(resp.Events) != 1 || resp.Events[0].ID != "e2" || resp.EventSequence != 2 {
+			t.Errorf("unexpected events: %+v", resp)
+		}
+		if resp.FullState || resp.StateVersion != initial.StateVersion+2 {
+			t.Errorf("unexpected state checkpoint: %+v", resp)
+		}
+		if len(resp.StateSet) != 2 || resp.StateSet["a"] != "2" || resp.StateSet["c"] != "1" {
+			t.Errorf("unexpected state diff: %v", resp.StateSet)
+		}
+	})
+
+	t.Run("up to date checkpoint returns nothing", func(t *testing.T) {
+		latest := sync(t, 0, 0)
+		resp := sync(t, latest.EventSequence, latest.StateVersion)
+		if len(resp.Events) != 0 || resp.FullState || len(resp.StateSet) != 0 || len(resp.StateRemoved) != 0 {
+			t.Errorf("expected no changes, got %+v", resp)
+		}
+	})
+
+	t.Run("checkpoint ahead resets", func(t *testing.T) {
+		resp := sync(t, 10, 100)
+		if !resp.EventsReset || len(resp.Events) != 2 || !resp.FullState || resp.State["c"] != "1" {
+			t.Errorf("expected a reset, got %+v", resp)
+		}
+	})
+
+	t.Run("log keeps the last changes", func(t *testing.T) {
+		for i := range maxStateLogEntries + 10 {
+			if err := sess.State().Set("n", i); err != nil {
+				t.Fatalf("Set failed: %v", err)
+			}
+		}
+		if n := rdb.LLen(ctx, buildStateLogKey(appName, userID, "sync")).Val(); n != maxStateLogEntries {
+			t.Errorf("expected %d log entries, got %d", maxStateLogEntries, n)
+		}
+		resp := sync(t, 2, initial.StateVersion)
+		if !resp.FullState {
+			t.Errorf("expected the full state for a checkpoint older than the log, got %+v", resp)
+		}
+	})
+
+	t.Run("missing log entries fall back to the full state", func(t *testing.T) {
+		rdb.Del(ctx, buildStateLogKey(appName, userID, "sync"))
+		resp := sync(t, 2, initial.StateVersion)
+		if !resp.FullState || resp.State["a"] != "2" {
+			t.Errorf("expected the full state, got %+v", resp)
+		}
+	})
+}
+
+func TestMergeStateChanges(t *testing.T) {
+	svc := &RedisSessionService{logger: &discardlog.DiscardLog{}}
+
+	entries := []string{
+		`{"version":1,"set":{"a":1}}`,
+		`{"version":3,"removed":["a"]}`,
+		`{"version":2,"set":{"a":2,"b":2}}`,
+	}
+
+	set, removed, ok := svc.mergeStateChanges(entries, 1, 3)
+	if !ok || len(set) != 1 || set["b"] != float64(2) || len(removed) != 1 || removed[0] != "a" {
+		t.Errorf("unexpected merge: set=%v, removed=%v, ok=%v", set, removed, ok)
+	}
+
+	if _, _, ok := svc.mergeStateChanges(entries, 0, 4); ok {
+		t.Error("expected a missing version to fail the merge")
+	}
+}
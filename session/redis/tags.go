@@ -0,0 +1,180 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bytedance/sonic"
+	"github.com/kydenul/k-adk/internal/redisscript"
+	ksess "github.com/kydenul/k-adk/session"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/adk/session"
+)
+
+// setTagsScript atomically sets and removes tags of the session at KEYS[1],
+// keeping its TTL, and returns the updated session.
+//
+// ARGV[1]: the tags to set, a JSON object
+// ARGV[2]: the keys of the tags to remove, a JSON array
+var setTagsScript = redisscript.New("session_set_tags", `
+local data = redis.call('GET', KEYS[1])
+if not data then
+    return false
+end
+
+local session = cjson.decode(data)
+local tags = session.tags
+if type(tags) ~= 'table' then
+    tags = {}
+end
+
+for _, k in ipairs(cjson.decode(ARGV[2])) do
+    tags[k] = nil
+end
+for k, v in pairs(cjson.decode(ARGV[1])) do
+    tags[k] = v
+end
+
+if next(tags) == nil then
+    session.tags = nil
+else
+    session.tags = tags
+end
+
+data = cjson.encode(session)
+redis.call('SET', KEYS[1], data, 'KEEPTTL')
+return data
+`)
+
+// SetTagsRequest describes a change of the tags of a session.
+type SetTagsRequest struct {
+	AppName   string
+	UserID    string
+	SessionID string
+
+	// Set are the tags to add or overwrite.
+	Set map[string]string
+
+	// Remove are the keys of the tags to remove. A key also in Set is set.
+	Remove []string
+}
+
+// FindRequest selects the sessions of a user by tag.
+type FindRequest struct {
+	AppName string
+	UserID  string
+
+	// Tags are the tags a session must all have, with the same values. Empty
+	// finds every session of the user, like List.
+	Tags map[string]string
+}
+
+// SetTags sets and removes tags of a session, atomically, and returns its tags.
+// Tags are key/value labels organizing sessions, e.g. topic, channel or priority:
+// they are kept apart from the state, are read with ksess.TagsOf and select
+// sessions with Find. Changing them does not update the last update time of the
+// session. With WithPersister, the tags are persisted with the session.
+//
+// Returns ErrSessionNotFound if the session does not exist and ksess.ErrInvalidTag
+// for an invalid key or value, see ksess.ValidateTags.
+func (s *RedisSessionService) SetTags(ctx context.Context, req *SetTagsRequest) (map[string]string, error) {
+	if err := ksess.ValidateTags(req.Set); err != nil {
+		return nil, err
+	}
+	for _, k := range req.Remove {
+		if err := ksess.ValidateTagKey(k); err != nil {
+			return nil, err
+		}
+	}
+
+	// NOTE: Non-nil, so the script decodes a table rather than null
+	set := req.Set
+	if set == nil {
+		set = map[string]string{}
+	}
+	remove := req.Remove
+	if remove == nil {
+		remove = []string{}
+	}
+
+	setJSON, err := sonic.Marshal(set)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal tags: %w", err)
+	}
+	removeJSON, err := sonic.Marshal(remove)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal tag keys: %w", err)
+	}
+
+	key := buildSessionKey(req.AppName, req.UserID, req.SessionID)
+	data, err := setTagsScript.Run(ctx, s.rdb, []string{key}, setJSON, removeJSON).Text()
+
+	// NOTE: Rebuild a session evicted from Redis from the loader
+	if errors.Is(err, redis.Nil) && s.loader != nil {
+		restored, rErr := s.restore(ctx, req.AppName, req.UserID, req.SessionID)
+		if rErr != nil {
+			return nil, rErr
+		}
+		if restored {
+			data, err = setTagsScript.Run(ctx, s.rdb, []string{key}, setJSON, removeJSON).Text()
+		}
+	}
+
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, req.SessionID)
+		}
+
+		s.logger.Errorf("failed to set tags of session %s: %v", req.SessionID, err)
+		return nil, fmt.Errorf("failed to set tags: %w", err)
+	}
+
+	var storable storableSession
+	if err := sonic.UnmarshalString(data, &storable); err != nil {
+		s.logger.Errorf("failed to unmarshal session %s: %v", req.SessionID, err)
+		return nil, fmt.Errorf("failed to unmarshal session: %w", err)
+	}
+	if err := s.openStored(ctx, &storable); err != nil {
+		s.logger.Errorf("failed to decrypt session %s: %v", req.SessionID, err)
+		return nil, err
+	}
+
+	s.logger.Infof("session tags set: session=%s, tags=%v", req.SessionID, storable.Tags)
+
+	// NOTE: Persist to PostgreSQL if persister is configured
+	if s.persister != nil {
+		if err := s.persister.PersistSession(ctx, s.listedSession(&storable)); err != nil {
+			s.logger.Warnf("failed to persist tags of session %s to postgres: %v", req.SessionID, err)
+			// Don't fail the request, Redis is the primary storage
+		}
+	}
+
+	if storable.Tags == nil {
+		return map[string]string{}, nil
+	}
+	return storable.Tags, nil
+}
+
+// Find lists the sessions of a user having all the requested tags, as List does.
+func (s *RedisSessionService) Find(ctx context.Context, req *FindRequest) (*session.ListResponse, error) {
+	resp, err := s.List(ctx, &session.ListRequest{AppName: req.AppName, UserID: req.UserID})
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Tags) == 0 {
+		return resp, nil
+	}
+
+	sessions := make([]session.Session, 0, len(resp.Sessions))
+	for _, sess := range resp.Sessions {
+		if ksess.MatchTags(ksess.TagsOf(sess), req.Tags) {
+			sessions = append(sessions, sess)
+		}
+	}
+
+	s.logger.Debugf("found %d of %d sessions of user %s with tags %v",
+		len(sessions), len(resp.Sessions), req.UserID, req.Tags)
+
+	return &session.ListResponse{Sessions: sessions}, nil
+}
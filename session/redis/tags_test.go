@@ -0,0 +1,133 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"testing"
+	"time"
+
+	ksess "github.com/kydenul/k-adk/session"
+	"google.golang.org/adk/session"
+)
+
+func TestSessionTags(t *testing.T) {
+	const (
+		appName = "test_tags_app"
+		userID  = "test_tags_user"
+	)
+
+	svc, rdb := setupTestRedis(t, WithTTL(30*time.Second))
+	t.Cleanup(func() {
+		cleanupTestKeys(t, rdb,
+			fmt.Sprintf("session:%s:*", appName),
+			fmt.Sprintf("events:%s:*", appName),
+		)
+	})
+
+	ctx := context.Background()
+
+	for _, id := range []string{"s1", "s2", "s3"} {
+		if _, err := svc.Create(ctx, &session.CreateRequest{
+			AppName: appName, UserID: userID, SessionID: id,
+			State: map[string]any{"n": 1},
+		}); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	setTags := func(t *testing.T, id string, set map[string]string, remove ...string) map[string]string {
+		t.Helper()
+		tags, err := svc.SetTags(ctx, &SetTagsRequest{
+			AppName: appName, UserID: userID, SessionID: id, Set: set, Remove: remove,
+		})
+		if err != nil {
+			t.Fatalf("SetTags failed: %v", err)
+		}
+		return tags
+	}
+
+	t.Run("set and remove", func(t *testing.T) {
+		setTags(t, "s1", map[string]string{"topic": "billing", "channel": "web"})
+		tags := setTags(t, "s1", map[string]string{"priority": "high"}, "channel")
+		if want := map[string]string{"topic": "billing", "priority": "high"}; !maps.Equal(tags, want) {
+			t.Errorf("expected %v, got %v", want, tags)
+		}
+
+		resp, err := svc.Get(ctx, &session.GetRequest{AppName: appName, UserID: userID, SessionID: "s1"})
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if got := ksess.TagsOf(resp.Session); !maps.Equal(got, tags) {
+			t.Errorf("expected %v, got %v", tags, got)
+		}
+		if v, _ := resp.Session.State().Get("n"); v == nil {
+			t.Error("expected the state to be kept")
+		}
+		if ttl := rdb.TTL(ctx, buildSessionKey(appName, userID, "s1")).Val(); ttl <= 0 {
+			t.Errorf("expected the TTL to be kept, got %s", ttl)
+		}
+
+		if tags := setTags(t, "s1", nil, "topic", "priority"); len(tags) != 0 {
+			t.Errorf("expected no tags, got %v", tags)
+		}
+	})
+
+	t.Run("find", func(t *testing.T) {
+		setTags(t, "s1", map[string]string{"topic": "billing", "channel": "web"})
+		setTags(t, "s2", map[string]string{"topic": "billing", "channel": "email"})
+
+		resp, err := svc.Find(ctx, &FindRequest{
+			AppName: appName, UserID: userID, Tags: map[string]string{"topic": "billing"},
+		})
+		if err != nil {
+			t.Fatalf("Find failed: %v", err)
+		}
+		if len(resp.Sessions) != 2 {
+			t.Errorf("expected 2 sessions, got %d", len(resp.Sessions))
+		}
+
+		resp, err = svc.Find(ctx, &FindRequest{
+			AppName: appName, UserID: userID, Tags: map[string]string{"topic": "billing", "channel": "web"},
+		})
+		if err != nil {
+			t.Fatalf("Find failed: %v", err)
+		}
+		if len(resp.Sessions) != 1 || resp.Sessions[0].ID() != "s1" {
+			t.Errorf("expected s1, got %d sessions", len(resp.Sessions))
+		}
+	})
+
+	t.Run("branch inherits tags", func(t *testing.T) {
+		if _, err := svc.CreateBranch(ctx, &CreateBranchRequest{
+			AppName: appName, UserID: userID, SessionID: "s2", BranchSessionID: "s2-fork",
+		}); err != nil {
+			t.Fatalf("CreateBranch failed: %v", err)
+		}
+
+		resp, err := svc.Get(ctx, &session.GetRequest{AppName: appName, UserID: userID, SessionID: "s2-fork"})
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if got := ksess.TagsOf(resp.Session); got["channel"] != "email" {
+			t.Errorf("expected the tags of s2, got %v", got)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := svc.SetTags(ctx, &SetTagsRequest{
+			AppName: appName, UserID: userID, SessionID: "missing", Set: map[string]string{"a": "b"},
+		})
+		if !errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("expected ErrSessionNotFound, got %v", err)
+		}
+
+		_, err = svc.SetTags(ctx, &SetTagsRequest{
+			AppName: appName, UserID: userID, SessionID: "s3", Set: map[string]string{"a:b": "c"},
+		})
+		if !errors.Is(err, ksess.ErrInvalidTag) {
+			t.Errorf("expected ErrInvalidTag, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,233 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrQuotaExceeded is matched by the errors of the creations and appends refused by
+// the quota of a tenant, see WithTenants.
+var ErrQuotaExceeded = errors.New("tenant quota exceeded")
+
+// Quota names a tenant quota.
+type Quota string
+
+const (
+	// QuotaSessions is the number of sessions of a user.
+	QuotaSessions Quota = "sessions"
+	// QuotaEvents is the number of events of a session.
+	QuotaEvents Quota = "events"
+	// QuotaUserEvents is the total number of events of the sessions of a user.
+	QuotaUserEvents Quota = "user_events"
+)
+
+// QuotaError reports a session creation or event append refused because it would
+// exceed a quota of the tenant.
+type QuotaError struct {
+	AppName string
+	UserID  string
+	// SessionID is the session the event was appended to, empty for QuotaSessions.
+	SessionID string
+
+	Quota Quota
+	Limit int64
+}
+
+func (e *QuotaError) Error() string {
+	switch e.Quota {
+	case QuotaEvents:
+		return fmt.Sprintf("%v: session %s of app %s has %d events", ErrQuotaExceeded, e.SessionID, e.AppName, e.Limit)
+	case QuotaUserEvents:
+		return fmt.Sprintf("%v: user %s of app %s has %d events", ErrQuotaExceeded, e.UserID, e.AppName, e.Limit)
+	}
+	return fmt.Sprintf("%v: user %s of app %s has %d sessions", ErrQuotaExceeded, e.UserID, e.AppName, e.Limit)
+}
+
+// Is reports whether target is ErrQuotaExceeded.
+func (e *QuotaError) Is(target error) bool { return target == ErrQuotaExceeded }
+
+// Tenant holds the quotas and settings of an app sharing the service with others.
+// Zero fields are unlimited, or the service defaults.
+type Tenant struct {
+	// Optional. MaxSessionsPerUser is the number of live sessions a user may have.
+	MaxSessionsPerUser int
+
+	// Optional. MaxEventsPerSession is the number of events a session may hold.
+	MaxEventsPerSession int64
+
+	// Optional. MaxEventsPerUser is the total number of events the live sessions
+	// of a user may hold. It is counted over the sessions of the user on every
+	// append, so pair it with MaxSessionsPerUser to bound that cost.
+	MaxEventsPerUser int64
+
+	// Optional. TTL replaces the service TTL for the sessions of the tenant; a TTL
+	// requested with SessionTTLStateKey still wins.
+	TTL time.Duration
+}
+
+// TenantRegistry holds the tenants of a service by app name. It is safe for
+// concurrent use, so tenants can be updated while the service runs.
+type TenantRegistry struct {
+	mu      sync.RWMutex
+	tenants map[string]Tenant
+}
+
+// NewTenantRegistry creates an empty TenantRegistry.
+func NewTenantRegistry() *TenantRegistry {
+	return &TenantRegistry{tenants: make(map[string]Tenant)}
+}
+
+// Register sets the tenant of appName, replacing the previous one. App names
+// cannot contain ":", which separates the parts of the Redis keys: the keys of a
+// tenant are then prefixed with its own app name only, e.g. "session:{app}:".
+func (r *TenantRegistry) Register(appName string, t Tenant) error {
+	if appName == "" || strings.Contains(appName, ":") {
+		return fmt.Errorf("invalid tenant app name %q", appName)
+	}
+	if t.MaxSessionsPerUser < 0 || t.MaxEventsPerSession < 0 || t.MaxEventsPerUser < 0 {
+		return fmt.Errorf("tenant %s: quotas cannot be negative", appName)
+	}
+	// NOTE: Redis expires keys by the second
+	if t.TTL != 0 && t.TTL < time.Second {
+		return fmt.Errorf("tenant %s: %w: %s is below 1s", appName, ErrInvalidTTL, t.TTL)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t.TTL = t.TTL.Truncate(time.Second)
+	r.tenants[appName] = t
+
+	return nil
+}
+
+// Remove removes the tenant of appName; its sessions are no longer limited.
+func (r *TenantRegistry) Remove(appName string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.tenants, appName)
+}
+
+// Tenant returns the tenant of appName. ok is false if it is not registered.
+func (r *TenantRegistry) Tenant(appName string) (t Tenant, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, ok = r.tenants[appName]
+	return t, ok
+}
+
+// WithTenants enforces the quotas of the tenants of r: Create refuses a session
+// beyond MaxSessionsPerUser and AppendEvent an event beyond MaxEventsPerSession or
+// MaxEventsPerUser, with a *QuotaError matching ErrQuotaExceeded. The quotas are checked before the
+// write, so concurrent writes of a user may exceed them briefly. The sessions
+// created or restored for a tenant with a TTL keep it. Apps that are not
+// registered are not limited.
+func WithTenants(r *TenantRegistry) ServiceOption {
+	return func(s *RedisSessionService) { s.tenants = r }
+}
+
+// tenant returns the tenant of appName, the zero Tenant if there is none.
+func (s *RedisSessionService) tenant(appName string) Tenant {
+	if s.tenants == nil {
+		return Tenant{}
+	}
+	t, _ := s.tenants.Tenant(appName)
+	return t
+}
+
+// checkSessionQuota returns a *QuotaError if the user has MaxSessionsPerUser live
+// sessions already. The index may list expired sessions, so they are only counted
+// once the user is at the quota.
+func (s *RedisSessionService) checkSessionQuota(ctx context.Context, appName, userID string) error {
+	limit := s.tenant(appName).MaxSessionsPerUser
+	if limit <= 0 {
+		return nil
+	}
+
+	ids, err := s.indexSessionIDs(ctx, appName, userID)
+	if err != nil {
+		return fmt.Errorf("failed to count sessions: %w", err)
+	}
+	if len(ids) < limit {
+		return nil
+	}
+
+	pipe := s.rdb.Pipeline()
+	cmds := make([]*redis.IntCmd, 0, len(ids))
+	for _, id := range ids {
+		cmds = append(cmds, pipe.Exists(ctx, buildSessionKey(appName, userID, id)))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to count sessions: %w", err)
+	}
+
+	live := 0
+	for _, cmd := range cmds {
+		live += int(cmd.Val())
+	}
+	if live < limit {
+		return nil
+	}
+
+	return &QuotaError{AppName: appName, UserID: userID, Quota: QuotaSessions, Limit: int64(limit)}
+}
+
+// checkEventQuota returns a *QuotaError if the session holds MaxEventsPerSession
+// events already, or the sessions of the user MaxEventsPerUser.
+func (s *RedisSessionService) checkEventQuota(ctx context.Context, appName, userID, sessionID string) error {
+	tenant := s.tenant(appName)
+
+	if limit := tenant.MaxEventsPerSession; limit > 0 {
+		n, err := s.rdb.LLen(ctx, buildEventsKey(appName, userID, sessionID)).Result()
+		if err != nil {
+			return fmt.Errorf("failed to count events: %w", err)
+		}
+		if n >= limit {
+			return &QuotaError{AppName: appName, UserID: userID, SessionID: sessionID, Quota: QuotaEvents, Limit: limit}
+		}
+	}
+
+	if limit := tenant.MaxEventsPerUser; limit > 0 {
+		n, err := s.countUserEvents(ctx, appName, userID)
+		if err != nil {
+			return fmt.Errorf("failed to count events: %w", err)
+		}
+		if n >= limit {
+			return &QuotaError{AppName: appName, UserID: userID, SessionID: sessionID, Quota: QuotaUserEvents, Limit: limit}
+		}
+	}
+
+	return nil
+}
+
+// countUserEvents returns the number of events of the sessions of a user. The
+// events of expired or deleted sessions, whose lists are gone, are not counted.
+func (s *RedisSessionService) countUserEvents(ctx context.Context, appName, userID string) (int64, error) {
+	ids, err := s.indexSessionIDs(ctx, appName, userID)
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+
+	pipe := s.rdb.Pipeline()
+	cmds := make([]*redis.IntCmd, 0, len(ids))
+	for _, id := range ids {
+		cmds = append(cmds, pipe.LLen(ctx, buildEventsKey(appName, userID, id)))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, cmd := range cmds {
+		total += cmd.Val()
+	}
+	return total, nil
+}
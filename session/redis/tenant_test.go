@@ -0,0 +1,164 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/adk/session"
+)
+
+func TestTenantRegistry(t *testing.T) {
+	tests := []struct {
+		name    string
+		appName string
+		tenant  Tenant
+		wantErr bool
+	}{
+		{name: "valid", appName: "app", tenant: Tenant{MaxSessionsPerUser: 3, TTL: time.Hour}},
+		{name: "empty app name", appName: "", wantErr: true},
+		{name: "app name with separator", appName: "app:1", wantErr: true},
+		{name: "negative quota", appName: "app", tenant: Tenant{MaxEventsPerSession: -1}, wantErr: true},
+		{name: "negative user events quota", appName: "app", tenant: Tenant{MaxEventsPerUser: -1}, wantErr: true},
+		{name: "TTL below 1s", appName: "app", tenant: Tenant{TTL: time.Millisecond}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewTenantRegistry()
+			err := r.Register(tt.appName, tt.tenant)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Register() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if _, ok := r.Tenant(tt.appName); ok == tt.wantErr {
+				t.Errorf("Tenant() ok = %v, want %v", ok, !tt.wantErr)
+			}
+		})
+	}
+
+	r := NewTenantRegistry()
+	if err := r.Register("app", Tenant{TTL: 1500 * time.Millisecond}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if got, _ := r.Tenant("app"); got.TTL != time.Second {
+		t.Errorf("TTL = %s, want 1s", got.TTL)
+	}
+
+	r.Remove("app")
+	if _, ok := r.Tenant("app"); ok {
+		t.Error("Tenant() ok = true after Remove")
+	}
+}
+
+func TestTenantQuotas(t *testing.T) {
+	const appName, otherApp, userID = "tenant-app", "tenant-other-app", "tenant-user"
+
+	tenants := NewTenantRegistry()
+	if err := tenants.Register(appName, Tenant{
+		MaxSessionsPerUser:  2,
+		MaxEventsPerSession: 2,
+		TTL:                 time.Hour,
+	}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	svc, rdb := setupTestRedis(t, WithTTL(24*time.Hour), WithTenants(tenants))
+	ctx := context.Background()
+	t.Cleanup(func() {
+		cleanupTestKeys(t, rdb, "*:"+appName+":*")
+		cleanupTestKeys(t, rdb, "*:"+otherApp+":*")
+	})
+
+	create := func(appName string) (session.Session, error) {
+		t.Helper()
+		resp, err := svc.Create(ctx, &session.CreateRequest{AppName: appName, UserID: userID})
+		if err != nil {
+			return nil, err
+		}
+		return resp.Session, nil
+	}
+
+	sess, err := create(appName)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if ttl := rdb.TTL(ctx, buildSessionKey(appName, userID, sess.ID())).Val(); ttl > time.Hour {
+		t.Errorf("session TTL = %s, want the tenant TTL", ttl)
+	}
+
+	t.Run("sessions per user", func(t *testing.T) {
+		if _, err := create(appName); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+
+		_, err := create(appName)
+		var quotaErr *QuotaError
+		if !errors.Is(err, ErrQuotaExceeded) || !errors.As(err, &quotaErr) || quotaErr.Quota != QuotaSessions {
+			t.Fatalf("Create() error = %v, want a sessions quota error", err)
+		}
+
+		// NOTE: Other apps are not limited
+		for range 3 {
+			if _, err := create(otherApp); err != nil {
+				t.Fatalf("Create() error = %v for an unregistered app", err)
+			}
+		}
+	})
+
+	t.Run("events per session", func(t *testing.T) {
+		for range 2 {
+			if err := svc.AppendEvent(ctx, sess, &session.Event{Author: "user"}); err != nil {
+				t.Fatalf("AppendEvent() error = %v", err)
+			}
+		}
+
+		err := svc.AppendEvent(ctx, sess, &session.Event{Author: "user"})
+		var quotaErr *QuotaError
+		if !errors.As(err, &quotaErr) || quotaErr.Quota != QuotaEvents || quotaErr.SessionID != sess.ID() {
+			t.Fatalf("AppendEvent() error = %v, want an events quota error", err)
+		}
+	})
+
+	t.Run("events per user", func(t *testing.T) {
+		const usersApp = "tenant-users-app"
+		if err := tenants.Register(usersApp, Tenant{MaxEventsPerSession: 2, MaxEventsPerUser: 3}); err != nil {
+			t.Fatalf("Register() error = %v", err)
+		}
+		t.Cleanup(func() { cleanupTestKeys(t, rdb, "*:"+usersApp+":*") })
+
+		first, err := create(usersApp)
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		second, err := create(usersApp)
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+
+		// NOTE: Spread over two sessions, each below MaxEventsPerSession
+		for _, s := range []session.Session{first, first, second} {
+			if err := svc.AppendEvent(ctx, s, &session.Event{Author: "user"}); err != nil {
+				t.Fatalf("AppendEvent() error = %v", err)
+			}
+		}
+
+		err = svc.AppendEvent(ctx, second, &session.Event{Author: "user"})
+		var quotaErr *QuotaError
+		if !errors.Is(err, ErrQuotaExceeded) || !errors.As(err, &quotaErr) || quotaErr.Quota != QuotaUserEvents {
+			t.Fatalf("AppendEvent() error = %v, want a user events quota error", err)
+		}
+	})
+
+	t.Run("deleting frees a session", func(t *testing.T) {
+		if err := svc.Delete(ctx, &session.DeleteRequest{
+			AppName: appName, UserID: userID, SessionID: sess.ID(),
+		}); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if _, err := create(appName); err != nil {
+			t.Errorf("Create() error = %v after a delete", err)
+		}
+	})
+}
@@ -0,0 +1,266 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kydenul/k-adk/internal/redisscript"
+	ksess "github.com/kydenul/k-adk/session"
+	"google.golang.org/adk/session"
+)
+
+// trimLockTTL bounds how long a trim flushing events to the persister holds the
+// session trim lock.
+const trimLockTTL = 30 * time.Second
+
+// TrimPolicy bounds the event list of every session, so long conversations do not
+// grow Redis without bound. Once a threshold is crossed, AppendEvent trims the
+// oldest events; the newest event is always kept.
+type TrimPolicy struct {
+	// Optional. MaxEvents is the number of events kept per session. 0 for no limit.
+	MaxEvents int64
+
+	// Optional. MaxEventBytes is the serialized size of the events kept per session,
+	// in bytes. 0 for no limit. The event list is scanned on every append, unless
+	// the counter of an EventSizeTracker shows it is under the limit.
+	MaxEventBytes int64
+
+	// Optional. Persister receives the session and the trimmed events before they
+	// are dropped, so nothing is lost; if it fails, they are kept and the trim is
+	// retried on a later append, persisting again the events flushed before the
+	// failure. Leave it nil if the persister set WithPersister, which already
+	// receives every event, is used.
+	Persister ksess.Persister
+}
+
+// enabled reports whether the policy bounds the event list.
+func (p *TrimPolicy) enabled() bool { return p.MaxEvents > 0 || p.MaxEventBytes > 0 }
+
+// excess returns the number of the oldest events, of the given serialized sizes,
+// beyond the policy.
+func (p *TrimPolicy) excess(sizes []int) int {
+	drop := 0
+	if p.MaxEvents > 0 && int64(len(sizes)) > p.MaxEvents {
+		drop = len(sizes) - int(p.MaxEvents)
+	}
+
+	if p.MaxEventBytes > 0 {
+		var total int64
+		for i := len(sizes) - 1; i >= drop; i-- {
+			total += int64(sizes[i])
+			if total > p.MaxEventBytes {
+				drop = min(i+1, len(sizes)-1)
+				break
+			}
+		}
+	}
+
+	return drop
+}
+
+// WithEventTrimming bounds the event list of every session with p. Trimmed events
+// are gone from Get, List and Sync; Sync keeps counting them in the event sequence.
+// The trimmed count of each session is kept in an extra key,
+// "evtrim:{app}:{user}:{session}".
+func WithEventTrimming(p TrimPolicy) ServiceOption {
+	return func(s *RedisSessionService) {
+		if p.enabled() {
+			s.trim = &p
+		}
+	}
+}
+
+func buildEventTrimKey(appName, userID, sessionID string) string {
+	return fmt.Sprintf("evtrim:%s:%s:%s", appName, userID, sessionID)
+}
+
+func buildTrimLockKey(appName, userID, sessionID string) string {
+	return fmt.Sprintf("evtrim-lock:%s:%s:%s", appName, userID, sessionID)
+}
+
+// planTrimScript returns the number of the oldest events of an event list beyond
+// a trim policy, and the first event. It only touches the event list: the keys of
+// a session are spread over the slots of a cluster.
+//
+// KEYS[1]: events key
+// ARGV[1]: max events, 0 for no limit
+// ARGV[2]: max event bytes, 0 for no limit
+// ARGV[3]: event bytes counted by an EventSizeTracker, -1 if not counted
+//
+// Returns: {number of events to drop, first event or ""}
+var planTrimScript = redisscript.New("session_plan_trim", `
+local len = redis.call('LLEN', KEYS[1])
+local maxEvents = tonumber(ARGV[1])
+local maxBytes = tonumber(ARGV[2])
+local counted = tonumber(ARGV[3])
+
+local drop = 0
+if maxEvents > 0 and len > maxEvents then
+    drop = len - maxEvents
+end
+
+if maxBytes > 0 and (counted < 0 or counted > maxBytes) then
+    local items = redis.call('LRANGE', KEYS[1], drop, -1)
+    local total = 0
+    for i = #items, 1, -1 do
+        total = total + #items[i]
+        if total > maxBytes then
+            drop = drop + math.min(i, #items - 1)
+            break
+        end
+    end
+end
+
+if drop == 0 then
+    return {0, ""}
+end
+return {drop, redis.call('LINDEX', KEYS[1], 0)}
+`)
+
+// dropEventsScript drops the oldest events of an event list, if the list still
+// starts with the expected event.
+//
+// KEYS[1]: events key
+// ARGV[1]: number of events to drop
+// ARGV[2]: expected first event
+//
+// Returns: {number of events dropped, 0 if the list changed; their bytes}
+var dropEventsScript = redisscript.New("session_drop_events", `
+if redis.call('LINDEX', KEYS[1], 0) ~= ARGV[2] then
+    return {0, 0}
+end
+
+local count = tonumber(ARGV[1])
+local bytes = 0
+for _, item in ipairs(redis.call('LRANGE', KEYS[1], 0, count - 1)) do
+    bytes = bytes + #item
+end
+redis.call('LTRIM', KEYS[1], count, -1)
+
+return {count, bytes}
+`)
+
+// decrExistingScript decrements a counter, if it exists.
+//
+// KEYS[1]: counter key
+// ARGV[1]: decrement
+var decrExistingScript = redisscript.New("session_decr_existing", `
+if redis.call('EXISTS', KEYS[1]) == 1 then
+    return redis.call('DECRBY', KEYS[1], ARGV[1])
+end
+return 0
+`)
+
+// trimEvents trims the oldest events of a session beyond the trim policy,
+// flushing them to the policy persister first. The trimmed count expires with the
+// session, in ttl. Failures are logged: the events are kept until a later append
+// trims them.
+func (s *RedisSessionService) trimEvents(ctx context.Context, sess session.Session, length int64, ttl time.Duration) {
+	if s.trim.MaxEventBytes <= 0 && length <= s.trim.MaxEvents {
+		return
+	}
+
+	appName, userID, sessionID := sess.AppName(), sess.UserID(), sess.ID()
+	evKey := buildEventsKey(appName, userID, sessionID)
+	bytesKey := buildEventBytesKey(appName, userID, sessionID)
+
+	counted := int64(-1)
+	if s.trim.MaxEventBytes > 0 {
+		if n, err := s.rdb.Get(ctx, bytesKey).Int64(); err == nil {
+			counted = n
+		}
+	}
+
+	plan, err := planTrimScript.Run(ctx, s.rdb, []string{evKey},
+		s.trim.MaxEvents, s.trim.MaxEventBytes, counted).Slice()
+	if err != nil {
+		s.logger.Warnf("failed to plan trim of session %s: %v", sessionID, err)
+		return
+	}
+	drop, _ := plan[0].(int64)
+	first, _ := plan[1].(string)
+	if drop <= 0 {
+		return
+	}
+
+	if s.trim.Persister != nil {
+		// NOTE: Flush under a lock, so concurrent appends do not flush the same events
+		lockKey := buildTrimLockKey(appName, userID, sessionID)
+		ok, err := s.rdb.SetNX(ctx, lockKey, 1, trimLockTTL).Result()
+		if err != nil || !ok {
+			s.logger.Debugf("trim of session %s skipped, locked: %v", sessionID, err)
+			return
+		}
+		defer s.rdb.Del(context.WithoutCancel(ctx), lockKey)
+
+		if err := s.flushEvents(ctx, sess, evKey, drop, first); err != nil {
+			s.logger.Warnf("failed to flush trimmed events of session %s, keeping them: %v", sessionID, err)
+			return
+		}
+	}
+
+	result, err := dropEventsScript.Run(ctx, s.rdb, []string{evKey}, drop, first).Slice()
+	if err != nil {
+		s.logger.Warnf("failed to trim events of session %s: %v", sessionID, err)
+		return
+	}
+	dropped, _ := result[0].(int64)
+	bytes, _ := result[1].(int64)
+	if dropped == 0 {
+		s.logger.Debugf("trim of session %s skipped, trimmed concurrently", sessionID)
+		return
+	}
+
+	// NOTE: The counters are updated after the trim, in their own slots: until then,
+	// Sync may count fewer events and reset the clients ahead, never skip events
+	trimKey := buildEventTrimKey(appName, userID, sessionID)
+	pipe := s.rdb.Pipeline()
+	pipe.IncrBy(ctx, trimKey, dropped)
+	if ttl > 0 {
+		pipe.Expire(ctx, trimKey, ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		s.logger.Errorf("failed to count trimmed events of session %s: %v", sessionID, err)
+	}
+	if err := decrExistingScript.Run(ctx, s.rdb, []string{bytesKey}, bytes).Err(); err != nil {
+		s.logger.Warnf("failed to uncount trimmed event bytes of session %s: %v", sessionID, err)
+	}
+
+	s.logger.Infof("session events trimmed: session=%s, dropped=%d", sessionID, dropped)
+}
+
+// flushEvents persists the session and its oldest count events with the trim
+// policy persister. The first event must be first.
+func (s *RedisSessionService) flushEvents(
+	ctx context.Context,
+	sess session.Session,
+	evKey string,
+	count int64,
+	first string,
+) error {
+	eventData, err := s.rdb.LRange(ctx, evKey, 0, count-1).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get events: %w", err)
+	}
+	if len(eventData) == 0 || eventData[0] != first {
+		return errors.New("events trimmed concurrently")
+	}
+
+	if err := s.trim.Persister.PersistSession(ctx, sess); err != nil {
+		return fmt.Errorf("failed to persist session: %w", err)
+	}
+
+	for _, ed := range eventData {
+		var evt session.Event
+		if err := s.codec.forApp(sess.AppName()).unmarshal(ctx, ed, &evt); err != nil {
+			return fmt.Errorf("failed to unmarshal event: %w", err)
+		}
+		if err := s.trim.Persister.PersistEvent(ctx, sess, &evt); err != nil {
+			return fmt.Errorf("failed to persist event %s: %w", evt.ID, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,198 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/adk/session"
+	"google.golang.org/genai"
+)
+
+func TestTrimPolicyExcess(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy TrimPolicy
+		sizes  []int
+		want   int
+	}{
+		{"under limits", TrimPolicy{MaxEvents: 3, MaxEventBytes: 100}, []int{10, 10, 10}, 0},
+		{"max events", TrimPolicy{MaxEvents: 2}, []int{10, 10, 10, 10}, 2},
+		{"max bytes", TrimPolicy{MaxEventBytes: 25}, []int{10, 10, 10, 10}, 2},
+		{"both", TrimPolicy{MaxEvents: 3, MaxEventBytes: 15}, []int{10, 10, 10, 10}, 3},
+		{"newest kept", TrimPolicy{MaxEventBytes: 5}, []int{10, 10}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.excess(tt.sizes); got != tt.want {
+				t.Errorf("excess() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// flushRecorder is a persister recording the flushed events.
+type flushRecorder struct {
+	events []string
+	fail   bool
+}
+
+func (f *flushRecorder) PersistSession(context.Context, session.Session) error { return nil }
+
+func (f *flushRecorder) PersistEvent(_ context.Context, _ session.Session, evt *session.Event) error {
+	if f.fail {
+		return errors.New("store down")
+	}
+	f.events = append(f.events, evt.ID)
+	return nil
+}
+
+func (f *flushRecorder) DeleteSession(context.Context, string, string, string) error { return nil }
+
+func (f *flushRecorder) Close() error { return nil }
+
+func TestEventTrimming(t *testing.T) {
+	const (
+		appName = "test_trim_app"
+		userID  = "test_trim_user"
+	)
+
+	flushed := &flushRecorder{}
+	svc, rdb := setupTestRedis(t, WithTTL(30*time.Second),
+		WithEventTrimming(TrimPolicy{MaxEvents: 3, Persister: flushed}))
+	t.Cleanup(func() {
+		cleanupTestKeys(t, rdb,
+			fmt.Sprintf("session:%s:*", appName),
+			fmt.Sprintf("events:%s:*", appName),
+			fmt.Sprintf("statelog:%s:*", appName),
+			fmt.Sprintf("evtrim:%s:*", appName),
+		)
+	})
+
+	ctx := context.Background()
+	resp, err := svc.Create(ctx, &session.CreateRequest{AppName: appName, UserID: userID, SessionID: "trim"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	for i := range 5 {
+		if err := svc.AppendEvent(ctx, resp.Session, &session.Event{ID: fmt.Sprintf("e%d", i), Author: "user"}); err != nil {
+			t.Fatalf("AppendEvent %d failed: %v", i, err)
+		}
+	}
+
+	evKey := buildEventsKey(appName, userID, "trim")
+	if n := rdb.LLen(ctx, evKey).Val(); n != 3 {
+		t.Errorf("events kept = %d, want 3", n)
+	}
+	if strings.Join(flushed.events, ",") != "e0,e1" {
+		t.Errorf("flushed events = %v, want e0,e1", flushed.events)
+	}
+
+	t.Run("sync counts trimmed events", func(t *testing.T) {
+		got, err := svc.Sync(ctx, &SyncRequest{AppName: appName, UserID: userID, SessionID: "trim", SinceEvent: 3})
+		if err != nil {
+			t.Fatalf("Sync failed: %v", err)
+		}
+		if got.EventSequence != 5 || len(got.Events) != 2 || got.Events[0].ID != "e3" || got.EventsReset {
+			t.Errorf("unexpected sync: sequence=%d, events=%d, reset=%v", got.EventSequence, len(got.Events), got.EventsReset)
+		}
+
+		got, _ = svc.Sync(ctx, &SyncRequest{AppName: appName, UserID: userID, SessionID: "trim", SinceEvent: 1})
+		if !got.EventsReset || len(got.Events) != 3 {
+			t.Errorf("expected a reset with the kept events, got reset=%v, events=%d", got.EventsReset, len(got.Events))
+		}
+	})
+
+	t.Run("failed flush keeps events", func(t *testing.T) {
+		flushed.fail = true
+		if err := svc.AppendEvent(ctx, resp.Session, &session.Event{ID: "e5", Author: "user"}); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+		if n := rdb.LLen(ctx, evKey).Val(); n != 4 {
+			t.Errorf("events kept = %d, want 4", n)
+		}
+	})
+}
+
+func TestEventTrimmingBytes(t *testing.T) {
+	const (
+		appName = "test_trim_bytes_app"
+		userID  = "test_trim_bytes_user"
+	)
+
+	_, rdb := setupTestRedis(t)
+	t.Cleanup(func() {
+		cleanupTestKeys(t, rdb,
+			fmt.Sprintf("session:%s:*", appName),
+			fmt.Sprintf("events:%s:*", appName),
+			fmt.Sprintf("statelog:%s:*", appName),
+			fmt.Sprintf("evtrim:%s:*", appName),
+		)
+	})
+
+	// NOTE: The limit keeps two events, whose stored size is well over their text
+	size := storedEventSize(t, rdb, appName, userID, 2000)
+	svc, err := NewRedisSessionService(rdb, WithTTL(30*time.Second),
+		WithEventTrimming(TrimPolicy{MaxEventBytes: 2*size + size/2}))
+	if err != nil {
+		t.Fatalf("NewRedisSessionService failed: %v", err)
+	}
+
+	ctx := context.Background()
+	resp, err := svc.Create(ctx, &session.CreateRequest{AppName: appName, UserID: userID, SessionID: "trim"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	for i := range 4 {
+		if err := svc.AppendEvent(ctx, resp.Session, textEvent(2000)); err != nil {
+			t.Fatalf("AppendEvent %d failed: %v", i, err)
+		}
+	}
+
+	if n := rdb.LLen(ctx, buildEventsKey(appName, userID, "trim")).Val(); n != 2 {
+		t.Errorf("events kept = %d, want 2", n)
+	}
+	if n, _ := rdb.Get(ctx, buildEventTrimKey(appName, userID, "trim")).Int(); n != 2 {
+		t.Errorf("trimmed count = %d, want 2", n)
+	}
+}
+
+// textEvent returns an event of the user with n bytes of text.
+func textEvent(n int) *session.Event {
+	evt := &session.Event{Author: "user"}
+	evt.Content = genai.NewContentFromText(strings.Repeat("x", n), genai.RoleUser)
+	return evt
+}
+
+// storedEventSize returns the size of textEvent(n) once stored in Redis, by
+// appending one to a session of its own.
+func storedEventSize(t *testing.T, rdb redis.UniversalClient, appName, userID string, n int) int64 {
+	t.Helper()
+
+	svc, err := NewRedisSessionService(rdb, WithTTL(30*time.Second))
+	if err != nil {
+		t.Fatalf("NewRedisSessionService failed: %v", err)
+	}
+
+	ctx := context.Background()
+	resp, err := svc.Create(ctx, &session.CreateRequest{AppName: appName, UserID: userID, SessionID: "measure"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := svc.AppendEvent(ctx, resp.Session, textEvent(n)); err != nil {
+		t.Fatalf("AppendEvent failed: %v", err)
+	}
+
+	item, err := rdb.LIndex(ctx, buildEventsKey(appName, userID, "measure"), 0).Result()
+	if err != nil {
+		t.Fatalf("LIndex failed: %v", err)
+	}
+	return int64(len(item))
+}
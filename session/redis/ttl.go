@@ -0,0 +1,77 @@
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+// SessionTTLStateKey is the key of the initial state of a CreateRequest setting the
+// TTL of the session, instead of the service TTL: a duration string ("720h") or a
+// number of seconds. It is removed from the state of the session; the "temp:"
+// prefix keeps it out of the persisters that drop temporary keys anyway.
+const SessionTTLStateKey = "temp:session_ttl"
+
+// ErrInvalidTTL is returned by Create for a session TTL that is not a positive
+// duration, or is above the maximum set with WithMaxSessionTTL.
+var ErrInvalidTTL = errors.New("invalid session TTL")
+
+// WithMaxSessionTTL caps the TTLs requested with SessionTTLStateKey, e.g. when the
+// initial state comes from API clients. Default: no limit.
+func WithMaxSessionTTL(maxTTL time.Duration) ServiceOption {
+	return func(s *RedisSessionService) { s.maxTTL = maxTTL }
+}
+
+// takeSessionTTL removes SessionTTLStateKey from state and returns its TTL, 0 if
+// it is not set.
+func (s *RedisSessionService) takeSessionTTL(state map[string]any) (time.Duration, error) {
+	v, ok := state[SessionTTLStateKey]
+	if !ok {
+		return 0, nil
+	}
+	delete(state, SessionTTLStateKey)
+
+	var ttl time.Duration
+	if text, ok := v.(string); ok {
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidTTL, text)
+		}
+		ttl = d
+	} else {
+		seconds, err := cast.ToFloat64E(v)
+		if err != nil || seconds > math.MaxInt64/float64(time.Second) {
+			return 0, fmt.Errorf("%w: %v", ErrInvalidTTL, v)
+		}
+		ttl = time.Duration(seconds * float64(time.Second))
+	}
+
+	// NOTE: Redis expires keys by the second
+	if ttl < time.Second {
+		return 0, fmt.Errorf("%w: %s is below 1s", ErrInvalidTTL, ttl)
+	}
+	if s.maxTTL > 0 && ttl > s.maxTTL {
+		return 0, fmt.Errorf("%w: %s is above %s", ErrInvalidTTL, ttl, s.maxTTL)
+	}
+
+	return ttl.Truncate(time.Second), nil
+}
+
+// sessionTTL returns the TTL of a session stored with ttlSeconds, the service TTL
+// if it has none.
+func (s *RedisSessionService) sessionTTL(ttlSeconds int64) time.Duration {
+	if ttlSeconds <= 0 {
+		return s.ttl
+	}
+	return time.Duration(ttlSeconds) * time.Second
+}
+
+// indexTTL returns the TTL of the index of a user refreshed by a session expiring
+// in ttl. The index is shared by the sessions of the user, so it is not shortened
+// below the service TTL.
+func (s *RedisSessionService) indexTTL(ttl time.Duration) time.Duration {
+	return max(ttl, s.ttl)
+}
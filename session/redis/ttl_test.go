@@ -0,0 +1,131 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/adk/session"
+)
+
+func TestTakeSessionTTL(t *testing.T) {
+	s := &RedisSessionService{ttl: time.Hour, maxTTL: 30 * 24 * time.Hour}
+
+	tests := []struct {
+		name    string
+		value   any
+		want    time.Duration
+		wantErr bool
+	}{
+		{"duration", "72h", 72 * time.Hour, false},
+		{"seconds", 90, 90 * time.Second, false},
+		{"JSON number", float64(3600), time.Hour, false},
+		{"fraction of a second", "1500ms", time.Second, false},
+		{"invalid", "soon", 0, true},
+		{"negative", -5, 0, true},
+		{"below a second", "10ms", 0, true},
+		{"above the maximum", "800h", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := map[string]any{SessionTTLStateKey: tt.value, "step": 1}
+			got, err := s.takeSessionTTL(state)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidTTL) {
+					t.Errorf("expected ErrInvalidTTL, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("takeSessionTTL failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+			if _, ok := state[SessionTTLStateKey]; ok || len(state) != 1 {
+				t.Errorf("expected the TTL key to be removed from the state, got %v", state)
+			}
+		})
+	}
+
+	if got, err := s.takeSessionTTL(map[string]any{"step": 1}); got != 0 || err != nil {
+		t.Errorf("expected no TTL, got %s, %v", got, err)
+	}
+}
+
+func TestSessionTTLOverride(t *testing.T) {
+	svc, rdb := setupTestRedis(t, WithTTL(time.Hour))
+	defer cleanupTestKeys(t, rdb, "session:ttl_app:*", "events:ttl_app:*", "statelog:ttl_app:*")
+
+	ctx := context.Background()
+
+	state := map[string]any{SessionTTLStateKey: "72h", "step": 1}
+	resp, err := svc.Create(ctx, &session.CreateRequest{AppName: "ttl_app", UserID: "u1", State: state})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, ok := state[SessionTTLStateKey]; !ok {
+		t.Error("expected the request state to be left alone")
+	}
+	if _, err := resp.Session.State().Get(SessionTTLStateKey); err == nil {
+		t.Error("expected the TTL key not to be in the session state")
+	}
+
+	key := buildSessionKey("ttl_app", "u1", resp.Session.ID())
+	assertTTL := func(t *testing.T, key string, want time.Duration) {
+		t.Helper()
+		got, err := rdb.TTL(ctx, key).Result()
+		if err != nil {
+			t.Fatalf("TTL failed: %v", err)
+		}
+		if got > want || got < want-time.Minute {
+			t.Errorf("expected %s to expire in %s, got %s", key, want, got)
+		}
+	}
+	assertTTL(t, key, 72*time.Hour)
+
+	t.Run("refreshes keep the session TTL", func(t *testing.T) {
+		got, err := svc.Get(ctx, &session.GetRequest{AppName: "ttl_app", UserID: "u1", SessionID: resp.Session.ID()})
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if err := got.Session.State().Set("step", 2); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+		if err := svc.AppendEvent(ctx, got.Session, &session.Event{ID: "e1", Author: "user"}); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+
+		assertTTL(t, key, 72*time.Hour)
+		assertTTL(t, buildEventsKey("ttl_app", "u1", resp.Session.ID()), 72*time.Hour)
+	})
+
+	t.Run("branches inherit the TTL", func(t *testing.T) {
+		branch, err := svc.CreateBranch(ctx, &CreateBranchRequest{
+			AppName: "ttl_app", UserID: "u1", SessionID: resp.Session.ID(),
+		})
+		if err != nil {
+			t.Fatalf("CreateBranch failed: %v", err)
+		}
+		assertTTL(t, buildSessionKey("ttl_app", "u1", branch.Session.ID()), 72*time.Hour)
+	})
+
+	t.Run("other sessions use the service TTL", func(t *testing.T) {
+		other, err := svc.Create(ctx, &session.CreateRequest{AppName: "ttl_app", UserID: "u1"})
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		assertTTL(t, buildSessionKey("ttl_app", "u1", other.Session.ID()), time.Hour)
+	})
+
+	t.Run("invalid TTL", func(t *testing.T) {
+		_, err := svc.Create(ctx, &session.CreateRequest{
+			AppName: "ttl_app", UserID: "u1", State: map[string]any{SessionTTLStateKey: "forever"},
+		})
+		if !errors.Is(err, ErrInvalidTTL) {
+			t.Errorf("expected ErrInvalidTTL, got %v", err)
+		}
+	})
+}
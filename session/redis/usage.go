@@ -0,0 +1,409 @@
+package redis
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/log"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultUsageInterval    = 10 * time.Minute
+	defaultUsageScanCount   = 500
+	defaultUsageKeysPerSec  = 2000
+	defaultUsageSampleRatio = 1.0
+)
+
+// usagePrefixes are the key prefixes of the session data, all followed by
+// "{appName}:{userID}[:{sessionID}]".
+var usagePrefixes = []string{"session", "events", "statelog", "evbytes", "evtrim", "evrate", "stateidx-entry"}
+
+// UsageConfig configures a UsageInspector.
+type UsageConfig struct {
+	// Optional. Interval between two inspections when started. Default: 10m.
+	Interval time.Duration
+
+	// Optional. ScanCount is the COUNT hint of every SCAN call. Default: 500.
+	ScanCount int64
+
+	// Optional. KeysPerSecond caps the keys inspected per second on each server,
+	// to keep the inspection light on a busy server. Default: 2000.
+	KeysPerSecond int
+
+	// Optional. SampleRatio is the fraction of the keys whose MEMORY USAGE is
+	// measured, in (0, 1]. Keys are picked by hash, and the bytes of the picked
+	// keys are scaled up to estimate the total. Default: 1 (every key).
+	SampleRatio float64
+
+	// Optional. Samples is the SAMPLES option of MEMORY USAGE: the number of
+	// elements sampled in aggregate values such as event lists. 0 uses the Redis
+	// default (5).
+	Samples int
+
+	// Optional. OnReport is called with every report, e.g. to export it as metrics.
+	OnReport func(*UsageReport)
+
+	// Optional. Logger for logging. Falls back to `DiscardLog` if nil.
+	Logger log.Logger
+}
+
+// Usage is the memory used by a set of keys.
+type Usage struct {
+	Keys  int64 `json:"keys"`
+	Bytes int64 `json:"bytes"`
+}
+
+func (u *Usage) add(o Usage) {
+	u.Keys += o.Keys
+	u.Bytes += o.Bytes
+}
+
+// AppUsage is the memory used by the keys of an app and of each of its users.
+type AppUsage struct {
+	Usage
+
+	Users map[string]Usage `json:"users"`
+}
+
+// UserUsage is the memory used by the keys of a user of an app.
+type UserUsage struct {
+	Usage
+
+	AppName string `json:"app_name"`
+	UserID  string `json:"user_id"`
+}
+
+// UsageReport is the result of an inspection. With a SampleRatio below 1, key
+// counts and bytes are estimates.
+type UsageReport struct {
+	Apps map[string]*AppUsage `json:"apps"`
+
+	// Total is the memory used by every inspected key.
+	Total Usage `json:"total"`
+
+	// Scanned is the number of keys scanned, Measured the number of keys whose
+	// MEMORY USAGE was read.
+	Scanned  int64 `json:"scanned"`
+	Measured int64 `json:"measured"`
+
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// TopUsers returns the n users using the most memory, across apps.
+func (r *UsageReport) TopUsers(n int) []UserUsage {
+	var users []UserUsage
+	for appName, app := range r.Apps {
+		for userID, u := range app.Users {
+			users = append(users, UserUsage{Usage: u, AppName: appName, UserID: userID})
+		}
+	}
+
+	slices.SortFunc(users, func(a, b UserUsage) int {
+		if c := cmp.Compare(b.Bytes, a.Bytes); c != 0 {
+			return c
+		}
+		return cmp.Or(strings.Compare(a.AppName, b.AppName), strings.Compare(a.UserID, b.UserID))
+	})
+
+	return users[:min(n, len(users))]
+}
+
+// UsageInspector reports the Redis memory used by the sessions of every app and
+// user, so operators can see which tenant is filling Redis.
+//
+// It SCANs the session, events and state log keys at a limited rate and reads
+// their MEMORY USAGE, so an inspection of a large keyspace takes a while but adds
+// little load. In cluster mode, every master is scanned.
+type UsageInspector struct {
+	logger log.Logger
+
+	rdb redis.UniversalClient
+	cfg UsageConfig
+
+	mu   sync.RWMutex
+	last *UsageReport
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewUsageInspector creates a UsageInspector. Call Inspect for a single report, or
+// Start to inspect periodically and read the last report with Stats.
+func NewUsageInspector(rdb redis.UniversalClient, cfg UsageConfig) (*UsageInspector, error) {
+	if rdb == nil {
+		return nil, ErrNilRedisClient
+	}
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return nil, errors.New("sample ratio must be in (0, 1]")
+	}
+
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultUsageInterval
+	}
+	if cfg.ScanCount <= 0 {
+		cfg.ScanCount = defaultUsageScanCount
+	}
+	if cfg.KeysPerSecond <= 0 {
+		cfg.KeysPerSecond = defaultUsageKeysPerSec
+	}
+	if cfg.SampleRatio == 0 {
+		cfg.SampleRatio = defaultUsageSampleRatio
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = &discardlog.DiscardLog{}
+	}
+
+	return &UsageInspector{logger: cfg.Logger, rdb: rdb, cfg: cfg}, nil
+}
+
+// Start launches the periodic inspection. It stops when ctx is cancelled or Stop
+// is called.
+func (u *UsageInspector) Start(ctx context.Context) {
+	ctx, u.cancel = context.WithCancel(ctx)
+
+	u.wg.Go(func() {
+		ticker := time.NewTicker(u.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := u.Inspect(ctx); err != nil && ctx.Err() == nil {
+				u.logger.Errorf("redis usage inspection failed: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+
+	u.logger.Infof("redis usage inspector started: interval=%s", u.cfg.Interval)
+}
+
+// Stop stops the periodic inspection and waits for the one in progress.
+func (u *UsageInspector) Stop() {
+	if u.cancel != nil {
+		u.cancel()
+	}
+	u.wg.Wait()
+
+	u.logger.Info("redis usage inspector stopped")
+}
+
+// Stats returns the last report, or nil before the first inspection completes.
+func (u *UsageInspector) Stats() *UsageReport {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	return u.last
+}
+
+// Inspect scans the session keys and returns their memory usage per app and user.
+func (u *UsageInspector) Inspect(ctx context.Context) (*UsageReport, error) {
+	report := &UsageReport{
+		Apps:      make(map[string]*AppUsage),
+		StartedAt: time.Now(),
+	}
+
+	var mu sync.Mutex
+	scan := func(ctx context.Context, client redis.UniversalClient) error {
+		return u.scan(ctx, client, report, &mu)
+	}
+
+	rdb := u.rdb
+	if wrapped, ok := rdb.(*RedisClient); ok {
+		rdb = wrapped.Client()
+	}
+
+	var err error
+	if cluster, ok := rdb.(*redis.ClusterClient); ok {
+		err = cluster.ForEachMaster(ctx, func(ctx context.Context, master *redis.Client) error {
+			return scan(ctx, master)
+		})
+	} else {
+		err = scan(ctx, rdb)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	report.scale(u.cfg.SampleRatio)
+	report.Duration = time.Since(report.StartedAt)
+
+	u.mu.Lock()
+	u.last = report
+	u.mu.Unlock()
+
+	u.logger.Infof("redis usage inspected: apps=%d, keys=%d, bytes=%d, scanned=%d, measured=%d, duration=%s",
+		len(report.Apps), report.Total.Keys, report.Total.Bytes, report.Scanned, report.Measured, report.Duration)
+
+	if u.cfg.OnReport != nil {
+		u.cfg.OnReport(report)
+	}
+
+	return report, nil
+}
+
+// scan inspects the session keys of one server into report.
+func (u *UsageInspector) scan(
+	ctx context.Context,
+	client redis.UniversalClient,
+	report *UsageReport,
+	mu *sync.Mutex,
+) error {
+	for _, prefix := range usagePrefixes {
+		var cursor uint64
+		for {
+			keys, next, err := client.Scan(ctx, cursor, prefix+":*", u.cfg.ScanCount).Result()
+			if err != nil {
+				return fmt.Errorf("failed to scan %s keys: %w", prefix, err)
+			}
+
+			measured, err := u.measure(ctx, client, keys)
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			report.Scanned += int64(len(keys))
+			for key, bytes := range measured {
+				report.Measured++
+				report.add(key, bytes)
+			}
+			mu.Unlock()
+
+			if next == 0 {
+				break
+			}
+			cursor = next
+
+			// Rate limit: spread the keys of the next batches over time
+			wait := time.Duration(len(keys)) * time.Second / time.Duration(u.cfg.KeysPerSecond)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+	}
+
+	return nil
+}
+
+// measure returns the MEMORY USAGE of the sampled keys. Keys expired since the
+// scan are skipped.
+func (u *UsageInspector) measure(
+	ctx context.Context,
+	client redis.UniversalClient,
+	keys []string,
+) (map[string]int64, error) {
+	pipe := client.Pipeline()
+	cmds := make(map[string]*redis.IntCmd, len(keys))
+	for _, key := range keys {
+		if !u.sampled(key) {
+			continue
+		}
+		if u.cfg.Samples > 0 {
+			cmds[key] = pipe.MemoryUsage(ctx, key, u.cfg.Samples)
+		} else {
+			cmds[key] = pipe.MemoryUsage(ctx, key)
+		}
+	}
+	if len(cmds) == 0 {
+		return nil, nil
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("failed to read memory usage: %w", err)
+	}
+
+	measured := make(map[string]int64, len(cmds))
+	for key, cmd := range cmds {
+		if bytes, err := cmd.Result(); err == nil {
+			measured[key] = bytes
+		}
+	}
+
+	return measured, nil
+}
+
+// sampled reports whether the key is part of the sample. Picking by hash keeps
+// the sample stable between inspections.
+func (u *UsageInspector) sampled(key string) bool {
+	if u.cfg.SampleRatio >= 1 {
+		return true
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return float64(h.Sum32())/float64(1<<32) < u.cfg.SampleRatio
+}
+
+// add accounts a measured key to its app and user. Keys that do not belong to a
+// session are ignored.
+func (r *UsageReport) add(key string, bytes int64) {
+	appName, userID, ok := parseUsageKey(key)
+	if !ok {
+		return
+	}
+
+	usage := Usage{Keys: 1, Bytes: bytes}
+
+	app, ok := r.Apps[appName]
+	if !ok {
+		app = &AppUsage{Users: make(map[string]Usage)}
+		r.Apps[appName] = app
+	}
+
+	user := app.Users[userID]
+	user.add(usage)
+	app.Users[userID] = user
+	app.add(usage)
+	r.Total.add(usage)
+}
+
+// scale extrapolates the usage of the sampled keys to every key.
+func (r *UsageReport) scale(ratio float64) {
+	if ratio >= 1 {
+		return
+	}
+
+	scaled := func(u Usage) Usage {
+		return Usage{Keys: int64(float64(u.Keys) / ratio), Bytes: int64(float64(u.Bytes) / ratio)}
+	}
+
+	for _, app := range r.Apps {
+		for userID, u := range app.Users {
+			app.Users[userID] = scaled(u)
+		}
+		app.Usage = scaled(app.Usage)
+	}
+	r.Total = scaled(r.Total)
+}
+
+// parseUsageKey returns the app and user of a session data key (see usagePrefixes)
+// "{prefix}:{appName}:{userID}" or "{prefix}:{appName}:{userID}:{sessionID}".
+func parseUsageKey(key string) (appName, userID string, ok bool) {
+	prefix, rest, found := strings.Cut(key, ":")
+	if !found || !slices.Contains(usagePrefixes, prefix) {
+		return "", "", false
+	}
+
+	parts := strings.SplitN(rest, ":", 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+
+	return parts[0], parts[1], true
+}
@@ -0,0 +1,117 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/adk/session"
+)
+
+func TestParseUsageKey(t *testing.T) {
+	tests := []struct {
+		key, appName, userID string
+		ok                   bool
+	}{
+		{"session:app:user:sess", "app", "user", true},
+		{"events:app:user:sess", "app", "user", true},
+		{"session:app:user", "app", "user", true},
+		{"session:app", "", "", false},
+		{"session::user:sess", "", "", false},
+		{"session", "", "", false},
+	}
+
+	for _, tt := range tests {
+		appName, userID, ok := parseUsageKey(tt.key)
+		if appName != tt.appName || userID != tt.userID || ok != tt.ok {
+			t.Errorf("parseUsageKey(%q) = %q, %q, %v", tt.key, appName, userID, ok)
+		}
+	}
+}
+
+func TestUsageReport(t *testing.T) {
+	report := &UsageReport{Apps: make(map[string]*AppUsage)}
+	report.add("session:app1:u1:s1", 100)
+	report.add("events:app1:u1:s1", 900)
+	report.add("events:app1:u2:s1", 300)
+	report.add("events:app2:u1:s1", 500)
+	report.add("session-index:{app1:0}", 50)
+
+	if report.Total != (Usage{Keys: 4, Bytes: 1800}) {
+		t.Errorf("unexpected total: %+v", report.Total)
+	}
+	if app := report.Apps["app1"]; app.Usage != (Usage{Keys: 3, Bytes: 1300}) || len(app.Users) != 2 {
+		t.Errorf("unexpected app usage: %+v", app)
+	}
+
+	top := report.TopUsers(2)
+	if len(top) != 2 || top[0].AppName != "app1" || top[0].UserID != "u1" || top[0].Bytes != 1000 ||
+		top[1].AppName != "app2" {
+		t.Errorf("unexpected top users: %+v", top)
+	}
+
+	report.scale(0.5)
+	if report.Total != (Usage{Keys: 8, Bytes: 3600}) || report.Apps["app2"].Users["u1"].Bytes != 1000 {
+		t.Errorf("unexpected scaled usage: %+v", report.Total)
+	}
+}
+
+func TestUsageInspector(t *testing.T) {
+	const appName = "test_usage_app"
+
+	svc, rdb := setupTestRedis(t, WithTTL(30*time.Second))
+	t.Cleanup(func() {
+		cleanupTestKeys(t, rdb,
+			fmt.Sprintf("session:%s:*", appName),
+			fmt.Sprintf("events:%s:*", appName),
+			fmt.Sprintf("statelog:%s:*", appName),
+		)
+	})
+
+	ctx := context.Background()
+
+	for _, userID := range []string{"small", "large"} {
+		resp, err := svc.Create(ctx, &session.CreateRequest{AppName: appName, UserID: userID})
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if userID == "large" {
+			evt := &session.Event{ID: "big"}
+			evt.CustomMetadata = map[string]any{"blob": strings.Repeat("x", 64*1024)}
+			if err := svc.AppendEvent(ctx, resp.Session, evt); err != nil {
+				t.Fatalf("AppendEvent failed: %v", err)
+			}
+		}
+	}
+
+	var reported *UsageReport
+	inspector, err := NewUsageInspector(rdb, UsageConfig{
+		ScanCount: 10,
+		OnReport:  func(r *UsageReport) { reported = r },
+	})
+	if err != nil {
+		t.Fatalf("NewUsageInspector failed: %v", err)
+	}
+
+	report, err := inspector.Inspect(ctx)
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	if reported != report || inspector.Stats() != report {
+		t.Error("expected the report to be reported and kept")
+	}
+
+	app := report.Apps[appName]
+	if app == nil {
+		t.Fatalf("expected usage of %s, got %v", appName, report.Apps)
+	}
+	if large, small := app.Users["large"], app.Users["small"]; large.Bytes <= small.Bytes || small.Keys == 0 {
+		t.Errorf("unexpected user usage: large=%+v, small=%+v", large, small)
+	}
+
+	if _, err := NewUsageInspector(rdb, UsageConfig{SampleRatio: 2}); err == nil {
+		t.Error("expected an invalid sample ratio to fail")
+	}
+}
@@ -0,0 +1,142 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/log"
+	"google.golang.org/adk/session"
+)
+
+var _ session.Service = (*Router)(nil)
+
+var (
+	ErrNoSessionService = errors.New("no session service for app")
+	ErrNilService       = errors.New("session service cannot be nil")
+	ErrNilSession       = errors.New("session cannot be nil")
+)
+
+// Router implements session.Service by dispatching every request to the service
+// of its app, so apps served by one process can use different backends (e.g.,
+// Redis + PostgreSQL for production apps, in-memory for a playground app).
+type Router struct {
+	logger   log.Logger
+	routes   map[string]session.Service
+	fallback session.Service
+}
+
+// RouterOption configures the Router.
+type RouterOption func(*Router)
+
+// WithRoute serves the sessions of appName with svc.
+func WithRoute(appName string, svc session.Service) RouterOption {
+	return func(r *Router) { r.routes[appName] = svc }
+}
+
+// WithFallback serves the sessions of apps without a route with svc.
+// Without a fallback, requests for such apps fail with ErrNoSessionService.
+func WithFallback(svc session.Service) RouterOption {
+	return func(r *Router) { r.fallback = svc }
+}
+
+// WithRouterLogger sets the optional logger for the Router.
+func WithRouterLogger(logger log.Logger) RouterOption {
+	return func(r *Router) { r.logger = logger }
+}
+
+// NewRouter creates a Router from its routes and optional fallback.
+// Returns an error if a route or the fallback is a nil service.
+func NewRouter(opts ...RouterOption) (*Router, error) {
+	r := &Router{routes: make(map[string]session.Service)}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.logger == nil {
+		r.logger = discardlog.NewDiscardLog()
+	}
+
+	for appName, svc := range r.routes {
+		if svc == nil {
+			return nil, fmt.Errorf("%w: app %q", ErrNilService, appName)
+		}
+	}
+
+	r.logger.Infof("session router created: apps=%v, fallback=%t", r.Apps(), r.fallback != nil)
+
+	return r, nil
+}
+
+// Apps returns the names of the apps with a route, sorted.
+func (r *Router) Apps() []string {
+	return slices.Sorted(maps.Keys(r.routes))
+}
+
+// Service returns the session service of appName.
+func (r *Router) Service(appName string) (session.Service, error) {
+	if svc, ok := r.routes[appName]; ok {
+		return svc, nil
+	}
+	if r.fallback != nil {
+		return r.fallback, nil
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrNoSessionService, appName)
+}
+
+// Create creates a session in the service of req.AppName.
+func (r *Router) Create(
+	ctx context.Context,
+	req *session.CreateRequest,
+) (*session.CreateResponse, error) {
+	svc, err := r.Service(req.AppName)
+	if err != nil {
+		return nil, err
+	}
+	return svc.Create(ctx, req)
+}
+
+// Get gets a session from the service of req.AppName.
+func (r *Router) Get(ctx context.Context, req *session.GetRequest) (*session.GetResponse, error) {
+	svc, err := r.Service(req.AppName)
+	if err != nil {
+		return nil, err
+	}
+	return svc.Get(ctx, req)
+}
+
+// List lists the sessions of the service of req.AppName.
+func (r *Router) List(ctx context.Context, req *session.ListRequest) (*session.ListResponse, error) {
+	svc, err := r.Service(req.AppName)
+	if err != nil {
+		return nil, err
+	}
+	return svc.List(ctx, req)
+}
+
+// Delete deletes a session from the service of req.AppName.
+func (r *Router) Delete(ctx context.Context, req *session.DeleteRequest) error {
+	svc, err := r.Service(req.AppName)
+	if err != nil {
+		return err
+	}
+	return svc.Delete(ctx, req)
+}
+
+// AppendEvent appends an event to a session of the service of its app.
+func (r *Router) AppendEvent(ctx context.Context, sess session.Session, evt *session.Event) error {
+	if sess == nil {
+		return ErrNilSession
+	}
+
+	svc, err := r.Service(sess.AppName())
+	if err != nil {
+		return err
+	}
+	return svc.AppendEvent(ctx, sess, evt)
+}
@@ -0,0 +1,103 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/adk/session"
+)
+
+func TestRouter(t *testing.T) {
+	ctx := context.Background()
+	prod := session.InMemoryService()
+	playground := session.InMemoryService()
+
+	router, err := NewRouter(
+		WithRoute("prod_app", prod),
+		WithRoute("playground", playground),
+	)
+	if err != nil {
+		t.Fatalf("NewRouter failed: %v", err)
+	}
+
+	if apps := router.Apps(); len(apps) != 2 || apps[0] != "playground" || apps[1] != "prod_app" {
+		t.Errorf("Apps() = %v", apps)
+	}
+
+	t.Run("routes by app", func(t *testing.T) {
+		resp, err := router.Create(ctx, &session.CreateRequest{AppName: "prod_app", UserID: "user1"})
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+
+		if err := router.AppendEvent(ctx, resp.Session, &session.Event{ID: "evt-1", Author: "user"}); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+
+		got, err := prod.Get(ctx, &session.GetRequest{
+			AppName: "prod_app", UserID: "user1", SessionID: resp.Session.ID(),
+		})
+		if err != nil {
+			t.Fatalf("session not stored in prod service: %v", err)
+		}
+		if got.Session.Events().Len() != 1 {
+			t.Errorf("Expected 1 event, got %d", got.Session.Events().Len())
+		}
+
+		list, err := playground.List(ctx, &session.ListRequest{AppName: "prod_app", UserID: "user1"})
+		if err == nil && len(list.Sessions) != 0 {
+			t.Errorf("session leaked into playground service: %d", len(list.Sessions))
+		}
+
+		listed, err := router.List(ctx, &session.ListRequest{AppName: "prod_app", UserID: "user1"})
+		if err != nil || len(listed.Sessions) != 1 {
+			t.Errorf("List() = %v, %v", listed, err)
+		}
+
+		if err := router.Delete(ctx, &session.DeleteRequest{
+			AppName: "prod_app", UserID: "user1", SessionID: resp.Session.ID(),
+		}); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if _, err := router.Get(ctx, &session.GetRequest{
+			AppName: "prod_app", UserID: "user1", SessionID: resp.Session.ID(),
+		}); err == nil {
+			t.Error("Expected error getting deleted session")
+		}
+	})
+
+	t.Run("unknown app", func(t *testing.T) {
+		_, err := router.Create(ctx, &session.CreateRequest{AppName: "other", UserID: "user1"})
+		if !errors.Is(err, ErrNoSessionService) {
+			t.Errorf("Expected ErrNoSessionService, got %v", err)
+		}
+	})
+
+	t.Run("fallback", func(t *testing.T) {
+		fallback := session.InMemoryService()
+		router, err := NewRouter(WithRoute("prod_app", prod), WithFallback(fallback))
+		if err != nil {
+			t.Fatalf("NewRouter failed: %v", err)
+		}
+
+		resp, err := router.Create(ctx, &session.CreateRequest{AppName: "other", UserID: "user1"})
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if _, err := fallback.Get(ctx, &session.GetRequest{
+			AppName: "other", UserID: "user1", SessionID: resp.Session.ID(),
+		}); err != nil {
+			t.Errorf("session not stored in fallback service: %v", err)
+		}
+	})
+
+	t.Run("nil service", func(t *testing.T) {
+		if _, err := NewRouter(WithRoute("prod_app", nil)); !errors.Is(err, ErrNilService) {
+			t.Errorf("Expected ErrNilService, got %v", err)
+		}
+		if err := router.AppendEvent(ctx, nil, &session.Event{}); !errors.Is(err, ErrNilSession) {
+			t.Errorf("Expected ErrNilSession, got %v", err)
+		}
+	})
+}
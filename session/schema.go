@@ -0,0 +1,239 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"google.golang.org/adk/session"
+)
+
+// ErrInvalidState is matched by every StateValidationError.
+var ErrInvalidState = errors.New("invalid session state")
+
+// StateViolation is a state key breaking the schema of the state.
+type StateViolation struct {
+	// Key is the state key, empty for a violation of the state as a whole.
+	Key string
+
+	// Message describes the violation.
+	Message string
+}
+
+// StateValidationError reports a state write refused by a StateSchema, with every
+// key breaking the schema.
+type StateValidationError struct {
+	Violations []StateViolation
+}
+
+func (e *StateValidationError) Error() string {
+	parts := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		if v.Key == "" {
+			parts = append(parts, v.Message)
+			continue
+		}
+		parts = append(parts, v.Key+": "+v.Message)
+	}
+	return fmt.Sprintf("%v: %s", ErrInvalidState, strings.Join(parts, "; "))
+}
+
+// Is makes errors.Is(err, ErrInvalidState) match a StateValidationError.
+func (e *StateValidationError) Is(target error) bool { return target == ErrInvalidState }
+
+// StateSchema validates session states against a JSON Schema of type object, so
+// writes that would break the state contract agents depend on are refused. Each
+// state key is checked against its property schema, or the additionalProperties
+// schema, and the required keys cannot be removed; the keys with the
+// session.KeyPrefixTemp prefix are not checked.
+type StateSchema struct {
+	root       *jsonschema.Resolved
+	properties map[string]*jsonschema.Resolved
+	additional *jsonschema.Resolved
+	closed     bool
+	required   []string
+}
+
+// NewStateSchema resolves schema, which must describe a JSON object.
+func NewStateSchema(schema *jsonschema.Schema) (*StateSchema, error) {
+	if schema == nil {
+		return nil, errors.New("state schema cannot be nil")
+	}
+	if schema.Type != "" && schema.Type != "object" {
+		return nil, fmt.Errorf("state schema must be of type object, got %q", schema.Type)
+	}
+
+	root, err := schema.Resolve(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve state schema: %w", err)
+	}
+
+	s := &StateSchema{
+		root:       root,
+		properties: make(map[string]*jsonschema.Resolved, len(schema.Properties)),
+		required:   slices.Clone(schema.Required),
+	}
+
+	// NOTE: Each property is resolved on its own, with the definitions of the root
+	// its references may point to
+	resolve := func(sub *jsonschema.Schema) (*jsonschema.Resolved, error) {
+		wrapper := &jsonschema.Schema{
+			Schema:      schema.Schema,
+			Defs:        schema.Defs,
+			Definitions: schema.Definitions,
+			AllOf:       []*jsonschema.Schema{sub},
+		}
+		return wrapper.CloneSchemas().Resolve(nil)
+	}
+
+	for key, sub := range schema.Properties {
+		if s.properties[key], err = resolve(sub); err != nil {
+			return nil, fmt.Errorf("failed to resolve state schema of %s: %w", key, err)
+		}
+	}
+
+	if ap := schema.AdditionalProperties; ap != nil {
+		s.closed = reflect.DeepEqual(ap, &jsonschema.Schema{Not: &jsonschema.Schema{}})
+		if s.additional, err = resolve(ap); err != nil {
+			return nil, fmt.Errorf("failed to resolve state schema of additional properties: %w", err)
+		}
+	}
+
+	return s, nil
+}
+
+// ParseStateSchema parses and resolves a JSON Schema document, see NewStateSchema.
+func ParseStateSchema(data []byte) (*StateSchema, error) {
+	var schema jsonschema.Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("failed to parse state schema: %w", err)
+	}
+	return NewStateSchema(&schema)
+}
+
+// Validate returns a *StateValidationError if state breaks the schema, e.g. the
+// initial state of a session.
+func (s *StateSchema) Validate(state map[string]any) error {
+	violations, err := s.check(state)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range s.required {
+		if _, ok := state[key]; !ok {
+			violations = append(violations, StateViolation{Key: key, Message: "is required"})
+		}
+	}
+
+	// NOTE: The keywords not checked key by key, e.g. patternProperties or
+	// minProperties, are checked on the whole state
+	if len(violations) == 0 {
+		instance, err := jsonValue(withoutTemp(state))
+		if err != nil {
+			return err
+		}
+		if err := s.root.Validate(instance); err != nil {
+			violations = append(violations, StateViolation{Message: violationMessage(err)})
+		}
+	}
+
+	return violationsError(violations)
+}
+
+// ValidateDelta returns a *StateValidationError if the keys set or removed by a
+// state change break the schema. The other keys of the state are not checked.
+func (s *StateSchema) ValidateDelta(set map[string]any, removed []string) error {
+	violations, err := s.check(set)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range removed {
+		if slices.Contains(s.required, key) {
+			violations = append(violations, StateViolation{Key: key, Message: "is required and cannot be removed"})
+		}
+	}
+
+	return violationsError(violations)
+}
+
+// check validates the keys of state against their schemas.
+func (s *StateSchema) check(state map[string]any) ([]StateViolation, error) {
+	var violations []StateViolation
+	for _, key := range slices.Sorted(maps.Keys(state)) {
+		if strings.HasPrefix(key, session.KeyPrefixTemp) {
+			continue
+		}
+
+		schema, ok := s.properties[key]
+		if !ok {
+			if s.closed {
+				violations = append(violations, StateViolation{Key: key, Message: "is not allowed"})
+				continue
+			}
+			if schema = s.additional; schema == nil {
+				continue
+			}
+		}
+
+		value, err := jsonValue(state[key])
+		if err != nil {
+			return nil, fmt.Errorf("failed to validate state key %s: %w", key, err)
+		}
+		if err := schema.Validate(value); err != nil {
+			violations = append(violations, StateViolation{Key: key, Message: violationMessage(err)})
+		}
+	}
+
+	return violations, nil
+}
+
+// jsonValue returns v as it is stored, with the JSON types the schema describes.
+func jsonValue(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// violationMessage returns the message of a validation error without the schema
+// paths leading to the failed keyword, e.g. "type: 3 has type ...".
+func violationMessage(err error) string {
+	msg := err.Error()
+	for strings.HasPrefix(msg, "validating ") {
+		i := strings.Index(msg, ": ")
+		if i < 0 {
+			break
+		}
+		msg = msg[i+2:]
+	}
+	return msg
+}
+
+func withoutTemp(state map[string]any) map[string]any {
+	out := make(map[string]any, len(state))
+	for k, v := range state {
+		if !strings.HasPrefix(k, session.KeyPrefixTemp) {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+func violationsError(violations []StateViolation) error {
+	if len(violations) == 0 {
+		return nil
+	}
+	return &StateValidationError{Violations: violations}
+}
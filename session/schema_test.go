@@ -0,0 +1,111 @@
+package session
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+const testStateSchema = `{
+	"type": "object",
+	"properties": {
+		"topic": {"type": "string", "enum": ["billing", "support"]},
+		"turns": {"$ref": "#/$defs/count"}
+	},
+	"required": ["topic"],
+	"additionalProperties": false,
+	"$defs": {"count": {"type": "integer", "minimum": 0}}
+}`
+
+func violatingKeys(t *testing.T, err error) []string {
+	t.Helper()
+
+	if err == nil {
+		return nil
+	}
+	var invalid *StateValidationError
+	if !errors.Is(err, ErrInvalidState) || !errors.As(err, &invalid) {
+		t.Fatalf("error = %v, want a StateValidationError", err)
+	}
+
+	keys := make([]string, 0, len(invalid.Violations))
+	for _, v := range invalid.Violations {
+		if v.Message == "" {
+			t.Errorf("violation of %q has no message", v.Key)
+		}
+		keys = append(keys, v.Key)
+	}
+	return keys
+}
+
+func TestStateSchemaValidate(t *testing.T) {
+	schema, err := ParseStateSchema([]byte(testStateSchema))
+	if err != nil {
+		t.Fatalf("ParseStateSchema() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		state map[string]any
+		want  []string
+	}{
+		{name: "valid", state: map[string]any{"topic": "billing", "turns": 2}},
+		{name: "temp keys are not checked", state: map[string]any{"topic": "support", "temp:x": 1}},
+		{name: "missing required key", state: map[string]any{"turns": 1}, want: []string{"topic"}},
+		{
+			name:  "every violating key",
+			state: map[string]any{"topic": 3, "turns": -1, "extra": true},
+			want:  []string{"extra", "topic", "turns"},
+		},
+		{name: "referenced definition", state: map[string]any{"topic": "billing", "turns": 1.5}, want: []string{"turns"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := violatingKeys(t, schema.Validate(tt.state)); !slices.Equal(got, tt.want) {
+				t.Errorf("Validate() violations = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStateSchemaValidateDelta(t *testing.T) {
+	schema, err := ParseStateSchema([]byte(testStateSchema))
+	if err != nil {
+		t.Fatalf("ParseStateSchema() error = %v", err)
+	}
+
+	if err := schema.ValidateDelta(map[string]any{"turns": 3}, nil); err != nil {
+		t.Errorf("ValidateDelta() error = %v, want nil", err)
+	}
+
+	got := violatingKeys(t, schema.ValidateDelta(map[string]any{"turns": "3"}, []string{"topic"}))
+	if want := []string{"turns", "topic"}; !slices.Equal(got, want) {
+		t.Errorf("ValidateDelta() violations = %v, want %v", got, want)
+	}
+}
+
+func TestNewStateSchema(t *testing.T) {
+	if _, err := NewStateSchema(nil); err == nil {
+		t.Error("NewStateSchema(nil) error = nil")
+	}
+	if _, err := NewStateSchema(&jsonschema.Schema{Type: "array"}); err == nil {
+		t.Error("NewStateSchema() error = nil for an array schema")
+	}
+	if _, err := ParseStateSchema([]byte(`{"properties": {"a": {"$ref": "#/$defs/missing"}}}`)); err == nil {
+		t.Error("ParseStateSchema() error = nil for an unresolved reference")
+	}
+
+	// NOTE: Schemas without additionalProperties accept any other key
+	schema, err := NewStateSchema(&jsonschema.Schema{
+		Properties: map[string]*jsonschema.Schema{"n": {Type: "number"}},
+	})
+	if err != nil {
+		t.Fatalf("NewStateSchema() error = %v", err)
+	}
+	if err := schema.Validate(map[string]any{"n": 1, "other": "x"}); err != nil {
+		t.Errorf("Validate() error = %v, want nil", err)
+	}
+}
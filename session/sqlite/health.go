@@ -0,0 +1,21 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+
+	ksess "github.com/kydenul/k-adk/session"
+)
+
+var _ ksess.HealthChecker = (*SessionService)(nil)
+
+// Ping checks that the database is open and reachable.
+func (s *SessionService) Ping(ctx context.Context) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("sqlite ping failed: %w", err)
+	}
+	return nil
+}
+
+// Healthy checks the database, as Ping does.
+func (s *SessionService) Healthy(ctx context.Context) error { return s.Ping(ctx) }
@@ -0,0 +1,262 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/bytedance/sonic"
+	ksess "github.com/kydenul/k-adk/session"
+	"google.golang.org/adk/session"
+)
+
+// PersistSession saves or updates a session, its state and tags, as a
+// ksess.Persister.
+func (s *SessionService) PersistSession(ctx context.Context, sess session.Session) error {
+	stateJSON, err := sonic.Marshal(maps.Collect(sess.State().All()))
+	if err != nil {
+		return fmt.Errorf("failed to marshal session state: %w", err)
+	}
+	tags := ksess.TagsOf(sess)
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	tagsJSON, err := sonic.Marshal(tags)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session tags: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO sessions (app_name, user_id, id, state, tags, last_update_time, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (app_name, user_id, id) DO UPDATE SET
+			state = excluded.state,
+			tags = excluded.tags,
+			last_update_time = excluded.last_update_time
+	`, sess.AppName(), sess.UserID(), sess.ID(), string(stateJSON), string(tagsJSON),
+		sess.LastUpdateTime().UnixNano(), time.Now().UnixNano())
+	if err != nil {
+		s.logger.Errorf("failed to persist session %s: %v", sess.ID(), err)
+		return fmt.Errorf("failed to persist session: %w", err)
+	}
+
+	s.logger.Debugf("session persisted: session=%s", sess.ID())
+	return nil
+}
+
+// PersistEvent saves an event of a stored session, as a ksess.Persister. It
+// fails with ErrSessionNotFound if the session is not stored.
+func (s *SessionService) PersistEvent(ctx context.Context, sess session.Session, evt *session.Event) error {
+	if evt == nil {
+		return ErrNilEvent
+	}
+	return s.insertEvent(ctx, sess, evt, false)
+}
+
+// DeleteSession removes a session and its events.
+func (s *SessionService) DeleteSession(ctx context.Context, appName, userID, sessionID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM session_events WHERE app_name = ? AND user_id = ? AND session_id = ?
+	`, appName, userID, sessionID); err != nil {
+		return fmt.Errorf("failed to delete events: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM sessions WHERE app_name = ? AND user_id = ? AND id = ?
+	`, appName, userID, sessionID); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// LoadSession reads a session and all its events, as a ksess.Loader. It returns
+// nil, nil if the session is not stored.
+func (s *SessionService) LoadSession(
+	ctx context.Context,
+	appName, userID, sessionID string,
+) (*ksess.StoredSession, error) {
+	return s.loadSession(ctx, appName, userID, sessionID, 0, time.Time{})
+}
+
+// ListSessionIDs returns the IDs of the stored sessions of a user, as a
+// ksess.Loader.
+func (s *SessionService) ListSessionIDs(ctx context.Context, appName, userID string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id FROM sessions WHERE app_name = ? AND user_id = ? ORDER BY id
+	`, appName, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list session IDs: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan session ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate session IDs: %w", err)
+	}
+	return ids, nil
+}
+
+// insertEvent stores evt with the next order of its session and moves the last
+// update time of the session forward. With withState, the state of sess is
+// written in the same transaction.
+func (s *SessionService) insertEvent(
+	ctx context.Context,
+	sess session.Session,
+	evt *session.Event,
+	withState bool,
+) error {
+	evtJSON, err := sonic.Marshal(evt)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event %s: %w", evt.ID, err)
+	}
+	var stateArg any
+	if withState {
+		stateJSON, err := sonic.Marshal(maps.Collect(sess.State().All()))
+		if err != nil {
+			return fmt.Errorf("failed to marshal session state: %w", err)
+		}
+		stateArg = string(stateJSON)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// NOTE: An older event, e.g. imported, does not move last_update_time back
+	result, err := tx.ExecContext(ctx, `
+		UPDATE sessions SET
+			last_update_time = MAX(last_update_time, ?),
+			state = COALESCE(?, state)
+		WHERE app_name = ? AND user_id = ? AND id = ?
+	`, evt.Timestamp.UnixNano(), stateArg, sess.AppName(), sess.UserID(), sess.ID())
+	if err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, sess.ID())
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO session_events (app_name, user_id, session_id, event_order, id, timestamp, content)
+		SELECT ?, ?, ?, COALESCE(MAX(event_order), -1) + 1, ?, ?, ?
+		FROM session_events WHERE app_name = ? AND user_id = ? AND session_id = ?
+	`, sess.AppName(), sess.UserID(), sess.ID(), evt.ID, evt.Timestamp.UnixNano(), string(evtJSON),
+		sess.AppName(), sess.UserID(), sess.ID()); err != nil {
+		s.logger.Errorf("failed to insert event %s: %v", evt.ID, err)
+		return fmt.Errorf("failed to insert event: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// loadSession reads a session with its last numRecent events (0 reads all) not
+// older than after. It returns nil, nil if the session is not stored.
+func (s *SessionService) loadSession(
+	ctx context.Context,
+	appName, userID, sessionID string,
+	numRecent int,
+	after time.Time,
+) (*ksess.StoredSession, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT user_id, id, state, tags, last_update_time FROM sessions
+		WHERE app_name = ? AND user_id = ? AND id = ?
+	`, appName, userID, sessionID)
+
+	stored := &ksess.StoredSession{AppName: appName}
+	err := s.scanSession(row, stored)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	afterNanos := int64(math.MinInt64)
+	if !after.IsZero() {
+		afterNanos = after.UnixNano()
+	}
+	limit := -1 // NOTE: A negative LIMIT is no limit in SQLite
+	if numRecent > 0 {
+		limit = numRecent
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT content FROM session_events
+		WHERE app_name = ? AND user_id = ? AND session_id = ? AND timestamp >= ?
+		ORDER BY event_order DESC
+		LIMIT ?
+	`, appName, userID, sessionID, afterNanos, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var content string
+		if err := rows.Scan(&content); err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		var evt session.Event
+		if err := sonic.UnmarshalString(content, &evt); err != nil {
+			s.logger.Warnf("failed to unmarshal event of session %s: %v", sessionID, err)
+			continue
+		}
+		stored.Events = append(stored.Events, &evt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate events: %w", err)
+	}
+	slices.Reverse(stored.Events)
+
+	return stored, nil
+}
+
+// scanSession scans a row of user_id, id, state, tags and last_update_time into
+// stored.
+func (s *SessionService) scanSession(row interface{ Scan(dest ...any) error }, stored *ksess.StoredSession) error {
+	var (
+		stateJSON, tagsJSON string
+		lastUpdate          int64
+	)
+	if err := row.Scan(&stored.UserID, &stored.ID, &stateJSON, &tagsJSON, &lastUpdate); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		return fmt.Errorf("failed to scan session: %w", err)
+	}
+
+	stored.LastUpdateTime = time.Unix(0, lastUpdate)
+	if err := sonic.UnmarshalString(stateJSON, &stored.State); err != nil {
+		s.logger.Warnf("failed to unmarshal session state: session=%s, err=%v", stored.ID, err)
+	}
+	if err := sonic.UnmarshalString(tagsJSON, &stored.Tags); err != nil || len(stored.Tags) == 0 {
+		stored.Tags = nil
+	}
+	return nil
+}
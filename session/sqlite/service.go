@@ -0,0 +1,406 @@
+// Package sqlite provides a session.Service and a Persister on a single SQLite
+// file, so CLI tools and tests get durable sessions without running Redis or
+// PostgreSQL. It uses database/sql with the SQLite driver of the application,
+// registered by importing it, e.g. the pure Go modernc.org/sqlite:
+//
+//	import _ "modernc.org/sqlite"
+//
+//	svc, err := sqlite.Open(ctx, "sessions.db")
+package sqlite
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	ksess "github.com/kydenul/k-adk/session"
+	"github.com/kydenul/log"
+	"google.golang.org/adk/session"
+)
+
+var (
+	_ session.Service = (*SessionService)(nil)
+	_ ksess.Persister = (*SessionService)(nil)
+	_ ksess.Loader    = (*SessionService)(nil)
+	_ ksess.Store     = (*SessionService)(nil)
+)
+
+const (
+	// sessionIDByteLength defines the length of the generated session IDs in bytes.
+	sessionIDByteLength = 16
+
+	// DefaultDriver is the driver name of modernc.org/sqlite.
+	DefaultDriver = "sqlite"
+
+	// busyTimeout is how long a statement waits for a lock held by another process.
+	busyTimeout = 5 * time.Second
+)
+
+var (
+	// ErrSessionNotFound is returned for a session that is not stored.
+	ErrSessionNotFound = ksess.ErrSessionNotFound
+
+	// ErrSessionExists is returned by Create for a session ID in use.
+	ErrSessionExists = errors.New("session already exists")
+
+	// ErrNilSession and ErrNilEvent are returned by AppendEvent.
+	ErrNilSession = errors.New("session cannot be nil")
+	ErrNilEvent   = errors.New("event cannot be nil")
+)
+
+// schema creates the sessions and events tables. Times are stored as Unix
+// nanoseconds, whatever the time format of the driver.
+const schema = `
+	CREATE TABLE IF NOT EXISTS sessions (
+		app_name TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		id TEXT NOT NULL,
+		state TEXT NOT NULL DEFAULT '{}',
+		tags TEXT NOT NULL DEFAULT '{}',
+		last_update_time INTEGER NOT NULL,
+		created_at INTEGER NOT NULL,
+		PRIMARY KEY (app_name, user_id, id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_sessions_app_last_update ON sessions(app_name, last_update_time DESC);
+
+	CREATE TABLE IF NOT EXISTS session_events (
+		app_name TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		session_id TEXT NOT NULL,
+		event_order INTEGER NOT NULL,
+		id TEXT NOT NULL,
+		timestamp INTEGER NOT NULL,
+		content TEXT NOT NULL,
+		PRIMARY KEY (app_name, user_id, session_id, event_order)
+	);
+`
+
+// SessionService implements session.Service on a SQLite database. It also
+// implements ksess.Persister and ksess.Loader on the same tables, so it can back
+// a Redis or in-memory service as its long-term store, and read back what they
+// persisted.
+//
+// The database is used through a single connection, which serializes the writes
+// of the process as SQLite does anyway. The state written by AppendEvent is the
+// state of the session passed to it: concurrent writers of a session should each
+// get it again first, the last write wins.
+type SessionService struct {
+	db     *sql.DB
+	logger log.Logger
+
+	// closeDB closes db on Close, when it was opened by Open.
+	closeDB bool
+
+	// Optional. driver is the driver name of Open.
+	driver string
+	// Optional. idPolicy validates the client-supplied session IDs.
+	idPolicy *ksess.IDPolicy
+	// Optional. stampEvents replaces the caller-supplied event timestamps.
+	stampEvents bool
+}
+
+// ServiceOption configures the SessionService.
+type ServiceOption func(*SessionService)
+
+// WithLogger sets the optional logger for the SessionService.
+func WithLogger(logger log.Logger) ServiceOption {
+	return func(s *SessionService) { s.logger = logger }
+}
+
+// WithDriver sets the name of the SQLite driver Open uses, e.g. "sqlite3" for
+// github.com/mattn/go-sqlite3. Default is DefaultDriver.
+func WithDriver(name string) ServiceOption {
+	return func(s *SessionService) { s.driver = name }
+}
+
+// WithIDPolicy validates the session IDs supplied to Create against p, or replaces
+// them with generated IDs if p.AlwaysGenerate is set. Invalid IDs fail with an
+// error matching ksess.ErrInvalidSessionID.
+func WithIDPolicy(p ksess.IDPolicy) ServiceOption {
+	return func(s *SessionService) { s.idPolicy = &p }
+}
+
+// WithAppendTimestamps stamps every appended event with the time of the append,
+// replacing the timestamp set by the caller. By default only the events without a
+// timestamp are stamped, so replayed or imported events keep their own.
+func WithAppendTimestamps() ServiceOption {
+	return func(s *SessionService) { s.stampEvents = true }
+}
+
+// Open opens the SQLite database at path, created if missing, and creates a
+// SessionService on it. The database is closed by Close. Use ":memory:" for a
+// database living as long as the service, e.g. in tests.
+func Open(ctx context.Context, path string, opts ...ServiceOption) (*SessionService, error) {
+	svc := &SessionService{driver: DefaultDriver}
+	for _, opt := range opts {
+		opt(svc)
+	}
+
+	db, err := sql.Open(svc.driver, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database %s: %w", path, err)
+	}
+
+	svc, err = NewSessionService(ctx, db, opts...)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	svc.closeDB = true
+
+	return svc, nil
+}
+
+// NewSessionService creates a SessionService on db, a SQLite database the caller
+// opened and closes, and creates its tables if needed. The connection pool of db
+// is limited to one connection.
+func NewSessionService(ctx context.Context, db *sql.DB, opts ...ServiceOption) (*SessionService, error) {
+	if db == nil {
+		return nil, errors.New("sqlite database cannot be nil")
+	}
+
+	svc := &SessionService{db: db, driver: DefaultDriver}
+	for _, opt := range opts {
+		opt(svc)
+	}
+
+	if svc.logger == nil {
+		svc.logger = discardlog.NewDiscardLog()
+	}
+
+	// NOTE: A single connection keeps the pragmas, and an in-memory database
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxLifetime(0)
+	db.SetConnMaxIdleTime(0)
+
+	for _, pragma := range []string{
+		fmt.Sprintf(`PRAGMA busy_timeout = %d`, busyTimeout.Milliseconds()),
+		`PRAGMA journal_mode = WAL`,
+	} {
+		if _, err := db.ExecContext(ctx, pragma); err != nil {
+			svc.logger.Errorf("failed to set sqlite pragma %q: %v", pragma, err)
+			return nil, fmt.Errorf("failed to set sqlite pragma %q: %w", pragma, err)
+		}
+	}
+
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		svc.logger.Errorf("failed to create sqlite tables: %v", err)
+		return nil, fmt.Errorf("failed to create sqlite tables: %w", err)
+	}
+
+	svc.logger.Info("SQLite session service initialized")
+
+	return svc, nil
+}
+
+// DB returns the database of the service.
+func (s *SessionService) DB() *sql.DB { return s.db }
+
+// Close closes the database if Open opened it.
+func (s *SessionService) Close() error {
+	if !s.closeDB {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// generateSessionID generates a unique session ID using crypto/rand.
+func generateSessionID() string {
+	b := make([]byte, sessionIDByteLength)
+
+	if _, err := rand.Read(b); err != nil {
+		// Fallback to timestamp if crypto/rand fails
+		return strconv.FormatInt(time.Now().UnixNano(), 10)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// resolveSessionID returns the ID of a new session: the requested ID, validated by
+// the ID policy if one is set, or a generated ID.
+func (s *SessionService) resolveSessionID(requested string) (string, error) {
+	if s.idPolicy != nil {
+		return s.idPolicy.Resolve(requested, generateSessionID)
+	}
+	if requested == "" {
+		return generateSessionID(), nil
+	}
+	return requested, nil
+}
+
+// Create creates a new session. Keys prefixed with session.KeyPrefixTemp are not
+// stored.
+func (s *SessionService) Create(
+	ctx context.Context,
+	req *session.CreateRequest,
+) (*session.CreateResponse, error) {
+	sessionID, err := s.resolveSessionID(req.SessionID)
+	if err != nil {
+		s.logger.Warnf("rejected session ID: app=%s, user=%s, err=%v", req.AppName, req.UserID, err)
+		return nil, err
+	}
+
+	state := make(map[string]any, len(req.State))
+	for key, value := range req.State {
+		if !strings.HasPrefix(key, session.KeyPrefixTemp) {
+			state[key] = value
+		}
+	}
+	stateJSON, err := sonic.Marshal(state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal session state: %w", err)
+	}
+
+	now := time.Now()
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO sessions (app_name, user_id, id, state, last_update_time, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (app_name, user_id, id) DO NOTHING
+	`, req.AppName, req.UserID, sessionID, string(stateJSON), now.UnixNano(), now.UnixNano())
+	if err != nil {
+		s.logger.Errorf("failed to create session %s: %v", sessionID, err)
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrSessionExists, sessionID)
+	}
+
+	s.logger.Infof("session created: app=%s, user=%s, session=%s", req.AppName, req.UserID, sessionID)
+
+	return &session.CreateResponse{Session: newSQLiteSession(&ksess.StoredSession{
+		ID:             sessionID,
+		AppName:        req.AppName,
+		UserID:         req.UserID,
+		State:          state,
+		LastUpdateTime: now,
+	})}, nil
+}
+
+// Get retrieves a session by ID, with its last NumRecentEvents events (0 gets
+// all) not older than After.
+func (s *SessionService) Get(
+	ctx context.Context,
+	req *session.GetRequest,
+) (*session.GetResponse, error) {
+	stored, err := s.loadSession(ctx, req.AppName, req.UserID, req.SessionID, req.NumRecentEvents, req.After)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, req.SessionID)
+	}
+
+	s.logger.Debugf("session retrieved: session=%s, events=%d", req.SessionID, len(stored.Events))
+
+	return &session.GetResponse{Session: newSQLiteSession(stored)}, nil
+}
+
+// List returns the sessions of a user, or of every user of the app if UserID is
+// empty, most recently updated first and without their events.
+func (s *SessionService) List(
+	ctx context.Context,
+	req *session.ListRequest,
+) (*session.ListResponse, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT user_id, id, state, tags, last_update_time FROM sessions
+		WHERE app_name = ? AND (? = '' OR user_id = ?)
+		ORDER BY last_update_time DESC
+	`, req.AppName, req.UserID, req.UserID)
+	if err != nil {
+		s.logger.Errorf("failed to list sessions of app %s: %v", req.AppName, err)
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []session.Session
+	for rows.Next() {
+		stored := &ksess.StoredSession{AppName: req.AppName}
+		if err := s.scanSession(rows, stored); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, newSQLiteSession(stored))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate sessions: %w", err)
+	}
+
+	s.logger.Debugf("listed %d sessions for user %s", len(sessions), req.UserID)
+
+	return &session.ListResponse{Sessions: sessions}, nil
+}
+
+// Delete removes a session and its events.
+func (s *SessionService) Delete(ctx context.Context, req *session.DeleteRequest) error {
+	if err := s.DeleteSession(ctx, req.AppName, req.UserID, req.SessionID); err != nil {
+		s.logger.Errorf("failed to delete session %s: %v", req.SessionID, err)
+		return err
+	}
+
+	s.logger.Infof("session deleted: app=%s, user=%s, session=%s",
+		req.AppName, req.UserID, req.SessionID)
+
+	return nil
+}
+
+// AppendEvent appends an event to a session, and applies its state delta; keys
+// prefixed with session.KeyPrefixTemp are not stored. Partial events are ignored.
+// Events without a timestamp are stamped with the current time (see
+// WithAppendTimestamps); the last update time of the session does not go back to
+// the timestamp of an older event.
+func (s *SessionService) AppendEvent(
+	ctx context.Context,
+	sess session.Session,
+	evt *session.Event,
+) error {
+	if sess == nil {
+		return ErrNilSession
+	}
+	if evt == nil {
+		return ErrNilEvent
+	}
+	if evt.Partial {
+		return nil
+	}
+
+	// NOTE: Keep the timestamp of events replayed or imported by the caller
+	if evt.Timestamp.IsZero() || s.stampEvents {
+		evt.Timestamp = time.Now()
+	}
+	if evt.ID == "" {
+		evt.ID = generateSessionID()
+	}
+
+	// NOTE: Apply the state delta to the caller's session, then store its state
+	for key, value := range evt.Actions.StateDelta {
+		if strings.HasPrefix(key, session.KeyPrefixTemp) {
+			continue
+		}
+		if err := sess.State().Set(key, value); err != nil {
+			return fmt.Errorf("failed to apply state delta: %w", err)
+		}
+	}
+
+	if err := s.insertEvent(ctx, sess, evt, true); err != nil {
+		return err
+	}
+
+	if ss, ok := sess.(*sqliteSession); ok {
+		ss.events.append(evt)
+		if evt.Timestamp.After(ss.lastUpdateTime) {
+			ss.lastUpdateTime = evt.Timestamp
+		}
+	}
+
+	s.logger.Debugf("event appended: session=%s, event=%s", sess.ID(), evt.ID)
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/adk/session"
+	_ "modernc.org/sqlite"
+)
+
+// setupTestService opens a service on a database file of the test.
+func setupTestService(t *testing.T) *SessionService {
+	t.Helper()
+
+	svc, err := Open(context.Background(), filepath.Join(t.TempDir(), "sessions.db"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { _ = svc.Close() })
+	return svc
+}
+
+func TestNewSessionServiceNilDB(t *testing.T) {
+	if _, err := NewSessionService(context.Background(), nil); err == nil {
+		t.Error("expected an error for a nil database")
+	}
+}
+
+func TestSessionService(t *testing.T) {
+	svc := setupTestService(t)
+	ctx := context.Background()
+
+	resp, err := svc.Create(ctx, &session.CreateRequest{
+		AppName: "app", UserID: "user", SessionID: "sess-1",
+		State: map[string]any{"topic": "go", session.KeyPrefixTemp + "scratch": 1},
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := svc.Create(ctx, &session.CreateRequest{AppName: "app", UserID: "user", SessionID: "sess-1"}); !errors.Is(err, ErrSessionExists) {
+		t.Errorf("expected ErrSessionExists, got %v", err)
+	}
+
+	for i, id := range []string{"e1", "e2", "e3"} {
+		evt := &session.Event{ID: id, Author: "user", Timestamp: time.Now().Add(time.Duration(i) * time.Second)}
+		evt.Actions.StateDelta = map[string]any{"step": i}
+		if err := svc.AppendEvent(ctx, resp.Session, evt); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+	}
+
+	got, err := svc.Get(ctx, &session.GetRequest{AppName: "app", UserID: "user", SessionID: "sess-1"})
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Session.Events().Len() != 3 {
+		t.Errorf("expected 3 events, got %d", got.Session.Events().Len())
+	}
+	if step, _ := got.Session.State().Get("step"); step != float64(2) {
+		t.Errorf("step = %v, want 2", step)
+	}
+	if _, err := got.Session.State().Get(session.KeyPrefixTemp + "scratch"); err == nil {
+		t.Error("temp key stored")
+	}
+
+	recent, err := svc.Get(ctx, &session.GetRequest{AppName: "app", UserID: "user", SessionID: "sess-1", NumRecentEvents: 2})
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if n := recent.Session.Events().Len(); n != 2 || recent.Session.Events().At(1).ID != "e3" {
+		t.Errorf("expected the last 2 events, got %d", n)
+	}
+
+	list, err := svc.List(ctx, &session.ListRequest{AppName: "app"})
+	if err != nil || len(list.Sessions) != 1 {
+		t.Fatalf("List = %v, %v", list, err)
+	}
+
+	if err := svc.Delete(ctx, &session.DeleteRequest{AppName: "app", UserID: "user", SessionID: "sess-1"}); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := svc.Get(ctx, &session.GetRequest{AppName: "app", UserID: "user", SessionID: "sess-1"}); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestPersister(t *testing.T) {
+	svc := setupTestService(t)
+	ctx := context.Background()
+
+	resp, err := session.InMemoryService().Create(ctx, &session.CreateRequest{
+		AppName: "app", UserID: "user", SessionID: "sess-persisted", State: map[string]any{"topic": "go"},
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	sess := resp.Session
+
+	if err := svc.PersistEvent(ctx, sess, &session.Event{ID: "e0"}); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound for an event of a missing session, got %v", err)
+	}
+	if err := svc.PersistSession(ctx, sess); err != nil {
+		t.Fatalf("PersistSession failed: %v", err)
+	}
+	for _, id := range []string{"e1", "e2"} {
+		if err := svc.PersistEvent(ctx, sess, &session.Event{ID: id, Author: "user", Timestamp: time.Now()}); err != nil {
+			t.Fatalf("PersistEvent failed: %v", err)
+		}
+	}
+
+	stored, err := svc.LoadSession(ctx, "app", "user", "sess-persisted")
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+	if stored == nil || len(stored.Events) != 2 || stored.State["topic"] != "go" {
+		t.Fatalf("unexpected stored session: %+v", stored)
+	}
+
+	ids, err := svc.ListSessionIDs(ctx, "app", "user")
+	if err != nil || !slices.Equal(ids, []string{"sess-persisted"}) {
+		t.Errorf("ListSessionIDs = %v, %v", ids, err)
+	}
+
+	if err := svc.DeleteSession(ctx, "app", "user", "sess-persisted"); err != nil {
+		t.Fatalf("DeleteSession failed: %v", err)
+	}
+	if stored, err := svc.LoadSession(ctx, "app", "user", "sess-persisted"); err != nil || stored != nil {
+		t.Errorf("expected the session deleted, got %+v, %v", stored, err)
+	}
+}
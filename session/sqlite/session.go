@@ -0,0 +1,135 @@
+package sqlite
+
+import (
+	"iter"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	ksess "github.com/kydenul/k-adk/session"
+	"google.golang.org/adk/session"
+)
+
+var (
+	_ session.Session = (*sqliteSession)(nil)
+	_ ksess.Tagged    = (*sqliteSession)(nil)
+)
+
+// sqliteSession implements the session.Session interface over a copy of a stored
+// session: changes reach SQLite through SessionService.AppendEvent only.
+type sqliteSession struct {
+	id             string
+	appName        string
+	userID         string
+	state          *sqliteState
+	events         *sqliteEvents
+	tags           map[string]string
+	lastUpdateTime time.Time
+}
+
+// newSQLiteSession returns the session of a stored one.
+func newSQLiteSession(stored *ksess.StoredSession) *sqliteSession {
+	state := maps.Clone(stored.State)
+	if state == nil {
+		state = make(map[string]any)
+	}
+
+	return &sqliteSession{
+		id:             stored.ID,
+		appName:        stored.AppName,
+		userID:         stored.UserID,
+		state:          &sqliteState{values: state},
+		events:         &sqliteEvents{events: slices.Clone(stored.Events)},
+		tags:           stored.Tags,
+		lastUpdateTime: stored.LastUpdateTime,
+	}
+}
+
+func (s *sqliteSession) ID() string                { return s.id }
+func (s *sqliteSession) AppName() string           { return s.appName }
+func (s *sqliteSession) UserID() string            { return s.userID }
+func (s *sqliteSession) State() session.State      { return s.state }
+func (s *sqliteSession) Events() session.Events    { return s.events }
+func (s *sqliteSession) LastUpdateTime() time.Time { return s.lastUpdateTime }
+
+// Tags implements ksess.Tagged.
+func (s *sqliteSession) Tags() map[string]string { return maps.Clone(s.tags) }
+
+var _ session.State = (*sqliteState)(nil)
+
+// sqliteState implements session.State with a map. It is thread-safe.
+type sqliteState struct {
+	mu     sync.RWMutex
+	values map[string]any
+}
+
+func (s *sqliteState) Get(key string) (any, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, ok := s.values[key]
+	if !ok {
+		return nil, session.ErrStateKeyNotExist
+	}
+	return value, nil
+}
+
+func (s *sqliteState) Set(key string, value any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.values == nil {
+		s.values = make(map[string]any)
+	}
+	s.values[key] = value
+	return nil
+}
+
+func (s *sqliteState) All() iter.Seq2[string, any] {
+	s.mu.RLock()
+	values := maps.Clone(s.values)
+	s.mu.RUnlock()
+
+	return maps.All(values)
+}
+
+var _ session.Events = (*sqliteEvents)(nil)
+
+// sqliteEvents implements session.Events with a slice. It is thread-safe.
+type sqliteEvents struct {
+	mu     sync.RWMutex
+	events []*session.Event
+}
+
+func (e *sqliteEvents) All() iter.Seq[*session.Event] {
+	e.mu.RLock()
+	events := slices.Clone(e.events)
+	e.mu.RUnlock()
+
+	return slices.Values(events)
+}
+
+func (e *sqliteEvents) Len() int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return len(e.events)
+}
+
+func (e *sqliteEvents) At(i int) *session.Event {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if i < 0 || i >= len(e.events) {
+		return nil
+	}
+	return e.events[i]
+}
+
+func (e *sqliteEvents) append(evt *session.Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.events = append(e.events, evt)
+}
@@ -0,0 +1,75 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+const (
+	// MaxTagKeyLength is the maximum length of a tag key, in bytes.
+	MaxTagKeyLength = 64
+
+	// MaxTagValueLength is the maximum length of a tag value, in bytes.
+	MaxTagValueLength = 256
+)
+
+// ErrInvalidTag is returned when setting a tag with an invalid key or value.
+var ErrInvalidTag = errors.New("invalid tag")
+
+// Tagged is implemented by the sessions carrying tags: key/value labels organizing
+// the sessions of a user, e.g. topic, channel or priority, kept apart from the
+// state the agent works with.
+type Tagged interface {
+	// Tags returns the tags of the session, nil if it has none.
+	Tags() map[string]string
+}
+
+// TagsOf returns the tags of sess, nil if it has none or does not carry tags.
+func TagsOf(sess any) map[string]string {
+	t, ok := sess.(Tagged)
+	if !ok {
+		return nil
+	}
+	return t.Tags()
+}
+
+// MatchTags reports whether tags has every key of filter with the same value. An
+// empty filter matches every session.
+func MatchTags(tags, filter map[string]string) bool {
+	for k, v := range filter {
+		if got, ok := tags[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateTagKey checks a tag key: 1 to MaxTagKeyLength bytes of ASCII letters,
+// digits, '-', '_', '.' and '/'.
+func ValidateTagKey(key string) error {
+	if key == "" || len(key) > MaxTagKeyLength {
+		return fmt.Errorf("%w: key %q must be 1 to %d bytes", ErrInvalidTag, key, MaxTagKeyLength)
+	}
+	for _, r := range key {
+		if !DefaultCharset(r) && r != '/' {
+			return fmt.Errorf("%w: key %q contains %q", ErrInvalidTag, key, r)
+		}
+	}
+	return nil
+}
+
+// ValidateTags checks the keys and values of tags. Values are valid UTF-8 strings
+// of at most MaxTagValueLength bytes.
+func ValidateTags(tags map[string]string) error {
+	for k, v := range tags {
+		if err := ValidateTagKey(k); err != nil {
+			return err
+		}
+		if len(v) > MaxTagValueLength || !utf8.ValidString(v) {
+			return fmt.Errorf("%w: value of %q must be valid UTF-8 of at most %d bytes",
+				ErrInvalidTag, k, MaxTagValueLength)
+		}
+	}
+	return nil
+}
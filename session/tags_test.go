@@ -0,0 +1,53 @@
+package session
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMatchTags(t *testing.T) {
+	tags := map[string]string{"topic": "billing", "channel": "web"}
+
+	tests := []struct {
+		filter map[string]string
+		want   bool
+	}{
+		{nil, true},
+		{map[string]string{"topic": "billing"}, true},
+		{map[string]string{"topic": "billing", "channel": "web"}, true},
+		{map[string]string{"topic": "support"}, false},
+		{map[string]string{"priority": ""}, false},
+	}
+
+	for _, tt := range tests {
+		if got := MatchTags(tags, tt.filter); got != tt.want {
+			t.Errorf("MatchTags(%v) = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTags(t *testing.T) {
+	tests := []struct {
+		tags  map[string]string
+		valid bool
+	}{
+		{map[string]string{"topic": "billing", "team/owner": "a b:c"}, true},
+		{map[string]string{"priority": ""}, true},
+		{map[string]string{"": "x"}, false},
+		{map[string]string{"a:b": "x"}, false},
+		{map[string]string{strings.Repeat("k", MaxTagKeyLength+1): "x"}, false},
+		{map[string]string{"topic": strings.Repeat("v", MaxTagValueLength+1)}, false},
+		{map[string]string{"topic": "\xff"}, false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateTags(tt.tags)
+		if tt.valid && err != nil {
+			t.Errorf("ValidateTags(%v) = %v, want nil", tt.tags, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidTag) {
+			t.Errorf("ValidateTags(%v) = %v, want ErrInvalidTag", tt.tags, err)
+		}
+	}
+}
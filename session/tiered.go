@@ -0,0 +1,327 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/log"
+	"google.golang.org/adk/session"
+)
+
+var (
+	_ session.Service = (*TieredService)(nil)
+	_ Importer        = (*TieredService)(nil)
+	_ HealthChecker   = (*TieredService)(nil)
+)
+
+var (
+	// ErrNilStore is returned by NewTieredService without a store.
+	ErrNilStore = errors.New("session store cannot be nil")
+
+	// ErrSessionNotFound is matched by the error of a session service for a session
+	// it does not have; the ErrSessionNotFound of the services of this module are
+	// this error. A TieredService restores only the sessions its cache fails to
+	// find with it.
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+// Cache is the fast tier of a TieredService: a session service that also takes
+// the sessions read back from the store as is, such as redis.RedisSessionService
+// and inmemory.InMemorySessionService. It should have no persister of its own:
+// the TieredService writes the store. A session it does not have fails with an
+// error matching ErrSessionNotFound.
+type Cache interface {
+	session.Service
+	Importer
+}
+
+// Store is the durable tier of a TieredService: a Persister with its Loader,
+// such as postgres.SessionPersister, sqlite.SessionService or
+// dynamodb.SessionService.
+type Store interface {
+	Persister
+	Loader
+}
+
+// TieredStats counts the reads and writes of a TieredService.
+type TieredStats struct {
+	// Hits counts the sessions read from the cache.
+	Hits int64
+	// Misses counts the sessions missing from the cache.
+	Misses int64
+	// Restores counts the sessions read back from the store into the cache.
+	Restores int64
+	// StoreFailures counts the writes failing on the store.
+	StoreFailures int64
+}
+
+// TieredService implements session.Service on a fast cache backed by a durable
+// store, the read-through/write-behind logic of the Redis + PostgreSQL hybrid for
+// any pair of backends (e.g., in-memory + SQLite, memcached + MongoDB):
+//
+//   - Reads go to the cache. A session missing from it, e.g. evicted or lost in a
+//     restart, is read back from the store into the cache, and so are the sessions
+//     of a user listed by the store but missing from the cache. Any other error of
+//     the cache is returned as is.
+//   - Writes go to the cache first, then to the store. The cache is the primary
+//     storage: a write failing on the store is logged and counted, not returned.
+//     Stores persisting asynchronously, such as postgres.SessionPersister, keep the
+//     store writes out of the request latency.
+type TieredService struct {
+	logger log.Logger
+	cache  Cache
+	store  Store
+
+	hits, misses, restores, storeFailures atomic.Int64
+}
+
+// TieredOption configures the TieredService.
+type TieredOption func(*TieredService)
+
+// WithTieredLogger sets the optional logger for the TieredService.
+func WithTieredLogger(logger log.Logger) TieredOption {
+	return func(t *TieredService) { t.logger = logger }
+}
+
+// NewTieredService creates a TieredService serving the sessions of store
+// through cache. Returns an error if cache or store is nil.
+func NewTieredService(cache Cache, store Store, opts ...TieredOption) (*TieredService, error) {
+	if cache == nil {
+		return nil, ErrNilService
+	}
+	if store == nil {
+		return nil, ErrNilStore
+	}
+
+	t := &TieredService{cache: cache, store: store}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	if t.logger == nil {
+		t.logger = discardlog.NewDiscardLog()
+	}
+
+	t.logger.Info("tiered session service created")
+
+	return t, nil
+}
+
+// Stats returns the reads and writes of the service so far.
+func (t *TieredService) Stats() TieredStats {
+	return TieredStats{
+		Hits:          t.hits.Load(),
+		Misses:        t.misses.Load(),
+		Restores:      t.restores.Load(),
+		StoreFailures: t.storeFailures.Load(),
+	}
+}
+
+// Close closes the store. The cache is left to its owner.
+func (t *TieredService) Close() error {
+	return t.store.Close()
+}
+
+// Ping pings the cache and the store, those implementing HealthChecker.
+func (t *TieredService) Ping(ctx context.Context) error {
+	return t.checkHealth(ctx, HealthChecker.Ping)
+}
+
+// Healthy checks the health of the cache and the store, those implementing
+// HealthChecker.
+func (t *TieredService) Healthy(ctx context.Context) error {
+	return t.checkHealth(ctx, HealthChecker.Healthy)
+}
+
+// checkHealth runs check on the tiers implementing HealthChecker.
+func (t *TieredService) checkHealth(ctx context.Context, check func(HealthChecker, context.Context) error) error {
+	var errs []error
+	if hc, ok := t.cache.(HealthChecker); ok {
+		if err := check(hc, ctx); err != nil {
+			errs = append(errs, fmt.Errorf("cache: %w", err))
+		}
+	}
+	if hc, ok := t.store.(HealthChecker); ok {
+		if err := check(hc, ctx); err != nil {
+			errs = append(errs, fmt.Errorf("store: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Create creates a session in the cache, then persists it to the store.
+func (t *TieredService) Create(ctx context.Context, req *session.CreateRequest) (*session.CreateResponse, error) {
+	resp, err := t.cache.Create(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.store.PersistSession(ctx, resp.Session); err != nil {
+		t.storeFailed(OpPersistSession, resp.Session.ID(), err)
+	}
+
+	return resp, nil
+}
+
+// Get reads a session from the cache. A session missing from the cache is read
+// back from the store, and the error of the cache is returned if the store does
+// not have it either. Other errors of the cache are returned as is.
+func (t *TieredService) Get(ctx context.Context, req *session.GetRequest) (*session.GetResponse, error) {
+	resp, err := t.cache.Get(ctx, req)
+	if err == nil {
+		t.hits.Add(1)
+		return resp, nil
+	}
+	if !errors.Is(err, ErrSessionNotFound) {
+		return nil, err
+	}
+	t.misses.Add(1)
+
+	// NOTE: Read through to the store
+	found, restoreErr := t.restore(ctx, req.AppName, req.UserID, req.SessionID)
+	if restoreErr != nil {
+		return nil, restoreErr
+	}
+	if !found {
+		return nil, err
+	}
+
+	return t.cache.Get(ctx, req)
+}
+
+// List returns the sessions of the cache. The sessions of a user the store lists
+// but the cache misses are read back from the store first, so they are listed
+// too; those failing to load are logged and skipped. Listing the sessions of
+// every user of an app (empty UserID) only reads the cache.
+func (t *TieredService) List(ctx context.Context, req *session.ListRequest) (*session.ListResponse, error) {
+	resp, err := t.cache.List(ctx, req)
+	if err != nil || req.UserID == "" {
+		return resp, err
+	}
+
+	ids, err := t.store.ListSessionIDs(ctx, req.AppName, req.UserID)
+	if err != nil {
+		t.logger.Errorf("failed to list sessions of user %s in the store: %v", req.UserID, err)
+		return nil, fmt.Errorf("failed to list stored sessions: %w", err)
+	}
+
+	cached := make(map[string]bool, len(resp.Sessions))
+	for _, sess := range resp.Sessions {
+		cached[sess.ID()] = true
+	}
+
+	restored := 0
+	for _, id := range ids {
+		if cached[id] {
+			continue
+		}
+		found, err := t.restore(ctx, req.AppName, req.UserID, id)
+		if err != nil {
+			t.logger.Warnf("failed to restore session %s: %v", id, err)
+			continue
+		}
+		if found {
+			restored++
+		}
+	}
+	if restored == 0 {
+		return resp, nil
+	}
+
+	return t.cache.List(ctx, req)
+}
+
+// Delete removes a session from the cache, then from the store.
+func (t *TieredService) Delete(ctx context.Context, req *session.DeleteRequest) error {
+	if err := t.cache.Delete(ctx, req); err != nil {
+		return err
+	}
+
+	if err := t.store.DeleteSession(ctx, req.AppName, req.UserID, req.SessionID); err != nil {
+		t.storeFailed(OpDeleteSession, req.SessionID, err)
+	}
+
+	return nil
+}
+
+// AppendEvent appends an event to a session in the cache, then persists it to
+// the store. A session evicted from the cache since it was read is read back from
+// the store, and the append retried once; other errors of the cache, e.g. a
+// conflict or a rate limit, are returned as is. Partial events are not persisted.
+func (t *TieredService) AppendEvent(ctx context.Context, sess session.Session, evt *session.Event) error {
+	if sess == nil {
+		return ErrNilSession
+	}
+
+	err := t.cache.AppendEvent(ctx, sess, evt)
+	if errors.Is(err, ErrSessionNotFound) {
+		found, restoreErr := t.restore(ctx, sess.AppName(), sess.UserID(), sess.ID())
+		if restoreErr != nil || !found {
+			return err
+		}
+		if err := t.cache.AppendEvent(ctx, sess, evt); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	if evt == nil || evt.Partial {
+		return nil
+	}
+
+	if err := t.store.PersistEvent(ctx, sess, evt); err != nil {
+		t.storeFailed(OpPersistEvent, sess.ID(), err)
+	}
+
+	return nil
+}
+
+// ImportSession imports a session into the cache as is, then persists it and its
+// events to the store. It implements Importer.
+func (t *TieredService) ImportSession(ctx context.Context, stored *StoredSession) error {
+	if err := t.cache.ImportSession(ctx, stored); err != nil {
+		return err
+	}
+
+	if err := ImportPersisted(ctx, t.store, NewDump(stored)); err != nil {
+		t.storeFailed(OpPersistSession, stored.ID, err)
+	}
+
+	return nil
+}
+
+// restore reads a session back from the store into the cache. It returns false if
+// the store does not have the session. A session written to the cache
+// concurrently is kept.
+func (t *TieredService) restore(ctx context.Context, appName, userID, sessionID string) (bool, error) {
+	stored, err := t.store.LoadSession(ctx, appName, userID, sessionID)
+	if err != nil {
+		t.logger.Errorf("failed to load session %s from the store: %v", sessionID, err)
+		return false, fmt.Errorf("failed to load session: %w", err)
+	}
+	if stored == nil {
+		return false, nil
+	}
+
+	// NOTE: The identity of the request wins over the one stored
+	stored.ID, stored.AppName, stored.UserID = sessionID, appName, userID
+	if err := t.cache.ImportSession(ctx, stored); err != nil {
+		t.logger.Debugf("session %s not restored, kept the cached one: %v", sessionID, err)
+		return true, nil
+	}
+	t.restores.Add(1)
+
+	t.logger.Infof("session restored from the store: session=%s, events=%d", sessionID, len(stored.Events))
+
+	return true, nil
+}
+
+// storeFailed logs and counts a write failing on the store.
+func (t *TieredService) storeFailed(op, sessionID string, err error) {
+	t.storeFailures.Add(1)
+	t.logger.Warnf("%s failed on the store: session=%s, err=%v", op, sessionID, err)
+}
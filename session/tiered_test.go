@@ -0,0 +1,277 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+
+	"google.golang.org/adk/model"
+	"google.golang.org/adk/session"
+)
+
+// importingCache is an ADK in-memory service importing sessions through Import.
+type importingCache struct {
+	session.Service
+}
+
+func (c importingCache) ImportSession(ctx context.Context, stored *StoredSession) error {
+	_, err := Import(ctx, c.Service, NewDump(stored))
+	return err
+}
+
+// NOTE: The ADK in-memory service only fails these for a missing session
+func (c importingCache) Get(ctx context.Context, req *session.GetRequest) (*session.GetResponse, error) {
+	resp, err := c.Service.Get(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSessionNotFound, err)
+	}
+	return resp, nil
+}
+
+func (c importingCache) AppendEvent(ctx context.Context, sess session.Session, evt *session.Event) error {
+	if err := c.Service.AppendEvent(ctx, sess, evt); err != nil {
+		return fmt.Errorf("%w: %w", ErrSessionNotFound, err)
+	}
+	return nil
+}
+
+// failingCache is a cache failing reads and appends with err.
+type failingCache struct {
+	importingCache
+	err error
+}
+
+func (c failingCache) Get(context.Context, *session.GetRequest) (*session.GetResponse, error) {
+	return nil, c.err
+}
+
+func (c failingCache) AppendEvent(context.Context, session.Session, *session.Event) error {
+	return c.err
+}
+
+// mapStore is a Store keeping the sessions in a map, or failing writes with err.
+type mapStore struct {
+	mu       sync.Mutex
+	sessions map[string]*StoredSession
+	err      error
+}
+
+func newMapStore() *mapStore { return &mapStore{sessions: make(map[string]*StoredSession)} }
+
+func storeKey(appName, userID, sessionID string) string {
+	return appName + "/" + userID + "/" + sessionID
+}
+
+func (s *mapStore) PersistSession(_ context.Context, sess session.Session) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := storeKey(sess.AppName(), sess.UserID(), sess.ID())
+	stored, ok := s.sessions[key]
+	if !ok {
+		stored = &StoredSession{ID: sess.ID(), AppName: sess.AppName(), UserID: sess.UserID()}
+		s.sessions[key] = stored
+	}
+	stored.State = maps.Collect(sess.State().All())
+	stored.LastUpdateTime = sess.LastUpdateTime()
+	return nil
+}
+
+func (s *mapStore) PersistEvent(_ context.Context, sess session.Session, evt *session.Event) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.sessions[storeKey(sess.AppName(), sess.UserID(), sess.ID())]
+	if stored == nil {
+		return errors.New("session missing")
+	}
+	stored.Events = append(stored.Events, evt)
+	stored.State = maps.Collect(sess.State().All())
+	return nil
+}
+
+func (s *mapStore) DeleteSession(_ context.Context, appName, userID, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, storeKey(appName, userID, sessionID))
+	return nil
+}
+
+func (s *mapStore) Close() error { return nil }
+
+func (s *mapStore) LoadSession(_ context.Context, appName, userID, sessionID string) (*StoredSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.sessions[storeKey(appName, userID, sessionID)]
+	if stored == nil {
+		return nil, nil
+	}
+	loaded := *stored
+	loaded.State = maps.Clone(stored.State)
+	loaded.Events = slices.Clone(stored.Events)
+	return &loaded, nil
+}
+
+func (s *mapStore) ListSessionIDs(_ context.Context, appName, userID string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []string
+	for _, stored := range s.sessions {
+		if stored.AppName == appName && stored.UserID == userID {
+			ids = append(ids, stored.ID)
+		}
+	}
+	return ids, nil
+}
+
+func newTestTiered(t *testing.T, store *mapStore) *TieredService {
+	t.Helper()
+
+	svc, err := NewTieredService(importingCache{session.InMemoryService()}, store)
+	if err != nil {
+		t.Fatalf("NewTieredService failed: %v", err)
+	}
+	return svc
+}
+
+func TestNewTieredService(t *testing.T) {
+	if _, err := NewTieredService(nil, newMapStore()); !errors.Is(err, ErrNilService) {
+		t.Errorf("expected ErrNilService, got %v", err)
+	}
+	if _, err := NewTieredService(importingCache{session.InMemoryService()}, nil); !errors.Is(err, ErrNilStore) {
+		t.Errorf("expected ErrNilStore, got %v", err)
+	}
+}
+
+func TestTieredService(t *testing.T) {
+	ctx := context.Background()
+	store := newMapStore()
+	svc := newTestTiered(t, store)
+
+	created, err := svc.Create(ctx, &session.CreateRequest{
+		AppName: "app", UserID: "u1", SessionID: "s1", State: map[string]any{"lang": "fr"},
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	evt := session.NewEvent("inv-1")
+	evt.Actions.StateDelta = map[string]any{"topic": "billing"}
+	if err := svc.AppendEvent(ctx, created.Session, evt); err != nil {
+		t.Fatalf("AppendEvent failed: %v", err)
+	}
+	if err := svc.AppendEvent(ctx, created.Session, &session.Event{LLMResponse: model.LLMResponse{Partial: true}}); err != nil {
+		t.Fatalf("AppendEvent of a partial event failed: %v", err)
+	}
+
+	stored, _ := store.LoadSession(ctx, "app", "u1", "s1")
+	if stored == nil || len(stored.Events) != 1 || stored.State["topic"] != "billing" {
+		t.Fatalf("expected the session written behind to the store, got %+v", stored)
+	}
+
+	if _, err := svc.Get(ctx, &session.GetRequest{AppName: "app", UserID: "u1", SessionID: "s1"}); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if stats := svc.Stats(); stats.Hits != 1 || stats.Misses != 0 {
+		t.Errorf("expected a cache hit, got %+v", stats)
+	}
+
+	t.Run("read through", func(t *testing.T) {
+		// NOTE: A new cache over the same store, as after a restart
+		restarted := newTestTiered(t, store)
+
+		resp, err := restarted.Get(ctx, &session.GetRequest{AppName: "app", UserID: "u1", SessionID: "s1"})
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if resp.Session.Events().Len() != 1 {
+			t.Errorf("expected 1 restored event, got %d", resp.Session.Events().Len())
+		}
+		if topic, _ := resp.Session.State().Get("topic"); topic != "billing" {
+			t.Errorf("expected topic=billing, got %v", topic)
+		}
+		if stats := restarted.Stats(); stats.Misses != 1 || stats.Restores != 1 {
+			t.Errorf("expected a restore, got %+v", stats)
+		}
+
+		if _, err := restarted.Get(ctx, &session.GetRequest{AppName: "app", UserID: "u1", SessionID: "missing"}); err == nil {
+			t.Error("expected an error for a missing session")
+		}
+	})
+
+	t.Run("list restores missing sessions", func(t *testing.T) {
+		restarted := newTestTiered(t, store)
+		if _, err := restarted.Create(ctx, &session.CreateRequest{AppName: "app", UserID: "u1", SessionID: "s2"}); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+
+		resp, err := restarted.List(ctx, &session.ListRequest{AppName: "app", UserID: "u1"})
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		var ids []string
+		for _, sess := range resp.Sessions {
+			ids = append(ids, sess.ID())
+		}
+		slices.Sort(ids)
+		if !slices.Equal(ids, []string{"s1", "s2"}) {
+			t.Errorf("expected s1 and s2, got %v", ids)
+		}
+	})
+
+	t.Run("cache errors are returned as is", func(t *testing.T) {
+		errLimited := errors.New("rate limited")
+		failing, err := NewTieredService(failingCache{importingCache{session.InMemoryService()}, errLimited}, store)
+		if err != nil {
+			t.Fatalf("NewTieredService failed: %v", err)
+		}
+
+		if _, err := failing.Get(ctx, &session.GetRequest{AppName: "app", UserID: "u1", SessionID: "s1"}); !errors.Is(err, errLimited) {
+			t.Errorf("expected the cache error, got %v", err)
+		}
+		if err := failing.AppendEvent(ctx, created.Session, session.NewEvent("inv-2")); !errors.Is(err, errLimited) {
+			t.Errorf("expected the cache error, got %v", err)
+		}
+		if stats := failing.Stats(); stats.Misses != 0 || stats.Restores != 0 {
+			t.Errorf("expected no restore, got %+v", stats)
+		}
+		if stored, _ := store.LoadSession(ctx, "app", "u1", "s1"); len(stored.Events) != 1 {
+			t.Errorf("expected the failed event not persisted, got %d events", len(stored.Events))
+		}
+	})
+
+	t.Run("store failures are not returned", func(t *testing.T) {
+		failing := newMapStore()
+		failing.err = errors.New("store down")
+		svc := newTestTiered(t, failing)
+
+		resp, err := svc.Create(ctx, &session.CreateRequest{AppName: "app", UserID: "u1"})
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if err := svc.AppendEvent(ctx, resp.Session, session.NewEvent("inv-1")); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+		if stats := svc.Stats(); stats.StoreFailures != 2 {
+			t.Errorf("expected 2 store failures, got %+v", stats)
+		}
+	})
+
+	if err := svc.Delete(ctx, &session.DeleteRequest{AppName: "app", UserID: "u1", SessionID: "s1"}); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if stored, _ := store.LoadSession(ctx, "app", "u1", "s1"); stored != nil {
+		t.Error("expected the session deleted from the store")
+	}
+}
@@ -0,0 +1,301 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"maps"
+	"slices"
+
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/log"
+	"google.golang.org/adk/session"
+	"google.golang.org/genai"
+)
+
+var (
+	_ session.Service = (*ViewService)(nil)
+	_ session.Session = (*viewSession)(nil)
+	_ Tagged          = (*viewSession)(nil)
+)
+
+// ErrUnknownViewRole is returned when reading sessions with a role that has no view.
+var ErrUnknownViewRole = errors.New("unknown view role")
+
+// ViewFilter decides how an event is shown in a view: it returns evt, a redacted
+// copy of it, or nil to hide it. It must not modify evt, which is the stored event.
+type ViewFilter func(evt *session.Event) *session.Event
+
+// viewRoleKey is the context key of the role reading sessions.
+type viewRoleKey struct{}
+
+// WithViewRole attaches the role of the caller reading sessions through a
+// ViewService, e.g. from the authenticated identity of an HTTP request.
+func WithViewRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, viewRoleKey{}, role)
+}
+
+// ViewRoleFromContext returns the role attached by WithViewRole.
+func ViewRoleFromContext(ctx context.Context) (string, bool) {
+	role, ok := ctx.Value(viewRoleKey{}).(string)
+	return role, ok && role != ""
+}
+
+// ViewConfig configures WithViews.
+type ViewConfig struct {
+	// Roles maps every role to the filter of its view. A role mapped to nil sees
+	// every event, e.g. "admin". Reading sessions with a role missing from Roles
+	// fails with ErrUnknownViewRole.
+	Roles map[string]ViewFilter
+
+	// Optional. Default is the filter of the reads without a role. Default: every
+	// event is visible.
+	Default ViewFilter
+
+	// Optional. Logger for logging. Falls back to `DiscardLog` if nil.
+	Logger log.Logger
+}
+
+// ViewService is a session.Service filtering the events of the sessions it reads
+// by the role of the caller, see WithViews.
+type ViewService struct {
+	next     session.Service
+	roles    map[string]ViewFilter
+	fallback ViewFilter
+	logger   log.Logger
+}
+
+// WithViews wraps svc so that Get and List return the sessions with the events the
+// role of the caller (see WithViewRole) may see, e.g. hiding the tool calls and
+// internal events from end users while admins see everything. Serve the REST API
+// and exports (e.g. dataset.FromService) from the ViewService, and run agents on
+// svc: an agent reading a filtered history would lose its own tool calls.
+//
+// Only the events are filtered: the state of the sessions is left as is.
+func WithViews(svc session.Service, cfg ViewConfig) (*ViewService, error) {
+	if svc == nil {
+		return nil, ErrNilService
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = discardlog.NewDiscardLog()
+	}
+
+	return &ViewService{
+		next:     svc,
+		roles:    maps.Clone(cfg.Roles),
+		fallback: cfg.Default,
+		logger:   cfg.Logger,
+	}, nil
+}
+
+// Create creates a session.
+func (s *ViewService) Create(ctx context.Context, req *session.CreateRequest) (*session.CreateResponse, error) {
+	return s.next.Create(ctx, req)
+}
+
+// Get gets a session, with the events visible to the role of the caller.
+func (s *ViewService) Get(ctx context.Context, req *session.GetRequest) (*session.GetResponse, error) {
+	resp, err := s.next.Get(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	sess, err := s.View(ctx, resp.Session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &session.GetResponse{Session: sess}, nil
+}
+
+// List lists sessions, with the events visible to the role of the caller.
+func (s *ViewService) List(ctx context.Context, req *session.ListRequest) (*session.ListResponse, error) {
+	resp, err := s.next.List(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]session.Session, 0, len(resp.Sessions))
+	for _, sess := range resp.Sessions {
+		viewed, err := s.View(ctx, sess)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, viewed)
+	}
+
+	return &session.ListResponse{Sessions: sessions}, nil
+}
+
+// AppendEvent appends an event to a session, read from this service or not.
+func (s *ViewService) AppendEvent(ctx context.Context, sess session.Session, evt *session.Event) error {
+	// NOTE: Services append to their own session types only
+	if v, ok := sess.(*viewSession); ok {
+		sess = v.Session
+	}
+	return s.next.AppendEvent(ctx, sess, evt)
+}
+
+// Delete deletes a session.
+func (s *ViewService) Delete(ctx context.Context, req *session.DeleteRequest) error {
+	return s.next.Delete(ctx, req)
+}
+
+// View returns sess with the events visible to the role of the caller, e.g. for a
+// session read from another service. Returns ErrUnknownViewRole if the role has
+// no view.
+func (s *ViewService) View(ctx context.Context, sess session.Session) (session.Session, error) {
+	if v, ok := sess.(*viewSession); ok {
+		sess = v.Session
+	}
+
+	filter, err := s.filter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if filter == nil {
+		return sess, nil
+	}
+
+	events := applyView(filter, sess.Events().All())
+
+	s.logger.Debugf("session viewed: session=%s, events=%d/%d", sess.ID(), len(events), sess.Events().Len())
+
+	return &viewSession{Session: sess, events: events}, nil
+}
+
+// FilterEvents returns the events visible to the role of the caller, e.g. the
+// events of a delta sync. Returns ErrUnknownViewRole if the role has no view.
+func (s *ViewService) FilterEvents(ctx context.Context, events []*session.Event) ([]*session.Event, error) {
+	filter, err := s.filter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if filter == nil {
+		return events, nil
+	}
+
+	return applyView(filter, slices.Values(events)), nil
+}
+
+// filter returns the filter of the role of the caller, nil if it sees every event.
+func (s *ViewService) filter(ctx context.Context) (ViewFilter, error) {
+	role, ok := ViewRoleFromContext(ctx)
+	if !ok {
+		return s.fallback, nil
+	}
+
+	filter, ok := s.roles[role]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownViewRole, role)
+	}
+	return filter, nil
+}
+
+// applyView returns the events kept by filter.
+func applyView(filter ViewFilter, events iter.Seq[*session.Event]) []*session.Event {
+	var kept []*session.Event
+	for evt := range events {
+		if viewed := filter(evt); viewed != nil {
+			kept = append(kept, viewed)
+		}
+	}
+	return kept
+}
+
+// viewSession is a session with the events of a view.
+type viewSession struct {
+	session.Session
+	events viewEvents
+}
+
+// Events returns the events of the view.
+func (s *viewSession) Events() session.Events { return s.events }
+
+// Tags returns the tags of the session.
+func (s *viewSession) Tags() map[string]string { return TagsOf(s.Session) }
+
+// viewEvents implements session.Events over the events of a view.
+type viewEvents []*session.Event
+
+func (e viewEvents) All() iter.Seq[*session.Event] { return slices.Values(e) }
+
+func (e viewEvents) Len() int { return len(e) }
+
+func (e viewEvents) At(i int) *session.Event { return e[i] }
+
+// ChainViews returns a filter applying filters in order, hiding the events any of
+// them hides.
+func ChainViews(filters ...ViewFilter) ViewFilter {
+	return func(evt *session.Event) *session.Event {
+		for _, filter := range filters {
+			if evt = filter(evt); evt == nil {
+				return nil
+			}
+		}
+		return evt
+	}
+}
+
+// HideAuthors hides the events of authors, e.g. internal agents or system events.
+func HideAuthors(authors ...string) ViewFilter {
+	return func(evt *session.Event) *session.Event {
+		if slices.Contains(authors, evt.Author) {
+			return nil
+		}
+		return evt
+	}
+}
+
+// HideToolCalls removes the function calls and responses from the events, hiding
+// the events left without content, e.g. tool results.
+func HideToolCalls(evt *session.Event) *session.Event {
+	redacted := withoutParts(evt, func(p *genai.Part) bool {
+		return p.FunctionCall != nil || p.FunctionResponse != nil
+	})
+	if redacted != nil && redacted != evt {
+		redacted.LongRunningToolIDs = nil
+	}
+	return redacted
+}
+
+// HideThoughts removes the thoughts of the model from the events, hiding the
+// events left without content.
+func HideThoughts(evt *session.Event) *session.Event {
+	return withoutParts(evt, func(p *genai.Part) bool { return p.Thought })
+}
+
+// HideStateDelta removes the state changes from the events.
+func HideStateDelta(evt *session.Event) *session.Event {
+	if len(evt.Actions.StateDelta) == 0 {
+		return evt
+	}
+
+	redacted := *evt
+	redacted.Actions.StateDelta = nil
+	return &redacted
+}
+
+// withoutParts returns a copy of evt without the content parts matching drop, nil
+// if no part is left. Events without content are returned as is.
+func withoutParts(evt *session.Event, drop func(*genai.Part) bool) *session.Event {
+	dropped := func(p *genai.Part) bool { return p != nil && drop(p) }
+	if evt.Content == nil || !slices.ContainsFunc(evt.Content.Parts, dropped) {
+		return evt
+	}
+
+	parts := make([]*genai.Part, 0, len(evt.Content.Parts))
+	for _, p := range evt.Content.Parts {
+		if p != nil && !drop(p) {
+			parts = append(parts, p)
+		}
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+
+	redacted := *evt
+	redacted.Content = &genai.Content{Role: evt.Content.Role, Parts: parts}
+	return &redacted
+}
@@ -0,0 +1,125 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/adk/model"
+	"google.golang.org/adk/session"
+	"google.golang.org/genai"
+)
+
+// viewTestEvents appends a user message, a tool call, its result and the answer.
+func viewTestEvents(t *testing.T, svc session.Service, sess session.Session) {
+	t.Helper()
+
+	events := []*session.Event{
+		{Author: "user", LLMResponse: model.LLMResponse{Content: genai.NewContentFromText("weather?", "user")}},
+		{Author: "agent", LLMResponse: model.LLMResponse{Content: &genai.Content{Role: "model", Parts: []*genai.Part{
+			{Text: "the user wants the weather", Thought: true},
+			genai.NewPartFromText("checking the weather"),
+			genai.NewPartFromFunctionCall("weather", map[string]any{"city": "Paris"}),
+		}}}},
+		{Author: "agent", LLMResponse: model.LLMResponse{Content: &genai.Content{Role: "user", Parts: []*genai.Part{
+			genai.NewPartFromFunctionResponse("weather", map[string]any{"temp": 21}),
+		}}}},
+		{Author: "agent", LLMResponse: model.LLMResponse{Content: genai.NewContentFromText("21°C", "model")}},
+	}
+	for i, evt := range events {
+		evt.ID = string(rune('a' + i))
+		if err := svc.AppendEvent(context.Background(), sess, evt); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+	}
+}
+
+func TestWithViews(t *testing.T) {
+	ctx := context.Background()
+
+	if _, err := WithViews(nil, ViewConfig{}); !errors.Is(err, ErrNilService) {
+		t.Fatalf("expected ErrNilService, got %v", err)
+	}
+
+	inner := session.InMemoryService()
+	svc, err := WithViews(inner, ViewConfig{
+		Roles: map[string]ViewFilter{
+			"admin": nil,
+			"user":  ChainViews(HideThoughts, HideToolCalls),
+		},
+		Default: HideAuthors("user"),
+	})
+	if err != nil {
+		t.Fatalf("WithViews failed: %v", err)
+	}
+
+	created, err := svc.Create(ctx, &session.CreateRequest{AppName: "app", UserID: "u1"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	viewTestEvents(t, svc, created.Session)
+
+	get := func(ctx context.Context) session.Session {
+		t.Helper()
+		resp, err := svc.Get(ctx, &session.GetRequest{AppName: "app", UserID: "u1", SessionID: created.Session.ID()})
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		return resp.Session
+	}
+
+	t.Run("admin sees every event", func(t *testing.T) {
+		if n := get(WithViewRole(ctx, "admin")).Events().Len(); n != 4 {
+			t.Errorf("expected 4 events, got %d", n)
+		}
+	})
+
+	t.Run("user sees the conversation", func(t *testing.T) {
+		events := get(WithViewRole(ctx, "user")).Events()
+		if events.Len() != 3 {
+			t.Fatalf("expected 3 events, got %d", events.Len())
+		}
+
+		parts := events.At(1).Content.Parts
+		if len(parts) != 1 || parts[0].Text != "checking the weather" {
+			t.Errorf("expected the thought and the call to be removed, got %d parts", len(parts))
+		}
+	})
+
+	t.Run("default view", func(t *testing.T) {
+		events := get(ctx).Events()
+		if events.Len() != 3 || events.At(0).Author != "agent" {
+			t.Errorf("expected the user events to be hidden, got %d events", events.Len())
+		}
+	})
+
+	t.Run("unknown role", func(t *testing.T) {
+		_, err := svc.Get(WithViewRole(ctx, "guest"), &session.GetRequest{
+			AppName: "app", UserID: "u1", SessionID: created.Session.ID(),
+		})
+		if !errors.Is(err, ErrUnknownViewRole) {
+			t.Errorf("expected ErrUnknownViewRole, got %v", err)
+		}
+	})
+
+	t.Run("stored events are left alone", func(t *testing.T) {
+		resp, err := inner.Get(ctx, &session.GetRequest{AppName: "app", UserID: "u1", SessionID: created.Session.ID()})
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if n := len(resp.Session.Events().At(1).Content.Parts); n != 3 {
+			t.Errorf("expected the stored event to keep its 3 parts, got %d", n)
+		}
+	})
+
+	t.Run("append to a viewed session", func(t *testing.T) {
+		viewed := get(WithViewRole(ctx, "user"))
+		err := svc.AppendEvent(ctx, viewed, &session.Event{ID: "e", Author: "user"})
+		if err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+		if n := get(WithViewRole(ctx, "admin")).Events().Len(); n != 5 {
+			t.Errorf("expected 5 events, got %d", n)
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package image
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/adk/agent"
+	"google.golang.org/adk/agent/llmagent"
+	"google.golang.org/adk/model"
+	"google.golang.org/adk/tool"
+	"google.golang.org/adk/tool/loadartifactstool"
+)
+
+// AgentConfig configures the agent of NewAgent.
+type AgentConfig struct {
+	// Generator generates the images.
+	Generator *Generator
+
+	// Model is the LLM of the agent.
+	Model model.LLM
+
+	// Optional. Name of the agent. Default: "image_generator".
+	Name string
+
+	// Optional. Instruction of the agent. Default: generate images from the user's prompts.
+	Instruction string
+}
+
+// NewAgent creates an agent that generates images from the user's prompts, saves
+// them to the artifact service and can load them back to answer questions about them.
+func NewAgent(cfg AgentConfig) (agent.Agent, error) {
+	if cfg.Generator == nil {
+		return nil, errors.New("generator cannot be nil")
+	}
+	if cfg.Model == nil {
+		return nil, errors.New("model cannot be nil")
+	}
+	if cfg.Name == "" {
+		cfg.Name = "image_generator"
+	}
+	if cfg.Instruction == "" {
+		cfg.Instruction = "You are an agent whose job is to generate or edit an image based on the user's prompt. " +
+			"If generation fails, tell the user why."
+	}
+
+	generateTool, err := cfg.Generator.Tool()
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := llmagent.New(llmagent.Config{
+		Name:        cfg.Name,
+		Model:       cfg.Model,
+		Description: "Agent to generate pictures, answers questions about it and saves it if asked.",
+		Instruction: cfg.Instruction,
+		Tools: []tool.Tool{
+			generateTool,
+			loadartifactstool.New(),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create image generator agent: %w", err)
+	}
+
+	return a, nil
+}
@@ -0,0 +1,282 @@
+// Package image provides an image generation tool, and an agent built around it,
+// that saves the generated images and their generation metadata to the artifact
+// service of the session.
+package image
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bytedance/sonic"
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/log"
+	"google.golang.org/adk/tool"
+	"google.golang.org/adk/tool/functiontool"
+	"google.golang.org/genai"
+)
+
+const (
+	defaultModel    = "imagen-3.0-generate-002"
+	defaultMIMEType = "image/png"
+	defaultToolName = "generate_image"
+
+	// MetadataSuffix is appended to the filename of an image to name the artifact
+	// holding its generation metadata.
+	MetadataSuffix = ".metadata.json"
+)
+
+var (
+	ErrEmptyPrompt   = errors.New("prompt cannot be empty")
+	ErrEmptyFilename = errors.New("filename cannot be empty")
+	ErrMissingVertex = errors.New("vertex AI backend requires project and location")
+
+	// ErrNoImage is returned when the model returns no image, e.g. when every
+	// image was filtered out. The error wraps the filter reason, if any.
+	ErrNoImage = errors.New("model returned no image")
+)
+
+// ImageModel generates images. It is implemented by genai.Models.
+type ImageModel interface {
+	GenerateImages(
+		ctx context.Context,
+		model, prompt string,
+		config *genai.GenerateImagesConfig,
+	) (*genai.GenerateImagesResponse, error)
+}
+
+// Config configures a Generator.
+type Config struct {
+	// Optional. Images generates the images. Falls back to the Models of a genai
+	// client built from Backend, APIKey, Project and Location.
+	Images ImageModel
+
+	// Optional. Backend of the genai client. BackendUnspecified follows the
+	// GOOGLE_GENAI_USE_VERTEXAI environment variable.
+	Backend genai.Backend
+
+	// Optional. APIKey for the Gemini API backend.
+	// Falls back to GOOGLE_API_KEY / GEMINI_API_KEY environment variables if empty.
+	APIKey string
+
+	// Optional. Project and Location of the Vertex AI backend.
+	// Fall back to GOOGLE_CLOUD_PROJECT / GOOGLE_CLOUD_LOCATION environment variables if empty.
+	Project  string
+	Location string
+
+	// Optional. Model is the image model. Default: "imagen-3.0-generate-002".
+	Model string
+
+	// Optional. AspectRatio of the images (e.g. "1:1", "16:9"). Default: model default.
+	AspectRatio string
+
+	// Optional. MIMEType of the images. Default: "image/png".
+	MIMEType string
+
+	// Optional. ToolName is the name of the tool. Default: "generate_image".
+	ToolName string
+
+	// Optional. Logger for logging. Falls back to `DiscardLog` if nil.
+	Logger log.Logger
+}
+
+// Generator generates images and saves them as artifacts.
+type Generator struct {
+	log.Logger
+
+	images      ImageModel
+	model       string
+	aspectRatio string
+	mimeType    string
+	toolName    string
+}
+
+// Image is a generated image.
+type Image struct {
+	Data     []byte
+	MIMEType string
+
+	// EnhancedPrompt is the prompt rewritten by the model, if it did.
+	EnhancedPrompt string
+}
+
+// Metadata describes how an image artifact was generated. It is saved as JSON
+// next to the image, under its filename with MetadataSuffix.
+type Metadata struct {
+	Filename       string    `json:"filename"`
+	Version        int64     `json:"version"`
+	Prompt         string    `json:"prompt"`
+	EnhancedPrompt string    `json:"enhanced_prompt,omitempty"`
+	Model          string    `json:"model"`
+	AspectRatio    string    `json:"aspect_ratio,omitempty"`
+	MIMEType       string    `json:"mime_type"`
+	Bytes          int       `json:"bytes"`
+	InvocationID   string    `json:"invocation_id"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// New creates a Generator with the specified configuration.
+func New(ctx context.Context, cfg Config) (*Generator, error) {
+	if cfg.Logger == nil {
+		cfg.Logger = discardlog.NewDiscardLog()
+	}
+	if cfg.Model == "" {
+		cfg.Model = defaultModel
+	}
+	if cfg.MIMEType == "" {
+		cfg.MIMEType = defaultMIMEType
+	}
+	if cfg.ToolName == "" {
+		cfg.ToolName = defaultToolName
+	}
+
+	if cfg.Images == nil {
+		client, err := genai.NewClient(ctx, &genai.ClientConfig{
+			Backend:  cfg.Backend,
+			APIKey:   cfg.APIKey,
+			Project:  cfg.Project,
+			Location: cfg.Location,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create genai client: %w", err)
+		}
+		if cc := client.ClientConfig(); cc.Backend == genai.BackendVertexAI &&
+			(cc.Project == "" || cc.Location == "") {
+			return nil, ErrMissingVertex
+		}
+		cfg.Images = client.Models
+	}
+
+	cfg.Logger.Infof("image generator created: model=%s, tool=%s", cfg.Model, cfg.ToolName)
+
+	return &Generator{
+		Logger:      cfg.Logger,
+		images:      cfg.Images,
+		model:       cfg.Model,
+		aspectRatio: cfg.AspectRatio,
+		mimeType:    cfg.MIMEType,
+		toolName:    cfg.ToolName,
+	}, nil
+}
+
+// Generate generates an image from prompt.
+func (g *Generator) Generate(ctx context.Context, prompt string) (*Image, error) {
+	if prompt == "" {
+		return nil, ErrEmptyPrompt
+	}
+
+	resp, err := g.images.GenerateImages(ctx, g.model, prompt, &genai.GenerateImagesConfig{
+		NumberOfImages:   1,
+		AspectRatio:      g.aspectRatio,
+		OutputMIMEType:   g.mimeType,
+		IncludeRAIReason: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate image: %w", err)
+	}
+
+	for _, generated := range resp.GeneratedImages {
+		if generated == nil || generated.Image == nil || len(generated.Image.ImageBytes) == 0 {
+			continue
+		}
+
+		mimeType := generated.Image.MIMEType
+		if mimeType == "" {
+			mimeType = g.mimeType
+		}
+		return &Image{
+			Data:           generated.Image.ImageBytes,
+			MIMEType:       mimeType,
+			EnhancedPrompt: generated.EnhancedPrompt,
+		}, nil
+	}
+
+	for _, generated := range resp.GeneratedImages {
+		if generated != nil && generated.RAIFilteredReason != "" {
+			return nil, fmt.Errorf("%w: filtered: %s", ErrNoImage, generated.RAIFilteredReason)
+		}
+	}
+	return nil, ErrNoImage
+}
+
+// generateInput defines the input parameters for the image generation tool.
+type generateInput struct {
+	// Prompt is the text description of the image to generate.
+	Prompt string `json:"prompt"`
+	// Filename is the name under which the generated image will be saved.
+	Filename string `json:"filename"`
+}
+
+// generateResult contains the result of an image generation.
+type generateResult struct {
+	Filename         string `json:"filename"`
+	Version          int64  `json:"version"`
+	MIMEType         string `json:"mime_type"`
+	MetadataFilename string `json:"metadata_filename"`
+	EnhancedPrompt   string `json:"enhanced_prompt,omitempty"`
+}
+
+// Tool returns the image generation tool. It saves the image and its Metadata to
+// the artifact service; errors are reported to the model.
+func (g *Generator) Tool() (tool.Tool, error) {
+	t, err := functiontool.New(functiontool.Config{
+		Name: g.toolName,
+		Description: "Generates an image from a text prompt and saves it in the " +
+			"artifact service under the given filename.",
+	}, g.generate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s tool: %w", g.toolName, err)
+	}
+
+	return t, nil
+}
+
+func (g *Generator) generate(ctx tool.Context, input generateInput) (generateResult, error) {
+	if input.Filename == "" {
+		return generateResult{}, ErrEmptyFilename
+	}
+
+	img, err := g.Generate(ctx, input.Prompt)
+	if err != nil {
+		g.Warnf("image generation failed: filename=%s, err=%v", input.Filename, err)
+		return generateResult{}, err
+	}
+
+	saved, err := ctx.Artifacts().Save(ctx, input.Filename, genai.NewPartFromBytes(img.Data, img.MIMEType))
+	if err != nil {
+		return generateResult{}, fmt.Errorf("failed to save image artifact: %w", err)
+	}
+
+	meta := Metadata{
+		Filename:       input.Filename,
+		Version:        saved.Version,
+		Prompt:         input.Prompt,
+		EnhancedPrompt: img.EnhancedPrompt,
+		Model:          g.model,
+		AspectRatio:    g.aspectRatio,
+		MIMEType:       img.MIMEType,
+		Bytes:          len(img.Data),
+		InvocationID:   ctx.InvocationID(),
+		CreatedAt:      time.Now(),
+	}
+	data, err := sonic.Marshal(meta)
+	if err != nil {
+		return generateResult{}, fmt.Errorf("failed to marshal image metadata: %w", err)
+	}
+
+	metaName := input.Filename + MetadataSuffix
+	if _, err := ctx.Artifacts().Save(ctx, metaName, genai.NewPartFromBytes(data, "application/json")); err != nil {
+		return generateResult{}, fmt.Errorf("failed to save image metadata artifact: %w", err)
+	}
+
+	g.Infof("image generated: filename=%s, version=%d, bytes=%d", input.Filename, saved.Version, len(img.Data))
+
+	return generateResult{
+		Filename:         input.Filename,
+		Version:          saved.Version,
+		MIMEType:         img.MIMEType,
+		MetadataFilename: metaName,
+		EnhancedPrompt:   img.EnhancedPrompt,
+	}, nil
+}
@@ -0,0 +1,150 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bytedance/sonic"
+	"google.golang.org/adk/agent"
+	"google.golang.org/adk/artifact"
+	"google.golang.org/adk/tool"
+	"google.golang.org/genai"
+)
+
+type fakeImages struct {
+	resp *genai.GenerateImagesResponse
+	err  error
+
+	model  string
+	config *genai.GenerateImagesConfig
+}
+
+func (f *fakeImages) GenerateImages(
+	_ context.Context,
+	model, _ string,
+	config *genai.GenerateImagesConfig,
+) (*genai.GenerateImagesResponse, error) {
+	f.model, f.config = model, config
+	return f.resp, f.err
+}
+
+// fakeArtifacts keeps the saved parts, with one version per save.
+type fakeArtifacts struct {
+	agent.Artifacts
+
+	saved map[string][]*genai.Part
+}
+
+func (f *fakeArtifacts) Save(_ context.Context, name string, data *genai.Part) (*artifact.SaveResponse, error) {
+	f.saved[name] = append(f.saved[name], data)
+	return &artifact.SaveResponse{Version: int64(len(f.saved[name]))}, nil
+}
+
+type fakeToolContext struct {
+	tool.Context
+
+	artifacts *fakeArtifacts
+}
+
+func (f *fakeToolContext) Artifacts() agent.Artifacts { return f.artifacts }
+func (f *fakeToolContext) InvocationID() string       { return "inv1" }
+
+func newTestGenerator(t *testing.T, images ImageModel) *Generator {
+	t.Helper()
+
+	g, err := New(context.Background(), Config{Images: images, AspectRatio: "16:9"})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return g
+}
+
+func TestGenerate(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("returns the first image", func(t *testing.T) {
+		images := &fakeImages{resp: &genai.GenerateImagesResponse{
+			GeneratedImages: []*genai.GeneratedImage{
+				{RAIFilteredReason: "filtered"},
+				{Image: &genai.Image{ImageBytes: []byte("png")}, EnhancedPrompt: "a red cat"},
+			},
+		}}
+		img, err := newTestGenerator(t, images).Generate(ctx, "cat")
+		if err != nil {
+			t.Fatalf("Generate failed: %v", err)
+		}
+		if string(img.Data) != "png" || img.MIMEType != "image/png" || img.EnhancedPrompt != "a red cat" {
+			t.Errorf("unexpected image: %+v", img)
+		}
+		if images.model != defaultModel || images.config.AspectRatio != "16:9" {
+			t.Errorf("unexpected request: model=%s, config=%+v", images.model, images.config)
+		}
+	})
+
+	t.Run("typed errors", func(t *testing.T) {
+		filtered := &fakeImages{resp: &genai.GenerateImagesResponse{
+			GeneratedImages: []*genai.GeneratedImage{{RAIFilteredReason: "unsafe"}},
+		}}
+		if _, err := newTestGenerator(t, filtered).Generate(ctx, "cat"); !errors.Is(err, ErrNoImage) {
+			t.Errorf("expected ErrNoImage, got %v", err)
+		}
+
+		apiErr := errors.New("quota exceeded")
+		failing := &fakeImages{err: apiErr}
+		if _, err := newTestGenerator(t, failing).Generate(ctx, "cat"); !errors.Is(err, apiErr) {
+			t.Errorf("expected the API error, got %v", err)
+		}
+
+		if _, err := newTestGenerator(t, failing).Generate(ctx, ""); !errors.Is(err, ErrEmptyPrompt) {
+			t.Errorf("expected ErrEmptyPrompt, got %v", err)
+		}
+	})
+}
+
+func TestGenerateTool(t *testing.T) {
+	images := &fakeImages{resp: &genai.GenerateImagesResponse{
+		GeneratedImages: []*genai.GeneratedImage{{Image: &genai.Image{ImageBytes: []byte("png")}}},
+	}}
+	g := newTestGenerator(t, images)
+	ctx := &fakeToolContext{artifacts: &fakeArtifacts{saved: make(map[string][]*genai.Part)}}
+
+	if _, err := g.generate(ctx, generateInput{Prompt: "cat"}); !errors.Is(err, ErrEmptyFilename) {
+		t.Errorf("expected ErrEmptyFilename, got %v", err)
+	}
+
+	res, err := g.generate(ctx, generateInput{Prompt: "cat", Filename: "cat.png"})
+	if err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	if res.Filename != "cat.png" || res.Version != 1 || res.MetadataFilename != "cat.png"+MetadataSuffix {
+		t.Errorf("unexpected result: %+v", res)
+	}
+
+	saved := ctx.artifacts.saved
+	if len(saved["cat.png"]) != 1 || string(saved["cat.png"][0].InlineData.Data) != "png" {
+		t.Fatalf("expected the image artifact, got %v", saved)
+	}
+
+	metaParts := saved["cat.png"+MetadataSuffix]
+	if len(metaParts) != 1 {
+		t.Fatalf("expected the metadata artifact, got %v", saved)
+	}
+	var meta Metadata
+	if err := sonic.Unmarshal(metaParts[0].InlineData.Data, &meta); err != nil {
+		t.Fatalf("failed to unmarshal metadata: %v", err)
+	}
+	if meta.Prompt != "cat" || meta.Model != defaultModel || meta.Version != 1 ||
+		meta.Bytes != 3 || meta.InvocationID != "inv1" {
+		t.Errorf("unexpected metadata: %+v", meta)
+	}
+
+	// Failures are returned to the model instead of a silent "fail" status
+	images.resp, images.err = nil, errors.New("quota exceeded")
+	if _, err := g.generate(ctx, generateInput{Prompt: "dog", Filename: "dog.png"}); err == nil {
+		t.Error("expected the generation error")
+	}
+	if _, ok := saved["dog.png"]; ok {
+		t.Error("expected nothing saved on failure")
+	}
+}
@@ -0,0 +1,259 @@
+// Package voice bridges live audio to agents: user speech is transcribed by a
+// pluggable SpeechToText, fed to the runner as text, and the agent replies are
+// synthesized by a TextToSpeech and streamed back. The transcripts are the session
+// events; the audio of both sides is saved as artifacts.
+package voice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"strings"
+
+	"github.com/google/uuid"
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/log"
+	"google.golang.org/adk/agent"
+	"google.golang.org/adk/artifact"
+	"google.golang.org/adk/runner"
+	"google.golang.org/adk/session"
+	"google.golang.org/genai"
+)
+
+// defaultMaxAudioBytes caps the audio of one utterance.
+const defaultMaxAudioBytes = 10 << 20
+
+var (
+	// ErrNoSpeech is returned when an utterance transcribes to nothing.
+	ErrNoSpeech = errors.New("no speech recognized")
+
+	// ErrAudioTooLarge is returned when an utterance exceeds MaxAudioBytes.
+	ErrAudioTooLarge = errors.New("audio too large")
+)
+
+// RunFunc runs the agent on a user message and yields its events.
+type RunFunc func(
+	ctx context.Context,
+	userID, sessionID string,
+	msg *genai.Content,
+) iter.Seq2[*session.Event, error]
+
+// RunnerFunc returns a RunFunc running r with cfg.
+func RunnerFunc(r *runner.Runner, cfg agent.RunConfig) RunFunc {
+	return func(
+		ctx context.Context,
+		userID, sessionID string,
+		msg *genai.Content,
+	) iter.Seq2[*session.Event, error] {
+		return r.Run(ctx, userID, sessionID, msg, cfg)
+	}
+}
+
+// Config configures a Bridge.
+type Config struct {
+	// AppName of the sessions, used to save artifacts.
+	AppName string
+
+	// Run runs the agent. See RunnerFunc.
+	Run RunFunc
+
+	// STT transcribes the user audio.
+	STT SpeechToText
+
+	// TTS synthesizes the agent replies.
+	TTS TextToSpeech
+
+	// Optional. Artifacts saves the audio of every turn. Audio is not persisted if nil.
+	Artifacts artifact.Service
+
+	// Optional. MaxAudioBytes is the largest utterance accepted. Default: 10 MiB.
+	MaxAudioBytes int
+
+	// Optional. Logger for logging. Falls back to `DiscardLog` if nil.
+	Logger log.Logger
+}
+
+// OutputType is the kind of an Output.
+type OutputType string
+
+// Output types, in the order of a turn: the user transcript, then a transcript and
+// its audio for every agent reply, then turn_complete.
+const (
+	OutputTranscript   OutputType = "transcript"
+	OutputAudio        OutputType = "audio"
+	OutputTurnComplete OutputType = "turn_complete"
+
+	// OutputError reports a failed turn to a WebSocket client; the connection stays open.
+	OutputError OutputType = "error"
+)
+
+// Output is one message of a voice turn sent back to the client.
+type Output struct {
+	Type OutputType `json:"type"`
+
+	// Author is "user" for the user transcript, the agent name otherwise.
+	Author string `json:"author,omitempty"`
+	Text   string `json:"text,omitempty"`
+
+	// Audio is the synthesized speech of OutputAudio, sent separately from the JSON.
+	Audio    []byte `json:"-"`
+	MIMEType string `json:"mimeType,omitempty"`
+
+	// Artifact and Version identify the saved audio, if any.
+	Artifact string `json:"artifact,omitempty"`
+	Version  int64  `json:"version,omitempty"`
+}
+
+// Bridge runs voice turns: speech in, speech out.
+type Bridge struct {
+	log.Logger
+
+	appName       string
+	run           RunFunc
+	stt           SpeechToText
+	tts           TextToSpeech
+	artifacts     artifact.Service
+	maxAudioBytes int
+}
+
+// New creates a Bridge with the specified configuration.
+func New(cfg Config) (*Bridge, error) {
+	if cfg.Run == nil {
+		return nil, errors.New("run cannot be nil")
+	}
+	if cfg.STT == nil {
+		return nil, errors.New("speech-to-text cannot be nil")
+	}
+	if cfg.TTS == nil {
+		return nil, errors.New("text-to-speech cannot be nil")
+	}
+	if cfg.MaxAudioBytes <= 0 {
+		cfg.MaxAudioBytes = defaultMaxAudioBytes
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = discardlog.NewDiscardLog()
+	}
+
+	return &Bridge{
+		Logger:        cfg.Logger,
+		appName:       cfg.AppName,
+		run:           cfg.Run,
+		stt:           cfg.STT,
+		tts:           cfg.TTS,
+		artifacts:     cfg.Artifacts,
+		maxAudioBytes: cfg.MaxAudioBytes,
+	}, nil
+}
+
+// Turn transcribes one user utterance, runs the agent on it and emits the
+// transcripts and synthesized replies as they are produced. It stops at the first
+// error of emit.
+func (b *Bridge) Turn(
+	ctx context.Context,
+	userID, sessionID string,
+	audio []byte,
+	mimeType string,
+	emit func(Output) error,
+) error {
+	if len(audio) > b.maxAudioBytes {
+		return fmt.Errorf("%w: %d bytes, max %d", ErrAudioTooLarge, len(audio), b.maxAudioBytes)
+	}
+
+	turnID := uuid.NewString()[:8]
+
+	text, err := b.stt.Transcribe(ctx, audio, mimeType)
+	if err != nil {
+		return fmt.Errorf("failed to transcribe audio: %w", err)
+	}
+	if text == "" {
+		return ErrNoSpeech
+	}
+
+	name := fmt.Sprintf("voice-%s-user%s", turnID, audioExtension(mimeType))
+
+	in := Output{Type: OutputTranscript, Author: "user", Text: text, MIMEType: mimeType}
+	in.Artifact, in.Version = b.saveAudio(ctx, userID, sessionID, name, audio, mimeType)
+	if err := emit(in); err != nil {
+		return err
+	}
+
+	replies := 0
+	for evt, err := range b.run(ctx, userID, sessionID, genai.NewContentFromText(text, genai.RoleUser)) {
+		if err != nil {
+			return fmt.Errorf("failed to run agent: %w", err)
+		}
+		if evt == nil || evt.Partial || evt.Author == "user" {
+			continue
+		}
+
+		reply := replyText(evt.Content)
+		if reply == "" {
+			continue
+		}
+		if err := emit(Output{Type: OutputTranscript, Author: evt.Author, Text: reply}); err != nil {
+			return err
+		}
+
+		speech, speechType, err := b.tts.Synthesize(ctx, reply)
+		if err != nil {
+			return fmt.Errorf("failed to synthesize reply: %w", err)
+		}
+
+		replies++
+		name := fmt.Sprintf("voice-%s-agent-%d%s", turnID, replies, audioExtension(speechType))
+
+		out := Output{Type: OutputAudio, Author: evt.Author, Audio: speech, MIMEType: speechType}
+		out.Artifact, out.Version = b.saveAudio(ctx, userID, sessionID, name, speech, speechType)
+		if err := emit(out); err != nil {
+			return err
+		}
+	}
+
+	b.Debugf("voice turn completed: session=%s, turn=%s, replies=%d", sessionID, turnID, replies)
+
+	return emit(Output{Type: OutputTurnComplete})
+}
+
+// saveAudio saves audio as an artifact of the session. Failures are logged, the
+// turn goes on without a saved artifact.
+func (b *Bridge) saveAudio(
+	ctx context.Context,
+	userID, sessionID, name string,
+	audio []byte,
+	mimeType string,
+) (string, int64) {
+	if b.artifacts == nil {
+		return "", 0
+	}
+
+	resp, err := b.artifacts.Save(ctx, &artifact.SaveRequest{
+		AppName:   b.appName,
+		UserID:    userID,
+		SessionID: sessionID,
+		FileName:  name,
+		Part:      genai.NewPartFromBytes(audio, mimeType),
+	})
+	if err != nil {
+		b.Warnf("failed to save voice artifact %s: %v", name, err)
+		return "", 0
+	}
+
+	return name, resp.Version
+}
+
+// replyText concatenates the non-thought text parts of c.
+func replyText(c *genai.Content) string {
+	if c == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, p := range c.Parts {
+		if p != nil && p.Text != "" && !p.Thought {
+			sb.WriteString(p.Text)
+		}
+	}
+
+	return strings.TrimSpace(sb.String())
+}
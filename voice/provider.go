@@ -0,0 +1,259 @@
+package voice
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	discardlog "github.com/kydenul/k-adk/internal/discard_log"
+	"github.com/kydenul/log"
+)
+
+// SpeechToText transcribes audio.
+type SpeechToText interface {
+	Transcribe(ctx context.Context, audio []byte, mimeType string) (string, error)
+}
+
+// TextToSpeech synthesizes speech. It returns the audio and its MIME type.
+type TextToSpeech interface {
+	Synthesize(ctx context.Context, text string) (audio []byte, mimeType string, err error)
+}
+
+// OpenAIConfig holds configuration for the OpenAI-compatible speech providers.
+type OpenAIConfig struct {
+	// e.g., "https://api.openai.com/v1", "http://localhost:8000/v1"
+	BaseURL string
+
+	// Optional. not required for local models
+	APIKey string
+
+	// e.g., "whisper-1", "gpt-4o-mini-transcribe" (STT); "tts-1", "gpt-4o-mini-tts" (TTS)
+	Model string
+
+	// Optional. TTS voice. Default: "alloy".
+	Voice string
+
+	// Optional. TTS audio format: "mp3", "opus", "aac", "flac", "wav" or "pcm".
+	// Default: "mp3".
+	Format string
+
+	// Optional. STT language (ISO-639-1), improves accuracy and latency.
+	Language string
+
+	// HTTPClient allows customizing the HTTP client used for requests.
+	// If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+
+	// Optional. Logger for logging. Falls back to DiscardLog if nil.
+	Logger log.Logger
+}
+
+func (cfg *OpenAIConfig) defaults() {
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = discardlog.NewDiscardLog()
+	}
+}
+
+// OpenAISpeechToText implements SpeechToText with the OpenAI transcription API
+// (/audio/transcriptions), also served by faster-whisper-server, vLLM and LocalAI.
+type OpenAISpeechToText struct {
+	log.Logger
+
+	cfg OpenAIConfig
+}
+
+// NewOpenAISpeechToText creates a SpeechToText using the OpenAI-compatible API.
+func NewOpenAISpeechToText(cfg OpenAIConfig) *OpenAISpeechToText {
+	cfg.defaults()
+	cfg.Logger.Infof("speech-to-text created: model=%s, baseURL=%s", cfg.Model, cfg.BaseURL)
+
+	return &OpenAISpeechToText{Logger: cfg.Logger, cfg: cfg}
+}
+
+// Transcribe transcribes audio, whose MIME type selects the uploaded file extension.
+func (s *OpenAISpeechToText) Transcribe(
+	ctx context.Context,
+	audio []byte,
+	mimeType string,
+) (string, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	fields := map[string]string{"model": s.cfg.Model, "response_format": "json"}
+	if s.cfg.Language != "" {
+		fields["language"] = s.cfg.Language
+	}
+	for k, v := range fields {
+		if err := mw.WriteField(k, v); err != nil {
+			return "", fmt.Errorf("failed to write form field: %w", err)
+		}
+	}
+
+	fw, err := mw.CreateFormFile("file", "audio"+audioExtension(mimeType))
+	if err != nil {
+		return "", fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := fw.Write(audio); err != nil {
+		return "", fmt.Errorf("failed to write audio: %w", err)
+	}
+	if err := mw.Close(); err != nil {
+		return "", fmt.Errorf("failed to close form: %w", err)
+	}
+
+	respBody, err := s.cfg.do(ctx, "/audio/transcriptions", mw.FormDataContentType(), &body)
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := sonic.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	s.Debugf("audio transcribed: bytes=%d, chars=%d", len(audio), len(result.Text))
+
+	return strings.TrimSpace(result.Text), nil
+}
+
+// OpenAITextToSpeech implements TextToSpeech with the OpenAI speech API
+// (/audio/speech), also served by Kokoro-FastAPI, openedai-speech and LocalAI.
+type OpenAITextToSpeech struct {
+	log.Logger
+
+	cfg OpenAIConfig
+}
+
+// NewOpenAITextToSpeech creates a TextToSpeech using the OpenAI-compatible API.
+func NewOpenAITextToSpeech(cfg OpenAIConfig) *OpenAITextToSpeech {
+	cfg.defaults()
+	if cfg.Voice == "" {
+		cfg.Voice = "alloy"
+	}
+	if cfg.Format == "" {
+		cfg.Format = "mp3"
+	}
+	cfg.Logger.Infof("text-to-speech created: model=%s, voice=%s, baseURL=%s",
+		cfg.Model, cfg.Voice, cfg.BaseURL)
+
+	return &OpenAITextToSpeech{Logger: cfg.Logger, cfg: cfg}
+}
+
+// Synthesize synthesizes text in the configured voice and format.
+func (t *OpenAITextToSpeech) Synthesize(
+	ctx context.Context,
+	text string,
+) ([]byte, string, error) {
+	jsonBody, err := sonic.Marshal(map[string]any{
+		"model":           t.cfg.Model,
+		"input":           text,
+		"voice":           t.cfg.Voice,
+		"response_format": t.cfg.Format,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	audio, err := t.cfg.do(ctx, "/audio/speech", "application/json", bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, "", err
+	}
+
+	t.Debugf("speech synthesized: chars=%d, bytes=%d", len(text), len(audio))
+
+	return audio, formatMIMEType(t.cfg.Format), nil
+}
+
+// do posts body to path and returns the response body of a 200 response.
+func (cfg *OpenAIConfig) do(
+	ctx context.Context,
+	path, contentType string,
+	body io.Reader,
+) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", cfg.BaseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	if cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
+	}
+
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		cfg.Logger.Errorf("speech API call failed: %v", err)
+		return nil, fmt.Errorf("failed to call speech API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		cfg.Logger.Errorf("speech API returned error: status=%d, body=%s", resp.StatusCode, string(respBody))
+		return nil, fmt.Errorf("speech API returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	return respBody, nil
+}
+
+// Ensure interfaces are implemented
+var (
+	_ SpeechToText = (*OpenAISpeechToText)(nil)
+	_ TextToSpeech = (*OpenAITextToSpeech)(nil)
+)
+
+// audioExtension returns the file extension of an audio MIME type, ".webm" if unknown.
+func audioExtension(mimeType string) string {
+	mediaType, _, _ := mime.ParseMediaType(mimeType)
+	switch mediaType {
+	case "audio/wav", "audio/x-wav", "audio/wave":
+		return ".wav"
+	case "audio/mpeg", "audio/mp3":
+		return ".mp3"
+	case "audio/ogg", "audio/opus":
+		return ".ogg"
+	case "audio/mp4", "audio/m4a", "audio/x-m4a":
+		return ".m4a"
+	case "audio/flac":
+		return ".flac"
+	case "audio/aac":
+		return ".aac"
+	case "audio/pcm", "audio/l16":
+		return ".pcm"
+	default:
+		return ".webm"
+	}
+}
+
+// formatMIMEType returns the MIME type of a TTS response format.
+func formatMIMEType(format string) string {
+	switch format {
+	case "opus":
+		return "audio/ogg"
+	case "aac":
+		return "audio/aac"
+	case "flac":
+		return "audio/flac"
+	case "wav":
+		return "audio/wav"
+	case "pcm":
+		return "audio/pcm"
+	default:
+		return "audio/mpeg"
+	}
+}
@@ -0,0 +1,332 @@
+package voice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/adk/artifact"
+	"google.golang.org/adk/model"
+	"google.golang.org/adk/session"
+	"google.golang.org/genai"
+)
+
+func TestOpenAISpeechToText(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/audio/transcriptions" {
+			t.Errorf("Expected /audio/transcriptions, got %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			t.Errorf("Expected bearer token, got %q", r.Header.Get("Authorization"))
+		}
+
+		if r.FormValue("model") != "whisper-1" || r.FormValue("language") != "en" {
+			t.Errorf("Unexpected form: model=%q, language=%q", r.FormValue("model"), r.FormValue("language"))
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Fatalf("Expected file: %v", err)
+		}
+		data, _ := io.ReadAll(file)
+		if header.Filename != "audio.wav" || string(data) != "RIFF" {
+			t.Errorf("Unexpected file: name=%s, data=%q", header.Filename, data)
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{"text": " What's the weather? "})
+	}))
+	defer server.Close()
+
+	stt := NewOpenAISpeechToText(OpenAIConfig{
+		BaseURL:    server.URL + "/",
+		APIKey:     "test-key",
+		Model:      "whisper-1",
+		Language:   "en",
+		HTTPClient: server.Client(),
+	})
+
+	text, err := stt.Transcribe(context.Background(), []byte("RIFF"), "audio/wav")
+	if err != nil {
+		t.Fatalf("Transcribe failed: %v", err)
+	}
+	if text != "What's the weather?" {
+		t.Errorf("Transcribe() = %q", text)
+	}
+}
+
+func TestOpenAITextToSpeech(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqBody map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		if r.URL.Path != "/audio/speech" || reqBody["input"] != "Sunny." ||
+			reqBody["voice"] != "alloy" || reqBody["response_format"] != "opus" {
+			t.Errorf("Unexpected request: path=%s, body=%v", r.URL.Path, reqBody)
+		}
+		w.Write([]byte("OggS"))
+	}))
+	defer server.Close()
+
+	tts := NewOpenAITextToSpeech(OpenAIConfig{
+		BaseURL:    server.URL,
+		Model:      "tts-1",
+		Format:     "opus",
+		HTTPClient: server.Client(),
+	})
+
+	audio, mimeType, err := tts.Synthesize(context.Background(), "Sunny.")
+	if err != nil {
+		t.Fatalf("Synthesize failed: %v", err)
+	}
+	if string(audio) != "OggS" || mimeType != "audio/ogg" {
+		t.Errorf("Synthesize() = %q, %s", audio, mimeType)
+	}
+}
+
+func TestOpenAISpeechServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "bad audio"}`))
+	}))
+	defer server.Close()
+
+	stt := NewOpenAISpeechToText(OpenAIConfig{BaseURL: server.URL, HTTPClient: server.Client()})
+	if _, err := stt.Transcribe(context.Background(), []byte("x"), "audio/webm"); err == nil {
+		t.Error("Expected error for server error response")
+	}
+}
+
+// fakeSTT returns a fixed transcript.
+type fakeSTT struct{ text string }
+
+func (f *fakeSTT) Transcribe(context.Context, []byte, string) (string, error) { return f.text, nil }
+
+// fakeTTS returns the text as audio.
+type fakeTTS struct{}
+
+func (*fakeTTS) Synthesize(_ context.Context, text string) ([]byte, string, error) {
+	return []byte("audio:" + text), "audio/mpeg", nil
+}
+
+// fakeRun yields a partial and a final reply for every message.
+func fakeRun(_ context.Context, _, _ string, msg *genai.Content) iter.Seq2[*session.Event, error] {
+	return func(yield func(*session.Event, error) bool) {
+		partial := &session.Event{Author: "weather_agent", LLMResponse: model.LLMResponse{
+			Content: genai.NewContentFromText("It is", genai.RoleModel),
+			Partial: true,
+		}}
+		if !yield(partial, nil) {
+			return
+		}
+
+		final := &session.Event{Author: "weather_agent", LLMResponse: model.LLMResponse{
+			Content: genai.NewContentFromText("Reply to: "+msg.Parts[0].Text, genai.RoleModel),
+		}}
+		yield(final, nil)
+	}
+}
+
+func newTestBridge(t *testing.T, text string, artifacts artifact.Service) *Bridge {
+	t.Helper()
+
+	b, err := New(Config{
+		AppName:   "voice_app",
+		Run:       fakeRun,
+		STT:       &fakeSTT{text: text},
+		TTS:       &fakeTTS{},
+		Artifacts: artifacts,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return b
+}
+
+func TestBridgeTurn(t *testing.T) {
+	ctx := context.Background()
+	artifacts := artifact.InMemoryService()
+	b := newTestBridge(t, "weather in Paris?", artifacts)
+
+	var outputs []Output
+	err := b.Turn(ctx, "user1", "sess1", []byte("RIFF"), "audio/wav", func(out Output) error {
+		outputs = append(outputs, out)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Turn failed: %v", err)
+	}
+
+	var types []string
+	for _, out := range outputs {
+		types = append(types, string(out.Type))
+	}
+	if got := strings.Join(types, ","); got != "transcript,transcript,audio,turn_complete" {
+		t.Fatalf("output types = %s", got)
+	}
+
+	if outputs[0].Author != "user" || outputs[0].Text != "weather in Paris?" {
+		t.Errorf("Unexpected user transcript: %+v", outputs[0])
+	}
+	if outputs[1].Author != "weather_agent" || outputs[1].Text != "Reply to: weather in Paris?" {
+		t.Errorf("Unexpected agent transcript: %+v", outputs[1])
+	}
+	if string(outputs[2].Audio) != "audio:Reply to: weather in Paris?" || outputs[2].MIMEType != "audio/mpeg" {
+		t.Errorf("Unexpected audio: %+v", outputs[2])
+	}
+
+	list, err := artifacts.List(ctx, &artifact.ListRequest{
+		AppName: "voice_app", UserID: "user1", SessionID: "sess1",
+	})
+	if err != nil {
+		t.Fatalf("List artifacts failed: %v", err)
+	}
+	if len(list.FileNames) != 2 {
+		t.Errorf("Expected user and agent audio artifacts, got %v", list.FileNames)
+	}
+	if outputs[0].Artifact == "" || !strings.HasSuffix(outputs[2].Artifact, ".mp3") {
+		t.Errorf("Unexpected artifact names: %q, %q", outputs[0].Artifact, outputs[2].Artifact)
+	}
+}
+
+func TestBridgeTurnErrors(t *testing.T) {
+	ctx := context.Background()
+	emit := func(Output) error { return nil }
+
+	err := newTestBridge(t, "", nil).Turn(ctx, "u", "s", []byte("x"), "audio/wav", emit)
+	if !errors.Is(err, ErrNoSpeech) {
+		t.Errorf("Expected ErrNoSpeech, got %v", err)
+	}
+
+	b := newTestBridge(t, "hi", nil)
+	b.maxAudioBytes = 2
+	if err := b.Turn(ctx, "u", "s", []byte("xyz"), "audio/wav", emit); !errors.Is(err, ErrAudioTooLarge) {
+		t.Errorf("Expected ErrAudioTooLarge, got %v", err)
+	}
+
+	if _, err := New(Config{Run: fakeRun, STT: &fakeSTT{}}); err == nil {
+		t.Error("Expected error without text-to-speech")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	server := httptest.NewServer(NewHandler(newTestBridge(t, "hello", nil), HandlerConfig{}))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "?userId=user1&sessionId=sess1"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	conn.WriteJSON(map[string]string{"type": "start", "mimeType": "audio/webm"})
+	conn.WriteMessage(websocket.BinaryMessage, []byte("chunk1"))
+	conn.WriteMessage(websocket.BinaryMessage, []byte("chunk2"))
+	conn.WriteJSON(map[string]string{"type": "end"})
+
+	var types []string
+	for {
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("ReadMessage failed: %v", err)
+		}
+		if msgType == websocket.BinaryMessage {
+			if string(data) != "audio:Reply to: hello" {
+				t.Errorf("Unexpected audio frame: %q", data)
+			}
+			types = append(types, "binary")
+			continue
+		}
+
+		var out Output
+		if err := json.Unmarshal(data, &out); err != nil {
+			t.Fatalf("invalid output: %v", err)
+		}
+		types = append(types, string(out.Type))
+		if out.Type == OutputTurnComplete {
+			break
+		}
+	}
+
+	if got := strings.Join(types, ","); got != "transcript,transcript,audio,binary,turn_complete" {
+		t.Errorf("frames = %s", got)
+	}
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 without userId and sessionId, got %d", resp.StatusCode)
+	}
+}
+
+func TestHandlerAudioTooLarge(t *testing.T) {
+	b := newTestBridge(t, "hello", nil)
+	b.maxAudioBytes = 8
+	server := httptest.NewServer(NewHandler(b, HandlerConfig{}))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "?userId=user1&sessionId=sess1"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	// The second chunk overflows the cap, the third one is dropped
+	conn.WriteJSON(map[string]string{"type": "start", "mimeType": "audio/webm"})
+	conn.WriteMessage(websocket.BinaryMessage, []byte("chunk1"))
+	conn.WriteMessage(websocket.BinaryMessage, []byte("chunk2"))
+	conn.WriteMessage(websocket.BinaryMessage, []byte("chunk3"))
+	conn.WriteJSON(map[string]string{"type": "end"})
+
+	conn.WriteJSON(map[string]string{"type": "start", "mimeType": "audio/webm"})
+	conn.WriteMessage(websocket.BinaryMessage, []byte("chunk4"))
+	conn.WriteJSON(map[string]string{"type": "end"})
+
+	var types []string
+	for {
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("ReadMessage failed: %v", err)
+		}
+		if msgType == websocket.BinaryMessage {
+			types = append(types, "binary")
+			continue
+		}
+
+		var out struct {
+			Type  OutputType `json:"type"`
+			Error string     `json:"error"`
+		}
+		if err := json.Unmarshal(data, &out); err != nil {
+			t.Fatalf("invalid output: %v", err)
+		}
+		if out.Type == OutputError && out.Error != ErrAudioTooLarge.Error() {
+			t.Errorf("Unexpected error frame: %q", out.Error)
+		}
+		types = append(types, string(out.Type))
+		if out.Type == OutputTurnComplete {
+			break
+		}
+	}
+
+	if got := strings.Join(types, ","); got != "error,transcript,transcript,audio,binary,turn_complete" {
+		t.Errorf("frames = %s", got)
+	}
+
+	// A frame over the read limit closes the connection
+	conn.WriteMessage(websocket.BinaryMessage, make([]byte, maxControlBytes+1))
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Error("Expected the connection to close after an oversized frame")
+	}
+}
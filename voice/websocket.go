@@ -0,0 +1,191 @@
+package voice
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+
+	"github.com/bytedance/sonic"
+	"github.com/gorilla/websocket"
+)
+
+// maxControlBytes is the read limit of a connection whose audio cap is smaller, so
+// control messages still fit.
+const maxControlBytes = 4 << 10
+
+// control is a client text message of the WebSocket protocol.
+type control struct {
+	// Type is "start" (begins an utterance) or "end" (runs the turn on it).
+	Type     string `json:"type"`
+	MIMEType string `json:"mimeType,omitempty"`
+}
+
+// HandlerConfig configures a Handler.
+type HandlerConfig struct {
+	// Optional. Session returns the user and session of a connection request.
+	// Default: the "userId" and "sessionId" query parameters.
+	Session func(r *http.Request) (userID, sessionID string, err error)
+
+	// Optional. CheckOrigin validates the Origin of the upgrade request.
+	// Default: same origin only.
+	CheckOrigin func(r *http.Request) bool
+}
+
+// Handler serves voice turns over WebSocket.
+//
+// The client sends {"type": "start", "mimeType": "audio/webm"}, the utterance as
+// binary frames, then {"type": "end"}. The server answers with an Output JSON text
+// frame per transcript, an Output text frame followed by a binary frame of audio
+// per reply, and a final {"type": "turn_complete"}. A failed turn is reported as
+// {"type": "error", "error": "..."}. Turns of a connection run one at a time.
+//
+// A frame larger than MaxAudioBytes closes the connection. An utterance growing
+// past it is reported with an ErrAudioTooLarge error, and its next frames are
+// dropped until the next "start".
+type Handler struct {
+	bridge   *Bridge
+	session  func(r *http.Request) (string, string, error)
+	upgrader websocket.Upgrader
+}
+
+// NewHandler creates a WebSocket Handler for b.
+func NewHandler(b *Bridge, cfg HandlerConfig) *Handler {
+	if cfg.Session == nil {
+		cfg.Session = querySession
+	}
+
+	return &Handler{
+		bridge:   b,
+		session:  cfg.Session,
+		upgrader: websocket.Upgrader{CheckOrigin: cfg.CheckOrigin},
+	}
+}
+
+// ServeHTTP upgrades the request and serves turns until the client disconnects.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, sessionID, err := h.session(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has replied with an HTTP error
+		h.bridge.Warnf("voice websocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	// NOTE: No frame may exceed the audio of a whole utterance
+	conn.SetReadLimit(int64(max(h.bridge.maxAudioBytes, maxControlBytes)))
+
+	h.bridge.Infof("voice session connected: user=%s, session=%s", userID, sessionID)
+
+	var (
+		audio    bytes.Buffer
+		mimeType string
+		// tooLarge drops the frames of an utterance past the audio cap
+		tooLarge bool
+	)
+	for {
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				h.bridge.Warnf("voice websocket read failed: %v", err)
+			}
+			return
+		}
+
+		if msgType == websocket.BinaryMessage {
+			if tooLarge {
+				continue
+			}
+			if audio.Len()+len(data) > h.bridge.maxAudioBytes {
+				audio.Reset()
+				tooLarge = true
+				if writeError(conn, ErrAudioTooLarge) != nil {
+					return
+				}
+				continue
+			}
+			audio.Write(data)
+			continue
+		}
+
+		var ctl control
+		if err := sonic.Unmarshal(data, &ctl); err != nil {
+			if writeError(conn, errors.New("invalid control message")) != nil {
+				return
+			}
+			continue
+		}
+
+		switch ctl.Type {
+		case "start":
+			audio.Reset()
+			tooLarge = false
+			mimeType = ctl.MIMEType
+
+		case "end":
+			if ctl.MIMEType != "" {
+				mimeType = ctl.MIMEType
+			}
+
+			// The utterance past the cap was reported when it was dropped
+			if tooLarge {
+				tooLarge = false
+				continue
+			}
+
+			// The artifact service may keep the audio, so it must not share the buffer
+			utterance := bytes.Clone(audio.Bytes())
+			audio.Reset()
+
+			err := h.bridge.Turn(r.Context(), userID, sessionID, utterance, mimeType,
+				func(out Output) error { return writeOutput(conn, out) })
+			if err != nil {
+				h.bridge.Warnf("voice turn failed: session=%s, err=%v", sessionID, err)
+				if writeError(conn, err) != nil {
+					return
+				}
+			}
+
+		default:
+			if writeError(conn, errors.New("unknown control message type")) != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeOutput writes out as a JSON text frame, followed by its audio as a binary frame.
+func writeOutput(conn *websocket.Conn, out Output) error {
+	data, err := sonic.Marshal(out)
+	if err != nil {
+		return err
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		return err
+	}
+	if out.Type == OutputAudio {
+		return conn.WriteMessage(websocket.BinaryMessage, out.Audio)
+	}
+	return nil
+}
+
+func writeError(conn *websocket.Conn, turnErr error) error {
+	data, err := sonic.Marshal(map[string]string{"type": string(OutputError), "error": turnErr.Error()})
+	if err != nil {
+		return err
+	}
+	return conn.WriteMessage(websocket.TextMessage, data)
+}
+
+func querySession(r *http.Request) (string, string, error) {
+	userID, sessionID := r.URL.Query().Get("userId"), r.URL.Query().Get("sessionId")
+	if userID == "" || sessionID == "" {
+		return "", "", errors.New("userId and sessionId are required")
+	}
+	return userID, sessionID, nil
+}